@@ -0,0 +1,151 @@
+package glance
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	auditLogFileName = "audit.log"
+	// how many of the most recent entries are kept in memory and shown on the audit page
+	auditLogRecentEntries = 500
+)
+
+type auditEntry struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user,omitempty"`
+	Address string    `json:"address"`
+	Action  string    `json:"action"`
+	Target  string    `json:"target"`
+	Before  string    `json:"before,omitempty"`
+	After   string    `json:"after,omitempty"`
+}
+
+// Keeps a record of the actions that change something, such as checking off a
+// reminder or toggling a light. If a data path is configured every entry is
+// appended to a file as a line of JSON, existing lines are never rewritten.
+type auditLog struct {
+	mu       sync.Mutex
+	filePath string
+	recent   []auditEntry
+}
+
+func newAuditLog(dataPath string) *auditLog {
+	log := &auditLog{}
+
+	if dataPath == "" {
+		return log
+	}
+
+	log.filePath = filepath.Join(dataPath, auditLogFileName)
+
+	file, err := os.Open(log.filePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Failed to read audit log", "path", log.filePath, "error", err)
+		}
+
+		return log
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+
+		log.recent = append(log.recent, entry)
+		if len(log.recent) > auditLogRecentEntries*2 {
+			log.recent = slices.Clone(log.recent[len(log.recent)-auditLogRecentEntries:])
+		}
+	}
+
+	if len(log.recent) > auditLogRecentEntries {
+		log.recent = log.recent[len(log.recent)-auditLogRecentEntries:]
+	}
+
+	return log
+}
+
+// Before and after describe what changed, either can be left empty when
+// there's nothing meaningful to show, such as when something gets created.
+// The request is nil for changes that Glance makes on its own
+func (l *auditLog) record(r *http.Request, action, target, before, after string) {
+	if l == nil {
+		return
+	}
+
+	entry := auditEntry{
+		Time:   time.Now(),
+		User:   "Glance",
+		Action: action,
+		Target: target,
+		Before: before,
+		After:  after,
+	}
+
+	if r != nil {
+		entry.User = ""
+		entry.Address = r.RemoteAddr
+
+		if user := requestUser(r); user != nil {
+			entry.User = user.Name
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.recent = append(l.recent, entry)
+	if len(l.recent) > auditLogRecentEntries {
+		l.recent = slices.Clone(l.recent[len(l.recent)-auditLogRecentEntries:])
+	}
+
+	if l.filePath == "" {
+		return
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		slog.Error("Failed to encode audit log entry", "error", err)
+		return
+	}
+
+	file, err := os.OpenFile(l.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		slog.Error("Failed to open audit log", "path", l.filePath, "error", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		slog.Error("Failed to write audit log", "path", l.filePath, "error", err)
+	}
+}
+
+// Returns the most recent entries, newest first
+func (l *auditLog) entries() []auditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := slices.Clone(l.recent)
+	slices.Reverse(entries)
+
+	return entries
+}
+
+// The audit log is shown on the diagnostics page, this keeps links to where
+// it used to be working
+func (a *application) handleAuditLogRequest(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, a.Config.Server.BaseURL+"/diagnostics#audit-log", http.StatusMovedPermanently)
+}
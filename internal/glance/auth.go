@@ -0,0 +1,1012 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var loginTemplate = mustParseTemplate("login.html", "document.html")
+
+const (
+	authSessionCookieName = "glance_auth"
+	authOIDCCookieName    = "glance_oidc"
+	authSecretKeyStateKey = "auth-secret-key"
+	authOIDCUserPrefix    = "oidc:"
+	// how long someone has to finish logging in with the OIDC provider
+	authOIDCFlowTimeout = 10 * time.Minute
+)
+
+type authConfig struct {
+	Users []struct {
+		Username string   `yaml:"username"`
+		Password string   `yaml:"password"`
+		Groups   []string `yaml:"groups"`
+		hash     *passwordHash
+	} `yaml:"users"`
+	OIDC            *authOIDCConfig  `yaml:"oidc"`
+	Tokens          []authToken      `yaml:"tokens"`
+	Proxy           *authProxyConfig `yaml:"proxy"`
+	SecretKey       string           `yaml:"secret-key"`
+	SessionDuration durationField    `yaml:"session-duration"`
+}
+
+type authOIDCConfig struct {
+	Name         string   `yaml:"name"`
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client-id"`
+	ClientSecret string   `yaml:"client-secret"`
+	Scopes       []string `yaml:"scopes"`
+	RedirectURL  string   `yaml:"redirect-url"`
+	AllowedUsers []string `yaml:"allowed-users"`
+}
+
+// Tokens let scripts and other services use the API without a user, they're
+// sent as a bearer token in the Authorization header
+type authToken struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+	// either read, which only allows requests that don't change anything, or write
+	Scope string `yaml:"scope"`
+	// when set the token can only be used with the API of widgets of these types
+	Widgets []string `yaml:"widgets"`
+}
+
+func (t *authToken) allowsMethod(method string) bool {
+	return t.Scope == "write" || isSafeMethod(method)
+}
+
+func (t *authToken) allowsWidget(widget widget) bool {
+	return len(t.Widgets) == 0 || slices.Contains(t.Widgets, widget.GetType())
+}
+
+// Used when an authenticating reverse proxy, such as Authelia or oauth2-proxy,
+// sits in front of Glance and passes along who is logged in through headers
+type authProxyConfig struct {
+	UserHeader     string   `yaml:"user-header"`
+	GroupsHeader   string   `yaml:"groups-header"`
+	TrustedProxies []string `yaml:"trusted-proxies"`
+	LogoutURL      string   `yaml:"logout-url"`
+	trustedNets    []*net.IPNet
+}
+
+func (c *authConfig) IsEnabled() bool {
+	return len(c.Users) > 0 || c.OIDC != nil || c.Proxy != nil
+}
+
+type authUser struct {
+	Name   string
+	Groups []string
+	// set when the request was authenticated with an API token
+	token *authToken
+}
+
+type authUserContextKey struct{}
+
+// Pages and widgets can be limited to specific users and groups, leaving both
+// empty makes them visible to everyone who is logged in
+type accessControl struct {
+	AllowedUsers  []string `yaml:"allowed-users"`
+	AllowedGroups []string `yaml:"allowed-groups"`
+}
+
+func (ac *accessControl) isRestricted() bool {
+	return len(ac.AllowedUsers) > 0 || len(ac.AllowedGroups) > 0
+}
+
+func (ac *accessControl) allows(user *authUser) bool {
+	if !ac.isRestricted() {
+		return true
+	}
+
+	if user == nil {
+		return false
+	}
+
+	if slices.Contains(ac.AllowedUsers, user.Name) {
+		return true
+	}
+
+	for _, group := range user.Groups {
+		if slices.Contains(ac.AllowedGroups, group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The contents of groups and split columns get rendered as a whole, so the
+// widgets within them can't be hidden individually
+func validateWidgetAccess(widget widget, authEnabled, nested bool) error {
+	if widget.getAccessControl().isRestricted() {
+		if !authEnabled {
+			return formatWidgetInitError(errors.New("allowed-users and allowed-groups require auth to be enabled"), widget)
+		}
+
+		if nested {
+			return formatWidgetInitError(errors.New("allowed-users and allowed-groups can only be set on widgets placed directly within columns"), widget)
+		}
+	}
+
+	if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+		for _, child := range container.getChildWidgets() {
+			if err := validateWidgetAccess(child, authEnabled, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateAuthConfig(c *authConfig) error {
+	usernames := make(map[string]struct{}, len(c.Users))
+
+	for i := range c.Users {
+		user := &c.Users[i]
+
+		if user.Username == "" {
+			return fmt.Errorf("auth: user %d has no username", i+1)
+		}
+
+		if _, exists := usernames[user.Username]; exists {
+			return fmt.Errorf("auth: multiple users with the username %s", user.Username)
+		}
+		usernames[user.Username] = struct{}{}
+
+		if user.Password == "" {
+			return fmt.Errorf("auth: user %s has no password", user.Username)
+		}
+
+		user.hash = nil
+		if isHashedPassword(user.Password) {
+			hash, err := parsePasswordHash(user.Password)
+			if err != nil {
+				return fmt.Errorf("auth: user %s: invalid password hash: %v", user.Username, err)
+			}
+
+			user.hash = hash
+		}
+	}
+
+	if c.OIDC != nil {
+		if c.OIDC.Issuer == "" || c.OIDC.ClientID == "" || c.OIDC.ClientSecret == "" {
+			return errors.New("auth: oidc: issuer, client-id and client-secret are required")
+		}
+
+		c.OIDC.Issuer = strings.TrimRight(c.OIDC.Issuer, "/")
+
+		if c.OIDC.Name == "" {
+			c.OIDC.Name = "single sign-on"
+		}
+
+		if len(c.OIDC.Scopes) == 0 {
+			c.OIDC.Scopes = []string{"openid", "profile", "email"}
+		} else if !slices.Contains(c.OIDC.Scopes, "openid") {
+			c.OIDC.Scopes = append([]string{"openid"}, c.OIDC.Scopes...)
+		}
+	}
+
+	if c.Proxy != nil {
+		if len(c.Proxy.TrustedProxies) == 0 {
+			return errors.New("auth: proxy: trusted-proxies is required")
+		}
+
+		for _, value := range c.Proxy.TrustedProxies {
+			if !strings.Contains(value, "/") {
+				value += ternary(strings.Contains(value, ":"), "/128", "/32")
+			}
+
+			_, network, err := net.ParseCIDR(value)
+			if err != nil {
+				return fmt.Errorf("auth: proxy: invalid trusted proxy %s", value)
+			}
+
+			c.Proxy.trustedNets = append(c.Proxy.trustedNets, network)
+		}
+
+		if c.Proxy.UserHeader == "" {
+			c.Proxy.UserHeader = "Remote-User"
+		}
+
+		if c.Proxy.GroupsHeader == "" {
+			c.Proxy.GroupsHeader = "Remote-Groups"
+		}
+	}
+
+	if len(c.Tokens) > 0 && !c.IsEnabled() {
+		return errors.New("auth: tokens require users, oidc or proxy to be configured")
+	}
+
+	tokenNames := make(map[string]struct{}, len(c.Tokens))
+
+	for i := range c.Tokens {
+		token := &c.Tokens[i]
+
+		if token.Name == "" {
+			return fmt.Errorf("auth: token %d has no name", i+1)
+		}
+
+		if _, exists := tokenNames[token.Name]; exists {
+			return fmt.Errorf("auth: multiple tokens with the name %s", token.Name)
+		}
+		tokenNames[token.Name] = struct{}{}
+
+		if _, exists := usernames[token.Name]; exists {
+			return fmt.Errorf("auth: token %s has the same name as a user", token.Name)
+		}
+
+		if len(token.Token) < 16 {
+			return fmt.Errorf("auth: token %s must be at least 16 characters long", token.Name)
+		}
+
+		if token.Scope == "" {
+			token.Scope = "read"
+		} else if token.Scope != "read" && token.Scope != "write" {
+			return fmt.Errorf("auth: token %s: scope can only be either read or write", token.Name)
+		}
+
+		for _, widgetType := range token.Widgets {
+			if _, err := newWidget(widgetType); err != nil {
+				return fmt.Errorf("auth: token %s: %v", token.Name, err)
+			}
+		}
+	}
+
+	if c.SessionDuration <= 0 {
+		c.SessionDuration = durationField(30 * 24 * time.Hour)
+	}
+
+	return nil
+}
+
+type authenticator struct {
+	config    *authConfig
+	secretKey []byte
+	logins    *loginLimiter
+
+	// hashing a password takes a while, so scripts sending it with every
+	// request only pay for it once
+	verifiedMu sync.Mutex
+	verified   map[[sha256.Size]byte]struct{}
+
+	discoveryMu sync.Mutex
+	discovery   *oidcDiscovery
+}
+
+// When no secret key is configured one gets generated and kept in the state,
+// which means that sessions survive restarts only if a data path is set
+func newAuthenticator(config *authConfig, state *stateStore) (*authenticator, error) {
+	auth := &authenticator{
+		config:   config,
+		logins:   newLoginLimiter(),
+		verified: make(map[[sha256.Size]byte]struct{}),
+	}
+
+	if config.SecretKey != "" {
+		auth.secretKey = []byte(config.SecretKey)
+		return auth, nil
+	}
+
+	var key string
+	if !state.get(authSecretKeyStateKey, &key) {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("generating auth secret key: %v", err)
+		}
+
+		key = hex.EncodeToString(random)
+		state.set(authSecretKeyStateKey, key)
+	}
+
+	auth.secretKey = []byte(key)
+	return auth, nil
+}
+
+// Values are signed rather than encrypted, so they shouldn't contain anything
+// that can't be seen by whoever has the cookie
+func (a *authenticator) sign(value string) string {
+	mac := hmac.New(sha256.New, a.secretKey)
+	mac.Write([]byte(value))
+
+	return base64.RawURLEncoding.EncodeToString([]byte(value)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (a *authenticator) verify(signed string) (string, bool) {
+	encodedValue, encodedMAC, found := strings.Cut(signed, ".")
+	if !found {
+		return "", false
+	}
+
+	value, err := base64.RawURLEncoding.DecodeString(encodedValue)
+	if err != nil {
+		return "", false
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return "", false
+	}
+
+	mac := hmac.New(sha256.New, a.secretKey)
+	mac.Write(value)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return "", false
+	}
+
+	return string(value), true
+}
+
+// Sessions are stored in the cookie itself as the name of the user, when the
+// session expires and for users that logged in through OIDC the groups they're
+// in, all separated by newlines
+func (a *authenticator) setSessionCookie(w http.ResponseWriter, r *http.Request, basePath, username string, groups []string) {
+	expires := time.Now().Add(time.Duration(a.config.SessionDuration))
+	value := append([]string{username, strconv.FormatInt(expires.Unix(), 10)}, groups...)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     authSessionCookieName,
+		Value:    a.sign(strings.Join(value, "\n")),
+		Path:     basePath,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (a *authenticator) sessionUser(r *http.Request) (*authUser, bool) {
+	cookie, err := r.Cookie(authSessionCookieName)
+	if err != nil {
+		return nil, false
+	}
+
+	value, ok := a.verify(cookie.Value)
+	if !ok {
+		return nil, false
+	}
+
+	parts := strings.Split(value, "\n")
+	if len(parts) < 2 {
+		return nil, false
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return nil, false
+	}
+
+	// users who have since been removed from the config lose access right away
+	return a.lookupUser(parts[0], parts[2:])
+}
+
+// Users that logged in through OIDC are prefixed with oidc: so that they can't
+// be mistaken for users with a password. Their groups come from the provider,
+// whereas the groups of users with a password are always taken from the config
+// so that changing them doesn't require logging in again
+func (a *authenticator) lookupUser(username string, oidcGroups []string) (*authUser, bool) {
+	if oidcUser, isOIDC := strings.CutPrefix(username, authOIDCUserPrefix); isOIDC {
+		if a.config.OIDC == nil {
+			return nil, false
+		}
+
+		if len(a.config.OIDC.AllowedUsers) > 0 && !slices.Contains(a.config.OIDC.AllowedUsers, oidcUser) {
+			return nil, false
+		}
+
+		return &authUser{Name: oidcUser, Groups: oidcGroups}, true
+	}
+
+	for i := range a.config.Users {
+		if a.config.Users[i].Username == username {
+			return &authUser{Name: username, Groups: a.config.Users[i].Groups}, true
+		}
+	}
+
+	return nil, false
+}
+
+func (a *authenticator) checkPassword(username, password string) bool {
+	for i := range a.config.Users {
+		user := &a.config.Users[i]
+		if user.Username != username {
+			continue
+		}
+
+		if user.hash != nil {
+			return a.checkHashedPassword(user.hash, username, password)
+		}
+
+		// hashed so that the comparison takes the same time regardless of the length of the password
+		given := sha256.Sum256([]byte(password))
+		expected := sha256.Sum256([]byte(user.Password))
+
+		return subtle.ConstantTimeCompare(given[:], expected[:]) == 1
+	}
+
+	return false
+}
+
+func (a *authenticator) checkHashedPassword(hash *passwordHash, username, password string) bool {
+	key := sha256.Sum256([]byte(username + "\x00" + password))
+
+	a.verifiedMu.Lock()
+	_, verified := a.verified[key]
+	a.verifiedMu.Unlock()
+
+	if verified {
+		return true
+	}
+
+	if !hash.matches(password) {
+		return false
+	}
+
+	a.verifiedMu.Lock()
+	a.verified[key] = struct{}{}
+	a.verifiedMu.Unlock()
+
+	return true
+}
+
+func (a *authenticator) tokenUser(r *http.Request) (*authUser, bool) {
+	value, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || value == "" {
+		return nil, false
+	}
+
+	given := sha256.Sum256([]byte(value))
+	var matched *authToken
+
+	for i := range a.config.Tokens {
+		expected := sha256.Sum256([]byte(a.config.Tokens[i].Token))
+		if subtle.ConstantTimeCompare(given[:], expected[:]) == 1 {
+			matched = &a.config.Tokens[i]
+		}
+	}
+
+	if matched == nil {
+		return nil, false
+	}
+
+	return &authUser{Name: matched.Name, token: matched}, true
+}
+
+// The headers are only trusted when the request comes directly from one of the
+// trusted proxies, since anyone else could set them to whatever they want
+func (a *authenticator) proxyUser(r *http.Request) (*authUser, bool) {
+	proxy := a.config.Proxy
+	if proxy == nil {
+		return nil, false
+	}
+
+	username := strings.TrimSpace(r.Header.Get(proxy.UserHeader))
+	if username == "" {
+		return nil, false
+	}
+
+	ip := net.ParseIP(remoteHost(r))
+	if ip == nil || !slices.ContainsFunc(proxy.trustedNets, func(n *net.IPNet) bool { return n.Contains(ip) }) {
+		return nil, false
+	}
+
+	user := &authUser{Name: username}
+	for _, group := range strings.Split(r.Header.Get(proxy.GroupsHeader), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			user.Groups = append(user.Groups, group)
+		}
+	}
+
+	return user, true
+}
+
+func isSecureRequest(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// Only allows redirecting to paths on the same site after logging in
+func safeRedirectPath(path, fallback string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return fallback
+	}
+
+	return path
+}
+
+func isPublicPath(path string) bool {
+	switch {
+	case path == "/login", path == "/logout", path == "/api/healthz", path == "/api/health", path == "/manifest.json":
+		return true
+	case strings.HasPrefix(path, "/auth/oidc/"), strings.HasPrefix(path, "/static/"):
+		return true
+	// webhooks are authenticated through their own secrets
+	case strings.HasPrefix(path, "/api/webhooks/"):
+		return true
+	}
+
+	return false
+}
+
+// Paths meant to be requested by scripts and other services rather than
+// browsers, they accept API tokens and aren't redirected to the login page
+func isAPIPath(path string) bool {
+	return strings.HasPrefix(path, "/api/") || path == "/metrics"
+}
+
+// Requests can be authenticated either through a session cookie set when logging
+// in or, for use with scripts and the API, by sending the username and password
+// of a user with basic authentication
+func (a *application) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if user, ok := a.auth.proxyUser(r); ok {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authUserContextKey{}, user)))
+			return
+		}
+
+		if user, ok := a.auth.sessionUser(r); ok {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authUserContextKey{}, user)))
+			return
+		}
+
+		if isAPIPath(r.URL.Path) {
+			if user, ok := a.auth.tokenUser(r); ok {
+				if !user.token.allowsMethod(r.Method) {
+					http.Error(w, "token does not have the write scope", http.StatusForbidden)
+					return
+				}
+
+				// the remaining checks happen in canAccessWidget
+				if len(user.token.Widgets) > 0 && !strings.HasPrefix(r.URL.Path, "/api/widgets/") {
+					http.Error(w, "token can only be used with the API of widgets", http.StatusForbidden)
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authUserContextKey{}, user)))
+				return
+			}
+		}
+
+		if username, password, ok := r.BasicAuth(); ok {
+			address := remoteHost(r)
+			if !a.auth.logins.allows(username, address) {
+				http.Error(w, "too many failed login attempts, try again later", http.StatusTooManyRequests)
+				return
+			}
+
+			if a.auth.checkPassword(username, password) {
+				user, _ := a.auth.lookupUser(username, nil)
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authUserContextKey{}, user)))
+				return
+			}
+
+			slog.Warn("Failed login attempt", "username", username, "remote_addr", r.RemoteAddr)
+			a.auth.logins.fail(username, address)
+		}
+
+		if isAPIPath(r.URL.Path) {
+			if len(a.Config.Auth.Users) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Glance"`)
+			}
+
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		redirect := strings.TrimRight(a.basePath(), "/") + r.URL.RequestURI()
+		http.Redirect(w, r, a.Config.Server.BaseURL+"/login?redirect="+url.QueryEscape(redirect), http.StatusSeeOther)
+	})
+}
+
+// Returns nil when auth isn't enabled or for requests to public paths
+func requestUser(r *http.Request) *authUser {
+	user, _ := r.Context().Value(authUserContextKey{}).(*authUser)
+	return user
+}
+
+type loginTemplateData struct {
+	App      *application
+	Redirect string
+	Username string
+	Error    string
+}
+
+func (a *application) renderLoginPage(w http.ResponseWriter, status int, data loginTemplateData) {
+	data.App = a
+
+	var responseBytes bytes.Buffer
+	if err := loginTemplate.Execute(&responseBytes, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(status)
+	w.Write(responseBytes.Bytes())
+}
+
+func (a *application) handleLoginPageRequest(w http.ResponseWriter, r *http.Request) {
+	redirect := safeRedirectPath(r.URL.Query().Get("redirect"), a.Config.Server.BaseURL+"/")
+
+	if _, ok := a.auth.sessionUser(r); ok {
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	if _, ok := a.auth.proxyUser(r); ok {
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	a.renderLoginPage(w, http.StatusOK, loginTemplateData{
+		Redirect: redirect,
+		Error:    r.URL.Query().Get("error"),
+	})
+}
+
+func (a *application) handleLoginRequest(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 16*1024)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	username := r.PostForm.Get("username")
+	redirect := safeRedirectPath(r.PostForm.Get("redirect"), a.Config.Server.BaseURL+"/")
+
+	address := remoteHost(r)
+	if !a.auth.logins.allows(username, address) {
+		a.renderLoginPage(w, http.StatusTooManyRequests, loginTemplateData{
+			Redirect: redirect,
+			Username: username,
+			Error:    "Too many failed attempts, try again later",
+		})
+		return
+	}
+
+	if !a.auth.checkPassword(username, r.PostForm.Get("password")) {
+		slog.Warn("Failed login attempt", "username", username, "remote_addr", r.RemoteAddr)
+		a.auth.logins.fail(username, address)
+
+		a.renderLoginPage(w, http.StatusUnauthorized, loginTemplateData{
+			Redirect: redirect,
+			Username: username,
+			Error:    "Incorrect username or password",
+		})
+		return
+	}
+
+	a.auth.setSessionCookie(w, r, a.basePath(), username, nil)
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+func (a *application) handleLogoutRequest(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     authSessionCookieName,
+		Value:    "",
+		Path:     a.basePath(),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// logging out of Glance alone would get you logged right back in by the proxy
+	if proxy := a.Config.Auth.Proxy; proxy != nil && proxy.LogoutURL != "" {
+		http.Redirect(w, r, proxy.LogoutURL, http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, a.Config.Server.BaseURL+"/login", http.StatusSeeOther)
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+// The discovery document is only fetched once it's needed and kept for as long
+// as the config is loaded
+func (a *authenticator) oidcDiscovery() (*oidcDiscovery, error) {
+	a.discoveryMu.Lock()
+	defer a.discoveryMu.Unlock()
+
+	if a.discovery != nil {
+		return a.discovery, nil
+	}
+
+	request, _ := http.NewRequest("GET", a.config.OIDC.Issuer+"/.well-known/openid-configuration", nil)
+	discovery, err := decodeJsonFromRequest[oidcDiscovery](defaultHTTPClient, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching OIDC discovery document: %v", err)
+	}
+
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" {
+		return nil, errors.New("OIDC discovery document is missing the authorization or token endpoint")
+	}
+
+	a.discovery = &discovery
+	return a.discovery, nil
+}
+
+func (a *application) oidcRedirectURL(r *http.Request) string {
+	if a.Config.Auth.OIDC.RedirectURL != "" {
+		return a.Config.Auth.OIDC.RedirectURL
+	}
+
+	if strings.HasPrefix(a.Config.Server.BaseURL, "http://") || strings.HasPrefix(a.Config.Server.BaseURL, "https://") {
+		return a.Config.Server.BaseURL + "/auth/oidc/callback"
+	}
+
+	return ternary(isSecureRequest(r), "https://", "http://") + r.Host + a.Config.Server.BaseURL + "/auth/oidc/callback"
+}
+
+func randomURLSafeString() string {
+	random := make([]byte, 24)
+	rand.Read(random)
+
+	return base64.RawURLEncoding.EncodeToString(random)
+}
+
+// The state, nonce and PKCE verifier of the login in progress are kept in a
+// short lived cookie until the provider redirects back
+type oidcFlowState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+	Expires  int64  `json:"expires"`
+}
+
+func (a *application) handleOIDCLoginRequest(w http.ResponseWriter, r *http.Request) {
+	if a.Config.Auth.OIDC == nil {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	discovery, err := a.auth.oidcDiscovery()
+	if err != nil {
+		slog.Error("Failed to start OIDC login", "error", err)
+		http.Redirect(w, r, a.Config.Server.BaseURL+"/login?error="+url.QueryEscape("Could not reach the identity provider"), http.StatusSeeOther)
+		return
+	}
+
+	flow := oidcFlowState{
+		State:    randomURLSafeString(),
+		Nonce:    randomURLSafeString(),
+		Verifier: randomURLSafeString() + randomURLSafeString(),
+		Redirect: safeRedirectPath(r.URL.Query().Get("redirect"), a.Config.Server.BaseURL+"/"),
+		Expires:  time.Now().Add(authOIDCFlowTimeout).Unix(),
+	}
+
+	encoded, _ := json.Marshal(flow)
+	http.SetCookie(w, &http.Cookie{
+		Name:     authOIDCCookieName,
+		Value:    a.auth.sign(string(encoded)),
+		Path:     a.basePath(),
+		MaxAge:   int(authOIDCFlowTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	challenge := sha256.Sum256([]byte(flow.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {a.Config.Auth.OIDC.ClientID},
+		"redirect_uri":          {a.oidcRedirectURL(r)},
+		"scope":                 {strings.Join(a.Config.Auth.OIDC.Scopes, " ")},
+		"state":                 {flow.State},
+		"nonce":                 {flow.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := ternary(strings.Contains(discovery.AuthorizationEndpoint, "?"), "&", "?")
+	http.Redirect(w, r, discovery.AuthorizationEndpoint+separator+query.Encode(), http.StatusSeeOther)
+}
+
+func (a *application) handleOIDCCallbackRequest(w http.ResponseWriter, r *http.Request) {
+	if a.Config.Auth.OIDC == nil {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	fail := func(message string, err error) {
+		slog.Error("OIDC login failed", "error", err)
+		http.Redirect(w, r, a.Config.Server.BaseURL+"/login?error="+url.QueryEscape(message), http.StatusSeeOther)
+	}
+
+	var flow oidcFlowState
+	cookie, err := r.Cookie(authOIDCCookieName)
+	if err != nil {
+		fail("The login has expired, please try again", err)
+		return
+	}
+
+	value, ok := a.auth.verify(cookie.Value)
+	if !ok || json.Unmarshal([]byte(value), &flow) != nil || time.Now().Unix() > flow.Expires {
+		fail("The login has expired, please try again", errors.New("invalid or expired flow cookie"))
+		return
+	}
+
+	query := r.URL.Query()
+	if errorCode := query.Get("error"); errorCode != "" {
+		fail("The identity provider returned an error", fmt.Errorf("%s: %s", errorCode, query.Get("error_description")))
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(flow.State)) != 1 {
+		fail("The login has expired, please try again", errors.New("state mismatch"))
+		return
+	}
+
+	claims, err := a.exchangeOIDCCode(r, query.Get("code"), &flow)
+	if err != nil {
+		fail("Could not complete the login", err)
+		return
+	}
+
+	username := claims.identity()
+	if _, allowed := a.auth.lookupUser(authOIDCUserPrefix+username, nil); username == "" || !allowed {
+		fail("You are not allowed to access this dashboard", fmt.Errorf("user %q is not allowed", username))
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   authOIDCCookieName,
+		Path:   a.basePath(),
+		MaxAge: -1,
+	})
+
+	a.auth.setSessionCookie(w, r, a.basePath(), authOIDCUserPrefix+username, claims.Groups)
+	http.Redirect(w, r, flow.Redirect, http.StatusSeeOther)
+}
+
+type oidcIDTokenClaims struct {
+	Issuer            string          `json:"iss"`
+	Audience          json.RawMessage `json:"aud"`
+	Expires           int64           `json:"exp"`
+	Nonce             string          `json:"nonce"`
+	Subject           string          `json:"sub"`
+	Email             string          `json:"email"`
+	EmailVerified     json.RawMessage `json:"email_verified"`
+	PreferredUsername string          `json:"preferred_username"`
+	Groups            []string        `json:"groups"`
+}
+
+// Providers that let users change their own email don't always verify it, so
+// an unverified one could be set to the email of someone else
+func (c *oidcIDTokenClaims) identity() string {
+	if c.Email != "" && c.emailIsVerified() {
+		return c.Email
+	}
+
+	return ternary(c.PreferredUsername != "", c.PreferredUsername, c.Subject)
+}
+
+// Some providers send it as a string rather than a boolean
+func (c *oidcIDTokenClaims) emailIsVerified() bool {
+	value := string(c.EmailVerified)
+	return value == "true" || value == `"true"`
+}
+
+func (c *oidcIDTokenClaims) hasAudience(clientID string) bool {
+	var single string
+	if json.Unmarshal(c.Audience, &single) == nil {
+		return single == clientID
+	}
+
+	var multiple []string
+	if json.Unmarshal(c.Audience, &multiple) == nil {
+		return slices.Contains(multiple, clientID)
+	}
+
+	return false
+}
+
+// The ID token is received directly from the token endpoint of the provider
+// over TLS, which the OIDC spec allows in place of checking its signature, so
+// only its claims get validated
+func (a *application) exchangeOIDCCode(r *http.Request, code string, flow *oidcFlowState) (*oidcIDTokenClaims, error) {
+	if code == "" {
+		return nil, errors.New("no code in callback")
+	}
+
+	discovery, err := a.auth.oidcDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	oidc := a.Config.Auth.OIDC
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {a.oidcRedirectURL(r)},
+		"code_verifier": {flow.Verifier},
+	}
+
+	request, err := http.NewRequest("POST", discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(oidc.ClientID), url.QueryEscape(oidc.ClientSecret))
+
+	response, err := defaultHTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1024*1024))
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned status code %d: %s", response.StatusCode, body)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil || tokens.IDToken == "" {
+		return nil, errors.New("token response does not contain an ID token")
+	}
+
+	parts := strings.Split(tokens.IDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("decoding ID token: %v", err)
+	}
+
+	var claims oidcIDTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("decoding ID token claims: %v", err)
+	}
+
+	expectedIssuer := ternary(discovery.Issuer != "", discovery.Issuer, oidc.Issuer)
+
+	switch {
+	case strings.TrimRight(claims.Issuer, "/") != strings.TrimRight(expectedIssuer, "/"):
+		return nil, fmt.Errorf("unexpected issuer %s", claims.Issuer)
+	case !claims.hasAudience(oidc.ClientID):
+		return nil, errors.New("ID token was not issued for this client")
+	case time.Now().Unix() > claims.Expires:
+		return nil, errors.New("ID token has expired")
+	case subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(flow.Nonce)) != 1:
+		return nil, errors.New("nonce mismatch")
+	}
+
+	return &claims, nil
+}
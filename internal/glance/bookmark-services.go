@@ -0,0 +1,579 @@
+package glance
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var bookmarkServiceTypes = []string{"linkding", "linkwarden", "wallabag", "readeck"}
+
+// A Linkding, Linkwarden, Wallabag or Readeck instance that the bookmarks
+// widget can read bookmarks from and that feed widgets can save items to
+type bookmarkService struct {
+	Name  string `yaml:"name"`
+	Type  string `yaml:"type"`
+	URL   string `yaml:"url"`
+	Token string `yaml:"token"`
+	// Wallabag only, used instead of the token to get an access token
+	ClientID     string `yaml:"client-id"`
+	ClientSecret string `yaml:"client-secret"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	// added to the items that get saved
+	Tags []string `yaml:"tags"`
+	// Linkwarden only, the collection that items get saved to
+	Collection string `yaml:"collection"`
+
+	accessTokenMu      sync.Mutex
+	accessToken        string
+	accessTokenExpires time.Time
+}
+
+type savedBookmark struct {
+	Title string
+	URL   string
+}
+
+// An unread item of a read-later service
+type readLaterItem struct {
+	Title string
+	URL   string
+	// where the item can be read within the service, same as the URL for
+	// services that don't have a reader
+	ReadURL     string
+	Domain      string
+	ReadingTime int
+	AddedAt     time.Time
+}
+
+func validateBookmarkServices(services []bookmarkService) error {
+	names := make(map[string]struct{}, len(services))
+
+	for i := range services {
+		service := &services[i]
+
+		if service.Name == "" {
+			return fmt.Errorf("bookmark service %d has no name", i+1)
+		}
+
+		if _, exists := names[service.Name]; exists {
+			return fmt.Errorf("multiple bookmark services with the name %s", service.Name)
+		}
+
+		names[service.Name] = struct{}{}
+
+		if !isValidBookmarkServiceType(service.Type) {
+			return fmt.Errorf(
+				"bookmark service %s: type must be one of %s",
+				service.Name,
+				strings.Join(bookmarkServiceTypes, ", "),
+			)
+		}
+
+		if service.URL == "" {
+			return fmt.Errorf("bookmark service %s has no url", service.Name)
+		}
+
+		if service.Type == "wallabag" {
+			if service.ClientID == "" || service.ClientSecret == "" || service.Username == "" || service.Password == "" {
+				return fmt.Errorf("bookmark service %s: wallabag requires client-id, client-secret, username and password", service.Name)
+			}
+		} else if service.Token == "" {
+			return fmt.Errorf("bookmark service %s has no token", service.Name)
+		}
+
+		if service.Collection != "" && service.Type != "linkwarden" {
+			return fmt.Errorf("bookmark service %s: collection is only supported by linkwarden", service.Name)
+		}
+
+		service.URL = strings.TrimRight(service.URL, "/")
+	}
+
+	return nil
+}
+
+func isValidBookmarkServiceType(serviceType string) bool {
+	for _, t := range bookmarkServiceTypes {
+		if t == serviceType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newBookmarkServices(services []bookmarkService) map[string]*bookmarkService {
+	byName := make(map[string]*bookmarkService, len(services))
+
+	for i := range services {
+		byName[services[i].Name] = &services[i]
+	}
+
+	return byName
+}
+
+// Whether the service keeps track of which items haven't been read yet
+func (s *bookmarkService) hasUnreadItems() bool {
+	return s.Type != "linkwarden"
+}
+
+func (s *bookmarkService) newRequest(client requestDoer, method, path string, body any) (*http.Request, error) {
+	var reader io.Reader
+
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		reader = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	switch s.Type {
+	case "linkding":
+		request.Header.Set("Authorization", "Token "+s.Token)
+	case "wallabag":
+		token, err := s.wallabagAccessToken(client)
+		if err != nil {
+			return nil, fmt.Errorf("getting access token: %w", err)
+		}
+
+		request.Header.Set("Authorization", "Bearer "+token)
+	default:
+		request.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	return request, nil
+}
+
+type wallabagTokenResponseJson struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Wallabag doesn't have long lived tokens, instead one that's valid for an
+// hour is requested using the credentials of an API client and reused until
+// shortly before it expires
+func (s *bookmarkService) wallabagAccessToken(client requestDoer) (string, error) {
+	s.accessTokenMu.Lock()
+	defer s.accessTokenMu.Unlock()
+
+	if s.accessToken != "" && time.Now().Before(s.accessTokenExpires) {
+		return s.accessToken, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"password"},
+		"client_id":     {s.ClientID},
+		"client_secret": {s.ClientSecret},
+		"username":      {s.Username},
+		"password":      {s.Password},
+	}
+
+	request, err := http.NewRequest(http.MethodPost, s.URL+"/oauth/v2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := decodeJsonFromRequest[wallabagTokenResponseJson](client, request)
+	if err != nil {
+		return "", err
+	}
+
+	if response.AccessToken == "" {
+		return "", errors.New("no access token in response")
+	}
+
+	s.accessToken = response.AccessToken
+	s.accessTokenExpires = time.Now().Add(time.Duration(max(response.ExpiresIn-60, 0)) * time.Second)
+
+	return s.accessToken, nil
+}
+
+type linkdingBookmarksResponseJson struct {
+	Results []struct {
+		URL          string `json:"url"`
+		Title        string `json:"title"`
+		WebsiteTitle string `json:"website_title"`
+		DateAdded    string `json:"date_added"`
+	} `json:"results"`
+}
+
+type linkwardenLinksResponseJson struct {
+	Response []struct {
+		URL  string `json:"url"`
+		Name string `json:"name"`
+	} `json:"response"`
+}
+
+type linkwardenCollectionsResponseJson struct {
+	Response []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"response"`
+}
+
+type wallabagEntriesResponseJson struct {
+	Embedded struct {
+		Items []struct {
+			ID          int    `json:"id"`
+			URL         string `json:"url"`
+			Title       string `json:"title"`
+			DomainName  string `json:"domain_name"`
+			ReadingTime int    `json:"reading_time"`
+			CreatedAt   string `json:"created_at"`
+		} `json:"items"`
+	} `json:"_embedded"`
+}
+
+type readeckBookmarksResponseJson []struct {
+	ID          string `json:"id"`
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	SiteName    string `json:"site_name"`
+	ReadingTime int    `json:"reading_time"`
+	Created     string `json:"created"`
+}
+
+// The collection is a tag for Linkding and Wallabag, a label for Readeck and
+// the name or ID of a collection for Linkwarden, all bookmarks are returned
+// when it's empty
+func (s *bookmarkService) fetchBookmarks(client requestDoer, collection string, limit int) ([]savedBookmark, error) {
+	if s.Type == "linkwarden" {
+		return s.fetchLinkwardenLinks(client, collection, limit)
+	}
+
+	items, err := s.fetchItems(client, collection, false, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	bookmarks := make([]savedBookmark, 0, len(items))
+	for i := range items {
+		bookmarks = append(bookmarks, savedBookmark{Title: items[i].Title, URL: items[i].URL})
+	}
+
+	return bookmarks, nil
+}
+
+// The items that haven't been read or archived yet, newest first
+func (s *bookmarkService) fetchUnreadItems(client requestDoer, limit int) ([]readLaterItem, error) {
+	if !s.hasUnreadItems() {
+		return nil, fmt.Errorf("%s does not keep track of unread items", s.Type)
+	}
+
+	return s.fetchItems(client, "", true, limit)
+}
+
+// Not supported by Linkwarden
+func (s *bookmarkService) fetchItems(client requestDoer, tag string, unreadOnly bool, limit int) ([]readLaterItem, error) {
+	switch s.Type {
+	case "linkding":
+		var search []string
+		if tag != "" {
+			search = append(search, "#"+tag)
+		}
+		if unreadOnly {
+			search = append(search, "!unread")
+		}
+
+		query := url.Values{"limit": {strconv.Itoa(limit)}}
+		if len(search) > 0 {
+			query.Set("q", strings.Join(search, " "))
+		}
+
+		request, err := s.newRequest(client, http.MethodGet, "/api/bookmarks/?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := decodeJsonFromRequest[linkdingBookmarksResponseJson](client, request)
+		if err != nil {
+			return nil, err
+		}
+
+		items := make([]readLaterItem, 0, len(response.Results))
+		for _, result := range response.Results {
+			items = append(items, readLaterItem{
+				Title:   ternary(result.Title != "", result.Title, ternary(result.WebsiteTitle != "", result.WebsiteTitle, result.URL)),
+				URL:     result.URL,
+				ReadURL: result.URL,
+				Domain:  extractDomainFromUrl(result.URL),
+				AddedAt: parseRFC3339Time(result.DateAdded),
+			})
+		}
+
+		return items, nil
+	case "wallabag":
+		query := url.Values{
+			"perPage": {strconv.Itoa(limit)},
+			"sort":    {"created"},
+			"order":   {"desc"},
+			"detail":  {"metadata"},
+		}
+		if tag != "" {
+			query.Set("tags", tag)
+		}
+		if unreadOnly {
+			query.Set("archive", "0")
+		}
+
+		request, err := s.newRequest(client, http.MethodGet, "/api/entries.json?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := decodeJsonFromRequest[wallabagEntriesResponseJson](client, request)
+		if err != nil {
+			return nil, err
+		}
+
+		items := make([]readLaterItem, 0, len(response.Embedded.Items))
+		for _, entry := range response.Embedded.Items {
+			// wallabag leaves out the colon in the offset
+			addedAt, err := time.Parse("2006-01-02T15:04:05-0700", entry.CreatedAt)
+			if err != nil {
+				addedAt = parseRFC3339Time(entry.CreatedAt)
+			}
+
+			items = append(items, readLaterItem{
+				Title:       ternary(entry.Title != "", entry.Title, entry.URL),
+				URL:         entry.URL,
+				ReadURL:     s.URL + "/view/" + strconv.Itoa(entry.ID),
+				Domain:      ternary(entry.DomainName != "", entry.DomainName, extractDomainFromUrl(entry.URL)),
+				ReadingTime: entry.ReadingTime,
+				AddedAt:     addedAt,
+			})
+		}
+
+		return items, nil
+	case "readeck":
+		query := url.Values{
+			"limit": {strconv.Itoa(limit)},
+			"sort":  {"-created"},
+		}
+		if tag != "" {
+			query.Set("labels", tag)
+		}
+		if unreadOnly {
+			query.Set("is_archived", "false")
+		}
+
+		request, err := s.newRequest(client, http.MethodGet, "/api/bookmarks?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := decodeJsonFromRequest[readeckBookmarksResponseJson](client, request)
+		if err != nil {
+			return nil, err
+		}
+
+		items := make([]readLaterItem, 0, len(response))
+		for _, bookmark := range response {
+			items = append(items, readLaterItem{
+				Title:       ternary(bookmark.Title != "", bookmark.Title, bookmark.URL),
+				URL:         bookmark.URL,
+				ReadURL:     s.URL + "/bookmarks/" + bookmark.ID,
+				Domain:      ternary(bookmark.SiteName != "", bookmark.SiteName, extractDomainFromUrl(bookmark.URL)),
+				ReadingTime: bookmark.ReadingTime,
+				AddedAt:     parseRFC3339Time(bookmark.Created),
+			})
+		}
+
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("listing items is not supported by %s", s.Type)
+}
+
+func (s *bookmarkService) fetchLinkwardenLinks(client requestDoer, collection string, limit int) ([]savedBookmark, error) {
+	query := url.Values{}
+	if collection != "" {
+		id, err := s.linkwardenCollectionID(client, collection)
+		if err != nil {
+			return nil, err
+		}
+
+		query.Set("collectionId", strconv.Itoa(id))
+	}
+
+	request, err := s.newRequest(client, http.MethodGet, "/api/v1/links?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeJsonFromRequest[linkwardenLinksResponseJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	bookmarks := make([]savedBookmark, 0, min(limit, len(response.Response)))
+	for i := 0; i < len(response.Response) && i < limit; i++ {
+		link := &response.Response[i]
+		bookmarks = append(bookmarks, savedBookmark{
+			Title: ternary(link.Name != "", link.Name, link.URL),
+			URL:   link.URL,
+		})
+	}
+
+	return bookmarks, nil
+}
+
+func (s *bookmarkService) linkwardenCollectionID(client requestDoer, collection string) (int, error) {
+	if id, err := strconv.Atoi(collection); err == nil {
+		return id, nil
+	}
+
+	request, err := s.newRequest(client, http.MethodGet, "/api/v1/collections", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	response, err := decodeJsonFromRequest[linkwardenCollectionsResponseJson](client, request)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, c := range response.Response {
+		if strings.EqualFold(c.Name, collection) {
+			return c.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("collection %s does not exist", collection)
+}
+
+// Linkding marks the bookmark as unread so that it shows up in its reading
+// list, Wallabag and Readeck fetch the content of the article themselves
+func (s *bookmarkService) save(client requestDoer, bookmarkURL, title string) error {
+	var request *http.Request
+	var err error
+	tags := ternary(s.Tags != nil, s.Tags, []string{})
+
+	switch s.Type {
+	case "linkding":
+		request, err = s.newRequest(client, http.MethodPost, "/api/bookmarks/", map[string]any{
+			"url":       bookmarkURL,
+			"title":     title,
+			"tag_names": tags,
+			"unread":    true,
+		})
+	case "wallabag":
+		request, err = s.newRequest(client, http.MethodPost, "/api/entries.json", map[string]any{
+			"url":   bookmarkURL,
+			"title": title,
+			"tags":  strings.Join(tags, ","),
+		})
+	case "readeck":
+		request, err = s.newRequest(client, http.MethodPost, "/api/bookmarks", map[string]any{
+			"url":    bookmarkURL,
+			"title":  title,
+			"labels": tags,
+		})
+	default:
+		linkTags := make([]map[string]string, 0, len(tags))
+		for _, tag := range tags {
+			linkTags = append(linkTags, map[string]string{"name": tag})
+		}
+
+		body := map[string]any{
+			"url":  bookmarkURL,
+			"name": title,
+			"type": "url",
+			"tags": linkTags,
+		}
+
+		if s.Collection != "" {
+			body["collection"] = map[string]string{"name": s.Collection}
+		}
+
+		request, err = s.newRequest(client, http.MethodPost, "/api/v1/links", body)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+		return fmt.Errorf("unexpected status code %d from %s: %s", response.StatusCode, s.Name, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// Shared by widgets whose items can be saved to a bookmark service
+type saveForLaterOptions struct {
+	SaveTo string `yaml:"save-to"`
+}
+
+func (o *saveForLaterOptions) getBookmarkServices() []string {
+	if o.SaveTo == "" {
+		return nil
+	}
+
+	return []string{o.SaveTo}
+}
+
+// Handles the request sent when pressing the save button of an item, only
+// items that the widget is currently showing can be saved. The title is
+// looked up from the URL.
+func handleSaveForLaterRequest(w http.ResponseWriter, r *http.Request, widget *widgetBase, serviceName string, lookup func(url string) (string, bool)) {
+	if r.Method != http.MethodPost || r.PathValue("path") != "save" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	var body struct {
+		URL string `json:"url"`
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4*1024)).Decode(&body); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	service := widget.Providers.bookmarkServices[serviceName]
+	title, ok := lookup(body.URL)
+	if service == nil || !ok {
+		http.Error(w, "item not found", http.StatusNotFound)
+		return
+	}
+
+	if err := service.save(widget.httpClient(false), body.URL, title); err != nil {
+		slog.Error("Failed to save item for later", "service", service.Name, "url", body.URL, "error", err)
+		http.Error(w, "failed to save item", http.StatusBadGateway)
+		return
+	}
+
+	widget.Providers.audit.record(r, "Saved for later", title, "", service.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,251 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBriefingHeadlines = 3
+	briefingSpeechTimeout    = time.Minute
+)
+
+type briefingConfig struct {
+	// names or slugs of the pages whose widgets are included, all pages if empty
+	Pages     []string `yaml:"pages"`
+	Headlines int      `yaml:"headlines"`
+	Speech    struct {
+		// run with the text on stdin, the audio is read from stdout
+		Command []string `yaml:"command"`
+		// requested with {text} replaced by the text, the response is the audio
+		URL         string `yaml:"url"`
+		ContentType string `yaml:"content-type"`
+	} `yaml:"speech"`
+}
+
+func validateBriefingConfig(c *config) error {
+	briefing := c.Briefing
+	if briefing == nil {
+		return nil
+	}
+
+	for _, name := range briefing.Pages {
+		found := false
+
+		for p := range c.Pages {
+			if strings.EqualFold(c.Pages[p].Title, name) || c.Pages[p].Slug == name || titleToSlug(c.Pages[p].Title) == name {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("briefing: page %s does not exist", name)
+		}
+	}
+
+	if briefing.Headlines < 0 {
+		return errors.New("briefing: headlines can't be negative")
+	}
+
+	if len(briefing.Speech.Command) > 0 && briefing.Speech.URL != "" {
+		return errors.New("briefing: speech can use either a command or a URL, not both")
+	}
+
+	if briefing.Speech.URL != "" && !strings.Contains(briefing.Speech.URL, "{text}") {
+		return errors.New("briefing: speech url must contain {text}")
+	}
+
+	return nil
+}
+
+// Widgets that can describe what they're showing in a sentence or a few, for
+// them to be read out as part of the briefing. Nothing is returned when there's
+// nothing worth mentioning.
+type briefingSource interface {
+	briefing(now time.Time, headlines int) []string
+}
+
+func (c *briefingConfig) includesPage(page *page) bool {
+	if len(c.Pages) == 0 {
+		return true
+	}
+
+	for _, name := range c.Pages {
+		if strings.EqualFold(page.Title, name) || page.Slug == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func briefingGreeting(now time.Time) string {
+	switch hour := now.Hour(); {
+	case hour >= 4 && hour < 12:
+		return "Good morning."
+	case hour >= 12 && hour < 18:
+		return "Good afternoon."
+	default:
+		return "Good evening."
+	}
+}
+
+func (a *application) buildBriefing(user *authUser) string {
+	config := a.Config.Briefing
+	headlines := ternary(config.Headlines == 0, defaultBriefingHeadlines, config.Headlines)
+	now := time.Now()
+
+	sentences := []string{
+		briefingGreeting(now),
+		"It's " + now.Format("Monday, January 2") + ".",
+	}
+
+	var collect func(widget widget)
+	collect = func(widget widget) {
+		if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+			for _, child := range container.getChildWidgets() {
+				collect(child)
+			}
+
+			return
+		}
+
+		if source, ok := widget.(briefingSource); ok {
+			sentences = append(sentences, source.briefing(now, headlines)...)
+		}
+	}
+
+	for p := range a.Config.Pages {
+		page := &a.Config.Pages[p]
+		if !config.includesPage(page) || !a.canAccessPage(user, page) {
+			continue
+		}
+
+		page.mu.Lock()
+		a.updateOutdatedWidgets(page)
+
+		for c := range page.Columns {
+			for _, widget := range page.Columns[c].Widgets {
+				if a.canAccessWidget(user, widget.GetID()) {
+					collect(widget)
+				}
+			}
+		}
+		page.mu.Unlock()
+	}
+
+	return strings.Join(sentences, " ")
+}
+
+func (c *briefingConfig) speechEnabled() bool {
+	return len(c.Speech.Command) > 0 || c.Speech.URL != ""
+}
+
+// Turns the text into audio using whichever engine is configured
+func (c *briefingConfig) synthesizeSpeech(ctx context.Context, text string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, briefingSpeechTimeout)
+	defer cancel()
+
+	if len(c.Speech.Command) > 0 {
+		var stdout, stderr bytes.Buffer
+
+		cmd := exec.CommandContext(ctx, c.Speech.Command[0], c.Speech.Command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			output, _ := limitStringLength(strings.TrimSpace(stderr.String()), 256)
+			return nil, fmt.Errorf("running speech command: %v: %s", err, output)
+		}
+
+		return stdout.Bytes(), nil
+	}
+
+	requestURL := strings.ReplaceAll(c.Speech.URL, "{text}", url.QueryEscape(text))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := defaultHTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from speech engine", response.StatusCode)
+	}
+
+	return io.ReadAll(response.Body)
+}
+
+func (a *application) handleBriefingRequest(w http.ResponseWriter, r *http.Request) {
+	config := a.Config.Briefing
+	if config == nil {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	text := a.buildBriefing(requestUser(r))
+
+	switch r.URL.Query().Get("format") {
+	case "", "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(text))
+	case "audio":
+		if !config.speechEnabled() {
+			http.Error(w, "no speech engine is configured", http.StatusNotFound)
+			return
+		}
+
+		audio, err := config.synthesizeSpeech(r.Context(), text)
+		if err != nil {
+			slog.Error("Failed to synthesize briefing", "error", err)
+			http.Error(w, "failed to synthesize speech", http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", ternary(config.Speech.ContentType == "", "audio/wav", config.Speech.ContentType))
+		w.Write(audio)
+	default:
+		http.Error(w, "format must be either text or audio", http.StatusBadRequest)
+	}
+}
+
+// Reads out the titles of the first few items, shared by the widgets that
+// show lists of posts or articles
+func briefingHeadlines(source string, titles []string, limit int) []string {
+	if limit == 0 || len(titles) == 0 {
+		return nil
+	}
+
+	titles = titles[:min(limit, len(titles))]
+	sentences := []string{fmt.Sprintf("Top headlines from %s.", source)}
+
+	for _, title := range titles {
+		title = strings.TrimSpace(title)
+		if !strings.HasSuffix(title, ".") && !strings.HasSuffix(title, "?") && !strings.HasSuffix(title, "!") {
+			title += "."
+		}
+
+		sentences = append(sentences, title)
+	}
+
+	return sentences
+}
+
+func briefingTime(t time.Time) string {
+	return t.Format("3:04 PM")
+}
@@ -14,6 +14,7 @@ const (
 	cliIntentConfigValidate           = iota
 	cliIntentConfigPrint              = iota
 	cliIntentDiagnose                 = iota
+	cliIntentPasswordHash             = iota
 )
 
 type cliOptions struct {
@@ -30,9 +31,10 @@ func parseCliOptions() (*cliOptions, error) {
 		flags.PrintDefaults()
 
 		fmt.Println("\nCommands:")
-		fmt.Println("  config:validate     Validate the config file")
-		fmt.Println("  config:print        Print the parsed config file with embedded includes")
+		fmt.Println("  config validate     Validate the config file")
+		fmt.Println("  config print        Print the config file with includes, environment variables and files resolved")
 		fmt.Println("  diagnose            Run diagnostic checks")
+		fmt.Println("  password hash       Hash a password read from stdin for use in the auth config")
 	}
 	configPath := flags.String("config", "glance.yml", "Set config path")
 	err := flags.Parse(os.Args[1:])
@@ -44,6 +46,11 @@ func parseCliOptions() (*cliOptions, error) {
 	var args = flags.Args()
 	unknownCommandErr := fmt.Errorf("unknown command: %s", strings.Join(args, " "))
 
+	// the config commands used to be written with a colon, e.g. config:validate
+	if len(args) == 2 && (args[0] == "config" || args[0] == "password") {
+		args = []string{args[0] + ":" + args[1]}
+	}
+
 	if len(args) == 0 {
 		intent = cliIntentServe
 	} else if len(args) == 1 {
@@ -53,6 +60,8 @@ func parseCliOptions() (*cliOptions, error) {
 			intent = cliIntentConfigPrint
 		} else if args[0] == "diagnose" {
 			intent = cliIntentDiagnose
+		} else if args[0] == "password:hash" {
+			intent = cliIntentPasswordHash
 		} else {
 			return nil, unknownCommandErr
 		}
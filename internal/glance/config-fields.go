@@ -1,7 +1,6 @@
 package glance
 
 import (
-	"crypto/tls"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -41,7 +40,7 @@ func (c *hslColorField) UnmarshalYAML(node *yaml.Node) error {
 	matches := hslColorFieldPattern.FindStringSubmatch(value)
 
 	if len(matches) != 4 {
-		return fmt.Errorf("invalid HSL color format: %s", value)
+		return errorAtConfigLine(node, fmt.Errorf("invalid HSL color format: %s", value))
 	}
 
 	hue, err := strconv.ParseUint(matches[1], 10, 16)
@@ -50,7 +49,7 @@ func (c *hslColorField) UnmarshalYAML(node *yaml.Node) error {
 	}
 
 	if hue > hslHueMax {
-		return fmt.Errorf("HSL hue must be between 0 and %d", hslHueMax)
+		return errorAtConfigLine(node, fmt.Errorf("HSL hue must be between 0 and %d", hslHueMax))
 	}
 
 	saturation, err := strconv.ParseUint(matches[2], 10, 8)
@@ -59,7 +58,7 @@ func (c *hslColorField) UnmarshalYAML(node *yaml.Node) error {
 	}
 
 	if saturation > hslSaturationMax {
-		return fmt.Errorf("HSL saturation must be between 0 and %d", hslSaturationMax)
+		return errorAtConfigLine(node, fmt.Errorf("HSL saturation must be between 0 and %d", hslSaturationMax))
 	}
 
 	lightness, err := strconv.ParseUint(matches[3], 10, 8)
@@ -68,7 +67,7 @@ func (c *hslColorField) UnmarshalYAML(node *yaml.Node) error {
 	}
 
 	if lightness > hslLightnessMax {
-		return fmt.Errorf("HSL lightness must be between 0 and %d", hslLightnessMax)
+		return errorAtConfigLine(node, fmt.Errorf("HSL lightness must be between 0 and %d", hslLightnessMax))
 	}
 
 	c.Hue = uint16(hue)
@@ -92,7 +91,7 @@ func (d *durationField) UnmarshalYAML(node *yaml.Node) error {
 	matches := durationFieldPattern.FindStringSubmatch(value)
 
 	if len(matches) != 3 {
-		return fmt.Errorf("invalid duration format: %s", value)
+		return errorAtConfigLine(node, fmt.Errorf("invalid duration format: %s", value))
 	}
 
 	duration, err := strconv.Atoi(matches[1])
@@ -114,6 +113,86 @@ func (d *durationField) UnmarshalYAML(node *yaml.Node) error {
 	return nil
 }
 
+var byteSizeFieldPattern = regexp.MustCompile(`(?i)^(\d+)\s*(b|kb|mb|gb|tb)?$`)
+
+// A number of bytes, optionally followed by a unit such as 500MB or 2GB, the
+// units are powers of 1000
+type byteSizeField int64
+
+func (b *byteSizeField) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+
+	matches := byteSizeFieldPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if len(matches) != 3 {
+		return errorAtConfigLine(node, fmt.Errorf("invalid size format: %s", value))
+	}
+
+	size, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return errorAtConfigLine(node, err)
+	}
+
+	switch strings.ToLower(matches[2]) {
+	case "kb":
+		size *= 1000
+	case "mb":
+		size *= 1000 * 1000
+	case "gb":
+		size *= 1000 * 1000 * 1000
+	case "tb":
+		size *= 1000 * 1000 * 1000 * 1000
+	}
+
+	*b = byteSizeField(size)
+
+	return nil
+}
+
+var rateFieldPattern = regexp.MustCompile(`^(\d+)\s*/\s*(s|m|h|d)$`)
+
+// A number of requests within a period, such as 10/s or 60/m
+type rateField struct {
+	Count  int
+	Period time.Duration
+}
+
+func (r *rateField) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+
+	matches := rateFieldPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if len(matches) != 3 {
+		return errorAtConfigLine(node, fmt.Errorf("invalid rate format: %s, expected a number of requests per s, m, h or d such as 10/s", value))
+	}
+
+	count, err := strconv.Atoi(matches[1])
+	if err != nil || count < 1 {
+		return errorAtConfigLine(node, fmt.Errorf("invalid rate format: %s, the number of requests must be at least 1", value))
+	}
+
+	r.Count = count
+
+	switch matches[2] {
+	case "s":
+		r.Period = time.Second
+	case "m":
+		r.Period = time.Minute
+	case "h":
+		r.Period = time.Hour
+	case "d":
+		r.Period = 24 * time.Hour
+	}
+
+	return nil
+}
+
 type customIconField struct {
 	URL        string
 	IsFlatIcon bool
@@ -199,22 +278,47 @@ func (p *proxyOptionsField) UnmarshalYAML(node *yaml.Node) error {
 		proxyURL = p.URL
 	}
 
-	parsedUrl, err := url.Parse(proxyURL)
+	client, err := newHTTPClient(&httpClientOptionsField{
+		Proxy:         proxyURL,
+		AllowInsecure: p.AllowInsecure,
+		Timeout:       p.Timeout,
+	}, false)
 	if err != nil {
-		return fmt.Errorf("parsing proxy URL: %v", err)
+		return errorAtConfigLine(node, err)
+	}
+
+	p.client = client
+
+	return nil
+}
+
+// Options for the client that a widget makes its requests with, for things
+// such as corporate proxies and self-signed certificates
+type httpClientOptionsField struct {
+	Proxy          string            `yaml:"proxy"`
+	Headers        map[string]string `yaml:"headers"`
+	AllowInsecure  bool              `yaml:"allow-insecure"`
+	CAFile         string            `yaml:"ca-file"`
+	Timeout        durationField     `yaml:"timeout"`
+	client         *http.Client      `yaml:"-"`
+	insecureClient *http.Client      `yaml:"-"`
+}
+
+func (o *httpClientOptionsField) UnmarshalYAML(node *yaml.Node) error {
+	type httpClientOptionsFieldAlias httpClientOptionsField
+	if err := node.Decode((*httpClientOptionsFieldAlias)(o)); err != nil {
+		return err
 	}
 
-	var timeout = defaultClientTimeout
-	if p.Timeout > 0 {
-		timeout = time.Duration(p.Timeout)
+	var err error
+	if o.client, err = newHTTPClient(o, false); err != nil {
+		return errorAtConfigLine(node, err)
 	}
 
-	p.client = &http.Client{
-		Timeout: timeout,
-		Transport: &http.Transport{
-			Proxy:           http.ProxyURL(parsedUrl),
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: p.AllowInsecure},
-		},
+	if o.AllowInsecure {
+		o.insecureClient = o.client
+	} else if o.insecureClient, err = newHTTPClient(o, true); err != nil {
+		return errorAtConfigLine(node, err)
 	}
 
 	return nil
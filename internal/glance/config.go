@@ -2,13 +2,16 @@ package glance
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,27 +22,24 @@ import (
 
 type config struct {
 	Server struct {
-		Host       string    `yaml:"host"`
-		Port       uint16    `yaml:"port"`
-		AssetsPath string    `yaml:"assets-path"`
-		BaseURL    string    `yaml:"base-url"`
-		StartedAt  time.Time `yaml:"-"` // used in custom css file
+		Host              string        `yaml:"host"`
+		Port              uint16        `yaml:"port"`
+		AssetsPath        string        `yaml:"assets-path"`
+		DataPath          string        `yaml:"data-path"`
+		PersistentCache   bool          `yaml:"persistent-cache"`
+		BaseURL           string        `yaml:"base-url"`
+		WidgetConcurrency int           `yaml:"widget-concurrency"`
+		WidgetTimeout     durationField `yaml:"widget-timeout"`
+		StartedAt         time.Time     `yaml:"-"` // used in custom css file
 	} `yaml:"server"`
 
 	Document struct {
 		Head template.HTML `yaml:"head"`
 	} `yaml:"document"`
 
-	Theme struct {
-		BackgroundColor          *hslColorField `yaml:"background-color"`
-		PrimaryColor             *hslColorField `yaml:"primary-color"`
-		PositiveColor            *hslColorField `yaml:"positive-color"`
-		NegativeColor            *hslColorField `yaml:"negative-color"`
-		Light                    bool           `yaml:"light"`
-		ContrastMultiplier       float32        `yaml:"contrast-multiplier"`
-		TextSaturationMultiplier float32        `yaml:"text-saturation-multiplier"`
-		CustomCSSFile            string         `yaml:"custom-css-file"`
-	} `yaml:"theme"`
+	Theme themeConfig `yaml:"theme"`
+
+	Locale localeField `yaml:"locale"`
 
 	Branding struct {
 		HideFooter   bool          `yaml:"hide-footer"`
@@ -49,17 +49,60 @@ type config struct {
 		FaviconURL   string        `yaml:"favicon-url"`
 	} `yaml:"branding"`
 
+	Notifications []notificationTarget `yaml:"notifications"`
+
+	BookmarkServices []bookmarkService `yaml:"bookmark-services"`
+
+	Auth authConfig `yaml:"auth"`
+
+	Privacy privacyConfig `yaml:"privacy"`
+
+	ImageProxy imageProxyConfig `yaml:"image-proxy"`
+
+	Security securityConfig `yaml:"security"`
+
+	RateLimits rateLimitsConfig `yaml:"rate-limits"`
+
+	Retries retriesConfig `yaml:"retries"`
+
+	CircuitBreaker circuitBreakerConfig `yaml:"circuit-breaker"`
+
+	Briefing *briefingConfig `yaml:"briefing"`
+
+	Logging loggingConfig `yaml:"logging"`
+
+	Summarizer *summarizerConfig `yaml:"summarizer"`
+
+	Kiosk kioskConfig `yaml:"kiosk"`
+
 	Pages []page `yaml:"pages"`
 }
 
+type themeConfig struct {
+	BackgroundColor          *hslColorField `yaml:"background-color"`
+	PrimaryColor             *hslColorField `yaml:"primary-color"`
+	PositiveColor            *hslColorField `yaml:"positive-color"`
+	NegativeColor            *hslColorField `yaml:"negative-color"`
+	Light                    bool           `yaml:"light"`
+	ContrastMultiplier       float32        `yaml:"contrast-multiplier"`
+	TextSaturationMultiplier float32        `yaml:"text-saturation-multiplier"`
+	CustomCSSFile            string         `yaml:"custom-css-file"`
+}
+
 type page struct {
-	Title                      string `yaml:"name"`
-	Slug                       string `yaml:"slug"`
-	Width                      string `yaml:"width"`
-	ShowMobileHeader           bool   `yaml:"show-mobile-header"`
-	ExpandMobilePageNavigation bool   `yaml:"expand-mobile-page-navigation"`
-	HideDesktopNavigation      bool   `yaml:"hide-desktop-navigation"`
-	CenterVertically           bool   `yaml:"center-vertically"`
+	Title                      string               `yaml:"name"`
+	Slug                       string               `yaml:"slug"`
+	Width                      string               `yaml:"width"`
+	ShowMobileHeader           bool                 `yaml:"show-mobile-header"`
+	ExpandMobilePageNavigation bool                 `yaml:"expand-mobile-page-navigation"`
+	HideDesktopNavigation      bool                 `yaml:"hide-desktop-navigation"`
+	CenterVertically           bool                 `yaml:"center-vertically"`
+	LiveUpdates                bool                 `yaml:"live-updates"`
+	KioskInterval              durationField        `yaml:"kiosk-interval"`
+	KioskSkip                  bool                 `yaml:"kiosk-skip"`
+	Snapshots                  *pageSnapshotsConfig `yaml:"snapshots"`
+	Access                     accessControl        `yaml:",inline"`
+	Schedule                   visibilitySchedule   `yaml:",inline"`
 	Columns                    []struct {
 		Size    string  `yaml:"size"`
 		Widgets widgets `yaml:"widgets"`
@@ -68,7 +111,7 @@ type page struct {
 	mu                 sync.Mutex `yaml:"-"`
 }
 
-func newConfigFromYAML(contents []byte) (*config, error) {
+func newConfigFromYAML(contents []byte, sources configSourceMap) (*config, error) {
 	contents, err := parseConfigEnvVariables(contents)
 	if err != nil {
 		return nil, err
@@ -76,21 +119,44 @@ func newConfigFromYAML(contents []byte) (*config, error) {
 
 	config := &config{}
 	config.Server.Port = 8080
+	config.Server.WidgetConcurrency = 10
+	config.Server.WidgetTimeout = durationField(15 * time.Second)
 
 	err = yaml.Unmarshal(contents, config)
 	if err != nil {
-		return nil, err
+		return nil, sources.annotateError(err)
 	}
 
 	if err = isConfigStateValid(config); err != nil {
 		return nil, err
 	}
 
+	var privacy *privacyPolicy
+	if config.Privacy.Enabled {
+		privacy = newPrivacyPolicy(config)
+	}
+
+	stateIDs := make(map[string]struct{})
+
 	for p := range config.Pages {
 		for c := range config.Pages[p].Columns {
 			for w := range config.Pages[p].Columns[c].Widgets {
+				if err := validateWidgetStateIDs(config.Pages[p].Columns[c].Widgets[w], stateIDs); err != nil {
+					return nil, sources.annotateError(err)
+				}
+
 				if err := config.Pages[p].Columns[c].Widgets[w].initialize(); err != nil {
-					return nil, formatWidgetInitError(err, config.Pages[p].Columns[c].Widgets[w])
+					return nil, sources.annotateError(formatWidgetInitError(err, config.Pages[p].Columns[c].Widgets[w]))
+				}
+
+				if err := validateWidgetAccess(config.Pages[p].Columns[c].Widgets[w], config.Auth.IsEnabled(), false); err != nil {
+					return nil, sources.annotateError(err)
+				}
+
+				if privacy != nil {
+					if err := validateWidgetPrivacy(config.Pages[p].Columns[c].Widgets[w], privacy); err != nil {
+						return nil, sources.annotateError(err)
+					}
 				}
 			}
 		}
@@ -100,7 +166,7 @@ func newConfigFromYAML(contents []byte) (*config, error) {
 }
 
 // TODO: change the pattern so that it doesn't match commented out lines
-var configEnvVariablePattern = regexp.MustCompile(`(^|.)\$\{([A-Z0-9_]+)\}`)
+var configEnvVariablePattern = regexp.MustCompile(`(^|.)\$\{([A-Z0-9_]+|file://[^}\n]+)\}`)
 
 func parseConfigEnvVariables(contents []byte) ([]byte, error) {
 	var err error
@@ -124,6 +190,17 @@ func parseConfigEnvVariables(contents []byte) ([]byte, error) {
 			}
 		}
 
+		if path, isFile := strings.CutPrefix(key, "file://"); isFile {
+			contents, readErr := os.ReadFile(path)
+			if readErr != nil {
+				err = fmt.Errorf("reading file referenced in config: %v", readErr)
+				return nil
+			}
+
+			// files such as Docker secrets usually end with a newline that isn't part of the value
+			return []byte(prefix + strings.TrimRight(string(contents), "\r\n"))
+		}
+
 		value, found := os.LookupEnv(key)
 		if !found {
 			err = fmt.Errorf("environment variable %s not found", key)
@@ -140,69 +217,224 @@ func parseConfigEnvVariables(contents []byte) ([]byte, error) {
 	return replaced, nil
 }
 
+func validateWidgetStateIDs(widget widget, seen map[string]struct{}) error {
+	if base, ok := widget.(interface{ getWidgetBase() *widgetBase }); ok {
+		if id := base.getWidgetBase().StateID; id != "" {
+			if _, exists := seen[id]; exists {
+				return formatWidgetInitError(fmt.Errorf("id %s is used by more than one widget", id), widget)
+			}
+
+			seen[id] = struct{}{}
+		}
+	}
+
+	if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+		for _, child := range container.getChildWidgets() {
+			if err := validateWidgetStateIDs(child, seen); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func formatWidgetInitError(err error, w widget) error {
+	if line := w.getConfigLine(); line > 0 {
+		return fmt.Errorf("%s widget at line %d: %v", w.GetType(), line, err)
+	}
+
 	return fmt.Errorf("%s widget: %v", w.GetType(), err)
 }
 
-var includePattern = regexp.MustCompile(`(?m)^(\s*)!include:\s*(.+)$`)
+// Unlike the errors of the YAML parser itself, the errors returned by custom
+// unmarshalers don't include where in the config the value that caused them is
+type configLineError struct {
+	line int
+	err  error
+}
+
+func (e *configLineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+func (e *configLineError) Unwrap() error {
+	return e.err
+}
+
+// Adds the line of the node to the error unless it already points to a line,
+// which is the case for errors that happened while decoding nested values
+func errorAtConfigLine(node *yaml.Node, err error) error {
+	var typeErr *yaml.TypeError
+	var lineErr *configLineError
+
+	if err == nil || errors.As(err, &typeErr) || errors.As(err, &lineErr) {
+		return err
+	}
 
-func parseYAMLIncludes(mainFilePath string) ([]byte, map[string]struct{}, error) {
+	return &configLineError{line: node.Line, err: err}
+}
+
+var includePattern = regexp.MustCompile(`^(\s*)!include:\s*(.+)$`)
+
+var customTemplateFilePattern = regexp.MustCompile(`(?m)^\s*(?:-\s+)?custom-template-file:\s*(.+?)\s*$`)
+
+// Custom template files aren't part of the config's contents, so they get
+// added to the watched files and what's returned combines their contents to
+// tell whether any of them changed
+func watchCustomTemplateFiles(contents []byte, includes map[string]struct{}) string {
+	var combined strings.Builder
+
+	for _, matches := range customTemplateFilePattern.FindAllStringSubmatch(string(contents), -1) {
+		path, err := filepath.Abs(strings.Trim(matches[1], `"'`))
+		if err != nil {
+			continue
+		}
+
+		includes[path] = struct{}{}
+		fileContents, _ := os.ReadFile(path)
+
+		combined.WriteString(path + "\n")
+		combined.Write(fileContents)
+	}
+
+	return combined.String()
+}
+
+type configSourceLine struct {
+	file string
+	line int
+}
+
+// Keeps track of which file and line each line of the config came from once
+// the includes have been resolved, so that errors can point to the right place
+type configSourceMap []configSourceLine
+
+func parseYAMLIncludes(mainFilePath string) ([]byte, map[string]struct{}, configSourceMap, error) {
 	mainFileContents, err := os.ReadFile(mainFilePath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("reading main YAML file: %w", err)
+		return nil, nil, nil, fmt.Errorf("reading main YAML file: %w", err)
 	}
 
 	mainFileAbsPath, err := filepath.Abs(mainFilePath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("getting absolute path of main YAML file: %w", err)
+		return nil, nil, nil, fmt.Errorf("getting absolute path of main YAML file: %w", err)
+	}
+
+	resolver := yamlIncludeResolver{
+		rootDir:  filepath.Dir(mainFileAbsPath),
+		includes: make(map[string]struct{}),
 	}
-	mainFileDir := filepath.Dir(mainFileAbsPath)
 
-	includes := make(map[string]struct{})
-	var includesLastErr error
+	if err := resolver.resolve(mainFileAbsPath, mainFileContents, "", nil); err != nil {
+		return nil, nil, nil, err
+	}
 
-	mainFileContents = includePattern.ReplaceAllFunc(mainFileContents, func(match []byte) []byte {
-		if includesLastErr != nil {
-			return nil
-		}
+	return []byte(strings.Join(resolver.lines, "\n")), resolver.includes, resolver.sources, nil
+}
 
-		matches := includePattern.FindSubmatch(match)
-		if len(matches) != 3 {
-			includesLastErr = fmt.Errorf("invalid include match: %v", matches)
-			return nil
+type yamlIncludeResolver struct {
+	rootDir  string
+	includes map[string]struct{}
+	lines    []string
+	sources  configSourceMap
+}
+
+// Included files can include other files themselves, relative paths are
+// relative to the file that contains the include
+func (r *yamlIncludeResolver) resolve(filePath string, contents []byte, indent string, stack []string) error {
+	stack = append(stack, filePath)
+
+	for i, line := range strings.Split(string(contents), "\n") {
+		matches := includePattern.FindStringSubmatch(line)
+		if matches == nil {
+			r.lines = append(r.lines, indent+line)
+			r.sources = append(r.sources, configSourceLine{file: filePath, line: i + 1})
+			continue
 		}
 
-		indent := string(matches[1])
-		includeFilePath := strings.TrimSpace(string(matches[2]))
+		location := fmt.Sprintf("%s, line %d", r.displayPath(filePath), i+1)
+		includeFilePath := strings.TrimSpace(matches[2])
 		if !filepath.IsAbs(includeFilePath) {
-			includeFilePath = filepath.Join(mainFileDir, includeFilePath)
+			includeFilePath = filepath.Join(filepath.Dir(filePath), includeFilePath)
 		}
 
-		var fileContents []byte
-		var err error
+		if slices.Contains(stack, includeFilePath) {
+			chain := make([]string, 0, len(stack)+1)
+			for _, path := range append(stack, includeFilePath) {
+				chain = append(chain, r.displayPath(path))
+			}
+
+			return fmt.Errorf("%s: include cycle: %s", location, strings.Join(chain, " -> "))
+		}
 
-		fileContents, err = os.ReadFile(includeFilePath)
+		fileContents, err := os.ReadFile(includeFilePath)
 		if err != nil {
-			includesLastErr = fmt.Errorf("reading included file %s: %w", includeFilePath, err)
-			return nil
+			return fmt.Errorf("%s: reading included file %s: %w", location, includeFilePath, err)
+		}
+
+		r.includes[includeFilePath] = struct{}{}
+
+		if err := r.resolve(includeFilePath, fileContents, indent+matches[1], stack); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *yamlIncludeResolver) displayPath(filePath string) string {
+	return configFileDisplayPath(r.rootDir, filePath)
+}
+
+// Files next to or below the main config file are shown relative to it
+func configFileDisplayPath(rootDir string, filePath string) string {
+	if relative, err := filepath.Rel(rootDir, filePath); err == nil && !strings.HasPrefix(relative, "..") {
+		return relative
+	}
+
+	return filePath
+}
+
+var yamlErrorLinePattern = regexp.MustCompile(`\bline (\d+)`)
+
+// Replaces the line numbers in errors returned by the YAML parser, which refer to
+// the config with all of its includes resolved, with the file and line they came from
+func (m configSourceMap) annotateError(err error) error {
+	if !m.hasIncludes() {
+		return err
+	}
+
+	rootDir := filepath.Dir(m[0].file)
+	message := yamlErrorLinePattern.ReplaceAllStringFunc(err.Error(), func(match string) string {
+		n, _ := strconv.Atoi(match[len("line "):])
+		if n < 1 || n > len(m) {
+			return match
 		}
 
-		includes[includeFilePath] = struct{}{}
-		return []byte(prefixStringLines(indent, string(fileContents)))
+		source := m[n-1]
+		return fmt.Sprintf("%s, line %d", configFileDisplayPath(rootDir, source.file), source.line)
 	})
 
-	if includesLastErr != nil {
-		return nil, nil, includesLastErr
+	return errors.New(message)
+}
+
+func (m configSourceMap) hasIncludes() bool {
+	for i := range m {
+		if m[i].file != m[0].file {
+			return true
+		}
 	}
 
-	return mainFileContents, includes, nil
+	return false
 }
 
 func configFilesWatcher(
 	mainFilePath string,
 	lastContents []byte,
 	lastIncludes map[string]struct{},
-	onChange func(newContents []byte),
+	lastSources configSourceMap,
+	onChange func(newContents []byte, sources configSourceMap),
 	onErr func(error),
 ) (func() error, error) {
 	mainFileAbsPath, err := filepath.Abs(mainFilePath)
@@ -228,22 +460,24 @@ func configFilesWatcher(
 		for filePath := range newWatched {
 			if _, ok := previousWatched[filePath]; !ok {
 				if err := watcher.Add(filePath); err != nil {
-					log.Printf(
-						"Could not add file to watcher, changes to this file will not trigger a reload. path: %s, error: %v",
-						filePath, err,
+					slog.Warn(
+						"Could not add file to watcher, changes to this file will not trigger a reload",
+						"path", filePath,
+						"error", err,
 					)
 				}
 			}
 		}
 	}
 
+	lastTemplates := watchCustomTemplateFiles(lastContents, lastIncludes)
 	updateWatchedFiles(nil, lastIncludes)
 
-	// needed for lastContents and lastIncludes because they get updated in multiple goroutines
+	// needed for lastContents, lastIncludes and lastTemplates because they get updated in multiple goroutines
 	mu := sync.Mutex{}
 
 	parseAndCompareBeforeCallback := func() {
-		currentContents, currentIncludes, err := parseYAMLIncludes(mainFilePath)
+		currentContents, currentIncludes, currentSources, err := parseYAMLIncludes(mainFilePath)
 		if err != nil {
 			onErr(fmt.Errorf("parsing main file contents for comparison: %w", err))
 			return
@@ -251,6 +485,7 @@ func configFilesWatcher(
 
 		// TODO: refactor, flaky
 		currentIncludes[mainFileAbsPath] = struct{}{}
+		currentTemplates := watchCustomTemplateFiles(currentContents, currentIncludes)
 
 		mu.Lock()
 		defer mu.Unlock()
@@ -260,9 +495,10 @@ func configFilesWatcher(
 			lastIncludes = currentIncludes
 		}
 
-		if !bytes.Equal(lastContents, currentContents) {
+		if !bytes.Equal(lastContents, currentContents) || lastTemplates != currentTemplates {
 			lastContents = currentContents
-			onChange(currentContents)
+			lastTemplates = currentTemplates
+			onChange(currentContents, currentSources)
 		}
 	}
 
@@ -329,7 +565,7 @@ func configFilesWatcher(
 		}
 	}()
 
-	onChange(lastContents)
+	onChange(lastContents, lastSources)
 
 	return func() error {
 		if debounceTimer != nil {
@@ -351,6 +587,72 @@ func isConfigStateValid(config *config) error {
 		}
 	}
 
+	if config.Server.DataPath != "" {
+		if stat, err := os.Stat(config.Server.DataPath); err != nil || !stat.IsDir() {
+			return fmt.Errorf("data directory does not exist: %s", config.Server.DataPath)
+		}
+	}
+
+	if config.Server.PersistentCache && config.Server.DataPath == "" {
+		return fmt.Errorf("persistent-cache requires data-path to be set")
+	}
+
+	if config.Server.WidgetConcurrency < 1 {
+		return fmt.Errorf("widget-concurrency must be at least 1")
+	}
+
+	if config.Server.WidgetTimeout <= 0 {
+		return fmt.Errorf("widget-timeout must be greater than 0")
+	}
+
+	if err := validateNotificationTargets(config.Notifications); err != nil {
+		return err
+	}
+
+	if err := validateBookmarkServices(config.BookmarkServices); err != nil {
+		return err
+	}
+
+	if err := validateAuthConfig(&config.Auth); err != nil {
+		return err
+	}
+
+	if err := validatePrivacy(config); err != nil {
+		return err
+	}
+
+	if err := validateImageProxyConfig(&config.ImageProxy); err != nil {
+		return err
+	}
+
+	if err := validateSecurityConfig(&config.Security); err != nil {
+		return err
+	}
+
+	if err := validateRateLimitsConfig(&config.RateLimits); err != nil {
+		return err
+	}
+
+	if err := validateRetriesConfig(config); err != nil {
+		return err
+	}
+
+	if err := validateSummarizerConfig(config.Summarizer); err != nil {
+		return err
+	}
+
+	if err := validateKioskConfig(config); err != nil {
+		return err
+	}
+
+	if err := validateBriefingConfig(config); err != nil {
+		return err
+	}
+
+	if err := validateLoggingConfig(&config.Logging); err != nil {
+		return err
+	}
+
 	for i := range config.Pages {
 		if config.Pages[i].Title == "" {
 			return fmt.Errorf("page %d has no name", i+1)
@@ -360,6 +662,14 @@ func isConfigStateValid(config *config) error {
 			return fmt.Errorf("page %d: width can only be either wide or slim", i+1)
 		}
 
+		if config.Pages[i].Access.isRestricted() && !config.Auth.IsEnabled() {
+			return fmt.Errorf("page %d: allowed-users and allowed-groups require auth to be enabled", i+1)
+		}
+
+		if err := validatePageSnapshots(config, i); err != nil {
+			return err
+		}
+
 		if len(config.Pages[i].Columns) == 0 {
 			return fmt.Errorf("page %d has no columns", i+1)
 		}
@@ -0,0 +1,191 @@
+package glance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A standard 5 field cron expression (minute, hour, day of month, month, day
+// of week) supporting wildcards, lists, ranges and steps. Day of week can be
+// specified either as a number where 0 and 7 are Sunday, or as a three letter
+// abbreviation.
+type cronSchedule struct {
+	minutes     [60]bool
+	hours       [24]bool
+	daysOfMonth [32]bool
+	months      [13]bool
+	daysOfWeek  [7]bool
+	// when both day of month and day of week are restricted, a day matches if either matches
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+var cronDayOfWeekNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// limit the search for occurrences so that impossible schedules such as "0 0 31 2 *" don't loop forever
+const cronMaxSearchDays = 366 * 4
+
+func parseCronSchedule(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields, got %d", len(fields))
+	}
+
+	schedule := &cronSchedule{}
+
+	if err := parseCronField(fields[0], 0, 59, nil, schedule.minutes[:]); err != nil {
+		return nil, fmt.Errorf("minute: %v", err)
+	}
+
+	if err := parseCronField(fields[1], 0, 23, nil, schedule.hours[:]); err != nil {
+		return nil, fmt.Errorf("hour: %v", err)
+	}
+
+	if err := parseCronField(fields[2], 1, 31, nil, schedule.daysOfMonth[:]); err != nil {
+		return nil, fmt.Errorf("day of month: %v", err)
+	}
+
+	if err := parseCronField(fields[3], 1, 12, nil, schedule.months[:]); err != nil {
+		return nil, fmt.Errorf("month: %v", err)
+	}
+
+	var daysOfWeek [8]bool
+	if err := parseCronField(fields[4], 0, 7, cronDayOfWeekNames, daysOfWeek[:]); err != nil {
+		return nil, fmt.Errorf("day of week: %v", err)
+	}
+
+	copy(schedule.daysOfWeek[:], daysOfWeek[:7])
+	schedule.daysOfWeek[0] = schedule.daysOfWeek[0] || daysOfWeek[7]
+
+	schedule.anyDayOfMonth = fields[2] == "*"
+	schedule.anyDayOfWeek = fields[4] == "*"
+
+	return schedule, nil
+}
+
+func parseCronValue(value string, names map[string]int) (int, error) {
+	if n, ok := names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+func parseCronField(field string, min int, max int, names map[string]int, values []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		start, end := min, max
+
+		if rangePart != "*" {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			start, err = parseCronValue(startPart, names)
+			if err != nil {
+				return fmt.Errorf("invalid value %q", startPart)
+			}
+
+			if isRange {
+				end, err = parseCronValue(endPart, names)
+				if err != nil {
+					return fmt.Errorf("invalid value %q", endPart)
+				}
+			} else if !hasStep {
+				end = start
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+
+		for i := start; i <= end; i += step {
+			values[i] = true
+		}
+	}
+
+	return nil
+}
+
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	if !s.months[t.Month()] {
+		return false
+	}
+
+	dayOfMonth := s.daysOfMonth[t.Day()]
+	dayOfWeek := s.daysOfWeek[t.Weekday()]
+
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+
+	return dayOfMonth || dayOfWeek
+}
+
+// Returns the first time after t that matches the schedule, or the zero time if there is none
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(0, 0, cronMaxSearchDays)
+
+	for t.Before(limit) {
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// Returns the latest time at or before t that matches the schedule, or the zero time if there is none
+func (s *cronSchedule) prev(t time.Time) time.Time {
+	t = t.Truncate(time.Minute)
+	limit := t.AddDate(0, 0, -cronMaxSearchDays)
+
+	for t.After(limit) {
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+			continue
+		}
+
+		if !s.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()).Add(-time.Minute)
+			continue
+		}
+
+		if !s.minutes[t.Minute()] {
+			t = t.Add(-time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
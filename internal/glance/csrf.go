@@ -0,0 +1,50 @@
+package glance
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Rejects requests that could change something when they come from a page on
+// another site, which browsers would otherwise happily send along with the
+// session cookie. Modern browsers tell us where a request came from through
+// the Sec-Fetch-Site header, older ones only through the Origin header.
+// Requests that include neither don't come from a browser and are let through.
+func crossOriginProtection(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isSafeMethod(r.Method) || strings.HasPrefix(r.URL.Path, "/api/webhooks/") || isSameOriginRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		http.Error(w, "cross-origin request rejected", http.StatusForbidden)
+	})
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func isSameOriginRequest(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "":
+	default:
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	// behind a reverse proxy the host the browser sees may only be in X-Forwarded-Host
+	return parsed.Host == r.Host || parsed.Host == r.Header.Get("X-Forwarded-Host")
+}
@@ -0,0 +1,101 @@
+package glance
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Implemented by widgets whose fetched data is available as JSON at
+// /api/widgets/{id}/data. The returned value only includes what's already
+// shown by the widget and never any of its config, which can contain secrets.
+type widgetDataSource interface {
+	data() any
+}
+
+type widgetDataResponseJson struct {
+	widgetStatusJson
+	Data any `json:"data"`
+}
+
+// Returns the page that the widget is on along with the widget placed
+// directly within one of its columns that either is or contains it, which is
+// the one that gets updated
+func (a *application) widgetPlacement(widgetID uint64) (*page, widget) {
+	var contains func(widget widget) bool
+	contains = func(widget widget) bool {
+		if widget.GetID() == widgetID {
+			return true
+		}
+
+		if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+			for _, child := range container.getChildWidgets() {
+				if contains(child) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	for p := range a.Config.Pages {
+		page := &a.Config.Pages[p]
+		for c := range page.Columns {
+			for _, widget := range page.Columns[c].Widgets {
+				if contains(widget) {
+					return page, widget
+				}
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// The widget gets updated first if its data is outdated, same as when
+// loading the page it's on, so that the data stays fresh even when the page
+// itself is never opened
+func (a *application) handleWidgetDataRequest(w http.ResponseWriter, r *http.Request, widget widget) {
+	source, ok := widget.(widgetDataSource)
+	base, hasBase := widget.(interface{ getWidgetBase() *widgetBase })
+	if !ok || !hasBase {
+		http.Error(w, "the data of this widget is not available", http.StatusNotFound)
+		return
+	}
+
+	page, outer := a.widgetPlacement(widget.GetID())
+	if page == nil || !a.canAccessPage(requestUser(r), page) {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	var response widgetDataResponseJson
+	var encoded []byte
+	var err error
+
+	func() {
+		page.mu.Lock()
+		defer page.mu.Unlock()
+
+		now := time.Now()
+		if outer.requiresUpdate(&now) {
+			a.updateWidgetWithTimeout(outer)
+		}
+
+		response.widgetStatusJson = base.getWidgetBase().status(time.Now())
+		response.Data = source.data()
+		// encoded while holding the lock since the data can share memory
+		// with the widget
+		encoded, err = json.Marshal(response)
+	}()
+
+	if err != nil {
+		http.Error(w, "failed to encode data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(encoded)
+}
@@ -6,7 +6,7 @@ import (
 	"encoding/hex"
 	"io"
 	"io/fs"
-	"log"
+	"log/slog"
 	"strconv"
 	"time"
 )
@@ -23,7 +23,7 @@ var templateFS, _ = fs.Sub(_templateFS, "templates")
 var staticFSHash = func() string {
 	hash, err := computeFSHash(staticFS)
 	if err != nil {
-		log.Printf("Could not compute static assets cache key: %v", err)
+		slog.Error("Could not compute static assets cache key", "error", err)
 		return strconv.FormatInt(time.Now().Unix(), 10)
 	}
 
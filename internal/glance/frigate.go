@@ -0,0 +1,119 @@
+package glance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// The parts of the Frigate API needed to show snapshots and the objects that
+// were detected
+
+type frigateConnection struct {
+	URL           string `yaml:"url"`
+	AllowInsecure bool   `yaml:"allow-insecure"`
+}
+
+type frigateEvent struct {
+	ID           string
+	Camera       string
+	Label        string
+	Score        float64
+	StartedAt    time.Time
+	ThumbnailURL string
+}
+
+func (e frigateEvent) ScorePercent() int {
+	return int(e.Score*100 + 0.5)
+}
+
+func (f *frigateConnection) initialize() error {
+	if f.URL == "" {
+		return errors.New("url is required")
+	}
+
+	f.URL = strings.TrimRight(f.URL, "/")
+
+	return nil
+}
+
+// Returns the latest events, newest first, of the given cameras or of all
+// cameras if none are given
+func (f *frigateConnection) fetchEvents(ctx context.Context, client requestDoer, cameras []string, limit int) ([]frigateEvent, error) {
+	params := url.Values{}
+	params.Set("limit", strconv.Itoa(limit))
+	if len(cameras) > 0 {
+		params.Set("cameras", strings.Join(cameras, ","))
+	}
+
+	request, _ := http.NewRequestWithContext(ctx, "GET", f.URL+"/api/events?"+params.Encode(), nil)
+	response, err := decodeJsonFromRequest[[]json.RawMessage](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]frigateEvent, 0, len(response))
+	for _, raw := range response {
+		events = append(events, parseFrigateEvent(gjson.ParseBytes(raw)))
+	}
+
+	return events, nil
+}
+
+// Events look the same in the API and in the after and before fields of the
+// messages that are published over MQTT
+func parseFrigateEvent(event gjson.Result) frigateEvent {
+	// moved into data in newer versions of Frigate
+	score := event.Get("top_score")
+	if score.Type != gjson.Number {
+		score = event.Get("data.top_score")
+	}
+
+	return frigateEvent{
+		ID:        event.Get("id").String(),
+		Camera:    event.Get("camera").String(),
+		Label:     formatFrigateName(event.Get("label").String()),
+		Score:     score.Float(),
+		StartedAt: time.UnixMilli(int64(event.Get("start_time").Float() * 1000)),
+	}
+}
+
+// Turns names such as front_door into Front door
+func formatFrigateName(name string) string {
+	name = strings.ReplaceAll(name, "_", " ")
+	if name == "" {
+		return name
+	}
+
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func (f *frigateConnection) latestSnapshotURL(camera string) string {
+	return f.URL + "/api/" + url.PathEscape(camera) + "/latest.jpg"
+}
+
+// Thumbnails don't change once the event has been recorded, so they get
+// cached by the browser
+func (f *frigateConnection) serveEventThumbnail(w http.ResponseWriter, r *http.Request, client requestDoer, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), cameraSnapshotTimeout)
+	defer cancel()
+
+	target := f.URL + "/api/events/" + url.PathEscape(id) + "/thumbnail.jpg"
+	data, contentType, err := fetchCameraImage(ctx, client, target, "", "")
+	if err != nil {
+		http.Error(w, "failed to get thumbnail: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(data)
+}
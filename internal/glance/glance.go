@@ -3,11 +3,12 @@ package glance
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
-	"path/filepath"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,23 +25,160 @@ type application struct {
 	Version          string
 	Config           config
 	ParsedThemeStyle template.HTML
+	// only set when kiosk mode has a theme of its own
+	parsedKioskThemeStyle template.HTML
 
 	slugToPage map[string]*page
 	widgetByID map[uint64]widget
+	seenItems  *seenItemsStore
+	state      *stateStore
+	audit      *auditLog
+	auth       *authenticator
+	privacy    *privacyPolicy
+	// nil unless either privacy mode or the image proxy is enabled
+	imageProxy *remoteImageProxy
+	// only rewrites images, set when the image proxy is enabled without privacy mode
+	imageRewriter *privacyPolicy
+	// nil unless at least one page has live updates enabled
+	liveUpdates *liveUpdates
+	// nil unless at least one page has snapshots enabled
+	snapshots *snapshotStore
+	// nil unless a summarizer is configured
+	summarizer *summarizer
+	feedItems  *feedItemIndex
+
+	// the access rules of the page a widget is on and those of the widget itself
+	// or of the group it's in, all of which have to allow a user to see it
+	widgetAccess map[uint64][]*accessControl
+
+	webhookByName map[string]*webhookWidget
+	notifier      *notifier
+	// by name
+	bookmarkServices map[string]*bookmarkService
+	backgroundTasks  []backgroundTaskRunner
+	listeners        []backgroundListener
+
+	// cancelled once the application is replaced by a reload or Glance is
+	// stopped, which ends its background tasks and event streams
+	stopping  context.Context
+	stopTasks context.CancelFunc
+	// cancelled once the application has had its chance to finish what it
+	// was doing, cutting short any widget updates that are still running
+	updates       context.Context
+	cancelUpdates context.CancelFunc
+	// requests being handled and background tasks that are running
+	running sync.WaitGroup
 }
 
-func newApplication(config *config) (*application, error) {
+// Widgets that need to do work regardless of whether anyone is looking at the
+// page, such as sending notifications, get called roughly every minute
+type backgroundTaskRunner interface {
+	runBackgroundTask(now time.Time)
+}
+
+// Widgets that keep a connection open to be told about things as they happen,
+// such as through an MQTT subscription, for as long as the application runs
+type backgroundListener interface {
+	listen(ctx context.Context)
+}
+
+// When the config gets reloaded the previous application is passed along so
+// that widgets whose config hasn't changed can be carried over together with
+// the data they've already fetched, it's nil on startup
+func newApplication(config *config, previous *application) (*application, error) {
 	app := &application{
 		Version:    buildVersion,
 		Config:     *config,
 		slugToPage: make(map[string]*page),
 		widgetByID: make(map[uint64]widget),
+
+		widgetAccess: make(map[uint64][]*accessControl),
+
+		webhookByName:    make(map[string]*webhookWidget),
+		notifier:         newNotifier(config.Notifications),
+		bookmarkServices: newBookmarkServices(config.BookmarkServices),
+	}
+
+	app.stopping, app.stopTasks = context.WithCancel(context.Background())
+	app.updates, app.cancelUpdates = context.WithCancel(context.Background())
+
+	var unchanged map[string][]widget
+	var reused int
+
+	if previous != nil && previous.Config.Server.DataPath == config.Server.DataPath {
+		app.seenItems = previous.seenItems
+		app.state = previous.state
+		app.audit = previous.audit
+	} else {
+		app.seenItems = newSeenItemsStore(config.Server.DataPath)
+		app.state = newStateStore(config.Server.DataPath)
+		app.audit = newAuditLog(config.Server.DataPath)
+	}
+
+	sharedResponseCache.setPersistence(ternary(config.Server.PersistentCache, config.Server.DataPath, ""))
+
+	// the rendered contents of widgets can contain links that include the base URL
+	if previous != nil && previous.Config.Server.BaseURL == strings.TrimRight(config.Server.BaseURL, "/") {
+		unchanged = previous.widgetsByConfigHash()
+	}
+
+	if config.Auth.IsEnabled() {
+		var err error
+		if app.auth, err = newAuthenticator(&app.Config.Auth, app.state); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Privacy.Enabled || config.ImageProxy.Enabled {
+		var previousProxy *remoteImageProxy
+		if previous != nil {
+			previousProxy = previous.imageProxy
+		}
+
+		app.imageProxy = newRemoteImageProxy(config, previousProxy)
+		policy := newPrivacyPolicy(config)
+		policy.images = app.imageProxy
+
+		if config.Privacy.Enabled {
+			app.privacy = policy
+		} else {
+			app.imageRewriter = policy
+		}
+	}
+
+	if config.Summarizer != nil {
+		var previousSummarizer *summarizer
+		if previous != nil {
+			previousSummarizer = previous.summarizer
+		}
+
+		app.summarizer = newSummarizer(config.Summarizer, config.Server.DataPath, previousSummarizer)
+	}
+
+	if previous != nil {
+		app.feedItems = previous.feedItems
+	} else {
+		app.feedItems = newFeedItemIndex()
 	}
 
 	app.slugToPage[""] = &config.Pages[0]
 
 	providers := &widgetProviders{
-		assetResolver: app.AssetPath,
+		assetResolver:           app.AssetPath,
+		readerURLResolver:       app.readerURL,
+		readerItemURLResolver:   app.readerItemURL,
+		widgetURLResolver:       app.widgetURL,
+		uptimeReportURLResolver: app.uptimeReportURL,
+		dataPath:                config.Server.DataPath,
+		state:                   app.state,
+		audit:                   app.audit,
+		notifier:                app.notifier,
+		bookmarkServices:        app.bookmarkServices,
+		summarizer:              app.summarizer,
+		feedItems:               app.feedItems,
+		feedItemsResolver:       app.recentFeedItems,
+		daySummaryResolver:      app.daySummaryParts,
+		locale:                  config.Locale,
 	}
 
 	var err error
@@ -49,6 +187,13 @@ func newApplication(config *config) (*application, error) {
 		return nil, fmt.Errorf("parsing theme style: %v", err)
 	}
 
+	if app.Config.Kiosk.Theme != nil {
+		app.parsedKioskThemeStyle, err = executeTemplateToHTML(pageThemeStyleTemplate, app.Config.Kiosk.Theme)
+		if err != nil {
+			return nil, fmt.Errorf("parsing kiosk theme style: %v", err)
+		}
+	}
+
 	for p := range config.Pages {
 		page := &config.Pages[p]
 		page.PrimaryColumnIndex = -1
@@ -59,6 +204,14 @@ func newApplication(config *config) (*application, error) {
 
 		app.slugToPage[page.Slug] = page
 
+		if page.LiveUpdates && app.liveUpdates == nil {
+			app.liveUpdates = newLiveUpdates()
+		}
+
+		if page.Snapshots != nil && app.snapshots == nil {
+			app.snapshots = newSnapshotStore(config.Server.DataPath)
+		}
+
 		for c := range page.Columns {
 			column := &page.Columns[c]
 
@@ -68,17 +221,47 @@ func newApplication(config *config) (*application, error) {
 
 			for w := range column.Widgets {
 				widget := column.Widgets[w]
-				app.widgetByID[widget.GetID()] = widget
 
+				if candidates := unchanged[widget.getConfigHash()]; len(candidates) > 0 {
+					widget = candidates[0]
+					unchanged[widget.getConfigHash()] = candidates[1:]
+					column.Widgets[w] = widget
+					reused++
+				}
+
+				if err := app.registerWidget(widget, []*accessControl{&page.Access}); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// done separately so that the widgets of the previous application are left
+	// untouched in case registering any of the widgets fails
+	app.feedItems.prune(app.widgetByID)
+
+	for p := range config.Pages {
+		for c, column := range config.Pages[p].Columns {
+			for w, widget := range column.Widgets {
 				widget.setProviders(providers)
+				setWidgetPage(widget, config.Pages[p].Title)
+				setWidgetLocation(widget, fmt.Sprintf("%s/%d/%d", config.Pages[p].Slug, c, w))
+				setWidgetSchedules(widget, []*visibilitySchedule{&config.Pages[p].Schedule})
 			}
 		}
 	}
 
+	if reused > 0 {
+		slog.Info("Kept unchanged widgets along with their cached data", "count", reused)
+	}
+
 	config = &app.Config
 
 	config.Server.BaseURL = strings.TrimRight(config.Server.BaseURL, "/")
 	config.Theme.CustomCSSFile = app.transformUserDefinedAssetPath(config.Theme.CustomCSSFile)
+	if config.Kiosk.Theme != nil {
+		config.Kiosk.Theme.CustomCSSFile = app.transformUserDefinedAssetPath(config.Kiosk.Theme.CustomCSSFile)
+	}
 
 	if config.Branding.FaviconURL == "" {
 		config.Branding.FaviconURL = app.AssetPath("favicon.png")
@@ -88,32 +271,157 @@ func newApplication(config *config) (*application, error) {
 
 	config.Branding.LogoURL = app.transformUserDefinedAssetPath(config.Branding.LogoURL)
 
+	setOutboundPolicy(&config.Security)
+	setRateLimits(&config.RateLimits)
+	setRetryPolicy(&config.Retries, &config.CircuitBreaker)
+	setLogging(&config.Logging)
+
 	return app, nil
 }
 
-func (p *page) updateOutdatedWidgets() {
-	now := time.Now()
+// The page, column and position of the widget, with the position within each
+// group or split column that it's in appended
+func setWidgetLocation(widget widget, location string) {
+	if base, ok := widget.(interface{ getWidgetBase() *widgetBase }); ok {
+		base.getWidgetBase().location = location
+	}
 
-	var wg sync.WaitGroup
-	context := context.Background()
+	if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+		for i, child := range container.getChildWidgets() {
+			setWidgetLocation(child, fmt.Sprintf("%s/%d", location, i))
+		}
+	}
+}
 
-	for c := range p.Columns {
-		for w := range p.Columns[c].Widgets {
-			widget := p.Columns[c].Widgets[w]
+// Only the widgets placed directly within columns are included, changing any
+// of the widgets within a group or split column recreates the whole container
+func (a *application) widgetsByConfigHash() map[string][]widget {
+	widgets := make(map[string][]widget)
 
-			if !widget.requiresUpdate(&now) {
-				continue
+	for p := range a.Config.Pages {
+		for _, column := range a.Config.Pages[p].Columns {
+			for _, widget := range column.Widgets {
+				hash := widget.getConfigHash()
+				widgets[hash] = append(widgets[hash], widget)
 			}
+		}
+	}
+
+	return widgets
+}
+
+func (a *application) registerWidget(widget widget, access []*accessControl) error {
+	a.widgetByID[widget.GetID()] = widget
+
+	// only widgets placed directly within columns can have access rules of
+	// their own, see validateWidgetAccess
+	if own := widget.getAccessControl(); own.isRestricted() {
+		access = append(access[:len(access):len(access)], own)
+	}
+
+	a.widgetAccess[widget.GetID()] = access
+
+	if webhook, ok := widget.(*webhookWidget); ok {
+		if _, exists := a.webhookByName[webhook.Name]; exists {
+			return fmt.Errorf("multiple webhook widgets with the name %s", webhook.Name)
+		}
+
+		a.webhookByName[webhook.Name] = webhook
+	}
+
+	if notifying, ok := widget.(interface{ getNotificationTargets() []string }); ok {
+		for _, name := range notifying.getNotificationTargets() {
+			if !a.notifier.hasTarget(name) {
+				return fmt.Errorf("%s widget: notification target %s does not exist", widget.GetType(), name)
+			}
+		}
+	}
+
+	if saving, ok := widget.(interface{ getBookmarkServices() []string }); ok {
+		for _, name := range saving.getBookmarkServices() {
+			if _, exists := a.bookmarkServices[name]; !exists {
+				return fmt.Errorf("%s widget: bookmark service %s does not exist", widget.GetType(), name)
+			}
+		}
+	}
+
+	if reading, ok := widget.(*readLaterWidget); ok {
+		if service := a.bookmarkServices[reading.Service]; !service.hasUnreadItems() {
+			return fmt.Errorf("read-later widget: %s does not keep track of unread items", service.Type)
+		}
+	}
+
+	if running, ok := widget.(interface{ getCommands() [][]string }); ok {
+		for _, command := range running.getCommands() {
+			if len(command) > 0 && !a.Config.Security.commandIsAllowed(command[0]) {
+				return fmt.Errorf("%s widget: %s is not listed in the allowed-commands of security", widget.GetType(), command[0])
+			}
+		}
+	}
+
+	if summarizing, ok := widget.(interface{ usesSummarizer() bool }); ok && summarizing.usesSummarizer() && a.summarizer == nil {
+		return fmt.Errorf("%s widget: summarizing requires a top level summarizer to be configured", widget.GetType())
+	}
+
+	if runner, ok := widget.(backgroundTaskRunner); ok {
+		a.backgroundTasks = append(a.backgroundTasks, runner)
+	}
 
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				widget.update(context)
-			}()
+	if listener, ok := widget.(backgroundListener); ok {
+		a.listeners = append(a.listeners, listener)
+	}
+
+	// widgets within groups and split columns can also receive requests
+	if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+		for _, child := range container.getChildWidgets() {
+			if err := a.registerWidget(child, access); err != nil {
+				return err
+			}
 		}
 	}
 
-	wg.Wait()
+	return nil
+}
+
+// Updates the widgets of the page a few at a time, each with its own timeout
+// so that a slow one gets shown as timed out rather than holding up the whole
+// page, and returns the top level widgets that were updated. Only requests
+// made with the widget's HTTP client get cancelled on timeout.
+func (a *application) updateOutdatedWidgets(p *page) []widget {
+	now := time.Now()
+
+	var outdated []widget
+
+	for c := range p.Columns {
+		for _, widget := range p.Columns[c].Widgets {
+			if widget.requiresUpdate(&now) {
+				outdated = append(outdated, widget)
+			}
+		}
+	}
+
+	job := newJob(func(widget widget) (struct{}, error) {
+		a.updateWidgetWithTimeout(widget)
+		return struct{}{}, nil
+	}, outdated).withWorkers(a.Config.Server.WidgetConcurrency)
+
+	workerPoolDo(job)
+
+	return outdated
+}
+
+func (a *application) updateWidgetWithTimeout(widget widget) {
+	timeout := time.Duration(a.Config.Server.WidgetTimeout)
+	ctx, cancel := context.WithTimeout(a.updates, timeout)
+	defer cancel()
+
+	updateWidget(ctx, widget)
+
+	if ctx.Err() == context.DeadlineExceeded {
+		if base, ok := widget.(interface{ getWidgetBase() *widgetBase }); ok && base.getWidgetBase().Error != nil {
+			base.getWidgetBase().Error = fmt.Errorf("timed out after %s", timeout)
+		}
+	}
 }
 
 func (a *application) transformUserDefinedAssetPath(path string) string {
@@ -124,15 +432,117 @@ func (a *application) transformUserDefinedAssetPath(path string) string {
 	return path
 }
 
+// Pages that are hidden by their schedule can't be accessed at all until
+// they're shown again
+func (a *application) canAccessPage(user *authUser, page *page) bool {
+	if !page.Schedule.isVisible(time.Now()) {
+		return false
+	}
+
+	return a.auth == nil || page.Access.allows(user)
+}
+
+func (a *application) canAccessWidget(user *authUser, widgetID uint64) bool {
+	if a.auth == nil {
+		return true
+	}
+
+	if user != nil && user.token != nil {
+		if widget, exists := a.widgetByID[widgetID]; !exists || !user.token.allowsWidget(widget) {
+			return false
+		}
+	}
+
+	for _, access := range a.widgetAccess[widgetID] {
+		if !access.allows(user) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Whether none of the pages and widgets are hidden from the user, for things
+// that show what happens across all of them, such as the audit log
+func (a *application) canAccessEverything(user *authUser) bool {
+	if a.auth == nil {
+		return true
+	}
+
+	for p := range a.Config.Pages {
+		if !a.Config.Pages[p].Access.allows(user) {
+			return false
+		}
+	}
+
+	for widgetID := range a.widgetAccess {
+		if !a.canAccessWidget(user, widgetID) {
+			return false
+		}
+	}
+
+	return true
+}
+
 type pageTemplateData struct {
 	App  *application
 	Page *page
+	// set when looking at the page's snapshots rather than its current state
+	History *pageHistory
+	Kiosk   *pageKiosk
+	user    *authUser
+}
+
+func (d pageTemplateData) VisiblePages() []*page {
+	pages := make([]*page, 0, len(d.App.Config.Pages))
+
+	for p := range d.App.Config.Pages {
+		if d.App.canAccessPage(d.user, &d.App.Config.Pages[p]) {
+			pages = append(pages, &d.App.Config.Pages[p])
+		}
+	}
+
+	return pages
+}
+
+func (d pageTemplateData) ContentURL() string {
+	base := d.App.Config.Server.BaseURL + "/api/pages/" + d.Page.Slug
+
+	if d.History == nil {
+		return base + "/content/"
+	}
+
+	if len(d.History.Times) == 0 {
+		return ""
+	}
+
+	return base + "/snapshots/" + strconv.FormatInt(d.History.Selected.Unix(), 10)
+}
+
+func (d pageTemplateData) CanSeeWidget(widget widget) bool {
+	if base, ok := widget.(interface{ getWidgetBase() *widgetBase }); ok && !base.getWidgetBase().Schedule.isVisible(time.Now()) {
+		return false
+	}
+
+	return d.App.canAccessWidget(d.user, widget.GetID())
 }
 
 func (a *application) handlePageRequest(w http.ResponseWriter, r *http.Request) {
-	page, exists := a.slugToPage[r.PathValue("page")]
+	slug := r.PathValue("page")
+	page, exists := a.slugToPage[slug]
+	user := requestUser(r)
+
+	// users who can't see the first page get sent to the first one they can see
+	if exists && slug == "" && !a.canAccessPage(user, page) {
+		for p := range a.Config.Pages {
+			if a.canAccessPage(user, &a.Config.Pages[p]) {
+				http.Redirect(w, r, a.Config.Server.BaseURL+"/"+a.Config.Pages[p].Slug, http.StatusSeeOther)
+				return
+			}
+		}
+	}
 
-	if !exists {
+	if !exists || !a.canAccessPage(user, page) {
 		a.handleNotFound(w, r)
 		return
 	}
@@ -140,6 +550,7 @@ func (a *application) handlePageRequest(w http.ResponseWriter, r *http.Request)
 	pageData := pageTemplateData{
 		Page: page,
 		App:  a,
+		user: user,
 	}
 
 	var responseBytes bytes.Buffer
@@ -155,14 +566,17 @@ func (a *application) handlePageRequest(w http.ResponseWriter, r *http.Request)
 
 func (a *application) handlePageContentRequest(w http.ResponseWriter, r *http.Request) {
 	page, exists := a.slugToPage[r.PathValue("page")]
+	user := requestUser(r)
 
-	if !exists {
+	if !exists || !a.canAccessPage(user, page) {
 		a.handleNotFound(w, r)
 		return
 	}
 
 	pageData := pageTemplateData{
 		Page: page,
+		App:  a,
+		user: user,
 	}
 
 	var err error
@@ -172,8 +586,11 @@ func (a *application) handlePageContentRequest(w http.ResponseWriter, r *http.Re
 		page.mu.Lock()
 		defer page.mu.Unlock()
 
-		page.updateOutdatedWidgets()
+		a.updateOutdatedWidgets(page)
+
+		start := time.Now()
 		err = pageContentTemplate.Execute(&responseBytes, pageData)
+		recordPageRender(page.Slug, time.Since(start))
 	}()
 
 	if err != nil {
@@ -202,68 +619,228 @@ func (a *application) handleWidgetRequest(w http.ResponseWriter, r *http.Request
 
 	widget, exists := a.widgetByID[widgetID]
 
-	if !exists {
+	if !exists || !a.canAccessWidget(requestUser(r), widgetID) {
 		a.handleNotFound(w, r)
 		return
 	}
 
+	if r.PathValue("path") == "data" && r.Method == http.MethodGet {
+		a.handleWidgetDataRequest(w, r, widget)
+		return
+	}
+
+	if r.PathValue("path") == "refresh" && r.Method == http.MethodPost {
+		a.handleWidgetRefreshRequest(w, r, widget)
+		return
+	}
+
 	widget.handleRequest(w, r)
 }
 
+// Updates a widget that failed right away rather than waiting for its next
+// update, responding with the HTML of the widget it's placed in since that's
+// what has to be replaced for widgets within groups. Widgets that haven't
+// failed only get rendered again so that this can't be used to get around
+// their cache duration
+func (a *application) handleWidgetRefreshRequest(w http.ResponseWriter, r *http.Request, widget widget) {
+	base, hasBase := widget.(interface{ getWidgetBase() *widgetBase })
+	page, outer := a.widgetPlacement(widget.GetID())
+	user := requestUser(r)
+	if !hasBase || page == nil || !a.canAccessPage(user, page) || !a.canAccessWidget(user, outer.GetID()) {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	var html template.HTML
+
+	func() {
+		page.mu.Lock()
+		defer page.mu.Unlock()
+
+		if base.getWidgetBase().Error != nil || base.getWidgetBase().Notice != nil {
+			base.getWidgetBase().nextUpdate = time.Time{}
+			base.getWidgetBase().bypassResponseCache.Store(true)
+			defer base.getWidgetBase().bypassResponseCache.Store(false)
+		}
+
+		now := time.Now()
+		if outer.requiresUpdate(&now) {
+			a.updateWidgetWithTimeout(outer)
+		}
+
+		html = outer.Render()
+	}()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(html))
+}
+
+func (a *application) runBackgroundTasks(ctx context.Context) {
+	if len(a.backgroundTasks) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	run := func(now time.Time) {
+		for _, runner := range a.backgroundTasks {
+			runner.runBackgroundTask(now)
+		}
+	}
+
+	run(time.Now())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			run(now)
+		}
+	}
+}
+
+// Returns the URL under which a widget can handle requests of its own, see handleWidgetRequest
+func (a *application) widgetURL(widgetID uint64, path string) string {
+	return a.Config.Server.BaseURL + "/api/widgets/" + strconv.FormatUint(widgetID, 10) + "/" + path
+}
+
+// The path that Glance is served under, which is the path of the base URL when
+// it includes the domain
+func (a *application) basePath() string {
+	if a.Config.Server.BaseURL == "" {
+		return "/"
+	}
+
+	if parsed, err := url.Parse(a.Config.Server.BaseURL); err == nil && parsed.Path != "" {
+		return parsed.Path
+	}
+
+	return "/"
+}
+
+// Reverse proxies that route by path can either strip the base path before
+// forwarding requests or leave it in, both of which are supported by removing
+// it from requests that still have it
+func stripBasePath(basePath string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == basePath {
+			http.Redirect(w, r, basePath+"/", http.StatusMovedPermanently)
+			return
+		}
+
+		path, found := strings.CutPrefix(r.URL.Path, basePath)
+		if !found || !strings.HasPrefix(path, "/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		stripped := new(http.Request)
+		*stripped = *r
+		stripped.URL = new(url.URL)
+		*stripped.URL = *r.URL
+		stripped.URL.Path = path
+		stripped.URL.RawPath = ""
+
+		next.ServeHTTP(w, stripped)
+	})
+}
+
+func (a *application) handleManifestRequest(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/manifest+json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"name":             "Glance",
+		"display":          "standalone",
+		"background_color": "#151519",
+		"scope":            a.Config.Server.BaseURL + "/",
+		"start_url":        a.Config.Server.BaseURL + "/",
+		"icons": []map[string]string{{
+			"src":   a.AssetPath("app-icon.png"),
+			"type":  "image/png",
+			"sizes": "512x512",
+		}},
+	})
+}
+
 func (a *application) AssetPath(asset string) string {
 	return a.Config.Server.BaseURL + "/static/" + staticFSHash + "/" + asset
 }
 
-func (a *application) server() (func() error, func() error) {
+func (a *application) address() string {
+	return fmt.Sprintf("%s:%d", a.Config.Server.Host, a.Config.Server.Port)
+}
+
+func (a *application) handler() http.Handler {
 	// TODO: add gzip support, static files must have their gzipped contents cached
 	// TODO: add HTTPS support
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /{$}", a.handlePageRequest)
 	mux.HandleFunc("GET /{page}", a.handlePageRequest)
+	mux.HandleFunc("GET /history/{page}", a.handlePageHistoryRequest)
+	mux.HandleFunc("GET /kiosk", a.handleKioskRequest)
+	mux.HandleFunc("GET /kiosk/{page}", a.handleKioskRequest)
 
 	mux.HandleFunc("GET /api/pages/{page}/content/{$}", a.handlePageContentRequest)
+	mux.HandleFunc("GET /api/pages/{page}/events", a.handlePageEventsRequest)
+	mux.HandleFunc("GET /api/pages/{page}/snapshots/{at}", a.handlePageSnapshotRequest)
 	mux.HandleFunc("/api/widgets/{widget}/{path...}", a.handleWidgetRequest)
+	mux.HandleFunc("GET /reader/{widget}", a.handleReaderRequest)
+	mux.HandleFunc("GET /uptime/{widget}", a.handleUptimeReportRequest)
+	mux.HandleFunc("GET /audit", a.handleAuditLogRequest)
+	mux.HandleFunc("GET /diagnostics", a.handleDiagnosticsRequest)
+	mux.HandleFunc("GET /metrics", a.handleMetricsRequest)
+	mux.HandleFunc("GET /api/briefing", a.handleBriefingRequest)
+	mux.HandleFunc("GET /api/seen-items", a.handleGetSeenItemsRequest)
+	mux.HandleFunc("POST /api/seen-items", a.handleMarkSeenItemsRequest)
+	mux.HandleFunc("POST /api/webhooks/{name}", a.handleWebhookRequest)
+	mux.HandleFunc("GET /manifest.json", a.handleManifestRequest)
 	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
+	mux.HandleFunc("GET /api/health", a.handleHealthRequest)
+	mux.HandleFunc("GET /api/widgets/status", a.handleWidgetStatusRequest)
+
+	if a.imageProxy != nil {
+		mux.HandleFunc("GET /image-proxy", a.handleImageProxyRequest)
+	}
+
+	if a.auth != nil {
+		mux.HandleFunc("GET /login", a.handleLoginPageRequest)
+		mux.HandleFunc("POST /login", a.handleLoginRequest)
+		mux.HandleFunc("GET /logout", a.handleLogoutRequest)
+		mux.HandleFunc("GET /auth/oidc/login", a.handleOIDCLoginRequest)
+		mux.HandleFunc("GET /auth/oidc/callback", a.handleOIDCCallbackRequest)
+	}
 
 	mux.Handle(
 		fmt.Sprintf("GET /static/%s/{path...}", staticFSHash),
 		http.StripPrefix("/static/"+staticFSHash, fileServerWithCache(http.FS(staticFS), 24*time.Hour)),
 	)
 
-	var absAssetsPath string
 	if a.Config.Server.AssetsPath != "" {
-		absAssetsPath, _ = filepath.Abs(a.Config.Server.AssetsPath)
 		assetsFS := fileServerWithCache(http.Dir(a.Config.Server.AssetsPath), 2*time.Hour)
 		mux.Handle("/assets/{path...}", http.StripPrefix("/assets/", assetsFS))
 	}
 
-	server := http.Server{
-		Addr:    fmt.Sprintf("%s:%d", a.Config.Server.Host, a.Config.Server.Port),
-		Handler: mux,
+	var handler http.Handler = mux
+	if a.privacy != nil {
+		handler = a.privacyMiddleware(handler)
+	} else if a.imageRewriter != nil {
+		handler = a.imageProxyMiddleware(handler)
 	}
-
-	start := func() error {
-		a.Config.Server.StartedAt = time.Now()
-		log.Printf("Starting server on %s:%d (base-url: \"%s\", assets-path: \"%s\")\n",
-			a.Config.Server.Host,
-			a.Config.Server.Port,
-			a.Config.Server.BaseURL,
-			absAssetsPath,
-		)
-
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			return err
-		}
-
-		return nil
+	if a.auth != nil {
+		handler = a.authMiddleware(handler)
 	}
+	handler = crossOriginProtection(handler)
 
-	stop := func() error {
-		return server.Close()
+	if basePath := strings.TrimRight(a.basePath(), "/"); basePath != "" {
+		handler = stripBasePath(basePath, handler)
 	}
 
-	return start, stop
+	return handler
 }
@@ -0,0 +1,386 @@
+package glance
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// Only the service alerts of GTFS Realtime feeds are read, which are decoded
+// straight from the protocol buffer wire format so that the few fields that
+// are needed don't require generated code. Feeds that are served as JSON are
+// also supported, with field names either as in the spec or in camel case.
+
+const (
+	gtfsSeverityUnknown = 1
+	gtfsSeverityInfo    = 2
+	gtfsSeverityWarning = 3
+	gtfsSeveritySevere  = 4
+)
+
+var gtfsAlertEffects = map[int]string{
+	1:  "No service",
+	2:  "Reduced service",
+	3:  "Significant delays",
+	4:  "Detour",
+	5:  "Additional service",
+	6:  "Modified service",
+	7:  "Other effect",
+	8:  "Unknown effect",
+	9:  "Stop moved",
+	10: "No effect",
+	11: "Accessibility issue",
+}
+
+type gtfsAlert struct {
+	ID          string
+	Routes      []string
+	Header      string
+	Description string
+	URL         string
+	Effect      int
+	Severity    int
+	Periods     [][2]time.Time
+}
+
+// Returns the period that the given time falls within and whether there was
+// one, alerts without any periods always apply
+func (a *gtfsAlert) activePeriod(t time.Time) (time.Time, time.Time, bool) {
+	if len(a.Periods) == 0 {
+		return time.Time{}, time.Time{}, true
+	}
+
+	for _, period := range a.Periods {
+		if (period[0].IsZero() || !t.Before(period[0])) && (period[1].IsZero() || t.Before(period[1])) {
+			return period[0], period[1], true
+		}
+	}
+
+	return time.Time{}, time.Time{}, false
+}
+
+func parseGTFSRealtimeAlerts(data []byte, lang string) ([]gtfsAlert, error) {
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "{") {
+		return parseGTFSRealtimeAlertsJSON(gjson.Parse(trimmed), lang), nil
+	}
+
+	alerts := make([]gtfsAlert, 0)
+
+	// FeedMessage
+	err := protobufFields(data, func(field int, _ uint64, value []byte) error {
+		if field != 2 || value == nil {
+			return nil
+		}
+
+		// FeedEntity
+		var id string
+		var alertData []byte
+		deleted := false
+
+		err := protobufFields(value, func(field int, number uint64, value []byte) error {
+			switch field {
+			case 1:
+				id = string(value)
+			case 2:
+				deleted = number != 0
+			case 5:
+				alertData = value
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if alertData == nil || deleted {
+			return nil
+		}
+
+		alert, err := parseGTFSRealtimeAlert(alertData, lang)
+		if err != nil {
+			return fmt.Errorf("alert %s: %v", id, err)
+		}
+
+		alert.ID = id
+		alerts = append(alerts, alert)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding feed: %v", err)
+	}
+
+	return alerts, nil
+}
+
+func parseGTFSRealtimeAlert(data []byte, lang string) (gtfsAlert, error) {
+	alert := gtfsAlert{Effect: 8, Severity: gtfsSeverityUnknown}
+
+	err := protobufFields(data, func(field int, number uint64, value []byte) error {
+		switch field {
+		case 1:
+			// TimeRange
+			var period [2]time.Time
+			err := protobufFields(value, func(field int, number uint64, _ []byte) error {
+				if (field == 1 || field == 2) && number > 0 {
+					period[field-1] = time.Unix(int64(number), 0)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			alert.Periods = append(alert.Periods, period)
+		case 5:
+			// EntitySelector, where the route is either set directly or through the trip
+			return protobufFields(value, func(field int, _ uint64, value []byte) error {
+				switch field {
+				case 2:
+					alert.addRoute(string(value))
+				case 4:
+					return protobufFields(value, func(field int, _ uint64, value []byte) error {
+						if field == 5 {
+							alert.addRoute(string(value))
+						}
+
+						return nil
+					})
+				}
+
+				return nil
+			})
+		case 7:
+			alert.Effect = int(number)
+		case 8:
+			alert.URL = gtfsTranslation(value, lang)
+		case 10:
+			alert.Header = gtfsTranslation(value, lang)
+		case 11:
+			alert.Description = gtfsTranslation(value, lang)
+		case 14:
+			alert.Severity = int(number)
+		}
+
+		return nil
+	})
+
+	return alert, err
+}
+
+func (a *gtfsAlert) addRoute(route string) {
+	if route != "" && !slices.Contains(a.Routes, route) {
+		a.Routes = append(a.Routes, route)
+	}
+}
+
+// Picks the translation in the given language, otherwise the one without a
+// language or the first one
+func gtfsTranslation(data []byte, lang string) string {
+	var fallback string
+	var found string
+
+	protobufFields(data, func(field int, _ uint64, value []byte) error {
+		if field != 1 || found != "" {
+			return nil
+		}
+
+		var text, language string
+		protobufFields(value, func(field int, _ uint64, value []byte) error {
+			switch field {
+			case 1:
+				text = string(value)
+			case 2:
+				language = string(value)
+			}
+
+			return nil
+		})
+
+		if gtfsLanguageMatches(language, lang) {
+			found = text
+		} else if fallback == "" || language == "" {
+			fallback = text
+		}
+
+		return nil
+	})
+
+	return cmp.Or(found, fallback)
+}
+
+func gtfsLanguageMatches(language, lang string) bool {
+	base, _, _ := strings.Cut(language, "-")
+	return language != "" && strings.EqualFold(base, lang)
+}
+
+func parseGTFSRealtimeAlertsJSON(feed gjson.Result, lang string) []gtfsAlert {
+	alerts := make([]gtfsAlert, 0)
+
+	for _, entity := range feed.Get("entity").Array() {
+		alertJson := gtfsJSONField(entity, "alert")
+		if !alertJson.Exists() || gtfsJSONField(entity, "is_deleted").Bool() {
+			continue
+		}
+
+		alert := gtfsAlert{
+			ID:          entity.Get("id").String(),
+			URL:         gtfsJSONTranslation(gtfsJSONField(alertJson, "url"), lang),
+			Header:      gtfsJSONTranslation(gtfsJSONField(alertJson, "header_text"), lang),
+			Description: gtfsJSONTranslation(gtfsJSONField(alertJson, "description_text"), lang),
+			Effect:      gtfsJSONEnum(gtfsJSONField(alertJson, "effect"), gtfsAlertEffectNames, 8),
+			Severity:    gtfsJSONEnum(gtfsJSONField(alertJson, "severity_level"), gtfsSeverityNames, gtfsSeverityUnknown),
+		}
+
+		for _, period := range gtfsJSONField(alertJson, "active_period").Array() {
+			var times [2]time.Time
+			if start := period.Get("start").Int(); start > 0 {
+				times[0] = time.Unix(start, 0)
+			}
+			if end := period.Get("end").Int(); end > 0 {
+				times[1] = time.Unix(end, 0)
+			}
+
+			alert.Periods = append(alert.Periods, times)
+		}
+
+		for _, selector := range gtfsJSONField(alertJson, "informed_entity").Array() {
+			alert.addRoute(gtfsJSONField(selector, "route_id").String())
+			alert.addRoute(gtfsJSONField(selector.Get("trip"), "route_id").String())
+		}
+
+		alerts = append(alerts, alert)
+	}
+
+	return alerts
+}
+
+// Fields are named as in the spec when converted with most tools, but in camel
+// case when using the JSON mapping of protocol buffers
+func gtfsJSONField(value gjson.Result, name string) gjson.Result {
+	if field := value.Get(name); field.Exists() {
+		return field
+	}
+
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+
+	return value.Get(strings.Join(parts, ""))
+}
+
+func gtfsJSONTranslation(value gjson.Result, lang string) string {
+	var fallback string
+
+	for _, translation := range value.Get("translation").Array() {
+		text := translation.Get("text").String()
+		language := translation.Get("language").String()
+
+		if gtfsLanguageMatches(language, lang) {
+			return text
+		}
+
+		if fallback == "" || language == "" {
+			fallback = text
+		}
+	}
+
+	return fallback
+}
+
+var gtfsAlertEffectNames = map[string]int{
+	"NO_SERVICE":          1,
+	"REDUCED_SERVICE":     2,
+	"SIGNIFICANT_DELAYS":  3,
+	"DETOUR":              4,
+	"ADDITIONAL_SERVICE":  5,
+	"MODIFIED_SERVICE":    6,
+	"OTHER_EFFECT":        7,
+	"UNKNOWN_EFFECT":      8,
+	"STOP_MOVED":          9,
+	"NO_EFFECT":           10,
+	"ACCESSIBILITY_ISSUE": 11,
+}
+
+var gtfsSeverityNames = map[string]int{
+	"UNKNOWN_SEVERITY": gtfsSeverityUnknown,
+	"INFO":             gtfsSeverityInfo,
+	"WARNING":          gtfsSeverityWarning,
+	"SEVERE":           gtfsSeveritySevere,
+}
+
+// Enums are either their name or their number
+func gtfsJSONEnum(value gjson.Result, names map[string]int, fallback int) int {
+	switch value.Type {
+	case gjson.Number:
+		return int(value.Int())
+	case gjson.String:
+		if number, ok := names[value.String()]; ok {
+			return number
+		}
+	}
+
+	return fallback
+}
+
+// Calls fn with every field of a protocol buffer message, with the number for
+// varint and fixed size fields and the bytes for length delimited ones. Nested
+// messages are left to be decoded by the caller.
+func protobufFields(data []byte, fn func(field int, number uint64, value []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("malformed field key")
+		}
+		data = data[n:]
+
+		field := int(key >> 3)
+		var number uint64
+		var value []byte
+
+		switch key & 0x07 {
+		case 0:
+			number, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errors.New("malformed varint")
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return errors.New("truncated fixed64")
+			}
+			number = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case 2:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return errors.New("malformed length")
+			}
+			value = data[n : n+int(length)]
+			data = data[n+int(length):]
+		case 5:
+			if len(data) < 4 {
+				return errors.New("truncated fixed32")
+			}
+			number = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d", key&0x07)
+		}
+
+		if err := fn(field, number, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package glance
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type widgetStatusJson struct {
+	ID    uint64 `json:"id"`
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	Page  string `json:"page"`
+	// one of ok, partial, failing, pending or static
+	Status       string     `json:"status"`
+	Stale        bool       `json:"stale"`
+	LastUpdate   *time.Time `json:"last_update"`
+	LastSuccess  *time.Time `json:"last_success"`
+	FailingSince *time.Time `json:"failing_since"`
+	NextUpdate   *time.Time `json:"next_update"`
+	LastError    string     `json:"last_error,omitempty"`
+	ErrorClass   string     `json:"error_class,omitempty"`
+	// seconds since the last successful update
+	Age *int64 `json:"age,omitempty"`
+}
+
+// Must be called while holding the lock of the page the widget is on
+func (w *widgetBase) status(now time.Time) widgetStatusJson {
+	status := widgetStatusJson{
+		ID:    w.ID,
+		Type:  w.GetType(),
+		Title: w.Title,
+		Page:  w.pageTitle,
+	}
+
+	w.metrics.mu.Lock()
+	lastUpdate := w.metrics.lastUpdate
+	lastSuccess := w.metrics.lastSuccess
+	failingSince := w.metrics.failingSince
+	w.metrics.mu.Unlock()
+
+	status.LastUpdate = timeOrNil(lastUpdate)
+	status.LastSuccess = timeOrNil(lastSuccess)
+	status.FailingSince = timeOrNil(failingSince)
+
+	if !lastSuccess.IsZero() {
+		age := int64(now.Sub(lastSuccess).Seconds())
+		status.Age = &age
+	}
+
+	switch {
+	case w.cacheType == cacheTypeInfinite:
+		status.Status = "static"
+		return status
+	case lastUpdate.IsZero():
+		status.Status = "pending"
+		return status
+	case w.Error != nil:
+		status.Status = "failing"
+		status.LastError = w.Error.Error()
+		status.ErrorClass = errorClass(w.Error)
+	case w.Notice != nil:
+		status.Status = "partial"
+		status.LastError = w.Notice.Error()
+		status.ErrorClass = errorClass(w.Notice)
+	default:
+		status.Status = "ok"
+	}
+
+	status.NextUpdate = timeOrNil(w.nextUpdate)
+	// either the cache has expired and nobody has loaded the page since, or
+	// the widget is showing a previous response because the upstream failed
+	status.Stale = w.requiresUpdate(&now) || w.usedRestoredResponse.Load()
+
+	return status
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// Widgets within groups and split columns are listed individually
+func (a *application) widgetStatuses(user *authUser) []widgetStatusJson {
+	now := time.Now()
+	statuses := make([]widgetStatusJson, 0, len(a.widgetByID))
+
+	for p := range a.Config.Pages {
+		page := &a.Config.Pages[p]
+		if !a.canAccessPage(user, page) {
+			continue
+		}
+
+		var widgets []metricsWidget
+
+		page.mu.Lock()
+		for c := range page.Columns {
+			for _, widget := range page.Columns[c].Widgets {
+				if a.canAccessWidget(user, widget.GetID()) {
+					widgets = collectMetricsWidgets(page.Title, widget, widgets)
+				}
+			}
+		}
+
+		for _, widget := range widgets {
+			statuses = append(statuses, widget.base.status(now))
+		}
+		page.mu.Unlock()
+	}
+
+	return statuses
+}
+
+func (a *application) handleWidgetStatusRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(a.widgetStatuses(requestUser(r)))
+}
+
+// Doesn't require logging in so that it can be used by container healthchecks,
+// which is why it only includes counts rather than which widgets are failing.
+// Pages aren't locked since that would block for as long as one of them is
+// being updated, so this only relies on what's recorded by recordUpdate.
+func (a *application) handleHealthRequest(w http.ResponseWriter, _ *http.Request) {
+	var widgets []metricsWidget
+	for p := range a.Config.Pages {
+		page := &a.Config.Pages[p]
+		for c := range page.Columns {
+			for _, widget := range page.Columns[c].Widgets {
+				widgets = collectMetricsWidgets(page.Title, widget, widgets)
+			}
+		}
+	}
+
+	counts := map[string]int{}
+
+	for _, widget := range widgets {
+		m := &widget.base.metrics
+		m.mu.Lock()
+		switch {
+		case widget.base.cacheType == cacheTypeInfinite:
+			counts["static"]++
+		case m.updates == 0:
+			counts["pending"]++
+		case !m.failingSince.IsZero():
+			counts["failing"]++
+		default:
+			counts["ok"]++
+		}
+		m.mu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":         ternary(counts["failing"] > 0, "degraded", "ok"),
+		"version":        a.Version,
+		"uptime_seconds": int64(time.Since(processStartedAt).Seconds()),
+		"widgets": map[string]int{
+			"ok":      counts["ok"],
+			"failing": counts["failing"],
+			"pending": counts["pending"],
+			"static":  counts["static"],
+		},
+	})
+}
@@ -0,0 +1,547 @@
+package glance
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"gopkg.in/yaml.v3"
+)
+
+// The language that the text Glance renders itself is shown in, along with
+// how numbers are formatted. Can be set globally and for each widget.
+type localeField struct {
+	tag language.Tag
+}
+
+func (l *localeField) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+
+	tag, err := language.Parse(value)
+	if err != nil {
+		return errorAtConfigLine(node, fmt.Errorf("invalid locale %s", value))
+	}
+
+	l.tag = tag
+
+	return nil
+}
+
+func (l localeField) isSet() bool {
+	return l.tag != language.Und
+}
+
+func (l localeField) String() string {
+	if !l.isSet() {
+		return "en"
+	}
+
+	return l.tag.String()
+}
+
+// The language without the region, such as de for de-AT
+func (l localeField) language() string {
+	if !l.isSet() {
+		return "en"
+	}
+
+	base, _ := l.tag.Base()
+	return base.String()
+}
+
+// Whether temperatures and such should be shown in imperial units by default
+func (l localeField) usesImperialUnits() bool {
+	// a locale without a region only gets one guessed for it
+	region, confidence := l.tag.Region()
+	if confidence != language.Exact {
+		return false
+	}
+
+	switch region.String() {
+	case "US", "LR", "MM":
+		return true
+	}
+
+	return false
+}
+
+type locale struct {
+	printer      *message.Printer
+	translations map[string]string
+}
+
+var (
+	localesMu sync.Mutex
+	locales   = map[language.Tag]*locale{}
+)
+
+func getLocale(field localeField) *locale {
+	localesMu.Lock()
+	defer localesMu.Unlock()
+
+	if l, ok := locales[field.tag]; ok {
+		return l
+	}
+
+	base, _ := field.tag.Base()
+	l := &locale{
+		printer:      message.NewPrinter(field.tag),
+		translations: translations[base.String()],
+	}
+	locales[field.tag] = l
+
+	return l
+}
+
+// Text that doesn't have a translation is shown in English
+func (l *locale) translate(text string) string {
+	if translated, ok := l.translations[text]; ok {
+		return translated
+	}
+
+	return text
+}
+
+func (l *locale) formatApproxNumber(count int) string {
+	if count < 1_000 {
+		return strconv.Itoa(count)
+	}
+
+	if count < 10_000 {
+		return l.printer.Sprintf("%.1f", float64(count)/1_000) + "k"
+	}
+
+	if count < 1_000_000 {
+		return strconv.Itoa(count/1_000) + "k"
+	}
+
+	return l.printer.Sprintf("%.1f", float64(count)/1_000_000) + "m"
+}
+
+// Replaces the functions that render text with ones for the given locale
+func (l *locale) templateFunctions() template.FuncMap {
+	return template.FuncMap{
+		"tr":                 l.translate,
+		"formatApproxNumber": l.formatApproxNumber,
+		"formatNumber":       l.printer.Sprint,
+		"formatPrice": func(price float64) string {
+			return l.printer.Sprintf("%.2f", price)
+		},
+		"formatPriceWithPrecision": func(precision int, price float64) string {
+			return l.printer.Sprintf("%."+strconv.Itoa(precision)+"f", price)
+		},
+	}
+}
+
+// The files each template was parsed from, so that they can be parsed again
+// with the functions of a different locale
+var templateFiles = map[*template.Template][]string{}
+
+type localizedTemplateKey struct {
+	template *template.Template
+	tag      language.Tag
+}
+
+var localizedTemplates sync.Map
+
+// Returns a copy of the template that renders text in the given locale, or
+// the template itself if it's already in English
+func localizedTemplate(t *template.Template, field localeField) *template.Template {
+	if !field.isSet() || field.tag == language.English {
+		return t
+	}
+
+	files, ok := templateFiles[t]
+	if !ok {
+		return t
+	}
+
+	key := localizedTemplateKey{t, field.tag}
+	if localized, ok := localizedTemplates.Load(key); ok {
+		return localized.(*template.Template)
+	}
+
+	localized, err := template.New(files[0]).
+		Funcs(globalTemplateFunctions).
+		Funcs(getLocale(field).templateFunctions()).
+		ParseFS(templateFS, files...)
+	if err != nil {
+		slog.Error("Failed to parse localized template", "template", files[0], "locale", field.String(), "error", err)
+		return t
+	}
+
+	stored, _ := localizedTemplates.LoadOrStore(key, localized)
+	return stored.(*template.Template)
+}
+
+// Keyed by the English text as it appears in the templates
+var translations = map[string]map[string]string{
+	"de": {
+		"Retry":                         "Erneut versuchen",
+		"ERROR":                         "FEHLER",
+		"No error information provided": "Keine Fehlerinformationen vorhanden",
+		"points":                        "Punkte",
+		"comments":                      "Kommentare",
+		"Feels like":                    "Gefühlt",
+		"All sites are online":          "Alle Seiten sind online",
+		"Uptime this month":             "Verfügbarkeit diesen Monat",
+		"Silenced until":                "Stummgeschaltet bis",
+		"changed":                       "geändert",
+		"password changed":              "Passwort geändert",
+		"Latest report":                 "Letzter Bericht",
+		"Report from":                   "Bericht von",
+		"Seen":                          "Gesehen",
+		"Due":                           "Fällig",
+		"Down from":                     "Gesunken von",
+		"Up from":                       "Gestiegen von",
+		"Week":                          "Woche",
+		"From":                          "Aus",
+		"article":                       "Artikel",
+		"articles":                      "Artikeln",
+		"written":                       "geschrieben",
+		"Nothing on the agenda.":        "Nichts auf dem Plan.",
+		"and":                           "und",
+		"%s is today":                   "%s ist heute",
+		"%s is tomorrow":                "%s ist morgen",
+		"next up is %s at %s":           "als Nächstes kommt %s um %s",
+		"next up is %s tomorrow at %s":  "als Nächstes kommt %s morgen um %s",
+		"there's nothing left to do":    "es gibt nichts mehr zu tun",
+		"you have 1 task left":          "du hast noch 1 Aufgabe",
+		"you have %d tasks left":        "du hast noch %d Aufgaben",
+		"it's %d° in %s with a high of %d° and a low of %d°": "es sind %d° in %s mit einem Höchstwert von %d° und einem Tiefstwert von %d°",
+		"%s's %s":                  "%s: %s",
+		"%s for %s":                "%s für %s",
+		"it takes %s to get to %s": "du brauchst %s nach %s",
+		"it takes %s to get to %s with %s of traffic": "du brauchst %s nach %s, davon %s durch Verkehr",
+		"Focus":                              "Fokus",
+		"Short break":                        "Kurze Pause",
+		"Long break":                         "Lange Pause",
+		"done":                               "erledigt",
+		"long break in":                      "lange Pause in",
+		"Start":                              "Start",
+		"Pause":                              "Pause",
+		"Skip":                               "Überspringen",
+		"Reset":                              "Zurücksetzen",
+		"Now":                                "Jetzt",
+		"until":                              "bis",
+		"Next":                               "Als Nächstes",
+		"No upcoming lessons":                "Keine anstehenden Stunden",
+		"No lessons":                         "Keine Stunden",
+		"Collected today":                    "Heute abgeholt",
+		"Out tonight":                        "Heute Abend rausstellen",
+		"Today":                              "Heute",
+		"Tomorrow":                           "Morgen",
+		"In %d days":                         "In %d Tagen",
+		"No collections in the next %d days": "Keine Abholungen in den nächsten %d Tagen",
+		"Until":                              "Bis",
+		"No disruptions":                     "Keine Störungen",
+	},
+	"es": {
+		"Retry":                         "Reintentar",
+		"ERROR":                         "ERROR",
+		"No error information provided": "No hay información sobre el error",
+		"points":                        "puntos",
+		"comments":                      "comentarios",
+		"Feels like":                    "Sensación térmica",
+		"All sites are online":          "Todos los sitios están en línea",
+		"Uptime this month":             "Disponibilidad este mes",
+		"Silenced until":                "Silenciado hasta",
+		"changed":                       "cambiado",
+		"password changed":              "contraseña cambiada",
+		"Latest report":                 "Último informe",
+		"Report from":                   "Informe de",
+		"Seen":                          "Visto",
+		"Due":                           "Pendiente desde",
+		"Down from":                     "Bajó desde",
+		"Up from":                       "Subió desde",
+		"Week":                          "Semana",
+		"From":                          "De",
+		"article":                       "artículo",
+		"articles":                      "artículos",
+		"written":                       "escrito",
+		"Nothing on the agenda.":        "Nada en la agenda.",
+		"and":                           "y",
+		"%s is today":                   "%s es hoy",
+		"%s is tomorrow":                "%s es mañana",
+		"next up is %s at %s":           "lo próximo es %s a las %s",
+		"next up is %s tomorrow at %s":  "lo próximo es %s mañana a las %s",
+		"there's nothing left to do":    "no queda nada por hacer",
+		"you have 1 task left":          "te queda 1 tarea",
+		"you have %d tasks left":        "te quedan %d tareas",
+		"it's %d° in %s with a high of %d° and a low of %d°": "hace %d° en %s con una máxima de %d° y una mínima de %d°",
+		"%s's %s":                  "%s: %s",
+		"%s for %s":                "%s para %s",
+		"it takes %s to get to %s": "se tarda %s en llegar a %s",
+		"it takes %s to get to %s with %s of traffic": "se tarda %s en llegar a %s con %s de tráfico",
+		"Focus":                              "Concentración",
+		"Short break":                        "Descanso corto",
+		"Long break":                         "Descanso largo",
+		"done":                               "hechas",
+		"long break in":                      "descanso largo en",
+		"Start":                              "Iniciar",
+		"Pause":                              "Pausar",
+		"Skip":                               "Saltar",
+		"Reset":                              "Reiniciar",
+		"Now":                                "Ahora",
+		"until":                              "hasta",
+		"Next":                               "Siguiente",
+		"No upcoming lessons":                "No hay clases próximas",
+		"No lessons":                         "No hay clases",
+		"Collected today":                    "Se recoge hoy",
+		"Out tonight":                        "Sacar esta noche",
+		"Today":                              "Hoy",
+		"Tomorrow":                           "Mañana",
+		"In %d days":                         "En %d días",
+		"No collections in the next %d days": "No hay recogidas en los próximos %d días",
+		"Until":                              "Hasta",
+		"No disruptions":                     "Sin incidencias",
+	},
+	"fr": {
+		"Retry":                         "Réessayer",
+		"ERROR":                         "ERREUR",
+		"No error information provided": "Aucune information sur l'erreur",
+		"points":                        "points",
+		"comments":                      "commentaires",
+		"Feels like":                    "Ressenti",
+		"All sites are online":          "Tous les sites sont en ligne",
+		"Uptime this month":             "Disponibilité ce mois-ci",
+		"Silenced until":                "Silencieux jusqu'à",
+		"changed":                       "modifié",
+		"password changed":              "mot de passe modifié",
+		"Latest report":                 "Dernier rapport",
+		"Report from":                   "Rapport généré",
+		"Seen":                          "Vu",
+		"Due":                           "Prévu",
+		"Down from":                     "En baisse par rapport à",
+		"Up from":                       "En hausse par rapport à",
+		"Week":                          "Semaine",
+		"From":                          "À partir de",
+		"article":                       "article",
+		"articles":                      "articles",
+		"written":                       "rédigé",
+		"Nothing on the agenda.":        "Rien au programme.",
+		"and":                           "et",
+		"%s is today":                   "%s est aujourd'hui",
+		"%s is tomorrow":                "%s est demain",
+		"next up is %s at %s":           "ensuite %s à %s",
+		"next up is %s tomorrow at %s":  "ensuite %s demain à %s",
+		"there's nothing left to do":    "il ne reste rien à faire",
+		"you have 1 task left":          "il vous reste 1 tâche",
+		"you have %d tasks left":        "il vous reste %d tâches",
+		"it's %d° in %s with a high of %d° and a low of %d°": "il fait %d° à %s avec un maximum de %d° et un minimum de %d°",
+		"%s's %s":                  "%s : %s",
+		"%s for %s":                "%s pour %s",
+		"it takes %s to get to %s": "il faut %s pour aller à %s",
+		"it takes %s to get to %s with %s of traffic": "il faut %s pour aller à %s dont %s de trafic",
+		"Focus":                              "Concentration",
+		"Short break":                        "Pause courte",
+		"Long break":                         "Pause longue",
+		"done":                               "terminées",
+		"long break in":                      "pause longue dans",
+		"Start":                              "Démarrer",
+		"Pause":                              "Pause",
+		"Skip":                               "Passer",
+		"Reset":                              "Réinitialiser",
+		"Now":                                "Maintenant",
+		"until":                              "jusqu'à",
+		"Next":                               "Ensuite",
+		"No upcoming lessons":                "Aucun cours à venir",
+		"No lessons":                         "Aucun cours",
+		"Collected today":                    "Collecté aujourd'hui",
+		"Out tonight":                        "À sortir ce soir",
+		"Today":                              "Aujourd'hui",
+		"Tomorrow":                           "Demain",
+		"In %d days":                         "Dans %d jours",
+		"No collections in the next %d days": "Aucune collecte dans les %d prochains jours",
+		"Until":                              "Jusqu'au",
+		"No disruptions":                     "Aucune perturbation",
+	},
+	"it": {
+		"Retry":                         "Riprova",
+		"ERROR":                         "ERRORE",
+		"No error information provided": "Nessuna informazione sull'errore",
+		"points":                        "punti",
+		"comments":                      "commenti",
+		"Feels like":                    "Percepita",
+		"All sites are online":          "Tutti i siti sono online",
+		"Uptime this month":             "Disponibilità questo mese",
+		"Silenced until":                "Silenziato fino a",
+		"changed":                       "modificato",
+		"password changed":              "password modificata",
+		"Latest report":                 "Ultimo rapporto",
+		"Report from":                   "Rapporto di",
+		"Seen":                          "Visto",
+		"Due":                           "Scaduto",
+		"Down from":                     "In calo da",
+		"Up from":                       "In rialzo da",
+		"Week":                          "Settimana",
+		"From":                          "Da",
+		"article":                       "articolo",
+		"articles":                      "articoli",
+		"written":                       "scritto",
+		"Nothing on the agenda.":        "Niente in programma.",
+		"and":                           "e",
+		"%s is today":                   "%s è oggi",
+		"%s is tomorrow":                "%s è domani",
+		"next up is %s at %s":           "il prossimo è %s alle %s",
+		"next up is %s tomorrow at %s":  "il prossimo è %s domani alle %s",
+		"there's nothing left to do":    "non c'è più niente da fare",
+		"you have 1 task left":          "ti resta 1 attività",
+		"you have %d tasks left":        "ti restano %d attività",
+		"it's %d° in %s with a high of %d° and a low of %d°": "ci sono %d° a %s con una massima di %d° e una minima di %d°",
+		"%s's %s":                  "%s: %s",
+		"%s for %s":                "%s per %s",
+		"it takes %s to get to %s": "ci vogliono %s per arrivare a %s",
+		"it takes %s to get to %s with %s of traffic": "ci vogliono %s per arrivare a %s con %s di traffico",
+		"Focus":                              "Concentrazione",
+		"Short break":                        "Pausa breve",
+		"Long break":                         "Pausa lunga",
+		"done":                               "completate",
+		"long break in":                      "pausa lunga tra",
+		"Start":                              "Avvia",
+		"Pause":                              "Pausa",
+		"Skip":                               "Salta",
+		"Reset":                              "Azzera",
+		"Now":                                "Ora",
+		"until":                              "fino alle",
+		"Next":                               "Prossima",
+		"No upcoming lessons":                "Nessuna lezione in arrivo",
+		"No lessons":                         "Nessuna lezione",
+		"Collected today":                    "Ritirato oggi",
+		"Out tonight":                        "Da esporre stasera",
+		"Today":                              "Oggi",
+		"Tomorrow":                           "Domani",
+		"In %d days":                         "Tra %d giorni",
+		"No collections in the next %d days": "Nessun ritiro nei prossimi %d giorni",
+		"Until":                              "Fino al",
+		"No disruptions":                     "Nessun disservizio",
+	},
+	"nl": {
+		"Retry":                         "Opnieuw proberen",
+		"ERROR":                         "FOUT",
+		"No error information provided": "Geen foutinformatie beschikbaar",
+		"points":                        "punten",
+		"comments":                      "reacties",
+		"Feels like":                    "Voelt als",
+		"All sites are online":          "Alle sites zijn online",
+		"Uptime this month":             "Beschikbaarheid deze maand",
+		"Silenced until":                "Gedempt tot",
+		"changed":                       "gewijzigd",
+		"password changed":              "wachtwoord gewijzigd",
+		"Latest report":                 "Laatste rapport",
+		"Report from":                   "Rapport van",
+		"Seen":                          "Gezien",
+		"Due":                           "Gepland",
+		"Down from":                     "Gedaald van",
+		"Up from":                       "Gestegen van",
+		"Week":                          "Week",
+		"From":                          "Uit",
+		"article":                       "artikel",
+		"articles":                      "artikelen",
+		"written":                       "geschreven",
+		"Nothing on the agenda.":        "Niets op de agenda.",
+		"and":                           "en",
+		"%s is today":                   "%s is vandaag",
+		"%s is tomorrow":                "%s is morgen",
+		"next up is %s at %s":           "hierna komt %s om %s",
+		"next up is %s tomorrow at %s":  "hierna komt %s morgen om %s",
+		"there's nothing left to do":    "er is niets meer te doen",
+		"you have 1 task left":          "je hebt nog 1 taak",
+		"you have %d tasks left":        "je hebt nog %d taken",
+		"it's %d° in %s with a high of %d° and a low of %d°": "het is %d° in %s met een maximum van %d° en een minimum van %d°",
+		"%s's %s":                  "%s: %s",
+		"%s for %s":                "%s voor %s",
+		"it takes %s to get to %s": "het duurt %s om bij %s te komen",
+		"it takes %s to get to %s with %s of traffic": "het duurt %s om bij %s te komen met %s vertraging",
+		"Focus":                              "Focus",
+		"Short break":                        "Korte pauze",
+		"Long break":                         "Lange pauze",
+		"done":                               "klaar",
+		"long break in":                      "lange pauze over",
+		"Start":                              "Start",
+		"Pause":                              "Pauze",
+		"Skip":                               "Overslaan",
+		"Reset":                              "Resetten",
+		"Now":                                "Nu",
+		"until":                              "tot",
+		"Next":                               "Volgende",
+		"No upcoming lessons":                "Geen komende lessen",
+		"No lessons":                         "Geen lessen",
+		"Collected today":                    "Vandaag opgehaald",
+		"Out tonight":                        "Vanavond buitenzetten",
+		"Today":                              "Vandaag",
+		"Tomorrow":                           "Morgen",
+		"In %d days":                         "Over %d dagen",
+		"No collections in the next %d days": "Geen ophaaldagen in de komende %d dagen",
+		"Until":                              "Tot",
+		"No disruptions":                     "Geen verstoringen",
+	},
+	"pt": {
+		"Retry":                         "Tentar novamente",
+		"ERROR":                         "ERRO",
+		"No error information provided": "Nenhuma informação sobre o erro",
+		"points":                        "pontos",
+		"comments":                      "comentários",
+		"Feels like":                    "Sensação de",
+		"All sites are online":          "Todos os sites estão online",
+		"Uptime this month":             "Disponibilidade este mês",
+		"Silenced until":                "Silenciado até",
+		"changed":                       "alterado",
+		"password changed":              "senha alterada",
+		"Latest report":                 "Último relatório",
+		"Report from":                   "Relatório de",
+		"Seen":                          "Visto",
+		"Due":                           "Pendente",
+		"Down from":                     "Caiu de",
+		"Up from":                       "Subiu de",
+		"Week":                          "Semana",
+		"From":                          "De",
+		"article":                       "artigo",
+		"articles":                      "artigos",
+		"written":                       "escrito",
+		"Nothing on the agenda.":        "Nada na agenda.",
+		"and":                           "e",
+		"%s is today":                   "%s é hoje",
+		"%s is tomorrow":                "%s é amanhã",
+		"next up is %s at %s":           "o próximo é %s às %s",
+		"next up is %s tomorrow at %s":  "o próximo é %s amanhã às %s",
+		"there's nothing left to do":    "não há mais nada a fazer",
+		"you have 1 task left":          "você tem 1 tarefa pendente",
+		"you have %d tasks left":        "você tem %d tarefas pendentes",
+		"it's %d° in %s with a high of %d° and a low of %d°": "faz %d° em %s com máxima de %d° e mínima de %d°",
+		"%s's %s":                  "%s: %s",
+		"%s for %s":                "%s para %s",
+		"it takes %s to get to %s": "leva %s para chegar a %s",
+		"it takes %s to get to %s with %s of traffic": "leva %s para chegar a %s com %s de trânsito",
+		"Focus":                              "Foco",
+		"Short break":                        "Pausa curta",
+		"Long break":                         "Pausa longa",
+		"done":                               "concluídas",
+		"long break in":                      "pausa longa em",
+		"Start":                              "Iniciar",
+		"Pause":                              "Pausar",
+		"Skip":                               "Pular",
+		"Reset":                              "Reiniciar",
+		"Now":                                "Agora",
+		"until":                              "até",
+		"Next":                               "Próxima",
+		"No upcoming lessons":                "Nenhuma aula próxima",
+		"No lessons":                         "Nenhuma aula",
+		"Collected today":                    "Coletado hoje",
+		"Out tonight":                        "Colocar para fora hoje à noite",
+		"Today":                              "Hoje",
+		"Tomorrow":                           "Amanhã",
+		"In %d days":                         "Em %d dias",
+		"No collections in the next %d days": "Nenhuma coleta nos próximos %d dias",
+		"Until":                              "Até",
+		"No disruptions":                     "Sem interrupções",
+	},
+}
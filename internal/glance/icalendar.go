@@ -0,0 +1,529 @@
+package glance
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The source can either be a path to a local file or a URL
+func readContentSource(ctx context.Context, client requestDoer, source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", response.StatusCode, source)
+	}
+
+	return io.ReadAll(response.Body)
+}
+
+// Both vCard and iCalendar split long lines by starting the continuation with
+// a space or a tab
+func unfoldContentLines(contents []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+type contentLine struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parses lines such as item1.BDAY;VALUE=date:1985-04-12, the group prefix is dropped
+func parseContentLine(line string) (contentLine, bool) {
+	head, value, found := strings.Cut(line, ":")
+	if !found {
+		return contentLine{}, false
+	}
+
+	parts := strings.Split(head, ";")
+	name := strings.ToUpper(parts[0])
+	if i := strings.LastIndex(name, "."); i != -1 {
+		name = name[i+1:]
+	}
+
+	params := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		if key, paramValue, found := strings.Cut(part, "="); found {
+			params[strings.ToUpper(key)] = strings.Trim(paramValue, `"`)
+		}
+	}
+
+	return contentLine{name: name, params: params, value: value}, true
+}
+
+var contentTextUnescaper = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ", `\\`, `\`)
+
+// Parses date times that are either in UTC, in the time zone given by the TZID
+// parameter or floating, which are all shown in the local time zone
+func parseICalendarDateTime(line contentLine) (time.Time, bool) {
+	value := strings.TrimSpace(line.value)
+
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t.Local(), true
+	}
+
+	location := time.Local
+	if tzid := line.params["TZID"]; tzid != "" {
+		if loaded, err := time.LoadLocation(tzid); err == nil {
+			location = loaded
+		}
+	}
+
+	if t, err := time.ParseInLocation("20060102T150405", value, location); err == nil {
+		return t.In(time.Local), true
+	}
+
+	if t, err := time.ParseInLocation("20060102", value, time.Local); err == nil {
+		return t, true
+	}
+
+	return time.Time{}, false
+}
+
+func fetchICalendarEvents(ctx context.Context, client requestDoer, source string) ([]icalEvent, error) {
+	contents, err := readContentSource(ctx, client, source)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := unfoldContentLines(contents)
+	if !slices.ContainsFunc(lines, func(line string) bool { return strings.EqualFold(line, "BEGIN:VCALENDAR") }) {
+		return nil, errors.New("not an iCalendar file")
+	}
+
+	return parseICalendarEvents(lines), nil
+}
+
+// The properties of a VEVENT, keyed by their name
+type icalEvent map[string][]contentLine
+
+// Components such as alarms can be nested within events and have a summary of
+// their own, their properties are left out
+func parseICalendarEvents(lines []string) []icalEvent {
+	var events []icalEvent
+	var current icalEvent
+	var nested int
+
+	for _, raw := range lines {
+		line, ok := parseContentLine(raw)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case line.name == "BEGIN" && strings.EqualFold(line.value, "VEVENT"):
+			current, nested = make(icalEvent), 0
+		case current == nil:
+			continue
+		case line.name == "BEGIN":
+			nested++
+		case line.name == "END" && nested > 0:
+			nested--
+		case nested > 0:
+			continue
+		case line.name == "END" && strings.EqualFold(line.value, "VEVENT"):
+			events = append(events, current)
+			current = nil
+		default:
+			current[line.name] = append(current[line.name], line)
+		}
+	}
+
+	return events
+}
+
+func (e icalEvent) get(name string) (contentLine, bool) {
+	if lines := e[name]; len(lines) > 0 {
+		return lines[0], true
+	}
+
+	return contentLine{}, false
+}
+
+func (e icalEvent) text(name string) string {
+	line, _ := e.get(name)
+	return strings.TrimSpace(contentTextUnescaper.Replace(line.value))
+}
+
+func (e icalEvent) dateTime(name string) (time.Time, bool) {
+	line, ok := e.get(name)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return parseICalendarDateTime(line)
+}
+
+func (e icalEvent) isAllDay() bool {
+	line, _ := e.get("DTSTART")
+	return line.params["VALUE"] == "DATE" || len(strings.TrimSpace(line.value)) == 8
+}
+
+func (e icalEvent) isCancelled() bool {
+	return strings.EqualFold(e.text("STATUS"), "CANCELLED")
+}
+
+// Events with a RECURRENCE-ID replace a single occurrence of the recurring
+// event with the same UID, such as when it was moved or cancelled
+func (e icalEvent) isOverride() bool {
+	_, ok := e.get("RECURRENCE-ID")
+	return ok
+}
+
+type icalOccurrence struct {
+	event icalEvent
+	start time.Time
+}
+
+// Returns the occurrences of the events that start between from and until.
+// Occurrences that were overridden are replaced by the events overriding them,
+// cancelled ones are left out and events with recurrence rules that aren't
+// supported get logged and skipped.
+func icalOccurrences(events []icalEvent, from, until time.Time, logger *slog.Logger) []icalOccurrence {
+	overridden := make(map[string][]time.Time)
+
+	for _, event := range events {
+		uid := event.text("UID")
+		if !event.isOverride() || uid == "" {
+			continue
+		}
+
+		if id, ok := event.dateTime("RECURRENCE-ID"); ok {
+			overridden[uid] = append(overridden[uid], id)
+		}
+	}
+
+	var occurrences []icalOccurrence
+
+	for _, event := range events {
+		if event.isCancelled() {
+			continue
+		}
+
+		start, ok := event.dateTime("DTSTART")
+		if !ok {
+			continue
+		}
+
+		recurring := icalRecurringEvent{start: start}
+
+		if !event.isOverride() {
+			rule, _ := event.get("RRULE")
+			recurring.rule = strings.ToUpper(strings.TrimSpace(rule.value))
+
+			for _, line := range event["EXDATE"] {
+				for _, value := range strings.Split(line.value, ",") {
+					if excluded, ok := parseICalendarDateTime(contentLine{params: line.params, value: value}); ok {
+						recurring.exclude = append(recurring.exclude, excluded)
+					}
+				}
+			}
+
+			if uid := event.text("UID"); uid != "" {
+				recurring.exclude = append(recurring.exclude, overridden[uid]...)
+			}
+		}
+
+		starts, err := recurring.occurrences(from, until)
+		if err != nil {
+			logger.Warn("Skipping event with unsupported recurrence rule", "event", event.text("SUMMARY"), "rule", recurring.rule, "error", err)
+			continue
+		}
+
+		for _, start := range starts {
+			occurrences = append(occurrences, icalOccurrence{event: event, start: start})
+		}
+	}
+
+	return occurrences
+}
+
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// A day of the week from BYDAY, optionally limited to the nth one of the
+// month, where negative numbers count from the end of it
+type icalWeekday struct {
+	weekday time.Weekday
+	nth     int
+}
+
+type icalRecurringEvent struct {
+	start   time.Time
+	rule    string
+	exclude []time.Time
+}
+
+type icalRecurrenceRule struct {
+	freq      string
+	interval  int
+	count     int
+	last      time.Time
+	byDay     []icalWeekday
+	weekStart time.Weekday
+}
+
+// Only the parts of recurrence rules that collection schedules and timetables
+// tend to use are supported, which is a frequency with an optional interval,
+// count, end date and days of the week
+func parseICalendarRecurrenceRule(rule string) (*icalRecurrenceRule, error) {
+	r := &icalRecurrenceRule{interval: 1, weekStart: time.Monday}
+
+	for _, part := range strings.Split(rule, ";") {
+		key, value, _ := strings.Cut(part, "=")
+
+		switch key {
+		case "FREQ":
+			r.freq = value
+		case "INTERVAL":
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				r.interval = n
+			}
+		case "COUNT":
+			r.count, _ = strconv.Atoi(value)
+		case "UNTIL":
+			last, ok := parseICalendarDateTime(contentLine{value: value})
+			if !ok {
+				return nil, fmt.Errorf("invalid UNTIL %s", value)
+			}
+
+			// dates include the whole day
+			if len(value) == 8 {
+				last = last.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+
+			r.last = last
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				if len(day) < 2 {
+					return nil, fmt.Errorf("invalid BYDAY %s", value)
+				}
+
+				weekday, ok := icalWeekdays[day[len(day)-2:]]
+				if !ok {
+					return nil, fmt.Errorf("invalid BYDAY %s", value)
+				}
+
+				var nth int
+				if prefix := day[:len(day)-2]; prefix != "" {
+					n, err := strconv.Atoi(prefix)
+					if err != nil || n == 0 {
+						return nil, fmt.Errorf("invalid BYDAY %s", value)
+					}
+
+					nth = n
+				}
+
+				r.byDay = append(r.byDay, icalWeekday{weekday: weekday, nth: nth})
+			}
+		case "WKST":
+			weekday, ok := icalWeekdays[value]
+			if !ok {
+				return nil, fmt.Errorf("invalid WKST %s", value)
+			}
+
+			r.weekStart = weekday
+		case "":
+		default:
+			return nil, fmt.Errorf("%s is not supported", key)
+		}
+	}
+
+	switch r.freq {
+	case "DAILY", "WEEKLY", "MONTHLY":
+	case "YEARLY":
+		if len(r.byDay) > 0 {
+			return nil, errors.New("BYDAY is not supported with a yearly frequency")
+		}
+	default:
+		return nil, fmt.Errorf("frequency %s is not supported", r.freq)
+	}
+
+	if r.freq != "MONTHLY" && slices.ContainsFunc(r.byDay, func(day icalWeekday) bool { return day.nth != 0 }) {
+		return nil, errors.New("numbered days in BYDAY are only supported with a monthly frequency")
+	}
+
+	return r, nil
+}
+
+func (r *icalRecurrenceRule) matchesDay(date time.Time) bool {
+	if len(r.byDay) == 0 {
+		return true
+	}
+
+	daysInMonth := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	return slices.ContainsFunc(r.byDay, func(day icalWeekday) bool {
+		switch {
+		case day.weekday != date.Weekday():
+			return false
+		case day.nth > 0:
+			return (date.Day()-1)/7+1 == day.nth
+		case day.nth < 0:
+			return (daysInMonth-date.Day())/7+1 == -day.nth
+		}
+
+		return true
+	})
+}
+
+// The candidate dates within the nth period of the rule, in order, along with
+// the start of that period, with the time of day taken from the start of the event
+func (r *icalRecurrenceRule) period(start time.Time, n int) (time.Time, []time.Time) {
+	on := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+
+	var dates []time.Time
+
+	switch r.freq {
+	case "DAILY":
+		date := start.AddDate(0, 0, n*r.interval)
+		if r.matchesDay(date) {
+			dates = append(dates, date)
+		}
+
+		return date, dates
+	case "WEEKLY":
+		if len(r.byDay) == 0 {
+			date := start.AddDate(0, 0, 7*n*r.interval)
+			return date, append(dates, date)
+		}
+
+		weekStart := start.AddDate(0, 0, -((int(start.Weekday())-int(r.weekStart)+7)%7)+7*n*r.interval)
+		for i := range 7 {
+			if date := weekStart.AddDate(0, 0, i); r.matchesDay(date) {
+				dates = append(dates, date)
+			}
+		}
+
+		return weekStart, dates
+	case "MONTHLY":
+		monthStart := on(start.Year(), start.Month()+time.Month(n*r.interval), 1)
+
+		if len(r.byDay) == 0 {
+			// months without the day of the start are skipped rather than rolling over
+			if date := monthStart.AddDate(0, 0, start.Day()-1); date.Month() == monthStart.Month() {
+				dates = append(dates, date)
+			}
+
+			return monthStart, dates
+		}
+
+		for date := monthStart; date.Month() == monthStart.Month(); date = date.AddDate(0, 0, 1) {
+			if r.matchesDay(date) {
+				dates = append(dates, date)
+			}
+		}
+
+		return monthStart, dates
+	}
+
+	yearStart := on(start.Year()+n*r.interval, 1, 1)
+	if date := on(yearStart.Year(), start.Month(), start.Day()); date.Month() == start.Month() {
+		dates = append(dates, date)
+	}
+
+	return yearStart, dates
+}
+
+// Excluded dates apply to the whole day
+func (e *icalRecurringEvent) occurrences(from, until time.Time) ([]time.Time, error) {
+	var occurrences []time.Time
+
+	excluded := func(t time.Time) bool {
+		return slices.ContainsFunc(e.exclude, func(date time.Time) bool {
+			return date.Year() == t.Year() && date.YearDay() == t.YearDay()
+		})
+	}
+
+	add := func(t time.Time) {
+		if !t.Before(from) && !t.After(until) && !excluded(t) {
+			occurrences = append(occurrences, t)
+		}
+	}
+
+	if e.rule == "" {
+		add(e.start)
+		return occurrences, nil
+	}
+
+	rule, err := parseICalendarRecurrenceRule(e.rule)
+	if err != nil {
+		return nil, err
+	}
+
+	// the count includes occurrences that were excluded or are before from
+	var counted int
+
+	for n := 0; ; n++ {
+		periodStart, dates := rule.period(e.start, n)
+		if periodStart.After(until) || (!rule.last.IsZero() && periodStart.After(rule.last)) {
+			return occurrences, nil
+		}
+
+		for _, date := range dates {
+			if date.Before(e.start) {
+				continue
+			}
+
+			if date.After(until) || (!rule.last.IsZero() && date.After(rule.last)) {
+				return occurrences, nil
+			}
+
+			add(date)
+
+			if counted++; rule.count > 0 && counted >= rule.count {
+				return occurrences, nil
+			}
+		}
+	}
+}
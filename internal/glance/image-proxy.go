@@ -0,0 +1,515 @@
+package glance
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	imageProxyMaxSize = 20 * 1024 * 1024
+	// images with more pixels than this are passed along without being resized
+	// since decoding them would take too much memory
+	imageProxyMaxPixels     = 40_000_000
+	imageProxyMinWidth      = 16
+	defaultImageProxyWidth  = 800
+	defaultImageCacheSize   = 200 * 1000 * 1000
+	defaultImageCacheMaxAge = 7 * 24 * time.Hour
+	imageProxyJPEGQuality   = 80
+	imageCacheDirName       = "images"
+	imageProxyCacheControl  = "private, max-age=86400"
+	imageProxyContentPolicy = "default-src 'none'; style-src 'unsafe-inline'; sandbox"
+	// used in place of a width for images that are passed along as they are
+	imageProxyUnresizedWidth = 0
+)
+
+type imageProxyConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	MaxWidth      int           `yaml:"max-width"`
+	CacheSize     byteSizeField `yaml:"cache-size"`
+	CacheDuration durationField `yaml:"cache-duration"`
+}
+
+func validateImageProxyConfig(c *imageProxyConfig) error {
+	if c.MaxWidth < 0 {
+		return errors.New("image-proxy: max-width can't be negative")
+	}
+
+	if c.MaxWidth > 0 && c.MaxWidth < imageProxyMinWidth {
+		return fmt.Errorf("image-proxy: max-width must be at least %d", imageProxyMinWidth)
+	}
+
+	return nil
+}
+
+// Fetches remote images on behalf of the browser. Privacy mode sends every
+// image through it as is, when the image proxy itself is enabled images also
+// get scaled down and cached.
+type remoteImageProxy struct {
+	baseURL  string
+	key      []byte
+	resize   bool
+	maxWidth int
+	cache    *imageCache
+}
+
+func newRemoteImageProxy(config *config, previous *remoteImageProxy) *remoteImageProxy {
+	proxy := &remoteImageProxy{
+		baseURL:  strings.TrimRight(config.Server.BaseURL, "/"),
+		resize:   config.ImageProxy.Enabled,
+		maxWidth: ternary(config.ImageProxy.MaxWidth > 0, config.ImageProxy.MaxWidth, defaultImageProxyWidth),
+	}
+
+	// so that pages rendered before the reload can still load their images
+	if previous != nil {
+		proxy.key = previous.key
+	} else {
+		proxy.key = newPrivacyKey()
+	}
+
+	if !proxy.resize {
+		return proxy
+	}
+
+	maxSize := ternary(config.ImageProxy.CacheSize > 0, int64(config.ImageProxy.CacheSize), defaultImageCacheSize)
+	maxAge := ternary(config.ImageProxy.CacheDuration > 0, time.Duration(config.ImageProxy.CacheDuration), defaultImageCacheMaxAge)
+
+	if previous != nil && previous.cache != nil && previous.cache.dataPath == config.Server.DataPath {
+		proxy.cache = previous.cache
+		proxy.cache.setLimits(maxSize, maxAge)
+	} else {
+		proxy.cache = newImageCache(config.Server.DataPath, maxSize, maxAge)
+	}
+
+	return proxy
+}
+
+func (p *remoteImageProxy) sign(value string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func (p *remoteImageProxy) url(target string) string {
+	// only used to find third party resources when validating the config
+	if p == nil {
+		return ""
+	}
+
+	return p.baseURL + "/image-proxy?url=" + url.QueryEscape(target) + "&sig=" + p.sign(target)
+}
+
+// The images come from the content of widgets, which can point anywhere
+var imageProxyHTTPClient = &http.Client{
+	Timeout:   defaultClientTimeout,
+	Transport: contentHTTPTransport,
+}
+
+func fetchProxiedImage(r *http.Request, target string) ([]byte, string, error) {
+	request, err := http.NewRequestWithContext(r.Context(), "GET", target, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	setBrowserUserAgentHeader(request)
+
+	response, err := imageProxyHTTPClient.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+
+	contentType := response.Header.Get("Content-Type")
+	if response.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, "", fmt.Errorf("unexpected content type %s", contentType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, imageProxyMaxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(data) > imageProxyMaxSize {
+		return nil, "", errors.New("image is too large")
+	}
+
+	return data, contentType, nil
+}
+
+func (a *application) handleImageProxyRequest(w http.ResponseWriter, r *http.Request) {
+	proxy := a.imageProxy
+	target := r.URL.Query().Get("url")
+	if target == "" || !hmac.Equal([]byte(r.URL.Query().Get("sig")), []byte(proxy.sign(target))) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	width := imageProxyUnresizedWidth
+	if proxy.resize {
+		width = proxy.maxWidth
+
+		if value := r.URL.Query().Get("w"); value != "" {
+			requested, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "invalid width", http.StatusBadRequest)
+				return
+			}
+
+			width = min(max(requested, imageProxyMinWidth), proxy.maxWidth)
+		}
+	}
+
+	cacheKey := imageCacheKey(target, width)
+
+	data, contentType, cached := proxy.cache.get(cacheKey)
+	if !cached {
+		var err error
+
+		data, contentType, err = fetchProxiedImage(r, target)
+		if err != nil {
+			if !errors.Is(err, r.Context().Err()) {
+				slog.Error("Failed to fetch image through proxy", "url", target, "error", err)
+			}
+
+			http.Error(w, "failed to fetch image", http.StatusBadGateway)
+			return
+		}
+
+		if width != imageProxyUnresizedWidth {
+			data, contentType = resizeImage(data, contentType, width)
+		}
+
+		proxy.cache.put(cacheKey, contentType, data)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", imageProxyCacheControl)
+	// SVGs can contain scripts which would otherwise run as if they were part of Glance
+	w.Header().Set("Content-Security-Policy", imageProxyContentPolicy)
+	w.Write(data)
+}
+
+// Scales the image down to the given width, keeping its aspect ratio. Images
+// that are already small enough, animated ones and formats that can't be
+// decoded are returned as they are.
+func resizeImage(data []byte, contentType string, width int) ([]byte, string) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "image/jpeg" && mediaType != "image/png" {
+		return data, contentType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width <= width || config.Width*config.Height > imageProxyMaxPixels {
+		return data, contentType
+	}
+
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return data, contentType
+	}
+
+	resized := downscaleImage(source, width)
+
+	var output bytes.Buffer
+	if mediaType == "image/jpeg" {
+		err = jpeg.Encode(&output, resized, &jpeg.Options{Quality: imageProxyJPEGQuality})
+	} else {
+		err = png.Encode(&output, resized)
+	}
+
+	// re-encoding can make already well compressed images larger
+	if err != nil || output.Len() >= len(data) {
+		return data, contentType
+	}
+
+	return output.Bytes(), mediaType
+}
+
+// Averages the source pixels that fall within each of the resulting pixels,
+// which unlike picking the nearest pixel doesn't leave artifacts when scaling
+// down by a lot
+func downscaleImage(source image.Image, width int) *image.RGBA {
+	bounds := source.Bounds()
+	sourceWidth, sourceHeight := bounds.Dx(), bounds.Dy()
+	height := max(1, sourceHeight*width/sourceWidth)
+
+	src := image.NewRGBA(image.Rect(0, 0, sourceWidth, sourceHeight))
+	draw.Draw(src, src.Bounds(), source, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := range height {
+		y0 := y * sourceHeight / height
+		y1 := max(y0+1, (y+1)*sourceHeight/height)
+
+		for x := range width {
+			x0 := x * sourceWidth / width
+			x1 := max(x0+1, (x+1)*sourceWidth/width)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+
+				for sx := x0; sx < x1; sx++ {
+					pixel := row[sx*4 : sx*4+4]
+					r += int(pixel[0])
+					g += int(pixel[1])
+					b += int(pixel[2])
+					a += int(pixel[3])
+					n++
+				}
+			}
+
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+func imageCacheKey(target string, width int) string {
+	hash := sha256.Sum256([]byte(target + "\n" + strconv.Itoa(width)))
+	return hex.EncodeToString(hash[:])
+}
+
+type imageCacheEntry struct {
+	contentType string
+	// nil when the image is stored on disk
+	data     []byte
+	size     int64
+	storedAt time.Time
+	usedAt   time.Time
+}
+
+// Keeps the most recently used images up to a total size, on disk when there's
+// a data path and in memory otherwise. Files on disk are named after the key
+// with an extension that tells their content type.
+type imageCache struct {
+	mu       sync.Mutex
+	dataPath string
+	dirPath  string
+	maxSize  int64
+	maxAge   time.Duration
+	size     int64
+	entries  map[string]*imageCacheEntry
+}
+
+func newImageCache(dataPath string, maxSize int64, maxAge time.Duration) *imageCache {
+	cache := &imageCache{
+		dataPath: dataPath,
+		maxSize:  maxSize,
+		maxAge:   maxAge,
+		entries:  make(map[string]*imageCacheEntry),
+	}
+
+	if dataPath == "" {
+		return cache
+	}
+
+	cache.dirPath = filepath.Join(dataPath, imageCacheDirName)
+	if err := os.MkdirAll(cache.dirPath, 0o700); err != nil {
+		slog.Error("Failed to create image cache directory", "path", cache.dirPath, "error", err)
+		cache.dirPath = ""
+		return cache
+	}
+
+	files, err := os.ReadDir(cache.dirPath)
+	if err != nil {
+		slog.Error("Failed to read image cache directory", "path", cache.dirPath, "error", err)
+		return cache
+	}
+
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || file.IsDir() {
+			continue
+		}
+
+		extension := filepath.Ext(file.Name())
+		contentType := mime.TypeByExtension(extension)
+		if !strings.HasPrefix(contentType, "image/") {
+			continue
+		}
+
+		cache.entries[strings.TrimSuffix(file.Name(), extension)] = &imageCacheEntry{
+			contentType: contentType,
+			size:        info.Size(),
+			storedAt:    info.ModTime(),
+			usedAt:      info.ModTime(),
+		}
+		cache.size += info.Size()
+	}
+
+	cache.mu.Lock()
+	cache.evict()
+	cache.mu.Unlock()
+
+	return cache
+}
+
+func (c *imageCache) setLimits(maxSize int64, maxAge time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxSize = maxSize
+	c.maxAge = maxAge
+	c.evict()
+}
+
+// The extensions of the common formats, the ones from the system's MIME types
+// can be unusual such as .jfif for JPEGs
+var imageCacheExtensions = map[string]string{
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/avif":    ".avif",
+	"image/svg+xml": ".svg",
+}
+
+// Returns an empty string for content types without a known extension, which
+// only get kept in memory
+func (c *imageCache) filePath(key, contentType string) string {
+	if c.dirPath == "" {
+		return ""
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	extension, ok := imageCacheExtensions[mediaType]
+	if !ok {
+		extensions, _ := mime.ExtensionsByType(mediaType)
+		if len(extensions) == 0 {
+			return ""
+		}
+
+		extension = extensions[0]
+	}
+
+	return filepath.Join(c.dirPath, key+extension)
+}
+
+func (c *imageCache) get(key string) ([]byte, string, bool) {
+	if c == nil {
+		return nil, "", false
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && time.Since(entry.storedAt) > c.maxAge {
+		c.remove(key)
+		ok = false
+	}
+	if !ok {
+		c.mu.Unlock()
+		return nil, "", false
+	}
+
+	entry.usedAt = time.Now()
+	data, contentType := entry.data, entry.contentType
+	c.mu.Unlock()
+
+	if data != nil {
+		return data, contentType, true
+	}
+
+	data, err := os.ReadFile(c.filePath(key, contentType))
+	if err != nil {
+		c.mu.Lock()
+		c.remove(key)
+		c.mu.Unlock()
+		return nil, "", false
+	}
+
+	return data, contentType, true
+}
+
+func (c *imageCache) put(key, contentType string, data []byte) {
+	if c == nil {
+		return
+	}
+
+	entry := &imageCacheEntry{
+		contentType: contentType,
+		size:        int64(len(data)),
+		storedAt:    time.Now(),
+		usedAt:      time.Now(),
+	}
+
+	if filePath := c.filePath(key, contentType); filePath == "" || os.WriteFile(filePath, data, 0o600) != nil {
+		entry.data = data
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(key)
+	c.entries[key] = entry
+	c.size += entry.size
+	c.evict()
+}
+
+// Needs to be called with the lock held
+func (c *imageCache) remove(key string) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+
+	delete(c.entries, key)
+	c.size -= entry.size
+
+	if entry.data == nil {
+		os.Remove(c.filePath(key, entry.contentType))
+	}
+}
+
+// Removes the least recently used images until the cache fits within its size,
+// needs to be called with the lock held
+func (c *imageCache) evict() {
+	for c.size > c.maxSize && len(c.entries) > 0 {
+		var oldestKey string
+		var oldest *imageCacheEntry
+
+		for key, entry := range c.entries {
+			if oldest == nil || entry.usedAt.Before(oldest.usedAt) {
+				oldestKey, oldest = key, entry
+			}
+		}
+
+		c.remove(oldestKey)
+	}
+}
+
+// Used instead of the privacy middleware when only the image proxy is enabled,
+// images from third parties get sent through the proxy and nothing else changes
+func (a *application) imageProxyMiddleware(next http.Handler) http.Handler {
+	return rewriteHTMLResponses(next, func(r *http.Request, _ http.Header, content string) string {
+		return a.imageRewriter.rewriteImages(content, r.Host)
+	})
+}
@@ -0,0 +1,230 @@
+package glance
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A minimal IMAP client that only supports what's needed to read the latest
+// messages from a folder over an implicit TLS connection
+
+const imapTimeout = 30 * time.Second
+
+var imapLiteralPattern = regexp.MustCompile(`\{(\d+)\}$`)
+
+type imapClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+type imapResponse struct {
+	line     string
+	literals [][]byte
+}
+
+func dialIMAP(host string, port int, allowInsecure bool) (*imapClient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), imapTimeout)
+	defer cancel()
+
+	rawConn, err := dialOutbound(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	conn := tls.Client(rawConn, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: allowInsecure,
+	})
+	if err := conn.HandshakeContext(ctx); err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(imapTimeout))
+
+	client := &imapClient{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+
+	greeting, err := client.readResponse()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if !strings.HasPrefix(greeting.line, "* OK") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected greeting: %s", greeting.line)
+	}
+
+	return client, nil
+}
+
+func (c *imapClient) close() {
+	c.command("LOGOUT")
+	c.conn.Close()
+}
+
+// Reads a single response line along with any literals that are part of it
+func (c *imapClient) readResponse() (imapResponse, error) {
+	var response imapResponse
+
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return response, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		response.line += line
+
+		matches := imapLiteralPattern.FindStringSubmatch(line)
+		if matches == nil {
+			return response, nil
+		}
+
+		size, _ := strconv.Atoi(matches[1])
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.reader, literal); err != nil {
+			return response, err
+		}
+
+		response.literals = append(response.literals, literal)
+	}
+}
+
+// Sends a command and returns the untagged responses to it
+func (c *imapClient) command(command string) ([]imapResponse, error) {
+	c.tag++
+	tag := "g" + strconv.Itoa(c.tag)
+
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, command); err != nil {
+		return nil, err
+	}
+
+	var responses []imapResponse
+
+	for {
+		response, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+
+		status, found := strings.CutPrefix(response.line, tag+" ")
+		if !found {
+			responses = append(responses, response)
+			continue
+		}
+
+		if !strings.HasPrefix(status, "OK") {
+			return nil, errors.New(status)
+		}
+
+		return responses, nil
+	}
+}
+
+func imapQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
+
+func (c *imapClient) login(username, password string) error {
+	_, err := c.command("LOGIN " + imapQuote(username) + " " + imapQuote(password))
+	return err
+}
+
+// Selects the folder in read-only mode and returns the number of messages in it
+func (c *imapClient) examine(folder string) (int, error) {
+	responses, err := c.command("EXAMINE " + imapQuote(folder))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, response := range responses {
+		fields := strings.Fields(response.line)
+		if len(fields) == 3 && fields[0] == "*" && fields[2] == "EXISTS" {
+			return strconv.Atoi(fields[1])
+		}
+	}
+
+	return 0, errors.New("server did not report the number of messages")
+}
+
+type imapMessage struct {
+	uid  string
+	body []byte
+}
+
+var imapUIDPattern = regexp.MustCompile(`\bUID (\d+)`)
+
+// Fetches the full contents of the messages within the given range of sequence
+// numbers without marking them as read
+func (c *imapClient) fetch(from, to int) ([]imapMessage, error) {
+	responses, err := c.command(fmt.Sprintf("FETCH %d:%d (UID BODY.PEEK[])", from, to))
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]imapMessage, 0, len(responses))
+
+	for _, response := range responses {
+		if !strings.Contains(response.line, "FETCH") || len(response.literals) == 0 {
+			continue
+		}
+
+		message := imapMessage{body: bytes.TrimSpace(response.literals[0])}
+		if matches := imapUIDPattern.FindStringSubmatch(response.line); matches != nil {
+			message.uid = matches[1]
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// Fetches the latest messages from the given folder, newest first
+func fetchLatestIMAPMessages(host string, port int, allowInsecure bool, username, password, folder string, limit int) ([]imapMessage, error) {
+	client, err := dialIMAP(host, port, allowInsecure)
+	if err != nil {
+		return nil, fmt.Errorf("connecting: %v", err)
+	}
+	defer client.close()
+
+	if err := client.login(username, password); err != nil {
+		return nil, fmt.Errorf("logging in: %v", err)
+	}
+
+	count, err := client.examine(folder)
+	if err != nil {
+		return nil, fmt.Errorf("opening folder %s: %v", folder, err)
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+
+	messages, err := client.fetch(max(1, count-limit+1), count)
+	if err != nil {
+		return nil, fmt.Errorf("fetching messages: %v", err)
+	}
+
+	slices.Reverse(messages)
+
+	return messages, nil
+}
@@ -0,0 +1,153 @@
+package glance
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultKioskInterval = 30 * time.Second
+	// anything shorter would reload pages before their content had a chance to load
+	minKioskInterval = 5 * time.Second
+)
+
+type kioskConfig struct {
+	Interval durationField `yaml:"interval"`
+	// replaces the theme of the pages while in kiosk mode
+	Theme *themeConfig `yaml:"theme"`
+}
+
+func validateKioskConfig(c *config) error {
+	if c.Kiosk.Interval != 0 && time.Duration(c.Kiosk.Interval) < minKioskInterval {
+		return fmt.Errorf("kiosk: interval must be at least %s", minKioskInterval)
+	}
+
+	for p := range c.Pages {
+		if interval := time.Duration(c.Pages[p].KioskInterval); interval != 0 && interval < minKioskInterval {
+			return fmt.Errorf("page %s: kiosk-interval must be at least %s", c.Pages[p].Title, minKioskInterval)
+		}
+	}
+
+	return nil
+}
+
+// Set on the template data of pages shown in kiosk mode
+type pageKiosk struct {
+	// empty when there's no page to rotate to
+	NextURL  string
+	Interval time.Duration
+}
+
+// The pages that get rotated through, in the order of the config
+func (a *application) kioskPages(user *authUser) []*page {
+	pages := make([]*page, 0, len(a.Config.Pages))
+
+	for p := range a.Config.Pages {
+		page := &a.Config.Pages[p]
+		if !page.KioskSkip && a.canAccessPage(user, page) {
+			pages = append(pages, page)
+		}
+	}
+
+	return pages
+}
+
+// Returns the page that comes after the current one, which can be the current
+// one itself when it's the only page, so that it still gets reloaded. Pages
+// that are skipped can still be opened directly, in which case the rotation
+// continues from the next page that isn't.
+func (a *application) nextKioskPage(current *page, pages []*page) *page {
+	if len(pages) == 0 {
+		return nil
+	}
+
+	passed := false
+	for p := range a.Config.Pages {
+		page := &a.Config.Pages[p]
+
+		if page == current {
+			passed = true
+			continue
+		}
+
+		if !passed {
+			continue
+		}
+
+		for _, candidate := range pages {
+			if candidate == page {
+				return page
+			}
+		}
+	}
+
+	return pages[0]
+}
+
+func (a *application) handleKioskRequest(w http.ResponseWriter, r *http.Request) {
+	user := requestUser(r)
+	pages := a.kioskPages(user)
+
+	var page *page
+
+	if slug := r.PathValue("page"); slug != "" {
+		var exists bool
+		page, exists = a.slugToPage[slug]
+		if !exists || !a.canAccessPage(user, page) {
+			a.handleNotFound(w, r)
+			return
+		}
+	} else if len(pages) > 0 {
+		page = pages[0]
+	} else {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	kiosk := &pageKiosk{
+		Interval: time.Duration(ternary(page.KioskInterval > 0, page.KioskInterval, a.Config.Kiosk.Interval)),
+	}
+
+	if kiosk.Interval == 0 {
+		kiosk.Interval = defaultKioskInterval
+	}
+
+	if next := a.nextKioskPage(page, pages); next != nil {
+		kiosk.NextURL = a.Config.Server.BaseURL + "/kiosk/" + next.Slug
+	}
+
+	pageData := pageTemplateData{
+		Page:  page,
+		App:   a,
+		Kiosk: kiosk,
+		user:  user,
+	}
+
+	var responseBytes bytes.Buffer
+	if err := pageTemplate.Execute(&responseBytes, pageData); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Write(responseBytes.Bytes())
+}
+
+func (d pageTemplateData) Theme() *themeConfig {
+	if d.Kiosk != nil && d.App.Config.Kiosk.Theme != nil {
+		return d.App.Config.Kiosk.Theme
+	}
+
+	return &d.App.Config.Theme
+}
+
+func (d pageTemplateData) ThemeStyle() template.HTML {
+	if d.Kiosk != nil && d.App.Config.Kiosk.Theme != nil {
+		return d.App.parsedKioskThemeStyle
+	}
+
+	return d.App.ParsedThemeStyle
+}
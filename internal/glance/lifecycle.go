@@ -0,0 +1,200 @@
+package glance
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// How long requests and background tasks get to finish when the config is
+// reloaded or Glance is stopped, kept under the 10 seconds that Docker waits
+// before killing the container
+const drainTimeout = 8 * time.Second
+
+// Keeps serving on the same socket across config reloads by swapping out the
+// application that handles requests, so that nothing gets refused while the
+// new config is loaded. The previous application finishes the requests it
+// already started before it's stopped.
+type appServer struct {
+	mu        sync.RWMutex
+	app       *application
+	handler   http.Handler
+	server    *http.Server
+	address   string
+	inherited bool
+}
+
+func (s *appServer) serve(app *application) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, previousServer := s.app, s.server
+	address := app.address()
+
+	if s.server == nil || address != s.address && !s.inherited {
+		listener, inherited, err := listen(address, s.server == nil)
+		if err != nil {
+			return err
+		}
+
+		var absAssetsPath string
+		if app.Config.Server.AssetsPath != "" {
+			absAssetsPath, _ = filepath.Abs(app.Config.Server.AssetsPath)
+		}
+
+		slog.Info("Starting server",
+			"address", listener.Addr().String(),
+			"base_url", app.Config.Server.BaseURL,
+			"assets_path", absAssetsPath,
+			"systemd_socket", inherited,
+		)
+
+		server := &http.Server{Handler: s}
+		go func() {
+			if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+				slog.Error("Server stopped unexpectedly", "error", err)
+			}
+		}()
+
+		s.server, s.address, s.inherited = server, address, inherited
+	} else if address != s.address {
+		slog.Warn("Listening on the socket passed by systemd, the changed host and port are ignored")
+	}
+
+	s.app, s.handler = app, app.handler()
+	app.start()
+
+	if previous != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+			defer cancel()
+
+			previous.stop(ctx)
+
+			if previousServer != s.server {
+				shutdownServer(ctx, previousServer)
+			}
+		}()
+	}
+
+	return nil
+}
+
+func (s *appServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	app, handler := s.app, s.handler
+	// added while holding the lock so that a reload can't stop the
+	// application in between it being picked and the request starting
+	app.running.Add(1)
+	s.mu.RUnlock()
+
+	defer app.running.Done()
+	handler.ServeHTTP(w, r)
+}
+
+// Stops accepting connections and waits for the requests that were already
+// made, along with the background tasks, to finish
+func (s *appServer) shutdown() {
+	s.mu.Lock()
+	app, server := s.app, s.server
+	s.mu.Unlock()
+
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	// ended first since event streams would otherwise hold up the shutdown
+	app.stopTasks()
+	shutdownServer(ctx, server)
+	app.stop(ctx)
+}
+
+func shutdownServer(ctx context.Context, server *http.Server) {
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("Requests didn't finish in time, closing their connections", "error", err)
+		server.Close()
+	}
+}
+
+// Uses the socket passed by systemd when started through socket activation,
+// which stays open while Glance restarts so that connections made in the
+// meantime wait rather than getting refused
+func listen(address string, canInherit bool) (net.Listener, bool, error) {
+	if canInherit && os.Getenv("LISTEN_PID") == strconv.Itoa(os.Getpid()) {
+		count, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+
+		if count != 1 {
+			return nil, false, fmt.Errorf("expected a single socket from systemd, got %d", count)
+		}
+
+		// passed sockets start at file descriptor 3
+		file := os.NewFile(3, "systemd-socket")
+		defer file.Close()
+
+		listener, err := net.FileListener(file)
+		if err != nil {
+			return nil, false, fmt.Errorf("using socket from systemd: %w", err)
+		}
+
+		return listener, true, nil
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return listener, false, nil
+}
+
+func (a *application) start() {
+	a.Config.Server.StartedAt = time.Now()
+
+	tasks := []func(context.Context){a.runBackgroundTasks, a.runLiveUpdates, a.runSnapshots}
+	for _, listener := range a.listeners {
+		tasks = append(tasks, listener.listen)
+	}
+
+	for _, task := range tasks {
+		a.running.Add(1)
+		go func() {
+			defer a.running.Done()
+			task(a.stopping)
+		}()
+	}
+}
+
+// Ends the background tasks and event streams right away, then waits for the
+// requests that are still being handled before cutting short any widget
+// updates that haven't finished by the time ctx is done
+func (a *application) stop(ctx context.Context) {
+	a.stopTasks()
+
+	done := make(chan struct{})
+	go func() {
+		a.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("Stopping before all requests and background tasks finished", "error", ctx.Err())
+	}
+
+	a.cancelUpdates()
+}
@@ -0,0 +1,256 @@
+package glance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// how often pages with connected browsers are checked for widgets whose cache has expired
+	liveUpdatesCheckInterval     = 15 * time.Second
+	liveUpdatesKeepAliveInterval = 30 * time.Second
+	liveUpdatesSubscriberBuffer  = 16
+)
+
+type liveUpdateEvent struct {
+	ID   uint64 `json:"id"`
+	HTML string `json:"html"`
+}
+
+type liveUpdateSubscriber struct {
+	page   *page
+	user   *authUser
+	events chan liveUpdateEvent
+}
+
+// Keeps track of the browsers that are listening for updates on pages with
+// live updates enabled. While at least one of them is connected to a page its
+// widgets get updated in the background as their cache expires and the ones
+// whose content changed get pushed to everyone listening.
+type liveUpdates struct {
+	mu          sync.Mutex
+	subscribers map[*liveUpdateSubscriber]struct{}
+	// hash of the last content pushed for each widget, so that widgets which got
+	// updated but ended up looking exactly the same aren't sent again
+	rendered map[uint64][sha256.Size]byte
+}
+
+func newLiveUpdates() *liveUpdates {
+	return &liveUpdates{
+		subscribers: make(map[*liveUpdateSubscriber]struct{}),
+		rendered:    make(map[uint64][sha256.Size]byte),
+	}
+}
+
+func (l *liveUpdates) subscribe(page *page, user *authUser) *liveUpdateSubscriber {
+	subscriber := &liveUpdateSubscriber{
+		page:   page,
+		user:   user,
+		events: make(chan liveUpdateEvent, liveUpdatesSubscriberBuffer),
+	}
+
+	l.mu.Lock()
+	l.subscribers[subscriber] = struct{}{}
+	l.mu.Unlock()
+
+	return subscriber
+}
+
+func (l *liveUpdates) unsubscribe(subscriber *liveUpdateSubscriber) {
+	l.mu.Lock()
+	delete(l.subscribers, subscriber)
+	l.mu.Unlock()
+}
+
+func (l *liveUpdates) watchedPages() []*page {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	seen := make(map[*page]struct{})
+	pages := make([]*page, 0)
+
+	for subscriber := range l.subscribers {
+		if _, ok := seen[subscriber.page]; ok {
+			continue
+		}
+
+		seen[subscriber.page] = struct{}{}
+		pages = append(pages, subscriber.page)
+	}
+
+	return pages
+}
+
+func (l *liveUpdates) publish(page *page, event liveUpdateEvent) {
+	hash := sha256.Sum256([]byte(event.HTML))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if previous, ok := l.rendered[event.ID]; ok && previous == hash {
+		return
+	}
+
+	l.rendered[event.ID] = hash
+
+	for subscriber := range l.subscribers {
+		if subscriber.page != page {
+			continue
+		}
+
+		// a browser that can't keep up misses the update rather than holding up
+		// everyone else, it'll get the latest content on the next change or reload
+		select {
+		case subscriber.events <- event:
+		default:
+		}
+	}
+}
+
+func (a *application) runLiveUpdates(ctx context.Context) {
+	if a.liveUpdates == nil {
+		return
+	}
+
+	ticker := time.NewTicker(liveUpdatesCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, page := range a.liveUpdates.watchedPages() {
+				a.updateLivePage(page)
+			}
+		}
+	}
+}
+
+func (a *application) updateLivePage(current *page) {
+	var events []liveUpdateEvent
+	var affected map[*page][]widget
+
+	func() {
+		current.mu.Lock()
+		defer current.mu.Unlock()
+
+		updated := a.updateOutdatedWidgets(current)
+		affected = a.daySummariesAffectedBy(updated)
+
+		for _, widget := range append(updated, affected[current]...) {
+			events = append(events, liveUpdateEvent{
+				ID:   widget.GetID(),
+				HTML: string(widget.Render()),
+			})
+		}
+	}()
+
+	for _, event := range events {
+		a.liveUpdates.publish(current, event)
+	}
+
+	// summaries on other pages use the widgets that were updated as well, they
+	// only get rendered once this one is no longer locked
+	for _, other := range a.liveUpdates.watchedPages() {
+		if other != current && len(affected[other]) > 0 {
+			a.publishLiveWidgets(other, affected[other])
+		}
+	}
+}
+
+func (a *application) publishLiveWidgets(page *page, widgets []widget) {
+	events := make([]liveUpdateEvent, 0, len(widgets))
+
+	page.mu.Lock()
+	for _, widget := range widgets {
+		events = append(events, liveUpdateEvent{
+			ID:   widget.GetID(),
+			HTML: string(widget.Render()),
+		})
+	}
+	page.mu.Unlock()
+
+	for _, event := range events {
+		a.liveUpdates.publish(page, event)
+	}
+}
+
+func (a *application) handlePageEventsRequest(w http.ResponseWriter, r *http.Request) {
+	page, exists := a.slugToPage[r.PathValue("page")]
+	user := requestUser(r)
+
+	if !exists || !page.LiveUpdates || !a.canAccessPage(user, page) {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+
+	controller := http.NewResponseController(w)
+
+	// tells the browser how long to wait before reconnecting, such as after a config reload
+	if _, err := w.Write([]byte("retry: 5000\n\n")); err != nil {
+		return
+	}
+
+	if err := controller.Flush(); err != nil {
+		slog.Error("Streaming responses are not supported", "error", err)
+		return
+	}
+
+	subscriber := a.liveUpdates.subscribe(page, user)
+	defer a.liveUpdates.unsubscribe(subscriber)
+
+	keepAlive := time.NewTicker(liveUpdatesKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		var message string
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.stopping.Done():
+			// the browser reconnects to the application that replaced this one
+			return
+		case <-keepAlive.C:
+			message = ": keep-alive\n\n"
+		case event := <-subscriber.events:
+			if !a.canAccessWidget(user, event.ID) {
+				continue
+			}
+
+			if a.privacy != nil {
+				event.HTML, _ = a.privacy.rewrite(event.HTML, r.Host)
+			} else if a.imageRewriter != nil {
+				event.HTML = a.imageRewriter.rewriteImages(event.HTML, r.Host)
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				slog.Error("Failed to encode live update", "error", err)
+				continue
+			}
+
+			message = fmt.Sprintf("event: widget\ndata: %s\n\n", data)
+		}
+
+		if _, err := w.Write([]byte(message)); err != nil {
+			return
+		}
+
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
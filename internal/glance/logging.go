@@ -0,0 +1,198 @@
+package glance
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type loggingConfig struct {
+	// one of debug, info, warn or error
+	Level string `yaml:"level"`
+	// either text or json
+	Format string `yaml:"format"`
+}
+
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+func validateLoggingConfig(c *loggingConfig) error {
+	if c.Level != "" {
+		if _, ok := logLevels[strings.ToLower(c.Level)]; !ok {
+			return fmt.Errorf("logging: level must be one of debug, info, warn or error")
+		}
+	}
+
+	if c.Format != "" && c.Format != "text" && c.Format != "json" {
+		return fmt.Errorf("logging: format must be either text or json")
+	}
+
+	return nil
+}
+
+var (
+	logLevel  = new(slog.LevelVar)
+	logFormat string
+)
+
+// Called on startup and whenever the config gets reloaded. Everything logs
+// through slog.Default, including the log package, so nothing needs to hold
+// on to a logger for the change to take effect.
+func setLogging(c *loggingConfig) {
+	level, ok := logLevels[strings.ToLower(c.Level)]
+	if !ok {
+		level = slog.LevelInfo
+	}
+
+	logLevel.Set(level)
+
+	format := ternary(c.Format == "", "text", c.Format)
+	if format == logFormat {
+		return
+	}
+
+	logFormat = format
+	options := &slog.HandlerOptions{Level: logLevel}
+
+	if format == "json" {
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, options)))
+	} else {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, options)))
+	}
+}
+
+func debugLogsEnabled() bool {
+	return slog.Default().Enabled(context.Background(), slog.LevelDebug)
+}
+
+// Returns a logger that includes which widget the message is about
+func (w *widgetBase) logger() *slog.Logger {
+	return slog.Default().With(
+		"widget", w.GetType(),
+		"widget_id", w.ID,
+		"title", w.Title,
+		"page", w.pageTitle,
+	)
+}
+
+func setWidgetPage(widget widget, pageTitle string) {
+	if base, ok := widget.(interface{ getWidgetBase() *widgetBase }); ok {
+		base.getWidgetBase().pageTitle = pageTitle
+	}
+
+	if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+		for _, child := range container.getChildWidgets() {
+			setWidgetPage(child, pageTitle)
+		}
+	}
+}
+
+var secretQueryParameters = []string{"token", "auth_token", "access_token", "key", "api_key", "apikey", "secret", "password"}
+
+// Used when logging requests since some APIs only accept credentials as part
+// of the URL
+func redactedURL(u *url.URL) string {
+	query := u.Query()
+	redacted := false
+
+	for _, name := range secretQueryParameters {
+		for key := range query {
+			if strings.EqualFold(key, name) {
+				query.Set(key, "REDACTED")
+				redacted = true
+			}
+		}
+	}
+
+	if !redacted {
+		return u.Redacted()
+	}
+
+	clone := *u
+	clone.RawQuery = query.Encode()
+
+	return clone.Redacted()
+}
+
+var statusCodeErrorPattern = regexp.MustCompile(`status code (\d{3})`)
+
+// A rough category of what went wrong, to make it easier to filter logs and
+// tell apart problems with the network from problems with the upstream
+func errorClass(err error) string {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var xmlErr *xml.SyntaxError
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, errPartialContent):
+		return "partial_content"
+	case errors.Is(err, errNoContent):
+		return "no_content"
+	case errors.Is(err, errRateLimited):
+		return "rate_limited"
+	case errors.Is(err, errCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, errOutboundRequestBlocked):
+		return "blocked"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthorityErr), errors.As(err, &hostnameErr):
+		return "tls"
+	case errors.As(err, &opErr):
+		return "connection"
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.As(err, &xmlErr):
+		return "parse"
+	}
+
+	if match := statusCodeErrorPattern.FindStringSubmatch(err.Error()); match != nil {
+		return "http_" + match[1][:1] + "xx"
+	}
+
+	return "other"
+}
+
+// Logged after every update, failures at the error level and successes only
+// when debug logs are enabled
+func (w *widgetBase) logUpdate(duration time.Duration) {
+	switch {
+	case w.Error != nil:
+		w.logger().Error("Failed to update widget",
+			"duration", duration,
+			"error", w.Error,
+			"error_class", errorClass(w.Error),
+		)
+	case w.Notice != nil:
+		w.logger().Warn("Updated widget with missing content",
+			"duration", duration,
+			"error", w.Notice,
+			"error_class", errorClass(w.Notice),
+		)
+	case debugLogsEnabled():
+		w.logger().Debug("Updated widget", "duration", duration)
+	}
+}
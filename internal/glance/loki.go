@@ -0,0 +1,164 @@
+package glance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The parts of the Loki HTTP API needed to show the latest log lines and how
+// many lines there were over time
+
+type lokiConnection struct {
+	URL           string        `yaml:"url"`
+	Query         string        `yaml:"query"`
+	Username      string        `yaml:"username"`
+	Password      string        `yaml:"password"`
+	Tenant        string        `yaml:"tenant"`
+	Since         durationField `yaml:"since"`
+	AllowInsecure bool          `yaml:"allow-insecure"`
+}
+
+func (loki *lokiConnection) initialize() error {
+	if loki.URL == "" || loki.Query == "" {
+		return errors.New("url and query are required")
+	}
+
+	loki.URL = strings.TrimRight(loki.URL, "/")
+
+	return nil
+}
+
+func (loki *lokiConnection) queryRange(ctx context.Context, client requestDoer, params url.Values) (lokiQueryResponseJson, error) {
+	request, _ := http.NewRequestWithContext(ctx, "GET", loki.URL+"/loki/api/v1/query_range?"+params.Encode(), nil)
+	if loki.Username != "" {
+		request.SetBasicAuth(loki.Username, loki.Password)
+	}
+	if loki.Tenant != "" {
+		request.Header.Set("X-Scope-OrgID", loki.Tenant)
+	}
+
+	response, err := decodeJsonFromRequest[lokiQueryResponseJson](client, request)
+	if err != nil {
+		return response, err
+	}
+
+	if response.Status != "success" {
+		return response, fmt.Errorf("query failed with status %s", response.Status)
+	}
+
+	return response, nil
+}
+
+// Returns up to limit of the latest lines between start and end that match
+// the query, newest first
+func (loki *lokiConnection) queryLines(ctx context.Context, client requestDoer, query string, limit int, start, end time.Time) ([]logLine, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("direction", "backward")
+	params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+
+	response, err := loki.queryRange(ctx, client, params)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]logLine, 0, limit)
+
+	for _, stream := range response.Data.Result {
+		level := normalizeLogLevel(stream.Stream["level"])
+		if level == "" {
+			level = normalizeLogLevel(stream.Stream["detected_level"])
+		}
+
+		for _, value := range stream.Values {
+			var timestamp, text string
+			if len(value) < 2 || json.Unmarshal(value[0], &timestamp) != nil || json.Unmarshal(value[1], &text) != nil {
+				continue
+			}
+
+			nanoseconds, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				continue
+			}
+
+			lines = append(lines, logLine{
+				Time:  time.Unix(0, nanoseconds),
+				Text:  strings.TrimRight(text, "\r\n"),
+				Level: level,
+			})
+		}
+	}
+
+	// each stream is sorted on its own
+	slices.SortStableFunc(lines, func(a, b logLine) int {
+		return b.Time.Compare(a.Time)
+	})
+
+	if len(lines) > limit {
+		lines = lines[:limit]
+	}
+
+	return lines, nil
+}
+
+// Returns how many lines matched the query within each step between start
+// and end, where the first count is for the step that ends at start
+func (loki *lokiConnection) queryCounts(ctx context.Context, client requestDoer, query string, start, end time.Time, step time.Duration) ([]float64, error) {
+	seconds := strconv.FormatInt(int64(step.Seconds()), 10)
+
+	params := url.Values{}
+	params.Set("query", "sum(count_over_time("+query+" ["+seconds+"s]))")
+	params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+	params.Set("step", seconds)
+
+	response, err := loki.queryRange(ctx, client, params)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]float64, int(end.Sub(start)/step)+1)
+
+	// steps without any lines are left out of the response
+	for _, series := range response.Data.Result {
+		for _, value := range series.Values {
+			var at float64
+			var count string
+			if len(value) < 2 || json.Unmarshal(value[0], &at) != nil || json.Unmarshal(value[1], &count) != nil {
+				continue
+			}
+
+			i := int(math.Round((at - float64(start.Unix())) / step.Seconds()))
+			if i < 0 || i >= len(counts) {
+				continue
+			}
+
+			counts[i], _ = strconv.ParseFloat(count, 64)
+		}
+	}
+
+	return counts, nil
+}
+
+// Values are a timestamp and a line for log queries, and a timestamp and a
+// number for metric queries
+type lokiQueryResponseJson struct {
+	Status string `json:"status"`
+	Data   struct {
+		Result []struct {
+			Stream map[string]string   `json:"stream"`
+			Values [][]json.RawMessage `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
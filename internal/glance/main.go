@@ -1,11 +1,16 @@
 package glance
 
 import (
+	"bufio"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 )
 
 var buildVersion = "dev"
@@ -29,18 +34,26 @@ func Main() int {
 			return 1
 		}
 	case cliIntentConfigValidate:
-		contents, _, err := parseYAMLIncludes(options.configPath)
+		contents, _, sources, err := parseYAMLIncludes(options.configPath)
 		if err != nil {
 			fmt.Printf("Could not parse config file: %v\n", err)
 			return 1
 		}
 
-		if _, err := newConfigFromYAML(contents); err != nil {
+		if _, err := newConfigFromYAML(contents, sources); err != nil {
 			fmt.Printf("Config file is invalid: %v\n", err)
 			return 1
 		}
+
+		fmt.Println("Config file is valid")
 	case cliIntentConfigPrint:
-		contents, _, err := parseYAMLIncludes(options.configPath)
+		contents, _, _, err := parseYAMLIncludes(options.configPath)
+		if err != nil {
+			fmt.Printf("Could not parse config file: %v\n", err)
+			return 1
+		}
+
+		contents, err = parseConfigEnvVariables(contents)
 		if err != nil {
 			fmt.Printf("Could not parse config file: %v\n", err)
 			return 1
@@ -49,27 +62,59 @@ func Main() int {
 		fmt.Println(string(contents))
 	case cliIntentDiagnose:
 		runDiagnostic()
+	case cliIntentPasswordHash:
+		// only the first line is used so that it can be piped in with echo
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			fmt.Printf("Could not read password: %v\n", err)
+			return 1
+		}
+
+		password = strings.TrimRight(password, "\r\n")
+		if password == "" {
+			fmt.Println("No password given")
+			return 1
+		}
+
+		hash, err := hashPassword(password)
+		if err != nil {
+			fmt.Printf("Could not hash password: %v\n", err)
+			return 1
+		}
+
+		fmt.Println(hash)
 	}
 
 	return 0
 }
 
 func serveApp(configPath string) error {
+	// used until the config is loaded, applies the default level and format
+	setLogging(&loggingConfig{})
+
 	exitChannel := make(chan struct{})
+	exit := sync.OnceFunc(func() { close(exitChannel) })
 	hadValidConfigOnStartup := false
-	var stopServer func() error
+	server := &appServer{}
+	var currentApp *application
+
+	// reloads can be triggered by both the file watcher and SIGHUP
+	var reloadMu sync.Mutex
+
+	load := func(newContents []byte, sources configSourceMap, reason string) {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
 
-	onChange := func(newContents []byte) {
-		if stopServer != nil {
-			log.Println("Config file changed, reloading...")
+		if currentApp != nil {
+			slog.Info(reason)
 		}
 
-		config, err := newConfigFromYAML(newContents)
+		config, err := newConfigFromYAML(newContents, sources)
 		if err != nil {
-			log.Printf("Config has errors: %v", err)
+			slog.Error("Config has errors", "error", err)
 
 			if !hadValidConfigOnStartup {
-				close(exitChannel)
+				exit()
 			}
 
 			return
@@ -77,61 +122,89 @@ func serveApp(configPath string) error {
 			hadValidConfigOnStartup = true
 		}
 
-		app, err := newApplication(config)
+		app, err := newApplication(config, currentApp)
 		if err != nil {
-			log.Printf("Failed to create application: %v", err)
+			slog.Error("Failed to create application", "error", err)
 			return
 		}
 
-		if stopServer != nil {
-			if err := stopServer(); err != nil {
-				log.Printf("Error while trying to stop server: %v", err)
+		if err := server.serve(app); err != nil {
+			slog.Error("Failed to start server", "error", err)
+
+			if currentApp == nil {
+				exit()
 			}
+
+			return
 		}
 
-		go func() {
-			var startServer func() error
-			startServer, stopServer = app.server()
+		currentApp = app
+	}
 
-			if err := startServer(); err != nil {
-				log.Printf("Failed to start server: %v", err)
-			}
-		}()
+	onChange := func(newContents []byte, sources configSourceMap) {
+		load(newContents, sources, "Config file changed, reloading")
 	}
 
 	onErr := func(err error) {
-		log.Printf("Error watching config files: %v", err)
+		slog.Error("Error watching config files", "error", err)
 	}
 
-	configContents, configIncludes, err := parseYAMLIncludes(configPath)
+	configContents, configIncludes, configSources, err := parseYAMLIncludes(configPath)
 	if err != nil {
 		return fmt.Errorf("parsing config: %w", err)
 	}
 
-	stopWatching, err := configFilesWatcher(configPath, configContents, configIncludes, onChange, onErr)
+	stopWatching, err := configFilesWatcher(configPath, configContents, configIncludes, configSources, onChange, onErr)
 	if err == nil {
 		defer stopWatching()
 	} else {
-		log.Printf("Error starting file watcher, config file changes will require a manual restart. (%v)", err)
+		slog.Warn("Error starting file watcher, config file changes will require a manual restart or SIGHUP", "error", err)
 
-		config, err := newConfigFromYAML(configContents)
+		config, err := newConfigFromYAML(configContents, configSources)
 		if err != nil {
 			return fmt.Errorf("validating config file: %w", err)
 		}
 
-		app, err := newApplication(config)
+		app, err := newApplication(config, nil)
 		if err != nil {
 			return fmt.Errorf("creating application: %w", err)
 		}
 
-		startServer, _ := app.server()
-		if err := startServer(); err != nil {
+		if err := server.serve(app); err != nil {
 			return fmt.Errorf("starting server: %w", err)
 		}
+
+		hadValidConfigOnStartup = true
+		currentApp = app
 	}
 
-	<-exitChannel
-	return nil
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-exitChannel:
+			server.shutdown()
+			return nil
+		case received := <-signals:
+			if received == syscall.SIGHUP {
+				contents, _, sources, err := parseYAMLIncludes(configPath)
+				if err != nil {
+					slog.Error("Could not parse config file", "error", err)
+					continue
+				}
+
+				load(contents, sources, "Received SIGHUP, reloading config")
+				continue
+			}
+
+			slog.Info("Shutting down", "signal", received.String())
+			server.shutdown()
+
+			return nil
+		}
+	}
 }
 
 func serveUpdateNoticeIfConfigLocationNotMigrated(configPath string) bool {
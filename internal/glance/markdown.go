@@ -0,0 +1,161 @@
+package glance
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+var (
+	markdownHeadingPattern     = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	markdownUnorderedPattern   = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	markdownOrderedPattern     = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	markdownHorizontalPattern  = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	markdownLinkPattern        = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	markdownBoldPattern        = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	markdownItalicPattern      = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	markdownAllowedLinkPattern = regexp.MustCompile(`^(https?://|mailto:|/|#)`)
+)
+
+// Renders the commonly used subset of markdown, being headings, paragraphs,
+// lists, block quotes, horizontal rules, code blocks and the inline code,
+// bold, italic and link formatting. Everything else, including any HTML, is
+// displayed as plain text
+func renderMarkdown(source string) template.HTML {
+	var out strings.Builder
+	var paragraph []string
+	var listTag string
+	inCode := false
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + renderMarkdownInline(strings.Join(paragraph, " ")) + "</p>")
+			paragraph = nil
+		}
+	}
+
+	closeList := func() {
+		if listTag != "" {
+			out.WriteString("</" + listTag + ">")
+			listTag = ""
+		}
+	}
+
+	openList := func(tag string) {
+		if listTag != tag {
+			closeList()
+			out.WriteString("<" + tag + ">")
+			listTag = tag
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inCode {
+				out.WriteString("</pre>")
+			} else {
+				flushParagraph()
+				closeList()
+				out.WriteString("<pre>")
+			}
+
+			inCode = !inCode
+			continue
+		}
+
+		if inCode {
+			out.WriteString(html.EscapeString(line) + "\n")
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			flushParagraph()
+			closeList()
+			continue
+		}
+
+		if markdownHorizontalPattern.MatchString(line) {
+			flushParagraph()
+			closeList()
+			out.WriteString("<hr>")
+			continue
+		}
+
+		if matches := markdownHeadingPattern.FindStringSubmatch(line); matches != nil {
+			flushParagraph()
+			closeList()
+			level := string(rune('0' + len(matches[1])))
+			out.WriteString("<h" + level + ">" + renderMarkdownInline(matches[2]) + "</h" + level + ">")
+			continue
+		}
+
+		if matches := markdownUnorderedPattern.FindStringSubmatch(line); matches != nil {
+			flushParagraph()
+			openList("ul")
+			out.WriteString("<li>" + renderMarkdownInline(matches[1]) + "</li>")
+			continue
+		}
+
+		if matches := markdownOrderedPattern.FindStringSubmatch(line); matches != nil {
+			flushParagraph()
+			openList("ol")
+			out.WriteString("<li>" + renderMarkdownInline(matches[1]) + "</li>")
+			continue
+		}
+
+		if quoted, ok := strings.CutPrefix(strings.TrimSpace(line), ">"); ok {
+			flushParagraph()
+			closeList()
+			out.WriteString("<blockquote>" + renderMarkdownInline(strings.TrimSpace(quoted)) + "</blockquote>")
+			continue
+		}
+
+		closeList()
+		paragraph = append(paragraph, strings.TrimSpace(line))
+	}
+
+	flushParagraph()
+	closeList()
+
+	if inCode {
+		out.WriteString("</pre>")
+	}
+
+	return template.HTML(out.String())
+}
+
+func renderMarkdownInline(text string) string {
+	parts := strings.Split(text, "`")
+	var out strings.Builder
+
+	for i, part := range parts {
+		escaped := html.EscapeString(part)
+
+		// every other part is within backticks, except for the last one
+		// when the backticks aren't balanced
+		if i%2 == 1 && i < len(parts)-1 {
+			out.WriteString("<code>" + escaped + "</code>")
+			continue
+		}
+
+		if i%2 == 1 {
+			out.WriteString("`")
+		}
+
+		escaped = markdownLinkPattern.ReplaceAllStringFunc(escaped, func(match string) string {
+			matches := markdownLinkPattern.FindStringSubmatch(match)
+			if !markdownAllowedLinkPattern.MatchString(html.UnescapeString(matches[2])) {
+				return matches[1]
+			}
+
+			return `<a class="color-primary-if-not-visited" href="` + matches[2] + `" target="_blank" rel="noreferrer">` + matches[1] + "</a>"
+		})
+		escaped = markdownBoldPattern.ReplaceAllString(escaped, "<strong>$1</strong>")
+		escaped = markdownItalicPattern.ReplaceAllString(escaped, "<em>$1</em>")
+
+		out.WriteString(escaped)
+	}
+
+	return out.String()
+}
@@ -0,0 +1,362 @@
+package glance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var metricsDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+var processStartedAt = time.Now()
+
+type durationHistogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *durationHistogram) observe(duration time.Duration) {
+	seconds := duration.Seconds()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.counts == nil {
+		h.counts = make([]uint64, len(metricsDurationBuckets))
+	}
+
+	for i, bound := range metricsDurationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.count++
+	h.sum += seconds
+}
+
+// Kept on each widget so that they carry over when the widget gets reused
+// after a config reload
+type widgetMetrics struct {
+	mu           sync.Mutex
+	updates      uint64
+	failures     uint64
+	lastUpdate   time.Time
+	lastSuccess  time.Time
+	failingSince time.Time
+	durations    durationHistogram
+}
+
+func (w *widgetBase) recordUpdate(duration time.Duration) {
+	m := &w.metrics
+	m.durations.observe(duration)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.updates++
+	m.lastUpdate = time.Now()
+
+	if w.Error != nil {
+		m.failures++
+		if m.failingSince.IsZero() {
+			m.failingSince = time.Now()
+		}
+
+		return
+	}
+
+	m.lastSuccess = time.Now()
+	m.failingSince = time.Time{}
+}
+
+// Updates the widget while keeping track of how long it took and whether it
+// failed, see the /metrics endpoint and logUpdate, and makes the items of feed widgets
+// available to the topics widget
+func updateWidget(ctx context.Context, widget widget) {
+	base, hasBase := widget.(interface{ getWidgetBase() *widgetBase })
+	if hasBase {
+		base.getWidgetBase().setUpdateContext(ctx)
+		defer base.getWidgetBase().setUpdateContext(nil)
+	}
+
+	start := time.Now()
+	widget.update(ctx)
+
+	if hasBase {
+		duration := time.Since(start)
+		base.getWidgetBase().recordUpdate(duration)
+		base.getWidgetBase().logUpdate(duration)
+	}
+
+	publishFeedItems(widget)
+}
+
+type upstreamResponseKey struct {
+	host string
+	code string
+}
+
+// Metrics that aren't tied to a single widget, kept globally since the
+// clients that record them are shared across config reloads
+var globalMetrics = struct {
+	mu                sync.Mutex
+	upstreamResponses map[upstreamResponseKey]uint64
+	cacheResults      map[string]uint64
+	pageRenders       map[string]*durationHistogram
+}{
+	upstreamResponses: make(map[upstreamResponseKey]uint64),
+	cacheResults:      make(map[string]uint64),
+	pageRenders:       make(map[string]*durationHistogram),
+}
+
+// A zero status means the request failed without getting a response
+func recordUpstreamResponse(host string, status int) {
+	key := upstreamResponseKey{host: strings.ToLower(host), code: ternary(status == 0, "error", strconv.Itoa(status))}
+
+	globalMetrics.mu.Lock()
+	globalMetrics.upstreamResponses[key]++
+	globalMetrics.mu.Unlock()
+}
+
+// The result is one of hit, stale or miss
+func recordResponseCacheResult(result string) {
+	globalMetrics.mu.Lock()
+	globalMetrics.cacheResults[result]++
+	globalMetrics.mu.Unlock()
+}
+
+func recordPageRender(slug string, duration time.Duration) {
+	globalMetrics.mu.Lock()
+	histogram, ok := globalMetrics.pageRenders[slug]
+	if !ok {
+		histogram = &durationHistogram{}
+		globalMetrics.pageRenders[slug] = histogram
+	}
+	globalMetrics.mu.Unlock()
+
+	histogram.observe(duration)
+}
+
+// Writes metrics in the Prometheus text format
+type metricsWriter struct {
+	builder strings.Builder
+}
+
+func (w *metricsWriter) header(name, kind, help string) {
+	fmt.Fprintf(&w.builder, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var metricsLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Labels are given as name and value pairs
+func (w *metricsWriter) sample(name string, value float64, labels ...string) {
+	w.builder.WriteString(name)
+
+	if len(labels) > 0 {
+		w.builder.WriteByte('{')
+
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.builder.WriteByte(',')
+			}
+
+			w.builder.WriteString(labels[i] + `="` + metricsLabelEscaper.Replace(labels[i+1]) + `"`)
+		}
+
+		w.builder.WriteByte('}')
+	}
+
+	w.builder.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64) + "\n")
+}
+
+func (w *metricsWriter) histogram(name string, h *durationHistogram, labels ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range metricsDurationBuckets {
+		count := uint64(0)
+		if h.counts != nil {
+			count = h.counts[i]
+		}
+
+		w.sample(name+"_bucket", float64(count), append(labels, "le", strconv.FormatFloat(bound, 'g', -1, 64))...)
+	}
+
+	w.sample(name+"_bucket", float64(h.count), append(labels, "le", "+Inf")...)
+	w.sample(name+"_sum", h.sum, labels...)
+	w.sample(name+"_count", float64(h.count), labels...)
+}
+
+type metricsWidget struct {
+	labels []string
+	base   *widgetBase
+}
+
+// Widgets within groups and split columns are listed individually since
+// they're the ones being updated
+func collectMetricsWidgets(pageTitle string, widget widget, into []metricsWidget) []metricsWidget {
+	if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+		for _, child := range container.getChildWidgets() {
+			into = collectMetricsWidgets(pageTitle, child, into)
+		}
+
+		return into
+	}
+
+	base, ok := widget.(interface{ getWidgetBase() *widgetBase })
+	if !ok {
+		return into
+	}
+
+	return append(into, metricsWidget{
+		labels: []string{
+			"page", pageTitle,
+			"type", widget.GetType(),
+			"title", base.getWidgetBase().Title,
+			"id", strconv.FormatUint(widget.GetID(), 10),
+		},
+		base: base.getWidgetBase(),
+	})
+}
+
+func (w *widgetBase) getWidgetBase() *widgetBase {
+	return w
+}
+
+func (a *application) handleMetricsRequest(w http.ResponseWriter, r *http.Request) {
+	user := requestUser(r)
+	var widgets []metricsWidget
+	var pages []*page
+
+	for p := range a.Config.Pages {
+		page := &a.Config.Pages[p]
+		if !a.canAccessPage(user, page) {
+			continue
+		}
+
+		pages = append(pages, page)
+
+		page.mu.Lock()
+		for c := range page.Columns {
+			for _, widget := range page.Columns[c].Widgets {
+				if a.canAccessWidget(user, widget.GetID()) {
+					widgets = collectMetricsWidgets(page.Title, widget, widgets)
+				}
+			}
+		}
+		page.mu.Unlock()
+	}
+
+	out := &metricsWriter{}
+
+	out.header("glance_build_info", "gauge", "The version of Glance that is running.")
+	out.sample("glance_build_info", 1, "version", a.Version)
+
+	out.header("process_start_time_seconds", "gauge", "When Glance was started, in seconds since the epoch.")
+	out.sample("process_start_time_seconds", float64(processStartedAt.Unix()))
+
+	out.header("glance_widget_updates_total", "counter", "The number of times the widget has been updated.")
+	for _, widget := range widgets {
+		widget.base.metrics.mu.Lock()
+		out.sample("glance_widget_updates_total", float64(widget.base.metrics.updates), widget.labels...)
+		widget.base.metrics.mu.Unlock()
+	}
+
+	out.header("glance_widget_update_failures_total", "counter", "The number of updates that left the widget showing an error.")
+	for _, widget := range widgets {
+		widget.base.metrics.mu.Lock()
+		out.sample("glance_widget_update_failures_total", float64(widget.base.metrics.failures), widget.labels...)
+		widget.base.metrics.mu.Unlock()
+	}
+
+	out.header("glance_widget_up", "gauge", "Whether the last update of the widget succeeded, or 1 if it hasn't been updated yet.")
+	for _, widget := range widgets {
+		widget.base.metrics.mu.Lock()
+		out.sample("glance_widget_up", ternary(widget.base.metrics.failingSince.IsZero(), 1.0, 0.0), widget.labels...)
+		widget.base.metrics.mu.Unlock()
+	}
+
+	out.header("glance_widget_last_success_timestamp_seconds", "gauge", "When the widget was last updated successfully, 0 if it never was.")
+	for _, widget := range widgets {
+		widget.base.metrics.mu.Lock()
+		out.sample("glance_widget_last_success_timestamp_seconds", unixOrZero(widget.base.metrics.lastSuccess), widget.labels...)
+		widget.base.metrics.mu.Unlock()
+	}
+
+	out.header("glance_widget_failing_since_timestamp_seconds", "gauge", "When the widget started failing to update, 0 if it isn't failing.")
+	for _, widget := range widgets {
+		widget.base.metrics.mu.Lock()
+		out.sample("glance_widget_failing_since_timestamp_seconds", unixOrZero(widget.base.metrics.failingSince), widget.labels...)
+		widget.base.metrics.mu.Unlock()
+	}
+
+	out.header("glance_widget_update_duration_seconds", "histogram", "How long updating the widget took, including fetching its data.")
+	for _, widget := range widgets {
+		out.histogram("glance_widget_update_duration_seconds", &widget.base.metrics.durations, widget.labels...)
+	}
+
+	out.header("glance_widget_month_requests", "gauge", "The number of requests the widget has made this month.")
+	for _, widget := range widgets {
+		out.sample("glance_widget_month_requests", float64(widget.base.currentUsage().Requests), widget.labels...)
+	}
+
+	out.header("glance_widget_month_bytes", "gauge", "The number of bytes the widget has sent and received this month.")
+	for _, widget := range widgets {
+		out.sample("glance_widget_month_bytes", float64(widget.base.currentUsage().Bytes), widget.labels...)
+	}
+
+	globalMetrics.mu.Lock()
+
+	upstream := make([]upstreamResponseKey, 0, len(globalMetrics.upstreamResponses))
+	for key := range globalMetrics.upstreamResponses {
+		upstream = append(upstream, key)
+	}
+	slices.SortFunc(upstream, func(a, b upstreamResponseKey) int {
+		return strings.Compare(a.host+" "+a.code, b.host+" "+b.code)
+	})
+
+	out.header("glance_upstream_responses_total", "counter", "Responses received from upstreams by host and status code, error if no response was received.")
+	for _, key := range upstream {
+		out.sample("glance_upstream_responses_total", float64(globalMetrics.upstreamResponses[key]), "host", key.host, "code", key.code)
+	}
+
+	out.header("glance_response_cache_requests_total", "counter", "Requests that went through the response cache, by whether a fresh, stale or no cached response was used.")
+	for _, result := range []string{"hit", "stale", "miss"} {
+		out.sample("glance_response_cache_requests_total", float64(globalMetrics.cacheResults[result]), "result", result)
+	}
+
+	renders := make(map[string]*durationHistogram, len(pages))
+	for _, page := range pages {
+		if histogram, ok := globalMetrics.pageRenders[page.Slug]; ok {
+			renders[page.Slug] = histogram
+		}
+	}
+
+	globalMetrics.mu.Unlock()
+
+	out.header("glance_page_render_duration_seconds", "histogram", "How long rendering the content of the page took, not including updating its widgets.")
+	for _, page := range pages {
+		if histogram, ok := renders[page.Slug]; ok {
+			out.histogram("glance_page_render_duration_seconds", histogram, "page", page.Title)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(out.builder.String()))
+}
+
+func unixOrZero(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return float64(t.Unix())
+}
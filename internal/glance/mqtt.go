@@ -0,0 +1,383 @@
+package glance
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A minimal MQTT 3.1.1 client that only supports what's needed to read the
+// latest message of a few topics, which are the retained messages that the
+// broker sends right after subscribing, and to stay subscribed to topics to
+// receive messages as they're published
+
+const (
+	mqttTimeout = 10 * time.Second
+	// for connections that stay open to receive messages as they're published
+	mqttKeepAlive = time.Minute
+	// how long to wait for messages on topics that don't have a retained one
+	mqttWaitForMessages = 3 * time.Second
+	mqttMaxPacketSize   = 1024 * 1024
+)
+
+const (
+	mqttPacketConnect    = 1
+	mqttPacketConnack    = 2
+	mqttPacketPublish    = 3
+	mqttPacketSubscribe  = 8
+	mqttPacketSuback     = 9
+	mqttPacketPingreq    = 12
+	mqttPacketDisconnect = 14
+)
+
+type mqttConnection struct {
+	Broker        string `yaml:"broker"`
+	Username      string `yaml:"username"`
+	Password      string `yaml:"password"`
+	AllowInsecure bool   `yaml:"allow-insecure"`
+	address       string
+	tls           bool
+}
+
+func (c *mqttConnection) initialize() error {
+	if c.Broker == "" {
+		return errors.New("broker is required")
+	}
+
+	parsed, err := url.Parse(c.Broker)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("invalid broker %s, expected something like mqtt://192.168.1.10:1883", c.Broker)
+	}
+
+	switch parsed.Scheme {
+	case "mqtt", "tcp":
+		c.address = hostWithDefaultPort(parsed, "1883")
+	case "mqtts", "ssl", "tls":
+		c.address = hostWithDefaultPort(parsed, "8883")
+		c.tls = true
+	default:
+		return fmt.Errorf("unsupported broker scheme %s, use mqtt or mqtts", parsed.Scheme)
+	}
+
+	return nil
+}
+
+func hostWithDefaultPort(u *url.URL, port string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// Returns the payload of the latest message of each topic that had one,
+// topics can't contain wildcards
+func (c *mqttConnection) latestMessages(ctx context.Context, topics []string) (map[string][]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, mqttTimeout)
+	defer cancel()
+
+	conn, reader, err := c.dial(ctx, mqttTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// DISCONNECT, so that the broker doesn't treat it as the connection dropping
+	defer conn.Write([]byte{mqttPacketDisconnect << 4, 0})
+
+	if err := mqttSubscribe(conn, topics); err != nil {
+		return nil, err
+	}
+
+	messages := make(map[string][]byte, len(topics))
+	subscribed := false
+	conn.SetReadDeadline(time.Now().Add(mqttWaitForMessages))
+
+	for !subscribed || len(messages) < len(topics) {
+		packetType, flags, body, err := mqttReadPacket(reader)
+		if err != nil {
+			var netErr net.Error
+			if subscribed && errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+
+			return nil, err
+		}
+
+		switch packetType {
+		case mqttPacketSuback:
+			for i, code := range body[min(2, len(body)):] {
+				if code == 0x80 && i < len(topics) {
+					return nil, fmt.Errorf("broker refused the subscription to %s", topics[i])
+				}
+			}
+
+			subscribed = true
+		case mqttPacketPublish:
+			topic, payload, err := mqttParsePublish(flags, body)
+			if err != nil {
+				return nil, err
+			}
+
+			messages[topic] = payload
+		}
+	}
+
+	return messages, nil
+}
+
+// Stays subscribed to the topics until ctx is done or the connection drops,
+// calling onMessage with every message that gets published to them. Retained
+// messages are skipped since they were published before subscribing.
+func (c *mqttConnection) subscribe(ctx context.Context, topics []string, onMessage func(topic string, payload []byte)) error {
+	conn, reader, err := c.dial(ctx, mqttKeepAlive)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.Write([]byte{mqttPacketDisconnect << 4, 0})
+		conn.Close()
+	})
+	defer stop()
+
+	if err := mqttSubscribe(conn, topics); err != nil {
+		return err
+	}
+
+	pinged := false
+
+	for {
+		// the broker drops connections that are quiet for longer than the
+		// keep alive, so a ping is sent when nothing arrives for half of it
+		conn.SetReadDeadline(time.Now().Add(mqttKeepAlive / 2))
+
+		packetType, flags, body, err := mqttReadPacket(reader)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			var netErr net.Error
+			if !pinged && errors.As(err, &netErr) && netErr.Timeout() {
+				conn.SetWriteDeadline(time.Now().Add(mqttTimeout))
+				if _, err := conn.Write([]byte{mqttPacketPingreq << 4, 0}); err != nil {
+					return err
+				}
+
+				pinged = true
+				continue
+			}
+
+			return err
+		}
+
+		pinged = false
+
+		switch packetType {
+		case mqttPacketSuback:
+			for i, code := range body[min(2, len(body)):] {
+				if code == 0x80 && i < len(topics) {
+					return fmt.Errorf("broker refused the subscription to %s", topics[i])
+				}
+			}
+		case mqttPacketPublish:
+			if flags&0x01 != 0 {
+				continue
+			}
+
+			topic, payload, err := mqttParsePublish(flags, body)
+			if err != nil {
+				return err
+			}
+
+			onMessage(topic, payload)
+		}
+	}
+}
+
+func (c *mqttConnection) dial(ctx context.Context, keepAlive time.Duration) (net.Conn, *bufio.Reader, error) {
+	conn, err := dialOutbound(ctx, "tcp", c.address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.tls {
+		host, _, _ := net.SplitHostPort(c.address)
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: c.AllowInsecure,
+		})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn = tlsConn
+	}
+
+	conn.SetDeadline(time.Now().Add(mqttTimeout))
+	reader := bufio.NewReader(conn)
+
+	if err := c.connect(conn, reader, keepAlive); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, reader, nil
+}
+
+func (c *mqttConnection) connect(conn net.Conn, reader *bufio.Reader, keepAlive time.Duration) error {
+	id := make([]byte, 6)
+	rand.Read(id)
+
+	// clean session, so that nothing is kept around by the broker once disconnected
+	flags := byte(0x02)
+	payload := mqttString("glance-" + hex.EncodeToString(id))
+
+	if c.Username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(c.Username)...)
+
+		if c.Password != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(c.Password)...)
+		}
+	}
+
+	body := binary.BigEndian.AppendUint16(append(mqttString("MQTT"), 4, flags), uint16(keepAlive.Seconds()))
+	body = append(body, payload...)
+
+	if _, err := conn.Write(mqttPacket(mqttPacketConnect<<4, body)); err != nil {
+		return err
+	}
+
+	packetType, _, response, err := mqttReadPacket(reader)
+	if err != nil {
+		return err
+	}
+
+	if packetType != mqttPacketConnack || len(response) != 2 {
+		return fmt.Errorf("unexpected response to connecting, packet type %d", packetType)
+	}
+
+	switch response[1] {
+	case 0:
+		return nil
+	case 4, 5:
+		return errors.New("broker refused the username or password")
+	default:
+		return fmt.Errorf("broker refused the connection with code %d", response[1])
+	}
+}
+
+func mqttSubscribe(conn net.Conn, topics []string) error {
+	// the packet identifier, which is always the same since there's only one subscription
+	body := []byte{0, 1}
+	for _, topic := range topics {
+		body = append(body, mqttString(topic)...)
+		body = append(body, 0)
+	}
+
+	_, err := conn.Write(mqttPacket(mqttPacketSubscribe<<4|0x02, body))
+	return err
+}
+
+func mqttParsePublish(flags byte, body []byte) (string, []byte, error) {
+	if len(body) < 2 {
+		return "", nil, errors.New("malformed publish packet")
+	}
+
+	length := int(binary.BigEndian.Uint16(body))
+	if len(body) < 2+length {
+		return "", nil, errors.New("malformed publish packet")
+	}
+
+	topic := string(body[2 : 2+length])
+	payload := body[2+length:]
+
+	// messages with a QoS above 0 have a packet identifier before the payload
+	if flags&0x06 != 0 {
+		if len(payload) < 2 {
+			return "", nil, errors.New("malformed publish packet")
+		}
+		payload = payload[2:]
+	}
+
+	return topic, payload, nil
+}
+
+func mqttReadPacket(reader *bufio.Reader) (byte, byte, []byte, error) {
+	header, err := reader.ReadByte()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, 0, nil, errors.New("malformed packet length")
+		}
+
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	if length > mqttMaxPacketSize {
+		return 0, 0, nil, fmt.Errorf("packet of %d bytes is too large", length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return 0, 0, nil, err
+	}
+
+	return header >> 4, header & 0x0f, body, nil
+}
+
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+
+		packet = append(packet, b)
+		if length == 0 {
+			break
+		}
+	}
+
+	return append(packet, body...)
+}
+
+func mqttString(value string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(value))), value...)
+}
+
+func isMQTTTopicWildcard(topic string) bool {
+	return strings.ContainsAny(topic, "+#")
+}
@@ -0,0 +1,216 @@
+package glance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type notificationTarget struct {
+	Name  string `yaml:"name"`
+	Type  string `yaml:"type"`
+	URL   string `yaml:"url"`
+	Token string `yaml:"token"`
+}
+
+type notification struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	URL     string `json:"url,omitempty"`
+}
+
+var notificationTargetTypes = []string{"ntfy", "gotify", "discord", "slack", "webhook"}
+
+func isValidNotificationTargetType(targetType string) bool {
+	for _, t := range notificationTargetTypes {
+		if t == targetType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateNotificationTargets(targets []notificationTarget) error {
+	names := make(map[string]struct{}, len(targets))
+
+	for i := range targets {
+		target := &targets[i]
+
+		if target.Name == "" {
+			return fmt.Errorf("notification target %d has no name", i+1)
+		}
+
+		if _, exists := names[target.Name]; exists {
+			return fmt.Errorf("multiple notification targets with the name %s", target.Name)
+		}
+
+		names[target.Name] = struct{}{}
+
+		if !isValidNotificationTargetType(target.Type) {
+			return fmt.Errorf(
+				"notification target %s: type must be one of %s",
+				target.Name,
+				strings.Join(notificationTargetTypes, ", "),
+			)
+		}
+
+		if target.URL == "" {
+			return fmt.Errorf("notification target %s has no url", target.Name)
+		}
+	}
+
+	return nil
+}
+
+func (target *notificationTarget) newRequest(n notification) (*http.Request, error) {
+	var body any
+
+	switch target.Type {
+	case "ntfy":
+		request, err := http.NewRequest("POST", target.URL, strings.NewReader(n.Message))
+		if err != nil {
+			return nil, err
+		}
+
+		request.Header.Set("Title", n.Title)
+		if n.URL != "" {
+			request.Header.Set("Click", n.URL)
+		}
+
+		if target.Token != "" {
+			request.Header.Set("Authorization", "Bearer "+target.Token)
+		}
+
+		return request, nil
+	case "gotify":
+		payload := map[string]any{
+			"title":   n.Title,
+			"message": n.Message,
+		}
+
+		if n.URL != "" {
+			payload["extras"] = map[string]any{
+				"client::notification": map[string]any{
+					"click": map[string]string{"url": n.URL},
+				},
+			}
+		}
+
+		body = payload
+	case "discord":
+		body = map[string]string{"content": formatNotificationAsText(n, "**")}
+	case "slack":
+		body = map[string]string{"text": formatNotificationAsText(n, "*")}
+	default:
+		body = n
+	}
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	url := target.URL
+	if target.Type == "gotify" {
+		url = strings.TrimRight(url, "/") + "/message"
+	}
+
+	request, err := http.NewRequest("POST", url, bytes.NewReader(encoded))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	if target.Token != "" {
+		if target.Type == "gotify" {
+			request.Header.Set("X-Gotify-Key", target.Token)
+		} else if target.Type == "webhook" {
+			request.Header.Set("Authorization", "Bearer "+target.Token)
+		}
+	}
+
+	return request, nil
+}
+
+func formatNotificationAsText(n notification, boldMarker string) string {
+	text := boldMarker + n.Title + boldMarker
+
+	if n.Message != "" {
+		text += "\n" + n.Message
+	}
+
+	if n.URL != "" {
+		text += "\n" + n.URL
+	}
+
+	return text
+}
+
+func (target *notificationTarget) send(n notification) error {
+	request, err := target.newRequest(n)
+	if err != nil {
+		return err
+	}
+
+	response, err := defaultHTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+		return fmt.Errorf("unexpected status code %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+type notifier struct {
+	targets map[string]*notificationTarget
+}
+
+func newNotifier(targets []notificationTarget) *notifier {
+	n := &notifier{
+		targets: make(map[string]*notificationTarget, len(targets)),
+	}
+
+	for i := range targets {
+		n.targets[targets[i].Name] = &targets[i]
+	}
+
+	return n
+}
+
+func (n *notifier) hasTarget(name string) bool {
+	_, exists := n.targets[name]
+	return exists
+}
+
+// Sends the notification to all of the specified targets, returns true if it
+// was delivered to at least one of them
+func (n *notifier) notify(targetNames []string, notification notification) bool {
+	delivered := false
+
+	for _, name := range targetNames {
+		target, exists := n.targets[name]
+		if !exists {
+			continue
+		}
+
+		if err := target.send(notification); err != nil {
+			slog.Error("Failed to send notification", "target", name, "error", err)
+			continue
+		}
+
+		delivered = true
+	}
+
+	return delivered
+}
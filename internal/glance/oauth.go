@@ -0,0 +1,375 @@
+package glance
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Obtains access tokens using the OAuth 2.0 client credentials grant and
+// reuses them until shortly before they expire. Safe for concurrent use.
+type clientCredentialsToken struct {
+	client       requestDoer
+	tokenURL     string
+	clientID     string
+	clientSecret string
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+type clientCredentialsTokenResponseJson struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func newClientCredentialsToken(client requestDoer, tokenURL, clientID, clientSecret string) *clientCredentialsToken {
+	return &clientCredentialsToken{
+		client:       client,
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+	}
+}
+
+func (t *clientCredentialsToken) get() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.accessToken != "" && time.Now().Before(t.expiresAt) {
+		return t.accessToken, nil
+	}
+
+	body := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {t.clientID},
+		"client_secret": {t.clientSecret},
+	}
+
+	request, _ := http.NewRequest("POST", t.tokenURL, strings.NewReader(body.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := decodeJsonFromRequest[clientCredentialsTokenResponseJson](t.client, request)
+	if err != nil {
+		return "", fmt.Errorf("obtaining access token: %v", err)
+	}
+
+	if response.AccessToken == "" {
+		return "", fmt.Errorf("obtaining access token: response did not contain a token")
+	}
+
+	t.accessToken = response.AccessToken
+	// refresh a bit early so that a token doesn't expire mid-update
+	t.expiresAt = time.Now().Add(time.Duration(response.ExpiresIn)*time.Second - time.Minute)
+
+	return t.accessToken, nil
+}
+
+// Should be called when a request gets rejected with the current token, e.g.
+// because it was revoked, so that a new one gets obtained on the next call to get
+func (t *clientCredentialsToken) invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.accessToken = ""
+}
+
+type oauthTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Keeps track of an access token on behalf of a user, refreshing it when it
+// expires. Some providers rotate refresh tokens every time they're used, which
+// would make the one from the config useless after a restart, so the latest one
+// gets persisted to the data path when it's configured.
+type refreshableToken struct {
+	initialRefreshToken string
+	refresh             func(refreshToken string) (oauthTokens, error)
+
+	mu           sync.Mutex
+	loaded       bool
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time
+}
+
+type refreshableTokenFileJson struct {
+	InitialRefreshToken string `json:"initial_refresh_token"`
+	RefreshToken        string `json:"refresh_token"`
+	AccessToken         string `json:"access_token"`
+	ExpiresAt           int64  `json:"expires_at"`
+}
+
+func newRefreshableToken(refreshToken string, refresh func(string) (oauthTokens, error)) *refreshableToken {
+	return &refreshableToken{
+		initialRefreshToken: refreshToken,
+		refreshToken:        refreshToken,
+		refresh:             refresh,
+	}
+}
+
+// storePath can be empty, in which case the tokens are only kept in memory
+func (t *refreshableToken) get(storePath string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.loaded {
+		t.loaded = true
+		t.load(storePath)
+	}
+
+	if t.accessToken != "" && time.Now().Before(t.expiresAt) {
+		return t.accessToken, nil
+	}
+
+	if t.refreshToken == "" {
+		return "", errors.New("no refresh token available")
+	}
+
+	tokens, err := t.refresh(t.refreshToken)
+	if err != nil {
+		return "", fmt.Errorf("refreshing access token: %v", err)
+	}
+
+	if tokens.AccessToken == "" {
+		return "", errors.New("refreshing access token: response did not contain a token")
+	}
+
+	t.accessToken = tokens.AccessToken
+	t.expiresAt = time.Now().Add(time.Duration(tokens.ExpiresIn)*time.Second - time.Minute)
+
+	if tokens.RefreshToken != "" {
+		t.refreshToken = tokens.RefreshToken
+	}
+
+	t.save(storePath)
+
+	return t.accessToken, nil
+}
+
+func (t *refreshableToken) invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.accessToken = ""
+}
+
+// must be called with the lock held
+func (t *refreshableToken) load(path string) {
+	if path == "" {
+		return
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Failed to read stored tokens", "path", path, "error", err)
+		}
+
+		return
+	}
+
+	var stored refreshableTokenFileJson
+	if err := json.Unmarshal(contents, &stored); err != nil {
+		slog.Error("Failed to parse stored tokens", "path", path, "error", err)
+		return
+	}
+
+	// the refresh token in the config was changed since the tokens were stored
+	if stored.InitialRefreshToken != t.initialRefreshToken {
+		return
+	}
+
+	t.refreshToken = stored.RefreshToken
+	t.accessToken = stored.AccessToken
+	t.expiresAt = time.Unix(stored.ExpiresAt, 0)
+}
+
+// must be called with the lock held
+func (t *refreshableToken) save(path string) {
+	if path == "" {
+		return
+	}
+
+	contents, _ := json.Marshal(refreshableTokenFileJson{
+		InitialRefreshToken: t.initialRefreshToken,
+		RefreshToken:        t.refreshToken,
+		AccessToken:         t.accessToken,
+		ExpiresAt:           t.expiresAt.Unix(),
+	})
+
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, contents, 0o600); err != nil {
+		slog.Error("Failed to store tokens", "path", tempPath, "error", err)
+		return
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		slog.Error("Failed to store tokens", "path", path, "error", err)
+	}
+}
+
+// Signs a JSON Web Token using a PEM encoded PKCS #8 private key, with ES256 for
+// ECDSA keys and RS256 for RSA keys
+func signJWT(privateKeyPEM []byte, header map[string]any, claims map[string]any) (string, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return "", errors.New("private key is not in the PEM format")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("parsing private key: %v", err)
+	}
+
+	switch key.(type) {
+	case *ecdsa.PrivateKey:
+		header["alg"] = "ES256"
+	case *rsa.PrivateKey:
+		header["alg"] = "RS256"
+	default:
+		return "", errors.New("private key must be either an ECDSA or RSA key")
+	}
+
+	header["typ"] = "JWT"
+
+	encodedHeader, _ := json.Marshal(header)
+	encodedClaims, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." + base64.RawURLEncoding.EncodeToString(encodedClaims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	var signature []byte
+
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return "", err
+		}
+
+		// JWTs use the fixed size concatenation of r and s rather than ASN.1
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, size*2)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Obtains access tokens for a Google Cloud service account using the JWT bearer
+// grant and reuses them until shortly before they expire. Safe for concurrent use.
+type googleServiceAccountToken struct {
+	client     requestDoer
+	email      string
+	privateKey []byte
+	tokenURL   string
+	scope      string
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+type googleServiceAccountKeyJson struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// Takes the contents of the JSON key file that gets downloaded when creating a key for a service account
+func newGoogleServiceAccountToken(client requestDoer, keyFile []byte, scope string) (*googleServiceAccountToken, error) {
+	var key googleServiceAccountKeyJson
+	if err := json.Unmarshal(keyFile, &key); err != nil {
+		return nil, fmt.Errorf("parsing service account key: %v", err)
+	}
+
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, errors.New("service account key is missing the client email or private key")
+	}
+
+	return &googleServiceAccountToken{
+		client:     client,
+		email:      key.ClientEmail,
+		privateKey: []byte(key.PrivateKey),
+		tokenURL:   ternary(key.TokenURI != "", key.TokenURI, "https://oauth2.googleapis.com/token"),
+		scope:      scope,
+	}, nil
+}
+
+func (t *googleServiceAccountToken) get() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.accessToken != "" && time.Now().Before(t.expiresAt) {
+		return t.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion, err := signJWT(t.privateKey, map[string]any{}, map[string]any{
+		"iss":   t.email,
+		"scope": t.scope,
+		"aud":   t.tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("signing assertion: %v", err)
+	}
+
+	body := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+
+	request, _ := http.NewRequest("POST", t.tokenURL, strings.NewReader(body.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := decodeJsonFromRequest[clientCredentialsTokenResponseJson](t.client, request)
+	if err != nil {
+		return "", fmt.Errorf("obtaining access token: %v", err)
+	}
+
+	if response.AccessToken == "" {
+		return "", errors.New("obtaining access token: response did not contain a token")
+	}
+
+	t.accessToken = response.AccessToken
+	t.expiresAt = now.Add(time.Duration(response.ExpiresIn)*time.Second - time.Minute)
+
+	return t.accessToken, nil
+}
+
+func (t *googleServiceAccountToken) invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.accessToken = ""
+}
@@ -0,0 +1,251 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var errOutboundRequestBlocked = errors.New("blocked by the outbound request policy")
+
+type securityConfig struct {
+	// when not empty, widgets can only connect to these hosts, see outboundPolicy
+	AllowedOutboundHosts []string `yaml:"allowed-outbound-hosts"`
+	// URLs taken from fetched content rather than the config, such as articles
+	// opened in the reader view, can't reach private addresses unless this is set
+	AllowPrivateContentURLs bool `yaml:"allow-private-content-urls"`
+	// programs that exec and extension widgets are allowed to run, nothing
+	// can be run unless it's listed
+	AllowedCommands []string `yaml:"allowed-commands"`
+	outboundHosts   []string
+	outboundNets    []*net.IPNet
+}
+
+func validateSecurityConfig(c *securityConfig) error {
+	c.outboundHosts = nil
+	c.outboundNets = nil
+
+	for _, value := range c.AllowedOutboundHosts {
+		value = strings.ToLower(strings.TrimSpace(value))
+		invalid := fmt.Errorf("security: invalid outbound host %s, expected a host name, IP address or CIDR range without a scheme or port", value)
+
+		if strings.Contains(value, "/") {
+			_, network, err := net.ParseCIDR(value)
+			if err != nil {
+				return invalid
+			}
+
+			c.outboundNets = append(c.outboundNets, network)
+			continue
+		}
+
+		if ip := net.ParseIP(value); ip != nil {
+			bits := ternary(ip.To4() != nil, 32, 128)
+			c.outboundNets = append(c.outboundNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		name := strings.TrimPrefix(value, "*.")
+		if name == "" || strings.ContainsAny(name, ":*?/ ") {
+			return invalid
+		}
+
+		c.outboundHosts = append(c.outboundHosts, value)
+	}
+
+	return nil
+}
+
+// The policy gets checked when a connection is being made rather than when a
+// request is created, so that it also covers redirects and host names that
+// resolve to an address other than the one expected. Since most widgets use
+// clients that are shared across config reloads it's kept globally.
+type outboundPolicy struct {
+	hosts               []string
+	nets                []*net.IPNet
+	allowPrivateContent bool
+}
+
+var currentOutboundPolicy atomic.Pointer[outboundPolicy]
+
+func setOutboundPolicy(c *securityConfig) {
+	currentOutboundPolicy.Store(&outboundPolicy{
+		hosts:               c.outboundHosts,
+		nets:                c.outboundNets,
+		allowPrivateContent: c.AllowPrivateContentURLs,
+	})
+}
+
+func (p *outboundPolicy) isRestricted() bool {
+	return len(p.hosts) > 0 || len(p.nets) > 0
+}
+
+func (p *outboundPolicy) allowsHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, allowed := range p.hosts {
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *outboundPolicy) allowsIP(ip net.IP) bool {
+	for _, network := range p.nets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPrivateAddress(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+// Returns why a connection to the given host and the address it resolved to
+// isn't allowed, or an empty string if it is
+func (p *outboundPolicy) check(host string, ip net.IP, content bool) string {
+	if p == nil {
+		return ""
+	}
+
+	hostAllowed := p.allowsHost(host)
+	ipAllowed := p.allowsIP(ip)
+
+	if p.isRestricted() && !hostAllowed && !ipAllowed {
+		return "host is not in allowed-outbound-hosts"
+	}
+
+	// a private address that's explicitly allowed is fine, otherwise content
+	// from the internet could be used to make requests to the local network
+	if content && !p.allowPrivateContent && isPrivateAddress(ip) && !hostAllowed && !ipAllowed {
+		return "content URLs can't point to private addresses"
+	}
+
+	return ""
+}
+
+// Content URLs are ones taken from fetched content instead of the config, such
+// as links in feeds, these get the additional private address check
+func newOutboundDialer(timeout time.Duration, content bool) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+
+		dialer := &net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: 30 * time.Second,
+			Control: func(_, resolved string, _ syscall.RawConn) error {
+				ipValue, _, err := net.SplitHostPort(resolved)
+				if err != nil {
+					return err
+				}
+
+				ip := net.ParseIP(ipValue)
+				if ip == nil {
+					return fmt.Errorf("unexpected address %s", resolved)
+				}
+
+				if reason := currentOutboundPolicy.Load().check(host, ip, content); reason != "" {
+					slog.Warn("Blocked outbound request", "host", host, "address", ipValue, "reason", reason)
+					return fmt.Errorf("connecting to %s: %w", host, errOutboundRequestBlocked)
+				}
+
+				return nil
+			},
+		}
+
+		return dialer.DialContext(ctx, network, address)
+	}
+}
+
+var dialOutbound = newOutboundDialer(30*time.Second, false)
+
+// Requests made through a proxy, whether configured for the widget or taken
+// from the environment, only get the proxy's address checked when connecting,
+// so the host the request is for gets checked before it's sent as well. That
+// check has to resolve the host itself, which may give a different address than
+// what the proxy ends up connecting to.
+type outboundTransport struct {
+	*http.Transport
+	content bool
+}
+
+// The transport outbound requests should be made with, its Proxy and
+// TLSClientConfig can be changed before it gets used
+func newOutboundTransport(content bool) *outboundTransport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = newOutboundDialer(30*time.Second, content)
+
+	return &outboundTransport{Transport: transport, content: content}
+}
+
+func (t *outboundTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if t.Proxy != nil {
+		proxyURL, err := t.Proxy(request)
+		if err != nil {
+			return nil, err
+		}
+
+		if proxyURL != nil {
+			host := request.URL.Hostname()
+			if reason := currentOutboundPolicy.Load().checkProxied(request.Context(), host, t.content); reason != "" {
+				slog.Warn("Blocked outbound request", "host", host, "proxy", proxyURL.Host, "reason", reason)
+				return nil, fmt.Errorf("requesting %s: %w", host, errOutboundRequestBlocked)
+			}
+		}
+	}
+
+	return t.Transport.RoundTrip(request)
+}
+
+// Same as check, for a host that's connected to through a proxy
+func (p *outboundPolicy) checkProxied(ctx context.Context, host string, content bool) string {
+	if p == nil || !p.isRestricted() && (!content || p.allowPrivateContent) || p.allowsHost(host) {
+		return ""
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return p.check(host, ip, content)
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return "host could not be resolved to be checked"
+	}
+
+	for _, ip := range ips {
+		if reason := p.check(host, ip, content); reason != "" {
+			return reason
+		}
+	}
+
+	return ""
+}
+
+var contentHTTPTransport = newOutboundTransport(true)
@@ -0,0 +1,190 @@
+package glance
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	passwordHashPrefix = "pbkdf2-sha256$"
+	// what's recommended by OWASP for PBKDF2 with SHA-256
+	passwordHashIterations = 600_000
+	passwordHashSaltLength = 16
+	passwordHashLength     = 32
+
+	// failed logins are counted for this long, once either the username or the
+	// address reaches its limit further attempts get rejected until it's over
+	loginFailureWindow         = 15 * time.Minute
+	loginMaxFailuresPerUser    = 10
+	loginMaxFailuresPerAddress = 30
+)
+
+// A password hashed with PBKDF2-HMAC-SHA256, written as
+// pbkdf2-sha256${iterations}${salt}${hash} with the salt and hash in base64
+type passwordHash struct {
+	iterations int
+	salt       []byte
+	hash       []byte
+}
+
+func isHashedPassword(value string) bool {
+	return strings.HasPrefix(value, passwordHashPrefix)
+}
+
+func parsePasswordHash(value string) (*passwordHash, error) {
+	parts := strings.Split(strings.TrimPrefix(value, passwordHashPrefix), "$")
+	if len(parts) != 3 {
+		return nil, errors.New("expected pbkdf2-sha256$iterations$salt$hash")
+	}
+
+	iterations, err := strconv.Atoi(parts[0])
+	if err != nil || iterations < 1 {
+		return nil, fmt.Errorf("invalid iterations %s", parts[0])
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil || len(salt) == 0 {
+		return nil, errors.New("invalid salt")
+	}
+
+	hash, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil || len(hash) == 0 {
+		return nil, errors.New("invalid hash")
+	}
+
+	return &passwordHash{iterations: iterations, salt: salt, hash: hash}, nil
+}
+
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordHashSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	hash := pbkdf2SHA256([]byte(password), salt, passwordHashIterations, passwordHashLength)
+
+	return fmt.Sprintf(
+		"%s%d$%s$%s",
+		passwordHashPrefix,
+		passwordHashIterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	), nil
+}
+
+func (h *passwordHash) matches(password string) bool {
+	given := pbkdf2SHA256([]byte(password), h.salt, h.iterations, len(h.hash))
+	return subtle.ConstantTimeCompare(given, h.hash) == 1
+}
+
+// As described in RFC 8018, the standard library only has it starting with Go 1.24
+func pbkdf2SHA256(password, salt []byte, iterations, keyLength int) []byte {
+	prf := hmac.New(sha256.New, password)
+	blocks := (keyLength + prf.Size() - 1) / prf.Size()
+	key := make([]byte, 0, blocks*prf.Size())
+	u := make([]byte, 0, prf.Size())
+
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u = prf.Sum(u[:0])
+		t := append([]byte(nil), u...)
+
+		for range iterations - 1 {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:keyLength]
+}
+
+// Counts failed logins by username and by address, which slows down guessing
+// passwords regardless of how many attempts are made at the same time
+type loginLimiter struct {
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+}
+
+type loginFailures struct {
+	count int
+	since time.Time
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{failures: make(map[string]*loginFailures)}
+}
+
+func (l *loginLimiter) count(key string, now time.Time) int {
+	failures, ok := l.failures[key]
+	if !ok || now.Sub(failures.since) >= loginFailureWindow {
+		return 0
+	}
+
+	return failures.count
+}
+
+func (l *loginLimiter) allows(username, address string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	return l.count("user:"+username, now) < loginMaxFailuresPerUser &&
+		l.count("address:"+address, now) < loginMaxFailuresPerAddress
+}
+
+func (l *loginLimiter) fail(username, address string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	for _, key := range []string{"user:" + username, "address:" + address} {
+		failures, ok := l.failures[key]
+		if !ok || now.Sub(failures.since) >= loginFailureWindow {
+			failures = &loginFailures{since: now}
+			l.failures[key] = failures
+		}
+
+		failures.count++
+	}
+
+	// attempts with made up usernames would otherwise keep adding entries
+	if len(l.failures) > 10_000 {
+		for key, failures := range l.failures {
+			if now.Sub(failures.since) >= loginFailureWindow {
+				delete(l.failures, key)
+			}
+		}
+	}
+}
+
+// The address that the request came from, without its port
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
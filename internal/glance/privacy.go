@@ -0,0 +1,410 @@
+package glance
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	privacyElementPattern   = regexp.MustCompile(`(?is)<(script|iframe|audio|video|object)\b([^>]*)>(.*?)</(?:script|iframe|audio|video|object)\s*>`)
+	privacyTagPattern       = regexp.MustCompile(`(?is)<(img|source|link|embed)\b[^>]*>`)
+	privacyImagePattern     = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	privacyAttributePattern = regexp.MustCompile(`(?is)\s(src|srcset|href|rel|data)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	privacyURLPattern       = regexp.MustCompile(`(?i)(?:https?:)?//[^\s"'<>]+`)
+	privacyHeadEndPattern   = regexp.MustCompile(`(?i)</head\s*>`)
+)
+
+type privacyConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// hosts that the browser is still allowed to load things from directly
+	AllowedHosts []string `yaml:"allowed-hosts"`
+}
+
+// Decides what counts as a third party and rewrites rendered pages so that the
+// browser only ever talks to Glance, see privacyMiddleware
+type privacyPolicy struct {
+	firstPartyHosts []string
+	allowedHosts    []string
+	images          *remoteImageProxy
+}
+
+func newPrivacyPolicy(config *config) *privacyPolicy {
+	policy := &privacyPolicy{
+		allowedHosts: config.Privacy.AllowedHosts,
+	}
+
+	if parsed, err := url.Parse(config.Server.BaseURL); err == nil && parsed.Host != "" {
+		policy.firstPartyHosts = append(policy.firstPartyHosts, strings.ToLower(parsed.Host))
+	}
+
+	policy.firstPartyHosts = append(policy.firstPartyHosts, config.Privacy.AllowedHosts...)
+
+	return policy
+}
+
+// Returns false for relative URLs and those that don't result in a request,
+// such as data URLs. The host of the request itself is always a first party.
+func (p *privacyPolicy) isThirdPartyURL(raw, requestHost string) bool {
+	value := strings.TrimSpace(html.UnescapeString(raw))
+	if strings.HasPrefix(value, "//") {
+		value = "https:" + value
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return false
+	}
+
+	host := strings.ToLower(parsed.Host)
+	if host == strings.ToLower(requestHost) {
+		return false
+	}
+
+	return !slices.ContainsFunc(p.firstPartyHosts, func(firstParty string) bool {
+		firstParty = strings.ToLower(firstParty)
+		return host == firstParty || parsed.Hostname() == firstParty
+	})
+}
+
+func (p *privacyPolicy) imageProxyURL(raw string) string {
+	value := strings.TrimSpace(html.UnescapeString(raw))
+	if strings.HasPrefix(value, "//") {
+		value = "https:" + value
+	}
+
+	return p.images.url(value)
+}
+
+func privacyAttributes(tag string) map[string]string {
+	attributes := make(map[string]string)
+
+	for _, match := range privacyAttributePattern.FindAllStringSubmatch(tag, -1) {
+		attributes[strings.ToLower(match[1])] = match[2] + match[3]
+	}
+
+	return attributes
+}
+
+// Returns a description of the first thing within the given HTML that would
+// make the browser contact a third party and can't be proxied, or an empty
+// string if there's nothing like that
+func (p *privacyPolicy) findThirdPartyResource(content string) string {
+	_, stripped := p.rewrite(content, "")
+	if len(stripped) == 0 {
+		return ""
+	}
+
+	return stripped[0]
+}
+
+// Sends images through the image proxy and removes everything else that would
+// load from a third party, returning descriptions of what was removed
+func (p *privacyPolicy) rewrite(content, requestHost string) (string, []string) {
+	var stripped []string
+
+	content = privacyElementPattern.ReplaceAllStringFunc(content, func(element string) string {
+		match := privacyElementPattern.FindStringSubmatch(element)
+		tag := strings.ToLower(match[1])
+
+		if tag == "script" {
+			src, hasSrc := privacyAttributes("<script" + match[2] + ">")["src"]
+			if !hasSrc || !p.isThirdPartyURL(src, requestHost) {
+				return element
+			}
+
+			stripped = append(stripped, "script from "+html.UnescapeString(src))
+			return ""
+		}
+
+		for _, found := range privacyURLPattern.FindAllString(match[2]+match[3], -1) {
+			if p.isThirdPartyURL(found, requestHost) {
+				stripped = append(stripped, tag+" from "+html.UnescapeString(found))
+				return ""
+			}
+		}
+
+		return element
+	})
+
+	content = privacyTagPattern.ReplaceAllStringFunc(content, func(element string) string {
+		tag := strings.ToLower(privacyTagPattern.FindStringSubmatch(element)[1])
+		attributes := privacyAttributes(element)
+
+		switch tag {
+		case "img":
+			return p.rewriteImageTag(element, requestHost)
+		case "link":
+			href := attributes["href"]
+			if !p.isThirdPartyURL(href, requestHost) {
+				return element
+			}
+
+			// icons can be proxied like any other image, anything else such as
+			// stylesheets or fonts can't
+			if strings.Contains(strings.ToLower(attributes["rel"]), "icon") {
+				return strings.Replace(element, href, html.EscapeString(p.imageProxyURL(href)), 1)
+			}
+
+			stripped = append(stripped, "link to "+html.UnescapeString(href))
+			return ""
+		default:
+			for _, name := range []string{"src", "srcset"} {
+				for _, found := range privacyURLPattern.FindAllString(attributes[name], -1) {
+					if p.isThirdPartyURL(found, requestHost) {
+						stripped = append(stripped, tag+" from "+html.UnescapeString(found))
+						return ""
+					}
+				}
+			}
+
+			return element
+		}
+	})
+
+	return content, stripped
+}
+
+// Sends the image through the image proxy if it's from a third party
+func (p *privacyPolicy) rewriteImageTag(element, requestHost string) string {
+	return privacyAttributePattern.ReplaceAllStringFunc(element, func(attribute string) string {
+		match := privacyAttributePattern.FindStringSubmatch(attribute)
+		name, value := strings.ToLower(match[1]), match[2]+match[3]
+
+		switch name {
+		case "src":
+			if p.isThirdPartyURL(value, requestHost) {
+				return ` src="` + html.EscapeString(p.imageProxyURL(value)) + `"`
+			}
+		case "srcset":
+			return ` srcset="` + html.EscapeString(privacyURLPattern.ReplaceAllStringFunc(html.UnescapeString(value), func(found string) string {
+				if p.isThirdPartyURL(found, requestHost) {
+					return p.imageProxyURL(found)
+				}
+
+				return found
+			})) + `"`
+		}
+
+		return attribute
+	})
+}
+
+// Only rewrites images, leaving everything else from third parties in place
+func (p *privacyPolicy) rewriteImages(content, requestHost string) string {
+	return privacyImagePattern.ReplaceAllStringFunc(content, func(element string) string {
+		return p.rewriteImageTag(element, requestHost)
+	})
+}
+
+func (p *privacyPolicy) contentSecurityPolicy(scriptHashes []string) string {
+	allowed := ""
+	for _, host := range p.allowedHosts {
+		// matches any port, in line with isThirdPartyURL
+		allowed += " " + ternary(strings.Contains(host, ":"), host, host+":*")
+	}
+
+	scripts := "'self'"
+	for _, hash := range scriptHashes {
+		scripts += " 'sha256-" + hash + "'"
+	}
+
+	return "default-src 'self'" +
+		"; script-src " + scripts + allowed +
+		"; style-src 'self' 'unsafe-inline'" + allowed +
+		"; img-src 'self' data:" + allowed +
+		"; media-src 'self'" + allowed +
+		"; frame-src 'self'" + allowed +
+		"; connect-src 'self'" + allowed +
+		"; font-src 'self'" + allowed +
+		"; object-src 'none'" +
+		"; base-uri 'self'" +
+		"; form-action 'self'" +
+		"; frame-ancestors 'self'"
+}
+
+// Inline scripts can only come from the templates or the document head of the
+// config, the contents of widgets get inserted after the page has loaded, so
+// only the scripts within the head of a document are allowed to run
+func privacyInlineScriptHashes(content string) []string {
+	end := privacyHeadEndPattern.FindStringIndex(content)
+	if end == nil {
+		return nil
+	}
+
+	var hashes []string
+	for _, match := range privacyElementPattern.FindAllStringSubmatch(content[:end[0]], -1) {
+		if strings.ToLower(match[1]) != "script" {
+			continue
+		}
+
+		if _, hasSrc := privacyAttributes("<script" + match[2] + ">")["src"]; hasSrc {
+			continue
+		}
+
+		hash := sha256.Sum256([]byte(match[3]))
+		hashes = append(hashes, base64.StdEncoding.EncodeToString(hash[:]))
+	}
+
+	return hashes
+}
+
+func validatePrivacy(config *config) error {
+	if !config.Privacy.Enabled {
+		return nil
+	}
+
+	policy := newPrivacyPolicy(config)
+
+	if found := policy.findThirdPartyResource(string(config.Document.Head)); found != "" {
+		return fmt.Errorf("privacy: document head loads from a third party: %s", found)
+	}
+
+	if found := policy.findThirdPartyResource(string(config.Branding.CustomFooter)); found != "" {
+		return fmt.Errorf("privacy: custom footer loads from a third party: %s", found)
+	}
+
+	if policy.isThirdPartyURL(config.Theme.CustomCSSFile, "") {
+		return fmt.Errorf("privacy: custom-css-file is loaded from a third party: %s", config.Theme.CustomCSSFile)
+	}
+
+	return nil
+}
+
+// Widgets whose content is known upfront to make the browser contact a third
+// party, anything that only shows up once the widget has fetched its data gets
+// removed from the page instead
+func validateWidgetPrivacy(widget widget, policy *privacyPolicy) error {
+	found := ""
+
+	switch widget := widget.(type) {
+	case *iframeWidget:
+		if policy.isThirdPartyURL(widget.Source, "") {
+			found = "iframe from " + widget.Source
+		}
+	case *htmlWidget:
+		found = policy.findThirdPartyResource(string(widget.Source))
+	}
+
+	if found != "" {
+		return formatWidgetInitError(fmt.Errorf("loads from a third party, which privacy mode does not allow: %s (add the host to privacy.allowed-hosts to allow it)", found), widget)
+	}
+
+	if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+		for _, child := range container.getChildWidgets() {
+			if err := validateWidgetPrivacy(child, policy); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func newPrivacyKey() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	return key
+}
+
+// Buffers HTML responses so that they can be rewritten before being sent,
+// everything else passes straight through
+type privacyResponseWriter struct {
+	http.ResponseWriter
+	status    int
+	decided   bool
+	buffering bool
+	buffer    bytes.Buffer
+}
+
+func (w *privacyResponseWriter) WriteHeader(status int) {
+	w.status = status
+	if w.decided && !w.buffering {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *privacyResponseWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+
+		contentType := w.Header().Get("Content-Type")
+		if contentType == "" {
+			contentType = http.DetectContentType(b)
+			w.Header().Set("Content-Type", contentType)
+		}
+
+		w.buffering = strings.HasPrefix(contentType, "text/html")
+		if !w.buffering && w.status != 0 {
+			w.ResponseWriter.WriteHeader(w.status)
+		}
+	}
+
+	if w.buffering {
+		return w.buffer.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Lets http.ResponseController reach the underlying writer, which is needed to
+// flush streamed responses such as live updates
+func (w *privacyResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Passes HTML responses through the given function before they're sent, the
+// headers can still be changed from within it
+func rewriteHTMLResponses(next http.Handler, rewrite func(r *http.Request, header http.Header, content string) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer := &privacyResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(writer, r)
+
+		if !writer.decided {
+			if writer.status != 0 {
+				w.WriteHeader(writer.status)
+			}
+			return
+		}
+
+		if !writer.buffering {
+			return
+		}
+
+		content := rewrite(r, w.Header(), writer.buffer.String())
+		w.Header().Del("Content-Length")
+
+		if writer.status != 0 {
+			w.WriteHeader(writer.status)
+		}
+		io.WriteString(w, content)
+	})
+}
+
+func (a *application) privacyMiddleware(next http.Handler) http.Handler {
+	rewriting := rewriteHTMLResponses(next, func(r *http.Request, header http.Header, content string) string {
+		content, _ = a.privacy.rewrite(content, r.Host)
+		header.Set("Content-Security-Policy", a.privacy.contentSecurityPolicy(privacyInlineScriptHashes(content)))
+
+		return content
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("Referrer-Policy", "no-referrer")
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Content-Security-Policy", a.privacy.contentSecurityPolicy(nil))
+
+		rewriting.ServeHTTP(w, r)
+	})
+}
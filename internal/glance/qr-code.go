@@ -0,0 +1,527 @@
+package glance
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A QR code encoder that only supports the byte mode, which is all that's
+// needed for the content of the QR code widget. Follows the approach of
+// Project Nayuki's QR Code generator library.
+
+type qrErrorCorrection int
+
+const (
+	qrErrorCorrectionL qrErrorCorrection = iota
+	qrErrorCorrectionM
+	qrErrorCorrectionQ
+	qrErrorCorrectionH
+)
+
+var qrErrorCorrectionFormatBits = [4]int{1, 0, 3, 2}
+
+func parseQRErrorCorrection(value string) (qrErrorCorrection, error) {
+	switch strings.ToUpper(value) {
+	case "L":
+		return qrErrorCorrectionL, nil
+	case "", "M":
+		return qrErrorCorrectionM, nil
+	case "Q":
+		return qrErrorCorrectionQ, nil
+	case "H":
+		return qrErrorCorrectionH, nil
+	}
+
+	return 0, fmt.Errorf("unknown error correction level %q, must be one of L, M, Q or H", value)
+}
+
+// indexed by error correction level and then version, version 0 is unused
+var qrECCCodewordsPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var qrNumECCBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+type qrCode struct {
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+// Uses the smallest version that the data fits in
+func encodeQRCode(data []byte, ecl qrErrorCorrection) (*qrCode, error) {
+	version := 0
+	for v := 1; v <= 40; v++ {
+		countBits := ternary(v <= 9, 8, 16)
+		if len(data) < 1<<countBits && 4+countBits+len(data)*8 <= qrNumDataCodewords(v, ecl)*8 {
+			version = v
+			break
+		}
+	}
+
+	if version == 0 {
+		return nil, errors.New("content is too long to fit in a QR code")
+	}
+
+	var bits qrBitBuffer
+	bits.append(0b0100, 4)
+	bits.append(len(data), ternary(version <= 9, 8, 16))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	capacity := qrNumDataCodewords(version, ecl) * 8
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+
+	size := version*4 + 17
+	qr := &qrCode{
+		size:       size,
+		modules:    make([][]bool, size),
+		isFunction: make([][]bool, size),
+	}
+	for i := range size {
+		qr.modules[i] = make([]bool, size)
+		qr.isFunction[i] = make([]bool, size)
+	}
+
+	qr.drawFunctionPatterns(version, ecl)
+	qr.drawCodewords(qrAddECCAndInterleave(codewords, version, ecl))
+
+	bestMask, minPenalty := 0, -1
+	for mask := range 8 {
+		qr.applyMask(mask)
+		qr.drawFormatBits(ecl, mask)
+
+		if penalty := qr.penaltyScore(); minPenalty == -1 || penalty < minPenalty {
+			bestMask, minPenalty = mask, penalty
+		}
+
+		// applying the mask again undoes it
+		qr.applyMask(mask)
+	}
+
+	qr.applyMask(bestMask)
+	qr.drawFormatBits(ecl, bestMask)
+
+	return qr, nil
+}
+
+type qrBitBuffer []bool
+
+func (b *qrBitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, (value>>i)&1 != 0)
+	}
+}
+
+func qrNumRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+
+		if version >= 7 {
+			result -= 36
+		}
+	}
+
+	return result
+}
+
+func qrNumDataCodewords(version int, ecl qrErrorCorrection) int {
+	return qrNumRawDataModules(version)/8 - qrECCCodewordsPerBlock[ecl][version]*qrNumECCBlocks[ecl][version]
+}
+
+// Splits the data into blocks, appends the error correction codewords to each
+// of them and then interleaves the blocks
+func qrAddECCAndInterleave(data []byte, version int, ecl qrErrorCorrection) []byte {
+	numBlocks := qrNumECCBlocks[ecl][version]
+	blockECCLen := qrECCCodewordsPerBlock[ecl][version]
+	rawCodewords := qrNumRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := qrReedSolomonDivisor(blockECCLen)
+	blocks := make([][]byte, 0, numBlocks)
+
+	for i, k := 0, 0; i < numBlocks; i++ {
+		length := shortBlockLen - blockECCLen + ternary(i < numShortBlocks, 0, 1)
+		block := append([]byte{}, data[k:k+length]...)
+		k += length
+
+		ecc := qrReedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			block = append(block, 0)
+		}
+
+		blocks = append(blocks, append(block, ecc...))
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			// skips the padding of short blocks
+			if i != shortBlockLen-blockECCLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+
+	return result
+}
+
+func qrReedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+
+	for range degree {
+		for j := range result {
+			result[j] = qrGFMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+
+		root = qrGFMultiply(root, 0x02)
+	}
+
+	return result
+}
+
+func qrReedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+
+		for i, coefficient := range divisor {
+			result[i] ^= qrGFMultiply(coefficient, factor)
+		}
+	}
+
+	return result
+}
+
+// Multiplies within GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func qrGFMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+
+	return byte(z)
+}
+
+func (qr *qrCode) setFunctionModule(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.isFunction[y][x] = true
+}
+
+func (qr *qrCode) drawFunctionPatterns(version int, ecl qrErrorCorrection) {
+	for i := range qr.size {
+		qr.setFunctionModule(6, i, i%2 == 0)
+		qr.setFunctionModule(i, 6, i%2 == 0)
+	}
+
+	qr.drawFinderPattern(3, 3)
+	qr.drawFinderPattern(qr.size-4, 3)
+	qr.drawFinderPattern(3, qr.size-4)
+
+	positions := qrAlignmentPatternPositions(version, qr.size)
+	last := len(positions) - 1
+	for i := range positions {
+		for j := range positions {
+			// the corners that have finder patterns
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+
+			qr.drawAlignmentPattern(positions[i], positions[j])
+		}
+	}
+
+	// reserves the area, the actual mask gets drawn later
+	qr.drawFormatBits(ecl, 0)
+	qr.drawVersion(version)
+}
+
+func (qr *qrCode) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			distance := max(absInt(dx), absInt(dy))
+			xx, yy := x+dx, y+dy
+
+			if xx >= 0 && xx < qr.size && yy >= 0 && yy < qr.size {
+				qr.setFunctionModule(xx, yy, distance != 2 && distance != 4)
+			}
+		}
+	}
+}
+
+func (qr *qrCode) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			qr.setFunctionModule(x+dx, y+dy, max(absInt(dx), absInt(dy)) != 1)
+		}
+	}
+}
+
+func qrAlignmentPatternPositions(version, size int) []int {
+	if version == 1 {
+		return nil
+	}
+
+	numAlign := version/7 + 2
+	step := (version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+
+	positions := make([]int, numAlign)
+	positions[0] = 6
+	for i, position := numAlign-1, size-7; i >= 1; i, position = i-1, position-step {
+		positions[i] = position
+	}
+
+	return positions
+}
+
+func (qr *qrCode) drawFormatBits(ecl qrErrorCorrection, mask int) {
+	data := qrErrorCorrectionFormatBits[ecl]<<3 | mask
+	remainder := data
+	for range 10 {
+		remainder = (remainder << 1) ^ ((remainder >> 9) * 0x537)
+	}
+	bits := (data<<10 | remainder) ^ 0x5412
+
+	bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		qr.setFunctionModule(8, i, bit(i))
+	}
+	qr.setFunctionModule(8, 7, bit(6))
+	qr.setFunctionModule(8, 8, bit(7))
+	qr.setFunctionModule(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.setFunctionModule(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		qr.setFunctionModule(qr.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunctionModule(8, qr.size-15+i, bit(i))
+	}
+	qr.setFunctionModule(8, qr.size-8, true)
+}
+
+func (qr *qrCode) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+
+	remainder := version
+	for range 12 {
+		remainder = (remainder << 1) ^ ((remainder >> 11) * 0x1F25)
+	}
+	bits := version<<12 | remainder
+
+	for i := range 18 {
+		dark := (bits>>i)&1 != 0
+		a, b := qr.size-11+i%3, i/3
+		qr.setFunctionModule(a, b, dark)
+		qr.setFunctionModule(b, a, dark)
+	}
+}
+
+// Places the codewords in the zigzag pattern that goes up and down two
+// columns at a time, starting from the bottom right corner
+func (qr *qrCode) drawCodewords(data []byte) {
+	i := 0
+
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+
+		for vertical := range qr.size {
+			for j := range 2 {
+				x := right - j
+				y := ternary((right+1)&2 == 0, qr.size-1-vertical, vertical)
+
+				if !qr.isFunction[y][x] && i < len(data)*8 {
+					qr.modules[y][x] = (data[i>>3]>>(7-i&7))&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (qr *qrCode) applyMask(mask int) {
+	for y := range qr.size {
+		for x := range qr.size {
+			var invert bool
+
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+
+			if invert && !qr.isFunction[y][x] {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+func (qr *qrCode) penaltyScore() int {
+	const (
+		penaltyN1 = 3
+		penaltyN2 = 3
+		penaltyN3 = 40
+		penaltyN4 = 10
+	)
+
+	result := 0
+
+	for _, vertical := range []bool{false, true} {
+		for a := range qr.size {
+			runColor, run := false, 0
+			var history [7]int
+
+			for b := range qr.size {
+				dark := ternary(vertical, qr.modules[b][a], qr.modules[a][b])
+
+				if dark == runColor {
+					run++
+					if run == 5 {
+						result += penaltyN1
+					} else if run > 5 {
+						result++
+					}
+				} else {
+					qr.finderPenaltyAddHistory(run, &history)
+					if !runColor {
+						result += qrFinderPenaltyCountPatterns(&history) * penaltyN3
+					}
+					runColor, run = dark, 1
+				}
+			}
+
+			result += qr.finderPenaltyTerminateAndCount(runColor, run, &history) * penaltyN3
+		}
+	}
+
+	for y := range qr.size - 1 {
+		for x := range qr.size - 1 {
+			color := qr.modules[y][x]
+			if color == qr.modules[y][x+1] && color == qr.modules[y+1][x] && color == qr.modules[y+1][x+1] {
+				result += penaltyN2
+			}
+		}
+	}
+
+	dark := 0
+	for y := range qr.size {
+		for x := range qr.size {
+			if qr.modules[y][x] {
+				dark++
+			}
+		}
+	}
+
+	total := qr.size * qr.size
+	k := (absInt(dark*20-total*10)+total-1)/total - 1
+	result += k * penaltyN4
+
+	return result
+}
+
+func (qr *qrCode) finderPenaltyAddHistory(run int, history *[7]int) {
+	// adds the light border to the initial run
+	if history[0] == 0 {
+		run += qr.size
+	}
+
+	copy(history[1:], history[:6])
+	history[0] = run
+}
+
+func qrFinderPenaltyCountPatterns(history *[7]int) int {
+	n := history[1]
+	core := n > 0 && history[2] == n && history[3] == n*3 && history[4] == n && history[5] == n
+
+	return ternary(core && history[0] >= n*4 && history[6] >= n, 1, 0) +
+		ternary(core && history[6] >= n*4 && history[0] >= n, 1, 0)
+}
+
+func (qr *qrCode) finderPenaltyTerminateAndCount(runColor bool, run int, history *[7]int) int {
+	if runColor {
+		qr.finderPenaltyAddHistory(run, history)
+		run = 0
+	}
+
+	run += qr.size
+	qr.finderPenaltyAddHistory(run, history)
+
+	return qrFinderPenaltyCountPatterns(history)
+}
+
+// Draws the dark modules as a single path, with the quiet zone around the code
+// that scanners need
+func (qr *qrCode) svg() string {
+	const border = 4
+	var path strings.Builder
+
+	for y := range qr.size {
+		for x := range qr.size {
+			if qr.modules[y][x] {
+				path.WriteString("M" + strconv.Itoa(x+border) + "," + strconv.Itoa(y+border) + "h1v1h-1z")
+			}
+		}
+	}
+
+	viewBox := strconv.Itoa(qr.size + border*2)
+
+	return `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 ` + viewBox + ` ` + viewBox + `" shape-rendering="crispEdges">` +
+		`<rect width="100%" height="100%" fill="#fff"/>` +
+		`<path d="` + path.String() + `" fill="#000"/></svg>`
+}
@@ -0,0 +1,299 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// requests that would have to wait longer than this for their turn fail instead
+const rateLimitMaxWait = 30 * time.Second
+
+var errRateLimited = errors.New("outbound rate limit reached")
+
+type rateLimitsConfig struct {
+	// applies to all requests made by widgets combined
+	Global *rateField `yaml:"global"`
+	// applies to each host separately unless it has its own limit in hosts
+	PerHost *rateField           `yaml:"per-host"`
+	Hosts   map[string]rateField `yaml:"hosts"`
+}
+
+func validateRateLimitsConfig(c *rateLimitsConfig) error {
+	for host := range c.Hosts {
+		name := strings.TrimPrefix(strings.ToLower(host), "*.")
+		if name == "" || strings.ContainsAny(name, ":*?/ ") {
+			return fmt.Errorf("rate-limits: invalid host %s, expected a host name without a scheme or port", host)
+		}
+	}
+
+	return nil
+}
+
+// A token bucket that holds up to as many requests as the rate allows within
+// its period, so a limit of 60/m allows a burst of 60 requests and then one
+// more every second
+type rateLimiter struct {
+	mu        sync.Mutex
+	capacity  float64
+	perSecond float64
+	tokens    float64
+	updated   time.Time
+}
+
+func newRateLimiter(rate rateField) *rateLimiter {
+	return &rateLimiter{
+		capacity:  float64(rate.Count),
+		perSecond: float64(rate.Count) / rate.Period.Seconds(),
+		tokens:    float64(rate.Count),
+		updated:   time.Now(),
+	}
+}
+
+// Takes a token and returns how long to wait before it can be used. When that
+// would be longer than maxWait nothing is taken and false is returned.
+func (l *rateLimiter) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = min(l.capacity, l.tokens+now.Sub(l.updated).Seconds()*l.perSecond)
+	l.updated = now
+
+	// the balance goes negative while requests are queued up
+	wait := time.Duration(max(0, 1-l.tokens) / l.perSecond * float64(time.Second))
+	if wait > maxWait {
+		return 0, false
+	}
+
+	l.tokens--
+
+	return wait, true
+}
+
+// Gives back a token taken by a request that didn't end up being made
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	l.tokens = min(l.capacity, l.tokens+1)
+	l.mu.Unlock()
+}
+
+// Like the outbound policy the limits are kept globally since most widgets use
+// clients that are shared across config reloads, reloading starts them over
+type outboundRateLimits struct {
+	global  *rateLimiter
+	perHost *rateField
+	// limiters for the configured hosts, a wildcard one is shared by all of
+	// the hosts matching it
+	exact     map[string]*rateLimiter
+	wildcards []hostRateLimiter
+
+	mu      sync.Mutex
+	created map[string]*rateLimiter
+}
+
+type hostRateLimiter struct {
+	suffix  string
+	limiter *rateLimiter
+}
+
+var currentRateLimits atomic.Pointer[outboundRateLimits]
+
+func setRateLimits(c *rateLimitsConfig) {
+	if c.Global == nil && c.PerHost == nil && len(c.Hosts) == 0 {
+		currentRateLimits.Store(nil)
+		return
+	}
+
+	limits := &outboundRateLimits{
+		perHost: c.PerHost,
+		exact:   make(map[string]*rateLimiter),
+		created: make(map[string]*rateLimiter),
+	}
+
+	if c.Global != nil {
+		limits.global = newRateLimiter(*c.Global)
+	}
+
+	for host, rate := range c.Hosts {
+		host = strings.ToLower(host)
+
+		if suffix, ok := strings.CutPrefix(host, "*"); ok {
+			limits.wildcards = append(limits.wildcards, hostRateLimiter{suffix: suffix, limiter: newRateLimiter(rate)})
+		} else {
+			limits.exact[host] = newRateLimiter(rate)
+		}
+	}
+
+	// the most specific wildcard wins
+	slices.SortFunc(limits.wildcards, func(a, b hostRateLimiter) int {
+		return len(b.suffix) - len(a.suffix)
+	})
+
+	currentRateLimits.Store(limits)
+}
+
+func (l *outboundRateLimits) limiterForHost(host string) *rateLimiter {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if limiter, ok := l.exact[host]; ok {
+		return limiter
+	}
+
+	for _, wildcard := range l.wildcards {
+		if strings.HasSuffix(host, wildcard.suffix) {
+			return wildcard.limiter
+		}
+	}
+
+	if l.perHost == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.created[host]
+	if !ok {
+		limiter = newRateLimiter(*l.perHost)
+		l.created[host] = limiter
+	}
+
+	return limiter
+}
+
+// Blocks until a request to the host is allowed to be made, see resilientHTTPClient
+func (l *outboundRateLimits) wait(ctx context.Context, host string) error {
+	if l == nil {
+		return nil
+	}
+
+	limiters := make([]*rateLimiter, 0, 2)
+	if l.global != nil {
+		limiters = append(limiters, l.global)
+	}
+	if limiter := l.limiterForHost(host); limiter != nil {
+		limiters = append(limiters, limiter)
+	}
+
+	now := time.Now()
+	var delay time.Duration
+
+	for i, limiter := range limiters {
+		wait, ok := limiter.reserve(now, rateLimitMaxWait)
+		if !ok {
+			for _, reserved := range limiters[:i] {
+				reserved.cancel()
+			}
+
+			return fmt.Errorf("requesting %s: %w", host, errRateLimited)
+		}
+
+		delay = max(delay, wait)
+	}
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		for _, limiter := range limiters {
+			limiter.cancel()
+		}
+
+		return ctx.Err()
+	}
+}
+
+type coalescedRequest struct {
+	done  chan struct{}
+	entry *responseCacheEntry
+	err   error
+	// the response couldn't be shared, so everyone makes their own request
+	notShared bool
+}
+
+var (
+	coalescedRequestsMu sync.Mutex
+	coalescedRequests   = make(map[string]*coalescedRequest)
+)
+
+// Every request made by a widget goes through this. Identical GET requests
+// that are in progress at the same time, such as several feeds on one page
+// updating together, are only made once with everyone getting a copy of the
+// response. Streams, media and large responses aren't shared, see
+// isBufferableResponse.
+type coalescingHTTPClient struct {
+	client requestDoer
+	// what the client wraps, used to tell which requests are identical
+	shared *http.Client
+}
+
+func (c *coalescingHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet || request.Body != nil {
+		return c.client.Do(request)
+	}
+
+	key := requestKey(c.shared, request)
+
+	coalescedRequestsMu.Lock()
+	if call, ok := coalescedRequests[key]; ok {
+		coalescedRequestsMu.Unlock()
+
+		select {
+		case <-call.done:
+			// the request that was being waited on got cancelled by whoever
+			// made it, which says nothing about this one
+			if call.notShared || isContextError(call.err) && request.Context().Err() == nil {
+				return c.client.Do(request)
+			}
+
+			if call.err != nil {
+				return nil, call.err
+			}
+
+			return call.entry.toResponse(request), nil
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		}
+	}
+
+	call := &coalescedRequest{done: make(chan struct{})}
+	coalescedRequests[key] = call
+	coalescedRequestsMu.Unlock()
+
+	var response *http.Response
+	call.entry, response, call.err = doCacheableRequest(c.client, request)
+	call.notShared = response != nil
+
+	coalescedRequestsMu.Lock()
+	delete(coalescedRequests, key)
+	coalescedRequestsMu.Unlock()
+
+	close(call.done)
+
+	if response != nil {
+		return response, nil
+	}
+
+	if call.err != nil {
+		return nil, call.err
+	}
+
+	return call.entry.toResponse(request), nil
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
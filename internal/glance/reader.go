@@ -0,0 +1,149 @@
+package glance
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glanceapp/glance/pkg/readability"
+)
+
+var readerTemplate = mustParseTemplate("reader.html", "document.html")
+
+// implemented by widgets that can have their items opened in the reader view,
+// used to make sure that glance can't be used to fetch arbitrary URLs
+type readerViewSource interface {
+	hasReaderViewItem(articleURL string) bool
+}
+
+// implemented by widgets whose items don't have to be fetched from a URL because
+// the widget already has their content, such as newsletters received by email
+type readerViewArticleSource interface {
+	readerViewArticle(key string) (article *readability.Article, articleURL string, ok bool)
+}
+
+type readerTemplateData struct {
+	App        *application
+	Article    *readability.Article
+	ArticleURL string
+	Domain     string
+	Error      error
+}
+
+func (a *application) readerURL(widgetID uint64, articleURL string) string {
+	return a.Config.Server.BaseURL + "/reader/" + strconv.FormatUint(widgetID, 10) + "?url=" + url.QueryEscape(articleURL)
+}
+
+func (a *application) readerItemURL(widgetID uint64, key string) string {
+	return a.Config.Server.BaseURL + "/reader/" + strconv.FormatUint(widgetID, 10) + "?item=" + url.QueryEscape(key)
+}
+
+func (a *application) handleReaderRequest(w http.ResponseWriter, r *http.Request) {
+	widgetID, err := strconv.ParseUint(r.PathValue("widget"), 10, 64)
+	if err != nil {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	widget, exists := a.widgetByID[widgetID]
+	if !exists || !a.canAccessWidget(requestUser(r), widgetID) {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	data := readerTemplateData{App: a}
+
+	if key := r.URL.Query().Get("item"); key != "" {
+		source, ok := widget.(readerViewArticleSource)
+		if !ok {
+			a.handleNotFound(w, r)
+			return
+		}
+
+		data.Article, data.ArticleURL, ok = source.readerViewArticle(key)
+		if !ok {
+			a.handleNotFound(w, r)
+			return
+		}
+
+		if data.Article == nil {
+			data.Error = readability.ErrNoContent
+		}
+	} else {
+		source, ok := widget.(readerViewSource)
+		articleURL := r.URL.Query().Get("url")
+
+		if !ok || articleURL == "" || !source.hasReaderViewItem(articleURL) {
+			a.handleNotFound(w, r)
+			return
+		}
+
+		data.ArticleURL = articleURL
+		data.Article, data.Error = fetchReaderViewArticle(articleURL)
+		if data.Error != nil {
+			slog.Error("Failed to extract article", "url", articleURL, "error", data.Error)
+		}
+	}
+
+	if data.ArticleURL != "" {
+		data.Domain = extractDomainFromUrl(data.ArticleURL)
+	}
+
+	var responseBytes bytes.Buffer
+	if err := readerTemplate.Execute(&responseBytes, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Write(responseBytes.Bytes())
+}
+
+func (d readerTemplateData) Content() template.HTML {
+	if d.Article == nil {
+		return ""
+	}
+
+	// the content only ever contains the allowlisted elements and attributes
+	// that the readability package outputs, so it is safe to render as is
+	return template.HTML(d.Article.Content)
+}
+
+const readerViewMaxBodySize = 5 * 1024 * 1024
+
+var readerViewHTTPClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: contentHTTPTransport,
+}
+
+func fetchReaderViewArticle(articleURL string) (*readability.Article, error) {
+	request, err := http.NewRequest("GET", articleURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	setBrowserUserAgentHeader(request)
+
+	response, err := readerViewHTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	if contentType := response.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, fmt.Errorf("unsupported content type %s", contentType)
+	}
+
+	return readability.Extract(io.LimitReader(response.Body, readerViewMaxBodySize), response.Request.URL)
+}
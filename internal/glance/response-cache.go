@@ -0,0 +1,498 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStaleIfErrorDuration = time.Hour
+	// responses larger than this are passed along without being cached or shared
+	responseCacheMaxBodySize   = 10 * 1024 * 1024
+	responseCacheSweepInterval = 10 * time.Minute
+	// how long an entry is kept around after it was last fetched when no widget
+	// has asked for it since, regardless of what the widgets allow
+	responseCacheMaxAge = 7 * 24 * time.Hour
+	// the directory within the data path that responses get persisted to
+	responseCacheDirName = "cache"
+	// how many restored responses get refreshed at the same time after a restart
+	responseCacheRevalidationLimit = 4
+	// how soon widgets that were shown using restored responses update again
+	restoredResponseRefreshDelay = 15 * time.Second
+)
+
+type responseCacheEntry struct {
+	status    int
+	header    http.Header
+	body      []byte
+	fetchedAt time.Time
+	// loaded from disk on startup and not fetched since
+	restored bool
+}
+
+type persistedResponse struct {
+	Status    int         `json:"status"`
+	Header    http.Header `json:"header"`
+	Body      []byte      `json:"body"`
+	FetchedAt time.Time   `json:"fetched_at"`
+}
+
+func (e *responseCacheEntry) toResponse(request *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.status, http.StatusText(e.status)),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       request,
+	}
+}
+
+// Responses are shared between all widgets so that several widgets making the
+// same request within their cache duration only result in one request upstream,
+// and kept across config reloads. How long a response is considered fresh and for how long a stale
+// one can still be used is up to each widget, see cachedHTTPClient.
+//
+// When persistence is enabled every stored response is also written to its own
+// file so that widgets can be shown right away after a restart.
+type responseCache struct {
+	mu           sync.Mutex
+	entries      map[string]*responseCacheEntry
+	revalidating map[string]struct{}
+	lastSweep    time.Time
+	dirPath      string
+	// limits how many revalidations run at once so that restarting with lots
+	// of restored responses doesn't result in a burst of requests
+	revalidationSlots chan struct{}
+}
+
+var sharedResponseCache = &responseCache{
+	entries:           make(map[string]*responseCacheEntry),
+	revalidating:      make(map[string]struct{}),
+	revalidationSlots: make(chan struct{}, responseCacheRevalidationLimit),
+}
+
+// An empty data path disables persistence, responses that have already been
+// persisted are left on disk
+func (c *responseCache) setPersistence(dataPath string) {
+	dirPath := ""
+	if dataPath != "" {
+		dirPath = filepath.Join(dataPath, responseCacheDirName)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if dirPath == c.dirPath {
+		return
+	}
+
+	c.dirPath = dirPath
+	if dirPath == "" {
+		return
+	}
+
+	if err := os.MkdirAll(dirPath, 0o700); err != nil {
+		slog.Error("Failed to create cache directory", "path", dirPath, "error", err)
+		c.dirPath = ""
+		return
+	}
+
+	files, err := os.ReadDir(dirPath)
+	if err != nil {
+		slog.Error("Failed to read cache directory", "path", dirPath, "error", err)
+		return
+	}
+
+	restored := 0
+
+	for _, file := range files {
+		key, ok := strings.CutSuffix(file.Name(), ".json")
+		if !ok || file.IsDir() {
+			continue
+		}
+
+		filePath := filepath.Join(dirPath, file.Name())
+		contents, err := os.ReadFile(filePath)
+		if err != nil {
+			slog.Error("Failed to read cached response", "path", filePath, "error", err)
+			continue
+		}
+
+		var persisted persistedResponse
+		if err := json.Unmarshal(contents, &persisted); err != nil || time.Since(persisted.FetchedAt) > responseCacheMaxAge {
+			os.Remove(filePath)
+			continue
+		}
+
+		// responses fetched since starting up are newer
+		if _, exists := c.entries[key]; exists {
+			continue
+		}
+
+		c.entries[key] = &responseCacheEntry{
+			status:    persisted.Status,
+			header:    persisted.Header,
+			body:      persisted.Body,
+			fetchedAt: persisted.FetchedAt,
+			restored:  true,
+		}
+		restored++
+	}
+
+	slog.Info("Restored cached responses", "count", restored, "path", dirPath)
+}
+
+func (c *responseCache) persist(dirPath, key string, entry *responseCacheEntry) {
+	contents, err := json.Marshal(persistedResponse{
+		Status:    entry.status,
+		Header:    entry.header,
+		Body:      entry.body,
+		FetchedAt: entry.fetchedAt,
+	})
+	if err != nil {
+		slog.Error("Failed to encode cached response", "error", err)
+		return
+	}
+
+	filePath := filepath.Join(dirPath, key+".json")
+	tempPath := filePath + ".tmp"
+
+	if err := os.WriteFile(tempPath, contents, 0o600); err != nil {
+		slog.Error("Failed to write cached response", "path", tempPath, "error", err)
+		return
+	}
+
+	if err := os.Rename(tempPath, filePath); err != nil {
+		slog.Error("Failed to write cached response", "path", filePath, "error", err)
+	}
+}
+
+func (c *responseCache) get(key string) *responseCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.entries[key]
+}
+
+// Makes the request and stores the response if it was successful, identical
+// requests that are already in progress get coalesced by the widget's client.
+// Responses that can't be cached are returned as they are.
+func (c *responseCache) fetch(key string, client requestDoer, request *http.Request) (*responseCacheEntry, *http.Response, error) {
+	entry, response, err := doCacheableRequest(client, request)
+	if err != nil || response != nil {
+		return nil, response, err
+	}
+
+	var dirPath string
+
+	c.mu.Lock()
+	if entry.status == http.StatusOK {
+		c.entries[key] = entry
+		dirPath = c.dirPath
+		c.sweepIfNeeded()
+	}
+	c.mu.Unlock()
+
+	if dirPath != "" {
+		c.persist(dirPath, key, entry)
+	}
+
+	return entry, nil, nil
+}
+
+// Refreshes an entry in the background while its stale copy gets used
+func (c *responseCache) revalidate(key string, client requestDoer, request *http.Request) {
+	c.mu.Lock()
+	if _, ok := c.revalidating[key]; ok {
+		c.mu.Unlock()
+		return
+	}
+	c.revalidating[key] = struct{}{}
+	c.mu.Unlock()
+
+	go func() {
+		c.revalidationSlots <- struct{}{}
+
+		defer func() {
+			<-c.revalidationSlots
+
+			c.mu.Lock()
+			delete(c.revalidating, key)
+			c.mu.Unlock()
+		}()
+
+		entry, response, err := c.fetch(key, client, request.Clone(context.Background()))
+		if response != nil {
+			response.Body.Close()
+			return
+		}
+
+		if err == nil && isFailedUpstreamStatus(entry.status) {
+			err = fmt.Errorf("unexpected status code %d", entry.status)
+		}
+
+		if err != nil {
+			slog.Warn("Failed to revalidate cached response", "url", request.URL.String(), "error", err)
+		}
+	}()
+}
+
+// Needs to be called with the lock held
+func (c *responseCache) sweepIfNeeded() {
+	now := time.Now()
+	if now.Sub(c.lastSweep) < responseCacheSweepInterval {
+		return
+	}
+
+	c.lastSweep = now
+
+	for key, entry := range c.entries {
+		if now.Sub(entry.fetchedAt) > responseCacheMaxAge {
+			delete(c.entries, key)
+
+			if c.dirPath != "" {
+				os.Remove(filepath.Join(c.dirPath, key+".json"))
+			}
+		}
+	}
+}
+
+// Reads the response so that it can be shared, responses that can't be are
+// returned as they are instead, see isBufferableResponse
+func doCacheableRequest(client requestDoer, request *http.Request) (*responseCacheEntry, *http.Response, error) {
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !isBufferableResponse(response) {
+		return nil, response, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, responseCacheMaxBodySize+1))
+	if err != nil {
+		response.Body.Close()
+		return nil, nil, err
+	}
+
+	// without a content length the size is only known once it's been read,
+	// what was read so far gets put back in front of the rest
+	if len(body) > responseCacheMaxBodySize {
+		response.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), response.Body), response.Body}
+
+		return nil, response, nil
+	}
+
+	response.Body.Close()
+
+	return &responseCacheEntry{
+		status:    response.StatusCode,
+		header:    response.Header,
+		body:      body,
+		fetchedAt: time.Now(),
+	}, nil, nil
+}
+
+// Streams and media such as camera snapshots aren't decoded by widgets and can
+// be large or never end, so they're neither held in memory nor shared
+func isBufferableResponse(response *http.Response) bool {
+	if response.ContentLength > responseCacheMaxBodySize {
+		return false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	for _, prefix := range []string{"multipart/", "image/", "video/", "audio/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Rate limits and server errors are usually temporary, so the last good
+// response gets used instead of showing an error
+func isFailedUpstreamStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// Identifies requests that can share a response. The user agent is left out
+// since it's randomized for some requests. The client is part of the key because
+// widgets with their own http options add headers at the transport level, so
+// their responses shouldn't be shared with anyone else.
+func requestKey(client *http.Client, request *http.Request) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%p\n%s\n%s\n", client, request.Method, request.URL.String())
+
+	keys := make([]string, 0, len(request.Header))
+	for key := range request.Header {
+		if key != "User-Agent" {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%s: %v\n", key, request.Header[key])
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type staleResponse struct {
+	fetchedAt time.Time
+	reason    string
+}
+
+// Wraps the client of a widget so that its GET requests go through the shared
+// response cache. Responses younger than the widget's cache duration are reused
+// as is, ones within stale-while-revalidate get used while being refreshed in
+// the background and ones within stale-if-error get used when the upstream is
+// failing, which also shows an indicator on the widget.
+type cachedHTTPClient struct {
+	client requestDoer
+	// what the client wraps, used to tell which widgets can share responses
+	shared *http.Client
+	widget *widgetBase
+}
+
+func (c *cachedHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet || request.Body != nil {
+		return c.client.Do(request)
+	}
+
+	widget := c.widget
+	freshFor := ternary(widget.cacheType == cacheTypeDuration, widget.cacheDuration, 0)
+	staleIfError := defaultStaleIfErrorDuration
+	if widget.StaleIfError != nil {
+		staleIfError = time.Duration(*widget.StaleIfError)
+	}
+	staleWhileRevalidate := time.Duration(widget.StaleWhileRevalidate)
+
+	key := requestKey(c.shared, request)
+	cached := sharedResponseCache.get(key)
+
+	// retrying a widget that failed, possibly because of what's cached
+	if widget.bypassResponseCache.Load() {
+		cached = nil
+	}
+
+	if cached != nil {
+		age := time.Since(cached.fetchedAt)
+
+		if age < freshFor {
+			recordResponseCacheResult("hit")
+			return cached.toResponse(request), nil
+		}
+
+		// after a restart the widget gets shown with what it had before while
+		// the fresh data that it will update with shortly gets fetched
+		if cached.restored || age < freshFor+staleWhileRevalidate {
+			if cached.restored {
+				widget.usedRestoredResponse.Store(true)
+			}
+
+			sharedResponseCache.revalidate(key, c.client, request)
+			recordResponseCacheResult("stale")
+			return cached.toResponse(request), nil
+		}
+	}
+
+	entry, response, err := sharedResponseCache.fetch(key, c.client, request)
+	if response != nil {
+		widget.setStaleResponse(key, nil)
+		recordResponseCacheResult("miss")
+		return response, nil
+	}
+
+	var reason string
+	if err != nil {
+		reason = err.Error()
+	} else if isFailedUpstreamStatus(entry.status) {
+		reason = fmt.Sprintf("unexpected status code %d", entry.status)
+	}
+
+	if reason == "" {
+		widget.setStaleResponse(key, nil)
+		recordResponseCacheResult("miss")
+		return entry.toResponse(request), nil
+	}
+
+	if cached != nil && time.Since(cached.fetchedAt) < freshFor+staleIfError {
+		slog.Warn("Using stale response", "url", request.URL.String(), "fetched_at", cached.fetchedAt, "error", reason)
+		widget.setStaleResponse(key, &staleResponse{fetchedAt: cached.fetchedAt, reason: reason})
+		recordResponseCacheResult("stale")
+		return cached.toResponse(request), nil
+	}
+
+	widget.setStaleResponse(key, nil)
+	recordResponseCacheResult("miss")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.toResponse(request), nil
+}
+
+// A nil response clears the stale state of the request
+func (w *widgetBase) setStaleResponse(key string, response *staleResponse) {
+	w.staleMu.Lock()
+	defer w.staleMu.Unlock()
+
+	if response == nil {
+		delete(w.staleResponses, key)
+		return
+	}
+
+	if w.staleResponses == nil {
+		w.staleResponses = make(map[string]staleResponse)
+	}
+
+	w.staleResponses[key] = *response
+}
+
+// Describes how old the data that's being shown is if any of the widget's
+// requests had to fall back to a stale response, empty otherwise
+func (w *widgetBase) StaleNotice() string {
+	w.staleMu.Lock()
+	defer w.staleMu.Unlock()
+
+	var oldest *staleResponse
+	for _, response := range w.staleResponses {
+		if oldest == nil || response.fetchedAt.Before(oldest.fetchedAt) {
+			oldest = &response
+		}
+	}
+
+	if oldest == nil {
+		return ""
+	}
+
+	return fmt.Sprintf(
+		"Showing data from %s, the latest update failed: %s",
+		oldest.fetchedAt.Format("Jan 2 15:04"),
+		oldest.reason,
+	)
+}
@@ -0,0 +1,323 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultMaxRetries              = 2
+	defaultRetryBaseDelay          = time.Second
+	defaultRetryMaxDelay           = 10 * time.Second
+	defaultCircuitFailureThreshold = 5
+	defaultCircuitCooldown         = time.Minute
+	// a host that keeps failing after being paused gets paused for twice as
+	// long each time, up to this
+	circuitMaxCooldown = 30 * time.Minute
+)
+
+var errCircuitOpen = errors.New("paused after repeated failures")
+
+type retriesConfig struct {
+	MaxRetries *int          `yaml:"max-retries"`
+	BaseDelay  durationField `yaml:"base-delay"`
+	MaxDelay   durationField `yaml:"max-delay"`
+}
+
+type circuitBreakerConfig struct {
+	Disabled         bool          `yaml:"disabled"`
+	FailureThreshold int           `yaml:"failure-threshold"`
+	Cooldown         durationField `yaml:"cooldown"`
+}
+
+func validateRetriesConfig(c *config) error {
+	if c.Retries.MaxRetries != nil && *c.Retries.MaxRetries < 0 {
+		return errors.New("retries: max-retries can't be negative")
+	}
+
+	if c.Retries.BaseDelay > 0 && c.Retries.MaxDelay > 0 && c.Retries.BaseDelay > c.Retries.MaxDelay {
+		return errors.New("retries: base-delay can't be longer than max-delay")
+	}
+
+	if c.CircuitBreaker.FailureThreshold < 0 {
+		return errors.New("circuit-breaker: failure-threshold can't be negative")
+	}
+
+	return nil
+}
+
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+
+	circuitDisabled  bool
+	failureThreshold int
+	cooldown         time.Duration
+}
+
+// Kept globally for the same reason as the outbound policy
+var currentRetryPolicy atomic.Pointer[retryPolicy]
+
+func setRetryPolicy(retries *retriesConfig, breaker *circuitBreakerConfig) {
+	policy := &retryPolicy{
+		maxRetries:       defaultMaxRetries,
+		baseDelay:        ternary(retries.BaseDelay > 0, time.Duration(retries.BaseDelay), defaultRetryBaseDelay),
+		maxDelay:         ternary(retries.MaxDelay > 0, time.Duration(retries.MaxDelay), defaultRetryMaxDelay),
+		circuitDisabled:  breaker.Disabled,
+		failureThreshold: ternary(breaker.FailureThreshold > 0, breaker.FailureThreshold, defaultCircuitFailureThreshold),
+		cooldown:         ternary(breaker.Cooldown > 0, time.Duration(breaker.Cooldown), defaultCircuitCooldown),
+	}
+
+	if retries.MaxRetries != nil {
+		policy.maxRetries = *retries.MaxRetries
+	}
+
+	currentRetryPolicy.Store(policy)
+}
+
+func init() {
+	setRetryPolicy(&retriesConfig{}, &circuitBreakerConfig{})
+}
+
+// Exponential backoff with jitter, somewhere between half and all of the
+// doubled delay so that widgets which failed together don't retry together
+func (p *retryPolicy) backoff(retry int) time.Duration {
+	delay := min(p.maxDelay, p.baseDelay<<retry)
+	half := delay / 2
+
+	return half + rand.N(half+1)
+}
+
+// Stops requests to a host that keeps failing for a while so that widgets
+// relying on it fail right away instead of each waiting for it to time out.
+// Once the cooldown is over a single request is let through to check whether
+// the host has recovered.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	failures int
+	// when the breaker was last opened, zero while it's closed
+	openedAt time.Time
+	cooldown time.Duration
+	probing  bool
+}
+
+var (
+	circuitBreakersMu sync.Mutex
+	circuitBreakers   = make(map[string]*circuitBreaker)
+)
+
+func circuitBreakerForHost(host string) *circuitBreaker {
+	host = strings.ToLower(host)
+
+	circuitBreakersMu.Lock()
+	defer circuitBreakersMu.Unlock()
+
+	breaker, ok := circuitBreakers[host]
+	if !ok {
+		breaker = &circuitBreaker{}
+		circuitBreakers[host] = breaker
+	}
+
+	return breaker
+}
+
+func (b *circuitBreaker) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return nil
+	}
+
+	reopensAt := b.openedAt.Add(b.cooldown)
+	if time.Now().Before(reopensAt) || b.probing {
+		return fmt.Errorf("requests to %s are %w, retrying after %s", host, errCircuitOpen, reopensAt.Format("15:04:05"))
+	}
+
+	b.probing = true
+
+	return nil
+}
+
+func (b *circuitBreaker) recordSuccess(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.openedAt.IsZero() {
+		slog.Info("Resuming requests to host", "host", host)
+	}
+
+	b.failures = 0
+	b.openedAt = time.Time{}
+	b.cooldown = 0
+	b.probing = false
+}
+
+// Lets another request check whether the host has recovered when the one that
+// was let through for it didn't end up telling either way
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *circuitBreaker) recordFailure(host string, policy *retryPolicy, reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+
+	switch {
+	case b.probing:
+		b.cooldown = min(circuitMaxCooldown, b.cooldown*2)
+	case b.openedAt.IsZero() && b.failures >= policy.failureThreshold:
+		b.cooldown = policy.cooldown
+	default:
+		return
+	}
+
+	b.openedAt = time.Now()
+	b.probing = false
+
+	slog.Warn(
+		"Pausing requests to failing host",
+		"host", host,
+		"failures", b.failures,
+		"until", b.openedAt.Add(b.cooldown).Format("15:04:05"),
+		"error", reason,
+	)
+}
+
+// Only requests that are safe to repeat get retried
+func isRetryableRequest(request *http.Request) bool {
+	return (request.Method == http.MethodGet || request.Method == http.MethodHead) && request.Body == nil
+}
+
+// Returns how long the upstream asked to wait before trying again, zero if it
+// didn't say
+func retryAfterDelay(response *http.Response) time.Duration {
+	value := response.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(0, seconds)) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, time.Until(date))
+	}
+
+	return 0
+}
+
+// Sits between the coalescing of identical requests and the actual client,
+// every attempt goes through the circuit breaker of the host and waits on the
+// rate limits. Failed requests are retried with backoff, a Retry-After sent
+// by the upstream is honored as long as it's within the maximum delay.
+type resilientHTTPClient struct {
+	client requestDoer
+}
+
+func (c *resilientHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	policy := currentRetryPolicy.Load()
+	host := request.URL.Hostname()
+	ctx := request.Context()
+
+	var breaker *circuitBreaker
+	if !policy.circuitDisabled {
+		breaker = circuitBreakerForHost(host)
+	}
+
+	maxRetries := ternary(isRetryableRequest(request), policy.maxRetries, 0)
+
+	for retry := 0; ; retry++ {
+		if breaker != nil {
+			if err := breaker.allow(host); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := currentRateLimits.Load().wait(ctx, host); err != nil {
+			if breaker != nil {
+				breaker.abandon()
+			}
+
+			return nil, err
+		}
+
+		response, err := c.client.Do(request)
+
+		var reason string
+		var delay time.Duration
+
+		if err != nil {
+			// the request was cancelled or blocked rather than the host failing
+			if ctx.Err() != nil || errors.Is(err, errOutboundRequestBlocked) {
+				if breaker != nil {
+					breaker.abandon()
+				}
+
+				return nil, err
+			}
+
+			reason = err.Error()
+		} else if isFailedUpstreamStatus(response.StatusCode) {
+			reason = fmt.Sprintf("unexpected status code %d", response.StatusCode)
+			delay = retryAfterDelay(response)
+		}
+
+		if reason == "" {
+			if breaker != nil {
+				breaker.recordSuccess(host)
+			}
+
+			return response, nil
+		}
+
+		if breaker != nil {
+			breaker.recordFailure(host, policy, reason)
+		}
+
+		if retry >= maxRetries || delay > policy.maxDelay {
+			return response, err
+		}
+
+		if delay == 0 {
+			delay = policy.backoff(retry)
+		}
+
+		if response != nil {
+			io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+			response.Body.Close()
+		}
+
+		if err := sleepWithContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func sleepWithContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
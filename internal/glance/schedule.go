@@ -0,0 +1,237 @@
+package glance
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Pages and widgets can be shown, hidden or have their updates paused during
+// certain times, such as hiding work related widgets on weekends or not
+// updating anything overnight while nobody is looking at the screen
+type visibilitySchedule struct {
+	ShowDuring  timeWindows `yaml:"show-during"`
+	HideDuring  timeWindows `yaml:"hide-during"`
+	PauseDuring timeWindows `yaml:"pause-during"`
+}
+
+func (s *visibilitySchedule) isVisible(t time.Time) bool {
+	if len(s.ShowDuring) > 0 && !s.ShowDuring.contains(t) {
+		return false
+	}
+
+	return !s.HideDuring.contains(t)
+}
+
+func (s *visibilitySchedule) isPaused(t time.Time) bool {
+	return s.PauseDuring.contains(t)
+}
+
+// Either a start and end time on certain days or a cron expression for when
+// the window starts along with how long it lasts. Times are in the timezone
+// of the server.
+type timeWindow struct {
+	Days     []string      `yaml:"days"`
+	From     string        `yaml:"from"`
+	To       string        `yaml:"to"`
+	Cron     string        `yaml:"cron"`
+	Duration durationField `yaml:"duration"`
+
+	days     [7]bool
+	from, to int // minutes since midnight
+	schedule *cronSchedule
+}
+
+type timeWindows []timeWindow
+
+func (w *timeWindows) UnmarshalYAML(node *yaml.Node) error {
+	var windows []timeWindow
+
+	if err := node.Decode(&windows); err != nil {
+		return err
+	}
+
+	for i := range windows {
+		if err := windows[i].parse(); err != nil {
+			return errorAtConfigLine(node, fmt.Errorf("time window %d: %v", i+1, err))
+		}
+	}
+
+	*w = windows
+
+	return nil
+}
+
+func (w *timeWindow) parse() error {
+	if w.Cron != "" {
+		if len(w.Days) > 0 || w.From != "" || w.To != "" {
+			return errors.New("cron cannot be combined with days, from or to")
+		}
+
+		if w.Duration <= 0 {
+			return errors.New("duration is required when using cron")
+		}
+
+		schedule, err := parseCronSchedule(w.Cron)
+		if err != nil {
+			return fmt.Errorf("invalid cron expression: %v", err)
+		}
+
+		w.schedule = schedule
+		return nil
+	}
+
+	if w.Duration != 0 {
+		return errors.New("duration can only be used with cron")
+	}
+
+	var err error
+	if w.from, err = parseTimeOfDay(w.From, 0); err != nil {
+		return fmt.Errorf("from: %v", err)
+	}
+
+	if w.to, err = parseTimeOfDay(w.To, 24*60); err != nil {
+		return fmt.Errorf("to: %v", err)
+	}
+
+	if w.from == w.to {
+		return errors.New("from and to cannot be the same")
+	}
+
+	if len(w.Days) == 0 {
+		w.days = [7]bool{true, true, true, true, true, true, true}
+		return nil
+	}
+
+	var values [8]bool
+	for i := range w.Days {
+		day := strings.ToLower(strings.TrimSpace(w.Days[i]))
+
+		switch day {
+		case "weekdays":
+			day = "mon-fri"
+		case "weekends":
+			day = "sat,sun"
+		default:
+			// allows both full day names and ranges of them, such as monday-friday
+			start, end, isRange := strings.Cut(day, "-")
+			day = limitDayName(start)
+			if isRange {
+				day += "-" + limitDayName(end)
+			}
+		}
+
+		if err := parseCronField(day, 0, 7, cronDayOfWeekNames, values[:]); err != nil {
+			return fmt.Errorf("invalid day %s", w.Days[i])
+		}
+	}
+
+	copy(w.days[:], values[:7])
+	w.days[0] = w.days[0] || values[7]
+
+	return nil
+}
+
+func limitDayName(day string) string {
+	if len(day) > 3 {
+		return day[:3]
+	}
+
+	return day
+}
+
+// Parses a time in the format of HH:MM into minutes since midnight, allowing
+// 24:00 for the end of the day
+func parseTimeOfDay(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+
+	at, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %s, must be in the format of HH:MM", value)
+	}
+
+	return at.Hour()*60 + at.Minute(), nil
+}
+
+func (w *timeWindow) contains(t time.Time) bool {
+	if w.schedule != nil {
+		start := w.schedule.prev(t)
+		return !start.IsZero() && t.Sub(start) < time.Duration(w.Duration)
+	}
+
+	minutes := t.Hour()*60 + t.Minute()
+	weekday := int(t.Weekday())
+
+	if w.from < w.to {
+		return w.days[weekday] && minutes >= w.from && minutes < w.to
+	}
+
+	// windows that go past midnight belong to the day they start on
+	return w.days[weekday] && minutes >= w.from || w.days[(weekday+6)%7] && minutes < w.to
+}
+
+func (w timeWindows) contains(t time.Time) bool {
+	for i := range w {
+		if w[i].contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Widgets are also hidden and paused along with the page and the group or
+// split column they're in
+func setWidgetSchedules(widget widget, inherited []*visibilitySchedule) {
+	base, ok := widget.(interface{ getWidgetBase() *widgetBase })
+	if !ok {
+		return
+	}
+
+	base.getWidgetBase().inheritedSchedules = inherited
+
+	if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+		inherited = append(inherited[:len(inherited):len(inherited)], &base.getWidgetBase().Schedule)
+
+		for _, child := range container.getChildWidgets() {
+			setWidgetSchedules(child, inherited)
+		}
+	}
+}
+
+func (w *widgetBase) isScheduledVisible(t time.Time) bool {
+	if !w.Schedule.isVisible(t) {
+		return false
+	}
+
+	for _, schedule := range w.inheritedSchedules {
+		if !schedule.isVisible(t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (w *widgetBase) isScheduledPaused(t time.Time) bool {
+	if w.Schedule.isPaused(t) {
+		return true
+	}
+
+	for _, schedule := range w.inheritedSchedules {
+		if schedule.isPaused(t) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,226 @@
+package glance
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookieName      = "glance_session"
+	seenItemsFileName      = "seen-items.json"
+	seenItemsRetention     = 30 * 24 * time.Hour
+	maxSeenItemsPerRequest = 100
+)
+
+// Shared by widgets that allow marking their items as seen
+type seenItemsOptions struct {
+	TrackSeenItems bool `yaml:"track-seen-items"`
+	HideSeenItems  bool `yaml:"hide-seen-items"`
+}
+
+func (o *seenItemsOptions) initializeSeenItems() {
+	if o.HideSeenItems {
+		o.TrackSeenItems = true
+	}
+}
+
+func seenItemID(url string) string {
+	hash := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(hash[:8])
+}
+
+// Keeps track of which items each browser session has seen. If a data path is
+// configured the state is also persisted to disk so that it survives restarts.
+type seenItemsStore struct {
+	mu       sync.Mutex
+	filePath string
+	// session -> item ID -> unix timestamp of when it was seen
+	sessions map[string]map[string]int64
+}
+
+func newSeenItemsStore(dataPath string) *seenItemsStore {
+	store := &seenItemsStore{
+		sessions: make(map[string]map[string]int64),
+	}
+
+	if dataPath == "" {
+		return store
+	}
+
+	store.filePath = filepath.Join(dataPath, seenItemsFileName)
+
+	contents, err := os.ReadFile(store.filePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Failed to read seen items", "path", store.filePath, "error", err)
+		}
+
+		return store
+	}
+
+	if err := json.Unmarshal(contents, &store.sessions); err != nil {
+		slog.Error("Failed to parse seen items", "path", store.filePath, "error", err)
+		store.sessions = make(map[string]map[string]int64)
+	}
+
+	store.prune()
+
+	return store
+}
+
+func (s *seenItemsStore) get(session string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := s.sessions[session]
+	ids := make([]string, 0, len(items))
+
+	for id := range items {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func (s *seenItemsStore) mark(session string, ids []string, seen bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items, exists := s.sessions[session]
+	if !exists {
+		if !seen {
+			return
+		}
+
+		items = make(map[string]int64)
+		s.sessions[session] = items
+	}
+
+	now := time.Now().Unix()
+
+	for _, id := range ids {
+		if seen {
+			items[id] = now
+		} else {
+			delete(items, id)
+		}
+	}
+
+	s.prune()
+	s.save()
+}
+
+// must be called with the lock held
+func (s *seenItemsStore) prune() {
+	cutoff := time.Now().Add(-seenItemsRetention).Unix()
+
+	for session, items := range s.sessions {
+		for id, seenAt := range items {
+			if seenAt < cutoff {
+				delete(items, id)
+			}
+		}
+
+		if len(items) == 0 {
+			delete(s.sessions, session)
+		}
+	}
+}
+
+// must be called with the lock held
+func (s *seenItemsStore) save() {
+	if s.filePath == "" {
+		return
+	}
+
+	contents, err := json.Marshal(s.sessions)
+	if err != nil {
+		slog.Error("Failed to encode seen items", "error", err)
+		return
+	}
+
+	// write to a temporary file first so that the state doesn't get corrupted if we crash mid-write
+	tempPath := s.filePath + ".tmp"
+	if err := os.WriteFile(tempPath, contents, 0o600); err != nil {
+		slog.Error("Failed to write seen items", "path", tempPath, "error", err)
+		return
+	}
+
+	if err := os.Rename(tempPath, s.filePath); err != nil {
+		slog.Error("Failed to write seen items", "path", s.filePath, "error", err)
+	}
+}
+
+func sessionFromRequest(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil && len(cookie.Value) == 32 {
+		return cookie.Value
+	}
+
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	session := hex.EncodeToString(bytes)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    session,
+		Path:     "/",
+		MaxAge:   int(seenItemsRetention.Seconds()) * 12,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return session
+}
+
+func (a *application) handleGetSeenItemsRequest(w http.ResponseWriter, r *http.Request) {
+	ids := a.seenItems.get(sessionFromRequest(w, r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ids)
+}
+
+func (a *application) handleMarkSeenItemsRequest(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		IDs  []string `json:"ids"`
+		Seen *bool    `json:"seen"`
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16*1024)).Decode(&body); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(body.IDs) == 0 || len(body.IDs) > maxSeenItemsPerRequest {
+		http.Error(w, "invalid number of ids", http.StatusBadRequest)
+		return
+	}
+
+	for _, id := range body.IDs {
+		if len(id) != 16 {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	seen := body.Seen == nil || *body.Seen
+	a.seenItems.mark(sessionFromRequest(w, r), body.IDs, seen)
+	a.audit.record(
+		r,
+		ternary(seen, "Marked as read", "Marked as unread"),
+		fmt.Sprintf("%d %s", len(body.IDs), ternary(len(body.IDs) == 1, "item", "items")),
+		"",
+		strings.Join(body.IDs, ", "),
+	)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,408 @@
+package glance
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// the directory within the data path that snapshots get stored in
+	snapshotsDirName         = "snapshots"
+	snapshotFileExtension    = ".json.gz"
+	snapshotsCheckInterval   = time.Minute
+	snapshotMinimumInterval  = 5 * time.Minute
+	defaultSnapshotInterval  = time.Hour
+	defaultSnapshotRetention = 7 * 24 * time.Hour
+)
+
+var snapshotContentTemplate = mustParseTemplate("snapshot-content.html")
+
+type pageSnapshotsConfig struct {
+	Interval durationField `yaml:"interval"`
+	Keep     durationField `yaml:"keep"`
+}
+
+type pageSnapshot struct {
+	TakenAt time.Time        `json:"taken_at"`
+	Columns []snapshotColumn `json:"columns"`
+}
+
+type snapshotColumn struct {
+	Size    string           `json:"size"`
+	Widgets []snapshotWidget `json:"widgets"`
+}
+
+type snapshotWidget struct {
+	// used to find the widget in the current config so that its access rules
+	// can be applied to what it used to show
+	ConfigHash string        `json:"config_hash"`
+	HTML       template.HTML `json:"html"`
+}
+
+// Snapshots are stored as one gzipped JSON file per page and point in time,
+// named after the unix timestamp of when they were taken
+type snapshotStore struct {
+	dirPath string
+}
+
+func newSnapshotStore(dataPath string) *snapshotStore {
+	if dataPath == "" {
+		return nil
+	}
+
+	return &snapshotStore{dirPath: filepath.Join(dataPath, snapshotsDirName)}
+}
+
+func (s *snapshotStore) pageDirPath(slug string) string {
+	return filepath.Join(s.dirPath, slug)
+}
+
+// Returns the times at which the page's snapshots were taken, newest first
+func (s *snapshotStore) list(slug string) []time.Time {
+	files, err := os.ReadDir(s.pageDirPath(slug))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Failed to read snapshots", "path", s.pageDirPath(slug), "error", err)
+		}
+
+		return nil
+	}
+
+	times := make([]time.Time, 0, len(files))
+
+	for _, file := range files {
+		name, ok := strings.CutSuffix(file.Name(), snapshotFileExtension)
+		if !ok || file.IsDir() {
+			continue
+		}
+
+		timestamp, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		times = append(times, time.Unix(timestamp, 0))
+	}
+
+	slices.SortFunc(times, func(a, b time.Time) int {
+		return b.Compare(a)
+	})
+
+	return times
+}
+
+func (s *snapshotStore) filePath(slug string, takenAt time.Time) string {
+	return filepath.Join(s.pageDirPath(slug), strconv.FormatInt(takenAt.Unix(), 10)+snapshotFileExtension)
+}
+
+func (s *snapshotStore) load(slug string, takenAt time.Time) (*pageSnapshot, error) {
+	file, err := os.Open(s.filePath(slug, takenAt))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	snapshot := &pageSnapshot{}
+	if err := json.NewDecoder(reader).Decode(snapshot); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func (s *snapshotStore) save(slug string, snapshot *pageSnapshot) error {
+	if err := os.MkdirAll(s.pageDirPath(slug), 0o700); err != nil {
+		return err
+	}
+
+	var contents bytes.Buffer
+	writer := gzip.NewWriter(&contents)
+
+	if err := json.NewEncoder(writer).Encode(snapshot); err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	filePath := s.filePath(slug, snapshot.TakenAt)
+	tempPath := filePath + ".tmp"
+
+	if err := os.WriteFile(tempPath, contents.Bytes(), 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, filePath)
+}
+
+func (s *snapshotStore) prune(slug string, keep time.Duration) {
+	cutoff := time.Now().Add(-keep)
+
+	for _, takenAt := range s.list(slug) {
+		if takenAt.Before(cutoff) {
+			os.Remove(s.filePath(slug, takenAt))
+		}
+	}
+}
+
+func (c *pageSnapshotsConfig) interval() time.Duration {
+	return ternary(c.Interval == 0, defaultSnapshotInterval, time.Duration(c.Interval))
+}
+
+func (c *pageSnapshotsConfig) retention() time.Duration {
+	return ternary(c.Keep == 0, defaultSnapshotRetention, time.Duration(c.Keep))
+}
+
+// Checks every minute whether any of the pages with snapshots enabled is due
+// for a new one. Snapshots are aligned to the interval, so hourly ones get taken
+// on the hour, and a page gets updated before its widgets are rendered since
+// nobody may have looked at it in a while.
+func (a *application) runSnapshots(ctx context.Context) {
+	if a.snapshots == nil {
+		return
+	}
+
+	ticker := time.NewTicker(snapshotsCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		for p := range a.Config.Pages {
+			page := &a.Config.Pages[p]
+			if page.Snapshots == nil {
+				continue
+			}
+
+			due := time.Now().Truncate(page.Snapshots.interval())
+			if times := a.snapshots.list(page.Slug); len(times) > 0 && !times[0].Before(due) {
+				continue
+			}
+
+			if err := a.takeSnapshot(page); err != nil {
+				slog.Error("Failed to take snapshot", "page", page.Title, "error", err)
+			}
+
+			a.snapshots.prune(page.Slug, page.Snapshots.retention())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *application) takeSnapshot(page *page) error {
+	snapshot := &pageSnapshot{}
+
+	func() {
+		page.mu.Lock()
+		defer page.mu.Unlock()
+
+		a.updateOutdatedWidgets(page)
+		snapshot.TakenAt = time.Now().Truncate(time.Second)
+
+		for c := range page.Columns {
+			column := snapshotColumn{Size: page.Columns[c].Size}
+
+			for _, widget := range page.Columns[c].Widgets {
+				column.Widgets = append(column.Widgets, snapshotWidget{
+					ConfigHash: widget.getConfigHash(),
+					HTML:       widget.Render(),
+				})
+			}
+
+			snapshot.Columns = append(snapshot.Columns, column)
+		}
+	}()
+
+	return a.snapshots.save(page.Slug, snapshot)
+}
+
+// Widgets are matched to the current config by their config hash, widgets that
+// have since been changed or removed are only shown when there's no auth since
+// there's no telling who should be able to see them
+func (a *application) visibleSnapshotColumns(user *authUser, snapshot *pageSnapshot) []snapshotColumn {
+	if a.auth == nil {
+		return snapshot.Columns
+	}
+
+	current := a.widgetsByConfigHash()
+	columns := make([]snapshotColumn, 0, len(snapshot.Columns))
+
+	for _, column := range snapshot.Columns {
+		visible := snapshotColumn{Size: column.Size}
+
+		for _, widget := range column.Widgets {
+			candidates := current[widget.ConfigHash]
+			if len(candidates) == 0 || !a.canAccessWidget(user, candidates[0].GetID()) {
+				continue
+			}
+
+			visible.Widgets = append(visible.Widgets, widget)
+		}
+
+		columns = append(columns, visible)
+	}
+
+	return columns
+}
+
+type pageHistory struct {
+	Selected time.Time
+	Times    []time.Time
+	Older    time.Time
+	Newer    time.Time
+}
+
+func (h *pageHistory) FormatTime(t time.Time) string {
+	if t.Year() != time.Now().Year() {
+		return t.Format("Jan 2 2006 15:04")
+	}
+
+	return t.Format("Jan 2 15:04")
+}
+
+func (a *application) historyPage(r *http.Request) (*page, bool) {
+	page, exists := a.slugToPage[r.PathValue("page")]
+	if !exists || page.Snapshots == nil || a.snapshots == nil || !a.canAccessPage(requestUser(r), page) {
+		return nil, false
+	}
+
+	return page, true
+}
+
+func (a *application) handlePageHistoryRequest(w http.ResponseWriter, r *http.Request) {
+	page, ok := a.historyPage(r)
+	if !ok {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	history := &pageHistory{Times: a.snapshots.list(page.Slug)}
+	selected := 0
+
+	if value := r.URL.Query().Get("at"); value != "" {
+		timestamp, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid snapshot time", http.StatusBadRequest)
+			return
+		}
+
+		// the closest snapshot taken at or before the requested time
+		selected = slices.IndexFunc(history.Times, func(t time.Time) bool {
+			return t.Unix() <= timestamp
+		})
+		if selected == -1 {
+			selected = len(history.Times) - 1
+		}
+	}
+
+	if len(history.Times) > 0 {
+		history.Selected = history.Times[selected]
+
+		if selected+1 < len(history.Times) {
+			history.Older = history.Times[selected+1]
+		}
+
+		if selected > 0 {
+			history.Newer = history.Times[selected-1]
+		}
+	}
+
+	pageData := pageTemplateData{
+		Page:    page,
+		App:     a,
+		History: history,
+		user:    requestUser(r),
+	}
+
+	var responseBytes bytes.Buffer
+	if err := pageTemplate.Execute(&responseBytes, pageData); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Write(responseBytes.Bytes())
+}
+
+type snapshotContentTemplateData struct {
+	Page    *page
+	Columns []snapshotColumn
+}
+
+func (a *application) handlePageSnapshotRequest(w http.ResponseWriter, r *http.Request) {
+	page, ok := a.historyPage(r)
+	if !ok {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	timestamp, err := strconv.ParseInt(r.PathValue("at"), 10, 64)
+	if err != nil {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	snapshot, err := a.snapshots.load(page.Slug, time.Unix(timestamp, 0))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Failed to load snapshot", "page", page.Title, "error", err)
+		}
+
+		a.handleNotFound(w, r)
+		return
+	}
+
+	data := snapshotContentTemplateData{
+		Page:    page,
+		Columns: a.visibleSnapshotColumns(requestUser(r), snapshot),
+	}
+
+	var responseBytes bytes.Buffer
+	if err := snapshotContentTemplate.Execute(&responseBytes, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Write(responseBytes.Bytes())
+}
+
+func validatePageSnapshots(c *config, p int) error {
+	snapshots := c.Pages[p].Snapshots
+	if snapshots == nil {
+		return nil
+	}
+
+	if c.Server.DataPath == "" {
+		return fmt.Errorf("page %d: snapshots require server.data-path to be set", p+1)
+	}
+
+	if snapshots.Interval != 0 && time.Duration(snapshots.Interval) < snapshotMinimumInterval {
+		return fmt.Errorf("page %d: snapshots interval must be at least 5m", p+1)
+	}
+
+	return nil
+}
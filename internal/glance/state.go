@@ -0,0 +1,117 @@
+package glance
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const stateFileName = "state.json"
+
+// A small key value store for state that widgets need to keep track of, such
+// as which reminders have been checked off. If a data path is configured the
+// state is persisted to disk, otherwise it only lives for as long as the
+// current configuration is loaded.
+type stateStore struct {
+	mu       sync.Mutex
+	filePath string
+	values   map[string]json.RawMessage
+}
+
+func newStateStore(dataPath string) *stateStore {
+	store := &stateStore{
+		values: make(map[string]json.RawMessage),
+	}
+
+	if dataPath == "" {
+		return store
+	}
+
+	store.filePath = filepath.Join(dataPath, stateFileName)
+
+	contents, err := os.ReadFile(store.filePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Failed to read state", "path", store.filePath, "error", err)
+		}
+
+		return store
+	}
+
+	if err := json.Unmarshal(contents, &store.values); err != nil {
+		slog.Error("Failed to parse state", "path", store.filePath, "error", err)
+		store.values = make(map[string]json.RawMessage)
+	}
+
+	return store
+}
+
+// Decodes the value stored under key into v, returns false if there's no such value
+func (s *stateStore) get(key string, v any) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, exists := s.values[key]
+	if !exists {
+		return false
+	}
+
+	if err := json.Unmarshal(value, v); err != nil {
+		slog.Error("Failed to decode state", "key", key, "error", err)
+		return false
+	}
+
+	return true
+}
+
+func (s *stateStore) set(key string, v any) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Failed to encode state", "key", key, "error", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = value
+	s.save()
+}
+
+func (s *stateStore) delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.values[key]; !exists {
+		return
+	}
+
+	delete(s.values, key)
+	s.save()
+}
+
+// must be called with the lock held
+func (s *stateStore) save() {
+	if s.filePath == "" {
+		return
+	}
+
+	contents, err := json.Marshal(s.values)
+	if err != nil {
+		slog.Error("Failed to encode state", "error", err)
+		return
+	}
+
+	tempPath := s.filePath + ".tmp"
+	if err := os.WriteFile(tempPath, contents, 0o600); err != nil {
+		slog.Error("Failed to write state", "path", tempPath, "error", err)
+		return
+	}
+
+	if err := os.Rename(tempPath, s.filePath); err != nil {
+		slog.Error("Failed to write state", "path", s.filePath, "error", err)
+	}
+}
@@ -0,0 +1,41 @@
+import { showToast } from './utils.js';
+
+export default function(container) {
+    const widgetID = container.dataset.widgetId;
+
+    container.addEventListener("click", async (event) => {
+        const button = event.target.closest(".buttons-widget-button");
+        if (button === null) return;
+
+        if (button.dataset.confirm !== undefined && !window.confirm(button.dataset.confirm)) {
+            return;
+        }
+
+        button.disabled = true;
+
+        try {
+            const response = await fetch(`${pageData.baseURL}/api/widgets/${widgetID}/run`, {
+                method: "POST",
+                headers: { "Content-Type": "application/json" },
+                body: JSON.stringify({ button: Number(button.dataset.button) }),
+            });
+
+            if (!response.ok) {
+                showToast(await response.text(), true);
+                return;
+            }
+
+            const result = await response.json();
+            showToast(result.message, !result.ok);
+
+            if (result.button) {
+                button.outerHTML = result.button;
+            }
+        } catch (e) {
+            console.error(e);
+            showToast(e.message, true);
+        } finally {
+            button.disabled = false;
+        }
+    });
+}
@@ -0,0 +1,27 @@
+export default function(container) {
+    const images = container.querySelectorAll(".camera-snapshot");
+    const interval = parseInt(container.dataset.refreshInterval, 10);
+
+    const refresh = (image) => {
+        // loaded separately and swapped in once done so that the previous
+        // snapshot stays visible in the meantime, or if the camera is down
+        const next = new Image();
+        const base = image.src.split("&t=")[0];
+
+        next.onload = () => image.src = next.src;
+        next.src = `${base}&t=${Date.now()}`;
+    };
+
+    const poll = () => {
+        setTimeout(() => {
+            if (!container.isConnected) return;
+
+            // nobody is looking, try again once the page is visible
+            if (!document.hidden) images.forEach(refresh);
+
+            poll();
+        }, interval);
+    };
+
+    poll();
+}
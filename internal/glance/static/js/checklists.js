@@ -0,0 +1,35 @@
+function setDone(widgetID, item, done) {
+    return fetch(`${pageData.baseURL}/api/widgets/${widgetID}/done`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ item, done }),
+    });
+}
+
+export default function(container) {
+    const widgetID = container.dataset.widgetId;
+    const toggles = container.getElementsByClassName("checklist-toggle");
+
+    for (let i = 0; i < toggles.length; i++) {
+        const toggle = toggles[i];
+        const item = toggle.closest(".checklist-item");
+
+        toggle.addEventListener("click", async () => {
+            const done = toggle.getAttribute("aria-pressed") != "true";
+            toggle.disabled = true;
+
+            try {
+                const response = await setDone(widgetID, toggle.dataset.item, done);
+                if (!response.ok) return;
+
+                toggle.setAttribute("aria-pressed", done);
+                toggle.title = done ? "Mark as not done" : "Mark as done";
+                item.classList.toggle("checklist-item-done", done);
+            } catch (e) {
+                console.error(e);
+            } finally {
+                toggle.disabled = false;
+            }
+        });
+    }
+}
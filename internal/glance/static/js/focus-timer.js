@@ -0,0 +1,106 @@
+const syncInterval = 10000;
+
+function formatRemaining(ms) {
+    const seconds = Math.ceil(Math.max(0, ms) / 1000);
+    return `${String(Math.floor(seconds / 60)).padStart(2, "0")}:${String(seconds % 60).padStart(2, "0")}`;
+}
+
+export default function(container) {
+    const widgetID = container.dataset.widgetId;
+    const phase = container.querySelector(".focus-timer-phase");
+    const time = container.querySelector(".focus-timer-time");
+    const progress = container.querySelector(".focus-timer-progress-value");
+    const sessions = container.querySelector(".focus-timer-sessions");
+    const toggle = container.querySelector(".focus-timer-button[data-action=start], .focus-timer-button[data-action=pause]");
+    const buttons = container.querySelectorAll(".focus-timer-button");
+    // already translated to the locale of the widget
+    const labels = JSON.parse(container.dataset.labels);
+
+    let status;
+    // when the status was received, remaining times are relative to it
+    let receivedAt;
+    let ticking = null;
+
+    const render = () => {
+        const remaining = status.running ? status.remaining_ms - (Date.now() - receivedAt) : status.remaining_ms;
+
+        time.textContent = formatRemaining(remaining);
+        progress.style.width = `${(1 - Math.max(0, remaining) / status.duration_ms) * 100}%`;
+
+        // the server moves on to the next phase, ask it once this one is over
+        if (status.running && remaining <= 0) {
+            sync();
+        }
+    };
+
+    const apply = (next) => {
+        status = next;
+        receivedAt = Date.now();
+
+        phase.textContent = labels[status.phase] ?? status.phase;
+        sessions.textContent = `${status.completed} ${labels.done} · ${labels.long_break_in} ${status.until_long_break}`;
+        toggle.dataset.action = status.running ? "pause" : "start";
+        toggle.textContent = status.running ? labels.pause : labels.start;
+        container.classList.toggle("focus-timer-running", status.running);
+        container.classList.toggle("focus-timer-break", status.phase != "work");
+
+        clearInterval(ticking);
+        ticking = status.running ? setInterval(render, 1000) : null;
+        render();
+    };
+
+    const request = async (path, options) => {
+        try {
+            const response = await fetch(`${pageData.baseURL}/api/widgets/${widgetID}/${path}`, options);
+            if (!response.ok) return;
+
+            apply(await response.json());
+        } catch (e) {
+            console.error(e);
+        }
+    };
+
+    let syncing = false;
+    const sync = async () => {
+        if (syncing) return;
+        syncing = true;
+        await request("status");
+        syncing = false;
+    };
+
+    // picks up changes made on other devices
+    const poll = () => {
+        setTimeout(async () => {
+            if (!container.isConnected) {
+                clearInterval(ticking);
+                return;
+            }
+
+            if (!document.hidden) await sync();
+            poll();
+        }, syncInterval);
+    };
+
+    for (let i = 0; i < buttons.length; i++) {
+        const button = buttons[i];
+
+        button.addEventListener("click", async () => {
+            buttons.forEach((b) => b.disabled = true);
+
+            await request("action", {
+                method: "POST",
+                headers: { "Content-Type": "application/json" },
+                body: JSON.stringify({ action: button.dataset.action }),
+            });
+
+            buttons.forEach((b) => b.disabled = false);
+        });
+    }
+
+    document.addEventListener("visibilitychange", () => {
+        if (!document.hidden && container.isConnected) sync();
+    });
+
+    apply(JSON.parse(container.dataset.status));
+    poll();
+}
@@ -0,0 +1,26 @@
+export default function(container) {
+    const widgetID = container.dataset.widgetId;
+
+    container.addEventListener("click", async (event) => {
+        const toggle = event.target.closest(".home-assistant-toggle");
+        if (toggle === null) return;
+
+        toggle.disabled = true;
+
+        try {
+            const response = await fetch(`${pageData.baseURL}/api/widgets/${widgetID}/toggle`, {
+                method: "POST",
+                headers: { "Content-Type": "application/json" },
+                body: JSON.stringify({ entity: toggle.dataset.entity }),
+            });
+
+            if (!response.ok) return;
+
+            toggle.closest(".home-assistant-entity").outerHTML = await response.text();
+        } catch (e) {
+            console.error(e);
+        } finally {
+            toggle.disabled = false;
+        }
+    });
+}
@@ -0,0 +1,51 @@
+export default function(container) {
+    const widgetID = container.closest(".widget").dataset.widgetId;
+    const lines = container.querySelector(".log-tail-lines");
+    const status = container.querySelector(".log-tail-status");
+    const pauseButton = container.querySelector(".log-tail-pause");
+    const interval = parseInt(container.dataset.refreshInterval, 10);
+
+    let paused = false;
+    let timeout = null;
+
+    const poll = () => {
+        timeout = setTimeout(async () => {
+            if (!container.isConnected) return;
+
+            // nobody is looking, try again once the page is visible
+            if (document.hidden) {
+                poll();
+                return;
+            }
+
+            try {
+                const response = await fetch(`${pageData.baseURL}/api/widgets/${widgetID}/lines`);
+
+                if (response.ok) {
+                    const html = await response.text();
+                    if (!paused) lines.innerHTML = html;
+                    status.textContent = paused ? "Paused" : "Live";
+                } else {
+                    status.textContent = "Failed to update, retrying";
+                }
+            } catch (e) {
+                console.error(e);
+                status.textContent = "Failed to update, retrying";
+            }
+
+            if (!paused) poll();
+        }, interval);
+    };
+
+    pauseButton.addEventListener("click", () => {
+        paused = !paused;
+        pauseButton.textContent = paused ? "Resume" : "Pause";
+        pauseButton.setAttribute("aria-pressed", paused);
+        status.textContent = paused ? "Paused" : "Live";
+
+        clearTimeout(timeout);
+        if (!paused) poll();
+    });
+
+    poll();
+}
@@ -5,14 +5,19 @@ import { throttledDebounce, isElementVisible, openURLInNewTab } from './utils.js
 async function fetchPageContent(pageData) {
     // TODO: handle non 200 status codes/time outs
     // TODO: add retries
-    const response = await fetch(`${pageData.baseURL}/api/pages/${pageData.slug}/content/`);
+    // empty when looking at the history of a page that has no snapshots yet
+    if (pageData.contentURL === "") {
+        return "";
+    }
+
+    const response = await fetch(pageData.contentURL);
     const content = await response.text();
 
     return content;
 }
 
-function setupCarousels() {
-    const carouselElements = document.getElementsByClassName("carousel-container");
+function setupCarousels(root = document) {
+    const carouselElements = root.getElementsByClassName("carousel-container");
 
     if (carouselElements.length == 0) {
         return;
@@ -52,32 +57,40 @@ const dayInSeconds = hourInSeconds * 24;
 const monthInSeconds = dayInSeconds * 30.4;
 const yearInSeconds = dayInSeconds * 365;
 
-function timestampToRelativeTime(timestamp) {
+const relativeTimeUnits = [
+    { seconds: yearInSeconds, unit: "year", short: "y" },
+    { seconds: monthInSeconds, unit: "month", short: "mo" },
+    { seconds: dayInSeconds, unit: "day", short: "d" },
+    { seconds: hourInSeconds, unit: "hour", short: "h" },
+    { seconds: minuteInSeconds, unit: "minute", short: "m" },
+];
+
+// English is kept short, such as 3h, other languages are formatted by the
+// browser, such as 3 Std. or vor 3 Std. when showing how long ago it was
+function timestampToRelativeTime(timestamp, lang, ago = false) {
     let delta = Math.round((Date.now() / 1000) - timestamp);
-    let prefix = "";
+    const future = delta < 0;
 
-    if (delta < 0) {
+    if (future) {
         delta = -delta;
-        prefix = "in ";
     }
 
-    if (delta < minuteInSeconds) {
-        return prefix + "1m";
-    }
-    if (delta < hourInSeconds) {
-        return prefix + Math.floor(delta / minuteInSeconds) + "m";
-    }
-    if (delta < dayInSeconds) {
-        return prefix + Math.floor(delta / hourInSeconds) + "h";
-    }
-    if (delta < monthInSeconds) {
-        return prefix + Math.floor(delta / dayInSeconds) + "d";
+    const { seconds, unit, short } = relativeTimeUnits.find(u => delta >= u.seconds) ?? relativeTimeUnits.at(-1);
+    const value = Math.max(1, Math.floor(delta / seconds));
+
+    if (lang === undefined || lang.startsWith("en")) {
+        return (future ? "in " : "") + value + short + (ago && !future ? " ago" : "");
     }
-    if (delta < yearInSeconds) {
-        return prefix + Math.floor(delta / monthInSeconds) + "mo";
+
+    if (ago || future) {
+        return new Intl.RelativeTimeFormat(lang, { style: "short" }).format(future ? value : -value, unit);
     }
 
-    return prefix + Math.floor(delta / yearInSeconds) + "y";
+    return new Intl.NumberFormat(lang, { style: "unit", unit: unit, unitDisplay: "narrow" }).format(value);
+}
+
+function elementLang(element) {
+    return element.closest("[lang]")?.lang || undefined;
 }
 
 function updateRelativeTimeForElements(elements)
@@ -90,7 +103,11 @@ function updateRelativeTimeForElements(elements)
         if (timestamp === undefined)
             continue
 
-        element.textContent = timestampToRelativeTime(timestamp);
+        element.textContent = timestampToRelativeTime(
+            timestamp,
+            elementLang(element),
+            element.dataset.relativeTimeAgo !== undefined
+        );
     }
 }
 
@@ -103,54 +120,161 @@ function setupSearchBoxes() {
 
     for (let i = 0; i < searchWidgets.length; i++) {
         const widget = searchWidgets[i];
+        const widgetID = widget.closest(".widget").dataset.widgetId;
         const defaultSearchUrl = widget.dataset.defaultSearchUrl;
         const newTab = widget.dataset.newTab === "true";
         const inputElement = widget.getElementsByClassName("search-input")[0];
         const bangElement = widget.getElementsByClassName("search-bang")[0];
+        const suggestionsElement = widget.getElementsByClassName("search-suggestions")[0] ?? null;
         const bangs = widget.querySelectorAll(".search-bangs > input");
         const bangsMap = {};
         const kbdElement = widget.getElementsByTagName("kbd")[0];
+        // typed at the start of the input
         let currentBang = null;
+        // picked with tab, used when no bang is typed
+        let selectedBangIndex = -1;
         let lastQuery = "";
+        let suggestions = [];
+        let highlightedSuggestion = -1;
+        let suggestionsRequest = 0;
 
         for (let j = 0; j < bangs.length; j++) {
             const bang = bangs[j];
             bangsMap[bang.dataset.shortcut] = bang;
         }
 
-        const handleKeyDown = (event) => {
-            if (event.key == "Escape") {
-                inputElement.blur();
+        const activeBang = () => currentBang ?? (selectedBangIndex >= 0 ? bangs[selectedBangIndex] : null);
+
+        const queryFromInput = () => {
+            const input = inputElement.value.trim();
+            return currentBang != null ? input.slice(currentBang.dataset.shortcut.length + 1) : input;
+        };
+
+        const search = (query, event) => {
+            const bang = activeBang();
+
+            if (query.length == 0 && bang == null) {
                 return;
             }
 
-            if (event.key == "Enter") {
-                const input = inputElement.value.trim();
-                let query;
-                let searchUrlTemplate;
+            const searchUrlTemplate = bang != null ? bang.dataset.url : defaultSearchUrl;
+            const url = searchUrlTemplate.replace("!QUERY!", encodeURIComponent(query));
 
-                if (currentBang != null) {
-                    query = input.slice(currentBang.dataset.shortcut.length + 1);
-                    searchUrlTemplate = currentBang.dataset.url;
-                } else {
-                    query = input;
-                    searchUrlTemplate = defaultSearchUrl;
-                }
-                if (query.length == 0 && currentBang == null) {
+            if (newTab && !event.ctrlKey || !newTab && event.ctrlKey) {
+                window.open(url, '_blank').focus();
+            } else {
+                window.location.href = url;
+            }
+
+            lastQuery = query;
+            inputElement.value = "";
+            changeCurrentBang(null);
+            hideSuggestions();
+        };
+
+        const hideSuggestions = () => {
+            suggestionsRequest++;
+            suggestions = [];
+            highlightedSuggestion = -1;
+
+            if (suggestionsElement !== null) {
+                suggestionsElement.hidden = true;
+                suggestionsElement.replaceChildren();
+            }
+        };
+
+        const highlightSuggestion = (index) => {
+            highlightedSuggestion = index;
+            const items = suggestionsElement.children;
+
+            for (let j = 0; j < items.length; j++) {
+                items[j].classList.toggle("search-suggestion-highlighted", j == index);
+                items[j].setAttribute("aria-selected", j == index ? "true" : "false");
+            }
+        };
+
+        const showSuggestions = (values) => {
+            suggestions = values;
+            highlightedSuggestion = -1;
+            suggestionsElement.replaceChildren();
+
+            for (let j = 0; j < values.length; j++) {
+                const item = document.createElement("li");
+                item.className = "search-suggestion";
+                item.setAttribute("role", "option");
+                item.textContent = values[j];
+                // mousedown rather than click so that it happens before the input loses focus
+                item.addEventListener("mousedown", (event) => {
+                    event.preventDefault();
+                    search(values[j], event);
+                });
+                suggestionsElement.append(item);
+            }
+
+            suggestionsElement.hidden = values.length == 0;
+        };
+
+        const requestSuggestions = throttledDebounce(async () => {
+            const query = queryFromInput();
+            const request = ++suggestionsRequest;
+
+            if (query.length == 0) {
+                hideSuggestions();
+                return;
+            }
+
+            const bang = activeBang();
+            const params = new URLSearchParams({ q: query });
+            if (bang != null) params.set("bang", bang.dataset.index);
+
+            try {
+                const response = await fetch(`${pageData.baseURL}/api/widgets/${widgetID}/suggestions?${params}`);
+                if (!response.ok) return;
+
+                const values = await response.json();
+                // a newer request was made in the meantime
+                if (request != suggestionsRequest || document.activeElement !== inputElement) return;
+
+                showSuggestions(values);
+            } catch (e) {
+                console.error(e);
+            }
+        }, 5, 150);
+
+        const handleKeyDown = (event) => {
+            if (event.key == "Escape") {
+                if (suggestions.length > 0) {
+                    hideSuggestions();
                     return;
                 }
 
-                const url = searchUrlTemplate.replace("!QUERY!", encodeURIComponent(query));
+                inputElement.blur();
+                return;
+            }
+
+            if (event.key == "Enter") {
+                search(highlightedSuggestion >= 0 ? suggestions[highlightedSuggestion] : queryFromInput(), event);
+                return;
+            }
 
-                if (newTab && !event.ctrlKey || !newTab && event.ctrlKey) {
-                    window.open(url, '_blank').focus();
-                } else {
-                    window.location.href = url;
-                }
+            if (event.key == "Tab" && bangs.length > 0 && currentBang == null) {
+                event.preventDefault();
+                const count = bangs.length + 1;
+                selectedBangIndex = (selectedBangIndex + 1 + (event.shiftKey ? -1 : 1) + count) % count - 1;
+                changeCurrentBang(null);
+                if (suggestionsElement !== null) requestSuggestions();
+                return;
+            }
 
-                lastQuery = query;
-                inputElement.value = "";
+            if (event.key == "ArrowDown" && suggestions.length > 0) {
+                event.preventDefault();
+                highlightSuggestion((highlightedSuggestion + 1) % suggestions.length);
+                return;
+            }
 
+            if (event.key == "ArrowUp" && suggestions.length > 0) {
+                event.preventDefault();
+                highlightSuggestion(highlightedSuggestion <= 0 ? suggestions.length - 1 : highlightedSuggestion - 1);
                 return;
             }
 
@@ -162,11 +286,17 @@ function setupSearchBoxes() {
 
         const changeCurrentBang = (bang) => {
             currentBang = bang;
-            bangElement.textContent = bang != null ? bang.dataset.title : "";
+            const active = activeBang();
+            bangElement.textContent = active != null ? (active.dataset.title || active.dataset.shortcut) : "";
         }
 
         const handleInput = (event) => {
             const value = event.target.value.trim();
+
+            if (suggestionsElement !== null) {
+                requestSuggestions();
+            }
+
             if (value in bangsMap) {
                 changeCurrentBang(bangsMap[value]);
                 return;
@@ -188,6 +318,7 @@ function setupSearchBoxes() {
         inputElement.addEventListener("blur", () => {
             document.removeEventListener("keydown", handleKeyDown);
             document.removeEventListener("input", handleInput);
+            hideSuggestions();
         });
 
         document.addEventListener("keydown", (event) => {
@@ -205,14 +336,15 @@ function setupSearchBoxes() {
 }
 
 function setupDynamicRelativeTime() {
-    const elements = document.querySelectorAll("[data-dynamic-relative-time]");
+    // queried on every update since widgets can get replaced by live updates
+    const getElements = () => document.querySelectorAll("[data-dynamic-relative-time]");
     const updateInterval = 60 * 1000;
     let lastUpdateTime = Date.now();
 
-    updateRelativeTimeForElements(elements);
+    updateRelativeTimeForElements(getElements());
 
     const updateElementsAndTimestamp = () => {
-        updateRelativeTimeForElements(elements);
+        updateRelativeTimeForElements(getElements());
         lastUpdateTime = Date.now();
     };
 
@@ -246,8 +378,31 @@ function setupDynamicRelativeTime() {
     });
 }
 
-function setupGroups() {
-    const groups = document.getElementsByClassName("widget-type-group");
+function setupLocalizedTimes(root = document) {
+    const elements = root.querySelectorAll("[data-localized-time]");
+    const now = new Date();
+
+    for (let i = 0; i < elements.length; i++) {
+        const element = elements[i];
+        const date = new Date(parseInt(element.dataset.localizedTime, 10) * 1000);
+        const isWithinWeek = Math.abs(date - now) < 6 * 24 * 60 * 60 * 1000;
+
+        const lang = elementLang(element);
+
+        // without a configured locale, times are shown the way the browser shows them
+        element.textContent = date.toLocaleString(lang === "en" ? [] : lang, isWithinWeek
+            ? { weekday: "short", hour: "2-digit", minute: "2-digit" }
+            : { month: "short", day: "numeric", hour: "2-digit", minute: "2-digit" }
+        );
+    }
+}
+
+function setupGroups(root = document) {
+    const groups = Array.from(root.getElementsByClassName("widget-type-group"));
+
+    if (root !== document && root.classList.contains("widget-type-group")) {
+        groups.push(root);
+    }
 
     if (groups.length == 0) {
         return;
@@ -306,8 +461,8 @@ function setupGroups() {
     }
 }
 
-function setupLazyImages() {
-    const images = document.querySelectorAll("img[loading=lazy]");
+function setupLazyImages(root = document) {
+    const images = root.querySelectorAll("img[loading=lazy]");
 
     if (images.length == 0) {
         return;
@@ -381,8 +536,8 @@ function attachExpandToggleButton(collapsibleContainer) {
 };
 
 
-function setupCollapsibleLists() {
-    const collapsibleLists = document.querySelectorAll(".list.collapsible-container");
+function setupCollapsibleLists(root = document) {
+    const collapsibleLists = root.querySelectorAll(".list.collapsible-container");
 
     if (collapsibleLists.length == 0) {
         return;
@@ -415,8 +570,8 @@ function setupCollapsibleLists() {
     }
 }
 
-function setupCollapsibleGrids() {
-    const collapsibleGridElements = document.querySelectorAll(".cards-grid.collapsible-container");
+function setupCollapsibleGrids(root = document) {
+    const collapsibleGridElements = root.querySelectorAll(".cards-grid.collapsible-container");
 
     if (collapsibleGridElements.length == 0) {
         return;
@@ -491,8 +646,14 @@ function setupCollapsibleGrids() {
 }
 
 const contentReadyCallbacks = [];
+let contentReady = false;
 
 function afterContentReady(callback) {
+    if (contentReady) {
+        callback();
+        return;
+    }
+
     contentReadyCallbacks.push(callback);
 }
 
@@ -568,8 +729,8 @@ function zoneDiffText(diffInMinutes) {
     return { text: `${sign}${hours}h~`, title: `${hours} hour${hourSuffix} and ${minutes} minutes ${signText}` };
 }
 
-function setupClocks() {
-    const clocks = document.getElementsByClassName('clock');
+function setupClocks(root = document) {
+    const clocks = root.getElementsByClassName('clock');
 
     if (clocks.length == 0) {
         return;
@@ -629,8 +790,8 @@ function setupClocks() {
     updateClocks();
 }
 
-async function setupCalendars() {
-    const elems = document.getElementsByClassName("calendar");
+async function setupCalendars(root = document) {
+    const elems = root.getElementsByClassName("calendar");
     if (elems.length == 0) return;
 
     // TODO: implement prefetching, currently loads as a nasty waterfall of requests
@@ -640,8 +801,116 @@ async function setupCalendars() {
         calendar.default(elems[i]);
 }
 
-function setupTruncatedElementTitles() {
-    const elements = document.querySelectorAll(".text-truncate, .single-line-titles .title, .text-truncate-2-lines, .text-truncate-3-lines");
+async function setupSeenItems(root = document) {
+    const elems = root.querySelectorAll("[data-seen-items]");
+    if (elems.length == 0) return;
+
+    const seenItems = await import ('./seen-items.js');
+    await seenItems.default(elems);
+}
+
+async function setupSaveForLater(root = document) {
+    const elems = root.querySelectorAll("[data-save-for-later]");
+    if (elems.length == 0) return;
+
+    const saveForLater = await import ('./save-for-later.js');
+
+    for (let i = 0; i < elems.length; i++)
+        saveForLater.default(elems[i]);
+}
+
+async function setupChecklists(root = document) {
+    const elems = root.getElementsByClassName("checklist");
+    if (elems.length == 0) return;
+
+    const checklists = await import ('./checklists.js');
+
+    for (let i = 0; i < elems.length; i++)
+        checklists.default(elems[i]);
+}
+
+async function setupMediaPickers(root = document) {
+    const elems = root.getElementsByClassName("media-picker");
+    if (elems.length == 0) return;
+
+    const mediaPicker = await import ('./media-picker.js');
+
+    for (let i = 0; i < elems.length; i++)
+        mediaPicker.default(elems[i]);
+}
+
+async function setupMonitors(root = document) {
+    const elems = root.getElementsByClassName("monitor-silenceable");
+    if (elems.length == 0) return;
+
+    const monitor = await import ('./monitor.js');
+
+    for (let i = 0; i < elems.length; i++)
+        monitor.default(elems[i]);
+}
+
+async function setupHomeAssistant(root = document) {
+    const elems = root.getElementsByClassName("home-assistant");
+    if (elems.length == 0) return;
+
+    const homeAssistant = await import ('./home-assistant.js');
+
+    for (let i = 0; i < elems.length; i++)
+        homeAssistant.default(elems[i]);
+}
+
+async function setupWakeOnLAN(root = document) {
+    const elems = root.getElementsByClassName("wake-on-lan");
+    if (elems.length == 0) return;
+
+    const wakeOnLAN = await import ('./wake-on-lan.js');
+
+    for (let i = 0; i < elems.length; i++)
+        wakeOnLAN.default(elems[i]);
+}
+
+async function setupLogTails(root = document) {
+    const elems = root.getElementsByClassName("log-tail");
+    if (elems.length == 0) return;
+
+    const logTail = await import ('./log-tail.js');
+
+    for (let i = 0; i < elems.length; i++)
+        logTail.default(elems[i]);
+}
+
+async function setupCameras(root = document) {
+    const elems = root.getElementsByClassName("cameras");
+    if (elems.length == 0) return;
+
+    const cameras = await import ('./cameras.js');
+
+    for (let i = 0; i < elems.length; i++)
+        cameras.default(elems[i]);
+}
+
+async function setupFocusTimers(root = document) {
+    const elems = root.getElementsByClassName("focus-timer");
+    if (elems.length == 0) return;
+
+    const focusTimer = await import ('./focus-timer.js');
+
+    for (let i = 0; i < elems.length; i++)
+        focusTimer.default(elems[i]);
+}
+
+async function setupButtons(root = document) {
+    const elems = root.getElementsByClassName("buttons-widget");
+    if (elems.length == 0) return;
+
+    const buttons = await import ('./buttons.js');
+
+    for (let i = 0; i < elems.length; i++)
+        buttons.default(elems[i]);
+}
+
+function setupTruncatedElementTitles(root = document) {
+    const elements = root.querySelectorAll(".text-truncate, .single-line-titles .title, .text-truncate-2-lines, .text-truncate-3-lines");
 
     if (elements.length == 0) {
         return;
@@ -664,6 +933,17 @@ async function setupPage() {
         setupPopovers();
         setupClocks()
         await setupCalendars();
+        await setupSeenItems();
+        await setupSaveForLater();
+        await setupChecklists();
+        await setupMediaPickers();
+        await setupHomeAssistant();
+        await setupWakeOnLAN();
+        await setupLogTails();
+        await setupCameras();
+        await setupFocusTimers();
+        await setupButtons();
+        await setupMonitors();
         setupCarousels();
         setupSearchBoxes();
         setupCollapsibleLists();
@@ -671,10 +951,12 @@ async function setupPage() {
         setupGroups();
         setupMasonries();
         setupDynamicRelativeTime();
+        setupLocalizedTimes();
         setupLazyImages();
     } finally {
         pageElement.classList.add("content-ready");
         pageElement.setAttribute("aria-busy", "false");
+        contentReady = true;
 
         for (let i = 0; i < contentReadyCallbacks.length; i++) {
             contentReadyCallbacks[i]();
@@ -688,6 +970,132 @@ async function setupPage() {
             document.body.classList.add("page-columns-transitioned");
         }, 300);
     }
+
+    setupWidgetRetries();
+
+    if (pageData.liveUpdates) {
+        setupLiveUpdates();
+    }
+
+    if (pageData.kiosk !== null) {
+        setupKioskRotation();
+    }
+}
+
+function setupKioskRotation() {
+    const { nextURL, interval } = pageData.kiosk;
+    if (nextURL === "") return;
+
+    const rotate = async () => {
+        // navigating to a page that can't be loaded would leave the screen on
+        // an error that nothing would ever move away from
+        try {
+            const response = await fetch(nextURL, { method: "HEAD" });
+
+            if (response.ok) {
+                location.assign(nextURL);
+                return;
+            }
+        } catch (e) {
+            console.error(e);
+        }
+
+        setTimeout(rotate, interval);
+    };
+
+    setTimeout(rotate, interval);
+}
+
+// Sets up the content of a single widget that got replaced after the page had
+// already loaded, everything that only needs to happen once per page is skipped
+async function setupWidget(widget) {
+    setupPopovers(widget);
+    setupClocks(widget);
+    await setupCalendars(widget);
+    await setupSeenItems(widget);
+    await setupSaveForLater(widget);
+    await setupChecklists(widget);
+    await setupMediaPickers(widget);
+    await setupHomeAssistant(widget);
+    await setupWakeOnLAN(widget);
+    await setupLogTails(widget);
+    await setupCameras(widget);
+    await setupFocusTimers(widget);
+    await setupButtons(widget);
+    await setupMonitors(widget);
+    setupCarousels(widget);
+    setupCollapsibleLists(widget);
+    setupCollapsibleGrids(widget);
+    setupGroups(widget);
+    setupMasonries(widget);
+    updateRelativeTimeForElements(widget.querySelectorAll("[data-dynamic-relative-time]"));
+    setupLocalizedTimes(widget);
+    setupLazyImages(widget);
+    setupTruncatedElementTitles(widget);
+}
+
+// Replaces the widget with the same ID as the one in the HTML
+async function replaceWidget(html) {
+    const template = document.createElement("template");
+    template.innerHTML = html;
+    const replacement = template.content.firstElementChild;
+
+    if (replacement === null) {
+        return;
+    }
+
+    const current = document.querySelector(`.widget[data-widget-id="${replacement.dataset.widgetId}"]`);
+
+    if (current === null) {
+        return;
+    }
+
+    current.replaceWith(replacement);
+
+    try {
+        await setupWidget(replacement);
+    } catch (e) {
+        console.error(e);
+    }
+}
+
+function setupLiveUpdates() {
+    const events = new EventSource(`${pageData.baseURL}/api/pages/${pageData.slug}/events`);
+
+    events.addEventListener("widget", async (event) => {
+        const update = JSON.parse(event.data);
+        await replaceWidget(update.html);
+    });
+}
+
+// Listens on the page rather than on each widget since the buttons come and
+// go as widgets get replaced
+function setupWidgetRetries() {
+    if (pageData.history) {
+        return;
+    }
+
+    document.getElementById("page-content").addEventListener("click", async (event) => {
+        const button = event.target.closest(".widget-retry");
+        if (button === null) return;
+
+        const widgetID = button.closest(".widget").dataset.widgetId;
+        button.disabled = true;
+
+        try {
+            const response = await fetch(`${pageData.baseURL}/api/widgets/${widgetID}/refresh`, {
+                method: "POST",
+            });
+
+            if (!response.ok) return;
+
+            await replaceWidget(await response.text());
+        } catch (e) {
+            console.error(e);
+        } finally {
+            button.disabled = false;
+        }
+    });
 }
 
 setupPage();
@@ -0,0 +1,20 @@
+export default function(container) {
+    const widgetID = container.dataset.widgetId;
+    const picks = container.getElementsByClassName("media-picker-picks")[0];
+    const button = container.getElementsByClassName("media-picker-roll")[0];
+
+    button.addEventListener("click", async () => {
+        button.disabled = true;
+
+        try {
+            const response = await fetch(`${pageData.baseURL}/api/widgets/${widgetID}/roll`);
+            if (!response.ok) return;
+
+            picks.innerHTML = await response.text();
+        } catch (e) {
+            console.error(e);
+        } finally {
+            button.disabled = false;
+        }
+    });
+}
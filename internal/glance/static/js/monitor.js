@@ -0,0 +1,27 @@
+export default function(container) {
+    const widgetID = container.dataset.widgetId;
+
+    container.addEventListener("click", async (event) => {
+        const button = event.target.closest(".monitor-silence");
+        if (button === null) return;
+
+        const site = button.closest(".monitor-site");
+        button.disabled = true;
+
+        try {
+            const response = await fetch(`${pageData.baseURL}/api/widgets/${widgetID}/silence`, {
+                method: "POST",
+                headers: { "Content-Type": "application/json" },
+                body: JSON.stringify({ site: site.dataset.site, duration: button.dataset.duration }),
+            });
+
+            if (!response.ok) return;
+
+            site.innerHTML = await response.text();
+        } catch (e) {
+            console.error(e);
+        } finally {
+            button.disabled = false;
+        }
+    });
+}
@@ -0,0 +1,29 @@
+export default function(container) {
+    const widgetID = container.dataset.saveForLater;
+
+    container.addEventListener("click", async (event) => {
+        const button = event.target.closest(".save-for-later");
+        if (button === null) return;
+
+        button.disabled = true;
+
+        try {
+            const response = await fetch(`${pageData.baseURL}/api/widgets/${widgetID}/save`, {
+                method: "POST",
+                headers: { "Content-Type": "application/json" },
+                body: JSON.stringify({ url: button.dataset.url }),
+            });
+
+            if (!response.ok) {
+                button.textContent = "Failed to save";
+                button.disabled = false;
+                return;
+            }
+
+            button.textContent = "Saved";
+        } catch (e) {
+            console.error(e);
+            button.disabled = false;
+        }
+    });
+}
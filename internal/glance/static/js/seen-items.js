@@ -0,0 +1,89 @@
+const dismissSvg = `<svg xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor">
+  <path stroke-linecap="round" stroke-linejoin="round" d="m4.5 12.75 6 6 9-13.5" />
+</svg>`;
+
+async function fetchSeenItems() {
+    const response = await fetch(`${pageData.baseURL}/api/seen-items`);
+    if (!response.ok) return new Set();
+
+    return new Set(await response.json());
+}
+
+function markItemsAsSeen(ids) {
+    return fetch(`${pageData.baseURL}/api/seen-items`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ ids }),
+    });
+}
+
+function applySeenState(item, hide) {
+    if (hide) {
+        item.remove();
+        return;
+    }
+
+    item.classList.add("seen-item");
+}
+
+export default async function(containers) {
+    let seen;
+
+    try {
+        seen = await fetchSeenItems();
+    } catch (e) {
+        console.error(e);
+        return;
+    }
+
+    for (let i = 0; i < containers.length; i++) {
+        const container = containers[i];
+        const hide = container.dataset.seenItems == "hide";
+        const items = container.querySelectorAll("[data-item-id]");
+
+        for (let j = 0; j < items.length; j++) {
+            const item = items[j];
+            const id = item.dataset.itemId;
+
+            if (seen.has(id)) {
+                applySeenState(item, hide);
+                continue;
+            }
+
+            const markAsSeen = () => {
+                if (seen.has(id)) return;
+                seen.add(id);
+                markItemsAsSeen([id]).catch(console.error);
+            };
+
+            // only mark the item as seen when opening the item itself rather than
+            // any of the other links within it, such as the domain or the channel
+            const link = item.querySelector("a");
+            if (link !== null) {
+                link.addEventListener("click", () => {
+                    markAsSeen();
+                    item.classList.add("seen-item");
+                });
+                link.addEventListener("auxclick", (event) => {
+                    if (event.button != 1) return;
+                    markAsSeen();
+                    item.classList.add("seen-item");
+                });
+            }
+
+            if (!container.classList.contains("collapsible-container")) continue;
+
+            const dismissButton = document.createElement("button");
+            dismissButton.classList.add("seen-item-dismiss");
+            dismissButton.title = "Mark as seen";
+            dismissButton.innerHTML = dismissSvg;
+            dismissButton.addEventListener("click", () => {
+                markAsSeen();
+                applySeenState(item, hide);
+            });
+
+            item.classList.add("seen-item-dismissable");
+            item.append(dismissButton);
+        }
+    }
+}
@@ -36,3 +36,24 @@ export function openURLInNewTab(url, focus = true) {
 
     if (focus && newWindow != null) newWindow.focus();
 }
+
+let toastContainer = null;
+
+export function showToast(message, isError = false) {
+    if (toastContainer === null) {
+        toastContainer = document.createElement("div");
+        toastContainer.className = "toasts";
+        toastContainer.setAttribute("role", "status");
+        document.body.append(toastContainer);
+    }
+
+    const toast = document.createElement("div");
+    toast.className = "toast" + (isError ? " toast-error" : "");
+    toast.textContent = message;
+    toastContainer.append(toast);
+
+    setTimeout(() => {
+        toast.classList.add("toast-hiding");
+        toast.addEventListener("animationend", () => toast.remove(), { once: true });
+    }, isError ? 8000 : 4000);
+}
@@ -0,0 +1,61 @@
+const pollInterval = 5000;
+
+export default function(container) {
+    const widgetID = container.dataset.widgetId;
+
+    const replaceMachine = async (machine, response) => {
+        if (!response.ok) return null;
+
+        const template = document.createElement("template");
+        template.innerHTML = await response.text();
+        const replacement = template.content.firstElementChild;
+
+        if (replacement === null) return null;
+
+        machine.replaceWith(replacement);
+        return replacement;
+    };
+
+    // keeps checking while the server is waiting for the machine to wake up
+    const poll = (machine) => {
+        if (!machine.hasAttribute("data-waking")) return;
+
+        setTimeout(async () => {
+            if (!machine.isConnected) return;
+
+            try {
+                const name = encodeURIComponent(machine.dataset.machine);
+                const response = await fetch(`${pageData.baseURL}/api/widgets/${widgetID}/machine?name=${name}`);
+                const replacement = await replaceMachine(machine, response);
+                poll(replacement ?? machine);
+            } catch (e) {
+                console.error(e);
+            }
+        }, pollInterval);
+    };
+
+    container.querySelectorAll(".wake-on-lan-machine[data-waking]").forEach(poll);
+
+    container.addEventListener("click", async (event) => {
+        const button = event.target.closest(".wake-on-lan-wake");
+        if (button === null) return;
+
+        const machine = button.closest(".wake-on-lan-machine");
+        button.disabled = true;
+
+        try {
+            const response = await fetch(`${pageData.baseURL}/api/widgets/${widgetID}/wake`, {
+                method: "POST",
+                headers: { "Content-Type": "application/json" },
+                body: JSON.stringify({ machine: machine.dataset.machine }),
+            });
+
+            const replacement = await replaceMachine(machine, response);
+            if (replacement !== null) poll(replacement);
+        } catch (e) {
+            console.error(e);
+        } finally {
+            button.disabled = false;
+        }
+    });
+}
@@ -0,0 +1,365 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	summariesFileName            = "summaries.json"
+	summaryCacheMaxAge           = 30 * 24 * time.Hour
+	summarizerTimeout            = 2 * time.Minute
+	defaultSummarizerMaxInput    = 4000
+	defaultSummaryMaxTokens      = 120
+	defaultDigestMaxTokens       = 400
+	summarizerCharactersPerToken = 4
+	defaultSummaryPrompt         = "Summarize the following article in one or two sentences. Reply with only the summary, in the language of the article."
+	defaultDigestPrompt          = "Below are the latest headlines from the feeds I follow. Write a short digest of the most notable news in a few sentences, grouping related stories together. Reply with only the digest."
+)
+
+var errSummarizerBudgetExceeded = errors.New("daily token budget of the summarizer has been used up")
+
+type summarizerConfig struct {
+	// an OpenAI compatible API, such as https://api.openai.com/v1 or Ollama's http://localhost:11434/v1
+	URL    string `yaml:"url"`
+	Model  string `yaml:"model"`
+	APIKey string `yaml:"api-key"`
+	Prompt string `yaml:"prompt"`
+	// in characters, anything past it gets cut off before being sent
+	MaxInputLength   int `yaml:"max-input-length"`
+	MaxSummaryTokens int `yaml:"max-summary-tokens"`
+	DailyTokenBudget int `yaml:"daily-token-budget"`
+}
+
+func validateSummarizerConfig(c *summarizerConfig) error {
+	if c == nil {
+		return nil
+	}
+
+	if c.URL == "" {
+		return errors.New("summarizer: url is required")
+	}
+
+	if parsed, err := url.Parse(c.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("summarizer: invalid url %s", c.URL)
+	}
+
+	if c.Model == "" {
+		return errors.New("summarizer: model is required")
+	}
+
+	if c.MaxInputLength < 0 || c.MaxSummaryTokens < 0 || c.DailyTokenBudget < 0 {
+		return errors.New("summarizer: max-input-length, max-summary-tokens and daily-token-budget can't be negative")
+	}
+
+	return nil
+}
+
+type storedSummary struct {
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Summaries are kept for a month and stored along with how many tokens have
+// been used today, so that neither restarting nor reloading the config leads
+// to the same content being summarized twice or to going over the budget
+type summaryStore struct {
+	mu         sync.Mutex
+	dataPath   string
+	filePath   string
+	Day        string                   `json:"day"`
+	TokensUsed int                      `json:"tokens_used"`
+	Summaries  map[string]storedSummary `json:"summaries"`
+}
+
+func newSummaryStore(dataPath string) *summaryStore {
+	store := &summaryStore{
+		dataPath:  dataPath,
+		Summaries: make(map[string]storedSummary),
+	}
+
+	if dataPath == "" {
+		return store
+	}
+
+	store.filePath = filepath.Join(dataPath, summariesFileName)
+
+	contents, err := os.ReadFile(store.filePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Failed to read summaries", "path", store.filePath, "error", err)
+		}
+
+		return store
+	}
+
+	if err := json.Unmarshal(contents, store); err != nil {
+		slog.Error("Failed to parse summaries", "path", store.filePath, "error", err)
+	}
+
+	if store.Summaries == nil {
+		store.Summaries = make(map[string]storedSummary)
+	}
+
+	cutoff := time.Now().Add(-summaryCacheMaxAge)
+	for key, summary := range store.Summaries {
+		if summary.CreatedAt.Before(cutoff) {
+			delete(store.Summaries, key)
+		}
+	}
+
+	return store
+}
+
+// must be called with the lock held
+func (s *summaryStore) save() {
+	if s.filePath == "" {
+		return
+	}
+
+	contents, err := json.Marshal(s)
+	if err != nil {
+		slog.Error("Failed to encode summaries", "error", err)
+		return
+	}
+
+	tempPath := s.filePath + ".tmp"
+	if err := os.WriteFile(tempPath, contents, 0o600); err != nil {
+		slog.Error("Failed to write summaries", "path", tempPath, "error", err)
+		return
+	}
+
+	if err := os.Rename(tempPath, s.filePath); err != nil {
+		slog.Error("Failed to write summaries", "path", s.filePath, "error", err)
+	}
+}
+
+// must be called with the lock held
+func (s *summaryStore) tokensUsedToday(now time.Time) int {
+	if today := now.Format(time.DateOnly); s.Day != today {
+		s.Day = today
+		s.TokensUsed = 0
+	}
+
+	return s.TokensUsed
+}
+
+// Sends content to a language model to have it summarized. Every summary is
+// cached by the model, prompt and content it was made from, and requests that
+// could go over the daily token budget are refused before being made.
+type summarizer struct {
+	config *summarizerConfig
+	store  *summaryStore
+
+	inFlightMu sync.Mutex
+	inFlight   map[string]*summaryCall
+}
+
+type summaryCall struct {
+	done chan struct{}
+	text string
+	err  error
+}
+
+func newSummarizer(config *summarizerConfig, dataPath string, previous *summarizer) *summarizer {
+	s := &summarizer{
+		config:   config,
+		inFlight: make(map[string]*summaryCall),
+	}
+
+	if previous != nil && previous.store.dataPath == dataPath {
+		s.store = previous.store
+	} else {
+		s.store = newSummaryStore(dataPath)
+	}
+
+	return s
+}
+
+var summarizerHTTPClient = &http.Client{
+	Timeout:   summarizerTimeout,
+	Transport: newOutboundTransport(false),
+}
+
+type chatCompletionRequest struct {
+	Model       string                  `json:"model"`
+	Messages    []chatCompletionMessage `json:"messages"`
+	MaxTokens   int                     `json:"max_tokens"`
+	Temperature float64                 `json:"temperature"`
+}
+
+type chatCompletionMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatCompletionMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		TotalTokens int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+func summaryKey(parts ...string) string {
+	hash := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(hash[:])
+}
+
+// Summarizes an article, the prompt from the config is used when one is set
+func (s *summarizer) summarizeArticle(ctx context.Context, title, content string) (string, error) {
+	prompt := ternary(s.config.Prompt != "", s.config.Prompt, defaultSummaryPrompt)
+	maxTokens := ternary(s.config.MaxSummaryTokens > 0, s.config.MaxSummaryTokens, defaultSummaryMaxTokens)
+
+	return s.summarize(ctx, prompt, title+"\n\n"+content, maxTokens)
+}
+
+func (s *summarizer) summarize(ctx context.Context, prompt, content string, maxTokens int) (string, error) {
+	content, _ = limitStringLength(strings.TrimSpace(content), ternary(s.config.MaxInputLength > 0, s.config.MaxInputLength, defaultSummarizerMaxInput))
+	key := summaryKey(s.config.Model, prompt, content)
+
+	s.store.mu.Lock()
+	summary, cached := s.store.Summaries[key]
+	s.store.mu.Unlock()
+
+	if cached {
+		return summary.Text, nil
+	}
+
+	// widgets showing the same feed tend to update at the same time
+	s.inFlightMu.Lock()
+	if call, ok := s.inFlight[key]; ok {
+		s.inFlightMu.Unlock()
+
+		select {
+		case <-call.done:
+			return call.text, call.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	call := &summaryCall{done: make(chan struct{})}
+	s.inFlight[key] = call
+	s.inFlightMu.Unlock()
+
+	call.text, call.err = s.request(ctx, key, prompt, content, maxTokens)
+
+	s.inFlightMu.Lock()
+	delete(s.inFlight, key)
+	s.inFlightMu.Unlock()
+	close(call.done)
+
+	return call.text, call.err
+}
+
+func (s *summarizer) request(ctx context.Context, key, prompt, content string, maxTokens int) (string, error) {
+	// the actual usage is only known afterwards, so the most that the request
+	// could use is set aside up front
+	estimate := (len(prompt)+len(content))/summarizerCharactersPerToken + maxTokens
+
+	s.store.mu.Lock()
+	if budget := s.config.DailyTokenBudget; budget > 0 && s.store.tokensUsedToday(time.Now())+estimate > budget {
+		s.store.mu.Unlock()
+		return "", errSummarizerBudgetExceeded
+	}
+	s.store.TokensUsed += estimate
+	s.store.mu.Unlock()
+
+	text, used, err := s.complete(ctx, prompt, content, maxTokens)
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	s.store.tokensUsedToday(time.Now())
+	s.store.TokensUsed = max(0, s.store.TokensUsed-estimate+used)
+
+	if err != nil {
+		s.store.save()
+		return "", err
+	}
+
+	s.store.Summaries[key] = storedSummary{Text: text, CreatedAt: time.Now()}
+	s.store.save()
+
+	return text, nil
+}
+
+// Returns the reply along with the number of tokens that were used, which is
+// estimated from the length of the text when the API doesn't report it
+func (s *summarizer) complete(ctx context.Context, prompt, content string, maxTokens int) (string, int, error) {
+	body, err := json.Marshal(chatCompletionRequest{
+		Model: s.config.Model,
+		Messages: []chatCompletionMessage{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: content},
+		},
+		MaxTokens:   maxTokens,
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	endpoint := strings.TrimRight(s.config.URL, "/") + "/chat/completions"
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	if s.config.APIKey != "" {
+		request.Header.Set("Authorization", "Bearer "+s.config.APIKey)
+	}
+
+	response, err := summarizerHTTPClient.Do(request)
+	if err != nil {
+		return "", 0, err
+	}
+	defer response.Body.Close()
+
+	contents, err := io.ReadAll(io.LimitReader(response.Body, 1024*1024))
+	if err != nil {
+		return "", 0, err
+	}
+
+	// a failed request may still have been billed, assume the input was
+	estimated := (len(prompt) + len(content)) / summarizerCharactersPerToken
+
+	if response.StatusCode != http.StatusOK {
+		truncatedBody, _ := limitStringLength(string(contents), 256)
+		return "", estimated, fmt.Errorf("unexpected status code %d from %s, response: %s", response.StatusCode, endpoint, truncatedBody)
+	}
+
+	var completion chatCompletionResponse
+	if err := json.Unmarshal(contents, &completion); err != nil {
+		return "", estimated, fmt.Errorf("parsing response from %s: %v", endpoint, err)
+	}
+
+	if len(completion.Choices) == 0 || strings.TrimSpace(completion.Choices[0].Message.Content) == "" {
+		return "", estimated, fmt.Errorf("no summary returned from %s", endpoint)
+	}
+
+	text := strings.TrimSpace(completion.Choices[0].Message.Content)
+	used := completion.Usage.TotalTokens
+	if used == 0 {
+		used = estimated + len(text)/summarizerCharactersPerToken
+	}
+
+	return text, used, nil
+}
@@ -13,6 +13,10 @@ import (
 var intl = message.NewPrinter(language.English)
 
 var globalTemplateFunctions = template.FuncMap{
+	// replaced for locales other than English, see localizedTemplate
+	"tr": func(text string) string {
+		return text
+	},
 	"formatApproxNumber": formatApproxNumber,
 	"formatNumber":       intl.Sprint,
 	"safeCSS": func(str string) template.CSS {
@@ -30,7 +34,9 @@ var globalTemplateFunctions = template.FuncMap{
 	"formatPriceWithPrecision": func(precision int, price float64) string {
 		return intl.Sprintf("%."+strconv.Itoa(precision)+"f", price)
 	},
-	"dynamicRelativeTimeAttrs": dynamicRelativeTimeAttrs,
+	"dynamicRelativeTimeAttrs":    dynamicRelativeTimeAttrs,
+	"dynamicRelativeTimeAgoAttrs": dynamicRelativeTimeAgoAttrs,
+	"localizedTimeAttrs":          localizedTimeAttrs,
 	"formatServerMegabytes": func(mb uint64) template.HTML {
 		var value string
 		var label string
@@ -64,6 +70,8 @@ func mustParseTemplate(primary string, dependencies ...string) *template.Templat
 		panic(err)
 	}
 
+	templateFiles[t] = append([]string{primary}, dependencies...)
+
 	return t
 }
 
@@ -86,3 +94,15 @@ func formatApproxNumber(count int) string {
 func dynamicRelativeTimeAttrs(t interface{ Unix() int64 }) template.HTMLAttr {
 	return template.HTMLAttr(`data-dynamic-relative-time="` + strconv.FormatInt(t.Unix(), 10) + `"`)
 }
+
+// Same as dynamicRelativeTimeAttrs but the time is shown as how long ago it
+// was in the language of the widget, such as "3h ago" or "vor 3 Std."
+func dynamicRelativeTimeAgoAttrs(t interface{ Unix() int64 }) template.HTMLAttr {
+	return dynamicRelativeTimeAttrs(t) + " data-relative-time-ago"
+}
+
+// The contents of the element get replaced with the time formatted in the
+// timezone of the browser, so they should contain a fallback in the server's timezone
+func localizedTimeAttrs(t interface{ Unix() int64 }) template.HTMLAttr {
+	return template.HTMLAttr(`data-localized-time="` + strconv.FormatInt(t.Unix(), 10) + `"`)
+}
@@ -0,0 +1,104 @@
+package glance
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var uptimeReportTemplate = mustParseTemplate("uptime-report.html", "document.html")
+
+type uptimeReportTemplateData struct {
+	App         *application
+	Title       string
+	Month       time.Time
+	Target      float64
+	Rows        []monitorUptimeReportRow
+	PreviousURL string
+	NextURL     string
+	CSVURL      string
+}
+
+func (a *application) uptimeReportURL(widgetID uint64) string {
+	return a.Config.Server.BaseURL + "/uptime/" + strconv.FormatUint(widgetID, 10)
+}
+
+func (a *application) handleUptimeReportRequest(w http.ResponseWriter, r *http.Request) {
+	widgetID, err := strconv.ParseUint(r.PathValue("widget"), 10, 64)
+	if err != nil {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	widget, exists := a.widgetByID[widgetID]
+	if !exists || !a.canAccessWidget(requestUser(r), widgetID) {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	monitor, ok := widget.(*monitorWidget)
+	if !ok || !monitor.UptimeReport {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+
+	if value := r.URL.Query().Get("month"); value != "" {
+		month, err = time.ParseInLocation("2006-01", value, time.Local)
+		if err != nil {
+			http.Error(w, "month must be in the format of YYYY-MM", http.StatusBadRequest)
+			return
+		}
+	}
+
+	rows := monitor.uptimeReport(month.Year(), month.Month())
+	reportURL := a.uptimeReportURL(widgetID)
+
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="uptime-`+month.Format("2006-01")+`.csv"`)
+
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"site", "url", "checks", "failed", "uptime"})
+
+		for i := range rows {
+			row := &rows[i]
+			var uptime string
+			if row.Checks > 0 {
+				uptime = strconv.FormatFloat(uptimePercentage(row.Checks, row.Failed), 'f', 3, 64)
+			}
+
+			writer.Write([]string{row.Title, row.URL, strconv.Itoa(row.Checks), strconv.Itoa(row.Failed), uptime})
+		}
+
+		writer.Flush()
+		return
+	}
+
+	data := uptimeReportTemplateData{
+		App:         a,
+		Title:       monitor.Title,
+		Month:       month,
+		Target:      monitor.UptimeTarget,
+		Rows:        rows,
+		PreviousURL: reportURL + "?month=" + month.AddDate(0, -1, 0).Format("2006-01"),
+		CSVURL:      reportURL + "?format=csv&month=" + month.Format("2006-01"),
+	}
+
+	if next := month.AddDate(0, 1, 0); !next.After(now) {
+		data.NextURL = reportURL + "?month=" + next.Format("2006-01")
+	}
+
+	var responseBytes bytes.Buffer
+	if err := uptimeReportTemplate.Execute(&responseBytes, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Write(responseBytes.Bytes())
+}
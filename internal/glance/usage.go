@@ -0,0 +1,314 @@
+package glance
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// how often the usage of a widget gets written to the state store at most
+const widgetUsageSaveInterval = time.Minute
+
+var diagnosticsTemplate = mustParseTemplate("diagnostics.html", "document.html")
+
+// Limits how much a widget is allowed to fetch within a calendar month, either
+// or both of the limits can be set
+type widgetBudget struct {
+	Requests int64         `yaml:"requests"`
+	Bytes    byteSizeField `yaml:"bytes"`
+}
+
+type widgetUsage struct {
+	Month    string `json:"month"`
+	Requests int64  `json:"requests"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// Counts the requests a widget makes and the bytes it sends and receives over
+// the current month. If a data path is configured the counts are kept across
+// restarts, keyed by the widget's config so that editing it starts over.
+type widgetUsageTracker struct {
+	mu        sync.Mutex
+	loaded    bool
+	usage     widgetUsage
+	lastSaved time.Time
+}
+
+func usageMonth(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+func (w *widgetBase) usageStateKey() string {
+	return "widget-usage:" + w.configHash
+}
+
+// must be called with the lock held
+func (w *widgetBase) loadUsage() {
+	t := &w.usageTracker
+
+	if !t.loaded {
+		t.loaded = true
+
+		if w.Providers != nil && w.Providers.state != nil {
+			w.Providers.state.get(w.usageStateKey(), &t.usage)
+		}
+	}
+
+	if month := usageMonth(time.Now()); t.usage.Month != month {
+		t.usage = widgetUsage{Month: month}
+	}
+}
+
+func (w *widgetBase) recordUsage(requests, bytes int64) {
+	t := &w.usageTracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w.loadUsage()
+	t.usage.Requests += requests
+	t.usage.Bytes += bytes
+
+	if w.Providers != nil && w.Providers.state != nil && time.Since(t.lastSaved) >= widgetUsageSaveInterval {
+		t.lastSaved = time.Now()
+		w.Providers.state.set(w.usageStateKey(), t.usage)
+	}
+}
+
+func (w *widgetBase) currentUsage() widgetUsage {
+	t := &w.usageTracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w.loadUsage()
+
+	return t.usage
+}
+
+// Returns how much of the budget has been used as a fraction, whichever of the
+// two limits is closer to being reached is used
+func (w *widgetBase) budgetUsed() float64 {
+	if w.Budget == nil {
+		return 0
+	}
+
+	usage := w.currentUsage()
+	used := 0.0
+
+	if w.Budget.Requests > 0 {
+		used = max(used, float64(usage.Requests)/float64(w.Budget.Requests))
+	}
+
+	if w.Budget.Bytes > 0 {
+		used = max(used, float64(usage.Bytes)/float64(w.Budget.Bytes))
+	}
+
+	return used
+}
+
+// Spreads the budget over the month by holding off on updating until the
+// widget is no longer ahead of an even pace, so that a widget which has used
+// half of its budget waits until half of the month has passed
+func (w *widgetBase) throttleForBudget(next time.Time) time.Time {
+	if w.Budget == nil || next.IsZero() {
+		w.budgetThrottledUntil = time.Time{}
+		return next
+	}
+
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	paced := monthStart.Add(time.Duration(w.budgetUsed() * float64(monthEnd.Sub(monthStart))))
+	if paced.After(monthEnd) {
+		paced = monthEnd
+	}
+
+	if !paced.After(next) {
+		w.budgetThrottledUntil = time.Time{}
+		return next
+	}
+
+	w.budgetThrottledUntil = paced
+
+	return paced
+}
+
+func (w *widgetBase) BudgetNotice() string {
+	if w.budgetThrottledUntil.IsZero() {
+		return ""
+	}
+
+	return fmt.Sprintf(
+		"Updating less often to stay within the monthly budget, next update on %s",
+		w.budgetThrottledUntil.Format("Jan 2 15:04"),
+	)
+}
+
+// Records the usage of every request made through it, cached responses and
+// ones shared with another widget's identical request never reach it and so
+// aren't counted
+type meteredHTTPClient struct {
+	client requestDoer
+	widget *widgetBase
+}
+
+func (c *meteredHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	sent := max(request.ContentLength, 0)
+	start := time.Now()
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		c.widget.recordUsage(1, sent)
+		recordUpstreamResponse(request.URL.Hostname(), 0)
+
+		if debugLogsEnabled() {
+			c.widget.logger().Debug("Request failed",
+				"url", redactedURL(request.URL),
+				"duration", time.Since(start),
+				"error", err,
+				"error_class", errorClass(err),
+			)
+		}
+
+		return nil, err
+	}
+
+	c.widget.recordUsage(1, sent)
+	recordUpstreamResponse(request.URL.Hostname(), response.StatusCode)
+
+	if debugLogsEnabled() {
+		c.widget.logger().Debug("Request completed",
+			"url", redactedURL(request.URL),
+			"status", response.StatusCode,
+			"duration", time.Since(start),
+		)
+	}
+	response.Body = &meteredBody{ReadCloser: response.Body, widget: c.widget}
+
+	return response, nil
+}
+
+type meteredBody struct {
+	io.ReadCloser
+	widget *widgetBase
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.widget.recordUsage(0, int64(n))
+	}
+
+	return n, err
+}
+
+type diagnosticsWidget struct {
+	Page       string
+	Type       string
+	Title      string
+	Usage      widgetUsage
+	Budget     *widgetBudget
+	BudgetUsed float64
+	Throttled  string
+}
+
+func (w diagnosticsWidget) FormattedBytes() string {
+	return formatNetworkUsageBytes(uint64(w.Usage.Bytes))
+}
+
+type diagnosticsTemplateData struct {
+	App     *application
+	Month   string
+	Widgets []diagnosticsWidget
+	// only shown to those who can see every widget since entries can include
+	// what restricted widgets show
+	ShowAuditLog       bool
+	AuditLog           []auditEntry
+	AuditLogPersistent bool
+}
+
+func (a *application) handleDiagnosticsRequest(w http.ResponseWriter, r *http.Request) {
+	user := requestUser(r)
+	data := diagnosticsTemplateData{
+		App:   a,
+		Month: time.Now().Format("January 2006"),
+	}
+
+	if a.canAccessEverything(user) {
+		data.ShowAuditLog = true
+		data.AuditLog = a.audit.entries()
+		data.AuditLogPersistent = a.audit.filePath != ""
+	}
+
+	for p := range a.Config.Pages {
+		page := &a.Config.Pages[p]
+		if !a.canAccessPage(user, page) {
+			continue
+		}
+
+		page.mu.Lock()
+		for c := range page.Columns {
+			for _, widget := range page.Columns[c].Widgets {
+				if !a.canAccessWidget(user, widget.GetID()) {
+					continue
+				}
+
+				data.Widgets = append(data.Widgets, newDiagnosticsWidgets(page.Title, widget)...)
+			}
+		}
+		page.mu.Unlock()
+	}
+
+	var responseBytes bytes.Buffer
+	if err := diagnosticsTemplate.Execute(&responseBytes, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Write(responseBytes.Bytes())
+}
+
+// Widgets within groups and split columns get listed individually since
+// that's where the requests are made from
+func newDiagnosticsWidgets(pageTitle string, widget widget) []diagnosticsWidget {
+	if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+		var result []diagnosticsWidget
+		for _, child := range container.getChildWidgets() {
+			result = append(result, newDiagnosticsWidgets(pageTitle, child)...)
+		}
+
+		return result
+	}
+
+	if tracked, ok := widget.(interface {
+		usageDiagnostics(string) diagnosticsWidget
+	}); ok {
+		return []diagnosticsWidget{tracked.usageDiagnostics(pageTitle)}
+	}
+
+	return nil
+}
+
+func (w *widgetBase) usageDiagnostics(pageTitle string) diagnosticsWidget {
+	entry := diagnosticsWidget{
+		Page:   pageTitle,
+		Type:   w.Type,
+		Title:  w.Title,
+		Usage:  w.currentUsage(),
+		Budget: w.Budget,
+	}
+
+	if w.Budget != nil {
+		entry.BudgetUsed = w.budgetUsed() * 100
+	}
+
+	if !w.budgetThrottledUntil.IsZero() {
+		entry.Throttled = w.budgetThrottledUntil.Format("Jan 2 15:04")
+	}
+
+	return entry
+}
@@ -0,0 +1,109 @@
+package glance
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+)
+
+// Functions that can be used within custom templates of widgets. Functions
+// that mark strings as safe, such as safeURL, are left out so that everything
+// coming from the fetched data is always escaped.
+var userTemplateFunctions = func() template.FuncMap {
+	funcs := template.FuncMap{
+		"toUpper":   strings.ToUpper,
+		"toLower":   strings.ToLower,
+		"contains":  strings.Contains,
+		"hasPrefix": strings.HasPrefix,
+		"hasSuffix": strings.HasSuffix,
+		"join":      strings.Join,
+		"truncate": func(length int, s string) string {
+			if truncated, cut := limitStringLength(s, length); cut {
+				return truncated + "…"
+			}
+
+			return s
+		},
+		"formatTime": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+	}
+
+	for _, name := range []string{
+		"formatApproxNumber",
+		"formatNumber",
+		"absInt",
+		"formatPrice",
+		"formatPriceWithPrecision",
+		"dynamicRelativeTimeAttrs",
+		"localizedTimeAttrs",
+		"formatServerMegabytes",
+	} {
+		funcs[name] = globalTemplateFunctions[name]
+	}
+
+	for _, name := range []string{
+		"toFloat",
+		"toInt",
+		"add",
+		"sub",
+		"mul",
+		"div",
+		"now",
+		"duration",
+		"trimPrefix",
+		"trimSuffix",
+		"trimSpace",
+		"replaceAll",
+		"concat",
+	} {
+		funcs[name] = customAPITemplateFuncs[name]
+	}
+
+	return funcs
+}()
+
+var userTemplateBase = mustParseTemplate("widget-base.html")
+
+// The custom template replaces the content of the widget while its header and
+// error state stay the same, it gets the same data as the built-in template
+// which is the widget itself
+func (w *widgetBase) parseCustomTemplate() error {
+	if w.CustomTemplate != "" && w.CustomTemplateFile != "" {
+		return errors.New("only one of custom-template and custom-template-file can be set")
+	}
+
+	source := w.CustomTemplate
+
+	if w.CustomTemplateFile != "" {
+		contents, err := os.ReadFile(w.CustomTemplateFile)
+		if err != nil {
+			return fmt.Errorf("reading custom-template-file: %w", err)
+		}
+
+		source = string(contents)
+		w.customTemplateFileContents = contents
+	}
+
+	if source == "" {
+		return nil
+	}
+
+	// functions are shared by all templates of a set, so the template is
+	// first parsed on its own to reject anything that isn't allowed
+	if _, err := template.New("widget-content").Funcs(userTemplateFunctions).Parse(source); err != nil {
+		return fmt.Errorf("parsing custom template: %w", err)
+	}
+
+	t, err := template.Must(userTemplateBase.Clone()).Funcs(userTemplateFunctions).New("widget-content").Parse(source)
+	if err != nil {
+		return fmt.Errorf("parsing custom template: %w", err)
+	}
+
+	w.customTemplate = t.Lookup("widget-base.html")
+
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -44,6 +45,11 @@ func svgPolylineCoordsFromYValues(width float64, height float64, values []float6
 	min := slices.Min(values)
 	max := slices.Max(values)
 
+	// a flat line is drawn at the bottom rather than dividing by zero
+	if max == min {
+		max = min + 1
+	}
+
 	for i := range values {
 		coordinates[i] = fmt.Sprintf(
 			"%.2f,%.2f",
@@ -110,6 +116,26 @@ func limitStringLength(s string, max int) (string, bool) {
 	return s, false
 }
 
+// Formats durations such as 1h 25m, anything shorter than a minute is shown as 1m
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+
+	if hours == 0 {
+		return strconv.Itoa(max(minutes, 1)) + "m"
+	}
+
+	if minutes == 0 {
+		return strconv.Itoa(hours) + "h"
+	}
+
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
 func parseRFC3339Time(t string) time.Time {
 	parsed, err := time.Parse(time.RFC3339, t)
 	if err != nil {
@@ -179,6 +205,10 @@ func ternary[T any](condition bool, a, b T) T {
 	return b
 }
 
+func absInt(x int) int {
+	return ternary(x < 0, -x, x)
+}
+
 // Having compile time errors about unused variables is cool and all, but I don't want to
 // have to constantly comment out my code while I'm working on it and testing things out
 func ItsUsedTrustMeBro(...any) {}
@@ -0,0 +1,479 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var analyticsWidgetTemplate = mustParseTemplate("analytics.html", "widget-base.html")
+
+type analyticsWidget struct {
+	widgetBase    `yaml:",inline"`
+	ProviderName  string               `yaml:"provider"`
+	URL           string               `yaml:"url"`
+	Token         string               `yaml:"token"`
+	Username      string               `yaml:"username"`
+	Password      string               `yaml:"password"`
+	AllowInsecure bool                 `yaml:"allow-insecure"`
+	Sites         []analyticsSite      `yaml:"sites"`
+	TopPages      int                  `yaml:"top-pages"`
+	Stats         []analyticsSiteStats `yaml:"-"`
+	provider      analyticsProvider    `yaml:"-"`
+}
+
+type analyticsSite struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+}
+
+// Implemented by each of the supported services. Stats are for the current day.
+type analyticsProvider interface {
+	fetchStats(site *analyticsSite, topPages int) (*analyticsSiteStats, error)
+}
+
+type analyticsSiteStats struct {
+	Name      string
+	Visitors  int
+	Pageviews int
+	TopPages  []analyticsPage
+	Error     bool
+}
+
+type analyticsPage struct {
+	Path  string
+	Count int
+}
+
+func (widget *analyticsWidget) initialize() error {
+	widget.withTitle("Analytics").withCacheDuration(15 * time.Minute)
+
+	if len(widget.Sites) == 0 {
+		return errors.New("no sites specified")
+	}
+
+	for i := range widget.Sites {
+		if widget.Sites[i].ID == "" {
+			return fmt.Errorf("site %d: id is required", i+1)
+		}
+
+		if widget.Sites[i].Name == "" {
+			widget.Sites[i].Name = widget.Sites[i].ID
+		}
+	}
+
+	widget.URL = strings.TrimRight(widget.URL, "/")
+	client := widget.httpClient(widget.AllowInsecure)
+
+	switch widget.ProviderName {
+	case "plausible":
+		if widget.Token == "" {
+			return errors.New("token is required")
+		}
+
+		if widget.URL == "" {
+			widget.URL = "https://plausible.io"
+		}
+
+		widget.provider = &plausibleProvider{url: widget.URL, token: widget.Token, client: client}
+	case "umami":
+		if widget.URL == "" {
+			return errors.New("url is required")
+		}
+
+		if widget.Token == "" && (widget.Username == "" || widget.Password == "") {
+			return errors.New("either token or username and password are required")
+		}
+
+		widget.provider = &umamiProvider{
+			url:      widget.URL,
+			token:    widget.Token,
+			username: widget.Username,
+			password: widget.Password,
+			client:   client,
+		}
+	case "matomo":
+		if widget.URL == "" || widget.Token == "" {
+			return errors.New("url and token are required")
+		}
+
+		widget.provider = &matomoProvider{url: widget.URL, token: widget.Token, client: client}
+	case "":
+		return errors.New("provider is required")
+	default:
+		return fmt.Errorf("unsupported provider %s, must be one of plausible, umami or matomo", widget.ProviderName)
+	}
+
+	if widget.TopPages == 0 {
+		widget.TopPages = 5
+	}
+
+	return nil
+}
+
+func (widget *analyticsWidget) update(ctx context.Context) {
+	sites := make([]*analyticsSite, len(widget.Sites))
+	for i := range widget.Sites {
+		sites[i] = &widget.Sites[i]
+	}
+
+	job := newJob(func(site *analyticsSite) (*analyticsSiteStats, error) {
+		return widget.provider.fetchStats(site, max(widget.TopPages, 0))
+	}, sites).withWorkers(len(sites))
+
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		widget.canContinueUpdateAfterHandlingErr(fmt.Errorf("%w: %v", errNoContent, err))
+		return
+	}
+
+	stats := make([]analyticsSiteStats, len(results))
+	var failed int
+
+	for i := range results {
+		if errs[i] != nil {
+			failed++
+			widget.logger().Error("Failed to fetch analytics", "provider", widget.ProviderName, "site", widget.Sites[i].ID, "error", errs[i])
+			stats[i] = analyticsSiteStats{Error: true}
+		} else {
+			stats[i] = *results[i]
+		}
+
+		stats[i].Name = widget.Sites[i].Name
+	}
+
+	if failed == len(widget.Sites) {
+		err = fmt.Errorf("%w: %v", errNoContent, errs[0])
+	} else if failed > 0 {
+		err = fmt.Errorf("%w: could not fetch the stats of %d sites", errPartialContent, failed)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Stats = stats
+}
+
+func (widget *analyticsWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, analyticsWidgetTemplate)
+}
+
+type plausibleProvider struct {
+	url    string
+	token  string
+	client requestDoer
+}
+
+type plausibleAggregateResponseJson struct {
+	Results struct {
+		Visitors struct {
+			Value int `json:"value"`
+		} `json:"visitors"`
+		Pageviews struct {
+			Value int `json:"value"`
+		} `json:"pageviews"`
+	} `json:"results"`
+}
+
+type plausibleBreakdownResponseJson struct {
+	Results []struct {
+		Page      string `json:"page"`
+		Pageviews int    `json:"pageviews"`
+	} `json:"results"`
+}
+
+func (p *plausibleProvider) newRequest(path string, query url.Values) *http.Request {
+	request, _ := http.NewRequest("GET", p.url+path+"?"+query.Encode(), nil)
+	request.Header.Set("Authorization", "Bearer "+p.token)
+
+	return request
+}
+
+func (p *plausibleProvider) fetchStats(site *analyticsSite, topPages int) (*analyticsSiteStats, error) {
+	aggregate, err := decodeJsonFromRequest[plausibleAggregateResponseJson](p.client, p.newRequest("/api/v1/stats/aggregate", url.Values{
+		"site_id": {site.ID},
+		"period":  {"day"},
+		"metrics": {"visitors,pageviews"},
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &analyticsSiteStats{
+		Visitors:  aggregate.Results.Visitors.Value,
+		Pageviews: aggregate.Results.Pageviews.Value,
+	}
+
+	if topPages == 0 {
+		return stats, nil
+	}
+
+	breakdown, err := decodeJsonFromRequest[plausibleBreakdownResponseJson](p.client, p.newRequest("/api/v1/stats/breakdown", url.Values{
+		"site_id":  {site.ID},
+		"period":   {"day"},
+		"property": {"event:page"},
+		"metrics":  {"pageviews"},
+		"limit":    {strconv.Itoa(topPages)},
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("fetching top pages: %v", err)
+	}
+
+	for _, result := range breakdown.Results {
+		stats.TopPages = append(stats.TopPages, analyticsPage{Path: result.Page, Count: result.Pageviews})
+	}
+
+	return stats, nil
+}
+
+type umamiProvider struct {
+	url      string
+	token    string
+	username string
+	password string
+	client   requestDoer
+
+	// only used when logging in with a username and password
+	mu          sync.Mutex
+	loginToken  string
+	metricsType string
+}
+
+// Older versions of Umami return each stat as an object with the current and
+// previous value while newer ones only return the current value
+type umamiStatValue int
+
+func (v *umamiStatValue) UnmarshalJSON(data []byte) error {
+	var value struct {
+		Value int `json:"value"`
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+
+		*v = umamiStatValue(value.Value)
+		return nil
+	}
+
+	var number int
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+
+	*v = umamiStatValue(number)
+	return nil
+}
+
+type umamiStatsResponseJson struct {
+	Pageviews umamiStatValue `json:"pageviews"`
+	Visitors  umamiStatValue `json:"visitors"`
+}
+
+type umamiMetricsResponseJson []struct {
+	X string `json:"x"`
+	Y int    `json:"y"`
+}
+
+func (p *umamiProvider) authorize(request *http.Request) error {
+	if p.token != "" {
+		// API keys are only used by Umami Cloud
+		request.Header.Set("x-umami-api-key", p.token)
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.loginToken == "" {
+		body, _ := json.Marshal(map[string]string{"username": p.username, "password": p.password})
+		loginRequest, _ := http.NewRequest("POST", p.url+"/api/auth/login", bytes.NewReader(body))
+		loginRequest.Header.Set("Content-Type", "application/json")
+
+		response, err := decodeJsonFromRequest[struct {
+			Token string `json:"token"`
+		}](p.client, loginRequest)
+		if err != nil {
+			return fmt.Errorf("logging in: %v", err)
+		}
+
+		p.loginToken = response.Token
+	}
+
+	request.Header.Set("Authorization", "Bearer "+p.loginToken)
+	return nil
+}
+
+func umamiRequest[T any](p *umamiProvider, path string, query url.Values) (T, error) {
+	var empty T
+
+	request, _ := http.NewRequest("GET", p.url+path+"?"+query.Encode(), nil)
+	if err := p.authorize(request); err != nil {
+		return empty, err
+	}
+
+	response, err := decodeJsonFromRequest[T](p.client, request)
+	// the login token has likely expired, a new one is requested on the next update
+	if err != nil && strings.Contains(err.Error(), "status code 401") {
+		p.mu.Lock()
+		p.loginToken = ""
+		p.mu.Unlock()
+	}
+
+	return response, err
+}
+
+func (p *umamiProvider) fetchStats(site *analyticsSite, topPages int) (*analyticsSiteStats, error) {
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	query := url.Values{
+		"startAt": {strconv.FormatInt(startOfDay.UnixMilli(), 10)},
+		"endAt":   {strconv.FormatInt(now.UnixMilli(), 10)},
+	}
+
+	path := "/api/websites/" + url.PathEscape(site.ID)
+
+	response, err := umamiRequest[umamiStatsResponseJson](p, path+"/stats", query)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &analyticsSiteStats{
+		Visitors:  int(response.Visitors),
+		Pageviews: int(response.Pageviews),
+	}
+
+	if topPages == 0 {
+		return stats, nil
+	}
+
+	query.Set("limit", strconv.Itoa(topPages))
+
+	p.mu.Lock()
+	metricsType := ternary(p.metricsType != "", p.metricsType, "url")
+	p.mu.Unlock()
+
+	query.Set("type", metricsType)
+	metrics, err := umamiRequest[umamiMetricsResponseJson](p, path+"/metrics", query)
+
+	// newer versions of Umami renamed the url metric to path
+	if err != nil && metricsType == "url" && strings.Contains(err.Error(), "status code 400") {
+		query.Set("type", "path")
+		if metrics, err = umamiRequest[umamiMetricsResponseJson](p, path+"/metrics", query); err == nil {
+			p.mu.Lock()
+			p.metricsType = "path"
+			p.mu.Unlock()
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("fetching top pages: %v", err)
+	}
+
+	for i := range metrics {
+		if i >= topPages {
+			break
+		}
+
+		stats.TopPages = append(stats.TopPages, analyticsPage{Path: metrics[i].X, Count: metrics[i].Y})
+	}
+
+	return stats, nil
+}
+
+type matomoProvider struct {
+	url    string
+	token  string
+	client requestDoer
+}
+
+type matomoVisitsSummaryResponseJson struct {
+	UniqueVisitors int `json:"nb_uniq_visitors"`
+	Visits         int `json:"nb_visits"`
+}
+
+type matomoActionsResponseJson struct {
+	Pageviews int `json:"nb_pageviews"`
+}
+
+type matomoPageURLsResponseJson []struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+	Hits  int    `json:"nb_hits"`
+}
+
+// The token is sent in the body of a POST request since newer versions of
+// Matomo reject it in the query string by default
+func matomoRequest[T any](p *matomoProvider, method string, site *analyticsSite, extra url.Values) (T, error) {
+	query := url.Values{
+		"module": {"API"},
+		"method": {method},
+		"idSite": {site.ID},
+		"period": {"day"},
+		"date":   {"today"},
+		"format": {"JSON"},
+	}
+
+	for key := range extra {
+		query.Set(key, extra.Get(key))
+	}
+
+	body := url.Values{"token_auth": {p.token}}
+	request, _ := http.NewRequest("POST", p.url+"/index.php?"+query.Encode(), strings.NewReader(body.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return decodeJsonFromRequest[T](p.client, request)
+}
+
+func (p *matomoProvider) fetchStats(site *analyticsSite, topPages int) (*analyticsSiteStats, error) {
+	summary, err := matomoRequest[matomoVisitsSummaryResponseJson](p, "VisitsSummary.get", site, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	actions, err := matomoRequest[matomoActionsResponseJson](p, "Actions.get", site, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetching pageviews: %v", err)
+	}
+
+	stats := &analyticsSiteStats{
+		// unique visitors aren't available for all periods depending on the configuration
+		Visitors:  ternary(summary.UniqueVisitors > 0, summary.UniqueVisitors, summary.Visits),
+		Pageviews: actions.Pageviews,
+	}
+
+	if topPages == 0 {
+		return stats, nil
+	}
+
+	pages, err := matomoRequest[matomoPageURLsResponseJson](p, "Actions.getPageUrls", site, url.Values{
+		"flat":         {"1"},
+		"filter_limit": {strconv.Itoa(topPages)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching top pages: %v", err)
+	}
+
+	for _, page := range pages {
+		path := page.Label
+		if parsed, err := url.Parse(page.URL); err == nil && parsed.Path != "" {
+			path = parsed.Path
+		}
+
+		stats.TopPages = append(stats.TopPages, analyticsPage{Path: path, Count: page.Hits})
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,342 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var anniversariesWidgetTemplate = mustParseTemplate("anniversaries.html", "widget-base.html")
+
+type anniversariesWidget struct {
+	widgetBase    `yaml:",inline"`
+	Events        []anniversaryEvent `yaml:"events"`
+	Sources       []string           `yaml:"sources"`
+	Days          int                `yaml:"days"`
+	CollapseAfter int                `yaml:"collapse-after"`
+	Items         []anniversaryItem  `yaml:"-"`
+	// guards loaded being set, see weatherWidget
+	loadedMu sync.Mutex
+	loaded   []anniversary
+}
+
+type anniversaryEvent struct {
+	Name string `yaml:"name"`
+	Date string `yaml:"date"`
+	Type string `yaml:"type"`
+}
+
+type anniversary struct {
+	name  string
+	kind  string
+	year  int // 0 when unknown
+	month time.Month
+	day   int
+	// events from calendars that don't repeat every year only happen once
+	once bool
+}
+
+type anniversaryItem struct {
+	Name  string
+	Kind  string
+	Date  time.Time
+	Days  int
+	Years int
+}
+
+func (widget *anniversariesWidget) initialize() error {
+	widget.withTitle("Anniversaries").withCacheDuration(6 * time.Hour)
+
+	if len(widget.Events) == 0 && len(widget.Sources) == 0 {
+		return errors.New("no events or sources specified")
+	}
+
+	for i := range widget.Events {
+		event := &widget.Events[i]
+
+		if event.Name == "" {
+			return fmt.Errorf("event %d has no name", i+1)
+		}
+
+		if event.Type == "" {
+			event.Type = "birthday"
+		} else if event.Type != "birthday" && event.Type != "anniversary" {
+			return fmt.Errorf("event %s: invalid type %s, must be one of birthday or anniversary", event.Name, event.Type)
+		}
+
+		if _, _, _, ok := parseAnniversaryDate(event.Date); !ok {
+			return fmt.Errorf("event %s: invalid date %s, must be in the format of YYYY-MM-DD or MM-DD", event.Name, event.Date)
+		}
+	}
+
+	if widget.Days <= 0 {
+		widget.Days = 30
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *anniversariesWidget) update(ctx context.Context) {
+	loaded := make([]anniversary, 0, len(widget.Events))
+
+	for i := range widget.Events {
+		year, month, day, _ := parseAnniversaryDate(widget.Events[i].Date)
+		loaded = append(loaded, anniversary{
+			name:  widget.Events[i].Name,
+			kind:  widget.Events[i].Type,
+			year:  year,
+			month: month,
+			day:   day,
+		})
+	}
+
+	var failed int
+	var lastErr error
+
+	for _, source := range widget.Sources {
+		anniversaries, err := loadAnniversariesFromSource(ctx, widget.httpClient(false), source)
+		if err != nil {
+			failed++
+			lastErr = err
+			widget.logger().Error("Failed to load anniversaries", "source", source, "error", err)
+			continue
+		}
+
+		loaded = append(loaded, anniversaries...)
+	}
+
+	var err error
+	if failed > 0 && failed == len(widget.Sources) && len(widget.Events) == 0 {
+		err = fmt.Errorf("%w: %v", errNoContent, lastErr)
+	} else if failed > 0 {
+		err = fmt.Errorf("%w: could not load %d sources", errPartialContent, failed)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.loadedMu.Lock()
+	widget.loaded = loaded
+	widget.loadedMu.Unlock()
+}
+
+func (widget *anniversariesWidget) Render() template.HTML {
+	widget.Items = widget.buildItems(time.Now())
+
+	return widget.renderTemplate(widget, anniversariesWidgetTemplate)
+}
+
+func (widget *anniversariesWidget) buildItems(now time.Time) []anniversaryItem {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	until := today.AddDate(0, 0, widget.Days)
+	items := make([]anniversaryItem, 0)
+
+	for i := range widget.loaded {
+		a := &widget.loaded[i]
+
+		date, ok := a.nextOccurrence(today)
+		if !ok || date.After(until) {
+			continue
+		}
+
+		item := anniversaryItem{
+			Name: a.name,
+			Kind: a.kind,
+			Date: date,
+			// rounded since days around DST changes aren't exactly 24 hours long
+			Days: int(date.Sub(today).Hours()/24 + 0.5),
+		}
+
+		if a.year > 0 && !a.once {
+			item.Years = date.Year() - a.year
+		}
+
+		items = append(items, item)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Days != items[j].Days {
+			return items[i].Days < items[j].Days
+		}
+
+		return items[i].Name < items[j].Name
+	})
+
+	return items
+}
+
+// Returns the first occurrence on or after today. People born on the 29th of
+// February celebrate on the 28th in years that aren't leap years.
+func (a *anniversary) nextOccurrence(today time.Time) (time.Time, bool) {
+	on := func(year int) time.Time {
+		day := a.day
+		if a.month == time.February && day == 29 && !isLeapYear(year) {
+			day = 28
+		}
+
+		return time.Date(year, a.month, day, 0, 0, 0, 0, today.Location())
+	}
+
+	if a.once {
+		date := on(a.year)
+		return date, !date.Before(today)
+	}
+
+	if date := on(today.Year()); !date.Before(today) {
+		return date, true
+	}
+
+	return on(today.Year() + 1), true
+}
+
+func isLeapYear(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// Accepts the formats used in the config, vCards and iCalendar files: YYYY-MM-DD,
+// YYYYMMDD, MM-DD, --MM-DD and --MMDD, optionally followed by a time which is ignored
+func parseAnniversaryDate(value string) (int, time.Month, int, bool) {
+	value = strings.TrimSpace(value)
+	if date, _, found := strings.Cut(value, "T"); found {
+		value = date
+	}
+
+	value = strings.TrimPrefix(value, "--")
+	value = strings.ReplaceAll(value, "-", "")
+
+	var year int
+	switch len(value) {
+	case 8:
+		var err error
+		if year, err = strconv.Atoi(value[:4]); err != nil {
+			return 0, 0, 0, false
+		}
+		value = value[4:]
+	case 4:
+	default:
+		return 0, 0, 0, false
+	}
+
+	month, err := strconv.Atoi(value[:2])
+	if err != nil || month < 1 || month > 12 {
+		return 0, 0, 0, false
+	}
+
+	day, err := strconv.Atoi(value[2:])
+	if err != nil || day < 1 || day > 31 {
+		return 0, 0, 0, false
+	}
+
+	return year, time.Month(month), day, true
+}
+
+// The source can either be a path to a local file or a URL, e.g. the export of
+// an address book in the vCard format or a birthday calendar in the iCalendar format
+func loadAnniversariesFromSource(ctx context.Context, client requestDoer, source string) ([]anniversary, error) {
+	contents, err := readContentSource(ctx, client, source)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := unfoldContentLines(contents)
+
+	for _, line := range lines {
+		switch strings.ToUpper(line) {
+		case "BEGIN:VCARD":
+			return parseVCardAnniversaries(lines), nil
+		case "BEGIN:VCALENDAR":
+			return parseICalendarAnniversaries(parseICalendarEvents(lines)), nil
+		}
+	}
+
+	return nil, errors.New("not a vCard or iCalendar file")
+}
+
+func parseVCardAnniversaries(lines []string) []anniversary {
+	var anniversaries []anniversary
+	var name string
+	var dates []anniversary
+
+	for _, raw := range lines {
+		line, ok := parseContentLine(raw)
+		if !ok {
+			continue
+		}
+
+		switch line.name {
+		case "BEGIN":
+			name, dates = "", nil
+		case "FN":
+			name = contentTextUnescaper.Replace(line.value)
+		case "BDAY", "ANNIVERSARY", "X-ANNIVERSARY":
+			year, month, day, ok := parseAnniversaryDate(line.value)
+			if !ok {
+				continue
+			}
+
+			// Apple Contacts uses a placeholder year when it's unknown
+			if omit := line.params["X-APPLE-OMIT-YEAR"]; omit != "" && omit == strconv.Itoa(year) {
+				year = 0
+			}
+
+			dates = append(dates, anniversary{
+				kind:  ternary(line.name == "BDAY", "birthday", "anniversary"),
+				year:  year,
+				month: month,
+				day:   day,
+			})
+		case "END":
+			if name == "" {
+				continue
+			}
+
+			for i := range dates {
+				dates[i].name = name
+				anniversaries = append(anniversaries, dates[i])
+			}
+		}
+	}
+
+	return anniversaries
+}
+
+func parseICalendarAnniversaries(events []icalEvent) []anniversary {
+	var anniversaries []anniversary
+
+	for _, event := range events {
+		// overrides of single occurrences would show up as events of their own
+		if event.isCancelled() || event.isOverride() {
+			continue
+		}
+
+		line, _ := event.get("DTSTART")
+		year, month, day, ok := parseAnniversaryDate(line.value)
+		name := event.text("SUMMARY")
+		if !ok || name == "" {
+			continue
+		}
+
+		rule, _ := event.get("RRULE")
+		anniversaries = append(anniversaries, anniversary{
+			name:  name,
+			kind:  "event",
+			year:  year,
+			month: month,
+			day:   day,
+			once:  !strings.Contains(strings.ToUpper(rule.value), "FREQ=YEARLY"),
+		})
+	}
+
+	return anniversaries
+}
@@ -0,0 +1,569 @@
+package glance
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+var appStatsWidgetTemplate = mustParseTemplate("app-stats.html", "widget-base.html")
+
+const (
+	appStoreConnectAPIURL = "https://api.appstoreconnect.apple.com/v1"
+	iTunesLookupURL       = "https://itunes.apple.com/lookup"
+	googleStorageAPIURL   = "https://storage.googleapis.com/storage/v1"
+)
+
+// product type identifiers of first time downloads in App Store sales reports,
+// as opposed to updates, redownloads and in-app purchases
+var appStoreDownloadProductTypes = map[string]bool{
+	"1": true, "1F": true, "1T": true, "F1": true, "1E": true, "1EP": true, "1EU": true,
+}
+
+type appStatsWidget struct {
+	widgetBase `yaml:",inline"`
+	AppStore   *struct {
+		IssuerID       string `yaml:"issuer-id"`
+		KeyID          string `yaml:"key-id"`
+		PrivateKeyFile string `yaml:"private-key-file"`
+		VendorNumber   string `yaml:"vendor-number"`
+	} `yaml:"app-store"`
+	GooglePlay *struct {
+		ServiceAccountFile string `yaml:"service-account-file"`
+		Bucket             string `yaml:"bucket"`
+	} `yaml:"google-play"`
+	Apps  []appStatsApp `yaml:"apps"`
+	Stats []appStats    `yaml:"-"`
+
+	appStorePrivateKey []byte                     `yaml:"-"`
+	googlePlayToken    *googleServiceAccountToken `yaml:"-"`
+	// daily reports don't change once they're published, keyed by date
+	appStoreReports map[string]map[string]int `yaml:"-"`
+}
+
+type appStatsApp struct {
+	Name       string `yaml:"name"`
+	AppStoreID string `yaml:"app-store-id"`
+	Country    string `yaml:"country"`
+	Package    string `yaml:"package"`
+}
+
+type appStats struct {
+	Name     string
+	Platform string
+	URL      string
+	IconURL  string
+	Error    bool
+
+	HasDownloads      bool
+	DownloadsDate     time.Time
+	Downloads         int
+	PreviousDownloads int
+
+	HasRating      bool
+	Rating         float64
+	PreviousRating float64
+	RatingCount    int
+	// the number of ratings added since the previous day, only available for the App Store
+	NewRatings int
+}
+
+type appStatsRatingState struct {
+	Day                 string  `json:"day"`
+	Rating              float64 `json:"rating"`
+	RatingCount         int     `json:"rating_count"`
+	PreviousRating      float64 `json:"previous_rating"`
+	PreviousRatingCount int     `json:"previous_rating_count"`
+}
+
+type appStoreDownloadsReport struct {
+	date     time.Time
+	current  map[string]int
+	previous map[string]int
+}
+
+func (widget *appStatsWidget) initialize() error {
+	widget.withTitle("App Stats").withCacheDuration(3 * time.Hour)
+
+	if len(widget.Apps) == 0 {
+		return errors.New("no apps specified")
+	}
+
+	for i := range widget.Apps {
+		app := &widget.Apps[i]
+
+		if (app.AppStoreID == "") == (app.Package == "") {
+			return fmt.Errorf("app %d: exactly one of app-store-id or package must be specified", i+1)
+		}
+
+		if app.Package != "" && widget.GooglePlay == nil {
+			return fmt.Errorf("app %d: google-play must be configured for apps on Google Play", i+1)
+		}
+
+		if app.Country == "" {
+			app.Country = "us"
+		}
+	}
+
+	if widget.AppStore != nil {
+		if widget.AppStore.IssuerID == "" || widget.AppStore.KeyID == "" || widget.AppStore.VendorNumber == "" {
+			return errors.New("app-store: issuer-id, key-id and vendor-number are required")
+		}
+
+		key, err := os.ReadFile(widget.AppStore.PrivateKeyFile)
+		if err != nil {
+			return fmt.Errorf("app-store: reading private key: %v", err)
+		}
+
+		widget.appStorePrivateKey = key
+	}
+
+	if widget.GooglePlay != nil {
+		if widget.GooglePlay.Bucket == "" {
+			return errors.New("google-play: bucket is required")
+		}
+
+		// the bucket is shown as gs://pubsite_prod_rev_... in the Play Console
+		widget.GooglePlay.Bucket = strings.TrimSuffix(strings.TrimPrefix(widget.GooglePlay.Bucket, "gs://"), "/")
+
+		keyFile, err := os.ReadFile(widget.GooglePlay.ServiceAccountFile)
+		if err != nil {
+			return fmt.Errorf("google-play: reading service account file: %v", err)
+		}
+
+		widget.googlePlayToken, err = newGoogleServiceAccountToken(widget.httpClient(false), keyFile, "https://www.googleapis.com/auth/devstorage.read_only")
+		if err != nil {
+			return fmt.Errorf("google-play: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (widget *appStatsWidget) update(ctx context.Context) {
+	var report *appStoreDownloadsReport
+
+	if widget.AppStore != nil && widget.hasAppStoreApps() {
+		var err error
+		if report, err = widget.fetchAppStoreDownloads(); err != nil {
+			widget.logger().Error("Failed to fetch App Store sales report", "error", err)
+		}
+	}
+
+	apps := make([]*appStatsApp, len(widget.Apps))
+	for i := range widget.Apps {
+		apps[i] = &widget.Apps[i]
+	}
+
+	job := newJob(func(app *appStatsApp) (*appStats, error) {
+		if app.Package != "" {
+			return widget.fetchGooglePlayStats(app)
+		}
+
+		return widget.fetchAppStoreStats(app, report)
+	}, apps).withWorkers(len(apps))
+
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		widget.canContinueUpdateAfterHandlingErr(fmt.Errorf("%w: %v", errNoContent, err))
+		return
+	}
+
+	stats := make([]appStats, len(results))
+	var failed int
+
+	for i := range results {
+		if errs[i] != nil {
+			failed++
+			widget.logger().Error("Failed to fetch app stats", "app", widget.Apps[i].Name, "error", errs[i])
+			stats[i] = appStats{Name: widget.Apps[i].Name, Error: true}
+			continue
+		}
+
+		stats[i] = *results[i]
+	}
+
+	if failed == len(widget.Apps) {
+		err = fmt.Errorf("%w: %v", errNoContent, errs[0])
+	} else if failed > 0 {
+		err = fmt.Errorf("%w: could not fetch the stats of %d apps", errPartialContent, failed)
+	} else if widget.AppStore != nil && widget.hasAppStoreApps() && report == nil {
+		err = fmt.Errorf("%w: could not fetch App Store downloads", errPartialContent)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Stats = stats
+}
+
+func (widget *appStatsWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, appStatsWidgetTemplate)
+}
+
+func (widget *appStatsWidget) hasAppStoreApps() bool {
+	for i := range widget.Apps {
+		if widget.Apps[i].AppStoreID != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (stats *appStats) DownloadsDelta() int {
+	return stats.Downloads - stats.PreviousDownloads
+}
+
+func (stats *appStats) RatingDelta() float64 {
+	if stats.PreviousRating == 0 {
+		return 0
+	}
+
+	// round to avoid showing a change that's too small to be visible
+	return math.Round((stats.Rating-stats.PreviousRating)*100) / 100
+}
+
+type iTunesLookupResponseJson struct {
+	Results []struct {
+		TrackName         string  `json:"trackName"`
+		TrackViewURL      string  `json:"trackViewUrl"`
+		ArtworkURL100     string  `json:"artworkUrl100"`
+		AverageUserRating float64 `json:"averageUserRating"`
+		UserRatingCount   int     `json:"userRatingCount"`
+	} `json:"results"`
+}
+
+func (widget *appStatsWidget) fetchAppStoreStats(app *appStatsApp, report *appStoreDownloadsReport) (*appStats, error) {
+	query := url.Values{"id": {app.AppStoreID}, "country": {app.Country}}
+	request, _ := http.NewRequest("GET", iTunesLookupURL+"?"+query.Encode(), nil)
+
+	response, err := decodeJsonFromRequest[iTunesLookupResponseJson](widget.httpClient(false), request)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Results) == 0 {
+		return nil, fmt.Errorf("app %s not found in the %s App Store", app.AppStoreID, app.Country)
+	}
+
+	result := &response.Results[0]
+
+	stats := &appStats{
+		Name:        ternary(app.Name != "", app.Name, result.TrackName),
+		Platform:    "App Store",
+		URL:         result.TrackViewURL,
+		IconURL:     result.ArtworkURL100,
+		HasRating:   result.UserRatingCount > 0,
+		Rating:      result.AverageUserRating,
+		RatingCount: result.UserRatingCount,
+	}
+
+	// the App Store only provides the current ratings so the ones from the
+	// previous day are kept track of in order to show how they changed
+	stateKey := "app-stats:" + seenItemID(app.AppStoreID+"#"+app.Country)
+	today := time.Now().Format("2006-01-02")
+
+	var state appStatsRatingState
+	exists := widget.Providers.state.get(stateKey, &state)
+
+	if exists && state.Day != today {
+		state.PreviousRating, state.PreviousRatingCount = state.Rating, state.RatingCount
+	}
+
+	state.Day, state.Rating, state.RatingCount = today, stats.Rating, stats.RatingCount
+	widget.Providers.state.set(stateKey, state)
+
+	if state.PreviousRatingCount > 0 {
+		stats.PreviousRating = state.PreviousRating
+		stats.NewRatings = stats.RatingCount - state.PreviousRatingCount
+	}
+
+	if report != nil {
+		stats.HasDownloads = true
+		stats.DownloadsDate = report.date
+		stats.Downloads = report.current[app.AppStoreID]
+		stats.PreviousDownloads = report.previous[app.AppStoreID]
+	}
+
+	return stats, nil
+}
+
+// Daily reports are dated in Pacific time and are usually published the next
+// morning, so the most recent one is either from yesterday or the day before
+func (widget *appStatsWidget) fetchAppStoreDownloads() (*appStoreDownloadsReport, error) {
+	pacific, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().In(pacific)
+	date := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, pacific)
+
+	reports := make(map[string]map[string]int, 2)
+	fetch := func(date time.Time) (map[string]int, error) {
+		key := date.Format("2006-01-02")
+		if downloads, ok := widget.appStoreReports[key]; ok {
+			reports[key] = downloads
+			return downloads, nil
+		}
+
+		downloads, err := widget.fetchAppStoreSalesReport(key)
+		if err == nil {
+			reports[key] = downloads
+		}
+
+		return downloads, err
+	}
+
+	current, err := fetch(date)
+	if errors.Is(err, errAppStoreReportNotAvailable) {
+		date = date.AddDate(0, 0, -1)
+		current, err = fetch(date)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := fetch(date.AddDate(0, 0, -1))
+	if err != nil && !errors.Is(err, errAppStoreReportNotAvailable) {
+		return nil, err
+	}
+
+	// only keep the reports that are still in use
+	widget.appStoreReports = reports
+
+	return &appStoreDownloadsReport{date: date, current: current, previous: previous}, nil
+}
+
+var errAppStoreReportNotAvailable = errors.New("report is not available yet")
+
+// Returns the number of first time downloads of each app keyed by their Apple ID
+func (widget *appStatsWidget) fetchAppStoreSalesReport(date string) (map[string]int, error) {
+	now := time.Now()
+	token, err := signJWT(widget.appStorePrivateKey, map[string]any{"kid": widget.AppStore.KeyID}, map[string]any{
+		"iss": widget.AppStore.IssuerID,
+		"iat": now.Unix(),
+		"exp": now.Add(10 * time.Minute).Unix(),
+		"aud": "appstoreconnect-v1",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("signing token: %v", err)
+	}
+
+	query := url.Values{
+		"filter[frequency]":     {"DAILY"},
+		"filter[reportDate]":    {date},
+		"filter[reportSubType]": {"SUMMARY"},
+		"filter[reportType]":    {"SALES"},
+		"filter[vendorNumber]":  {widget.AppStore.VendorNumber},
+		"filter[version]":       {"1_0"},
+	}
+
+	request, _ := http.NewRequest("GET", appStoreConnectAPIURL+"/salesReports?"+query.Encode(), nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("Accept", "application/a-gzip")
+
+	response, err := widget.httpClient(false).Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, errAppStoreReportNotAvailable
+	}
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+		return nil, fmt.Errorf("unexpected status code %d for sales report, response: %s", response.StatusCode, body)
+	}
+
+	reader, err := gzip.NewReader(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing sales report: %v", err)
+	}
+
+	rows, err := readCSVWithHeader(reader, '\t')
+	if err != nil {
+		return nil, fmt.Errorf("parsing sales report: %v", err)
+	}
+
+	downloads := make(map[string]int)
+
+	for _, row := range rows {
+		if !appStoreDownloadProductTypes[row["Product Type Identifier"]] {
+			continue
+		}
+
+		units, _ := strconv.ParseFloat(row["Units"], 64)
+		downloads[row["Apple Identifier"]] += int(units)
+	}
+
+	return downloads, nil
+}
+
+// Statistics for Google Play are only available as monthly CSV reports in the
+// Cloud Storage bucket of the developer account, which get updated daily
+func (widget *appStatsWidget) fetchGooglePlayStats(app *appStatsApp) (*appStats, error) {
+	stats := &appStats{
+		Name:     ternary(app.Name != "", app.Name, app.Package),
+		Platform: "Google Play",
+		URL:      "https://play.google.com/store/apps/details?id=" + url.QueryEscape(app.Package),
+	}
+
+	installs, err := widget.fetchGooglePlayReport("installs", app.Package)
+	if err != nil {
+		return nil, fmt.Errorf("fetching installs report: %v", err)
+	}
+
+	if len(installs) > 0 {
+		latest := installs[len(installs)-1]
+		column := ternary(latest["Daily User Installs"] != "", "Daily User Installs", "Daily Device Installs")
+
+		stats.HasDownloads = true
+		stats.DownloadsDate, _ = time.Parse("2006-01-02", latest["Date"])
+		stats.Downloads, _ = strconv.Atoi(latest[column])
+
+		if len(installs) > 1 {
+			stats.PreviousDownloads, _ = strconv.Atoi(installs[len(installs)-2][column])
+		}
+	}
+
+	ratings, err := widget.fetchGooglePlayReport("ratings", app.Package)
+	if err != nil {
+		return nil, fmt.Errorf("fetching ratings report: %v", err)
+	}
+
+	if len(ratings) > 0 {
+		stats.Rating, err = strconv.ParseFloat(ratings[len(ratings)-1]["Total Average Rating"], 64)
+		stats.HasRating = err == nil
+
+		if len(ratings) > 1 {
+			stats.PreviousRating, _ = strconv.ParseFloat(ratings[len(ratings)-2]["Total Average Rating"], 64)
+		}
+	}
+
+	return stats, nil
+}
+
+// Returns the rows of the overview report for the current month, along with the
+// ones from the previous month when needed to compare with the previous day
+func (widget *appStatsWidget) fetchGooglePlayReport(kind string, packageName string) ([]map[string]string, error) {
+	now := time.Now().UTC()
+	var rows []map[string]string
+
+	for i, month := range []time.Time{now, now.AddDate(0, 0, -now.Day())} {
+		object := fmt.Sprintf("stats/%s/%s_%s_%s_overview.csv", kind, kind, packageName, month.Format("200601"))
+
+		monthRows, err := widget.fetchGoogleStorageCSV(object)
+		// the report for the current month doesn't exist until its first day is over
+		if errors.Is(err, errGoogleStorageObjectNotFound) && i == 0 {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		rows = append(monthRows, rows...)
+		if len(rows) >= 2 {
+			break
+		}
+	}
+
+	return rows, nil
+}
+
+var errGoogleStorageObjectNotFound = errors.New("object not found")
+
+func (widget *appStatsWidget) fetchGoogleStorageCSV(object string) ([]map[string]string, error) {
+	token, err := widget.googlePlayToken.get()
+	if err != nil {
+		return nil, err
+	}
+
+	requestURL := fmt.Sprintf("%s/b/%s/o/%s?alt=media", googleStorageAPIURL, url.PathEscape(widget.GooglePlay.Bucket), url.PathEscape(object))
+	request, _ := http.NewRequest("GET", requestURL, nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	response, err := widget.httpClient(false).Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errGoogleStorageObjectNotFound
+	case http.StatusUnauthorized:
+		widget.googlePlayToken.invalidate()
+		fallthrough
+	default:
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+		return nil, fmt.Errorf("unexpected status code %d for %s, response: %s", response.StatusCode, object, body)
+	}
+
+	// the reports are encoded as UTF-16 with a byte order mark
+	decoder := unicode.BOMOverride(unicode.UTF8.NewDecoder())
+
+	return readCSVWithHeader(transform.NewReader(response.Body, decoder), ',')
+}
+
+// Returns each row as a map of the column names from the first row to their values
+func readCSVWithHeader(r io.Reader, separator rune) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = separator
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []map[string]string
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]string, len(header))
+		for i := range header {
+			if i < len(record) {
+				row[strings.TrimSpace(header[i])] = strings.TrimSpace(record[i])
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,433 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+var arrWidgetTemplate = mustParseTemplate("arr.html", "widget-base.html")
+
+type arrWidget struct {
+	widgetBase      `yaml:",inline"`
+	Instances       []arrInstance `yaml:"instances"`
+	Days            int           `yaml:"days"`
+	HideDownloads   bool          `yaml:"hide-downloads"`
+	Limit           int           `yaml:"limit"`
+	CollapseAfter   int           `yaml:"collapse-after"`
+	Upcoming        []arrRelease  `yaml:"-"`
+	RecentDownloads []arrRelease  `yaml:"-"`
+}
+
+type arrInstance struct {
+	Type          string `yaml:"type"`
+	Name          string `yaml:"name"`
+	URL           string `yaml:"url"`
+	APIKey        string `yaml:"api-key"`
+	AllowInsecure bool   `yaml:"allow-insecure"`
+	httpClient    requestDoer
+}
+
+type arrRelease struct {
+	Title     string
+	Subtitle  string
+	URL       string
+	PosterURL string
+	Instance  string
+	Time      time.Time
+	HasFile   bool
+}
+
+func (widget *arrWidget) initialize() error {
+	widget.withTitle("Upcoming").withCacheDuration(30 * time.Minute)
+
+	if len(widget.Instances) == 0 {
+		return errors.New("no instances specified")
+	}
+
+	for i := range widget.Instances {
+		instance := &widget.Instances[i]
+
+		if instance.Type != "sonarr" && instance.Type != "radarr" {
+			return fmt.Errorf("instance %d: type must be either sonarr or radarr", i+1)
+		}
+
+		if instance.URL == "" {
+			return fmt.Errorf("instance %d: url is required", i+1)
+		}
+
+		if instance.APIKey == "" {
+			return fmt.Errorf("instance %d: api-key is required", i+1)
+		}
+
+		instance.URL = strings.TrimRight(instance.URL, "/")
+		instance.httpClient = widget.httpClient(instance.AllowInsecure)
+
+		if instance.Name == "" {
+			instance.Name = strings.ToUpper(instance.Type[:1]) + instance.Type[1:]
+		}
+	}
+
+	if widget.Days <= 0 {
+		widget.Days = 7
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 10
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *arrWidget) update(ctx context.Context) {
+	upcoming, downloads, err := fetchArrReleases(widget.Instances, widget.Days, widget.Limit, !widget.HideDownloads)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	// the name of the instance is only useful to tell releases from different instances apart
+	if len(widget.Instances) == 1 {
+		for i := range upcoming {
+			upcoming[i].Instance = ""
+		}
+
+		for i := range downloads {
+			downloads[i].Instance = ""
+		}
+	}
+
+	widget.Upcoming = upcoming
+	widget.RecentDownloads = downloads
+}
+
+func (widget *arrWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, arrWidgetTemplate)
+}
+
+type arrImageJson struct {
+	CoverType string `json:"coverType"`
+	RemoteURL string `json:"remoteUrl"`
+}
+
+func arrPosterURL(images []arrImageJson) string {
+	for i := range images {
+		if images[i].CoverType == "poster" {
+			return images[i].RemoteURL
+		}
+	}
+
+	return ""
+}
+
+type sonarrSeriesJson struct {
+	Title     string         `json:"title"`
+	TitleSlug string         `json:"titleSlug"`
+	Images    []arrImageJson `json:"images"`
+}
+
+type sonarrEpisodeJson struct {
+	Title         string           `json:"title"`
+	SeasonNumber  int              `json:"seasonNumber"`
+	EpisodeNumber int              `json:"episodeNumber"`
+	AirDateUtc    string           `json:"airDateUtc"`
+	HasFile       bool             `json:"hasFile"`
+	Series        sonarrSeriesJson `json:"series"`
+}
+
+type radarrMovieJson struct {
+	Title           string         `json:"title"`
+	Year            int            `json:"year"`
+	TitleSlug       string         `json:"titleSlug"`
+	InCinemas       string         `json:"inCinemas"`
+	DigitalRelease  string         `json:"digitalRelease"`
+	PhysicalRelease string         `json:"physicalRelease"`
+	HasFile         bool           `json:"hasFile"`
+	Images          []arrImageJson `json:"images"`
+}
+
+type arrHistoryResponseJson struct {
+	Records []struct {
+		Date        string             `json:"date"`
+		SourceTitle string             `json:"sourceTitle"`
+		Series      *sonarrSeriesJson  `json:"series"`
+		Episode     *sonarrEpisodeJson `json:"episode"`
+		Movie       *radarrMovieJson   `json:"movie"`
+	} `json:"records"`
+}
+
+func (instance *arrInstance) newRequest(path string, query url.Values) (*http.Request, error) {
+	request, err := http.NewRequest("GET", instance.URL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("X-Api-Key", instance.APIKey)
+
+	return request, nil
+}
+
+func (instance *arrInstance) client() requestDoer {
+	return instance.httpClient
+}
+
+func sonarrEpisodeSubtitle(episode *sonarrEpisodeJson) string {
+	subtitle := formatEpisodeNumber(episode.SeasonNumber, episode.EpisodeNumber)
+
+	if episode.Title != "" && episode.Title != "TBA" {
+		subtitle += " · " + episode.Title
+	}
+
+	return subtitle
+}
+
+func (instance *arrInstance) seriesURL(series *sonarrSeriesJson) string {
+	if series.TitleSlug == "" {
+		return instance.URL
+	}
+
+	return instance.URL + "/series/" + series.TitleSlug
+}
+
+func (instance *arrInstance) movieURL(movie *radarrMovieJson) string {
+	if movie.TitleSlug == "" {
+		return instance.URL
+	}
+
+	return instance.URL + "/movie/" + movie.TitleSlug
+}
+
+// Movies have multiple release dates, the one shown is the earliest one within the range
+func radarrReleaseDate(movie *radarrMovieJson, start, end time.Time) time.Time {
+	var earliest time.Time
+
+	for _, value := range []string{movie.InCinemas, movie.DigitalRelease, movie.PhysicalRelease} {
+		if value == "" {
+			continue
+		}
+
+		date, err := time.Parse(time.RFC3339, value)
+		if err != nil || date.Before(start) || date.After(end) {
+			continue
+		}
+
+		if earliest.IsZero() || date.Before(earliest) {
+			earliest = date
+		}
+	}
+
+	return earliest
+}
+
+func (instance *arrInstance) fetchUpcoming(days int) ([]arrRelease, error) {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	end := start.AddDate(0, 0, days)
+
+	query := url.Values{
+		"start":         {start.UTC().Format(time.RFC3339)},
+		"end":           {end.UTC().Format(time.RFC3339)},
+		"includeSeries": {"true"},
+	}
+
+	request, err := instance.newRequest("/api/v3/calendar", query)
+	if err != nil {
+		return nil, err
+	}
+
+	if instance.Type == "sonarr" {
+		episodes, err := decodeJsonFromRequest[[]sonarrEpisodeJson](instance.client(), request)
+		if err != nil {
+			return nil, err
+		}
+
+		releases := make([]arrRelease, 0, len(episodes))
+
+		for i := range episodes {
+			episode := &episodes[i]
+
+			releases = append(releases, arrRelease{
+				Title:     episode.Series.Title,
+				Subtitle:  sonarrEpisodeSubtitle(episode),
+				URL:       instance.seriesURL(&episode.Series),
+				PosterURL: arrPosterURL(episode.Series.Images),
+				Instance:  instance.Name,
+				Time:      parseRFC3339Time(episode.AirDateUtc),
+				HasFile:   episode.HasFile,
+			})
+		}
+
+		return releases, nil
+	}
+
+	movies, err := decodeJsonFromRequest[[]radarrMovieJson](instance.client(), request)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]arrRelease, 0, len(movies))
+
+	for i := range movies {
+		movie := &movies[i]
+
+		releaseDate := radarrReleaseDate(movie, start, end)
+		if releaseDate.IsZero() {
+			continue
+		}
+
+		releases = append(releases, arrRelease{
+			Title:     movie.Title,
+			Subtitle:  ternary(movie.Year > 0, fmt.Sprint(movie.Year), ""),
+			URL:       instance.movieURL(movie),
+			PosterURL: arrPosterURL(movie.Images),
+			Instance:  instance.Name,
+			Time:      releaseDate,
+			HasFile:   movie.HasFile,
+		})
+	}
+
+	return releases, nil
+}
+
+func (instance *arrInstance) fetchRecentDownloads(limit int) ([]arrRelease, error) {
+	query := url.Values{
+		"page":          {"1"},
+		"pageSize":      {fmt.Sprint(limit)},
+		"sortKey":       {"date"},
+		"sortDirection": {"descending"},
+		// downloadFolderImported
+		"eventType":      {"3"},
+		"includeSeries":  {"true"},
+		"includeEpisode": {"true"},
+		"includeMovie":   {"true"},
+	}
+
+	request, err := instance.newRequest("/api/v3/history", query)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeJsonFromRequest[arrHistoryResponseJson](instance.client(), request)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]arrRelease, 0, len(response.Records))
+
+	for i := range response.Records {
+		record := &response.Records[i]
+
+		release := arrRelease{
+			Title:    record.SourceTitle,
+			URL:      instance.URL,
+			Instance: instance.Name,
+			Time:     parseRFC3339Time(record.Date),
+		}
+
+		if record.Series != nil {
+			release.Title = record.Series.Title
+			release.URL = instance.seriesURL(record.Series)
+			release.PosterURL = arrPosterURL(record.Series.Images)
+
+			if record.Episode != nil {
+				release.Subtitle = sonarrEpisodeSubtitle(record.Episode)
+			}
+		} else if record.Movie != nil {
+			release.Title = record.Movie.Title
+			release.Subtitle = ternary(record.Movie.Year > 0, fmt.Sprint(record.Movie.Year), "")
+			release.URL = instance.movieURL(record.Movie)
+			release.PosterURL = arrPosterURL(record.Movie.Images)
+		}
+
+		releases = append(releases, release)
+	}
+
+	return releases, nil
+}
+
+func fetchArrReleases(instances []arrInstance, days int, limit int, includeDownloads bool) ([]arrRelease, []arrRelease, error) {
+	type result struct {
+		upcoming  []arrRelease
+		downloads []arrRelease
+	}
+
+	task := func(instance *arrInstance) (result, error) {
+		var r result
+		var err error
+
+		r.upcoming, err = instance.fetchUpcoming(days)
+		if err != nil {
+			return r, fmt.Errorf("fetching calendar: %v", err)
+		}
+
+		if includeDownloads {
+			r.downloads, err = instance.fetchRecentDownloads(limit)
+			if err != nil {
+				return r, fmt.Errorf("fetching history: %v", err)
+			}
+		}
+
+		return r, nil
+	}
+
+	requests := make([]*arrInstance, len(instances))
+	for i := range instances {
+		requests[i] = &instances[i]
+	}
+
+	job := newJob(task, requests).withWorkers(len(requests))
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	var upcoming, downloads []arrRelease
+	var failed int
+
+	for i := range results {
+		if errs[i] != nil {
+			failed++
+			slog.Error("Failed to fetch releases", "instance", instances[i].Name, "url", instances[i].URL, "error", errs[i])
+		}
+
+		upcoming = append(upcoming, results[i].upcoming...)
+		downloads = append(downloads, results[i].downloads...)
+	}
+
+	if failed == len(instances) {
+		return nil, nil, fmt.Errorf("%w: %v", errNoContent, errs[0])
+	}
+
+	sort.SliceStable(upcoming, func(i, j int) bool {
+		return upcoming[i].Time.Before(upcoming[j].Time)
+	})
+
+	sort.SliceStable(downloads, func(i, j int) bool {
+		return downloads[i].Time.After(downloads[j].Time)
+	})
+
+	if len(upcoming) > limit {
+		upcoming = upcoming[:limit]
+	}
+
+	if len(downloads) > limit {
+		downloads = downloads[:limit]
+	}
+
+	if failed > 0 {
+		return upcoming, downloads, fmt.Errorf("%w: could not fetch releases from %d instances", errPartialContent, failed)
+	}
+
+	return upcoming, downloads, nil
+}
@@ -0,0 +1,350 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/v4/net"
+)
+
+var bandwidthWidgetTemplate = mustParseTemplate("bandwidth.html", "widget-base.html")
+
+type bandwidthWidget struct {
+	widgetBase    `yaml:",inline"`
+	Source        string             `yaml:"source"`
+	Interface     string             `yaml:"interface"`
+	URL           string             `yaml:"url"`
+	Token         string             `yaml:"token"`
+	AllowInsecure bool               `yaml:"allow-insecure"`
+	RunEvery      durationField      `yaml:"run-every"`
+	History       int                `yaml:"history"`
+	Latest        *bandwidthSample   `yaml:"-"`
+	DownloadChart string             `yaml:"-"`
+	UploadChart   string             `yaml:"-"`
+	PeakDownload  float64            `yaml:"-"`
+	PeakUpload    float64            `yaml:"-"`
+	samples       []bandwidthSample  `yaml:"-"`
+	samplesMutex  sync.Mutex         `yaml:"-"`
+	lastCounters  *bandwidthCounters `yaml:"-"`
+	lastTestRun   time.Time          `yaml:"-"`
+}
+
+type bandwidthSample struct {
+	Time time.Time
+	// bits per second
+	Download float64
+	Upload   float64
+	// only set for speed tests
+	Ping float64
+}
+
+type bandwidthCounters struct {
+	time     time.Time
+	received uint64
+	sent     uint64
+}
+
+func (widget *bandwidthWidget) initialize() error {
+	switch widget.Source {
+	case "interface":
+		widget.withTitle("Bandwidth").withCacheDuration(time.Minute)
+	case "speedtest-tracker":
+		widget.withTitle("Speedtest").withCacheDuration(10 * time.Minute)
+
+		if widget.URL == "" {
+			return errors.New("url is required")
+		}
+
+		if widget.Token == "" {
+			return errors.New("token is required")
+		}
+
+		widget.URL = strings.TrimRight(widget.URL, "/")
+	default:
+		return errors.New("source must be either interface or speedtest-tracker")
+	}
+
+	if widget.RunEvery > 0 && widget.Source != "speedtest-tracker" {
+		return errors.New("run-every can only be used with speedtest-tracker")
+	}
+
+	if widget.History <= 0 {
+		widget.History = ternary(widget.Source == "interface", 60, 24)
+	}
+
+	return nil
+}
+
+func (widget *bandwidthWidget) update(ctx context.Context) {
+	var samples []bandwidthSample
+	var err error
+
+	if widget.Source == "speedtest-tracker" {
+		samples, err = widget.fetchSpeedtestResults()
+		if err != nil {
+			err = fmt.Errorf("%w: %v", errNoContent, err)
+		}
+	} else {
+		widget.samplesMutex.Lock()
+		samples = make([]bandwidthSample, len(widget.samples))
+		copy(samples, widget.samples)
+		widget.samplesMutex.Unlock()
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Latest = nil
+	widget.PeakDownload, widget.PeakUpload = 0, 0
+
+	if len(samples) > 0 {
+		widget.Latest = &samples[len(samples)-1]
+	}
+
+	downloads := make([]float64, len(samples))
+	uploads := make([]float64, len(samples))
+
+	for i := range samples {
+		downloads[i] = samples[i].Download
+		uploads[i] = samples[i].Upload
+		widget.PeakDownload = max(widget.PeakDownload, samples[i].Download)
+		widget.PeakUpload = max(widget.PeakUpload, samples[i].Upload)
+	}
+
+	// both lines share the same scale so that they can be compared against each other
+	peak := max(widget.PeakDownload, widget.PeakUpload)
+	widget.DownloadChart = bandwidthChartPoints(downloads, peak)
+	widget.UploadChart = bandwidthChartPoints(uploads, peak)
+}
+
+func bandwidthChartPoints(values []float64, peak float64) string {
+	if len(values) < 2 {
+		return ""
+	}
+
+	const width, height = 100, 50
+	coordinates := make([]string, len(values))
+
+	for i := range values {
+		y := float64(height)
+		if peak > 0 {
+			y -= values[i] / peak * height * 0.96
+		}
+
+		coordinates[i] = fmt.Sprintf("%.2f,%.2f", float64(i)*width/float64(len(values)-1), y)
+	}
+
+	return strings.Join(coordinates, " ")
+}
+
+func (widget *bandwidthWidget) runBackgroundTask(now time.Time) {
+	if widget.Source == "interface" {
+		widget.sampleInterface(now)
+		return
+	}
+
+	if widget.RunEvery <= 0 {
+		return
+	}
+
+	// don't trigger a test as soon as Glance starts since it may be restarted often
+	if widget.lastTestRun.IsZero() {
+		widget.lastTestRun = now
+		return
+	}
+
+	if now.Sub(widget.lastTestRun) < time.Duration(widget.RunEvery) {
+		return
+	}
+
+	widget.lastTestRun = now
+
+	if err := widget.runSpeedtest(); err != nil {
+		widget.logger().Error("Failed to start speed test", "url", widget.URL, "error", err)
+	}
+}
+
+func (widget *bandwidthWidget) sampleInterface(now time.Time) {
+	stats, err := psnet.IOCounters(true)
+	if err != nil {
+		widget.logger().Error("Failed to read network interface counters", "error", err)
+		return
+	}
+
+	var counters *bandwidthCounters
+
+	for i := range stats {
+		// when no interface is specified the traffic of all of them except for loopback is combined
+		if (widget.Interface == "" && stats[i].Name != "lo") || stats[i].Name == widget.Interface {
+			if counters == nil {
+				counters = &bandwidthCounters{time: now}
+			}
+
+			counters.received += stats[i].BytesRecv
+			counters.sent += stats[i].BytesSent
+		}
+	}
+
+	if counters == nil {
+		widget.logger().Error("Network interface not found", "interface", widget.Interface)
+		return
+	}
+
+	previous := widget.lastCounters
+	widget.lastCounters = counters
+
+	// counters reset when the interface goes down, which would show up as a huge spike
+	if previous == nil || counters.received < previous.received || counters.sent < previous.sent {
+		return
+	}
+
+	seconds := counters.time.Sub(previous.time).Seconds()
+	if seconds <= 0 {
+		return
+	}
+
+	sample := bandwidthSample{
+		Time:     now,
+		Download: float64(counters.received-previous.received) * 8 / seconds,
+		Upload:   float64(counters.sent-previous.sent) * 8 / seconds,
+	}
+
+	widget.samplesMutex.Lock()
+	defer widget.samplesMutex.Unlock()
+
+	widget.samples = append(widget.samples, sample)
+	if len(widget.samples) > widget.History {
+		widget.samples = widget.samples[len(widget.samples)-widget.History:]
+	}
+}
+
+func (widget *bandwidthWidget) newSpeedtestRequest(method, path string) (*http.Request, error) {
+	request, err := http.NewRequest(method, widget.URL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+widget.Token)
+	request.Header.Set("Accept", "application/json")
+
+	return request, nil
+}
+
+type speedtestTrackerResultJson struct {
+	Status       string  `json:"status"`
+	Ping         float64 `json:"ping"`
+	DownloadBits float64 `json:"download_bits"`
+	UploadBits   float64 `json:"upload_bits"`
+	CreatedAt    string  `json:"created_at"`
+}
+
+func (widget *bandwidthWidget) fetchSpeedtestResults() ([]bandwidthSample, error) {
+	request, err := widget.newSpeedtestRequest("GET", "/api/v1/results?sort=-created_at&per_page="+strconv.Itoa(widget.History))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeJsonFromRequest[struct {
+		Data []speedtestTrackerResultJson `json:"data"`
+	}](widget.httpClient(widget.AllowInsecure), request)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]bandwidthSample, 0, len(response.Data))
+
+	// results are sorted newest first while the chart goes from oldest to newest
+	for i := len(response.Data) - 1; i >= 0; i-- {
+		result := &response.Data[i]
+
+		if result.Status != "" && result.Status != "completed" {
+			continue
+		}
+
+		samples = append(samples, bandwidthSample{
+			Time:     parseSpeedtestTrackerTime(result.CreatedAt),
+			Download: result.DownloadBits,
+			Upload:   result.UploadBits,
+			Ping:     result.Ping,
+		})
+	}
+
+	return samples, nil
+}
+
+func parseSpeedtestTrackerTime(value string) time.Time {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+
+	t, _ := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+	return t
+}
+
+func (widget *bandwidthWidget) runSpeedtest() error {
+	request, err := widget.newSpeedtestRequest("POST", "/api/v1/speedtests/run")
+	if err != nil {
+		return err
+	}
+
+	response, err := widget.httpClient(widget.AllowInsecure).Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+func (widget *bandwidthWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, bandwidthWidgetTemplate)
+}
+
+func (s bandwidthSample) FormattedDownload() string {
+	return formatBitrate(s.Download)
+}
+
+func (s bandwidthSample) FormattedUpload() string {
+	return formatBitrate(s.Upload)
+}
+
+func (widget *bandwidthWidget) FormattedPeakDownload() string {
+	return formatBitrate(widget.PeakDownload)
+}
+
+func (widget *bandwidthWidget) FormattedPeakUpload() string {
+	return formatBitrate(widget.PeakUpload)
+}
+
+func formatBitrate(bits float64) string {
+	const unit = 1000
+	if bits < unit {
+		return fmt.Sprintf("%.0f bps", bits)
+	}
+
+	suffixes := []string{"Kbps", "Mbps", "Gbps"}
+	i := -1
+
+	for bits >= unit && i < len(suffixes)-1 {
+		bits /= unit
+		i++
+	}
+
+	if bits < 100 {
+		return fmt.Sprintf("%.1f %s", bits, suffixes[i])
+	}
+
+	return fmt.Sprintf("%.0f %s", bits, suffixes[i])
+}
@@ -1,76 +1,527 @@
 package glance
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
 var bookmarksWidgetTemplate = mustParseTemplate("bookmarks.html", "widget-base.html")
 
+const (
+	bookmarksServicesInterval = time.Hour
+	bookmarksStatusInterval   = 5 * time.Minute
+	bookmarksFaviconMaxAge    = 24 * time.Hour
+	// favicons that couldn't be found get looked for again after this long
+	bookmarksFaviconRetryAfter = time.Hour
+	bookmarksFaviconMaxSize    = 512 * 1024
+	bookmarksPageMaxSize       = 1024 * 1024
+)
+
 type bookmarksWidget struct {
 	widgetBase `yaml:",inline"`
 	cachedHTML template.HTML `yaml:"-"`
 	Groups     []struct {
-		Title     string         `yaml:"title"`
-		Color     *hslColorField `yaml:"color"`
-		SameTab   bool           `yaml:"same-tab"`
-		HideArrow bool           `yaml:"hide-arrow"`
-		Target    string         `yaml:"target"`
-		Links     []struct {
-			Title string          `yaml:"title"`
-			URL   string          `yaml:"url"`
-			Icon  customIconField `yaml:"icon"`
-			// we need a pointer to bool to know whether a value was provided,
-			// however there's no way to dereference a pointer in a template so
-			// {{ if not .SameTab }} would return true for any non-nil pointer
-			// which leaves us with no way of checking if the value is true or
-			// false, hence the duplicated fields below
-			SameTabRaw   *bool  `yaml:"same-tab"`
-			SameTab      bool   `yaml:"-"`
-			HideArrowRaw *bool  `yaml:"hide-arrow"`
-			HideArrow    bool   `yaml:"-"`
-			Target       string `yaml:"target"`
-		} `yaml:"links"`
+		Title       string         `yaml:"title"`
+		Color       *hslColorField `yaml:"color"`
+		SameTab     bool           `yaml:"same-tab"`
+		HideArrow   bool           `yaml:"hide-arrow"`
+		Target      string         `yaml:"target"`
+		Favicons    bool           `yaml:"favicons"`
+		CheckStatus bool           `yaml:"check-status"`
+		// the links of the group come from a bookmark service rather than the config
+		Service    string         `yaml:"service"`
+		Collection string         `yaml:"collection"`
+		Limit      int            `yaml:"limit"`
+		Links      []bookmarkLink `yaml:"links"`
 	} `yaml:"groups"`
+	servicesUpdatedAt time.Time `yaml:"-"`
+
+	// read when serving favicons, which doesn't hold the lock of the page
+	faviconsMu sync.Mutex
+	favicons   map[string]*bookmarkFavicon
+}
+
+type bookmarkLink struct {
+	Title string          `yaml:"title"`
+	URL   string          `yaml:"url"`
+	Icon  customIconField `yaml:"icon"`
+	// we need a pointer to bool to know whether a value was provided,
+	// however there's no way to dereference a pointer in a template so
+	// {{ if not .SameTab }} would return true for any non-nil pointer
+	// which leaves us with no way of checking if the value is true or
+	// false, hence the duplicated fields below
+	SameTabRaw     *bool       `yaml:"same-tab"`
+	SameTab        bool        `yaml:"-"`
+	HideArrowRaw   *bool       `yaml:"hide-arrow"`
+	HideArrow      bool        `yaml:"-"`
+	Target         string      `yaml:"target"`
+	CheckStatusRaw *bool       `yaml:"check-status"`
+	CheckURL       string      `yaml:"check-url"`
+	AllowInsecure  bool        `yaml:"allow-insecure"`
+	Status         *siteStatus `yaml:"-"`
+	StatusText     string      `yaml:"-"`
+	StatusFailed   bool        `yaml:"-"`
+	statusRequest  *SiteStatusRequest
+	// the icon gets looked for on the page that the link points to
+	autoIcon bool
+}
+
+type bookmarkFavicon struct {
+	// nil when no favicon could be found
+	data        []byte
+	contentType string
+	fetchedAt   time.Time
 }
 
 func (widget *bookmarksWidget) initialize() error {
 	widget.withTitle("Bookmarks").withError(nil)
 
+	fromServices := false
+
+	for g := range widget.Groups {
+		group := &widget.Groups[g]
+
+		if group.Service != "" {
+			if len(group.Links) > 0 {
+				return fmt.Errorf("group %s: links can't be set when using a service", group.Title)
+			}
+
+			if group.Limit <= 0 {
+				group.Limit = 10
+			}
+
+			fromServices = true
+			continue
+		}
+
+		if group.Collection != "" {
+			return fmt.Errorf("group %s: collection requires a service to be set", group.Title)
+		}
+
+		widget.applyLinkDefaults(g)
+	}
+
+	checksStatus, fetchesFavicons := false, false
 	for g := range widget.Groups {
 		group := &widget.Groups[g]
+		checksStatus = checksStatus || group.CheckStatus
+		fetchesFavicons = fetchesFavicons || group.Favicons
+
 		for l := range group.Links {
-			link := &group.Links[l]
-			if link.SameTabRaw == nil {
-				link.SameTab = group.SameTab
+			checksStatus = checksStatus || group.Links[l].statusRequest != nil
+			fetchesFavicons = fetchesFavicons || group.Links[l].autoIcon
+		}
+	}
+
+	if fetchesFavicons {
+		widget.favicons = make(map[string]*bookmarkFavicon)
+	}
+
+	if checksStatus {
+		widget.withCacheDuration(bookmarksStatusInterval)
+		return nil
+	}
+
+	if fromServices || fetchesFavicons {
+		widget.withCacheDuration(time.Hour)
+		return nil
+	}
+
+	widget.cachedHTML = widget.renderTemplate(widget, bookmarksWidgetTemplate)
+
+	return nil
+}
+
+func (widget *bookmarksWidget) applyLinkDefaults(g int) {
+	group := &widget.Groups[g]
+
+	for l := range group.Links {
+		link := &group.Links[l]
+		if link.SameTabRaw == nil {
+			link.SameTab = group.SameTab
+		} else {
+			link.SameTab = *link.SameTabRaw
+		}
+
+		if link.HideArrowRaw == nil {
+			link.HideArrow = group.HideArrow
+		} else {
+			link.HideArrow = *link.HideArrowRaw
+		}
+
+		if link.Target == "" {
+			if group.Target != "" {
+				link.Target = group.Target
 			} else {
-				link.SameTab = *link.SameTabRaw
+				if link.SameTab {
+					link.Target = ""
+				} else {
+					link.Target = "_blank"
+				}
 			}
+		}
 
-			if link.HideArrowRaw == nil {
-				link.HideArrow = group.HideArrow
-			} else {
-				link.HideArrow = *link.HideArrowRaw
+		if link.Icon.URL == "auto" || link.Icon.URL == "" && group.Favicons {
+			link.Icon = customIconField{}
+			link.autoIcon = true
+		}
+
+		if link.CheckStatusRaw == nil && group.CheckStatus || link.CheckStatusRaw != nil && *link.CheckStatusRaw {
+			link.statusRequest = &SiteStatusRequest{
+				DefaultURL:    link.URL,
+				CheckURL:      link.CheckURL,
+				AllowInsecure: link.AllowInsecure,
 			}
+			link.statusRequest.initialize()
+			link.statusRequest.client = widget.httpClient(link.AllowInsecure)
+		}
+	}
+}
 
-			if link.Target == "" {
-				if group.Target != "" {
-					link.Target = group.Target
-				} else {
-					if link.SameTab {
-						link.Target = ""
-					} else {
-						link.Target = "_blank"
+func (widget *bookmarksWidget) getBookmarkServices() []string {
+	var services []string
+
+	for g := range widget.Groups {
+		if widget.Groups[g].Service != "" {
+			services = append(services, widget.Groups[g].Service)
+		}
+	}
+
+	return services
+}
+
+// Only gets called when at least one of the groups uses a service, fetches
+// favicons or checks the status of its links
+func (widget *bookmarksWidget) update(ctx context.Context) {
+	err := widget.updateServiceGroups()
+	links := widget.links()
+
+	widget.updateFavicons(ctx, links)
+	widget.updateStatuses(links)
+
+	widget.canContinueUpdateAfterHandlingErr(err)
+}
+
+// Bookmarks get fetched every hour unless the cache duration was set, even
+// when the widget updates more often than that in order to check statuses.
+// The links of groups that fail to load are kept from the previous update.
+func (widget *bookmarksWidget) updateServiceGroups() error {
+	interval := ternary(widget.CustomCacheDuration > 0, time.Duration(widget.CustomCacheDuration), bookmarksServicesInterval)
+	if time.Since(widget.servicesUpdatedAt) < interval {
+		return nil
+	}
+
+	var failed, groups int
+	var lastErr error
+
+	for g := range widget.Groups {
+		group := &widget.Groups[g]
+		if group.Service == "" {
+			continue
+		}
+
+		groups++
+		service := widget.Providers.bookmarkServices[group.Service]
+
+		bookmarks, err := service.fetchBookmarks(widget.httpClient(false), group.Collection, group.Limit)
+		if err != nil {
+			failed++
+			lastErr = fmt.Errorf("%s: %w", group.Service, err)
+			continue
+		}
+
+		group.Links = make([]bookmarkLink, 0, len(bookmarks))
+		for _, bookmark := range bookmarks {
+			group.Links = append(group.Links, bookmarkLink{Title: bookmark.Title, URL: bookmark.URL})
+		}
+
+		widget.applyLinkDefaults(g)
+	}
+
+	if failed == 0 {
+		widget.servicesUpdatedAt = time.Now()
+		return nil
+	}
+
+	if failed == groups {
+		return lastErr
+	}
+
+	return fmt.Errorf("%w: could not load the bookmarks of %d groups", errPartialContent, failed)
+}
+
+func (widget *bookmarksWidget) links() []*bookmarkLink {
+	var links []*bookmarkLink
+
+	for g := range widget.Groups {
+		for l := range widget.Groups[g].Links {
+			links = append(links, &widget.Groups[g].Links[l])
+		}
+	}
+
+	return links
+}
+
+func bookmarkFaviconKey(link *bookmarkLink) string {
+	hash := sha256.Sum256([]byte(link.URL))
+	return hex.EncodeToString(hash[:8])
+}
+
+// Favicons are cached for a day, links that share the same URL share the same
+// favicon and links without one don't get an icon
+func (widget *bookmarksWidget) updateFavicons(ctx context.Context, links []*bookmarkLink) {
+	if widget.favicons == nil {
+		return
+	}
+
+	now := time.Now()
+	outdated := make([]*bookmarkLink, 0)
+	queued := make(map[string]bool)
+
+	widget.faviconsMu.Lock()
+	for _, link := range links {
+		if !link.autoIcon {
+			continue
+		}
+
+		key := bookmarkFaviconKey(link)
+		favicon, exists := widget.favicons[key]
+		if queued[key] || exists && now.Sub(favicon.fetchedAt) < ternary(favicon.data != nil, bookmarksFaviconMaxAge, bookmarksFaviconRetryAfter) {
+			continue
+		}
+
+		queued[key] = true
+		outdated = append(outdated, link)
+	}
+	widget.faviconsMu.Unlock()
+
+	job := newJob(func(link *bookmarkLink) (*bookmarkFavicon, error) {
+		return widget.fetchFavicon(ctx, link), nil
+	}, outdated).withWorkers(10)
+
+	favicons, _, _ := workerPoolDo(job)
+
+	widget.faviconsMu.Lock()
+	defer widget.faviconsMu.Unlock()
+
+	for i, link := range outdated {
+		if favicons[i] == nil {
+			continue
+		}
+
+		key := bookmarkFaviconKey(link)
+		// a favicon that went missing is still better than no icon at all
+		if previous, exists := widget.favicons[key]; exists && favicons[i].data == nil && previous.data != nil {
+			previous.fetchedAt = favicons[i].fetchedAt
+			continue
+		}
+
+		widget.favicons[key] = favicons[i]
+	}
+
+	for _, link := range links {
+		if !link.autoIcon {
+			continue
+		}
+
+		key := bookmarkFaviconKey(link)
+		if favicon, exists := widget.favicons[key]; exists && favicon.data != nil {
+			link.Icon = customIconField{URL: widget.Providers.widgetURLResolver(widget.GetID(), "favicon?key="+key)}
+		} else {
+			link.Icon = customIconField{}
+		}
+	}
+}
+
+// Looks for the icons that the page declares before falling back to
+// /favicon.ico, returns nil if the update got cancelled
+func (widget *bookmarksWidget) fetchFavicon(ctx context.Context, link *bookmarkLink) *bookmarkFavicon {
+	client := widget.httpClient(link.AllowInsecure)
+	candidates := findFaviconCandidates(ctx, client, link.URL)
+
+	for _, candidate := range candidates {
+		data, contentType, err := fetchFaviconImage(ctx, client, candidate)
+		if err == nil {
+			return &bookmarkFavicon{data: data, contentType: contentType, fetchedAt: time.Now()}
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+
+	widget.logger().Debug("Could not find a favicon", "url", link.URL)
+	return &bookmarkFavicon{fetchedAt: time.Now()}
+}
+
+func findFaviconCandidates(ctx context.Context, client requestDoer, pageURL string) []string {
+	base, err := url.Parse(pageURL)
+	if err != nil || base.Host == "" {
+		return nil
+	}
+
+	var icons, touchIcons []string
+
+	request, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err == nil {
+		setBrowserUserAgentHeader(request)
+
+		if response, err := client.Do(request); err == nil {
+			// the page may have redirected somewhere else
+			base = response.Request.URL
+			document, err := goquery.NewDocumentFromReader(io.LimitReader(response.Body, bookmarksPageMaxSize))
+			response.Body.Close()
+
+			if err == nil {
+				document.Find("link[rel][href]").Each(func(_ int, element *goquery.Selection) {
+					href, _ := element.Attr("href")
+					resolved, err := base.Parse(href)
+					if err != nil || resolved.Scheme != "http" && resolved.Scheme != "https" {
+						return
 					}
-				}
+
+					for _, rel := range strings.Fields(strings.ToLower(element.AttrOr("rel", ""))) {
+						if rel == "icon" {
+							icons = append(icons, resolved.String())
+							break
+						} else if rel == "apple-touch-icon" {
+							touchIcons = append(touchIcons, resolved.String())
+							break
+						}
+					}
+				})
 			}
 		}
 	}
 
-	widget.cachedHTML = widget.renderTemplate(widget, bookmarksWidgetTemplate)
+	candidates := append(icons, touchIcons...)
+	candidates = candidates[:min(len(candidates), 3)]
 
-	return nil
+	return append(candidates, base.ResolveReference(&url.URL{Path: "/favicon.ico"}).String())
+}
+
+func fetchFaviconImage(ctx context.Context, client requestDoer, iconURL string) ([]byte, string, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", iconURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	setBrowserUserAgentHeader(request)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, bookmarksFaviconMaxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(data) > bookmarksFaviconMaxSize {
+		return nil, "", errors.New("favicon is too large")
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, "", errors.New("favicon is empty")
+	}
+
+	// .ico files are often served with a generic content type
+	contentType := response.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(data)
+	}
+
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, "", fmt.Errorf("unexpected content type %s", contentType)
+	}
+
+	return data, contentType, nil
 }
 
+func (widget *bookmarksWidget) updateStatuses(links []*bookmarkLink) {
+	checked := make([]*bookmarkLink, 0)
+	requests := make([]*SiteStatusRequest, 0)
+
+	for _, link := range links {
+		if link.statusRequest != nil {
+			checked = append(checked, link)
+			requests = append(requests, link.statusRequest)
+		}
+	}
+
+	if len(requests) == 0 {
+		return
+	}
+
+	statuses, err := fetchStatusForSites(requests)
+	if err != nil {
+		return
+	}
+
+	for i, link := range checked {
+		status := statuses[i]
+		link.Status = &status
+		link.StatusFailed = siteCheckFailed(&status, nil)
+
+		switch {
+		case status.TimedOut:
+			link.StatusText = "Timed out"
+		case status.Error != nil:
+			link.StatusText, _ = limitStringLength(status.Error.Error(), 200)
+		case link.StatusFailed:
+			link.StatusText = fmt.Sprintf("%s (%d)", statusCodeToText(status.Code, nil), status.Code)
+		default:
+			link.StatusText = fmt.Sprintf("%s · %dms", statusCodeToText(status.Code, nil), status.ResponseTime.Milliseconds())
+		}
+	}
+}
+
+func (widget *bookmarksWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("path") != "favicon" || r.Method != http.MethodGet || widget.favicons == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	widget.faviconsMu.Lock()
+	favicon, exists := widget.favicons[r.URL.Query().Get("key")]
+	widget.faviconsMu.Unlock()
+
+	if !exists || favicon.data == nil {
+		http.Error(w, "favicon not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", favicon.contentType)
+	w.Header().Set("Cache-Control", imageProxyCacheControl)
+	// SVGs can contain scripts which would otherwise run as if they were part of Glance
+	w.Header().Set("Content-Security-Policy", imageProxyContentPolicy)
+	w.Write(favicon.data)
+}
+
+// The HTML is only cached when none of the groups use a service, fetch
+// favicons or check statuses
 func (widget *bookmarksWidget) Render() template.HTML {
+	if widget.cachedHTML == "" {
+		return widget.renderTemplate(widget, bookmarksWidgetTemplate)
+	}
+
 	return widget.cachedHTML
 }
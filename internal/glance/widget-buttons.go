@@ -0,0 +1,353 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var buttonsWidgetTemplate = mustParseTemplate("buttons.html", "widget-base.html")
+
+type buttonsWidget struct {
+	widgetBase    `yaml:",inline"`
+	HomeAssistant *homeAssistantConnection `yaml:"home-assistant"`
+	Buttons       []buttonsWidgetButton    `yaml:"buttons"`
+	// the states of Home Assistant entities get written to when a button
+	// gets pressed, which doesn't hold the lock of the page
+	statesMu sync.Mutex
+}
+
+type buttonsWidgetButton struct {
+	Label          string            `yaml:"label"`
+	Icon           customIconField   `yaml:"icon"`
+	Confirm        string            `yaml:"confirm"`
+	SuccessMessage string            `yaml:"success-message"`
+	URL            string            `yaml:"url"`
+	Method         string            `yaml:"method"`
+	Headers        map[string]string `yaml:"headers"`
+	Body           string            `yaml:"body"`
+	AllowInsecure  bool              `yaml:"allow-insecure"`
+	Command        []string          `yaml:"command"`
+	Timeout        durationField     `yaml:"timeout"`
+	// Home Assistant
+	Scene       string         `yaml:"scene"`
+	Toggle      string         `yaml:"toggle"`
+	Script      string         `yaml:"script"`
+	Service     string         `yaml:"service"`
+	Entity      string         `yaml:"entity"`
+	Data        map[string]any `yaml:"data"`
+	Index       int            `yaml:"-"`
+	HasState    bool           `yaml:"-"`
+	IsOn        bool           `yaml:"-"`
+	Unavailable bool           `yaml:"-"`
+}
+
+func (widget *buttonsWidget) initialize() error {
+	widget.withTitle("Actions").withError(nil)
+
+	if len(widget.Buttons) == 0 {
+		return errors.New("at least one button is required")
+	}
+
+	if widget.HomeAssistant != nil {
+		if err := widget.HomeAssistant.initialize(); err != nil {
+			return fmt.Errorf("home-assistant: %v", err)
+		}
+	}
+
+	hasStates := false
+
+	for i := range widget.Buttons {
+		button := &widget.Buttons[i]
+		button.Index = i
+
+		if button.Label == "" {
+			return fmt.Errorf("button %d: label is required", i+1)
+		}
+
+		actions := 0
+		for _, set := range []bool{button.URL != "", len(button.Command) > 0, button.Scene != "", button.Toggle != "", button.Script != "", button.Service != ""} {
+			if set {
+				actions++
+			}
+		}
+
+		if actions != 1 {
+			return fmt.Errorf("button %s: exactly one of url, command, scene, toggle, script or service is required", button.Label)
+		}
+
+		if err := button.initializeHomeAssistantAction(); err != nil {
+			return fmt.Errorf("button %s: %v", button.Label, err)
+		}
+
+		if button.Service != "" && widget.HomeAssistant == nil {
+			return fmt.Errorf("button %s: home-assistant is required to call services", button.Label)
+		}
+
+		hasStates = hasStates || button.HasState
+
+		if len(button.Command) > 0 && button.Command[0] == "" {
+			return fmt.Errorf("button %s: command is empty", button.Label)
+		}
+
+		if button.URL != "" {
+			button.Method = strings.ToUpper(button.Method)
+			if button.Method == "" {
+				button.Method = http.MethodPost
+			}
+		}
+
+		if button.Timeout <= 0 {
+			button.Timeout = durationField(30 * time.Second)
+		}
+
+		if button.Confirm == "true" {
+			button.Confirm = button.Label + "?"
+		} else if button.Confirm == "false" {
+			button.Confirm = ""
+		}
+	}
+
+	if hasStates {
+		widget.withCacheDuration(time.Minute)
+	}
+
+	return nil
+}
+
+// Scenes, toggles and scripts are shorthands for calling the service that
+// each of them needs, which is what the button gets set up to do
+func (button *buttonsWidgetButton) initializeHomeAssistantAction() error {
+	switch {
+	case button.Scene != "":
+		button.Service, button.Entity = "scene.turn_on", button.Scene
+	case button.Toggle != "":
+		if !isHomeAssistantToggleable(button.Toggle) {
+			return fmt.Errorf("toggling is only supported for the %s domains", strings.Join(homeAssistantToggleableDomains, ", "))
+		}
+
+		domain, _, _ := strings.Cut(button.Toggle, ".")
+		button.Service, button.Entity = domain+".toggle", button.Toggle
+	case button.Script != "":
+		if !strings.HasPrefix(button.Script, "script.") {
+			button.Script = "script." + button.Script
+		}
+
+		button.Service, button.Entity = "script.turn_on", button.Script
+	case button.Service != "":
+		if domain, name, _ := strings.Cut(button.Service, "."); domain == "" || name == "" {
+			return fmt.Errorf("service must be given as domain.service, got %q", button.Service)
+		}
+	default:
+		if button.Entity != "" || button.Data != nil {
+			return errors.New("entity and data can only be used with Home Assistant actions")
+		}
+
+		return nil
+	}
+
+	// scenes don't stay on, their state is when they were last activated
+	button.HasState = button.Entity != "" && (isHomeAssistantToggleable(button.Entity) || strings.HasPrefix(button.Entity, "script."))
+
+	return nil
+}
+
+func (widget *buttonsWidget) update(ctx context.Context) {
+	states, err := widget.HomeAssistant.fetchEntityStates(widget.httpClient(widget.HomeAssistant.AllowInsecure))
+	if err != nil {
+		// the rest of the buttons can still be used
+		widget.canContinueUpdateAfterHandlingErr(fmt.Errorf("%w: home assistant: %v", errPartialContent, err))
+		return
+	}
+
+	widget.statesMu.Lock()
+	for i := range widget.Buttons {
+		if widget.Buttons[i].HasState {
+			widget.Buttons[i].setState(states[widget.Buttons[i].Entity])
+		}
+	}
+	widget.statesMu.Unlock()
+
+	widget.canContinueUpdateAfterHandlingErr(nil)
+}
+
+// state may be nil if the entity doesn't exist
+func (button *buttonsWidgetButton) setState(state *homeAssistantStateJson) {
+	button.Unavailable = state == nil || state.State == "unavailable" || state.State == "unknown"
+	button.IsOn = !button.Unavailable && state.State == "on"
+}
+
+func (widget *buttonsWidget) getCommands() [][]string {
+	commands := make([][]string, 0, len(widget.Buttons))
+	for i := range widget.Buttons {
+		if len(widget.Buttons[i].Command) > 0 {
+			commands = append(commands, widget.Buttons[i].Command)
+		}
+	}
+
+	return commands
+}
+
+func (widget *buttonsWidget) Render() template.HTML {
+	widget.statesMu.Lock()
+	defer widget.statesMu.Unlock()
+
+	return widget.renderTemplate(widget, buttonsWidgetTemplate)
+}
+
+type buttonsWidgetResultJson struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+	// the updated button, for buttons that reflect the state of an entity
+	Button string `json:"button,omitempty"`
+}
+
+func (widget *buttonsWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("path") != "run" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Button int `json:"button"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if body.Button < 0 || body.Button >= len(widget.Buttons) {
+		http.Error(w, "button not found", http.StatusNotFound)
+		return
+	}
+
+	button := &widget.Buttons[body.Button]
+	output, err := widget.run(r.Context(), button)
+
+	result := buttonsWidgetResultJson{OK: err == nil}
+
+	if err != nil {
+		widget.logger().Error("Failed to run action", "button", button.Label, "error", err)
+		result.Message = err.Error()
+	} else {
+		widget.logger().Info("Ran action", "button", button.Label)
+		result.Message = ternary(button.SuccessMessage != "", button.SuccessMessage, output)
+		if result.Message == "" {
+			result.Message = button.Label + ": done"
+		}
+	}
+
+	if button.HasState {
+		result.Button = widget.renderButton(button)
+	}
+
+	widget.Providers.audit.record(r, "Ran action", widget.Title+": "+button.Label, "", ternary(err == nil, "succeeded", "failed"))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// Returns the first line of what the action responded with, if anything
+func (widget *buttonsWidget) run(ctx context.Context, button *buttonsWidgetButton) (string, error) {
+	var output string
+
+	if len(button.Command) > 0 {
+		var err error
+		output, err = runWidgetCommand(ctx, button.Command, time.Duration(button.Timeout), nil)
+		if err != nil {
+			return "", err
+		}
+	} else if button.Service != "" {
+		return "", widget.callService(ctx, button)
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(button.Timeout))
+		defer cancel()
+
+		var body io.Reader
+		if button.Body != "" {
+			body = strings.NewReader(button.Body)
+		}
+
+		request, err := http.NewRequestWithContext(ctx, button.Method, button.URL, body)
+		if err != nil {
+			return "", err
+		}
+
+		for key, value := range button.Headers {
+			request.Header.Set(key, value)
+		}
+
+		response, err := widget.httpClient(button.AllowInsecure).Do(request)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		output = strings.TrimSpace(string(responseBody))
+
+		// not worth showing
+		if strings.HasPrefix(response.Header.Get("Content-Type"), "text/html") {
+			output = ""
+		}
+
+		if response.StatusCode < 200 || response.StatusCode >= 300 {
+			if line, _, _ := strings.Cut(output, "\n"); line != "" {
+				line, _ = limitStringLength(line, 200)
+				return "", fmt.Errorf("unexpected status code %d: %s", response.StatusCode, line)
+			}
+
+			return "", fmt.Errorf("unexpected status code %d", response.StatusCode)
+		}
+	}
+
+	output, _, _ = strings.Cut(output, "\n")
+	output, _ = limitStringLength(output, 200)
+
+	return output, nil
+}
+
+func (widget *buttonsWidget) callService(ctx context.Context, button *buttonsWidgetButton) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(button.Timeout))
+	defer cancel()
+
+	client := widget.httpClient(widget.HomeAssistant.AllowInsecure)
+	state, err := widget.HomeAssistant.callService(ctx, client, button.Service, button.Entity, button.Data)
+	if err != nil {
+		return err
+	}
+
+	if button.HasState {
+		widget.statesMu.Lock()
+		button.setState(state)
+		widget.statesMu.Unlock()
+	}
+
+	return nil
+}
+
+func (widget *buttonsWidget) renderButton(button *buttonsWidgetButton) string {
+	widget.statesMu.Lock()
+	defer widget.statesMu.Unlock()
+
+	var html bytes.Buffer
+	if err := buttonsWidgetTemplate.ExecuteTemplate(&html, "button", button); err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(html.String())
+}
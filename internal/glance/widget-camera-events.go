@@ -0,0 +1,439 @@
+package glance
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+var cameraEventsWidgetTemplate = mustParseTemplate("camera-events.html", "widget-base.html")
+
+const (
+	cameraEventsDefaultTopic = "frigate/events"
+	// how long to wait before subscribing again after the connection dropped
+	cameraEventsReconnectDelay = 30 * time.Second
+	cameraEventMaxTextLength   = 200
+)
+
+type cameraEventsWidget struct {
+	widgetBase    `yaml:",inline"`
+	Frigate       *frigateConnection  `yaml:"frigate"`
+	MQTT          *mqttConnection     `yaml:"mqtt"`
+	Topics        []cameraEventsTopic `yaml:"topics"`
+	Cameras       []string            `yaml:"cameras"`
+	Labels        []string            `yaml:"labels"`
+	MinScore      float64             `yaml:"min-score"`
+	Limit         int                 `yaml:"limit"`
+	CollapseAfter int                 `yaml:"collapse-after"`
+	Retention     durationField       `yaml:"retention"`
+	MaxEvents     int                 `yaml:"max-events"`
+	Notify        []string            `yaml:"notify"`
+	stateMutex    sync.Mutex          `yaml:"-"`
+}
+
+type cameraEventsTopic struct {
+	Topic string `yaml:"topic"`
+	Label string `yaml:"label"`
+}
+
+type cameraEvent struct {
+	ID           string
+	Camera       string
+	Label        string
+	Text         string
+	Score        float64
+	Time         time.Time
+	ThumbnailURL string
+}
+
+func (e cameraEvent) ScorePercent() int {
+	return int(e.Score*100 + 0.5)
+}
+
+type cameraEventsState struct {
+	// newest first
+	Events []cameraEventState `json:"events"`
+	// no notifications are sent for the events that were already there the
+	// first time the Frigate API was checked
+	CheckedFrigate bool `json:"checked_frigate,omitempty"`
+}
+
+type cameraEventState struct {
+	ID     string  `json:"id"`
+	Camera string  `json:"camera,omitempty"`
+	Label  string  `json:"label"`
+	Text   string  `json:"text,omitempty"`
+	Score  float64 `json:"score,omitempty"`
+	Time   int64   `json:"time"`
+	// events detected by Frigate, which have a thumbnail
+	Frigate bool `json:"frigate,omitempty"`
+}
+
+func (widget *cameraEventsWidget) initialize() error {
+	widget.withTitle("Events").withCacheDuration(time.Minute)
+
+	if widget.Frigate == nil && widget.MQTT == nil {
+		return errors.New("at least one of frigate or mqtt is required")
+	}
+
+	if widget.Frigate != nil {
+		if err := widget.Frigate.initialize(); err != nil {
+			return fmt.Errorf("frigate: %v", err)
+		}
+	}
+
+	if widget.MQTT != nil {
+		if err := widget.MQTT.initialize(); err != nil {
+			return fmt.Errorf("mqtt: %v", err)
+		}
+
+		if len(widget.Topics) == 0 {
+			widget.Topics = []cameraEventsTopic{{Topic: cameraEventsDefaultTopic}}
+		}
+	} else if len(widget.Topics) > 0 {
+		return errors.New("mqtt is required to subscribe to topics")
+	}
+
+	for i := range widget.Topics {
+		topic := &widget.Topics[i]
+
+		if topic.Topic == "" {
+			return fmt.Errorf("topic %d: topic is required", i+1)
+		}
+
+		if isMQTTTopicWildcard(topic.Topic) {
+			return fmt.Errorf("topic %d: topic can't contain wildcards", i+1)
+		}
+	}
+
+	if widget.MinScore < 0 || widget.MinScore > 1 {
+		return errors.New("min-score must be between 0 and 1")
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 10
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	if widget.Retention <= 0 {
+		widget.Retention = durationField(7 * 24 * time.Hour)
+	}
+
+	if widget.MaxEvents <= 0 {
+		widget.MaxEvents = 200
+	}
+
+	widget.MaxEvents = max(widget.MaxEvents, widget.Limit)
+
+	return nil
+}
+
+func (widget *cameraEventsWidget) getNotificationTargets() []string {
+	return widget.Notify
+}
+
+func (widget *cameraEventsWidget) stateKey() string {
+	var source strings.Builder
+
+	if widget.Frigate != nil {
+		source.WriteString(widget.Frigate.URL)
+	}
+
+	if widget.MQTT != nil {
+		source.WriteString("#" + widget.MQTT.Broker)
+		for _, topic := range widget.Topics {
+			source.WriteString("#" + topic.Topic)
+		}
+	}
+
+	return "camera-events:" + seenItemID(source.String())
+}
+
+// Events received over MQTT are recorded as they come in, the update only
+// checks the Frigate API for any that were missed
+func (widget *cameraEventsWidget) update(ctx context.Context) {
+	if widget.Frigate == nil {
+		return
+	}
+
+	events, err := widget.Frigate.fetchEvents(ctx, widget.httpClient(widget.Frigate.AllowInsecure), widget.Cameras, min(widget.MaxEvents, 50))
+	if err != nil {
+		err = fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	recorded := make([]cameraEventState, 0, len(events))
+	for i := range events {
+		recorded = append(recorded, newFrigateEventState(&events[i]))
+	}
+
+	widget.record(recorded, true)
+}
+
+func newFrigateEventState(event *frigateEvent) cameraEventState {
+	return cameraEventState{
+		ID:      event.ID,
+		Camera:  event.Camera,
+		Label:   event.Label,
+		Score:   event.Score,
+		Time:    event.StartedAt.Unix(),
+		Frigate: true,
+	}
+}
+
+func (widget *cameraEventsWidget) listen(ctx context.Context) {
+	if widget.MQTT == nil {
+		return
+	}
+
+	topics := make([]string, len(widget.Topics))
+	for i := range widget.Topics {
+		topics[i] = widget.Topics[i].Topic
+	}
+
+	for {
+		err := widget.MQTT.subscribe(ctx, topics, widget.handleMessage)
+		if ctx.Err() != nil {
+			return
+		}
+
+		slog.Warn("Lost connection to MQTT broker, reconnecting", "widget", widget.GetType(), "title", widget.Title, "broker", widget.MQTT.Broker, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cameraEventsReconnectDelay):
+		}
+	}
+}
+
+// Messages in the format of Frigate's events topic are recorded as detected
+// objects, anything else is recorded as an event with the label of the topic,
+// such as a doorbell being pressed
+func (widget *cameraEventsWidget) handleMessage(topicName string, payload []byte) {
+	index := slices.IndexFunc(widget.Topics, func(t cameraEventsTopic) bool { return t.Topic == topicName })
+	if index == -1 {
+		return
+	}
+
+	topic := &widget.Topics[index]
+	now := time.Now()
+
+	if message := gjson.ParseBytes(payload); message.Get("after.id").Exists() {
+		// updates are sent for the same event while the object is in view
+		if message.Get("type").String() != "new" {
+			return
+		}
+
+		event := parseFrigateEvent(message.Get("after"))
+		if topic.Label != "" {
+			event.Label = topic.Label
+		}
+
+		widget.record([]cameraEventState{newFrigateEventState(&event)}, false)
+		return
+	}
+
+	text := strings.TrimSpace(string(payload))
+	if !utf8.ValidString(text) || strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		text = ""
+	}
+	text, _ = limitStringLength(text, cameraEventMaxTextLength)
+
+	widget.record([]cameraEventState{{
+		ID:    topic.Topic + "#" + strconv.FormatInt(now.UnixNano(), 10),
+		Label: cmp.Or(topic.Label, path.Base(topic.Topic)),
+		Text:  text,
+		Time:  now.Unix(),
+	}}, false)
+}
+
+func (widget *cameraEventsWidget) matches(event *cameraEventState) bool {
+	if !event.Frigate {
+		return true
+	}
+
+	if len(widget.Cameras) > 0 && !slices.Contains(widget.Cameras, event.Camera) {
+		return false
+	}
+
+	if len(widget.Labels) > 0 && !slices.ContainsFunc(widget.Labels, func(label string) bool {
+		return strings.EqualFold(formatFrigateName(label), event.Label)
+	}) {
+		return false
+	}
+
+	return event.Score == 0 || event.Score >= widget.MinScore
+}
+
+// Adds the events that haven't been seen before, sends notifications about
+// them and forgets the ones that are past the retention limits. Events from
+// the first check of the Frigate API don't get notified about since they
+// likely happened a while ago.
+func (widget *cameraEventsWidget) record(events []cameraEventState, fromAPI bool) {
+	widget.stateMutex.Lock()
+
+	var state cameraEventsState
+	widget.Providers.state.get(widget.stateKey(), &state)
+
+	now := time.Now()
+	oldest := now.Add(-time.Duration(widget.Retention)).Unix()
+	shouldNotify := len(widget.Notify) > 0 && (!fromAPI || state.CheckedFrigate)
+	var added []cameraEventState
+
+	for i := range events {
+		event := &events[i]
+
+		if event.Time < oldest || !widget.matches(event) {
+			continue
+		}
+
+		existing := slices.IndexFunc(state.Events, func(e cameraEventState) bool { return e.ID == event.ID })
+		if existing != -1 {
+			// the score keeps going up while Frigate is tracking the object
+			state.Events[existing].Score = max(state.Events[existing].Score, event.Score)
+			continue
+		}
+
+		state.Events = append(state.Events, *event)
+		added = append(added, *event)
+	}
+
+	slices.SortStableFunc(state.Events, func(a, b cameraEventState) int {
+		return cmp.Compare(b.Time, a.Time)
+	})
+
+	state.Events = slices.DeleteFunc(state.Events, func(e cameraEventState) bool {
+		return e.Time < oldest
+	})
+
+	if len(state.Events) > widget.MaxEvents {
+		state.Events = state.Events[:widget.MaxEvents]
+	}
+
+	if fromAPI {
+		state.CheckedFrigate = true
+	}
+
+	widget.Providers.state.set(widget.stateKey(), state)
+	widget.stateMutex.Unlock()
+
+	if !shouldNotify {
+		return
+	}
+
+	for i := range added {
+		widget.Providers.notifier.notify(widget.Notify, newCameraEventNotification(&added[i]))
+	}
+}
+
+func newCameraEventNotification(event *cameraEventState) notification {
+	n := notification{Title: formatFrigateName(event.Label)}
+
+	if event.Frigate {
+		n.Title += " detected"
+	}
+
+	details := make([]string, 0, 2)
+	if event.Camera != "" {
+		details = append(details, formatFrigateName(event.Camera))
+	}
+
+	if event.Score > 0 {
+		details = append(details, strconv.Itoa(int(event.Score*100+0.5))+"%")
+	}
+
+	n.Message = strings.Join(details, " · ")
+	if event.Text != "" {
+		n.Message = strings.TrimSpace(event.Text + "\n" + n.Message)
+	}
+
+	if n.Message == "" {
+		n.Message = time.Unix(event.Time, 0).Format("15:04:05")
+	}
+
+	return n
+}
+
+// Read from the state on every render so that events received over MQTT show
+// up without waiting for the next update
+func (widget *cameraEventsWidget) Events() []cameraEvent {
+	widget.stateMutex.Lock()
+	defer widget.stateMutex.Unlock()
+
+	var state cameraEventsState
+	widget.Providers.state.get(widget.stateKey(), &state)
+
+	events := make([]cameraEvent, 0, min(len(state.Events), widget.Limit))
+
+	for i := range state.Events {
+		if len(events) == widget.Limit {
+			break
+		}
+
+		stored := &state.Events[i]
+		event := cameraEvent{
+			ID:     stored.ID,
+			Camera: formatFrigateName(stored.Camera),
+			Label:  formatFrigateName(stored.Label),
+			Text:   stored.Text,
+			Score:  stored.Score,
+			Time:   time.Unix(stored.Time, 0),
+		}
+
+		if stored.Frigate && widget.Frigate != nil {
+			event.ThumbnailURL = widget.Providers.widgetURLResolver(widget.GetID(), "thumbnail?id="+url.QueryEscape(stored.ID))
+		}
+
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func (widget *cameraEventsWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || r.PathValue("path") != "thumbnail" || widget.Frigate == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+
+	// only the thumbnails of events that are being kept track of can be requested
+	widget.stateMutex.Lock()
+	var state cameraEventsState
+	widget.Providers.state.get(widget.stateKey(), &state)
+	exists := slices.ContainsFunc(state.Events, func(e cameraEventState) bool { return e.ID == id && e.Frigate })
+	widget.stateMutex.Unlock()
+
+	if !exists {
+		http.Error(w, "event not found", http.StatusNotFound)
+		return
+	}
+
+	widget.Frigate.serveEventThumbnail(w, r, widget.httpClient(widget.Frigate.AllowInsecure), id)
+}
+
+func (widget *cameraEventsWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, cameraEventsWidgetTemplate)
+}
@@ -0,0 +1,341 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var camerasWidgetTemplate = mustParseTemplate("cameras.html", "widget-base.html")
+
+const (
+	cameraSnapshotMaxSize = 10 * 1024 * 1024
+	cameraSnapshotTimeout = 15 * time.Second
+)
+
+type camerasWidget struct {
+	widgetBase      `yaml:",inline"`
+	Frigate         *frigateConnection `yaml:"frigate"`
+	Cameras         []camera           `yaml:"cameras"`
+	RefreshInterval durationField      `yaml:"refresh-interval"`
+	Events          int                `yaml:"events"`
+	LatestEvents    []frigateEvent     `yaml:"-"`
+
+	eventsMu sync.Mutex
+	eventIDs map[string]bool
+}
+
+type camera struct {
+	Name          string `yaml:"name"`
+	SnapshotURL   string `yaml:"snapshot-url"`
+	Username      string `yaml:"username"`
+	Password      string `yaml:"password"`
+	RTSP          string `yaml:"rtsp"`
+	Frigate       string `yaml:"frigate"`
+	AllowInsecure bool   `yaml:"allow-insecure"`
+	URL           string `yaml:"-"`
+	snapshot      cachedImage
+}
+
+// The latest image is shared by everyone viewing the page, and only fetched
+// again once it's older than the given age
+type cachedImage struct {
+	mu          sync.Mutex
+	data        []byte
+	contentType string
+	fetchedAt   time.Time
+}
+
+func (widget *camerasWidget) initialize() error {
+	widget.withTitle("Cameras").withCacheDuration(time.Minute)
+
+	if len(widget.Cameras) == 0 {
+		return errors.New("no cameras specified")
+	}
+
+	if widget.Frigate != nil {
+		if err := widget.Frigate.initialize(); err != nil {
+			return fmt.Errorf("frigate: %v", err)
+		}
+	}
+
+	for i := range widget.Cameras {
+		c := &widget.Cameras[i]
+
+		sources := 0
+		for _, set := range []bool{c.SnapshotURL != "", c.RTSP != "", c.Frigate != ""} {
+			if set {
+				sources++
+			}
+		}
+
+		if sources != 1 {
+			return fmt.Errorf("camera %d: exactly one of snapshot-url, rtsp or frigate is required", i+1)
+		}
+
+		if c.RTSP != "" && !strings.HasPrefix(c.RTSP, "rtsp://") && !strings.HasPrefix(c.RTSP, "rtsps://") {
+			return fmt.Errorf("camera %d: rtsp must start with rtsp:// or rtsps://", i+1)
+		}
+
+		if c.Frigate != "" && widget.Frigate == nil {
+			return fmt.Errorf("camera %d: frigate is required to show frigate cameras", i+1)
+		}
+
+		if c.Name == "" {
+			c.Name = ternary(c.Frigate != "", formatFrigateName(c.Frigate), "Camera "+strconv.Itoa(i+1))
+		}
+	}
+
+	if widget.Events > 0 && widget.Frigate == nil {
+		return errors.New("frigate is required to show events")
+	}
+
+	if widget.RefreshInterval == 0 {
+		widget.RefreshInterval = durationField(10 * time.Second)
+	} else if widget.RefreshInterval < durationField(time.Second) {
+		return errors.New("refresh-interval must be at least 1s")
+	}
+
+	return nil
+}
+
+func (widget *camerasWidget) getCommands() [][]string {
+	for i := range widget.Cameras {
+		if widget.Cameras[i].RTSP != "" {
+			return [][]string{{"ffmpeg"}}
+		}
+	}
+
+	return nil
+}
+
+// Snapshots are loaded by the browser, the update only gets the events
+func (widget *camerasWidget) update(ctx context.Context) {
+	for i := range widget.Cameras {
+		widget.Cameras[i].URL = widget.Providers.widgetURLResolver(widget.GetID(), "snapshot?camera="+strconv.Itoa(i))
+	}
+
+	if widget.Events <= 0 {
+		widget.canContinueUpdateAfterHandlingErr(nil)
+		return
+	}
+
+	events, err := widget.fetchFrigateEvents(ctx)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	ids := make(map[string]bool, len(events))
+	for i := range events {
+		ids[events[i].ID] = true
+		events[i].ThumbnailURL = widget.Providers.widgetURLResolver(widget.GetID(), "event?id="+url.QueryEscape(events[i].ID))
+	}
+
+	widget.eventsMu.Lock()
+	widget.eventIDs = ids
+	widget.eventsMu.Unlock()
+
+	widget.LatestEvents = events
+}
+
+func (widget *camerasWidget) fetchFrigateEvents(ctx context.Context) ([]frigateEvent, error) {
+	names := make(map[string]string)
+	cameras := make([]string, 0, len(widget.Cameras))
+
+	for i := range widget.Cameras {
+		if c := &widget.Cameras[i]; c.Frigate != "" {
+			names[c.Frigate] = c.Name
+			cameras = append(cameras, c.Frigate)
+		}
+	}
+
+	events, err := widget.Frigate.fetchEvents(ctx, widget.httpClient(widget.Frigate.AllowInsecure), cameras, widget.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range events {
+		events[i].Camera = ternary(names[events[i].Camera] != "", names[events[i].Camera], formatFrigateName(events[i].Camera))
+	}
+
+	return events, nil
+}
+
+func (widget *camerasWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.PathValue("path") {
+	case "snapshot":
+		widget.serveSnapshot(w, r)
+	case "event":
+		widget.serveEventThumbnail(w, r)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (widget *camerasWidget) serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(r.URL.Query().Get("camera"))
+	if err != nil || index < 0 || index >= len(widget.Cameras) {
+		http.Error(w, "camera not found", http.StatusNotFound)
+		return
+	}
+
+	c := &widget.Cameras[index]
+
+	c.snapshot.serve(w, r, time.Duration(widget.RefreshInterval)/2, func(ctx context.Context) ([]byte, string, error) {
+		return widget.fetchSnapshot(ctx, c)
+	})
+}
+
+func (c *cachedImage) serve(w http.ResponseWriter, r *http.Request, maxAge time.Duration, fetch func(context.Context) ([]byte, string, error)) {
+	// held while fetching so that any number of open pages only cause a
+	// single request to the camera
+	c.mu.Lock()
+	if time.Since(c.fetchedAt) >= maxAge {
+		data, contentType, err := fetch(r.Context())
+		if err != nil {
+			c.mu.Unlock()
+			http.Error(w, "failed to get snapshot: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		c.data, c.contentType, c.fetchedAt = data, contentType, time.Now()
+	}
+	data, contentType := c.data, c.contentType
+	c.mu.Unlock()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(data)
+}
+
+func (widget *camerasWidget) fetchSnapshot(ctx context.Context, c *camera) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, cameraSnapshotTimeout)
+	defer cancel()
+
+	if c.RTSP != "" {
+		data, err := captureRTSPSnapshot(ctx, c.RTSP)
+		return data, "image/jpeg", err
+	}
+
+	if c.Frigate != "" {
+		return fetchCameraImage(ctx, widget.httpClient(widget.Frigate.AllowInsecure), widget.Frigate.latestSnapshotURL(c.Frigate), "", "")
+	}
+
+	return fetchCameraImage(ctx, widget.httpClient(c.AllowInsecure), c.SnapshotURL, c.Username, c.Password)
+}
+
+func fetchCameraImage(ctx context.Context, client requestDoer, target, username, password string) ([]byte, string, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if username != "" {
+		request.SetBasicAuth(username, password)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	contentType := response.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "image/svg") {
+		return nil, "", fmt.Errorf("unexpected content type %s", contentType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, cameraSnapshotMaxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(data) > cameraSnapshotMaxSize {
+		return nil, "", errors.New("snapshot is too large")
+	}
+
+	return data, contentType, nil
+}
+
+// Grabs a single frame from the stream with ffmpeg, which has to be installed
+// and listed in the allowed commands
+func captureRTSPSnapshot(ctx context.Context, stream string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-rtsp_transport", "tcp",
+		"-i", stream,
+		"-frames:v", "1", "-f", "image2", "-c:v", "mjpeg", "-q:v", "4",
+		"pipe:1",
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", cameraSnapshotTimeout)
+		}
+
+		if output, _ := limitStringLength(strings.TrimSpace(stderr.String()), 256); output != "" {
+			err = fmt.Errorf("%v: %s", err, output)
+		}
+
+		return nil, fmt.Errorf("running ffmpeg: %v", err)
+	}
+
+	if stdout.Len() == 0 {
+		return nil, errors.New("ffmpeg didn't output a frame")
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Only the thumbnails of events that were listed during the last update can
+// be requested
+func (widget *camerasWidget) serveEventThumbnail(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	widget.eventsMu.Lock()
+	exists := widget.eventIDs[id]
+	widget.eventsMu.Unlock()
+
+	if !exists {
+		http.Error(w, "event not found", http.StatusNotFound)
+		return
+	}
+
+	widget.Frigate.serveEventThumbnail(w, r, widget.httpClient(widget.Frigate.AllowInsecure), id)
+}
+
+func (widget *camerasWidget) RefreshIntervalMilliseconds() int64 {
+	return time.Duration(widget.RefreshInterval).Milliseconds()
+}
+
+func (widget *camerasWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, camerasWidgetTemplate)
+}
@@ -43,7 +43,7 @@ func (widget *changeDetectionWidget) initialize() error {
 
 func (widget *changeDetectionWidget) update(ctx context.Context) {
 	if len(widget.WatchUUIDs) == 0 {
-		uuids, err := fetchWatchUUIDsFromChangeDetection(widget.InstanceURL, string(widget.Token))
+		uuids, err := fetchWatchUUIDsFromChangeDetection(widget.httpClient(false), widget.InstanceURL, string(widget.Token))
 
 		if !widget.canContinueUpdateAfterHandlingErr(err) {
 			return
@@ -52,7 +52,7 @@ func (widget *changeDetectionWidget) update(ctx context.Context) {
 		widget.WatchUUIDs = uuids
 	}
 
-	watches, err := fetchWatchesFromChangeDetection(widget.InstanceURL, widget.WatchUUIDs, string(widget.Token))
+	watches, err := fetchWatchesFromChangeDetection(widget.httpClient(false), widget.InstanceURL, widget.WatchUUIDs, string(widget.Token))
 
 	if !widget.canContinueUpdateAfterHandlingErr(err) {
 		return
@@ -95,14 +95,14 @@ type changeDetectionResponseJson struct {
 	PreviousHash string `json:"previous_md5"`
 }
 
-func fetchWatchUUIDsFromChangeDetection(instanceURL string, token string) ([]string, error) {
+func fetchWatchUUIDsFromChangeDetection(client requestDoer, instanceURL string, token string) ([]string, error) {
 	request, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/watch", instanceURL), nil)
 
 	if token != "" {
 		request.Header.Add("x-api-key", token)
 	}
 
-	uuidsMap, err := decodeJsonFromRequest[map[string]struct{}](defaultHTTPClient, request)
+	uuidsMap, err := decodeJsonFromRequest[map[string]struct{}](client, request)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch list of watch UUIDs: %v", err)
 	}
@@ -116,7 +116,7 @@ func fetchWatchUUIDsFromChangeDetection(instanceURL string, token string) ([]str
 	return uuids, nil
 }
 
-func fetchWatchesFromChangeDetection(instanceURL string, requestedWatchIDs []string, token string) (changeDetectionWatchList, error) {
+func fetchWatchesFromChangeDetection(client requestDoer, instanceURL string, requestedWatchIDs []string, token string) (changeDetectionWatchList, error) {
 	watches := make(changeDetectionWatchList, 0, len(requestedWatchIDs))
 
 	if len(requestedWatchIDs) == 0 {
@@ -135,7 +135,7 @@ func fetchWatchesFromChangeDetection(instanceURL string, requestedWatchIDs []str
 		requests[i] = request
 	}
 
-	task := decodeJsonFromRequestTask[changeDetectionResponseJson](defaultHTTPClient)
+	task := decodeJsonFromRequestTask[changeDetectionResponseJson](client)
 	job := newJob(task, requests).withWorkers(15)
 	responses, errs, err := workerPoolDo(job)
 	if err != nil {
@@ -0,0 +1,271 @@
+package glance
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var choresWidgetTemplate = mustParseTemplate("chores.html", "widget-base.html")
+
+// the maximum number of completions kept per chore
+const choreHistoryMaxLength = 50
+
+type choresWidget struct {
+	widgetBase   `yaml:",inline"`
+	Members      []string       `yaml:"members"`
+	Rotate       string         `yaml:"rotate"`
+	StartDate    string         `yaml:"start-date"`
+	Chores       []chore        `yaml:"chores"`
+	HistoryLimit int            `yaml:"history-limit"`
+	Items        []choreItem    `yaml:"-"`
+	History      []choreHistory `yaml:"-"`
+	startDate    time.Time      `yaml:"-"`
+	stateMutex   sync.Mutex     `yaml:"-"`
+}
+
+type chore struct {
+	Name    string   `yaml:"name"`
+	Members []string `yaml:"members"`
+	Rotate  string   `yaml:"rotate"`
+}
+
+type choreState struct {
+	History []choreCompletion `json:"history"`
+}
+
+type choreCompletion struct {
+	Member string `json:"member"`
+	DoneAt int64  `json:"done_at"`
+}
+
+type choreItem struct {
+	Name       string
+	Member     string
+	NextMember string
+	Rotate     string
+	IsDone     bool
+}
+
+type choreHistory struct {
+	Chore  string
+	Member string
+	DoneAt time.Time
+}
+
+func (widget *choresWidget) initialize() error {
+	widget.withTitle("Chores").withError(nil)
+
+	if len(widget.Chores) == 0 {
+		return errors.New("no chores specified")
+	}
+
+	if widget.Rotate == "" {
+		widget.Rotate = "weekly"
+	} else if !isValidChoreRotation(widget.Rotate) {
+		return fmt.Errorf("invalid rotate value %s, must be one of daily, weekly or monthly", widget.Rotate)
+	}
+
+	if widget.StartDate == "" {
+		// a Monday, so that weekly rotations happen at the start of the week
+		widget.startDate = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.Local)
+	} else {
+		startDate, err := time.ParseInLocation("2006-01-02", widget.StartDate, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid start-date %s, must be in the format of YYYY-MM-DD", widget.StartDate)
+		}
+
+		widget.startDate = startDate
+	}
+
+	if widget.HistoryLimit == 0 {
+		widget.HistoryLimit = 5
+	} else if widget.HistoryLimit < 0 {
+		widget.HistoryLimit = 0
+	}
+
+	names := make(map[string]struct{}, len(widget.Chores))
+
+	for i := range widget.Chores {
+		c := &widget.Chores[i]
+
+		if c.Name == "" {
+			return fmt.Errorf("chore %d has no name", i+1)
+		}
+
+		if _, exists := names[c.Name]; exists {
+			return fmt.Errorf("multiple chores with the name %s", c.Name)
+		}
+
+		names[c.Name] = struct{}{}
+
+		if len(c.Members) == 0 {
+			c.Members = widget.Members
+		}
+
+		if len(c.Members) == 0 {
+			return fmt.Errorf("chore %s: no members specified", c.Name)
+		}
+
+		if c.Rotate == "" {
+			c.Rotate = widget.Rotate
+		} else if !isValidChoreRotation(c.Rotate) {
+			return fmt.Errorf("chore %s: invalid rotate value %s, must be one of daily, weekly or monthly", c.Name, c.Rotate)
+		}
+	}
+
+	return nil
+}
+
+func isValidChoreRotation(rotate string) bool {
+	return rotate == "daily" || rotate == "weekly" || rotate == "monthly"
+}
+
+// Returns the start of the rotation period that t falls within along with how
+// many periods have passed since the start date
+func (widget *choresWidget) rotationPeriod(rotate string, t time.Time) (time.Time, int) {
+	// count days in UTC so that DST changes don't throw off the division
+	daysBetween := func(from, to time.Time) int {
+		fromUTC := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+		toUTC := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+		return int(toUTC.Sub(fromUTC).Hours() / 24)
+	}
+
+	start := widget.startDate
+	today := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	switch rotate {
+	case "daily":
+		return today, daysBetween(start, today)
+	case "monthly":
+		periodStart := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		return periodStart, (t.Year()-start.Year())*12 + int(t.Month()-start.Month())
+	default:
+		days := daysBetween(start, today)
+		weeks := days / 7
+		if days < 0 && days%7 != 0 {
+			weeks--
+		}
+
+		return today.AddDate(0, 0, -(days - weeks*7)), weeks
+	}
+}
+
+func (widget *choresWidget) stateKey(c *chore) string {
+	return "chores:" + widget.stateScope() + ":" + c.Name
+}
+
+func (widget *choresWidget) getState(c *chore) choreState {
+	var state choreState
+	widget.Providers.state.get(widget.stateKey(c), &state)
+
+	return state
+}
+
+func memberForPeriod(members []string, offset int, period int) string {
+	n := len(members)
+	return members[((offset+period)%n+n)%n]
+}
+
+func (widget *choresWidget) buildItems(now time.Time) ([]choreItem, []choreHistory) {
+	items := make([]choreItem, 0, len(widget.Chores))
+	history := make([]choreHistory, 0)
+
+	for i := range widget.Chores {
+		c := &widget.Chores[i]
+		state := widget.getState(c)
+		periodStart, period := widget.rotationPeriod(c.Rotate, now)
+
+		item := choreItem{
+			Name:       c.Name,
+			Member:     memberForPeriod(c.Members, i, period),
+			NextMember: memberForPeriod(c.Members, i, period+1),
+			Rotate:     c.Rotate,
+		}
+
+		if len(state.History) > 0 {
+			item.IsDone = state.History[len(state.History)-1].DoneAt >= periodStart.Unix()
+		}
+
+		for _, completion := range state.History {
+			history = append(history, choreHistory{
+				Chore:  c.Name,
+				Member: completion.Member,
+				DoneAt: time.Unix(completion.DoneAt, 0),
+			})
+		}
+
+		items = append(items, item)
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].DoneAt.After(history[j].DoneAt)
+	})
+
+	if len(history) > widget.HistoryLimit {
+		history = history[:widget.HistoryLimit]
+	}
+
+	return items, history
+}
+
+func (widget *choresWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	body, ok := decodeChecklistToggleRequest(w, r)
+	if !ok {
+		return
+	}
+
+	index := -1
+	for i := range widget.Chores {
+		if widget.Chores[i].Name == body.Item {
+			index = i
+			break
+		}
+	}
+
+	if index == -1 {
+		http.Error(w, "chore not found", http.StatusNotFound)
+		return
+	}
+
+	c := &widget.Chores[index]
+	now := time.Now()
+	periodStart, period := widget.rotationPeriod(c.Rotate, now)
+
+	widget.stateMutex.Lock()
+	defer widget.stateMutex.Unlock()
+
+	state := widget.getState(c)
+	last := len(state.History) - 1
+	isDone := last >= 0 && state.History[last].DoneAt >= periodStart.Unix()
+
+	if body.Done && !isDone {
+		member := memberForPeriod(c.Members, index, period)
+		state.History = append(state.History, choreCompletion{
+			Member: member,
+			DoneAt: now.Unix(),
+		})
+
+		if len(state.History) > choreHistoryMaxLength {
+			state.History = state.History[len(state.History)-choreHistoryMaxLength:]
+		}
+
+		widget.Providers.audit.record(r, "Checked off chore", widget.Title+": "+c.Name, "not done", ternary(member != "", "done by "+member, "done"))
+	} else if !body.Done && isDone {
+		state.History = state.History[:last]
+		widget.Providers.audit.record(r, "Unchecked chore", widget.Title+": "+c.Name, "done", "not done")
+	}
+
+	widget.Providers.state.set(widget.stateKey(c), state)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (widget *choresWidget) Render() template.HTML {
+	widget.Items, widget.History = widget.buildItems(time.Now())
+
+	return widget.renderTemplate(widget, choresWidgetTemplate)
+}
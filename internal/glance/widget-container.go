@@ -2,6 +2,7 @@ package glance
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 )
@@ -15,6 +16,13 @@ func (widget *containerWidgetBase) _initializeWidgets() error {
 		if err := widget.Widgets[i].initialize(); err != nil {
 			return formatWidgetInitError(err, widget.Widgets[i])
 		}
+
+		// the containers always render all of their widgets
+		if base, ok := widget.Widgets[i].(interface{ getWidgetBase() *widgetBase }); ok {
+			if schedule := &base.getWidgetBase().Schedule; len(schedule.ShowDuring) > 0 || len(schedule.HideDuring) > 0 {
+				return formatWidgetInitError(errors.New("show-during and hide-during can only be set on the group or split column itself"), widget.Widgets[i])
+			}
+		}
 	}
 
 	return nil
@@ -34,7 +42,7 @@ func (widget *containerWidgetBase) _update(ctx context.Context) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			widget.update(ctx)
+			updateWidget(ctx, widget)
 		}()
 	}
 
@@ -47,6 +55,10 @@ func (widget *containerWidgetBase) _setProviders(providers *widgetProviders) {
 	}
 }
 
+func (widget *containerWidgetBase) getChildWidgets() widgets {
+	return widget.Widgets
+}
+
 func (widget *containerWidgetBase) _requiresUpdate(now *time.Time) bool {
 	for i := range widget.Widgets {
 		if widget.Widgets[i].requiresUpdate(now) {
@@ -0,0 +1,192 @@
+package glance
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"sort"
+	"time"
+)
+
+var countdownWidgetTemplate = mustParseTemplate("countdown.html", "widget-base.html")
+
+type countdownWidget struct {
+	widgetBase    `yaml:",inline"`
+	Events        []countdownEvent `yaml:"events"`
+	CollapseAfter int              `yaml:"collapse-after"`
+	Items         []countdownItem  `yaml:"-"`
+}
+
+type countdownEvent struct {
+	Name   string          `yaml:"name"`
+	Date   string          `yaml:"date"`
+	Repeat string          `yaml:"repeat"`
+	URL    string          `yaml:"url"`
+	Icon   customIconField `yaml:"icon"`
+	Color  *hslColorField  `yaml:"color"`
+	date   time.Time
+	allDay bool
+}
+
+type countdownItem struct {
+	Name   string
+	URL    string
+	Icon   customIconField
+	Color  *hslColorField
+	At     time.Time
+	AllDay bool
+	// the number of calendar days left, 0 if the event is today
+	Days int
+}
+
+func (widget *countdownWidget) initialize() error {
+	widget.withTitle("Countdown").withError(nil)
+
+	if len(widget.Events) == 0 {
+		return errors.New("no events specified")
+	}
+
+	for i := range widget.Events {
+		event := &widget.Events[i]
+
+		if event.Name == "" {
+			return fmt.Errorf("event %d has no name", i+1)
+		}
+
+		if date, err := time.ParseInLocation("2006-01-02", event.Date, time.Local); err == nil {
+			event.date = date
+			event.allDay = true
+		} else if date, err := time.ParseInLocation("2006-01-02 15:04", event.Date, time.Local); err == nil {
+			event.date = date
+		} else {
+			return fmt.Errorf("event %s: invalid date %s, must be in the format of YYYY-MM-DD or YYYY-MM-DD HH:MM", event.Name, event.Date)
+		}
+
+		if event.Repeat != "" && event.Repeat != "yearly" && event.Repeat != "monthly" && event.Repeat != "weekly" {
+			return fmt.Errorf("event %s: invalid repeat value %s, must be one of yearly, monthly or weekly", event.Name, event.Repeat)
+		}
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+// Returns the nth occurrence of the event after its date. Days that don't
+// exist in a month, such as the 31st or the 29th of February, fall on the last
+// day of the month instead of overflowing into the next one.
+func (event *countdownEvent) occurrence(n int) time.Time {
+	date := event.date
+
+	switch event.Repeat {
+	case "weekly":
+		return date.AddDate(0, 0, 7*n)
+	case "monthly", "yearly":
+		months := ternary(event.Repeat == "monthly", n, n*12)
+		firstOfMonth := time.Date(date.Year(), date.Month()+time.Month(months), 1, date.Hour(), date.Minute(), 0, 0, date.Location())
+		lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+
+		return firstOfMonth.AddDate(0, 0, min(date.Day(), lastDay)-1)
+	}
+
+	return date
+}
+
+// Returns the next occurrence of the event that hasn't passed yet, all day
+// events count as not having passed until the end of their day
+func (event *countdownEvent) nextOccurrence(now time.Time) (time.Time, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	hasPassed := func(t time.Time) bool {
+		if event.allDay {
+			return t.Before(today)
+		}
+
+		return t.Before(now)
+	}
+
+	if !hasPassed(event.date) {
+		return event.date, true
+	}
+
+	if event.Repeat == "" {
+		return time.Time{}, false
+	}
+
+	// skip ahead close to the current date instead of going through every occurrence
+	var n int
+	switch event.Repeat {
+	case "weekly":
+		n = int(now.Sub(event.date).Hours() / (24 * 7))
+	case "monthly":
+		n = (now.Year()-event.date.Year())*12 + int(now.Month()-event.date.Month()) - 1
+	case "yearly":
+		n = now.Year() - event.date.Year() - 1
+	}
+
+	for n = max(n, 1); ; n++ {
+		if t := event.occurrence(n); !hasPassed(t) {
+			return t, true
+		}
+	}
+}
+
+func (widget *countdownWidget) buildItems(now time.Time) []countdownItem {
+	items := make([]countdownItem, 0, len(widget.Events))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	for i := range widget.Events {
+		event := &widget.Events[i]
+
+		at, ok := event.nextOccurrence(now)
+		if !ok {
+			continue
+		}
+
+		day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+
+		items = append(items, countdownItem{
+			Name:   event.Name,
+			URL:    event.URL,
+			Icon:   event.Icon,
+			Color:  event.Color,
+			At:     at,
+			AllDay: event.allDay,
+			// rounded since days around DST changes aren't exactly 24 hours long
+			Days: int(day.Sub(today).Hours()/24 + 0.5),
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].At.Before(items[j].At)
+	})
+
+	return items
+}
+
+func (widget *countdownWidget) briefing(now time.Time, _ int) []string {
+	var sentences []string
+
+	for _, item := range widget.buildItems(now) {
+		if item.Days > 1 {
+			break
+		}
+
+		sentence := ternary(item.Days == 0, "Today: ", "Tomorrow: ") + item.Name
+		if !item.AllDay {
+			sentence += " at " + briefingTime(item.At)
+		}
+
+		sentences = append(sentences, sentence+".")
+	}
+
+	return sentences
+}
+
+func (widget *countdownWidget) Render() template.HTML {
+	widget.Items = widget.buildItems(time.Now())
+
+	return widget.renderTemplate(widget, countdownWidgetTemplate)
+}
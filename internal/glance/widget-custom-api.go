@@ -35,6 +35,7 @@ type CustomAPIRequest struct {
 	SkipJSONValidation bool                 `yaml:"skip-json-validation"`
 	bodyReader         io.ReadSeeker        `yaml:"-"`
 	httpRequest        *http.Request        `yaml:"-"`
+	client             requestDoer          `yaml:"-"`
 }
 
 type customAPIWidget struct {
@@ -54,10 +55,14 @@ func (widget *customAPIWidget) initialize() error {
 		return fmt.Errorf("initializing primary request: %v", err)
 	}
 
+	widget.CustomAPIRequest.client = widget.cachedHTTPClient(widget.CustomAPIRequest.AllowInsecure)
+
 	for key := range widget.Subrequests {
 		if err := widget.Subrequests[key].initialize(); err != nil {
 			return fmt.Errorf("initializing subrequest %q: %v", key, err)
 		}
+
+		widget.Subrequests[key].client = widget.cachedHTTPClient(widget.Subrequests[key].AllowInsecure)
 	}
 
 	if widget.Template == "" {
@@ -188,8 +193,7 @@ func fetchCustomAPIRequest(ctx context.Context, req *CustomAPIRequest) (*customA
 		req.bodyReader.Seek(0, io.SeekStart)
 	}
 
-	client := ternary(req.AllowInsecure, defaultInsecureHTTPClient, defaultHTTPClient)
-	resp, err := client.Do(req.httpRequest.WithContext(ctx))
+	resp, err := req.client.Do(req.httpRequest.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,439 @@
+package glance
+
+import (
+	"fmt"
+	"html/template"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var daySummaryWidgetTemplate = mustParseTemplate("day-summary.html", "widget-base.html")
+
+const (
+	daySummaryKindEvent   = "event"
+	daySummaryKindWeather = "weather"
+	daySummaryKindTasks   = "tasks"
+	daySummaryKindCommute = "commute"
+)
+
+var daySummaryKinds = []string{daySummaryKindEvent, daySummaryKindWeather, daySummaryKindTasks, daySummaryKindCommute}
+
+// Something another widget has to say about the day, which gets turned into
+// part of the summary's sentence
+type daySummaryPart struct {
+	Kind string
+	// the name of the event or the route
+	Name string
+	// already worded in the summary's locale, for weather and commutes
+	Text   string
+	At     time.Time
+	AllDay bool
+	// the number of tasks left to do
+	Count int
+}
+
+// Widgets that can contribute to the day summary, nothing is returned when
+// there's nothing worth mentioning. Text is worded in the locale of the summary
+// rather than the one of the widget.
+type daySummarySource interface {
+	daySummary(now time.Time, l *locale) []daySummaryPart
+}
+
+// Puts what the other widgets are showing into a single sentence, worked out
+// when rendering so that it changes as soon as any of them gets updated
+type daySummaryWidget struct {
+	widgetBase `yaml:",inline"`
+	Include    []string `yaml:"include"`
+	Route      string   `yaml:"route"`
+}
+
+type daySummaryTemplateData struct {
+	*daySummaryWidget
+	Summary string
+}
+
+func (widget *daySummaryWidget) initialize() error {
+	widget.withTitle("Today").withError(nil)
+
+	if len(widget.Include) == 0 {
+		widget.Include = daySummaryKinds
+		return nil
+	}
+
+	for i, kind := range widget.Include {
+		if !slices.Contains(daySummaryKinds, kind) {
+			return fmt.Errorf("include: unknown value %s, must be one of %s", kind, strings.Join(daySummaryKinds, ", "))
+		}
+
+		if slices.Contains(widget.Include[:i], kind) {
+			return fmt.Errorf("include: %s is specified more than once", kind)
+		}
+	}
+
+	return nil
+}
+
+// Returns the parts of the widgets that anyone who can see the given widget
+// can also see, as of their last update. Sources on other pages aren't locked
+// or updated here, each one guards whatever its update changes that it reads.
+func (a *application) daySummaryParts(forWidgetID uint64, now time.Time, l *locale) []daySummaryPart {
+	var parts []daySummaryPart
+
+	var collect func(widget widget)
+	collect = func(widget widget) {
+		if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+			for _, child := range container.getChildWidgets() {
+				collect(child)
+			}
+
+			return
+		}
+
+		if source, ok := widget.(daySummarySource); ok && a.visibleAlongside(forWidgetID, widget.GetID()) {
+			parts = append(parts, source.daySummary(now, l)...)
+		}
+	}
+
+	for p := range a.Config.Pages {
+		for c := range a.Config.Pages[p].Columns {
+			for _, widget := range a.Config.Pages[p].Columns[c].Widgets {
+				collect(widget)
+			}
+		}
+	}
+
+	return parts
+}
+
+func isDaySummarySource(widget widget) bool {
+	if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+		return slices.ContainsFunc(container.getChildWidgets(), isDaySummarySource)
+	}
+
+	_, ok := widget.(daySummarySource)
+	return ok
+}
+
+// The day summaries placed directly within the columns of any page that have
+// to be rendered again because one of their sources was updated, by page
+func (a *application) daySummariesAffectedBy(updated []widget) map[*page][]widget {
+	if !slices.ContainsFunc(updated, isDaySummarySource) {
+		return nil
+	}
+
+	affected := make(map[*page][]widget)
+
+	for p := range a.Config.Pages {
+		page := &a.Config.Pages[p]
+
+		for c := range page.Columns {
+			for _, widget := range page.Columns[c].Widgets {
+				if _, ok := widget.(*daySummaryWidget); ok && !slices.Contains(updated, widget) {
+					affected[page] = append(affected[page], widget)
+				}
+			}
+		}
+	}
+
+	return affected
+}
+
+func (widget *daySummaryWidget) compose(parts []daySummaryPart, now time.Time, l *locale) string {
+	clauses := make([]string, 0, len(widget.Include))
+
+	for _, kind := range widget.Include {
+		var clause string
+
+		switch kind {
+		case daySummaryKindEvent:
+			clause = daySummaryEventClause(parts, now, l)
+		case daySummaryKindWeather:
+			clause = daySummaryFirstText(parts, daySummaryKindWeather, "")
+		case daySummaryKindTasks:
+			clause = daySummaryTasksClause(parts, l)
+		case daySummaryKindCommute:
+			clause = daySummaryFirstText(parts, daySummaryKindCommute, widget.Route)
+		}
+
+		if clause != "" {
+			clauses = append(clauses, clause)
+		}
+	}
+
+	if len(clauses) == 0 {
+		return l.translate("Nothing on the agenda.")
+	}
+
+	sentence := clauses[0]
+	if len(clauses) > 1 {
+		sentence = strings.Join(clauses[:len(clauses)-1], ", ") + " " + l.translate("and") + " " + clauses[len(clauses)-1]
+	}
+
+	first, size := utf8.DecodeRuneInString(sentence)
+
+	return string(unicode.ToUpper(first)) + sentence[size:] + "."
+}
+
+// Mentions the soonest event that hasn't started yet, with all-day events
+// coming after the timed ones of the same day
+func daySummaryEventClause(parts []daySummaryPart, now time.Time, l *locale) string {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	tomorrow := today.AddDate(0, 0, 1)
+	until := today.AddDate(0, 0, 2)
+
+	endOfDay := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).AddDate(0, 0, 1)
+	}
+
+	var events []daySummaryPart
+	for _, part := range parts {
+		if part.Kind != daySummaryKindEvent {
+			continue
+		}
+
+		if part.AllDay && !part.At.Before(today) && part.At.Before(until) || !part.AllDay && part.At.After(now) && part.At.Before(until) {
+			events = append(events, part)
+		}
+	}
+
+	if len(events) == 0 {
+		return ""
+	}
+
+	sortKey := func(part daySummaryPart) time.Time {
+		return ternary(part.AllDay, endOfDay(part.At), part.At)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return sortKey(events[i]).Before(sortKey(events[j]))
+	})
+
+	event := events[0]
+	isTomorrow := !event.At.Before(tomorrow)
+
+	if event.AllDay {
+		return fmt.Sprintf(l.translate(ternary(isTomorrow, "%s is tomorrow", "%s is today")), event.Name)
+	}
+
+	return fmt.Sprintf(l.translate(ternary(isTomorrow, "next up is %s tomorrow at %s", "next up is %s at %s")), event.Name, briefingTime(event.At))
+}
+
+func daySummaryTasksClause(parts []daySummaryPart, l *locale) string {
+	count, sources := 0, 0
+
+	for _, part := range parts {
+		if part.Kind == daySummaryKindTasks {
+			count += part.Count
+			sources++
+		}
+	}
+
+	switch {
+	case sources == 0:
+		return ""
+	case count == 0:
+		return l.translate("there's nothing left to do")
+	case count == 1:
+		return l.translate("you have 1 task left")
+	}
+
+	return fmt.Sprintf(l.translate("you have %d tasks left"), count)
+}
+
+// The text of the part with the given name, or of the first one of its kind
+// if there's no name or none of them have it
+func daySummaryFirstText(parts []daySummaryPart, kind, name string) string {
+	text := ""
+
+	for _, part := range parts {
+		if part.Kind != kind {
+			continue
+		}
+
+		if name != "" && strings.EqualFold(part.Name, name) {
+			return part.Text
+		}
+
+		if text == "" {
+			text = part.Text
+		}
+	}
+
+	return text
+}
+
+func (widget *daySummaryWidget) Render() template.HTML {
+	now := time.Now()
+	l := getLocale(widget.locale())
+
+	return widget.renderTemplate(&daySummaryTemplateData{
+		daySummaryWidget: widget,
+		Summary:          widget.compose(widget.Providers.daySummaryResolver(widget.GetID(), now, l), now, l),
+	}, daySummaryWidgetTemplate)
+}
+
+func (widget *weatherWidget) daySummary(_ time.Time, l *locale) []daySummaryPart {
+	widget.weatherMu.Lock()
+	defer widget.weatherMu.Unlock()
+
+	if widget.Weather == nil || widget.Place == nil || len(widget.Weather.Columns) == 0 {
+		return nil
+	}
+
+	high, low := widget.Weather.Columns[0].Temperature, widget.Weather.Columns[0].Temperature
+	for _, column := range widget.Weather.Columns[1:] {
+		high = max(high, column.Temperature)
+		low = min(low, column.Temperature)
+	}
+
+	return []daySummaryPart{{
+		Kind: daySummaryKindWeather,
+		Name: widget.Place.Name,
+		Text: fmt.Sprintf(l.translate("it's %d° in %s with a high of %d° and a low of %d°"), widget.Weather.Temperature, widget.Place.Name, high, low),
+	}}
+}
+
+func (widget *countdownWidget) daySummary(now time.Time, _ *locale) []daySummaryPart {
+	var parts []daySummaryPart
+
+	for _, item := range widget.buildItems(now) {
+		if item.Days > 1 {
+			break
+		}
+
+		parts = append(parts, daySummaryPart{
+			Kind:   daySummaryKindEvent,
+			Name:   item.Name,
+			At:     item.At,
+			AllDay: item.AllDay,
+		})
+	}
+
+	return parts
+}
+
+func (widget *anniversariesWidget) daySummary(now time.Time, l *locale) []daySummaryPart {
+	var parts []daySummaryPart
+
+	widget.loadedMu.Lock()
+	items := widget.buildItems(now)
+	widget.loadedMu.Unlock()
+
+	for _, item := range items {
+		if item.Days > 1 {
+			break
+		}
+
+		name := item.Name
+		if item.Kind != "" {
+			name = fmt.Sprintf(l.translate("%s's %s"), item.Name, item.Kind)
+		}
+
+		parts = append(parts, daySummaryPart{
+			Kind:   daySummaryKindEvent,
+			Name:   name,
+			At:     item.Date,
+			AllDay: true,
+		})
+	}
+
+	return parts
+}
+
+func (widget *timetableWidget) daySummary(now time.Time, l *locale) []daySummaryPart {
+	var parts []daySummaryPart
+
+	for _, person := range widget.buildItems(now) {
+		if person.Next == nil {
+			continue
+		}
+
+		name := person.Next.Subject
+		if len(widget.People) > 1 {
+			name = fmt.Sprintf(l.translate("%s for %s"), name, person.Name)
+		}
+
+		parts = append(parts, daySummaryPart{
+			Kind: daySummaryKindEvent,
+			Name: name,
+			At:   person.Next.Start,
+		})
+	}
+
+	return parts
+}
+
+func (widget *choresWidget) daySummary(now time.Time, _ *locale) []daySummaryPart {
+	items, _ := widget.buildItems(now)
+	part := daySummaryPart{Kind: daySummaryKindTasks}
+
+	for i := range items {
+		if !items[i].IsDone {
+			part.Count++
+		}
+	}
+
+	return []daySummaryPart{part}
+}
+
+func (widget *remindersWidget) daySummary(now time.Time, _ *locale) []daySummaryPart {
+	part := daySummaryPart{Kind: daySummaryKindTasks}
+
+	// reminders that are past due still count until they're checked off
+	for _, item := range widget.buildItems(now) {
+		if !item.IsEmpty && !item.IsDone {
+			part.Count++
+		}
+	}
+
+	return []daySummaryPart{part}
+}
+
+func (widget *gardenWidget) daySummary(now time.Time, _ *locale) []daySummaryPart {
+	part := daySummaryPart{Kind: daySummaryKindTasks}
+
+	widget.rainMu.Lock()
+	plants := widget.buildItems(now)
+	widget.rainMu.Unlock()
+
+	for _, plant := range plants {
+		for _, task := range plant.Tasks {
+			if task.IsLate {
+				part.Count++
+			}
+		}
+	}
+
+	return []daySummaryPart{part}
+}
+
+func (widget *travelTimeWidget) daySummary(_ time.Time, l *locale) []daySummaryPart {
+	var parts []daySummaryPart
+
+	widget.resultsMu.Lock()
+	results := widget.Results
+	widget.resultsMu.Unlock()
+
+	for _, result := range results {
+		if !result.HasValue || result.Error {
+			continue
+		}
+
+		text := fmt.Sprintf(l.translate("it takes %s to get to %s"), result.FormattedDuration(), result.Name)
+		if result.Delay() > 0 {
+			text = fmt.Sprintf(l.translate("it takes %s to get to %s with %s of traffic"), result.FormattedDuration(), result.Name, result.FormattedDelay())
+		}
+
+		parts = append(parts, daySummaryPart{
+			Kind: daySummaryKindCommute,
+			Name: result.Name,
+			Text: text,
+		})
+	}
+
+	return parts
+}
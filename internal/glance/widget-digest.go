@@ -0,0 +1,104 @@
+package glance
+
+import (
+	"context"
+	"html/template"
+	"strings"
+	"time"
+)
+
+var digestWidgetTemplate = mustParseTemplate("digest.html", "widget-base.html")
+
+const defaultDigestPeriod = 24 * time.Hour
+
+// Fetches feeds the same way as the RSS widget and has the summarizer write a
+// short digest of what was published within the period instead of listing it
+type digestWidget struct {
+	rssWidget `yaml:",inline"`
+	Prompt    string        `yaml:"prompt"`
+	Period    durationField `yaml:"period"`
+
+	Digest      string    `yaml:"-"`
+	Articles    int       `yaml:"-"`
+	GeneratedAt time.Time `yaml:"-"`
+}
+
+func (widget *digestWidget) initialize() error {
+	widget.withTitle("Digest")
+
+	if widget.Limit <= 0 {
+		widget.Limit = 40
+	}
+
+	// the descriptions are only kept for detailed lists
+	widget.Style = "detailed-list"
+
+	if err := widget.rssWidget.initialize(); err != nil {
+		return err
+	}
+
+	widget.withCacheDuration(24 * time.Hour)
+	widget.NoItemsMessage = "Nothing was published within the period."
+
+	return nil
+}
+
+func (widget *digestWidget) usesSummarizer() bool {
+	return true
+}
+
+func (widget *digestWidget) update(ctx context.Context) {
+	items, err := widget.fetchItemsFromFeeds()
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	items.sortByNewest()
+
+	cutoff := time.Now().Add(-ternary(widget.Period > 0, time.Duration(widget.Period), defaultDigestPeriod))
+	recent := make(rssFeedItemList, 0, len(items))
+
+	for i := range items {
+		if items[i].PublishedAt.After(cutoff) && len(recent) < widget.Limit {
+			recent = append(recent, items[i])
+		}
+	}
+
+	widget.Articles = len(recent)
+	widget.GeneratedAt = time.Now()
+
+	if len(recent) == 0 {
+		widget.Digest = ""
+		return
+	}
+
+	var headlines strings.Builder
+	for i := range recent {
+		headlines.WriteString("- " + recent[i].Title + " (" + recent[i].ChannelName + ")")
+		if recent[i].Description != "" {
+			headlines.WriteString(": " + recent[i].Description)
+		}
+		headlines.WriteString("\n")
+	}
+
+	prompt := ternary(widget.Prompt != "", widget.Prompt, defaultDigestPrompt)
+	digest, err := widget.Providers.summarizer.summarize(ctx, prompt, headlines.String(), defaultDigestMaxTokens)
+	if err != nil {
+		widget.withError(err).scheduleEarlyUpdate()
+		return
+	}
+
+	widget.Digest = digest
+}
+
+func (widget *digestWidget) briefing(_ time.Time, _ int) []string {
+	if widget.Digest == "" {
+		return nil
+	}
+
+	return []string{widget.Title + ".", widget.Digest}
+}
+
+func (widget *digestWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, digestWidgetTemplate)
+}
@@ -0,0 +1,236 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var discordWidgetTemplate = mustParseTemplate("discord.html", "widget-base.html")
+
+type discordWidget struct {
+	widgetBase           `yaml:",inline"`
+	ServerID             string        `yaml:"server-id"`
+	BotToken             string        `yaml:"bot-token"`
+	AnnouncementChannels []string      `yaml:"announcement-channels"`
+	MessagesLimit        int           `yaml:"messages-limit"`
+	Server               discordServer `yaml:"-"`
+}
+
+func (widget *discordWidget) initialize() error {
+	widget.withTitle("Discord").withCacheDuration(5 * time.Minute)
+
+	if widget.ServerID == "" {
+		return errors.New("server-id is required")
+	}
+
+	if len(widget.AnnouncementChannels) > 0 && widget.BotToken == "" {
+		return errors.New("bot-token is required in order to show announcements")
+	}
+
+	if widget.MessagesLimit <= 0 {
+		widget.MessagesLimit = 3
+	}
+
+	return nil
+}
+
+func (widget *discordWidget) update(ctx context.Context) {
+	server, err := fetchDiscordServer(widget.httpClient(false), widget.ServerID, widget.BotToken, widget.AnnouncementChannels, widget.MessagesLimit)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	if server.InviteURL != "" {
+		widget.withTitleURL(server.InviteURL)
+	}
+
+	widget.Server = server
+}
+
+func (widget *discordWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, discordWidgetTemplate)
+}
+
+type discordServer struct {
+	Name          string
+	InviteURL     string
+	Online        int
+	Members       int
+	VoiceChannels []discordVoiceChannel
+	Announcements []discordMessage
+}
+
+type discordVoiceChannel struct {
+	Name    string
+	Members []string
+}
+
+type discordMessage struct {
+	Channel  string
+	Author   string
+	Content  string
+	URL      string
+	PostedAt time.Time
+}
+
+type discordServerWidgetResponseJson struct {
+	Name          string `json:"name"`
+	InstantInvite string `json:"instant_invite"`
+	PresenceCount int    `json:"presence_count"`
+	Channels      []struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Position int    `json:"position"`
+	} `json:"channels"`
+	Members []struct {
+		Username  string `json:"username"`
+		ChannelID string `json:"channel_id"`
+	} `json:"members"`
+}
+
+type discordGuildResponseJson struct {
+	Name                     string `json:"name"`
+	ApproximateMemberCount   int    `json:"approximate_member_count"`
+	ApproximatePresenceCount int    `json:"approximate_presence_count"`
+}
+
+type discordChannelResponseJson struct {
+	Name string `json:"name"`
+}
+
+type discordMessageResponseJson struct {
+	ID        string `json:"id"`
+	Content   string `json:"content"`
+	Timestamp string `json:"timestamp"`
+	Author    struct {
+		Username   string `json:"username"`
+		GlobalName string `json:"global_name"`
+	} `json:"author"`
+}
+
+const discordAPIBaseURL = "https://discord.com/api/v10"
+
+func newDiscordBotRequest(url string, token string) *http.Request {
+	request, _ := http.NewRequest("GET", discordAPIBaseURL+url, nil)
+	request.Header.Set("Authorization", "Bot "+token)
+
+	return request
+}
+
+func fetchDiscordServer(client requestDoer, serverID string, botToken string, announcementChannels []string, messagesLimit int) (discordServer, error) {
+	server := discordServer{}
+
+	// the server widget needs to be enabled in the server's settings, however it's
+	// the only way to get voice channel members without a gateway connection
+	widgetRequest, _ := http.NewRequest("GET", discordAPIBaseURL+"/guilds/"+serverID+"/widget.json", nil)
+	widgetResponse, widgetErr := decodeJsonFromRequest[discordServerWidgetResponseJson](client, widgetRequest)
+
+	if widgetErr == nil {
+		server.Name = widgetResponse.Name
+		server.InviteURL = widgetResponse.InstantInvite
+		server.Online = widgetResponse.PresenceCount
+
+		channelMembers := make(map[string][]string)
+		for _, member := range widgetResponse.Members {
+			if member.ChannelID != "" {
+				channelMembers[member.ChannelID] = append(channelMembers[member.ChannelID], member.Username)
+			}
+		}
+
+		channels := widgetResponse.Channels
+		sort.Slice(channels, func(i, j int) bool {
+			return channels[i].Position < channels[j].Position
+		})
+
+		for _, channel := range channels {
+			if members, ok := channelMembers[channel.ID]; ok {
+				server.VoiceChannels = append(server.VoiceChannels, discordVoiceChannel{
+					Name:    channel.Name,
+					Members: members,
+				})
+			}
+		}
+	}
+
+	if botToken == "" {
+		if widgetErr != nil {
+			return server, fmt.Errorf("%w: could not fetch server widget: %v", errNoContent, widgetErr)
+		}
+
+		return server, nil
+	}
+
+	guild, guildErr := decodeJsonFromRequest[discordGuildResponseJson](
+		client,
+		newDiscordBotRequest("/guilds/"+serverID+"?with_counts=true", botToken),
+	)
+
+	if guildErr == nil {
+		server.Name = guild.Name
+		server.Members = guild.ApproximateMemberCount
+		server.Online = guild.ApproximatePresenceCount
+	}
+
+	if widgetErr != nil && guildErr != nil {
+		return server, fmt.Errorf("%w: could not fetch server details: %v", errNoContent, guildErr)
+	}
+
+	var failed int
+
+	for _, channelID := range announcementChannels {
+		channel, err := decodeJsonFromRequest[discordChannelResponseJson](
+			client,
+			newDiscordBotRequest("/channels/"+channelID, botToken),
+		)
+		if err != nil {
+			failed++
+			slog.Error("Failed to fetch discord channel", "channel", channelID, "error", err)
+			continue
+		}
+
+		messages, err := decodeJsonFromRequest[[]discordMessageResponseJson](
+			client,
+			newDiscordBotRequest(fmt.Sprintf("/channels/%s/messages?limit=%d", channelID, messagesLimit), botToken),
+		)
+		if err != nil {
+			failed++
+			slog.Error("Failed to fetch discord messages", "channel", channelID, "error", err)
+			continue
+		}
+
+		for _, message := range messages {
+			if message.Content == "" {
+				continue
+			}
+
+			server.Announcements = append(server.Announcements, discordMessage{
+				Channel:  channel.Name,
+				Author:   ternary(message.Author.GlobalName != "", message.Author.GlobalName, message.Author.Username),
+				Content:  message.Content,
+				URL:      fmt.Sprintf("https://discord.com/channels/%s/%s/%s", serverID, channelID, message.ID),
+				PostedAt: parseRFC3339Time(message.Timestamp),
+			})
+		}
+	}
+
+	sort.Slice(server.Announcements, func(i, j int) bool {
+		return server.Announcements[i].PostedAt.After(server.Announcements[j].PostedAt)
+	})
+
+	if len(server.Announcements) > messagesLimit {
+		server.Announcements = server.Announcements[:messagesLimit]
+	}
+
+	if failed > 0 {
+		return server, fmt.Errorf("%w: could not fetch messages from %d channel(s)", errPartialContent, failed)
+	}
+
+	return server, nil
+}
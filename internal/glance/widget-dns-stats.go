@@ -82,14 +82,14 @@ func (widget *dnsStatsWidget) update(ctx context.Context) {
 
 	switch widget.Service {
 	case dnsServiceAdguard:
-		stats, err = fetchAdguardStats(widget.URL, widget.AllowInsecure, widget.Username, widget.Password, widget.HideGraph)
+		stats, err = fetchAdguardStats(widget.URL, widget.httpClient(widget.AllowInsecure), widget.Username, widget.Password, widget.HideGraph)
 	case dnsServicePihole:
-		stats, err = fetchPihole5Stats(widget.URL, widget.AllowInsecure, widget.Token, widget.HideGraph)
+		stats, err = fetchPihole5Stats(widget.URL, widget.httpClient(widget.AllowInsecure), widget.Token, widget.HideGraph)
 	case dnsServicePiholeV6:
 		var newSessionID string
 		stats, newSessionID, err = fetchPiholeStats(
 			widget.URL,
-			widget.AllowInsecure,
+			widget.httpClient(widget.AllowInsecure),
 			widget.Password,
 			widget.piholeSessionID,
 			!widget.HideGraph,
@@ -148,7 +148,7 @@ type adguardStatsResponse struct {
 	TopBlockedDomains []map[string]int `json:"top_blocked_domains"`
 }
 
-func fetchAdguardStats(instanceURL string, allowInsecure bool, username, password string, noGraph bool) (*dnsStats, error) {
+func fetchAdguardStats(instanceURL string, client requestDoer, username, password string, noGraph bool) (*dnsStats, error) {
 	requestURL := strings.TrimRight(instanceURL, "/") + "/control/stats"
 
 	request, err := http.NewRequest("GET", requestURL, nil)
@@ -158,7 +158,6 @@ func fetchAdguardStats(instanceURL string, allowInsecure bool, username, passwor
 
 	request.SetBasicAuth(username, password)
 
-	var client = ternary(allowInsecure, defaultInsecureHTTPClient, defaultHTTPClient)
 	responseJson, err := decodeJsonFromRequest[adguardStatsResponse](client, request)
 	if err != nil {
 		return nil, err
@@ -301,7 +300,7 @@ func (p *pihole5TopBlockedDomains) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func fetchPihole5Stats(instanceURL string, allowInsecure bool, token string, noGraph bool) (*dnsStats, error) {
+func fetchPihole5Stats(instanceURL string, client requestDoer, token string, noGraph bool) (*dnsStats, error) {
 	if token == "" {
 		return nil, errors.New("missing API token")
 	}
@@ -314,7 +313,6 @@ func fetchPihole5Stats(instanceURL string, allowInsecure bool, token string, noG
 		return nil, err
 	}
 
-	var client = ternary(allowInsecure, defaultInsecureHTTPClient, defaultHTTPClient)
 	responseJson, err := decodeJsonFromRequest[pihole5StatsResponse](client, request)
 	if err != nil {
 		return nil, err
@@ -401,14 +399,13 @@ func fetchPihole5Stats(instanceURL string, allowInsecure bool, token string, noG
 
 func fetchPiholeStats(
 	instanceURL string,
-	allowInsecure bool,
+	client requestDoer,
 	password string,
 	sessionID string,
 	includeGraph bool,
 	includeTopDomains bool,
 ) (*dnsStats, string, error) {
 	instanceURL = strings.TrimRight(instanceURL, "/")
-	var client = ternary(allowInsecure, defaultInsecureHTTPClient, defaultHTTPClient)
 
 	fetchNewSessionID := func() error {
 		newSessionID, err := fetchPiholeSessionID(instanceURL, client, password)
@@ -605,7 +602,7 @@ func fetchPiholeStats(
 	return stats, sessionID, ternary(partialContent, errPartialContent, nil)
 }
 
-func fetchPiholeSessionID(instanceURL string, client *http.Client, password string) (string, error) {
+func fetchPiholeSessionID(instanceURL string, client requestDoer, password string) (string, error) {
 	requestBody := []byte(`{"password":"` + password + `"}`)
 
 	request, err := http.NewRequest("POST", instanceURL+"/api/auth", bytes.NewBuffer(requestBody))
@@ -653,7 +650,7 @@ func fetchPiholeSessionID(instanceURL string, client *http.Client, password stri
 	return jsonResponse.Session.SID, nil
 }
 
-func checkPiholeSessionIDIsValid(instanceURL string, client *http.Client, sessionID string) (bool, error) {
+func checkPiholeSessionIDIsValid(instanceURL string, client requestDoer, sessionID string) (bool, error) {
 	request, err := http.NewRequest("GET", instanceURL+"/api/auth", nil)
 	if err != nil {
 		return false, fmt.Errorf("creating session ID check request: %v", err)
@@ -271,3 +271,40 @@ func fetchAllDockerContainersFromSock(socketPath string) ([]dockerContainerJsonR
 
 	return containers, nil
 }
+
+type dockerContainerJson struct {
+	Title       string                `json:"title"`
+	URL         string                `json:"url,omitempty"`
+	Image       string                `json:"image"`
+	State       string                `json:"state"`
+	Status      string                `json:"status"`
+	Description string                `json:"description,omitempty"`
+	Children    []dockerContainerJson `json:"children,omitempty"`
+}
+
+func (containers dockerContainerList) data() []dockerContainerJson {
+	if len(containers) == 0 {
+		return nil
+	}
+
+	result := make([]dockerContainerJson, 0, len(containers))
+
+	for i := range containers {
+		container := &containers[i]
+		result = append(result, dockerContainerJson{
+			Title:       container.Title,
+			URL:         container.URL,
+			Image:       container.Image,
+			State:       container.State,
+			Status:      container.StateText,
+			Description: container.Description,
+			Children:    container.Children.data(),
+		})
+	}
+
+	return result
+}
+
+func (widget *dockerContainersWidget) data() any {
+	return ternary(len(widget.Containers) > 0, widget.Containers.data(), []dockerContainerJson{})
+}
@@ -0,0 +1,413 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var dynamicDNSWidgetTemplate = mustParseTemplate("dynamic-dns.html", "widget-base.html")
+
+type dynamicDNSWidget struct {
+	widgetBase `yaml:",inline"`
+	Provider   string                   `yaml:"provider"`
+	Token      string                   `yaml:"token"`
+	ZoneID     string                   `yaml:"zone-id"`
+	Records    []string                 `yaml:"records"`
+	RecordType string                   `yaml:"record-type"`
+	IPURL      string                   `yaml:"ip-url"`
+	Update     bool                     `yaml:"update"`
+	PublicIP   string                   `yaml:"-"`
+	Statuses   []dynamicDNSRecordStatus `yaml:"-"`
+
+	lastCheck time.Time
+	// the last address each record was updated to, so that it doesn't get
+	// updated again while the change is still propagating
+	pushedMu sync.Mutex
+	pushed   map[string]dynamicDNSPush
+}
+
+type dynamicDNSPush struct {
+	ip string
+	at time.Time
+}
+
+type dynamicDNSRecordStatus struct {
+	Name      string
+	Value     string
+	InSync    bool
+	ChangedAt time.Time
+	Error     string
+}
+
+// What gets persisted about the last change made to a record
+type dynamicDNSChange struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+const dynamicDNSPropagationWindow = 10 * time.Minute
+
+func (widget *dynamicDNSWidget) initialize() error {
+	widget.withTitle("Dynamic DNS").withCacheDuration(5 * time.Minute)
+
+	if len(widget.Records) == 0 {
+		return errors.New("at least one record is required")
+	}
+
+	switch widget.RecordType {
+	case "", "A":
+		widget.RecordType = "A"
+		if widget.IPURL == "" {
+			widget.IPURL = "https://api.ipify.org"
+		}
+	case "AAAA":
+		if widget.IPURL == "" {
+			widget.IPURL = "https://api6.ipify.org"
+		}
+	default:
+		return errors.New("record-type must be either A or AAAA")
+	}
+
+	switch widget.Provider {
+	case "":
+		if widget.Update {
+			return errors.New("update requires a provider")
+		}
+	case "cloudflare":
+		if widget.Token == "" || widget.ZoneID == "" {
+			return errors.New("token and zone-id are required for cloudflare")
+		}
+	case "desec", "duckdns":
+		if widget.Token == "" {
+			return fmt.Errorf("token is required for %s", widget.Provider)
+		}
+	default:
+		return errors.New("provider must be one of cloudflare, desec or duckdns")
+	}
+
+	for i := range widget.Records {
+		widget.Records[i] = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(widget.Records[i])), ".")
+
+		if widget.Provider == "duckdns" && !strings.HasSuffix(widget.Records[i], ".duckdns.org") {
+			widget.Records[i] += ".duckdns.org"
+		}
+	}
+
+	return nil
+}
+
+func (widget *dynamicDNSWidget) update(ctx context.Context) {
+	client := widget.httpClient(false)
+
+	publicIP, err := fetchPublicIP(client, widget.IPURL)
+	if err != nil {
+		widget.withError(fmt.Errorf("getting public IP: %w", err)).scheduleEarlyUpdate()
+		return
+	}
+
+	statuses := make([]dynamicDNSRecordStatus, len(widget.Records))
+	failed := 0
+
+	for i, name := range widget.Records {
+		status := dynamicDNSRecordStatus{Name: name}
+		value, _, err := widget.fetchRecord(client, name)
+
+		if err != nil {
+			failed++
+			status.Error = err.Error()
+		} else {
+			status.Value = value
+			status.InSync = value == publicIP
+		}
+
+		var change dynamicDNSChange
+		if widget.Providers.state.get(widget.changeStateKey(name), &change) {
+			status.ChangedAt = change.At
+		}
+
+		statuses[i] = status
+	}
+
+	if failed > 0 {
+		err = fmt.Errorf("%w: could not get %d record(s)", ternary(failed == len(statuses), errNoContent, errPartialContent), failed)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.PublicIP = publicIP
+	widget.Statuses = statuses
+}
+
+func (widget *dynamicDNSWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, dynamicDNSWidgetTemplate)
+}
+
+// Records are checked and updated in the background so that they're kept up
+// to date even when nobody is looking at the page
+func (widget *dynamicDNSWidget) runBackgroundTask(now time.Time) {
+	if !widget.Update || now.Sub(widget.lastCheck) < widget.cacheDuration {
+		return
+	}
+
+	widget.lastCheck = now
+	client := widget.httpClient(false)
+
+	publicIP, err := fetchPublicIP(client, widget.IPURL)
+	if err != nil {
+		widget.logger().Error("Failed to get public IP", "error", err)
+		return
+	}
+
+	widget.pushedMu.Lock()
+	defer widget.pushedMu.Unlock()
+
+	if widget.pushed == nil {
+		widget.pushed = make(map[string]dynamicDNSPush)
+	}
+
+	for _, name := range widget.Records {
+		if pushed, ok := widget.pushed[name]; ok && pushed.ip == publicIP && now.Sub(pushed.at) < dynamicDNSPropagationWindow {
+			continue
+		}
+
+		value, recordID, err := widget.fetchRecord(client, name)
+		if err != nil {
+			widget.logger().Error("Failed to get DNS record", "record", name, "error", err)
+			continue
+		}
+
+		if value == publicIP {
+			continue
+		}
+
+		if err := widget.updateRecord(client, name, recordID, publicIP); err != nil {
+			widget.logger().Error("Failed to update DNS record", "record", name, "error", err)
+			continue
+		}
+
+		widget.pushed[name] = dynamicDNSPush{ip: publicIP, at: now}
+		widget.Providers.state.set(widget.changeStateKey(name), dynamicDNSChange{From: value, To: publicIP, At: now})
+		widget.Providers.audit.record(nil, "Updated DNS record", name, value, publicIP)
+		widget.logger().Info("Updated DNS record", "record", name, "from", value, "to", publicIP)
+	}
+}
+
+func (widget *dynamicDNSWidget) changeStateKey(name string) string {
+	return "dynamic-dns:" + widget.RecordType + ":" + name
+}
+
+func fetchPublicIP(client requestDoer, ipURL string) (string, error) {
+	request, _ := http.NewRequest("GET", ipURL, nil)
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 256))
+	if err != nil {
+		return "", err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d from %s", response.StatusCode, ipURL)
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return "", fmt.Errorf("%s did not respond with an IP address", ipURL)
+	}
+
+	return ip.String(), nil
+}
+
+// Returns the current value of the record along with its ID for providers
+// that need it to update the record. Only Cloudflare has an API for reading
+// records, the rest are looked up through DNS over HTTPS
+func (widget *dynamicDNSWidget) fetchRecord(client requestDoer, name string) (string, string, error) {
+	if widget.Provider == "cloudflare" {
+		return fetchCloudflareRecord(client, widget.Token, widget.ZoneID, widget.RecordType, name)
+	}
+
+	value, err := resolveOverHTTPS(client, name, widget.RecordType)
+	return value, "", err
+}
+
+func (widget *dynamicDNSWidget) updateRecord(client requestDoer, name, recordID, ip string) error {
+	switch widget.Provider {
+	case "cloudflare":
+		return updateCloudflareRecord(client, widget.Token, widget.ZoneID, recordID, ip)
+	case "desec":
+		return updateDesecRecord(client, widget.Token, name, widget.RecordType, ip)
+	case "duckdns":
+		return updateDuckDNSRecord(client, widget.Token, name, widget.RecordType, ip)
+	}
+
+	return nil
+}
+
+type cloudflareRecordsResponseJson struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result []struct {
+		ID      string `json:"id"`
+		Content string `json:"content"`
+	} `json:"result"`
+}
+
+func fetchCloudflareRecord(client requestDoer, token, zoneID, recordType, name string) (string, string, error) {
+	query := url.Values{"type": {recordType}, "name": {name}}
+	request, _ := http.NewRequest("GET", "https://api.cloudflare.com/client/v4/zones/"+url.PathEscape(zoneID)+"/dns_records?"+query.Encode(), nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	response, err := decodeJsonFromRequest[cloudflareRecordsResponseJson](client, request)
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(response.Result) == 0 {
+		return "", "", fmt.Errorf("no %s record named %s, it has to be created first", recordType, name)
+	}
+
+	return response.Result[0].Content, response.Result[0].ID, nil
+}
+
+func updateCloudflareRecord(client requestDoer, token, zoneID, recordID, ip string) error {
+	body, _ := json.Marshal(map[string]string{"content": ip})
+	request, _ := http.NewRequest(
+		"PATCH",
+		"https://api.cloudflare.com/client/v4/zones/"+url.PathEscape(zoneID)+"/dns_records/"+url.PathEscape(recordID),
+		bytes.NewReader(body),
+	)
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := decodeJsonFromRequest[cloudflareRecordsResponseJson](client, request)
+	if err != nil {
+		return err
+	}
+
+	if !response.Success && len(response.Errors) > 0 {
+		return errors.New(response.Errors[0].Message)
+	}
+
+	return nil
+}
+
+func updateDesecRecord(client requestDoer, token, name, recordType, ip string) error {
+	query := url.Values{"hostname": {name}}
+	query.Set(ternary(recordType == "A", "myipv4", "myipv6"), ip)
+
+	request, _ := http.NewRequest("GET", "https://update.dedyn.io/?"+query.Encode(), nil)
+	request.Header.Set("Authorization", "Token "+token)
+
+	return expectPlainTextResponse(client, request, "good", "")
+}
+
+// DuckDNS only accepts the token as part of the URL
+func updateDuckDNSRecord(client requestDoer, token, name, recordType, ip string) error {
+	query := url.Values{
+		"domains": {strings.TrimSuffix(name, ".duckdns.org")},
+		"token":   {token},
+	}
+	query.Set(ternary(recordType == "A", "ip", "ipv6"), ip)
+
+	request, _ := http.NewRequest("GET", "https://www.duckdns.org/update?"+query.Encode(), nil)
+
+	return expectPlainTextResponse(client, request, "OK", url.QueryEscape(token))
+}
+
+func expectPlainTextResponse(client requestDoer, request *http.Request, expected, secret string) error {
+	response, err := client.Do(request)
+	if err != nil {
+		if secret != "" {
+			return &redactedError{err: err, secret: secret}
+		}
+
+		return err
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+	text := strings.TrimSpace(string(body))
+
+	if response.StatusCode != http.StatusOK || !strings.HasPrefix(text, expected) {
+		return fmt.Errorf("update failed with status code %d: %s", response.StatusCode, text)
+	}
+
+	return nil
+}
+
+type dnsOverHTTPSResponseJson struct {
+	Status int `json:"Status"`
+	Answer []struct {
+		Type int    `json:"type"`
+		Data string `json:"data"`
+	} `json:"Answer"`
+}
+
+// Goes through a public resolver rather than the one of the system, which
+// may return addresses from the local network for the same names
+func resolveOverHTTPS(client requestDoer, name, recordType string) (string, error) {
+	query := url.Values{"name": {name}, "type": {recordType}}
+	request, _ := http.NewRequest("GET", "https://cloudflare-dns.com/dns-query?"+query.Encode(), nil)
+	request.Header.Set("Accept", "application/dns-json")
+
+	response, err := decodeJsonFromRequest[dnsOverHTTPSResponseJson](client, request)
+	if err != nil {
+		return "", err
+	}
+
+	wantedType := ternary(recordType == "A", 1, 28)
+
+	for _, answer := range response.Answer {
+		if answer.Type == wantedType {
+			return answer.Data, nil
+		}
+	}
+
+	return "", nil
+}
+
+type dynamicDNSRecordJson struct {
+	Name      string     `json:"name"`
+	Value     string     `json:"value"`
+	InSync    bool       `json:"in_sync"`
+	ChangedAt *time.Time `json:"changed_at"`
+	Error     string     `json:"error,omitempty"`
+}
+
+func (widget *dynamicDNSWidget) data() any {
+	records := make([]dynamicDNSRecordJson, 0, len(widget.Statuses))
+
+	for _, status := range widget.Statuses {
+		records = append(records, dynamicDNSRecordJson{
+			Name:      status.Name,
+			Value:     status.Value,
+			InSync:    status.InSync,
+			ChangedAt: timeOrNil(status.ChangedAt),
+			Error:     status.Error,
+		})
+	}
+
+	return map[string]any{
+		"public_ip": widget.PublicIP,
+		"records":   records,
+	}
+}
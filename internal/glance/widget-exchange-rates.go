@@ -0,0 +1,159 @@
+package glance
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+type exchangeRatesWidget struct {
+	widgetBase   `yaml:",inline"`
+	PairRequests []exchangeRatePairRequest `yaml:"pairs"`
+	Sort         string                    `yaml:"sort-by"`
+	Markets      marketList                `yaml:"-"`
+}
+
+type exchangeRatePairRequest struct {
+	From       string `yaml:"from"`
+	To         string `yaml:"to"`
+	CustomName string `yaml:"name"`
+	ChartLink  string `yaml:"chart-link"`
+}
+
+func (widget *exchangeRatesWidget) initialize() error {
+	widget.withTitle("Exchange Rates").withCacheDuration(3 * time.Hour)
+
+	if len(widget.PairRequests) == 0 {
+		return fmt.Errorf("no currency pairs specified")
+	}
+
+	for i := range widget.PairRequests {
+		pair := &widget.PairRequests[i]
+		pair.From = strings.ToUpper(strings.TrimSpace(pair.From))
+		pair.To = strings.ToUpper(strings.TrimSpace(pair.To))
+
+		if pair.From == "" || pair.To == "" {
+			return fmt.Errorf("pair %d must have both from and to currencies", i+1)
+		}
+	}
+
+	return nil
+}
+
+func (widget *exchangeRatesWidget) update(ctx context.Context) {
+	markets, err := fetchExchangeRatesFromFrankfurter(widget.cachedHTTPClient(false), widget.PairRequests)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	if widget.Sort == "absolute-change" {
+		markets.sortByAbsChange()
+	} else if widget.Sort == "change" {
+		markets.sortByChange()
+	}
+
+	widget.Markets = markets
+}
+
+func (widget *exchangeRatesWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, marketsWidgetTemplate)
+}
+
+type frankfurterTimeSeriesResponseJson struct {
+	Rates map[string]map[string]float64 `json:"rates"`
+}
+
+// the ECB only publishes rates on working days, so fetch a bit more
+// than a week to always have at least a week's worth of data points
+const exchangeRateHistoryDays = 10
+
+func fetchExchangeRatesFromFrankfurter(client requestDoer, pairRequests []exchangeRatePairRequest) (marketList, error) {
+	startDate := time.Now().AddDate(0, 0, -exchangeRateHistoryDays).Format(time.DateOnly)
+	requests := make([]*http.Request, 0, len(pairRequests))
+
+	for i := range pairRequests {
+		request, _ := http.NewRequest("GET", fmt.Sprintf(
+			"https://api.frankfurter.app/%s..?from=%s&to=%s",
+			startDate,
+			pairRequests[i].From,
+			pairRequests[i].To,
+		), nil)
+		requests = append(requests, request)
+	}
+
+	job := newJob(decodeJsonFromRequestTask[frankfurterTimeSeriesResponseJson](client), requests)
+	responses, errs, err := workerPoolDo(job)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	markets := make(marketList, 0, len(responses))
+	var failed int
+
+	for i := range responses {
+		pair := &pairRequests[i]
+
+		if errs[i] != nil {
+			failed++
+			slog.Error("Failed to fetch exchange rate", "from", pair.From, "to", pair.To, "error", errs[i])
+			continue
+		}
+
+		dates := make([]string, 0, len(responses[i].Rates))
+		for date := range responses[i].Rates {
+			dates = append(dates, date)
+		}
+		slices.Sort(dates)
+
+		rates := make([]float64, 0, len(dates))
+		for _, date := range dates {
+			if rate, ok := responses[i].Rates[date][pair.To]; ok {
+				rates = append(rates, rate)
+			}
+		}
+
+		if len(rates) == 0 {
+			failed++
+			slog.Error("Exchange rate response contains no data", "from", pair.From, "to", pair.To)
+			continue
+		}
+
+		current := rates[len(rates)-1]
+		currency, exists := currencyToSymbol[pair.To]
+		if !exists {
+			currency = pair.To + " "
+		}
+
+		markets = append(markets, market{
+			marketRequest: marketRequest{
+				Symbol:    pair.From + "/" + pair.To,
+				ChartLink: pair.ChartLink,
+			},
+			Name: ternary(pair.CustomName == "",
+				"1 "+pair.From+" in "+pair.To,
+				pair.CustomName,
+			),
+			Price:          current,
+			Currency:       currency,
+			PriceHint:      ternary(current < 1, 4, 2),
+			PercentChange:  percentChange(current, rates[0]),
+			SvgChartPoints: svgPolylineCoordsFromYValues(100, 50, rates),
+		})
+	}
+
+	if len(markets) == 0 {
+		return nil, errNoContent
+	}
+
+	if failed > 0 {
+		return markets, fmt.Errorf("%w: could not fetch data for %d pair(s)", errPartialContent, failed)
+	}
+
+	return markets, nil
+}
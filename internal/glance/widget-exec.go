@@ -0,0 +1,209 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+var execWidgetTemplate = mustParseTemplate("exec.html", "widget-base.html")
+
+// Output beyond this many bytes is cut off, widgets aren't meant for displaying logs
+const execWidgetMaxOutputLength = 64 * 1024
+
+type execWidget struct {
+	widgetBase `yaml:",inline"`
+	Command    []string      `yaml:"command"`
+	Timeout    durationField `yaml:"timeout"`
+	Output     string        `yaml:"output"`
+	Fields     []struct {
+		Label string `yaml:"label"`
+		Path  string `yaml:"path"`
+	} `yaml:"fields"`
+	Text     string            `yaml:"-"`
+	Markdown template.HTML     `yaml:"-"`
+	Values   []execWidgetValue `yaml:"-"`
+}
+
+type execWidgetValue struct {
+	Label string
+	Value string
+}
+
+func (widget *execWidget) initialize() error {
+	widget.withTitle("Command").withCacheDuration(5 * time.Minute)
+
+	if len(widget.Command) == 0 || widget.Command[0] == "" {
+		return errors.New("command is required")
+	}
+
+	if widget.Timeout <= 0 {
+		widget.Timeout = durationField(10 * time.Second)
+	}
+
+	switch widget.Output {
+	case "":
+		widget.Output = "text"
+	case "text", "markdown":
+	case "json":
+		if len(widget.Fields) == 0 {
+			return errors.New("fields are required when output is json")
+		}
+
+		for i := range widget.Fields {
+			if widget.Fields[i].Path == "" {
+				return fmt.Errorf("field %d: path is required", i+1)
+			}
+
+			if widget.Fields[i].Label == "" {
+				widget.Fields[i].Label = widget.Fields[i].Path
+			}
+		}
+	default:
+		return errors.New("output must be one of text, markdown or json")
+	}
+
+	return nil
+}
+
+func (widget *execWidget) getCommands() [][]string {
+	return [][]string{widget.Command}
+}
+
+func (widget *execWidget) update(ctx context.Context) {
+	output, err := runWidgetCommand(ctx, widget.Command, time.Duration(widget.Timeout), nil)
+	if err == nil && widget.Output == "json" && !gjson.Valid(output) {
+		err = errors.New("command output is not valid JSON")
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Text = output
+
+	switch widget.Output {
+	case "markdown":
+		widget.Markdown = renderMarkdown(output)
+	case "json":
+		values := make([]execWidgetValue, 0, len(widget.Fields))
+		for _, field := range widget.Fields {
+			values = append(values, execWidgetValue{
+				Label: field.Label,
+				Value: gjson.Get(output, field.Path).String(),
+			})
+		}
+
+		widget.Values = values
+	}
+}
+
+func (widget *execWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, execWidgetTemplate)
+}
+
+// Commands are run directly rather than through a shell, so a shell has to be
+// part of the command for things like pipes to work. The environment variables
+// are in addition to the ones Glance was started with
+func runWidgetCommand(ctx context.Context, command []string, timeout time.Duration, env []string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := &limitedWriter{limit: execWidgetMaxOutputLength}
+	stderr := &limitedWriter{limit: 1024}
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// children of the command that keep its output open would otherwise keep
+	// the update waiting after the command itself was killed
+	cmd.WaitDelay = 2 * time.Second
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+
+		if output, _ := limitStringLength(strings.TrimSpace(stderr.String()), 256); output != "" {
+			err = fmt.Errorf("%v: %s", err, output)
+		}
+
+		return "", fmt.Errorf("running command: %v", err)
+	}
+
+	output := strings.TrimRight(stdout.String(), "\n")
+	if stdout.truncated {
+		output += "\n…"
+	}
+
+	return output, nil
+}
+
+// Keeps up to limit bytes of what gets written and discards the rest, so that
+// a command printing a lot doesn't fill up memory or block on a full pipe
+type limitedWriter struct {
+	buffer    bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	written := len(p)
+
+	if remaining := w.limit - w.buffer.Len(); written > remaining {
+		w.truncated = true
+		p = p[:max(0, remaining)]
+	}
+
+	w.buffer.Write(p)
+
+	return written, nil
+}
+
+// A character that was cut off at the limit gets dropped
+func (w *limitedWriter) String() string {
+	s := w.buffer.String()
+
+	if w.truncated {
+		for len(s) > 0 {
+			r, size := utf8.DecodeLastRuneInString(s)
+			if r != utf8.RuneError || size != 1 {
+				break
+			}
+			s = s[:len(s)-size]
+		}
+	}
+
+	return s
+}
+
+// Only the name of the program is checked, the arguments are up to the config
+func (c *securityConfig) commandIsAllowed(program string) bool {
+	return slices.Contains(c.AllowedCommands, program)
+}
+
+func (widget *execWidget) data() any {
+	if widget.Output != "json" {
+		return map[string]any{"output": widget.Text}
+	}
+
+	values := make(map[string]string, len(widget.Values))
+	for _, value := range widget.Values {
+		values[value.Label] = value.Value
+	}
+
+	return map[string]any{"fields": values}
+}
@@ -2,6 +2,7 @@ package glance
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html"
@@ -10,16 +11,22 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
 var extensionWidgetTemplate = mustParseTemplate("extension.html", "widget-base.html")
 
+// without a timeout, extensions are allowed to take as long as they need
+var extensionHTTPClient = &http.Client{Transport: newOutboundTransport(false)}
+
 const extensionWidgetDefaultTitle = "Extension"
 
 type extensionWidget struct {
 	widgetBase          `yaml:",inline"`
 	URL                 string               `yaml:"url"`
+	Command             []string             `yaml:"command"`
+	Timeout             durationField        `yaml:"timeout"`
 	FallbackContentType string               `yaml:"fallback-content-type"`
 	Parameters          queryParametersField `yaml:"parameters"`
 	Headers             map[string]string    `yaml:"headers"`
@@ -31,25 +38,51 @@ type extensionWidget struct {
 func (widget *extensionWidget) initialize() error {
 	widget.withTitle(extensionWidgetDefaultTitle).withCacheDuration(time.Minute * 30)
 
-	if widget.URL == "" {
-		return errors.New("URL is required")
+	if widget.URL == "" && len(widget.Command) == 0 {
+		return errors.New("either url or command is required")
+	}
+
+	if widget.URL != "" && len(widget.Command) > 0 {
+		return errors.New("only one of url or command can be specified")
 	}
 
 	if _, err := url.Parse(widget.URL); err != nil {
 		return fmt.Errorf("parsing URL: %v", err)
 	}
 
+	if len(widget.Command) > 0 && widget.Timeout <= 0 {
+		widget.Timeout = durationField(extensionCommandDefaultTimeout)
+	}
+
 	return nil
 }
 
+func (widget *extensionWidget) getCommands() [][]string {
+	return [][]string{widget.Command}
+}
+
 func (widget *extensionWidget) update(ctx context.Context) {
-	extension, err := fetchExtension(extensionRequestOptions{
+	options := extensionRequestOptions{
 		URL:                 widget.URL,
 		FallbackContentType: widget.FallbackContentType,
 		Parameters:          widget.Parameters,
 		Headers:             widget.Headers,
 		AllowHtml:           widget.AllowHtml,
-	})
+	}
+
+	var extension extension
+	var err error
+
+	if len(widget.Command) > 0 {
+		extension, err = runExtensionCommand(ctx, widget.Command, time.Duration(widget.Timeout), options)
+	} else {
+		var client requestDoer = extensionHTTPClient
+		if widget.HTTPOptions != nil {
+			client = widget.httpClient(false)
+		}
+
+		extension, err = fetchExtension(client, options)
+	}
 
 	widget.canContinueUpdateAfterHandlingErr(err)
 
@@ -74,11 +107,13 @@ type extensionType int
 
 const (
 	extensionContentHTML extensionType = iota
+	extensionContentJSON
 	extensionContentUnknown
 )
 
 var extensionStringToType = map[string]extensionType{
 	"html": extensionContentHTML,
+	"json": extensionContentJSON,
 }
 
 const (
@@ -97,10 +132,40 @@ type extensionRequestOptions struct {
 }
 
 type extension struct {
-	Title     string
-	TitleURL  string
-	Content   template.HTML
-	Frameless bool
+	Title         string
+	TitleURL      string
+	Content       template.HTML
+	Frameless     bool
+	Items         []extensionItem
+	CollapseAfter int
+}
+
+type extensionItem struct {
+	Title       string
+	URL         string
+	Description string
+	Meta        []string
+	Time        time.Time
+}
+
+// The response of extensions with the json content type, which is also what
+// commands have to output. Items get displayed using the same styles as the
+// built-in widgets, so they don't require allowing HTML
+type extensionResponseJson struct {
+	Title         string `json:"title"`
+	TitleURL      string `json:"title_url"`
+	Frameless     bool   `json:"frameless"`
+	Error         string `json:"error"`
+	HTML          string `json:"html"`
+	Text          string `json:"text"`
+	CollapseAfter int    `json:"collapse_after"`
+	Items         []struct {
+		Title       string   `json:"title"`
+		URL         string   `json:"url"`
+		Description string   `json:"description"`
+		Meta        []string `json:"meta"`
+		Time        string   `json:"time"`
+	} `json:"items"`
 }
 
 func convertExtensionContent(options extensionRequestOptions, content []byte, contentType extensionType) template.HTML {
@@ -116,7 +181,7 @@ func convertExtensionContent(options extensionRequestOptions, content []byte, co
 	}
 }
 
-func fetchExtension(options extensionRequestOptions) (extension, error) {
+func fetchExtension(client requestDoer, options extensionRequestOptions) (extension, error) {
 	request, _ := http.NewRequest("GET", options.URL, nil)
 	if len(options.Parameters) > 0 {
 		request.URL.RawQuery = options.Parameters.toQueryString()
@@ -126,7 +191,7 @@ func fetchExtension(options extensionRequestOptions) (extension, error) {
 		request.Header.Add(key, value)
 	}
 
-	response, err := http.DefaultClient.Do(request)
+	response, err := client.Do(request)
 	if err != nil {
 		slog.Error("Failed fetching extension", "url", options.URL, "error", err)
 		return extension{}, fmt.Errorf("%w: request failed: %w", errNoContent, err)
@@ -166,7 +231,85 @@ func fetchExtension(options extensionRequestOptions) (extension, error) {
 		extension.Frameless = true
 	}
 
+	if contentType == extensionContentJSON {
+		return parseExtensionJson(extension, options, body)
+	}
+
 	extension.Content = convertExtensionContent(options, body, contentType)
 
 	return extension, nil
 }
+
+// Values set in the response take precedence over the ones from the headers,
+// and an error in the response is treated the same as a failed request unless
+// there's also something to display
+func parseExtensionJson(extension extension, options extensionRequestOptions, body []byte) (extension, error) {
+	var response extensionResponseJson
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return extension, fmt.Errorf("%w: parsing response: %v", errNoContent, err)
+	}
+
+	if response.Title != "" {
+		extension.Title = response.Title
+	}
+
+	if response.TitleURL != "" {
+		extension.TitleURL = response.TitleURL
+	}
+
+	extension.Frameless = extension.Frameless || response.Frameless
+	extension.CollapseAfter = ternary(response.CollapseAfter == 0 || response.CollapseAfter < -1, 5, response.CollapseAfter)
+
+	if response.HTML != "" {
+		extension.Content = convertExtensionContent(options, []byte(response.HTML), extensionContentHTML)
+	} else if response.Text != "" {
+		extension.Content = convertExtensionContent(options, []byte(response.Text), extensionContentUnknown)
+	}
+
+	for _, item := range response.Items {
+		parsed := extensionItem{
+			Title:       item.Title,
+			URL:         item.URL,
+			Description: item.Description,
+			Meta:        item.Meta,
+		}
+
+		if item.Time != "" {
+			parsed.Time = parseRFC3339Time(item.Time)
+		}
+
+		extension.Items = append(extension.Items, parsed)
+	}
+
+	if response.Error != "" {
+		if extension.Content == "" && len(extension.Items) == 0 {
+			return extension, fmt.Errorf("%w: %s", errNoContent, response.Error)
+		}
+
+		return extension, fmt.Errorf("%w: %s", errPartialContent, response.Error)
+	}
+
+	return extension, nil
+}
+
+const extensionCommandDefaultTimeout = 30 * time.Second
+
+// Parameters are passed to commands as environment variables, such as
+// GLANCE_PARAMETER_LOCATION for a parameter named location, with multiple
+// values being separated by commas
+func runExtensionCommand(ctx context.Context, command []string, timeout time.Duration, options extensionRequestOptions) (extension, error) {
+	env := make([]string, 0, len(options.Parameters))
+
+	for key, values := range options.Parameters {
+		name := "GLANCE_PARAMETER_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+		env = append(env, name+"="+strings.Join(values, ","))
+	}
+
+	output, err := runWidgetCommand(ctx, command, timeout, env)
+	if err != nil {
+		return extension{}, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	return parseExtensionJson(extension{Title: extensionWidgetDefaultTitle}, options, []byte(output))
+}
@@ -0,0 +1,288 @@
+package glance
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var f1WidgetTemplate = mustParseTemplate("f1.html", "widget-base.html")
+
+const jolpicaAPIURL = "https://api.jolpi.ca/ergast/f1"
+
+type f1Widget struct {
+	widgetBase    `yaml:",inline"`
+	HideStandings bool                    `yaml:"hide-standings"`
+	CollapseAfter int                     `yaml:"collapse-after"`
+	Race          *f1Race                 `yaml:"-"`
+	Drivers       []f1DriverStanding      `yaml:"-"`
+	Constructors  []f1ConstructorStanding `yaml:"-"`
+}
+
+type f1Race struct {
+	Round    string
+	Name     string
+	Circuit  string
+	Location string
+	URL      string
+	Sessions []f1Session
+}
+
+type f1Session struct {
+	Name     string
+	StartsAt time.Time
+}
+
+type f1DriverStanding struct {
+	Position    string
+	Name        string
+	Constructor string
+	Points      string
+}
+
+type f1ConstructorStanding struct {
+	Position string
+	Name     string
+	Points   string
+}
+
+func (widget *f1Widget) initialize() error {
+	widget.withTitle("Formula 1").withCacheDuration(time.Hour)
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *f1Widget) update(ctx context.Context) {
+	race, err := fetchNextF1Race(widget.cachedHTTPClient(false))
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Race = race
+
+	if widget.HideStandings {
+		return
+	}
+
+	// the standings are secondary to the schedule so failing to fetch them isn't
+	// treated as an error, the previous ones are kept instead
+	if drivers, err := fetchF1DriverStandings(widget.cachedHTTPClient(false)); err != nil {
+		widget.logger().Error("Failed to fetch F1 driver standings", "error", err)
+	} else {
+		widget.Drivers = drivers
+	}
+
+	if constructors, err := fetchF1ConstructorStandings(widget.cachedHTTPClient(false)); err != nil {
+		widget.logger().Error("Failed to fetch F1 constructor standings", "error", err)
+	} else {
+		widget.Constructors = constructors
+	}
+}
+
+func (widget *f1Widget) Render() template.HTML {
+	return widget.renderTemplate(widget, f1WidgetTemplate)
+}
+
+type jolpicaSessionJson struct {
+	Date string `json:"date"`
+	Time string `json:"time"`
+}
+
+type jolpicaRaceJson struct {
+	Round    string `json:"round"`
+	RaceName string `json:"raceName"`
+	URL      string `json:"url"`
+	Date     string `json:"date"`
+	Time     string `json:"time"`
+	Circuit  struct {
+		CircuitName string `json:"circuitName"`
+		Location    struct {
+			Locality string `json:"locality"`
+			Country  string `json:"country"`
+		} `json:"Location"`
+	} `json:"Circuit"`
+	FirstPractice    *jolpicaSessionJson `json:"FirstPractice"`
+	SecondPractice   *jolpicaSessionJson `json:"SecondPractice"`
+	ThirdPractice    *jolpicaSessionJson `json:"ThirdPractice"`
+	SprintQualifying *jolpicaSessionJson `json:"SprintQualifying"`
+	SprintShootout   *jolpicaSessionJson `json:"SprintShootout"`
+	Sprint           *jolpicaSessionJson `json:"Sprint"`
+	Qualifying       *jolpicaSessionJson `json:"Qualifying"`
+}
+
+type jolpicaScheduleResponseJson struct {
+	MRData struct {
+		RaceTable struct {
+			Races []jolpicaRaceJson `json:"Races"`
+		} `json:"RaceTable"`
+	} `json:"MRData"`
+}
+
+// Session times are in UTC, e.g. a date of 2025-03-16 and a time of 04:00:00Z
+func parseJolpicaSessionTime(date, clock string) time.Time {
+	if clock == "" {
+		clock = "00:00:00Z"
+	}
+
+	t, err := time.Parse(time.RFC3339, date+"T"+clock)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+func fetchNextF1Race(client requestDoer) (*f1Race, error) {
+	request, _ := http.NewRequest("GET", jolpicaAPIURL+"/current/next.json", nil)
+	response, err := decodeJsonFromRequest[jolpicaScheduleResponseJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// between seasons there's no next race
+	if len(response.MRData.RaceTable.Races) == 0 {
+		return nil, nil
+	}
+
+	data := &response.MRData.RaceTable.Races[0]
+
+	race := &f1Race{
+		Round:    data.Round,
+		Name:     data.RaceName,
+		Circuit:  data.Circuit.CircuitName,
+		Location: data.Circuit.Location.Locality + ", " + data.Circuit.Location.Country,
+		URL:      data.URL,
+	}
+
+	sessions := []struct {
+		name    string
+		session *jolpicaSessionJson
+	}{
+		{"Practice 1", data.FirstPractice},
+		{"Practice 2", data.SecondPractice},
+		{"Practice 3", data.ThirdPractice},
+		{"Sprint Qualifying", data.SprintQualifying},
+		{"Sprint Shootout", data.SprintShootout},
+		{"Sprint", data.Sprint},
+		{"Qualifying", data.Qualifying},
+		{"Race", &jolpicaSessionJson{Date: data.Date, Time: data.Time}},
+	}
+
+	for i := range sessions {
+		if sessions[i].session == nil {
+			continue
+		}
+
+		startsAt := parseJolpicaSessionTime(sessions[i].session.Date, sessions[i].session.Time)
+		if startsAt.IsZero() {
+			continue
+		}
+
+		race.Sessions = append(race.Sessions, f1Session{Name: sessions[i].name, StartsAt: startsAt})
+	}
+
+	sort.SliceStable(race.Sessions, func(i, j int) bool {
+		return race.Sessions[i].StartsAt.Before(race.Sessions[j].StartsAt)
+	})
+
+	return race, nil
+}
+
+type jolpicaStandingsResponseJson struct {
+	MRData struct {
+		StandingsTable struct {
+			StandingsLists []struct {
+				DriverStandings []struct {
+					Position string `json:"position"`
+					Points   string `json:"points"`
+					Driver   struct {
+						GivenName  string `json:"givenName"`
+						FamilyName string `json:"familyName"`
+					} `json:"Driver"`
+					Constructors []struct {
+						Name string `json:"name"`
+					} `json:"Constructors"`
+				} `json:"DriverStandings"`
+				ConstructorStandings []struct {
+					Position    string `json:"position"`
+					Points      string `json:"points"`
+					Constructor struct {
+						Name string `json:"name"`
+					} `json:"Constructor"`
+				} `json:"ConstructorStandings"`
+			} `json:"StandingsLists"`
+		} `json:"StandingsTable"`
+	} `json:"MRData"`
+}
+
+func fetchF1Standings(client requestDoer, kind string) (*jolpicaStandingsResponseJson, error) {
+	request, _ := http.NewRequest("GET", jolpicaAPIURL+"/current/"+kind+".json", nil)
+	response, err := decodeJsonFromRequest[jolpicaStandingsResponseJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+func fetchF1DriverStandings(client requestDoer) ([]f1DriverStanding, error) {
+	response, err := fetchF1Standings(client, "driverStandings")
+	if err != nil {
+		return nil, err
+	}
+
+	// no standings until the first race of the season
+	if len(response.MRData.StandingsTable.StandingsLists) == 0 {
+		return nil, nil
+	}
+
+	list := response.MRData.StandingsTable.StandingsLists[0].DriverStandings
+	standings := make([]f1DriverStanding, 0, len(list))
+
+	for i := range list {
+		standing := f1DriverStanding{
+			Position: list[i].Position,
+			Name:     list[i].Driver.GivenName + " " + list[i].Driver.FamilyName,
+			Points:   list[i].Points,
+		}
+
+		if len(list[i].Constructors) > 0 {
+			standing.Constructor = list[i].Constructors[len(list[i].Constructors)-1].Name
+		}
+
+		standings = append(standings, standing)
+	}
+
+	return standings, nil
+}
+
+func fetchF1ConstructorStandings(client requestDoer) ([]f1ConstructorStanding, error) {
+	response, err := fetchF1Standings(client, "constructorStandings")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.MRData.StandingsTable.StandingsLists) == 0 {
+		return nil, nil
+	}
+
+	list := response.MRData.StandingsTable.StandingsLists[0].ConstructorStandings
+	standings := make([]f1ConstructorStanding, 0, len(list))
+
+	for i := range list {
+		standings = append(standings, f1ConstructorStanding{
+			Position: list[i].Position,
+			Name:     list[i].Constructor.Name,
+			Points:   list[i].Points,
+		})
+	}
+
+	return standings, nil
+}
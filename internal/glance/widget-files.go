@@ -0,0 +1,286 @@
+package glance
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var filesWidgetTemplate = mustParseTemplate("files.html", "widget-base.html")
+
+// SVGs are left out since they can contain scripts
+var filePreviewExtensions = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".avif": "image/avif",
+	".bmp":  "image/bmp",
+}
+
+const maxFilePreviewSize = 20 * 1024 * 1024
+
+type filesWidget struct {
+	widgetBase      `yaml:",inline"`
+	Path            string      `yaml:"path"`
+	Include         []string    `yaml:"include"`
+	Exclude         []string    `yaml:"exclude"`
+	Recursive       bool        `yaml:"recursive"`
+	ShowHidden      bool        `yaml:"show-hidden"`
+	ShowDirectories bool        `yaml:"show-directories"`
+	SortBy          string      `yaml:"sort-by"`
+	Limit           int         `yaml:"limit"`
+	CollapseAfter   int         `yaml:"collapse-after"`
+	Previews        bool        `yaml:"previews"`
+	URLTemplate     string      `yaml:"url-template"`
+	Files           []localFile `yaml:"-"`
+	previews        filePreviews
+}
+
+type localFile struct {
+	Name        string
+	Directory   string
+	Size        int64
+	ModifiedAt  time.Time
+	IsDirectory bool
+	URL         string
+	PreviewURL  string
+	path        string
+}
+
+func (f localFile) FormattedSize() string {
+	return formatNetworkUsageBytes(uint64(f.Size))
+}
+
+func (widget *filesWidget) initialize() error {
+	widget.withTitle("Files").withCacheDuration(time.Minute)
+
+	if widget.Path == "" {
+		return errors.New("path is required")
+	}
+
+	widget.Path = filepath.Clean(widget.Path)
+
+	for _, pattern := range slices.Concat(widget.Include, widget.Exclude) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %s", pattern)
+		}
+	}
+
+	switch widget.SortBy {
+	case "":
+		widget.SortBy = "modified"
+	case "modified", "name", "size":
+	default:
+		return errors.New("sort-by must be one of modified, name or size")
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 10
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *filesWidget) update(ctx context.Context) {
+	files, err := widget.listFiles()
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	switch widget.SortBy {
+	case "name":
+		slices.SortFunc(files, func(a, b localFile) int {
+			return strings.Compare(strings.ToLower(a.path), strings.ToLower(b.path))
+		})
+	case "size":
+		slices.SortStableFunc(files, func(a, b localFile) int {
+			return cmp.Compare(b.Size, a.Size)
+		})
+	default:
+		slices.SortFunc(files, func(a, b localFile) int {
+			return b.ModifiedAt.Compare(a.ModifiedAt)
+		})
+	}
+
+	if len(files) > widget.Limit {
+		files = files[:widget.Limit]
+	}
+
+	previews := make(map[string]string)
+
+	for i := range files {
+		file := &files[i]
+		relative := filepath.ToSlash(file.path)
+
+		if widget.URLTemplate != "" {
+			file.URL = strings.ReplaceAll(widget.URLTemplate, "{PATH}", escapeFilePath(relative))
+		}
+
+		if widget.Previews && !file.IsDirectory && file.Size <= maxFilePreviewSize {
+			if _, ok := filePreviewExtensions[strings.ToLower(path.Ext(file.Name))]; ok {
+				hash := sha256.Sum256([]byte(relative))
+				key := hex.EncodeToString(hash[:8])
+				previews[key] = filepath.Join(widget.Path, file.path)
+				file.PreviewURL = widget.Providers.widgetURLResolver(widget.GetID(), "preview?key="+key)
+			}
+		}
+	}
+
+	widget.previews.commit(previews)
+	widget.Files = files
+}
+
+func (widget *filesWidget) listFiles() ([]localFile, error) {
+	files := make([]localFile, 0)
+
+	err := filepath.WalkDir(widget.Path, func(fullPath string, entry fs.DirEntry, err error) error {
+		if fullPath == widget.Path {
+			return err
+		}
+
+		if err != nil {
+			// skip what can't be read rather than failing the whole listing
+			return nil
+		}
+
+		name := entry.Name()
+		isDirectory := entry.IsDir()
+
+		// excluded directories are left out along with everything in them
+		if !widget.ShowHidden && strings.HasPrefix(name, ".") || matchesAnyPattern(name, widget.Exclude) {
+			return ternary(isDirectory, fs.SkipDir, nil)
+		}
+
+		// only the contents of the top level directory are listed unless recursive
+		next := ternary(isDirectory && !widget.Recursive, fs.SkipDir, nil)
+
+		if isDirectory && !widget.ShowDirectories {
+			return next
+		}
+
+		if !isDirectory && len(widget.Include) > 0 && !matchesAnyPattern(name, widget.Include) {
+			return nil
+		}
+
+		// follows symlinks so that they show the size of what they point to
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			return nil
+		}
+
+		relative, _ := filepath.Rel(widget.Path, fullPath)
+		directory := filepath.Dir(relative)
+
+		files = append(files, localFile{
+			Name:        name,
+			Directory:   ternary(directory == ".", "", filepath.ToSlash(directory)),
+			Size:        ternary(info.IsDir(), 0, info.Size()),
+			ModifiedAt:  info.ModTime(),
+			IsDirectory: info.IsDir(),
+			path:        relative,
+		})
+
+		return next
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	return files, nil
+}
+
+func matchesAnyPattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func escapeFilePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func (widget *filesWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || r.PathValue("path") != "preview" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	widget.previews.serve(w, r)
+}
+
+func (widget *filesWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, filesWidgetTemplate)
+}
+
+// Only files that were listed during the last update can be requested, so
+// that nothing else on the disk can be read through the widget
+type filePreviews struct {
+	mu    sync.Mutex
+	paths map[string]string
+}
+
+func (p *filePreviews) commit(paths map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.paths = paths
+}
+
+func (p *filePreviews) serve(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	filePath, exists := p.paths[r.URL.Query().Get("key")]
+	p.mu.Unlock()
+
+	if !exists {
+		http.Error(w, "preview not found", http.StatusNotFound)
+		return
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		http.Error(w, "preview not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxFilePreviewSize {
+		http.Error(w, "preview not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", filePreviewExtensions[strings.ToLower(filepath.Ext(filePath))])
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
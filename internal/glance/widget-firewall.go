@@ -0,0 +1,532 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+var firewallWidgetTemplate = mustParseTemplate("firewall.html", "widget-base.html")
+
+type firewallWidget struct {
+	widgetBase    `yaml:",inline"`
+	Service       string         `yaml:"service"`
+	URL           string         `yaml:"url"`
+	Key           string         `yaml:"key"`
+	Secret        string         `yaml:"secret"`
+	WANInterface  string         `yaml:"wan-interface"`
+	CountryLookup bool           `yaml:"country-lookup"`
+	BlockedLimit  int            `yaml:"blocked-limit"`
+	AllowInsecure bool           `yaml:"allow-insecure"`
+	Status        firewallStatus `yaml:"-"`
+
+	countriesMu sync.Mutex
+	countries   map[string]string
+}
+
+func (widget *firewallWidget) initialize() error {
+	widget.withTitle("Firewall").withCacheDuration(5 * time.Minute)
+
+	if widget.URL == "" {
+		return errors.New("url is required")
+	}
+
+	widget.URL = strings.TrimRight(widget.URL, "/")
+
+	switch widget.Service {
+	case "opnsense":
+		if widget.Key == "" || widget.Secret == "" {
+			return errors.New("key and secret are required for opnsense")
+		}
+	case "pfsense":
+		if widget.Key == "" {
+			return errors.New("key is required for pfsense")
+		}
+	default:
+		return errors.New("service must be either opnsense or pfsense")
+	}
+
+	if widget.WANInterface == "" {
+		widget.WANInterface = "wan"
+	}
+
+	if widget.BlockedLimit <= 0 {
+		widget.BlockedLimit = 5
+	}
+
+	widget.withTitleURL(widget.URL)
+
+	return nil
+}
+
+func (widget *firewallWidget) update(ctx context.Context) {
+	client := widget.httpClient(widget.AllowInsecure)
+	status := firewallStatus{}
+	var failed []string
+
+	address, err := widget.fetchWANAddress(client)
+	if err != nil {
+		failed = append(failed, fmt.Sprintf("interfaces: %v", err))
+	} else {
+		status.WANAddress = address
+	}
+
+	gateways, err := widget.fetchGateways(client)
+	if err != nil {
+		failed = append(failed, fmt.Sprintf("gateways: %v", err))
+	} else {
+		status.Gateways = gateways
+		status.GatewaysIsAvailable = true
+	}
+
+	sources, err := widget.fetchBlockedSources(client)
+	if err != nil {
+		failed = append(failed, fmt.Sprintf("firewall log: %v", err))
+	} else {
+		status.Blocked, status.BlockedTotal = widget.groupBlockedSources(sources)
+		status.BlockedIsAvailable = true
+	}
+
+	firmware, err := widget.fetchFirmware(client)
+	if err != nil {
+		failed = append(failed, fmt.Sprintf("firmware: %v", err))
+	} else {
+		status.Firmware = firmware
+		status.FirmwareIsAvailable = true
+	}
+
+	if len(failed) > 0 {
+		if len(failed) == 4 {
+			err = fmt.Errorf("%w: %s", errNoContent, strings.Join(failed, "; "))
+		} else {
+			err = fmt.Errorf("%w: %s", errPartialContent, strings.Join(failed, "; "))
+		}
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Status = status
+}
+
+func (widget *firewallWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, firewallWidgetTemplate)
+}
+
+type firewallStatus struct {
+	WANAddress          string
+	Gateways            []firewallGateway
+	GatewaysIsAvailable bool
+	Blocked             []firewallBlockedGroup
+	BlockedTotal        int
+	BlockedIsAvailable  bool
+	Firmware            firewallFirmware
+	FirmwareIsAvailable bool
+}
+
+type firewallGateway struct {
+	Name    string
+	Address string
+	Online  bool
+	Status  string
+	Delay   string
+	Loss    string
+}
+
+type firewallBlockedGroup struct {
+	Label       string
+	CountryCode string
+	Count       int
+}
+
+type firewallFirmware struct {
+	Version         string
+	LatestVersion   string
+	UpdateAvailable bool
+}
+
+func (widget *firewallWidget) get(client requestDoer, path string) (gjson.Result, error) {
+	request, _ := http.NewRequest("GET", widget.URL+path, nil)
+
+	if widget.Service == "opnsense" {
+		request.SetBasicAuth(widget.Key, widget.Secret)
+	} else {
+		request.Header.Set("X-API-Key", widget.Key)
+	}
+
+	response, err := decodeJsonFromRequest[json.RawMessage](client, request)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	result := gjson.ParseBytes(response)
+
+	// all responses of the pfSense REST API are wrapped
+	if widget.Service == "pfsense" {
+		result = result.Get("data")
+	}
+
+	return result, nil
+}
+
+func (widget *firewallWidget) fetchWANAddress(client requestDoer) (string, error) {
+	if widget.Service == "opnsense" {
+		response, err := widget.get(client, "/api/interfaces/overview/interfacesInfo")
+		if err != nil {
+			return "", err
+		}
+
+		for _, row := range response.Get("rows").Array() {
+			if row.Get("identifier").String() != widget.WANInterface {
+				continue
+			}
+
+			// the address includes the subnet, such as 203.0.113.5/24
+			address, _, _ := strings.Cut(row.Get("addr4").String(), "/")
+			if address == "" {
+				address = row.Get("ipv4.0.ipaddr").String()
+			}
+
+			return address, nil
+		}
+
+		return "", fmt.Errorf("interface %s not found", widget.WANInterface)
+	}
+
+	response, err := widget.get(client, "/api/v2/status/interfaces")
+	if err != nil {
+		return "", err
+	}
+
+	for _, row := range response.Array() {
+		if row.Get("name").String() == widget.WANInterface || strings.EqualFold(row.Get("descr").String(), widget.WANInterface) {
+			return row.Get("ipaddr").String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("interface %s not found", widget.WANInterface)
+}
+
+func (widget *firewallWidget) fetchGateways(client requestDoer) ([]firewallGateway, error) {
+	var rows []gjson.Result
+
+	if widget.Service == "opnsense" {
+		response, err := widget.get(client, "/api/routes/gateway/status")
+		if err != nil {
+			return nil, err
+		}
+
+		rows = response.Get("items").Array()
+	} else {
+		response, err := widget.get(client, "/api/v2/status/gateways")
+		if err != nil {
+			return nil, err
+		}
+
+		rows = response.Array()
+	}
+
+	gateways := make([]firewallGateway, 0, len(rows))
+
+	for _, row := range rows {
+		gateway := firewallGateway{
+			Name:  row.Get("name").String(),
+			Delay: row.Get("delay").String(),
+			Loss:  row.Get("loss").String(),
+		}
+
+		if widget.Service == "opnsense" {
+			gateway.Address = row.Get("address").String()
+			gateway.Status = row.Get("status_translated").String()
+			// "none" means that nothing is wrong with the gateway
+			gateway.Online = row.Get("status").String() == "none"
+		} else {
+			gateway.Address = row.Get("monitorip").String()
+			gateway.Status = row.Get("status").String()
+			gateway.Online = strings.EqualFold(gateway.Status, "online") || gateway.Status == "none"
+		}
+
+		if gateway.Status == "" {
+			gateway.Status = ternary(gateway.Online, "Online", "Offline")
+		}
+
+		gateways = append(gateways, gateway)
+	}
+
+	return gateways, nil
+}
+
+// Only the source addresses of blocked inbound connections are used
+func (widget *firewallWidget) fetchBlockedSources(client requestDoer) ([]string, error) {
+	var sources []string
+
+	if widget.Service == "opnsense" {
+		response, err := widget.get(client, "/api/diagnostics/firewall/log?limit=1000")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range response.Array() {
+			if entry.Get("action").String() == "block" && entry.Get("dir").String() != "out" {
+				sources = append(sources, entry.Get("src").String())
+			}
+		}
+
+		return sources, nil
+	}
+
+	response, err := widget.get(client, "/api/v2/status/logs/firewall?limit=1000")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range response.Array() {
+		if source := parsePfsenseFilterlogBlockedSource(entry.Get("text").String()); source != "" {
+			sources = append(sources, source)
+		}
+	}
+
+	return sources, nil
+}
+
+// The lines of pfSense's firewall log are in the filterlog format, which is
+// comma separated with the position of the source address depending on the
+// IP version, see https://docs.netgate.com/pfsense/en/latest/monitoring/logs/raw-filter-format.html
+func parsePfsenseFilterlogBlockedSource(line string) string {
+	if index := strings.Index(line, "filterlog"); index != -1 {
+		if _, rest, found := strings.Cut(line[index:], ": "); found {
+			line = rest
+		}
+	}
+
+	fields := strings.Split(line, ",")
+	if len(fields) < 9 || fields[6] != "block" || fields[7] != "in" {
+		return ""
+	}
+
+	switch fields[8] {
+	case "4":
+		if len(fields) > 18 {
+			return fields[18]
+		}
+	case "6":
+		if len(fields) > 15 {
+			return fields[15]
+		}
+	}
+
+	return ""
+}
+
+func (widget *firewallWidget) fetchFirmware(client requestDoer) (firewallFirmware, error) {
+	firmware := firewallFirmware{}
+
+	if widget.Service == "opnsense" {
+		// the result of the last check for updates, which OPNsense does
+		// on its own and when visiting the firmware page
+		response, err := widget.get(client, "/api/core/firmware/status")
+		if err != nil {
+			return firmware, err
+		}
+
+		firmware.Version = response.Get("product.product_version").String()
+		firmware.LatestVersion = response.Get("product.product_latest").String()
+		status := response.Get("status").String()
+		firmware.UpdateAvailable = status == "update" || status == "upgrade"
+
+		return firmware, nil
+	}
+
+	response, err := widget.get(client, "/api/v2/system/version/upgrade")
+	if err != nil {
+		return firmware, err
+	}
+
+	firmware.Version = response.Get("installed_version").String()
+	firmware.LatestVersion = response.Get("latest_version").String()
+	firmware.UpdateAvailable = response.Get("update_available").Bool()
+
+	return firmware, nil
+}
+
+func (widget *firewallWidget) groupBlockedSources(sources []string) ([]firewallBlockedGroup, int) {
+	countries := map[string]string{}
+	if widget.CountryLookup {
+		var err error
+		countries, err = widget.lookupCountries(sources)
+		if err != nil {
+			widget.logger().Warn("Failed to look up the countries of blocked addresses", "error", err)
+		}
+	}
+
+	groups := make(map[string]*firewallBlockedGroup)
+
+	for _, source := range sources {
+		key := source
+		group := firewallBlockedGroup{Label: source}
+
+		if ip := net.ParseIP(source); ip != nil && isPrivateAddress(ip) {
+			key = "private"
+			group.Label = "Private network"
+		} else if country, ok := countries[source]; ok {
+			code, name, _ := strings.Cut(country, ":")
+			key = code
+			group = firewallBlockedGroup{Label: name, CountryCode: code}
+		}
+
+		if existing, ok := groups[key]; ok {
+			existing.Count++
+		} else {
+			group.Count = 1
+			groups[key] = &group
+		}
+	}
+
+	sorted := make([]firewallBlockedGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, *group)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+
+		return sorted[i].Label < sorted[j].Label
+	})
+
+	if len(sorted) > widget.BlockedLimit {
+		sorted = sorted[:widget.BlockedLimit]
+	}
+
+	return sorted, len(sources)
+}
+
+const firewallMaxCachedCountries = 5000
+
+// Looks up the countries of public addresses using ip-api.com, in batches of
+// up to 100 addresses which is the most it accepts. Countries are kept in the
+// format code:name and cached since the same addresses tend to show up again
+func (widget *firewallWidget) lookupCountries(sources []string) (map[string]string, error) {
+	widget.countriesMu.Lock()
+	defer widget.countriesMu.Unlock()
+
+	if widget.countries == nil || len(widget.countries) > firewallMaxCachedCountries {
+		widget.countries = make(map[string]string)
+	}
+
+	var missing []string
+	seen := make(map[string]bool)
+
+	for _, source := range sources {
+		ip := net.ParseIP(source)
+		if ip == nil || isPrivateAddress(ip) || seen[source] {
+			continue
+		}
+
+		seen[source] = true
+
+		if _, ok := widget.countries[source]; !ok {
+			missing = append(missing, source)
+		}
+	}
+
+	client := widget.httpClient(false)
+
+	for start := 0; start < len(missing); start += 100 {
+		batch := missing[start:min(start+100, len(missing))]
+		body, _ := json.Marshal(batch)
+
+		request, _ := http.NewRequest("POST", "http://ip-api.com/batch?fields=status,query,countryCode,country", bytes.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+
+		response, err := decodeJsonFromRequest[[]struct {
+			Status      string `json:"status"`
+			Query       string `json:"query"`
+			CountryCode string `json:"countryCode"`
+			Country     string `json:"country"`
+		}](client, request)
+		if err != nil {
+			return widget.knownCountries(seen), err
+		}
+
+		for _, result := range response {
+			if result.Status == "success" && result.CountryCode != "" {
+				widget.countries[result.Query] = result.CountryCode + ":" + result.Country
+			}
+		}
+	}
+
+	return widget.knownCountries(seen), nil
+}
+
+func (widget *firewallWidget) knownCountries(sources map[string]bool) map[string]string {
+	countries := make(map[string]string, len(sources))
+
+	for source := range sources {
+		if country, ok := widget.countries[source]; ok {
+			countries[source] = country
+		}
+	}
+
+	return countries
+}
+
+func (widget *firewallWidget) data() any {
+	status := widget.Status
+	data := map[string]any{
+		"wan_address": status.WANAddress,
+	}
+
+	if status.GatewaysIsAvailable {
+		gateways := make([]map[string]any, 0, len(status.Gateways))
+		for _, gateway := range status.Gateways {
+			gateways = append(gateways, map[string]any{
+				"name":    gateway.Name,
+				"address": gateway.Address,
+				"online":  gateway.Online,
+				"status":  gateway.Status,
+				"delay":   gateway.Delay,
+				"loss":    gateway.Loss,
+			})
+		}
+
+		data["gateways"] = gateways
+	}
+
+	if status.BlockedIsAvailable {
+		blocked := make([]map[string]any, 0, len(status.Blocked))
+		for _, group := range status.Blocked {
+			blocked = append(blocked, map[string]any{
+				"label":        group.Label,
+				"country_code": group.CountryCode,
+				"count":        group.Count,
+			})
+		}
+
+		data["blocked_total"] = status.BlockedTotal
+		data["blocked"] = blocked
+	}
+
+	if status.FirmwareIsAvailable {
+		data["firmware"] = map[string]any{
+			"version":          status.Firmware.Version,
+			"latest_version":   status.Firmware.LatestVersion,
+			"update_available": status.Firmware.UpdateAvailable,
+		}
+	}
+
+	return data
+}
@@ -0,0 +1,409 @@
+package glance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var fitnessWidgetTemplate = mustParseTemplate("fitness.html", "widget-base.html")
+
+const fitnessTrendDays = 7
+
+type fitnessWidget struct {
+	widgetBase   `yaml:",inline"`
+	ProviderName string          `yaml:"provider"`
+	ClientID     string          `yaml:"client-id"`
+	ClientSecret string          `yaml:"client-secret"`
+	RefreshToken string          `yaml:"refresh-token"`
+	Metrics      []fitnessMetric `yaml:"-"`
+	provider     fitnessProvider `yaml:"-"`
+}
+
+// Implemented by each of the supported services. Days are returned in
+// chronological order with the last one being today, metrics which aren't
+// available for a given day are left as 0.
+type fitnessProvider interface {
+	fetchDays(count int) ([]fitnessDay, error)
+	// the label used for the sleep metric since not all services provide a sleep score
+	sleepLabel() string
+	// the label used for the heart rate metric since not all services provide a resting heart rate
+	heartRateLabel() string
+}
+
+type fitnessDay struct {
+	Steps     int
+	Sleep     int
+	HeartRate int
+}
+
+func (widget *fitnessWidget) initialize() error {
+	widget.withTitle("Fitness").withCacheDuration(30 * time.Minute)
+
+	if widget.ClientID == "" || widget.ClientSecret == "" || widget.RefreshToken == "" {
+		return errors.New("client-id, client-secret and refresh-token are required")
+	}
+
+	switch widget.ProviderName {
+	case "fitbit":
+		client := widget.httpClient(false)
+		widget.provider = &fitbitProvider{
+			client:    client,
+			token:     newRefreshableToken(widget.RefreshToken, fitbitRefreshTokenFunc(client, widget.ClientID, widget.ClientSecret)),
+			storePath: widget.tokenStorePath,
+		}
+	case "withings":
+		client := widget.httpClient(false)
+		widget.provider = &withingsProvider{
+			client:    client,
+			token:     newRefreshableToken(widget.RefreshToken, withingsRefreshTokenFunc(client, widget.ClientID, widget.ClientSecret)),
+			storePath: widget.tokenStorePath,
+		}
+	case "garmin":
+		return errors.New("garmin is not supported since it doesn't offer an API for personal use, must be either fitbit or withings")
+	case "":
+		return errors.New("provider is required")
+	default:
+		return fmt.Errorf("unsupported provider %s, must be either fitbit or withings", widget.ProviderName)
+	}
+
+	return nil
+}
+
+// evaluated lazily since the data path only becomes available after initialization
+func (widget *fitnessWidget) tokenStorePath() string {
+	if widget.Providers == nil || widget.Providers.dataPath == "" {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(widget.ClientID))
+	return filepath.Join(widget.Providers.dataPath, "tokens-"+widget.ProviderName+"-"+hex.EncodeToString(hash[:4])+".json")
+}
+
+func (widget *fitnessWidget) update(ctx context.Context) {
+	days, err := widget.provider.fetchDays(fitnessTrendDays)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	steps := make([]float64, len(days))
+	sleep := make([]float64, len(days))
+	heartRate := make([]float64, len(days))
+
+	for i := range days {
+		steps[i] = float64(days[i].Steps)
+		sleep[i] = float64(days[i].Sleep)
+		heartRate[i] = float64(days[i].HeartRate)
+	}
+
+	widget.Metrics = []fitnessMetric{
+		newFitnessMetric("Steps", steps),
+		newFitnessMetric(widget.provider.sleepLabel(), sleep),
+		newFitnessMetric(widget.provider.heartRateLabel(), heartRate),
+	}
+}
+
+func (widget *fitnessWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, fitnessWidgetTemplate)
+}
+
+type fitnessMetric struct {
+	Label          string
+	Value          int
+	HasValue       bool
+	SvgChartPoints string
+}
+
+func newFitnessMetric(label string, values []float64) fitnessMetric {
+	metric := fitnessMetric{Label: label}
+
+	if len(values) > 0 && values[len(values)-1] != 0 {
+		metric.Value = int(values[len(values)-1])
+		metric.HasValue = true
+	}
+
+	values = maybeCopySliceWithoutZeroValues(values)
+	if len(values) >= 2 && slices.Min(values) != slices.Max(values) {
+		metric.SvgChartPoints = svgPolylineCoordsFromYValues(100, 50, values)
+	}
+
+	return metric
+}
+
+func fitnessDates(count int) []time.Time {
+	today := time.Now()
+	dates := make([]time.Time, count)
+
+	for i := range dates {
+		dates[i] = today.AddDate(0, 0, i-count+1)
+	}
+
+	return dates
+}
+
+func fitnessDayIndex(dates []time.Time, date string) int {
+	for i := range dates {
+		if dates[i].Format(time.DateOnly) == date {
+			return i
+		}
+	}
+
+	return -1
+}
+
+type fitbitProvider struct {
+	client    requestDoer
+	token     *refreshableToken
+	storePath func() string
+}
+
+func fitbitRefreshTokenFunc(client requestDoer, clientID, clientSecret string) func(string) (oauthTokens, error) {
+	return func(refreshToken string) (oauthTokens, error) {
+		body := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+		}
+
+		request, _ := http.NewRequest("POST", "https://api.fitbit.com/oauth2/token", strings.NewReader(body.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.SetBasicAuth(clientID, clientSecret)
+
+		return decodeJsonFromRequest[oauthTokens](client, request)
+	}
+}
+
+type fitbitStepsResponseJson struct {
+	Steps []struct {
+		DateTime string `json:"dateTime"`
+		Value    string `json:"value"`
+	} `json:"activities-steps"`
+}
+
+type fitbitHeartRateResponseJson struct {
+	Heart []struct {
+		DateTime string `json:"dateTime"`
+		Value    struct {
+			RestingHeartRate int `json:"restingHeartRate"`
+		} `json:"value"`
+	} `json:"activities-heart"`
+}
+
+type fitbitSleepResponseJson struct {
+	Sleep []struct {
+		DateOfSleep string `json:"dateOfSleep"`
+		Efficiency  int    `json:"efficiency"`
+		IsMainSleep bool   `json:"isMainSleep"`
+	} `json:"sleep"`
+}
+
+func fitbitRequest[T any](provider *fitbitProvider, path string) (T, error) {
+	accessToken, err := provider.token.get(provider.storePath())
+	if err != nil {
+		var empty T
+		return empty, err
+	}
+
+	request, _ := http.NewRequest("GET", "https://api.fitbit.com"+path, nil)
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+
+	response, err := decodeJsonFromRequest[T](provider.client, request)
+	if err != nil && strings.Contains(err.Error(), "status code 401") {
+		provider.token.invalidate()
+	}
+
+	return response, err
+}
+
+func (p *fitbitProvider) sleepLabel() string {
+	return "Sleep efficiency"
+}
+
+func (p *fitbitProvider) heartRateLabel() string {
+	return "Resting heart rate"
+}
+
+func (p *fitbitProvider) fetchDays(count int) ([]fitnessDay, error) {
+	dates := fitnessDates(count)
+	days := make([]fitnessDay, count)
+	period := strconv.Itoa(count) + "d"
+	start := dates[0].Format(time.DateOnly)
+	end := dates[count-1].Format(time.DateOnly)
+
+	steps, err := fitbitRequest[fitbitStepsResponseJson](p, "/1/user/-/activities/steps/date/"+end+"/"+period+".json")
+	if err != nil {
+		return nil, fmt.Errorf("%w: fetching steps: %v", errNoContent, err)
+	}
+
+	for _, entry := range steps.Steps {
+		if i := fitnessDayIndex(dates, entry.DateTime); i != -1 {
+			days[i].Steps, _ = strconv.Atoi(entry.Value)
+		}
+	}
+
+	var failed []string
+
+	heart, err := fitbitRequest[fitbitHeartRateResponseJson](p, "/1/user/-/activities/heart/date/"+end+"/"+period+".json")
+	if err != nil {
+		failed = append(failed, "heart rate")
+	} else {
+		for _, entry := range heart.Heart {
+			if i := fitnessDayIndex(dates, entry.DateTime); i != -1 {
+				days[i].HeartRate = entry.Value.RestingHeartRate
+			}
+		}
+	}
+
+	sleep, err := fitbitRequest[fitbitSleepResponseJson](p, "/1.2/user/-/sleep/date/"+start+"/"+end+".json")
+	if err != nil {
+		failed = append(failed, "sleep")
+	} else {
+		for _, entry := range sleep.Sleep {
+			if !entry.IsMainSleep {
+				continue
+			}
+
+			if i := fitnessDayIndex(dates, entry.DateOfSleep); i != -1 {
+				days[i].Sleep = entry.Efficiency
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return days, fmt.Errorf("%w: could not fetch %s", errPartialContent, strings.Join(failed, " and "))
+	}
+
+	return days, nil
+}
+
+type withingsProvider struct {
+	client    requestDoer
+	token     *refreshableToken
+	storePath func() string
+}
+
+// Withings wraps all of its responses in an envelope and indicates errors through the status property
+type withingsResponseJson[T any] struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+	Body   T      `json:"body"`
+}
+
+func withingsRequest[T any](client requestDoer, endpoint string, params url.Values, accessToken string) (T, error) {
+	request, _ := http.NewRequest("POST", "https://wbsapi.withings.net"+endpoint, strings.NewReader(params.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if accessToken != "" {
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	response, err := decodeJsonFromRequest[withingsResponseJson[T]](client, request)
+	if err != nil {
+		return response.Body, err
+	}
+
+	if response.Status != 0 {
+		return response.Body, fmt.Errorf("withings responded with status %d: %s", response.Status, response.Error)
+	}
+
+	return response.Body, nil
+}
+
+func withingsRefreshTokenFunc(client requestDoer, clientID, clientSecret string) func(string) (oauthTokens, error) {
+	return func(refreshToken string) (oauthTokens, error) {
+		return withingsRequest[oauthTokens](client, "/v2/oauth2", url.Values{
+			"action":        {"requesttoken"},
+			"grant_type":    {"refresh_token"},
+			"client_id":     {clientID},
+			"client_secret": {clientSecret},
+			"refresh_token": {refreshToken},
+		}, "")
+	}
+}
+
+type withingsActivityResponseJson struct {
+	Activities []struct {
+		Date  string `json:"date"`
+		Steps int    `json:"steps"`
+	} `json:"activities"`
+}
+
+type withingsSleepSummaryResponseJson struct {
+	Series []struct {
+		Date string `json:"date"`
+		Data struct {
+			SleepScore int `json:"sleep_score"`
+			HrMin      int `json:"hr_min"`
+		} `json:"data"`
+	} `json:"series"`
+}
+
+func (p *withingsProvider) sleepLabel() string {
+	return "Sleep score"
+}
+
+func (p *withingsProvider) heartRateLabel() string {
+	return "Sleeping heart rate"
+}
+
+func (p *withingsProvider) fetchDays(count int) ([]fitnessDay, error) {
+	accessToken, err := p.token.get(p.storePath())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	dates := fitnessDates(count)
+	days := make([]fitnessDay, count)
+	start := dates[0].Format(time.DateOnly)
+	end := dates[count-1].Format(time.DateOnly)
+
+	activity, err := withingsRequest[withingsActivityResponseJson](p.client, "/v2/measure", url.Values{
+		"action":       {"getactivity"},
+		"startdateymd": {start},
+		"enddateymd":   {end},
+		"data_fields":  {"steps"},
+	}, accessToken)
+	if err != nil {
+		// status 401 indicates an invalid token
+		if strings.Contains(err.Error(), "status 401") {
+			p.token.invalidate()
+		}
+
+		return nil, fmt.Errorf("%w: fetching activity: %v", errNoContent, err)
+	}
+
+	for _, entry := range activity.Activities {
+		if i := fitnessDayIndex(dates, entry.Date); i != -1 {
+			days[i].Steps = entry.Steps
+		}
+	}
+
+	sleep, err := withingsRequest[withingsSleepSummaryResponseJson](p.client, "/v2/sleep", url.Values{
+		"action":       {"getsummary"},
+		"startdateymd": {start},
+		"enddateymd":   {end},
+		"data_fields":  {"sleep_score,hr_min"},
+	}, accessToken)
+	if err != nil {
+		return days, fmt.Errorf("%w: could not fetch sleep: %v", errPartialContent, err)
+	}
+
+	for _, entry := range sleep.Series {
+		if i := fitnessDayIndex(dates, entry.Date); i != -1 {
+			days[i].Sleep = entry.Data.SleepScore
+			days[i].HeartRate = entry.Data.HrMin
+		}
+	}
+
+	return days, nil
+}
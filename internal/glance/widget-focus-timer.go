@@ -0,0 +1,325 @@
+package glance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var focusTimerWidgetTemplate = mustParseTemplate("focus-timer.html", "widget-base.html")
+
+const (
+	focusTimerPhaseWork       = "work"
+	focusTimerPhaseShortBreak = "short-break"
+	focusTimerPhaseLongBreak  = "long-break"
+)
+
+type focusTimerWidget struct {
+	widgetBase     `yaml:",inline"`
+	Work           durationField    `yaml:"work"`
+	ShortBreak     durationField    `yaml:"short-break"`
+	LongBreak      durationField    `yaml:"long-break"`
+	LongBreakEvery int              `yaml:"long-break-every"`
+	AutoStart      bool             `yaml:"auto-start"`
+	Notify         []string         `yaml:"notify"`
+	State          focusTimerStatus `yaml:"-"`
+	stateMutex     sync.Mutex       `yaml:"-"`
+}
+
+// Kept in the state store so that the timer carries on across reloads and is
+// the same on every device
+type focusTimerState struct {
+	Phase string `json:"phase"`
+	// when the current phase ends while it's running, in unix milliseconds
+	EndsAt int64 `json:"ends_at,omitempty"`
+	// how much of the current phase is left while it's paused
+	Remaining int64 `json:"remaining,omitempty"`
+	Running   bool  `json:"running"`
+	// the number of work sessions finished since the timer was last reset
+	Completed int `json:"completed"`
+}
+
+// What gets rendered and sent to the browser, with times relative to now so
+// that it doesn't matter if the browser's clock is off
+type focusTimerStatus struct {
+	Phase       string `json:"phase"`
+	Running     bool   `json:"running"`
+	RemainingMs int64  `json:"remaining_ms"`
+	DurationMs  int64  `json:"duration_ms"`
+	Completed   int    `json:"completed"`
+	// how many work sessions are left until the next long break
+	UntilLongBreak int `json:"until_long_break"`
+}
+
+// As minutes and seconds, rounded up so that it only shows 00:00 once it's over
+func (s focusTimerStatus) FormattedRemaining() string {
+	seconds := (s.RemainingMs + 999) / 1000
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+func (widget *focusTimerWidget) initialize() error {
+	widget.withTitle("Focus Timer").withError(nil)
+
+	if widget.Work == 0 {
+		widget.Work = durationField(25 * time.Minute)
+	}
+
+	if widget.ShortBreak == 0 {
+		widget.ShortBreak = durationField(5 * time.Minute)
+	}
+
+	if widget.LongBreak == 0 {
+		widget.LongBreak = durationField(15 * time.Minute)
+	}
+
+	if widget.Work < durationField(time.Minute) || widget.ShortBreak < durationField(time.Minute) || widget.LongBreak < durationField(time.Minute) {
+		return errors.New("work, short-break and long-break must be at least 1m")
+	}
+
+	if widget.LongBreakEvery == 0 {
+		widget.LongBreakEvery = 4
+	} else if widget.LongBreakEvery < 0 {
+		return errors.New("long-break-every must be a positive number")
+	}
+
+	return nil
+}
+
+func (widget *focusTimerWidget) getNotificationTargets() []string {
+	return widget.Notify
+}
+
+func (widget *focusTimerWidget) stateKey() string {
+	return "focus-timer:" + widget.stateScope()
+}
+
+func (widget *focusTimerWidget) phaseDuration(phase string) time.Duration {
+	switch phase {
+	case focusTimerPhaseShortBreak:
+		return time.Duration(widget.ShortBreak)
+	case focusTimerPhaseLongBreak:
+		return time.Duration(widget.LongBreak)
+	}
+
+	return time.Duration(widget.Work)
+}
+
+// must be called with the lock held
+func (widget *focusTimerWidget) getState() focusTimerState {
+	var state focusTimerState
+	if !widget.Providers.state.get(widget.stateKey(), &state) || state.Phase == "" {
+		state = focusTimerState{Phase: focusTimerPhaseWork}
+	}
+
+	return state
+}
+
+// Moves on to the next phase once the running one is over, returns the phase
+// that ended if there was one. Must be called with the lock held.
+func (widget *focusTimerWidget) advance(state *focusTimerState, now time.Time) string {
+	if !state.Running || now.UnixMilli() < state.EndsAt {
+		return ""
+	}
+
+	ended := state.Phase
+	endedAt := state.EndsAt
+
+	if ended == focusTimerPhaseWork {
+		state.Completed++
+		state.Phase = ternary(state.Completed%widget.LongBreakEvery == 0, focusTimerPhaseLongBreak, focusTimerPhaseShortBreak)
+	} else {
+		state.Phase = focusTimerPhaseWork
+	}
+
+	duration := widget.phaseDuration(state.Phase).Milliseconds()
+
+	// the next phase starts from when the previous one ended rather than from
+	// when it was noticed, unless it's been long enough for that one to be over too
+	if widget.AutoStart && now.UnixMilli() < endedAt+duration {
+		state.EndsAt = endedAt + duration
+		state.Remaining = 0
+	} else {
+		state.Running = false
+		state.EndsAt = 0
+		state.Remaining = duration
+	}
+
+	return ended
+}
+
+func (widget *focusTimerWidget) status(state focusTimerState, now time.Time) focusTimerStatus {
+	status := focusTimerStatus{
+		Phase:      state.Phase,
+		Running:    state.Running,
+		DurationMs: widget.phaseDuration(state.Phase).Milliseconds(),
+		Completed:  state.Completed,
+	}
+
+	switch {
+	case state.Running:
+		status.RemainingMs = max(0, state.EndsAt-now.UnixMilli())
+	case state.Remaining > 0:
+		status.RemainingMs = state.Remaining
+	default:
+		status.RemainingMs = status.DurationMs
+	}
+
+	status.UntilLongBreak = widget.LongBreakEvery - state.Completed%widget.LongBreakEvery
+
+	return status
+}
+
+// Sends the notification for sessions that ended while nobody was looking,
+// within a minute of them ending
+func (widget *focusTimerWidget) runBackgroundTask(now time.Time) {
+	widget.stateMutex.Lock()
+	defer widget.stateMutex.Unlock()
+
+	state := widget.getState()
+	if ended := widget.advance(&state, now); ended != "" {
+		widget.Providers.state.set(widget.stateKey(), state)
+		widget.notifyPhaseEnded(ended, state)
+	}
+}
+
+func (widget *focusTimerWidget) notifyPhaseEnded(ended string, state focusTimerState) {
+	if len(widget.Notify) == 0 {
+		return
+	}
+
+	n := notification{Title: "Break's over", Message: "Time to focus again"}
+
+	if ended == focusTimerPhaseWork {
+		n.Title = "Focus session done"
+		n.Message = ternary(state.Phase == focusTimerPhaseLongBreak, "Time for a long break", "Time for a short break")
+	}
+
+	if state.Running {
+		n.Message += ", which has already started"
+	}
+
+	// sent in the background so that the lock isn't held while waiting on it
+	go widget.Providers.notifier.notify(widget.Notify, n)
+}
+
+func (widget *focusTimerWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	var action string
+
+	switch {
+	case r.PathValue("path") == "status" && r.Method == http.MethodGet:
+	case r.PathValue("path") == "action" && r.Method == http.MethodPost:
+		var body struct {
+			Action string `json:"action"`
+		}
+
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&body); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		action = body.Action
+		if action == "" {
+			http.Error(w, "unknown action", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+
+	widget.stateMutex.Lock()
+	defer widget.stateMutex.Unlock()
+
+	state := widget.getState()
+	ended := widget.advance(&state, now)
+	if ended != "" {
+		widget.notifyPhaseEnded(ended, state)
+	}
+
+	switch action {
+	case "":
+	case "start":
+		if !state.Running {
+			remaining := state.Remaining
+			if remaining <= 0 {
+				remaining = widget.phaseDuration(state.Phase).Milliseconds()
+			}
+
+			state.Running = true
+			state.EndsAt = now.UnixMilli() + remaining
+			state.Remaining = 0
+		}
+	case "pause":
+		if state.Running {
+			state.Running = false
+			state.Remaining = max(0, state.EndsAt-now.UnixMilli())
+			state.EndsAt = 0
+		}
+	case "skip":
+		// skipped work sessions don't count as finished
+		if state.Phase == focusTimerPhaseWork {
+			state.Phase = ternary((state.Completed+1)%widget.LongBreakEvery == 0, focusTimerPhaseLongBreak, focusTimerPhaseShortBreak)
+		} else {
+			state.Phase = focusTimerPhaseWork
+		}
+
+		state.Running, state.EndsAt, state.Remaining = false, 0, 0
+	case "reset":
+		state = focusTimerState{Phase: focusTimerPhaseWork}
+	default:
+		http.Error(w, "unknown action", http.StatusBadRequest)
+		return
+	}
+
+	// checking on the status only changes it when a phase has ended
+	if action != "" || ended != "" {
+		widget.Providers.state.set(widget.stateKey(), state)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(widget.status(state, now))
+}
+
+func (widget *focusTimerWidget) StatusJSON() string {
+	encoded, _ := json.Marshal(widget.State)
+	return string(encoded)
+}
+
+// The text that the script changes as the timer goes, in the widget's locale
+func (widget *focusTimerWidget) LabelsJSON() string {
+	l := getLocale(widget.locale())
+
+	encoded, _ := json.Marshal(map[string]string{
+		focusTimerPhaseWork:       l.translate("Focus"),
+		focusTimerPhaseShortBreak: l.translate("Short break"),
+		focusTimerPhaseLongBreak:  l.translate("Long break"),
+		"done":                    l.translate("done"),
+		"long_break_in":           l.translate("long break in"),
+		"start":                   l.translate("Start"),
+		"pause":                   l.translate("Pause"),
+	})
+
+	return string(encoded)
+}
+
+func (widget *focusTimerWidget) Render() template.HTML {
+	now := time.Now()
+
+	widget.stateMutex.Lock()
+	state := widget.getState()
+	if ended := widget.advance(&state, now); ended != "" {
+		widget.Providers.state.set(widget.stateKey(), state)
+		widget.notifyPhaseEnded(ended, state)
+	}
+	widget.State = widget.status(state, now)
+	widget.stateMutex.Unlock()
+
+	return widget.renderTemplate(widget, focusTimerWidgetTemplate)
+}
@@ -0,0 +1,365 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var gardenWidgetTemplate = mustParseTemplate("garden.html", "widget-base.html")
+
+// the maximum number of times kept per task of each plant
+const gardenHistoryMaxLength = 10
+
+const (
+	gardenTaskWater     = "water"
+	gardenTaskFertilize = "fertilize"
+)
+
+type gardenWidget struct {
+	widgetBase    `yaml:",inline"`
+	Location      string                      `yaml:"location"`
+	Units         string                      `yaml:"units"`
+	RainThreshold float64                     `yaml:"rain-threshold"`
+	Plants        []gardenPlant               `yaml:"plants"`
+	Place         *openMeteoPlaceResponseJson `yaml:"-"`
+	Rain          *gardenRain                 `yaml:"-"`
+	Items         []gardenPlantItem           `yaml:"-"`
+	stateMutex    sync.Mutex                  `yaml:"-"`
+	// guards Rain being set, see weatherWidget
+	rainMu sync.Mutex
+}
+
+type gardenPlant struct {
+	Name           string        `yaml:"name"`
+	Indoor         bool          `yaml:"indoor"`
+	WaterEvery     durationField `yaml:"water-every"`
+	FertilizeEvery durationField `yaml:"fertilize-every"`
+}
+
+type gardenPlantState struct {
+	Watered    []int64 `json:"watered"`
+	Fertilized []int64 `json:"fertilized"`
+}
+
+// How much it rained in the last 24 hours and how much it's expected to in the
+// next 24, along with when it last rained enough to count as watering
+type gardenRain struct {
+	Past     float64
+	Next     float64
+	LastRain time.Time
+}
+
+type gardenPlantItem struct {
+	Name           string
+	Tasks          []gardenTaskItem
+	NeedsAttention bool
+}
+
+type gardenTaskItem struct {
+	Key          string
+	Task         string
+	LastDone     time.Time
+	DueAt        time.Time
+	IsDone       bool
+	ByRain       bool
+	RainExpected bool
+	IsLate       bool
+}
+
+func (widget *gardenWidget) initialize() error {
+	widget.withTitle("Garden").withCacheOnTheHour()
+
+	if len(widget.Plants) == 0 {
+		return errors.New("no plants specified")
+	}
+
+	if widget.Units != "" && widget.Units != "metric" && widget.Units != "imperial" {
+		return errors.New("units must be either metric or imperial")
+	}
+
+	if widget.RainThreshold < 0 {
+		return errors.New("rain-threshold must be a positive number")
+	}
+
+	names := make(map[string]struct{}, len(widget.Plants))
+
+	for i := range widget.Plants {
+		p := &widget.Plants[i]
+
+		if p.Name == "" {
+			return fmt.Errorf("plant %d has no name", i+1)
+		}
+
+		if _, exists := names[p.Name]; exists {
+			return fmt.Errorf("multiple plants with the name %s", p.Name)
+		}
+
+		names[p.Name] = struct{}{}
+
+		if p.WaterEvery == 0 && p.FertilizeEvery == 0 {
+			return fmt.Errorf("plant %s: at least one of water-every or fertilize-every is required", p.Name)
+		}
+	}
+
+	return nil
+}
+
+func (widget *gardenWidget) RainUnit() string {
+	return ternary(widget.units(widget.Units) == "metric", "mm", "in")
+}
+
+// Enough rain to skip watering outdoor plants, 5mm by default
+func (widget *gardenWidget) rainThreshold() float64 {
+	if widget.RainThreshold > 0 {
+		return widget.RainThreshold
+	}
+
+	return ternary(widget.units(widget.Units) == "metric", 5.0, 0.2)
+}
+
+// Only the rain is fetched, the tasks are worked out when rendering so that
+// check-offs show up straight away
+func (widget *gardenWidget) update(ctx context.Context) {
+	if widget.Location == "" {
+		widget.canContinueUpdateAfterHandlingErr(nil)
+		return
+	}
+
+	if widget.Place == nil {
+		place, err := fetchOpenMeteoPlaceFromName(widget.cachedHTTPClient(false), widget.Location)
+		if err != nil {
+			widget.canContinueUpdateAfterHandlingErr(fmt.Errorf("%w: %v", errPartialContent, err))
+			return
+		}
+
+		widget.Place = place
+	}
+
+	rain, err := fetchGardenRain(ctx, widget.cachedHTTPClient(false), widget.Place, widget.units(widget.Units), widget.rainThreshold())
+	if err != nil {
+		widget.canContinueUpdateAfterHandlingErr(fmt.Errorf("%w: fetching rain: %v", errPartialContent, err))
+		return
+	}
+
+	// the last time it rained is kept so that it still counts once it's more
+	// than a day ago, for plants that don't need watering that often
+	var lastRain int64
+	widget.Providers.state.get(widget.rainStateKey(), &lastRain)
+
+	if rain.LastRain.Unix() > lastRain {
+		widget.Providers.state.set(widget.rainStateKey(), rain.LastRain.Unix())
+	} else if lastRain > 0 {
+		rain.LastRain = time.Unix(lastRain, 0)
+	}
+
+	widget.canContinueUpdateAfterHandlingErr(nil)
+
+	widget.rainMu.Lock()
+	widget.Rain = rain
+	widget.rainMu.Unlock()
+}
+
+type openMeteoPrecipitationResponseJson struct {
+	Hourly struct {
+		Time          []int64   `json:"time"`
+		Precipitation []float64 `json:"precipitation"`
+	} `json:"hourly"`
+}
+
+func fetchGardenRain(ctx context.Context, client requestDoer, place *openMeteoPlaceResponseJson, units string, threshold float64) (*gardenRain, error) {
+	query := url.Values{}
+	query.Add("latitude", fmt.Sprintf("%f", place.Latitude))
+	query.Add("longitude", fmt.Sprintf("%f", place.Longitude))
+	query.Add("timeformat", "unixtime")
+	query.Add("timezone", place.Timezone)
+	query.Add("past_days", "1")
+	query.Add("forecast_days", "2")
+	query.Add("hourly", "precipitation")
+	query.Add("precipitation_unit", ternary(units == "imperial", "inch", "mm"))
+
+	request, _ := http.NewRequestWithContext(ctx, "GET", "https://api.open-meteo.com/v1/forecast?"+query.Encode(), nil)
+	response, err := decodeJsonFromRequest[openMeteoPrecipitationResponseJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	times := response.Hourly.Time
+	precipitation := response.Hourly.Precipitation
+	if len(times) == 0 || len(times) != len(precipitation) {
+		return nil, errors.New("no hourly precipitation in response")
+	}
+
+	now := time.Now().Unix()
+	rain := &gardenRain{}
+	var rainedUntil int64
+
+	// each hour holds the precipitation of the hour leading up to it
+	for i := range times {
+		if times[i] <= now && times[i] > now-24*60*60 {
+			rain.Past += precipitation[i]
+			if precipitation[i] > 0 {
+				rainedUntil = times[i]
+			}
+		} else if times[i] > now && times[i] <= now+24*60*60 {
+			rain.Next += precipitation[i]
+		}
+	}
+
+	if rain.Past >= threshold && rainedUntil > 0 {
+		rain.LastRain = time.Unix(rainedUntil, 0)
+	}
+
+	return rain, nil
+}
+
+func (widget *gardenWidget) rainStateKey() string {
+	return "garden-rain:" + widget.Location
+}
+
+func (widget *gardenWidget) stateKey(p *gardenPlant) string {
+	return "garden:" + widget.stateScope() + ":" + p.Name
+}
+
+func (widget *gardenWidget) getState(p *gardenPlant) gardenPlantState {
+	var state gardenPlantState
+	widget.Providers.state.get(widget.stateKey(p), &state)
+
+	return state
+}
+
+func (s *gardenPlantState) history(task string) *[]int64 {
+	if task == gardenTaskWater {
+		return &s.Watered
+	}
+
+	return &s.Fertilized
+}
+
+func (p *gardenPlant) interval(task string) time.Duration {
+	if task == gardenTaskWater {
+		return time.Duration(p.WaterEvery)
+	}
+
+	return time.Duration(p.FertilizeEvery)
+}
+
+func (widget *gardenWidget) buildItems(now time.Time) []gardenPlantItem {
+	items := make([]gardenPlantItem, 0, len(widget.Plants))
+	threshold := widget.rainThreshold()
+
+	for i := range widget.Plants {
+		p := &widget.Plants[i]
+		state := widget.getState(p)
+		item := gardenPlantItem{Name: p.Name}
+
+		for _, task := range []string{gardenTaskWater, gardenTaskFertilize} {
+			interval := p.interval(task)
+			if interval == 0 {
+				continue
+			}
+
+			taskItem := gardenTaskItem{
+				Key:  task + ":" + p.Name,
+				Task: task,
+			}
+
+			if history := *state.history(task); len(history) > 0 {
+				taskItem.LastDone = time.Unix(history[len(history)-1], 0)
+			}
+
+			lastDone := taskItem.LastDone
+			rainApplies := task == gardenTaskWater && !p.Indoor && widget.Rain != nil
+
+			if rainApplies && widget.Rain.LastRain.After(lastDone) {
+				lastDone = widget.Rain.LastRain
+				taskItem.LastDone = lastDone
+				taskItem.ByRain = true
+			}
+
+			if lastDone.IsZero() {
+				taskItem.DueAt = now
+			} else {
+				taskItem.DueAt = lastDone.Add(interval)
+			}
+
+			taskItem.IsDone = now.Before(taskItem.DueAt)
+
+			if !taskItem.IsDone {
+				taskItem.RainExpected = rainApplies && widget.Rain.Next >= threshold
+				taskItem.IsLate = !taskItem.RainExpected
+				item.NeedsAttention = item.NeedsAttention || taskItem.IsLate
+			}
+
+			item.Tasks = append(item.Tasks, taskItem)
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func (widget *gardenWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	body, ok := decodeChecklistToggleRequest(w, r)
+	if !ok {
+		return
+	}
+
+	task, name, _ := strings.Cut(body.Item, ":")
+	if task != gardenTaskWater && task != gardenTaskFertilize {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+
+	var target *gardenPlant
+	for i := range widget.Plants {
+		if widget.Plants[i].Name == name {
+			target = &widget.Plants[i]
+			break
+		}
+	}
+
+	if target == nil || target.interval(task) == 0 {
+		http.Error(w, "plant not found", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+
+	widget.stateMutex.Lock()
+	defer widget.stateMutex.Unlock()
+
+	state := widget.getState(target)
+	history := state.history(task)
+	last := len(*history) - 1
+	isDone := last >= 0 && now.Before(time.Unix((*history)[last], 0).Add(target.interval(task)))
+	action := ternary(task == gardenTaskWater, "watered", "fertilized")
+
+	if body.Done && !isDone {
+		*history = append(*history, now.Unix())
+		if len(*history) > gardenHistoryMaxLength {
+			*history = (*history)[len(*history)-gardenHistoryMaxLength:]
+		}
+
+		widget.Providers.audit.record(r, "Checked off plant care", widget.Title+": "+target.Name, "not "+action, action)
+	} else if !body.Done && isDone {
+		*history = (*history)[:last]
+		widget.Providers.audit.record(r, "Unchecked plant care", widget.Title+": "+target.Name, action, "not "+action)
+	}
+
+	widget.Providers.state.set(widget.stateKey(target), state)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (widget *gardenWidget) Render() template.HTML {
+	widget.Items = widget.buildItems(time.Now())
+
+	return widget.renderTemplate(widget, gardenWidgetTemplate)
+}
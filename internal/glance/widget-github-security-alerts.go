@@ -0,0 +1,215 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var githubSecurityAlertsWidgetTemplate = mustParseTemplate("github-security-alerts.html", "widget-base.html")
+
+type githubSecurityAlertsWidget struct {
+	widgetBase    `yaml:",inline"`
+	Repositories  []string                   `yaml:"repositories"`
+	Organization  string                     `yaml:"organization"`
+	Token         string                     `yaml:"token"`
+	Limit         int                        `yaml:"limit"`
+	CollapseAfter int                        `yaml:"collapse-after"`
+	Alerts        githubSecurityAlertList    `yaml:"-"`
+	Severities    []githubAlertSeverityCount `yaml:"-"`
+}
+
+func (widget *githubSecurityAlertsWidget) initialize() error {
+	widget.withTitle("Security Alerts").withCacheDuration(2 * time.Hour)
+
+	if widget.Token == "" {
+		return errors.New("token is required")
+	}
+
+	if len(widget.Repositories) == 0 && widget.Organization == "" {
+		return errors.New("at least one repository or an organization must be specified")
+	}
+
+	if widget.Organization != "" {
+		widget.withTitleURL("https://github.com/orgs/" + widget.Organization + "/security/alerts/dependabot")
+	} else if len(widget.Repositories) == 1 {
+		widget.withTitleURL("https://github.com/" + widget.Repositories[0] + "/security/dependabot")
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 15
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *githubSecurityAlertsWidget) update(ctx context.Context) {
+	alerts, err := fetchGithubSecurityAlerts(widget.httpClient(false), widget.Organization, widget.Repositories, widget.Token)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	alerts.sortBySeverityThenNewest()
+	widget.Severities = alerts.countBySeverity()
+
+	if len(alerts) > widget.Limit {
+		alerts = alerts[:widget.Limit]
+	}
+
+	widget.Alerts = alerts
+}
+
+func (widget *githubSecurityAlertsWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, githubSecurityAlertsWidgetTemplate)
+}
+
+var githubAlertSeverities = []string{"critical", "high", "medium", "low"}
+
+func githubAlertSeverityRank(severity string) int {
+	for i := range githubAlertSeverities {
+		if githubAlertSeverities[i] == severity {
+			return i
+		}
+	}
+
+	return len(githubAlertSeverities)
+}
+
+type githubSecurityAlert struct {
+	Repository string
+	Package    string
+	Summary    string
+	Severity   string
+	URL        string
+	CreatedAt  time.Time
+}
+
+type githubAlertSeverityCount struct {
+	Severity string
+	Count    int
+}
+
+type githubSecurityAlertList []githubSecurityAlert
+
+func (alerts githubSecurityAlertList) sortBySeverityThenNewest() {
+	sort.SliceStable(alerts, func(i, j int) bool {
+		ri, rj := githubAlertSeverityRank(alerts[i].Severity), githubAlertSeverityRank(alerts[j].Severity)
+
+		if ri != rj {
+			return ri < rj
+		}
+
+		return alerts[i].CreatedAt.After(alerts[j].CreatedAt)
+	})
+}
+
+func (alerts githubSecurityAlertList) countBySeverity() []githubAlertSeverityCount {
+	counts := make([]githubAlertSeverityCount, 0, len(githubAlertSeverities))
+
+	for _, severity := range githubAlertSeverities {
+		count := 0
+
+		for i := range alerts {
+			if alerts[i].Severity == severity {
+				count++
+			}
+		}
+
+		if count > 0 {
+			counts = append(counts, githubAlertSeverityCount{Severity: severity, Count: count})
+		}
+	}
+
+	return counts
+}
+
+type githubDependabotAlertResponseJson struct {
+	HTMLURL    string `json:"html_url"`
+	CreatedAt  string `json:"created_at"`
+	Dependency struct {
+		Package struct {
+			Name string `json:"name"`
+		} `json:"package"`
+	} `json:"dependency"`
+	SecurityAdvisory struct {
+		Summary  string `json:"summary"`
+		Severity string `json:"severity"`
+	} `json:"security_advisory"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+func fetchGithubSecurityAlerts(client requestDoer, organization string, repositories []string, token string) (githubSecurityAlertList, error) {
+	var urls []string
+	// only the organization endpoint includes the repository in each alert
+	var sources []string
+
+	if organization != "" {
+		urls = append(urls, fmt.Sprintf("https://api.github.com/orgs/%s/dependabot/alerts?state=open&per_page=100", organization))
+		sources = append(sources, "")
+	}
+
+	for _, repository := range repositories {
+		urls = append(urls, fmt.Sprintf("https://api.github.com/repos/%s/dependabot/alerts?state=open&per_page=100", repository))
+		sources = append(sources, repository)
+	}
+
+	requests := make([]*http.Request, len(urls))
+
+	for i := range urls {
+		request, _ := http.NewRequest("GET", urls[i], nil)
+		request.Header.Set("Accept", "application/vnd.github+json")
+		request.Header.Set("Authorization", "Bearer "+token)
+		requests[i] = request
+	}
+
+	task := decodeJsonFromRequestTask[[]githubDependabotAlertResponseJson](client)
+	job := newJob(task, requests).withWorkers(10)
+	responses, errs, err := workerPoolDo(job)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := make(githubSecurityAlertList, 0)
+	var failed int
+
+	for i := range responses {
+		if errs[i] != nil {
+			failed++
+			slog.Error("Failed to fetch dependabot alerts", "url", requests[i].URL, "error", errs[i])
+			continue
+		}
+
+		for _, alert := range responses[i] {
+			alerts = append(alerts, githubSecurityAlert{
+				Repository: ternary(alert.Repository.FullName == "", sources[i], alert.Repository.FullName),
+				Package:    alert.Dependency.Package.Name,
+				Summary:    alert.SecurityAdvisory.Summary,
+				Severity:   alert.SecurityAdvisory.Severity,
+				URL:        alert.HTMLURL,
+				CreatedAt:  parseRFC3339Time(alert.CreatedAt),
+			})
+		}
+	}
+
+	if failed == len(requests) {
+		return nil, errNoContent
+	}
+
+	if failed > 0 {
+		return alerts, fmt.Errorf("%w: could not get alerts for %d source(s)", errPartialContent, failed)
+	}
+
+	return alerts, nil
+}
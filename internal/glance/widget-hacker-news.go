@@ -13,20 +13,22 @@ import (
 
 type hackerNewsWidget struct {
 	widgetBase          `yaml:",inline"`
+	seenItemsOptions    `yaml:",inline"`
+	saveForLaterOptions `yaml:",inline"`
 	Posts               forumPostList `yaml:"-"`
 	Limit               int           `yaml:"limit"`
 	SortBy              string        `yaml:"sort-by"`
 	ExtraSortBy         string        `yaml:"extra-sort-by"`
 	CollapseAfter       int           `yaml:"collapse-after"`
 	CommentsUrlTemplate string        `yaml:"comments-url-template"`
+	MinScore            int           `yaml:"min-score"`
+	MinComments         int           `yaml:"min-comments"`
 	ShowThumbnails      bool          `yaml:"-"`
 }
 
 func (widget *hackerNewsWidget) initialize() error {
-	widget.
-		withTitle("Hacker News").
-		withTitleURL("https://news.ycombinator.com/").
-		withCacheDuration(30 * time.Minute)
+	widget.withTitle("Hacker News").withCacheDuration(30 * time.Minute)
+	widget.initializeSeenItems()
 
 	if widget.Limit <= 0 {
 		widget.Limit = 15
@@ -36,20 +38,30 @@ func (widget *hackerNewsWidget) initialize() error {
 		widget.CollapseAfter = 5
 	}
 
-	if widget.SortBy != "top" && widget.SortBy != "new" && widget.SortBy != "best" {
+	titleURL, ok := hackerNewsSortToPageURL[widget.SortBy]
+	if !ok {
 		widget.SortBy = "top"
+		titleURL = hackerNewsSortToPageURL["top"]
 	}
 
+	widget.withTitleURL(titleURL)
+
 	return nil
 }
 
 func (widget *hackerNewsWidget) update(ctx context.Context) {
-	posts, err := fetchHackerNewsPosts(widget.SortBy, 40, widget.CommentsUrlTemplate)
+	// fetch more posts than usual when filtering so that there's still enough left afterwards
+	postsToFetch := ternary(widget.MinScore > 0 || widget.MinComments > 0, 100, 40)
+	posts, err := fetchHackerNewsPosts(widget.cachedHTTPClient(false), widget.SortBy, postsToFetch, widget.CommentsUrlTemplate)
 
 	if !widget.canContinueUpdateAfterHandlingErr(err) {
 		return
 	}
 
+	if widget.MinScore > 0 || widget.MinComments > 0 {
+		posts = posts.filterByMinimums(widget.MinScore, widget.MinComments)
+	}
+
 	if widget.ExtraSortBy == "engagement" {
 		posts.calculateEngagement()
 		posts.sortByEngagement()
@@ -62,10 +74,27 @@ func (widget *hackerNewsWidget) update(ctx context.Context) {
 	widget.Posts = posts
 }
 
+func (widget *hackerNewsWidget) briefing(_ time.Time, headlines int) []string {
+	return widget.Posts.briefing(widget.Title, headlines)
+}
+
+func (widget *hackerNewsWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	handleSaveForLaterRequest(w, r, &widget.widgetBase, widget.SaveTo, widget.Posts.titleByURL)
+}
+
 func (widget *hackerNewsWidget) Render() template.HTML {
 	return widget.renderTemplate(widget, forumPostsTemplate)
 }
 
+var hackerNewsSortToPageURL = map[string]string{
+	"top":  "https://news.ycombinator.com/",
+	"new":  "https://news.ycombinator.com/newest",
+	"best": "https://news.ycombinator.com/best",
+	"ask":  "https://news.ycombinator.com/ask",
+	"show": "https://news.ycombinator.com/show",
+	"job":  "https://news.ycombinator.com/jobs",
+}
+
 type hackerNewsPostResponseJson struct {
 	Id           int    `json:"id"`
 	Score        int    `json:"score"`
@@ -75,9 +104,9 @@ type hackerNewsPostResponseJson struct {
 	TimePosted   int64  `json:"time"`
 }
 
-func fetchHackerNewsPostIds(sort string) ([]int, error) {
+func fetchHackerNewsPostIds(client requestDoer, sort string) ([]int, error) {
 	request, _ := http.NewRequest("GET", fmt.Sprintf("https://hacker-news.firebaseio.com/v0/%sstories.json", sort), nil)
-	response, err := decodeJsonFromRequest[[]int](defaultHTTPClient, request)
+	response, err := decodeJsonFromRequest[[]int](client, request)
 	if err != nil {
 		return nil, fmt.Errorf("%w: could not fetch list of post IDs", errNoContent)
 	}
@@ -85,7 +114,7 @@ func fetchHackerNewsPostIds(sort string) ([]int, error) {
 	return response, nil
 }
 
-func fetchHackerNewsPostsFromIds(postIds []int, commentsUrlTemplate string) (forumPostList, error) {
+func fetchHackerNewsPostsFromIds(client requestDoer, postIds []int, commentsUrlTemplate string) (forumPostList, error) {
 	requests := make([]*http.Request, len(postIds))
 
 	for i, id := range postIds {
@@ -93,7 +122,7 @@ func fetchHackerNewsPostsFromIds(postIds []int, commentsUrlTemplate string) (for
 		requests[i] = request
 	}
 
-	task := decodeJsonFromRequestTask[hackerNewsPostResponseJson](defaultHTTPClient)
+	task := decodeJsonFromRequestTask[hackerNewsPostResponseJson](client)
 	job := newJob(task, requests).withWorkers(30)
 	results, errs, err := workerPoolDo(job)
 	if err != nil {
@@ -138,8 +167,8 @@ func fetchHackerNewsPostsFromIds(postIds []int, commentsUrlTemplate string) (for
 	return posts, nil
 }
 
-func fetchHackerNewsPosts(sort string, limit int, commentsUrlTemplate string) (forumPostList, error) {
-	postIds, err := fetchHackerNewsPostIds(sort)
+func fetchHackerNewsPosts(client requestDoer, sort string, limit int, commentsUrlTemplate string) (forumPostList, error) {
+	postIds, err := fetchHackerNewsPostIds(client, sort)
 	if err != nil {
 		return nil, err
 	}
@@ -148,5 +177,9 @@ func fetchHackerNewsPosts(sort string, limit int, commentsUrlTemplate string) (f
 		postIds = postIds[:limit]
 	}
 
-	return fetchHackerNewsPostsFromIds(postIds, commentsUrlTemplate)
+	return fetchHackerNewsPostsFromIds(client, postIds, commentsUrlTemplate)
+}
+
+func (widget *hackerNewsWidget) data() any {
+	return widget.Posts.data()
 }
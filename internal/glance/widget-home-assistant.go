@@ -0,0 +1,375 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var homeAssistantWidgetTemplate = mustParseTemplate("home-assistant.html", "widget-base.html")
+
+// domains whose entities can be turned on and off through the toggle service
+var homeAssistantToggleableDomains = []string{"switch", "light", "fan", "input_boolean", "automation"}
+
+type homeAssistantWidget struct {
+	widgetBase              `yaml:",inline"`
+	homeAssistantConnection `yaml:",inline"`
+	Entities                []homeAssistantEntity `yaml:"entities"`
+	States                  []homeAssistantState  `yaml:"-"`
+	statesMutex             sync.Mutex            `yaml:"-"`
+}
+
+// Also used by the buttons widget to call services
+type homeAssistantConnection struct {
+	URL           string `yaml:"url"`
+	Token         string `yaml:"token"`
+	AllowInsecure bool   `yaml:"allow-insecure"`
+}
+
+type homeAssistantEntity struct {
+	ID          string          `yaml:"entity"`
+	Name        string          `yaml:"name"`
+	Icon        customIconField `yaml:"icon"`
+	AllowToggle bool            `yaml:"allow-toggle"`
+}
+
+type homeAssistantState struct {
+	EntityID    string
+	Name        string
+	Icon        customIconField
+	Value       string
+	Unit        string
+	IsOn        bool
+	CanToggle   bool
+	Unavailable bool
+}
+
+type homeAssistantStateJson struct {
+	EntityID   string `json:"entity_id"`
+	State      string `json:"state"`
+	Attributes struct {
+		FriendlyName      string `json:"friendly_name"`
+		UnitOfMeasurement string `json:"unit_of_measurement"`
+		DeviceClass       string `json:"device_class"`
+	} `json:"attributes"`
+}
+
+func (widget *homeAssistantWidget) initialize() error {
+	widget.withTitle("Home Assistant").withCacheDuration(time.Minute)
+
+	if err := widget.homeAssistantConnection.initialize(); err != nil {
+		return err
+	}
+
+	if len(widget.Entities) == 0 {
+		return errors.New("no entities specified")
+	}
+
+	for i := range widget.Entities {
+		entity := &widget.Entities[i]
+
+		if entity.ID == "" {
+			return fmt.Errorf("entity %d: entity is required", i+1)
+		}
+
+		if entity.AllowToggle && !isHomeAssistantToggleable(entity.ID) {
+			return fmt.Errorf("entity %s: toggling is only supported for the %s domains", entity.ID, strings.Join(homeAssistantToggleableDomains, ", "))
+		}
+	}
+
+	return nil
+}
+
+func (c *homeAssistantConnection) initialize() error {
+	if c.URL == "" {
+		return errors.New("url is required")
+	}
+
+	if c.Token == "" {
+		return errors.New("token is required")
+	}
+
+	c.URL = strings.TrimRight(c.URL, "/")
+
+	return nil
+}
+
+func isHomeAssistantToggleable(entityID string) bool {
+	domain, _, _ := strings.Cut(entityID, ".")
+	return slices.Contains(homeAssistantToggleableDomains, domain)
+}
+
+func (widget *homeAssistantWidget) client() requestDoer {
+	return widget.httpClient(widget.AllowInsecure)
+}
+
+func (c *homeAssistantConnection) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequest(method, c.URL+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+c.Token)
+	request.Header.Set("Content-Type", "application/json")
+
+	return request, nil
+}
+
+func (c *homeAssistantConnection) fetchEntityStates(client requestDoer) (map[string]*homeAssistantStateJson, error) {
+	request, err := c.newRequest("GET", "/api/states", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeJsonFromRequest[[]homeAssistantStateJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*homeAssistantStateJson, len(response))
+	for i := range response {
+		byID[response[i].EntityID] = &response[i]
+	}
+
+	return byID, nil
+}
+
+// Calls the service and returns the state of the entity once it's done, the
+// service is given as domain.service
+func (c *homeAssistantConnection) callService(ctx context.Context, client requestDoer, service, entityID string, data map[string]any) (*homeAssistantStateJson, error) {
+	domain, name, _ := strings.Cut(service, ".")
+
+	payload := make(map[string]any, len(data)+1)
+	for key, value := range data {
+		payload[key] = value
+	}
+
+	if entityID != "" {
+		payload["entity_id"] = entityID
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := c.newRequest("POST", "/api/services/"+domain+"/"+name, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request = request.WithContext(ctx)
+
+	changed, err := decodeJsonFromRequest[[]homeAssistantStateJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if entityID == "" {
+		return nil, nil
+	}
+
+	for i := range changed {
+		if changed[i].EntityID == entityID {
+			return &changed[i], nil
+		}
+	}
+
+	// the response only includes states that changed while the service was
+	// being called, which devices that are slow to respond may not be part of
+	request, err = c.newRequest("GET", "/api/states/"+entityID, nil)
+	if err != nil {
+		return nil, err
+	}
+	request = request.WithContext(ctx)
+
+	state, err := decodeJsonFromRequest[homeAssistantStateJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func (widget *homeAssistantWidget) update(ctx context.Context) {
+	states, err := widget.fetchStates()
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.statesMutex.Lock()
+	widget.States = states
+	widget.statesMutex.Unlock()
+}
+
+func (widget *homeAssistantWidget) fetchStates() ([]homeAssistantState, error) {
+	byID, err := widget.fetchEntityStates(widget.client())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	states := make([]homeAssistantState, 0, len(widget.Entities))
+	var missing int
+
+	for i := range widget.Entities {
+		entity := &widget.Entities[i]
+		state, ok := byID[entity.ID]
+		if !ok {
+			missing++
+		}
+
+		states = append(states, entity.newState(state))
+	}
+
+	if missing > 0 {
+		return states, fmt.Errorf("%w: %d entities were not found", errPartialContent, missing)
+	}
+
+	return states, nil
+}
+
+// state may be nil if the entity doesn't exist
+func (entity *homeAssistantEntity) newState(state *homeAssistantStateJson) homeAssistantState {
+	s := homeAssistantState{
+		EntityID:  entity.ID,
+		Name:      entity.Name,
+		Icon:      entity.Icon,
+		CanToggle: entity.AllowToggle,
+	}
+
+	if state == nil || state.State == "unavailable" || state.State == "unknown" {
+		s.Unavailable = true
+		s.Value = "Unavailable"
+		s.CanToggle = false
+	} else {
+		s.Value, s.Unit = formatHomeAssistantState(state)
+		s.IsOn = state.State == "on"
+	}
+
+	if s.Name == "" && state != nil {
+		s.Name = state.Attributes.FriendlyName
+	}
+
+	if s.Name == "" {
+		s.Name = entity.ID
+	}
+
+	return s
+}
+
+func formatHomeAssistantState(state *homeAssistantStateJson) (string, string) {
+	domain, _, _ := strings.Cut(state.EntityID, ".")
+
+	if value, err := strconv.ParseFloat(state.State, 64); err == nil {
+		return strconv.FormatFloat(value, 'f', -1, 64), state.Attributes.UnitOfMeasurement
+	}
+
+	if domain == "binary_sensor" {
+		switch state.Attributes.DeviceClass {
+		case "door", "window", "opening", "garage_door":
+			return ternary(state.State == "on", "Open", "Closed"), ""
+		case "motion", "occupancy", "presence":
+			return ternary(state.State == "on", "Detected", "Clear"), ""
+		case "lock":
+			return ternary(state.State == "on", "Unlocked", "Locked"), ""
+		}
+	}
+
+	value := strings.ReplaceAll(state.State, "_", " ")
+	if value == "" {
+		return value, ""
+	}
+
+	return strings.ToUpper(value[:1]) + value[1:], state.Attributes.UnitOfMeasurement
+}
+
+func (widget *homeAssistantWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("path") != "toggle" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Entity string `json:"entity"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var entity *homeAssistantEntity
+	for i := range widget.Entities {
+		if widget.Entities[i].ID == body.Entity {
+			entity = &widget.Entities[i]
+			break
+		}
+	}
+
+	if entity == nil || !entity.AllowToggle {
+		http.Error(w, "entity not found", http.StatusNotFound)
+		return
+	}
+
+	state, err := widget.toggle(entity)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	before := ""
+
+	widget.statesMutex.Lock()
+	for i := range widget.States {
+		if widget.States[i].EntityID == entity.ID {
+			before = widget.States[i].Value
+			widget.States[i] = state
+			break
+		}
+	}
+	widget.statesMutex.Unlock()
+
+	widget.Providers.audit.record(r, "Toggled entity", widget.Title+": "+ternary(state.Name != "", state.Name, entity.ID), before, state.Value)
+
+	var html bytes.Buffer
+	if err := homeAssistantWidgetTemplate.ExecuteTemplate(&html, "entity", state); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(html.Bytes())
+}
+
+func (widget *homeAssistantWidget) toggle(entity *homeAssistantEntity) (homeAssistantState, error) {
+	domain, _, _ := strings.Cut(entity.ID, ".")
+
+	state, err := widget.callService(context.Background(), widget.client(), domain+".toggle", entity.ID, nil)
+	if err != nil {
+		return homeAssistantState{}, err
+	}
+
+	return entity.newState(state), nil
+}
+
+func (widget *homeAssistantWidget) Render() template.HTML {
+	widget.statesMutex.Lock()
+	defer widget.statesMutex.Unlock()
+
+	return widget.renderTemplate(widget, homeAssistantWidgetTemplate)
+}
@@ -0,0 +1,572 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var homeNetworkWidgetTemplate = mustParseTemplate("home-network.html", "widget-base.html")
+
+type homeNetworkWidget struct {
+	widgetBase `yaml:",inline"`
+	UniFi      *unifiConnection  `yaml:"unifi"`
+	Networks   []homeNetworkWiFi `yaml:"networks"`
+	HideWANIP  bool              `yaml:"hide-wan-ip"`
+	HideVPN    bool              `yaml:"hide-vpn"`
+	WAN        homeNetworkWAN    `yaml:"-"`
+	VPN        homeNetworkVPN    `yaml:"-"`
+
+	lastRotationCheck time.Time
+}
+
+type homeNetworkWiFi struct {
+	SSID           string        `yaml:"ssid"`
+	Name           string        `yaml:"name"`
+	ShowPassword   bool          `yaml:"show-password"`
+	QRCode         bool          `yaml:"qr-code"`
+	RotatePassword durationField `yaml:"rotate-password"`
+
+	Found     bool          `yaml:"-"`
+	Enabled   bool          `yaml:"-"`
+	Password  string        `yaml:"-"`
+	Clients   int           `yaml:"-"`
+	QRCodeSVG template.HTML `yaml:"-"`
+	RotatedAt time.Time     `yaml:"-"`
+	// the password the QR code was generated for
+	qrCodePassword string
+}
+
+type homeNetworkWAN struct {
+	IP     string
+	Online bool
+}
+
+type homeNetworkVPN struct {
+	Available     bool
+	Enabled       bool
+	ActiveClients int
+}
+
+// Rotations are checked for more often than the widget updates so that a
+// rotation doesn't have to wait for someone to open the page
+const homeNetworkRotationCheckInterval = 5 * time.Minute
+
+// Rotating more often would have people constantly reconnecting their devices
+const homeNetworkMinRotationInterval = time.Hour
+
+func (widget *homeNetworkWidget) initialize() error {
+	widget.withTitle("Network").withCacheDuration(5 * time.Minute)
+
+	if widget.UniFi == nil {
+		return errors.New("unifi is required")
+	}
+
+	if err := widget.UniFi.initialize(); err != nil {
+		return fmt.Errorf("unifi: %v", err)
+	}
+
+	for i := range widget.Networks {
+		network := &widget.Networks[i]
+
+		if network.SSID == "" {
+			return fmt.Errorf("network %d: ssid is required", i+1)
+		}
+
+		if network.RotatePassword != 0 && time.Duration(network.RotatePassword) < homeNetworkMinRotationInterval {
+			return fmt.Errorf("network %s: rotate-password must be at least %s", network.SSID, homeNetworkMinRotationInterval)
+		}
+
+		if network.Name == "" {
+			network.Name = network.SSID
+		}
+	}
+
+	return nil
+}
+
+func (widget *homeNetworkWidget) update(ctx context.Context) {
+	client := widget.httpClient(widget.UniFi.AllowInsecure)
+
+	var wlans []unifiWLANJson
+	var clients []unifiClientJson
+	var health []unifiHealthJson
+	var wlansErr, clientsErr, healthErr error
+
+	if len(widget.Networks) > 0 {
+		wlansErr = widget.UniFi.get(client, "/rest/wlanconf", &wlans)
+		clientsErr = widget.UniFi.get(client, "/stat/sta", &clients)
+	}
+
+	if !widget.HideWANIP || !widget.HideVPN {
+		healthErr = widget.UniFi.get(client, "/stat/health", &health)
+	}
+
+	var err error
+	if failed := errors.Join(wlansErr, clientsErr, healthErr); failed != nil {
+		allFailed := (wlansErr != nil || len(widget.Networks) == 0) && (healthErr != nil || widget.HideWANIP && widget.HideVPN)
+		err = fmt.Errorf("%w: %v", ternary(allFailed, errNoContent, errPartialContent), failed)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	if wlansErr == nil {
+		clientsPerSSID := make(map[string]int)
+		for i := range clients {
+			clientsPerSSID[clients[i].ESSID]++
+		}
+
+		for i := range widget.Networks {
+			widget.updateNetwork(&widget.Networks[i], wlans, clientsPerSSID, clientsErr == nil)
+		}
+	}
+
+	if healthErr == nil {
+		widget.WAN = homeNetworkWAN{}
+		widget.VPN = homeNetworkVPN{}
+
+		for i := range health {
+			switch health[i].Subsystem {
+			case "wan":
+				widget.WAN.IP = health[i].WANIP
+				widget.WAN.Online = health[i].Status == "ok"
+			case "vpn":
+				widget.VPN.Available = true
+				widget.VPN.Enabled = health[i].RemoteUserEnabled || health[i].SiteToSiteEnabled
+				widget.VPN.ActiveClients = health[i].RemoteUserActive
+			}
+		}
+	}
+}
+
+func (widget *homeNetworkWidget) updateNetwork(network *homeNetworkWiFi, wlans []unifiWLANJson, clients map[string]int, withClients bool) {
+	network.Found = false
+
+	for i := range wlans {
+		if wlans[i].Name != network.SSID {
+			continue
+		}
+
+		network.Found = true
+		network.Enabled = wlans[i].Enabled
+		network.Password = wlans[i].Passphrase
+		network.Clients = ternary(withClients, clients[network.SSID], -1)
+
+		if network.RotatePassword > 0 {
+			var rotation homeNetworkRotation
+			if widget.Providers.state.get(widget.rotationStateKey(network.SSID), &rotation) {
+				network.RotatedAt = rotation.At
+			}
+		}
+
+		// networks that need more than a password to join, such as enterprise
+		// ones, can't be joined by scanning a code
+		canJoinWithCode := wlans[i].Security == "open" || network.Password != ""
+
+		if network.QRCode && canJoinWithCode && (network.QRCodeSVG == "" || network.qrCodePassword != network.Password) {
+			security := ternary(wlans[i].Security == "open", "nopass", ternary(wlans[i].Security == "wep", "WEP", "WPA"))
+			wifi := qrCodeWiFi{SSID: network.SSID, Password: network.Password, Security: security, Hidden: wlans[i].HideSSID}
+
+			if content, err := wifi.content(); err != nil {
+				widget.logger().Error("Failed to generate Wi-Fi QR code", "ssid", network.SSID, "error", err)
+			} else if code, err := encodeQRCode([]byte(content), qrErrorCorrectionM); err != nil {
+				widget.logger().Error("Failed to generate Wi-Fi QR code", "ssid", network.SSID, "error", err)
+			} else {
+				network.QRCodeSVG = template.HTML(code.svg())
+				network.qrCodePassword = network.Password
+			}
+		}
+
+		return
+	}
+}
+
+func (widget *homeNetworkWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, homeNetworkWidgetTemplate)
+}
+
+// What gets persisted about the last time a network's password was rotated
+type homeNetworkRotation struct {
+	At time.Time `json:"at"`
+}
+
+func (widget *homeNetworkWidget) rotationStateKey(ssid string) string {
+	return "home-network:" + widget.UniFi.URL + ":" + widget.UniFi.Site + ":" + ssid
+}
+
+// Passwords are rotated in the background so that it happens on schedule even
+// when nobody is looking at the page
+func (widget *homeNetworkWidget) runBackgroundTask(now time.Time) {
+	if now.Sub(widget.lastRotationCheck) < homeNetworkRotationCheckInterval {
+		return
+	}
+
+	widget.lastRotationCheck = now
+
+	var due []*homeNetworkWiFi
+	for i := range widget.Networks {
+		network := &widget.Networks[i]
+		if network.RotatePassword == 0 {
+			continue
+		}
+
+		var rotation homeNetworkRotation
+		if !widget.Providers.state.get(widget.rotationStateKey(network.SSID), &rotation) {
+			// the schedule starts from when rotation was first set up rather
+			// than changing the password right away
+			widget.Providers.state.set(widget.rotationStateKey(network.SSID), homeNetworkRotation{At: now})
+			continue
+		}
+
+		if now.Sub(rotation.At) >= time.Duration(network.RotatePassword) {
+			due = append(due, network)
+		}
+	}
+
+	if len(due) == 0 {
+		return
+	}
+
+	client := widget.httpClient(widget.UniFi.AllowInsecure)
+
+	var wlans []unifiWLANJson
+	if err := widget.UniFi.get(client, "/rest/wlanconf", &wlans); err != nil {
+		widget.logger().Error("Failed to get wireless networks", "error", err)
+		return
+	}
+
+	for _, network := range due {
+		var wlan *unifiWLANJson
+		for i := range wlans {
+			if wlans[i].Name == network.SSID {
+				wlan = &wlans[i]
+				break
+			}
+		}
+
+		if wlan == nil {
+			widget.logger().Error("Wireless network to rotate the password of not found", "ssid", network.SSID)
+			continue
+		}
+
+		if wlan.Security == "open" {
+			widget.logger().Error("Can't rotate the password of an open network", "ssid", network.SSID)
+			continue
+		}
+
+		password, err := generateHomeNetworkPassword()
+		if err != nil {
+			widget.logger().Error("Failed to generate password", "error", err)
+			return
+		}
+
+		update := map[string]string{"x_passphrase": password}
+		if err := widget.UniFi.put(client, "/rest/wlanconf/"+wlan.ID, update); err != nil {
+			widget.logger().Error("Failed to rotate Wi-Fi password", "ssid", network.SSID, "error", err)
+			continue
+		}
+
+		widget.Providers.state.set(widget.rotationStateKey(network.SSID), homeNetworkRotation{At: now})
+		// the passwords themselves are left out so that they don't end up in the log
+		widget.Providers.audit.record(nil, "Rotated Wi-Fi password", network.SSID, "", "")
+		widget.logger().Info("Rotated Wi-Fi password", "ssid", network.SSID)
+	}
+}
+
+// Short words that are easy to read out and type on a phone
+var homeNetworkPasswordWords = []string{
+	"apple", "banana", "cherry", "grape", "lemon", "mango", "melon", "peach",
+	"pear", "plum", "berry", "olive", "carrot", "pepper", "tomato", "potato",
+	"tiger", "otter", "panda", "koala", "zebra", "camel", "eagle", "falcon",
+	"robin", "whale", "shark", "turtle", "rabbit", "badger", "beaver", "moose",
+	"river", "ocean", "island", "forest", "meadow", "valley", "canyon", "desert",
+	"cloud", "storm", "thunder", "rainbow", "sunset", "comet", "planet", "rocket",
+	"piano", "guitar", "violin", "trumpet", "drum", "banjo", "flute", "harp",
+	"castle", "bridge", "tower", "garden", "harbor", "lantern", "window", "ladder",
+	"purple", "orange", "silver", "golden", "green", "yellow", "scarlet", "copper",
+	"happy", "sunny", "brave", "gentle", "clever", "quick", "quiet", "jolly",
+	"pencil", "basket", "button", "candle", "marble", "pillow", "kettle", "teapot",
+	"maple", "willow", "cedar", "birch", "pine", "oak", "cactus", "tulip",
+}
+
+// Generates a password made of three words and a number, such as
+// maple-otter-rocket-42
+func generateHomeNetworkPassword() (string, error) {
+	parts := make([]string, 0, 4)
+
+	for range 3 {
+		i, err := rand.Int(rand.Reader, big.NewInt(int64(len(homeNetworkPasswordWords))))
+		if err != nil {
+			return "", err
+		}
+
+		parts = append(parts, homeNetworkPasswordWords[i.Int64()])
+	}
+
+	number, err := rand.Int(rand.Reader, big.NewInt(90))
+	if err != nil {
+		return "", err
+	}
+
+	parts = append(parts, fmt.Sprint(number.Int64()+10))
+
+	return strings.Join(parts, "-"), nil
+}
+
+type unifiConnection struct {
+	URL           string `yaml:"url"`
+	APIKey        string `yaml:"api-key"`
+	Username      string `yaml:"username"`
+	Password      string `yaml:"password"`
+	Site          string `yaml:"site"`
+	AllowInsecure bool   `yaml:"allow-insecure"`
+
+	// logging in is rate limited, so the session is reused until it expires
+	sessionMu sync.Mutex
+	cookies   []*http.Cookie
+	csrfToken string
+	// UniFi OS consoles serve the network application under a prefix, standalone
+	// controllers don't, which is figured out when logging in
+	pathPrefix string
+}
+
+type unifiWLANJson struct {
+	ID         string `json:"_id"`
+	Name       string `json:"name"`
+	Enabled    bool   `json:"enabled"`
+	Passphrase string `json:"x_passphrase"`
+	Security   string `json:"security"`
+	HideSSID   bool   `json:"hide_ssid"`
+}
+
+type unifiClientJson struct {
+	ESSID string `json:"essid"`
+}
+
+type unifiHealthJson struct {
+	Subsystem         string `json:"subsystem"`
+	Status            string `json:"status"`
+	WANIP             string `json:"wan_ip"`
+	RemoteUserEnabled bool   `json:"remote_user_enabled"`
+	RemoteUserActive  int    `json:"remote_user_num_active"`
+	SiteToSiteEnabled bool   `json:"site_to_site_enabled"`
+}
+
+type unifiResponseJson struct {
+	Meta struct {
+		RC  string `json:"rc"`
+		Msg string `json:"msg"`
+	} `json:"meta"`
+	Data json.RawMessage `json:"data"`
+}
+
+var errUniFiUnauthorized = errors.New("not logged in to the UniFi controller")
+
+func (c *unifiConnection) initialize() error {
+	if c.URL == "" {
+		return errors.New("url is required")
+	}
+
+	if c.APIKey == "" && (c.Username == "" || c.Password == "") {
+		return errors.New("either api-key or username and password are required")
+	}
+
+	if c.Site == "" {
+		c.Site = "default"
+	}
+
+	c.URL = strings.TrimRight(c.URL, "/")
+
+	// API keys are only available on UniFi OS
+	if c.APIKey != "" {
+		c.pathPrefix = "/proxy/network"
+	}
+
+	return nil
+}
+
+func (c *unifiConnection) get(client requestDoer, path string, v any) error {
+	return c.do(client, "GET", path, nil, v)
+}
+
+func (c *unifiConnection) put(client requestDoer, path string, body any) error {
+	return c.do(client, "PUT", path, body, nil)
+}
+
+// Sends a request to the API of the configured site, logging in first when
+// needed, and decodes the data of the response into v
+func (c *unifiConnection) do(client requestDoer, method, path string, body any, v any) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
+	if c.APIKey != "" {
+		return c.send(client, method, path, body, v)
+	}
+
+	loggedIn := false
+	if c.cookies == nil {
+		if err := c.login(client); err != nil {
+			return err
+		}
+		loggedIn = true
+	}
+
+	err := c.send(client, method, path, body, v)
+	if errors.Is(err, errUniFiUnauthorized) && !loggedIn {
+		if err := c.login(client); err != nil {
+			return err
+		}
+
+		err = c.send(client, method, path, body, v)
+	}
+
+	if errors.Is(err, errUniFiUnauthorized) {
+		c.cookies = nil
+	}
+
+	return err
+}
+
+func (c *unifiConnection) send(client requestDoer, method, path string, body any, v any) error {
+	var bodyReader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		bodyReader = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequest(method, c.URL+c.pathPrefix+"/api/s/"+c.Site+path, bodyReader)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.APIKey != "" {
+		request.Header.Set("X-API-KEY", c.APIKey)
+	} else {
+		for _, cookie := range c.cookies {
+			request.AddCookie(cookie)
+		}
+
+		if c.csrfToken != "" {
+			request.Header.Set("X-CSRF-Token", c.csrfToken)
+		}
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// UniFi OS hands out a new token every now and then
+	if token := response.Header.Get("X-Updated-CSRF-Token"); token != "" {
+		c.csrfToken = token
+	}
+
+	if response.StatusCode == http.StatusUnauthorized {
+		if c.APIKey != "" {
+			return errors.New("the API key was rejected")
+		}
+
+		return errUniFiUnauthorized
+	}
+
+	contents, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	var decoded unifiResponseJson
+	if err := json.Unmarshal(contents, &decoded); err != nil {
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status code %d for %s", response.StatusCode, request.URL)
+		}
+
+		return fmt.Errorf("decoding response: %v", err)
+	}
+
+	if decoded.Meta.RC != "ok" {
+		if decoded.Meta.Msg == "api.err.LoginRequired" {
+			return errUniFiUnauthorized
+		}
+
+		return fmt.Errorf("request to %s failed: %s", path, decoded.Meta.Msg)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.Unmarshal(decoded.Data, v)
+}
+
+// Tries logging in the way UniFi OS consoles expect first, falling back to
+// the way standalone controllers do
+func (c *unifiConnection) login(client requestDoer) error {
+	credentials, _ := json.Marshal(map[string]any{
+		"username": c.Username,
+		"password": c.Password,
+		"remember": true,
+	})
+
+	for _, attempt := range []struct{ path, prefix string }{
+		{"/api/auth/login", "/proxy/network"},
+		{"/api/login", ""},
+	} {
+		request, _ := http.NewRequest("POST", c.URL+attempt.path, bytes.NewReader(credentials))
+		request.Header.Set("Content-Type", "application/json")
+
+		response, err := client.Do(request)
+		if err != nil {
+			return fmt.Errorf("logging in to the UniFi controller: %v", err)
+		}
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+
+		if response.StatusCode == http.StatusNotFound {
+			continue
+		}
+
+		if response.StatusCode == http.StatusTooManyRequests {
+			return errors.New("logging in to the UniFi controller: too many login attempts")
+		}
+
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("logging in to the UniFi controller failed with status code %d, check that the username and password are correct", response.StatusCode)
+		}
+
+		c.cookies = response.Cookies()
+		c.csrfToken = response.Header.Get("X-CSRF-Token")
+		c.pathPrefix = attempt.prefix
+
+		// standalone controllers send the token as a cookie instead
+		for _, cookie := range c.cookies {
+			if cookie.Name == "csrf_token" && c.csrfToken == "" {
+				c.csrfToken = cookie.Value
+			}
+		}
+
+		return nil
+	}
+
+	return errors.New("logging in to the UniFi controller: no login endpoint found, check that the url is correct")
+}
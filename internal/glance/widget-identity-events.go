@@ -0,0 +1,424 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var identityEventsWidgetTemplate = mustParseTemplate("identity-events.html", "widget-base.html")
+
+type identityEventsWidget struct {
+	widgetBase `yaml:",inline"`
+	Service    string `yaml:"service"`
+	URL        string `yaml:"url"`
+	// Authentik only
+	Token string `yaml:"token"`
+	// Keycloak only
+	Realm         string               `yaml:"realm"`
+	ClientID      string               `yaml:"client-id"`
+	ClientSecret  string               `yaml:"client-secret"`
+	Events        []string             `yaml:"events"`
+	Period        durationField        `yaml:"period"`
+	AllowInsecure bool                 `yaml:"allow-insecure"`
+	Limit         int                  `yaml:"limit"`
+	CollapseAfter int                  `yaml:"collapse-after"`
+	Counts        []identityEventCount `yaml:"-"`
+	RecentEvents  []identityEvent      `yaml:"-"`
+
+	accessTokenMu      sync.Mutex
+	accessToken        string
+	accessTokenExpires time.Time
+}
+
+// Keycloak and Authentik only return up to this many events of each kind,
+// counts that reach it are shown as being at least this many
+const identityEventsMaxPerKind = 100
+
+var identityEventKinds = []string{"failed-login", "new-device", "impersonation"}
+
+var identityEventKindLabels = map[string]string{
+	"failed-login":  "Failed login",
+	"new-device":    "New device",
+	"impersonation": "Impersonation",
+}
+
+func (widget *identityEventsWidget) initialize() error {
+	widget.withTitle("Identity Events").withCacheDuration(5 * time.Minute)
+
+	if widget.URL == "" {
+		return errors.New("url is required")
+	}
+
+	widget.URL = strings.TrimRight(widget.URL, "/")
+
+	switch widget.Service {
+	case "authentik":
+		if widget.Token == "" {
+			return errors.New("token is required for authentik")
+		}
+
+		widget.withTitleURL(widget.URL + "/if/admin/#/events/log")
+	case "keycloak":
+		if widget.ClientID == "" || widget.ClientSecret == "" {
+			return errors.New("client-id and client-secret are required for keycloak")
+		}
+
+		if widget.Realm == "" {
+			widget.Realm = "master"
+		}
+
+		widget.withTitleURL(widget.URL + "/admin/master/console/#/" + url.PathEscape(widget.Realm) + "/events")
+	default:
+		return errors.New("service must be either authentik or keycloak")
+	}
+
+	if len(widget.Events) == 0 {
+		widget.Events = identityEventKinds
+	}
+
+	for _, kind := range widget.Events {
+		if !slices.Contains(identityEventKinds, kind) {
+			return fmt.Errorf("unknown event %q, must be one of %s", kind, strings.Join(identityEventKinds, ", "))
+		}
+	}
+
+	if widget.Period <= 0 {
+		widget.Period = durationField(24 * time.Hour)
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 10
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *identityEventsWidget) update(ctx context.Context) {
+	client := widget.httpClient(widget.AllowInsecure)
+	since := time.Now().Add(-time.Duration(widget.Period))
+
+	var task func(string) ([]identityEvent, error)
+
+	if widget.Service == "authentik" {
+		task = func(kind string) ([]identityEvent, error) {
+			return fetchAuthentikEvents(client, widget.URL, widget.Token, kind)
+		}
+	} else {
+		token, err := widget.keycloakAccessToken(client)
+		if err != nil {
+			widget.withError(fmt.Errorf("getting access token: %w", err)).scheduleEarlyUpdate()
+			return
+		}
+
+		task = func(kind string) ([]identityEvent, error) {
+			return fetchKeycloakEvents(client, widget.URL, widget.Realm, token, kind, since)
+		}
+	}
+
+	responses, errs, err := workerPoolDo(newJob(task, widget.Events).withWorkers(len(widget.Events)))
+	if err != nil {
+		widget.withError(err).scheduleEarlyUpdate()
+		return
+	}
+
+	counts := make([]identityEventCount, 0, len(widget.Events))
+	events := make([]identityEvent, 0)
+	var failed []string
+
+	for i, kind := range widget.Events {
+		if errs[i] != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", kind, errs[i]))
+			continue
+		}
+
+		count := identityEventCount{Kind: kind, Label: identityEventKindLabels[kind]}
+
+		for _, event := range responses[i] {
+			if event.Time.Before(since) {
+				continue
+			}
+
+			count.Count++
+			events = append(events, event)
+		}
+
+		count.AtLeast = len(responses[i]) >= identityEventsMaxPerKind && count.Count == len(responses[i])
+		counts = append(counts, count)
+	}
+
+	if len(failed) > 0 {
+		err = fmt.Errorf("%w: %s", ternary(len(failed) == len(widget.Events), errNoContent, errPartialContent), strings.Join(failed, "; "))
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.After(events[j].Time)
+	})
+
+	if len(events) > widget.Limit {
+		events = events[:widget.Limit]
+	}
+
+	widget.Counts = counts
+	widget.RecentEvents = events
+}
+
+func (widget *identityEventsWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, identityEventsWidgetTemplate)
+}
+
+type identityEvent struct {
+	Kind   string
+	User   string
+	Detail string
+	IP     string
+	URL    string
+	Time   time.Time
+}
+
+func (e identityEvent) Label() string {
+	return identityEventKindLabels[e.Kind]
+}
+
+type identityEventCount struct {
+	Kind    string
+	Label   string
+	Count   int
+	AtLeast bool
+}
+
+type authentikEventsResponseJson struct {
+	Results []struct {
+		PK   string `json:"pk"`
+		User struct {
+			Username string `json:"username"`
+		} `json:"user"`
+		Action   string         `json:"action"`
+		Context  map[string]any `json:"context"`
+		ClientIP string         `json:"client_ip"`
+		Created  string         `json:"created"`
+	} `json:"results"`
+}
+
+var authentikEventActions = map[string]string{
+	"failed-login":  "login_failed",
+	"new-device":    "model_created",
+	"impersonation": "impersonation_started",
+}
+
+func fetchAuthentikEvents(client requestDoer, instanceURL, token, kind string) ([]identityEvent, error) {
+	query := url.Values{
+		"action":    {authentikEventActions[kind]},
+		"ordering":  {"-created"},
+		"page_size": {fmt.Sprint(identityEventsMaxPerKind)},
+	}
+
+	request, _ := http.NewRequest("GET", instanceURL+"/api/v3/events/events/?"+query.Encode(), nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	response, err := decodeJsonFromRequest[authentikEventsResponseJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]identityEvent, 0, len(response.Results))
+
+	for _, result := range response.Results {
+		event := identityEvent{
+			Kind: kind,
+			User: result.User.Username,
+			IP:   result.ClientIP,
+			URL:  instanceURL + "/if/admin/#/events/log/" + result.PK,
+			Time: parseRFC3339Time(result.Created),
+		}
+
+		switch kind {
+		case "failed-login":
+			// the user of failed logins is anonymous, the username that was
+			// attempted is part of the context instead
+			if username, ok := result.Context["username"].(string); ok && username != "" {
+				event.User = username
+			}
+		case "new-device":
+			// every created object is logged under the same action, devices
+			// are the models of the authenticator stages, such as totpdevice
+			model, _ := result.Context["model"].(map[string]any)
+			modelName, _ := model["model_name"].(string)
+			if !strings.HasSuffix(modelName, "device") {
+				continue
+			}
+
+			event.Detail = strings.TrimSuffix(modelName, "device")
+		case "impersonation":
+			if user, ok := result.Context["user"].(map[string]any); ok {
+				if username, ok := user["username"].(string); ok {
+					event.Detail = "as " + username
+				}
+			}
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+type keycloakEventResponseJson struct {
+	Time      int64             `json:"time"`
+	Type      string            `json:"type"`
+	UserID    string            `json:"userId"`
+	IPAddress string            `json:"ipAddress"`
+	Error     string            `json:"error"`
+	Details   map[string]string `json:"details"`
+}
+
+var keycloakEventTypes = map[string][]string{
+	"failed-login":  {"LOGIN_ERROR"},
+	"new-device":    {"UPDATE_TOTP", "UPDATE_CREDENTIAL"},
+	"impersonation": {"IMPERSONATE"},
+}
+
+// Requires the service account of the client to have the view-events role of
+// the realm-management client
+func fetchKeycloakEvents(client requestDoer, instanceURL, realm, token, kind string, since time.Time) ([]identityEvent, error) {
+	query := url.Values{
+		"type":     keycloakEventTypes[kind],
+		"dateFrom": {since.UTC().Format("2006-01-02")},
+		"max":      {fmt.Sprint(identityEventsMaxPerKind)},
+	}
+
+	request, _ := http.NewRequest("GET", instanceURL+"/admin/realms/"+url.PathEscape(realm)+"/events?"+query.Encode(), nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	response, err := decodeJsonFromRequest[[]keycloakEventResponseJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]identityEvent, 0, len(response))
+
+	for _, result := range response {
+		event := identityEvent{
+			Kind: kind,
+			User: result.Details["username"],
+			IP:   result.IPAddress,
+			Time: time.UnixMilli(result.Time),
+		}
+
+		if event.User == "" {
+			event.User = result.UserID
+		}
+
+		switch kind {
+		case "failed-login":
+			event.Detail = strings.ReplaceAll(result.Error, "_", " ")
+		case "new-device":
+			// also used when changing passwords
+			credentialType := result.Details["credential_type"]
+			if credentialType == "password" {
+				continue
+			}
+
+			event.Detail = ternary(credentialType == "", "otp", credentialType)
+		case "impersonation":
+			if impersonator := result.Details["impersonator"]; impersonator != "" {
+				event.Detail = "by " + impersonator
+			}
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+type keycloakTokenResponseJson struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Access tokens of Keycloak are only valid for a few minutes, so one is
+// requested using the client credentials and reused until shortly before it
+// expires
+func (widget *identityEventsWidget) keycloakAccessToken(client requestDoer) (string, error) {
+	widget.accessTokenMu.Lock()
+	defer widget.accessTokenMu.Unlock()
+
+	if widget.accessToken != "" && time.Now().Before(widget.accessTokenExpires) {
+		return widget.accessToken, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {widget.ClientID},
+		"client_secret": {widget.ClientSecret},
+	}
+
+	request, err := http.NewRequest(
+		http.MethodPost,
+		widget.URL+"/realms/"+url.PathEscape(widget.Realm)+"/protocol/openid-connect/token",
+		strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := decodeJsonFromRequest[keycloakTokenResponseJson](client, request)
+	if err != nil {
+		return "", err
+	}
+
+	if response.AccessToken == "" {
+		return "", errors.New("no access token in response")
+	}
+
+	widget.accessToken = response.AccessToken
+	widget.accessTokenExpires = time.Now().Add(time.Duration(max(response.ExpiresIn-30, 0)) * time.Second)
+
+	return widget.accessToken, nil
+}
+
+type identityEventJson struct {
+	Kind   string    `json:"kind"`
+	User   string    `json:"user"`
+	Detail string    `json:"detail,omitempty"`
+	IP     string    `json:"ip,omitempty"`
+	URL    string    `json:"url,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+func (widget *identityEventsWidget) data() any {
+	counts := make(map[string]int, len(widget.Counts))
+	for _, count := range widget.Counts {
+		counts[count.Kind] = count.Count
+	}
+
+	events := make([]identityEventJson, 0, len(widget.RecentEvents))
+	for _, event := range widget.RecentEvents {
+		events = append(events, identityEventJson(event))
+	}
+
+	return map[string]any{
+		"period_seconds": int(time.Duration(widget.Period).Seconds()),
+		"counts":         counts,
+		"events":         events,
+	}
+}
@@ -0,0 +1,448 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var jobListingsWidgetTemplate = mustParseTemplate("job-listings.html", "widget-base.html")
+
+// postings that haven't been seen for this long are forgotten, after which
+// they'd be considered new again if they were to reappear
+const jobListingsRetention = 60 * 24 * time.Hour
+
+type jobListingsWidget struct {
+	widgetBase       `yaml:",inline"`
+	seenItemsOptions `yaml:",inline"`
+	Sources          []jobListingsSource `yaml:"sources"`
+	Keywords         []string            `yaml:"keywords"`
+	ExcludeKeywords  []string            `yaml:"exclude-keywords"`
+	Limit            int                 `yaml:"limit"`
+	CollapseAfter    int                 `yaml:"collapse-after"`
+	Postings         []jobPosting        `yaml:"-"`
+	stateMutex       sync.Mutex          `yaml:"-"`
+}
+
+type jobListingsSource struct {
+	Type    string `yaml:"type"`
+	URL     string `yaml:"url"`
+	Company string `yaml:"company"`
+}
+
+type jobPosting struct {
+	Title    string
+	Company  string
+	Location string
+	URL      string
+	Source   string
+	PostedAt time.Time
+	text     string
+}
+
+func (p jobPosting) ID() string {
+	return seenItemID(p.URL)
+}
+
+// Postings are deduplicated by company and title since the same position is
+// often listed on multiple boards or reposted with a new ID
+func (p *jobPosting) dedupKey() string {
+	return strings.ToLower(sequentialWhitespacePattern.ReplaceAllString(p.Company+" "+p.Title, " "))
+}
+
+type jobListingsState struct {
+	// dedup key -> unix timestamps of when the posting was first and last seen
+	Postings map[string][2]int64 `json:"postings"`
+}
+
+func (widget *jobListingsWidget) initialize() error {
+	widget.withTitle("Job Listings").withCacheDuration(3 * time.Hour)
+	widget.initializeSeenItems()
+
+	if len(widget.Sources) == 0 {
+		return errors.New("no sources specified")
+	}
+
+	for i := range widget.Sources {
+		source := &widget.Sources[i]
+
+		switch source.Type {
+		case "hn-hiring":
+		case "rss":
+			if source.URL == "" {
+				return fmt.Errorf("source %d: url is required for rss", i+1)
+			}
+		case "greenhouse", "lever":
+			if source.Company == "" {
+				return fmt.Errorf("source %d: company is required for %s", i+1, source.Type)
+			}
+		default:
+			return fmt.Errorf("source %d: type must be one of hn-hiring, rss, greenhouse or lever", i+1)
+		}
+	}
+
+	for i := range widget.Keywords {
+		widget.Keywords[i] = strings.ToLower(widget.Keywords[i])
+	}
+
+	for i := range widget.ExcludeKeywords {
+		widget.ExcludeKeywords[i] = strings.ToLower(widget.ExcludeKeywords[i])
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 25
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *jobListingsWidget) update(ctx context.Context) {
+	postings, err := fetchJobPostings(widget.httpClient(false), widget.Sources)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	postings = widget.filterPostings(postings)
+	postings = widget.deduplicate(postings, time.Now())
+
+	sort.SliceStable(postings, func(i, j int) bool {
+		return postings[i].PostedAt.After(postings[j].PostedAt)
+	})
+
+	if len(postings) > widget.Limit {
+		postings = postings[:widget.Limit]
+	}
+
+	widget.Postings = postings
+}
+
+func (widget *jobListingsWidget) filterPostings(postings []jobPosting) []jobPosting {
+	filtered := make([]jobPosting, 0, len(postings))
+
+	for i := range postings {
+		haystack := strings.ToLower(postings[i].Title + " " + postings[i].Company + " " + postings[i].Location + " " + postings[i].text)
+
+		if len(widget.Keywords) > 0 && !containsAnyKeyword(haystack, widget.Keywords) {
+			continue
+		}
+
+		if containsAnyKeyword(haystack, widget.ExcludeKeywords) {
+			continue
+		}
+
+		filtered = append(filtered, postings[i])
+	}
+
+	return filtered
+}
+
+func containsAnyKeyword(haystack string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(haystack, keyword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (widget *jobListingsWidget) stateKey() string {
+	var sources []string
+	for i := range widget.Sources {
+		sources = append(sources, widget.Sources[i].Type+":"+widget.Sources[i].URL+widget.Sources[i].Company)
+	}
+
+	return "job-listings:" + seenItemID(strings.Join(sources, ","))
+}
+
+// Removes duplicate postings and dates each one by when it was first seen if
+// that's earlier than the date it was posted on, so that reposts don't show up as new
+func (widget *jobListingsWidget) deduplicate(postings []jobPosting, now time.Time) []jobPosting {
+	widget.stateMutex.Lock()
+	defer widget.stateMutex.Unlock()
+
+	var state jobListingsState
+	widget.Providers.state.get(widget.stateKey(), &state)
+	if state.Postings == nil {
+		state.Postings = make(map[string][2]int64)
+	}
+
+	deduplicated := make([]jobPosting, 0, len(postings))
+	seen := make(map[string]struct{}, len(postings))
+
+	for i := range postings {
+		posting := &postings[i]
+		key := posting.dedupKey()
+
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		if posting.PostedAt.IsZero() || posting.PostedAt.After(now) {
+			posting.PostedAt = now
+		}
+
+		timestamps, known := state.Postings[key]
+		if !known {
+			timestamps[0] = posting.PostedAt.Unix()
+		} else if firstSeen := time.Unix(timestamps[0], 0); firstSeen.Before(posting.PostedAt) {
+			posting.PostedAt = firstSeen
+		}
+
+		timestamps[1] = now.Unix()
+		state.Postings[key] = timestamps
+		deduplicated = append(deduplicated, *posting)
+	}
+
+	cutoff := now.Add(-jobListingsRetention).Unix()
+	for key, timestamps := range state.Postings {
+		if timestamps[1] < cutoff {
+			delete(state.Postings, key)
+		}
+	}
+
+	widget.Providers.state.set(widget.stateKey(), state)
+
+	return deduplicated
+}
+
+func (widget *jobListingsWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, jobListingsWidgetTemplate)
+}
+
+func fetchJobPostings(client requestDoer, sources []jobListingsSource) ([]jobPosting, error) {
+	requests := make([]*jobListingsSource, len(sources))
+	for i := range sources {
+		requests[i] = &sources[i]
+	}
+
+	job := newJob(func(source *jobListingsSource) ([]jobPosting, error) {
+		return source.fetch(client)
+	}, requests).withWorkers(len(requests))
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	var postings []jobPosting
+	var failed int
+
+	for i := range results {
+		if errs[i] != nil {
+			failed++
+			slog.Error("Failed to fetch job postings", "type", sources[i].Type, "url", sources[i].URL, "company", sources[i].Company, "error", errs[i])
+			continue
+		}
+
+		postings = append(postings, results[i]...)
+	}
+
+	if failed == len(sources) {
+		return nil, fmt.Errorf("%w: %v", errNoContent, errs[0])
+	}
+
+	if failed > 0 {
+		return postings, fmt.Errorf("%w: could not fetch %d sources", errPartialContent, failed)
+	}
+
+	return postings, nil
+}
+
+func (source *jobListingsSource) fetch(client requestDoer) ([]jobPosting, error) {
+	switch source.Type {
+	case "hn-hiring":
+		return fetchHNWhoIsHiringPostings(client)
+	case "greenhouse":
+		return fetchGreenhousePostings(client, source.Company)
+	case "lever":
+		return fetchLeverPostings(client, source.Company)
+	default:
+		return fetchRSSJobPostings(client, source.URL)
+	}
+}
+
+type hnAlgoliaItemJson struct {
+	ID        int                 `json:"id"`
+	Text      string              `json:"text"`
+	CreatedAt string              `json:"created_at"`
+	Children  []hnAlgoliaItemJson `json:"children"`
+}
+
+func fetchHNWhoIsHiringPostings(client requestDoer) ([]jobPosting, error) {
+	request, _ := http.NewRequest("GET", "https://hn.algolia.com/api/v1/search_by_date?tags=story,author_whoishiring&hitsPerPage=10", nil)
+	search, err := decodeJsonFromRequest[struct {
+		Hits []struct {
+			ObjectID string `json:"objectID"`
+			Title    string `json:"title"`
+		} `json:"hits"`
+	}](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("finding thread: %v", err)
+	}
+
+	var threadID string
+	for _, hit := range search.Hits {
+		if strings.Contains(strings.ToLower(hit.Title), "who is hiring") {
+			threadID = hit.ObjectID
+			break
+		}
+	}
+
+	if threadID == "" {
+		return nil, errors.New("could not find the latest Who is hiring thread")
+	}
+
+	request, _ = http.NewRequest("GET", "https://hn.algolia.com/api/v1/items/"+threadID, nil)
+	thread, err := decodeJsonFromRequest[hnAlgoliaItemJson](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching thread: %v", err)
+	}
+
+	postings := make([]jobPosting, 0, len(thread.Children))
+
+	for i := range thread.Children {
+		comment := &thread.Children[i]
+		if comment.Text == "" {
+			continue
+		}
+
+		// by convention the first line is "Company | Position | Location | ..."
+		firstLine, _, _ := strings.Cut(comment.Text, "<p>")
+		firstLine = html.UnescapeString(htmlTagsWithAttributesPattern.ReplaceAllString(firstLine, ""))
+		company, rest, _ := strings.Cut(firstLine, "|")
+
+		posting := jobPosting{
+			Title:    strings.TrimSpace(ternary(rest != "", rest, firstLine)),
+			Company:  strings.TrimSpace(company),
+			URL:      "https://news.ycombinator.com/item?id=" + strconv.Itoa(comment.ID),
+			Source:   "Hacker News",
+			PostedAt: parseRFC3339Time(comment.CreatedAt),
+			text:     html.UnescapeString(htmlTagsWithAttributesPattern.ReplaceAllString(comment.Text, " ")),
+		}
+
+		posting.Title, _ = limitStringLength(posting.Title, 150)
+		postings = append(postings, posting)
+	}
+
+	return postings, nil
+}
+
+func fetchGreenhousePostings(client requestDoer, company string) ([]jobPosting, error) {
+	request, _ := http.NewRequest("GET", "https://boards-api.greenhouse.io/v1/boards/"+url.PathEscape(company)+"/jobs", nil)
+	response, err := decodeJsonFromRequest[struct {
+		Jobs []struct {
+			Title       string `json:"title"`
+			AbsoluteURL string `json:"absolute_url"`
+			UpdatedAt   string `json:"updated_at"`
+			Location    struct {
+				Name string `json:"name"`
+			} `json:"location"`
+		} `json:"jobs"`
+	}](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	postings := make([]jobPosting, 0, len(response.Jobs))
+
+	for _, job := range response.Jobs {
+		postings = append(postings, jobPosting{
+			Title:    job.Title,
+			Company:  company,
+			Location: job.Location.Name,
+			URL:      job.AbsoluteURL,
+			Source:   "Greenhouse",
+			PostedAt: parseRFC3339Time(job.UpdatedAt),
+		})
+	}
+
+	return postings, nil
+}
+
+func fetchLeverPostings(client requestDoer, company string) ([]jobPosting, error) {
+	request, _ := http.NewRequest("GET", "https://api.lever.co/v0/postings/"+url.PathEscape(company)+"?mode=json", nil)
+	response, err := decodeJsonFromRequest[[]struct {
+		Text       string `json:"text"`
+		HostedURL  string `json:"hostedUrl"`
+		CreatedAt  int64  `json:"createdAt"`
+		Categories struct {
+			Location string `json:"location"`
+			Team     string `json:"team"`
+		} `json:"categories"`
+		DescriptionPlain string `json:"descriptionPlain"`
+	}](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	postings := make([]jobPosting, 0, len(response))
+
+	for _, job := range response {
+		postings = append(postings, jobPosting{
+			Title:    job.Text,
+			Company:  company,
+			Location: job.Categories.Location,
+			URL:      job.HostedURL,
+			Source:   "Lever",
+			PostedAt: time.UnixMilli(job.CreatedAt),
+			text:     job.Categories.Team + " " + job.DescriptionPlain,
+		})
+	}
+
+	return postings, nil
+}
+
+func fetchRSSJobPostings(client requestDoer, feedURL string) ([]jobPosting, error) {
+	request, _ := http.NewRequest("GET", feedURL, nil)
+	setBrowserUserAgentHeader(request)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", response.StatusCode, feedURL)
+	}
+
+	feed, err := feedParser.Parse(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	postings := make([]jobPosting, 0, len(feed.Items))
+
+	for _, item := range feed.Items {
+		posting := jobPosting{
+			Title:  item.Title,
+			URL:    item.Link,
+			Source: feed.Title,
+			text:   sanitizeFeedDescription(item.Description),
+		}
+
+		if item.PublishedParsed != nil {
+			posting.PostedAt = *item.PublishedParsed
+		}
+
+		postings = append(postings, posting)
+	}
+
+	return postings, nil
+}
@@ -0,0 +1,214 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+var kpiWidgetTemplate = mustParseTemplate("kpi.html", "widget-base.html")
+
+type kpiWidget struct {
+	widgetBase        `yaml:",inline"`
+	*CustomAPIRequest `yaml:",inline"`
+	Value             string  `yaml:"value"`
+	Target            float64 `yaml:"target"`
+	Prefix            string  `yaml:"prefix"`
+	Suffix            string  `yaml:"suffix"`
+	Precision         *int    `yaml:"precision"`
+	LowerIsBetter     bool    `yaml:"lower-is-better"`
+	History           int     `yaml:"history"`
+
+	Current     float64    `yaml:"-"`
+	Previous    float64    `yaml:"-"`
+	HasPrevious bool       `yaml:"-"`
+	Chart       string     `yaml:"-"`
+	stateMutex  sync.Mutex `yaml:"-"`
+}
+
+type kpiState struct {
+	// pairs of unix timestamps and values, one for each refresh
+	History [][2]float64 `json:"history"`
+}
+
+func (widget *kpiWidget) initialize() error {
+	widget.withTitle("KPI").withCacheDuration(time.Hour)
+
+	if widget.CustomAPIRequest == nil {
+		return errors.New("url is required")
+	}
+
+	if err := widget.CustomAPIRequest.initialize(); err != nil {
+		return fmt.Errorf("initializing request: %v", err)
+	}
+
+	widget.CustomAPIRequest.client = widget.httpClient(widget.CustomAPIRequest.AllowInsecure)
+
+	if widget.History <= 0 {
+		widget.History = 30
+	}
+
+	return nil
+}
+
+func (widget *kpiWidget) update(ctx context.Context) {
+	value, err := widget.fetchValue(ctx)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.stateMutex.Lock()
+	defer widget.stateMutex.Unlock()
+
+	var state kpiState
+	widget.Providers.state.get(widget.stateKey(), &state)
+
+	state.History = append(state.History, [2]float64{float64(time.Now().Unix()), value})
+	if len(state.History) > widget.History {
+		state.History = state.History[len(state.History)-widget.History:]
+	}
+
+	widget.Providers.state.set(widget.stateKey(), state)
+
+	widget.Current = value
+	widget.HasPrevious = len(state.History) > 1
+	if widget.HasPrevious {
+		widget.Previous = state.History[len(state.History)-2][1]
+	}
+
+	values := make([]float64, len(state.History))
+	for i := range state.History {
+		values[i] = state.History[i][1]
+	}
+
+	switch {
+	case len(values) < 2:
+		widget.Chart = ""
+	case slices.Min(values) == slices.Max(values):
+		// the values can't be scaled when they're all the same, so they're
+		// drawn as a flat line through the middle
+		widget.Chart = "0,25 100,25"
+	default:
+		widget.Chart = svgPolylineCoordsFromYValues(100, 50, values)
+	}
+}
+
+func (widget *kpiWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, kpiWidgetTemplate)
+}
+
+func (widget *kpiWidget) stateKey() string {
+	return "kpi:" + seenItemID(widget.URL+"#"+widget.Value)
+}
+
+func (widget *kpiWidget) fetchValue(ctx context.Context) (float64, error) {
+	response, err := fetchCustomAPIRequest(ctx, widget.CustomAPIRequest)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	if response.Response.StatusCode != 200 {
+		return 0, fmt.Errorf("%w: unexpected status code %d", errNoContent, response.Response.StatusCode)
+	}
+
+	result := response.JSON.Result
+	if widget.Value != "" {
+		result = result.Get(widget.Value)
+	}
+
+	if !result.Exists() {
+		return 0, fmt.Errorf("%w: value %q not found in response", errNoContent, widget.Value)
+	}
+
+	if result.Type == gjson.Number {
+		return result.Float(), nil
+	}
+
+	// numbers are sometimes returned as strings, possibly with formatting such as "$1,234.56"
+	value, ok := parsePrice(result.String())
+	if !ok {
+		return 0, fmt.Errorf("%w: value %q is not a number", errNoContent, result.String())
+	}
+
+	return value, nil
+}
+
+func (widget *kpiWidget) format(value float64) string {
+	precision := 2
+	if widget.Precision != nil {
+		precision = *widget.Precision
+	} else if value == math.Trunc(value) {
+		precision = 0
+	}
+
+	return widget.Prefix + widget.printer().Sprintf("%.*f", precision, value) + widget.Suffix
+}
+
+func (widget *kpiWidget) FormattedCurrent() string {
+	return widget.format(widget.Current)
+}
+
+func (widget *kpiWidget) FormattedTarget() string {
+	return widget.format(widget.Target)
+}
+
+func (widget *kpiWidget) FormattedDelta() string {
+	delta := widget.Current - widget.Previous
+	formatted := widget.format(math.Abs(delta))
+
+	switch {
+	case delta > 0:
+		formatted = "+" + formatted
+	case delta < 0:
+		formatted = "−" + formatted
+	}
+
+	if widget.Previous != 0 {
+		formatted += widget.printer().Sprintf(" (%.1f%%)", math.Abs(delta/widget.Previous)*100)
+	}
+
+	return formatted
+}
+
+// Returns 1 if the value changed for the better since the previous refresh,
+// -1 if it changed for the worse and 0 if it didn't change
+func (widget *kpiWidget) DeltaDirection() int {
+	if !widget.HasPrevious || widget.Current == widget.Previous {
+		return 0
+	}
+
+	if (widget.Current > widget.Previous) != widget.LowerIsBetter {
+		return 1
+	}
+
+	return -1
+}
+
+func (widget *kpiWidget) TargetMet() bool {
+	if widget.LowerIsBetter {
+		return widget.Current <= widget.Target
+	}
+
+	return widget.Current >= widget.Target
+}
+
+// How far along the value is towards the target, from 0 to 100
+func (widget *kpiWidget) TargetProgress() int {
+	if widget.Target == 0 || widget.TargetMet() {
+		return 100
+	}
+
+	if widget.LowerIsBetter {
+		return int(max(0, widget.Target/widget.Current*100))
+	}
+
+	return int(max(0, widget.Current/widget.Target*100))
+}
@@ -0,0 +1,428 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var listingWatchWidgetTemplate = mustParseTemplate("listing-watch.html", "widget-base.html")
+
+// listings that haven't been seen for this long are forgotten, after which
+// they'd be considered new again if they were to reappear
+const listingWatchRetention = 30 * 24 * time.Hour
+
+type listingWatchWidget struct {
+	widgetBase    `yaml:",inline"`
+	Searches      []listingWatchSearch `yaml:"searches"`
+	Notify        []string             `yaml:"notify"`
+	NewFor        durationField        `yaml:"new-for"`
+	Limit         int                  `yaml:"limit"`
+	CollapseAfter int                  `yaml:"collapse-after"`
+	Listings      []listing            `yaml:"-"`
+	stateMutex    sync.Mutex           `yaml:"-"`
+}
+
+type listingWatchSearch struct {
+	Name        string            `yaml:"name"`
+	URL         string            `yaml:"url"`
+	Headers     map[string]string `yaml:"headers"`
+	Item        string            `yaml:"item"`
+	IDAttribute string            `yaml:"id-attribute"`
+	Title       string            `yaml:"title"`
+	Price       string            `yaml:"price"`
+	Link        string            `yaml:"link"`
+	Location    string            `yaml:"location"`
+	MaxPrice    float64           `yaml:"max-price"`
+}
+
+type listing struct {
+	Title         string
+	URL           string
+	Search        string
+	Location      string
+	Price         string
+	PreviousPrice string
+	PriceDropped  bool
+	FirstSeen     time.Time
+	IsNew         bool
+	id            string
+	price         float64
+}
+
+type listingState struct {
+	FirstSeen         int64   `json:"first_seen"`
+	LastSeen          int64   `json:"last_seen"`
+	Price             float64 `json:"price"`
+	PriceText         string  `json:"price_text"`
+	PreviousPrice     float64 `json:"previous_price,omitempty"`
+	PreviousPriceText string  `json:"previous_price_text,omitempty"`
+	// whether the listing was already there the first time the search was checked
+	Baseline bool `json:"baseline,omitempty"`
+}
+
+type listingWatchState struct {
+	Listings map[string]listingState `json:"listings"`
+}
+
+func (widget *listingWatchWidget) initialize() error {
+	widget.withTitle("Listing Watch").withCacheDuration(time.Hour)
+
+	if len(widget.Searches) == 0 {
+		return errors.New("no searches specified")
+	}
+
+	for i := range widget.Searches {
+		search := &widget.Searches[i]
+
+		if search.URL == "" {
+			return fmt.Errorf("search %d: url is required", i+1)
+		}
+
+		if search.Item == "" {
+			return fmt.Errorf("search %d: item is required", i+1)
+		}
+
+		if search.Name == "" {
+			if parsed, err := url.Parse(search.URL); err == nil {
+				search.Name = parsed.Hostname()
+			}
+		}
+
+		if search.Link == "" {
+			search.Link = "a[href]"
+		}
+	}
+
+	if widget.NewFor <= 0 {
+		widget.NewFor = durationField(24 * time.Hour)
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 25
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *listingWatchWidget) getNotificationTargets() []string {
+	return widget.Notify
+}
+
+func (widget *listingWatchWidget) update(ctx context.Context) {
+	results, err := fetchListings(widget.httpClient(false), widget.Searches)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	now := time.Now()
+	var listings []listing
+
+	for i := range results {
+		// the state of searches that failed to be fetched is kept as is
+		if results[i] == nil {
+			continue
+		}
+
+		listings = append(listings, widget.trackChanges(&widget.Searches[i], results[i], now)...)
+	}
+
+	sort.SliceStable(listings, func(i, j int) bool {
+		return listings[i].FirstSeen.After(listings[j].FirstSeen)
+	})
+
+	if len(listings) > widget.Limit {
+		listings = listings[:widget.Limit]
+	}
+
+	widget.Listings = listings
+}
+
+func (widget *listingWatchWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, listingWatchWidgetTemplate)
+}
+
+// Scoped by the widget so that the same search in another widget still gets
+// notified about, which may be to different targets
+func (widget *listingWatchWidget) stateKey(search *listingWatchSearch) string {
+	return "listing-watch:" + seenItemID(widget.stateScope()+"#"+search.URL)
+}
+
+// Compares the listings against the ones from previous checks, keeping track
+// of when each was first seen and what its price was, and sends notifications
+// about new listings and price changes
+func (widget *listingWatchWidget) trackChanges(search *listingWatchSearch, listings []listing, now time.Time) []listing {
+	widget.stateMutex.Lock()
+	defer widget.stateMutex.Unlock()
+
+	var state listingWatchState
+	// no notifications are sent for the listings present on the very first check
+	isFirstCheck := !widget.Providers.state.get(widget.stateKey(search), &state)
+	if state.Listings == nil {
+		state.Listings = make(map[string]listingState)
+	}
+
+	var notifications []notification
+
+	for i := range listings {
+		l := &listings[i]
+		previous, exists := state.Listings[l.id]
+
+		current := listingState{
+			FirstSeen:         ternary(exists, previous.FirstSeen, now.Unix()),
+			LastSeen:          now.Unix(),
+			Price:             l.price,
+			PriceText:         l.Price,
+			PreviousPrice:     previous.PreviousPrice,
+			PreviousPriceText: previous.PreviousPriceText,
+			Baseline:          ternary(exists, previous.Baseline, isFirstCheck),
+		}
+
+		if !exists && !isFirstCheck {
+			details := l.Price
+			if l.Location != "" {
+				details = strings.TrimSpace(details + " " + l.Location)
+			}
+
+			notifications = append(notifications, notification{
+				Title:   "New listing: " + l.Title,
+				Message: strings.TrimSpace(details + " (" + search.Name + ")"),
+				URL:     l.URL,
+			})
+		}
+
+		if exists && l.price > 0 && previous.Price > 0 && l.price != previous.Price {
+			current.PreviousPrice = previous.Price
+			current.PreviousPriceText = previous.PriceText
+
+			notifications = append(notifications, notification{
+				Title:   "Price " + ternary(l.price < previous.Price, "dropped", "increased") + ": " + l.Title,
+				Message: previous.PriceText + " → " + l.Price + " (" + search.Name + ")",
+				URL:     l.URL,
+			})
+		}
+
+		state.Listings[l.id] = current
+
+		l.FirstSeen = time.Unix(current.FirstSeen, 0)
+		l.IsNew = !current.Baseline && now.Sub(l.FirstSeen) < time.Duration(widget.NewFor)
+		l.PreviousPrice = current.PreviousPriceText
+		l.PriceDropped = current.PreviousPrice > 0 && l.price < current.PreviousPrice
+	}
+
+	for id, s := range state.Listings {
+		if now.Sub(time.Unix(s.LastSeen, 0)) > listingWatchRetention {
+			delete(state.Listings, id)
+		}
+	}
+
+	widget.Providers.state.set(widget.stateKey(search), state)
+
+	if len(widget.Notify) > 0 {
+		for i := range notifications {
+			widget.Providers.notifier.notify(widget.Notify, notifications[i])
+		}
+	}
+
+	return listings
+}
+
+func (search *listingWatchSearch) fetch(client requestDoer) ([]listing, error) {
+	request, err := http.NewRequest("GET", search.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	setBrowserUserAgentHeader(request)
+	for key, value := range search.Headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", response.StatusCode, search.URL)
+	}
+
+	document, err := goquery.NewDocumentFromReader(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := response.Request.URL
+	var listings []listing
+
+	document.Find(search.Item).Each(func(_ int, item *goquery.Selection) {
+		l := listing{
+			Title:    selectionText(item, search.Title),
+			Price:    selectionText(item, search.Price),
+			Location: selectionText(item, search.Location),
+			Search:   search.Name,
+		}
+
+		if href, ok := item.Find(search.Link).First().Attr("href"); ok {
+			if resolved, err := baseURL.Parse(href); err == nil {
+				l.URL = resolved.String()
+			}
+		} else if href, ok := item.Attr("href"); ok {
+			if resolved, err := baseURL.Parse(href); err == nil {
+				l.URL = resolved.String()
+			}
+		}
+
+		if search.IDAttribute != "" {
+			l.id, _ = item.Attr(search.IDAttribute)
+		}
+
+		if l.id == "" {
+			l.id = l.URL
+		}
+
+		if l.id == "" {
+			return
+		}
+
+		if l.Title == "" {
+			l.Title = limitedSelectionText(item.Find(search.Link).First())
+		}
+
+		l.price, _ = parsePrice(l.Price)
+
+		if search.MaxPrice > 0 && l.price > search.MaxPrice {
+			return
+		}
+
+		listings = append(listings, l)
+	})
+
+	if len(listings) == 0 && document.Find(search.Item).Length() == 0 {
+		return nil, fmt.Errorf("no elements matched %s", search.Item)
+	}
+
+	return listings, nil
+}
+
+// Returns the whitespace normalized text of the first element matching the
+// selector, or nothing if no selector was specified
+func selectionText(selection *goquery.Selection, selector string) string {
+	if selector == "" {
+		return ""
+	}
+
+	return limitedSelectionText(selection.Find(selector).First())
+}
+
+func limitedSelectionText(selection *goquery.Selection) string {
+	text := strings.TrimSpace(sequentialWhitespacePattern.ReplaceAllString(selection.Text(), " "))
+	text, _ = limitStringLength(text, 200)
+
+	return text
+}
+
+// either a number with grouped thousands followed by optional decimals, or a plain number
+var priceNumberPattern = regexp.MustCompile(`\d{1,3}(?:[.,' \x{00a0}]\d{3})+(?:[.,]\d+)?|\d+(?:[.,]\d+)?`)
+
+// Parses the first number within a price such as "$1,299.99", "1.299,99 €" or
+// "CHF 1'299.-", taking into account the different thousands and decimal separators
+func parsePrice(text string) (float64, bool) {
+	number := priceNumberPattern.FindString(text)
+	if number == "" {
+		return 0, false
+	}
+
+	number = strings.NewReplacer(" ", "", "\u00a0", "", "'", "").Replace(number)
+
+	lastDot := strings.LastIndex(number, ".")
+	lastComma := strings.LastIndex(number, ",")
+
+	decimalSeparator := ""
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		decimalSeparator = ternary(lastDot > lastComma, ".", ",")
+	case lastDot >= 0 || lastComma >= 0:
+		separator := ternary(lastDot >= 0, ".", ",")
+		// a single separator followed by exactly three digits is most likely a thousands separator
+		if strings.Count(number, separator) == 1 && len(number)-strings.LastIndex(number, separator)-1 != 3 {
+			decimalSeparator = separator
+		}
+	}
+
+	var thousandsSeparator string
+	if decimalSeparator == "" {
+		number = strings.NewReplacer(".", "", ",", "").Replace(number)
+	} else {
+		thousandsSeparator = ternary(decimalSeparator == ".", ",", ".")
+		number = strings.ReplaceAll(number, thousandsSeparator, "")
+		number = strings.Replace(number, decimalSeparator, ".", 1)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return value, true
+}
+
+// Returns the listings of each search, with the results of the searches that
+// couldn't be fetched being nil
+func fetchListings(client requestDoer, searches []listingWatchSearch) ([][]listing, error) {
+	requests := make([]*listingWatchSearch, len(searches))
+	for i := range searches {
+		requests[i] = &searches[i]
+	}
+
+	job := newJob(func(search *listingWatchSearch) ([]listing, error) {
+		return search.fetch(client)
+	}, requests).withWorkers(len(requests))
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	var failed int
+
+	for i := range results {
+		if errs[i] != nil {
+			failed++
+			results[i] = nil
+			slog.Error("Failed to fetch listings", "url", searches[i].URL, "error", errs[i])
+			continue
+		}
+
+		if results[i] == nil {
+			results[i] = []listing{}
+		}
+	}
+
+	if failed == len(searches) {
+		return nil, fmt.Errorf("%w: %v", errNoContent, errs[0])
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%w: could not fetch %d searches", errPartialContent, failed)
+	}
+
+	return results, nil
+}
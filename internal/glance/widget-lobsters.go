@@ -2,26 +2,33 @@ package glance
 
 import (
 	"context"
+	"fmt"
 	"html/template"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
 
 type lobstersWidget struct {
-	widgetBase     `yaml:",inline"`
-	Posts          forumPostList `yaml:"-"`
-	InstanceURL    string        `yaml:"instance-url"`
-	CustomURL      string        `yaml:"custom-url"`
-	Limit          int           `yaml:"limit"`
-	CollapseAfter  int           `yaml:"collapse-after"`
-	SortBy         string        `yaml:"sort-by"`
-	Tags           []string      `yaml:"tags"`
-	ShowThumbnails bool          `yaml:"-"`
+	widgetBase          `yaml:",inline"`
+	seenItemsOptions    `yaml:",inline"`
+	saveForLaterOptions `yaml:",inline"`
+	Posts               forumPostList `yaml:"-"`
+	InstanceURL         string        `yaml:"instance-url"`
+	CustomURL           string        `yaml:"custom-url"`
+	Limit               int           `yaml:"limit"`
+	CollapseAfter       int           `yaml:"collapse-after"`
+	SortBy              string        `yaml:"sort-by"`
+	Tags                []string      `yaml:"tags"`
+	ExcludeTags         []string      `yaml:"exclude-tags"`
+	ShowThumbnails      bool          `yaml:"-"`
 }
 
 func (widget *lobstersWidget) initialize() error {
 	widget.withTitle("Lobsters").withCacheDuration(time.Hour)
+	widget.initializeSeenItems()
 
 	if widget.InstanceURL == "" {
 		widget.withTitleURL("https://lobste.rs")
@@ -37,6 +44,14 @@ func (widget *lobstersWidget) initialize() error {
 		widget.Limit = 15
 	}
 
+	for i := range widget.Tags {
+		widget.Tags[i] = strings.ToLower(strings.TrimSpace(widget.Tags[i]))
+	}
+
+	for i := range widget.ExcludeTags {
+		widget.ExcludeTags[i] = strings.ToLower(strings.TrimSpace(widget.ExcludeTags[i]))
+	}
+
 	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
 		widget.CollapseAfter = 5
 	}
@@ -45,12 +60,14 @@ func (widget *lobstersWidget) initialize() error {
 }
 
 func (widget *lobstersWidget) update(ctx context.Context) {
-	posts, err := fetchLobstersPosts(widget.CustomURL, widget.InstanceURL, widget.SortBy, widget.Tags)
+	posts, err := fetchLobstersPosts(widget.cachedHTTPClient(false), widget.CustomURL, widget.InstanceURL, widget.SortBy, widget.Tags)
 
 	if !widget.canContinueUpdateAfterHandlingErr(err) {
 		return
 	}
 
+	posts = posts.filterByExcludedTags(widget.ExcludeTags)
+
 	if widget.Limit < len(posts) {
 		posts = posts[:widget.Limit]
 	}
@@ -58,6 +75,14 @@ func (widget *lobstersWidget) update(ctx context.Context) {
 	widget.Posts = posts
 }
 
+func (widget *lobstersWidget) briefing(_ time.Time, headlines int) []string {
+	return widget.Posts.briefing(widget.Title, headlines)
+}
+
+func (widget *lobstersWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	handleSaveForLaterRequest(w, r, &widget.widgetBase, widget.SaveTo, widget.Posts.titleByURL)
+}
+
 func (widget *lobstersWidget) Render() template.HTML {
 	return widget.renderTemplate(widget, forumPostsTemplate)
 }
@@ -74,13 +99,13 @@ type lobstersPostResponseJson struct {
 
 type lobstersFeedResponseJson []lobstersPostResponseJson
 
-func fetchLobstersPostsFromFeed(feedUrl string) (forumPostList, error) {
+func fetchLobstersPostsFromFeed(client requestDoer, feedUrl string) (forumPostList, error) {
 	request, err := http.NewRequest("GET", feedUrl, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	feed, err := decodeJsonFromRequest[lobstersFeedResponseJson](defaultHTTPClient, request)
+	feed, err := decodeJsonFromRequest[lobstersFeedResponseJson](client, request)
 	if err != nil {
 		return nil, err
 	}
@@ -109,36 +134,75 @@ func fetchLobstersPostsFromFeed(feedUrl string) (forumPostList, error) {
 	return posts, nil
 }
 
-func fetchLobstersPosts(customURL string, instanceURL string, sortBy string, tags []string) (forumPostList, error) {
-	var feedUrl string
-
+func fetchLobstersPosts(client requestDoer, customURL string, instanceURL string, sortBy string, tags []string) (forumPostList, error) {
 	if customURL != "" {
-		feedUrl = customURL
+		return fetchLobstersPostsFromFeed(client, customURL)
+	}
+
+	if instanceURL != "" {
+		instanceURL = strings.TrimRight(instanceURL, "/") + "/"
 	} else {
-		if instanceURL != "" {
-			instanceURL = strings.TrimRight(instanceURL, "/") + "/"
-		} else {
-			instanceURL = "https://lobste.rs/"
-		}
+		instanceURL = "https://lobste.rs/"
+	}
 
+	if len(tags) == 0 {
 		if sortBy == "hot" {
 			sortBy = "hottest"
 		} else if sortBy == "new" {
 			sortBy = "newest"
 		}
 
-		if len(tags) == 0 {
-			feedUrl = instanceURL + sortBy + ".json"
-		} else {
-			tags := strings.Join(tags, ",")
-			feedUrl = instanceURL + "t/" + tags + ".json"
-		}
+		return fetchLobstersPostsFromFeed(client, instanceURL+sortBy+".json")
+	}
+
+	// each tag is requested separately so that a single missing tag doesn't
+	// break the whole widget and so that the posts can be sorted afterwards
+	feedUrls := make([]string, len(tags))
+	for i := range tags {
+		feedUrls[i] = instanceURL + "t/" + url.PathEscape(tags[i]) + ".json"
 	}
 
-	posts, err := fetchLobstersPostsFromFeed(feedUrl)
+	job := newJob(func(feedUrl string) (forumPostList, error) {
+		return fetchLobstersPostsFromFeed(client, feedUrl)
+	}, feedUrls).withWorkers(10)
+	results, errs, err := workerPoolDo(job)
 	if err != nil {
 		return nil, err
 	}
 
+	posts := make(forumPostList, 0, len(tags)*25)
+	var failed int
+
+	for i := range results {
+		if errs[i] != nil {
+			failed++
+			slog.Error("Failed to fetch lobsters tag", "tag", tags[i], "error", errs[i])
+			continue
+		}
+
+		posts = append(posts, results[i]...)
+	}
+
+	if failed == len(tags) {
+		return nil, errNoContent
+	}
+
+	posts = posts.deduplicate()
+
+	if sortBy == "new" {
+		posts.sortByNewest()
+	} else {
+		posts.calculateEngagement()
+		posts.sortByEngagement()
+	}
+
+	if failed > 0 {
+		return posts, fmt.Errorf("%w: could not fetch posts for %d tags", errPartialContent, failed)
+	}
+
 	return posts, nil
 }
+
+func (widget *lobstersWidget) data() any {
+	return widget.Posts.data()
+}
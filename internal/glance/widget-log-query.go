@@ -0,0 +1,330 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+var logQueryWidgetTemplate = mustParseTemplate("log-query.html", "widget-base.html")
+
+// How many points the chart of hits over time has
+const logQueryChartPoints = 24
+
+type logQueryWidget struct {
+	widgetBase     `yaml:",inline"`
+	Loki           *lokiConnection          `yaml:"loki"`
+	Elasticsearch  *elasticsearchConnection `yaml:"elasticsearch"`
+	Range          durationField            `yaml:"range"`
+	Entries        int                      `yaml:"entries"`
+	Hits           int                      `yaml:"-"`
+	RangeLabel     string                   `yaml:"-"`
+	SvgChartPoints string                   `yaml:"-"`
+	RecentEntries  []logLine                `yaml:"-"`
+}
+
+type elasticsearchConnection struct {
+	URL           string `yaml:"url"`
+	Index         string `yaml:"index"`
+	Query         string `yaml:"query"`
+	Username      string `yaml:"username"`
+	Password      string `yaml:"password"`
+	APIKey        string `yaml:"api-key"`
+	TimeField     string `yaml:"time-field"`
+	MessageField  string `yaml:"message-field"`
+	LevelField    string `yaml:"level-field"`
+	AllowInsecure bool   `yaml:"allow-insecure"`
+}
+
+func (widget *logQueryWidget) initialize() error {
+	widget.withTitle("Logs").withCacheDuration(5 * time.Minute)
+
+	if (widget.Loki == nil) == (widget.Elasticsearch == nil) {
+		return errors.New("exactly one of loki or elasticsearch is required")
+	}
+
+	if widget.Loki != nil {
+		if err := widget.Loki.initialize(); err != nil {
+			return fmt.Errorf("loki: %v", err)
+		}
+
+		if widget.Loki.Since != 0 {
+			return errors.New("loki: since can't be used here, set the range of the widget instead")
+		}
+	}
+
+	if widget.Elasticsearch != nil {
+		if err := widget.Elasticsearch.initialize(); err != nil {
+			return fmt.Errorf("elasticsearch: %v", err)
+		}
+	}
+
+	if widget.Range == 0 {
+		widget.Range = durationField(24 * time.Hour)
+	} else if widget.Range < durationField(time.Minute) {
+		return errors.New("range must be at least 1m")
+	}
+
+	widget.RangeLabel = formatLogQueryRange(time.Duration(widget.Range))
+
+	if widget.Entries == 0 {
+		widget.Entries = 5
+	} else if widget.Entries > logTailMaxLines {
+		return fmt.Errorf("entries can't be more than %d", logTailMaxLines)
+	}
+
+	return nil
+}
+
+func formatLogQueryRange(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour && d%(24*time.Hour) == 0:
+		return strconv.Itoa(int(d/(24*time.Hour))) + "d"
+	case d%time.Hour == 0:
+		return strconv.Itoa(int(d/time.Hour)) + "h"
+	}
+
+	return strconv.Itoa(int(d/time.Minute)) + "m"
+}
+
+func (widget *logQueryWidget) update(ctx context.Context) {
+	var counts []float64
+	var entries []logLine
+	var err error
+
+	// the last point of the chart is for the step that ends now
+	now := time.Now()
+	step := (time.Duration(widget.Range) / logQueryChartPoints).Truncate(time.Second)
+	limit := max(widget.Entries, 0)
+
+	if widget.Loki != nil {
+		counts, entries, err = widget.queryLoki(ctx, now, step, limit)
+	} else {
+		client := widget.httpClient(widget.Elasticsearch.AllowInsecure)
+		counts, entries, err = widget.Elasticsearch.search(ctx, client, now, time.Duration(widget.Range), step, limit)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	hits := 0.0
+	for _, count := range counts {
+		hits += count
+	}
+
+	for i := range entries {
+		entries[i].Text, _ = limitStringLength(entries[i].Text, logTailMaxLineLength)
+
+		if entries[i].Level == "" {
+			entries[i].Level = detectLogLevel(entries[i].Text)
+		}
+	}
+
+	widget.Hits = int(hits)
+	widget.SvgChartPoints = svgPolylineCoordsFromYValues(100, 50, counts)
+	widget.RecentEntries = entries
+}
+
+func (widget *logQueryWidget) queryLoki(ctx context.Context, now time.Time, step time.Duration, limit int) ([]float64, []logLine, error) {
+	loki := widget.Loki
+	client := widget.httpClient(loki.AllowInsecure)
+	start := now.Add(-step * (logQueryChartPoints - 1))
+
+	counts, err := loki.queryCounts(ctx, client, loki.Query, start, now, step)
+	if err != nil {
+		return nil, nil, fmt.Errorf("counting lines: %v", err)
+	}
+
+	if limit == 0 {
+		return counts, nil, nil
+	}
+
+	entries, err := loki.queryLines(ctx, client, loki.Query, limit, now.Add(-time.Duration(widget.Range)), now)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting lines: %v", err)
+	}
+
+	return counts, entries, nil
+}
+
+func (widget *logQueryWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, logQueryWidgetTemplate)
+}
+
+func (es *elasticsearchConnection) initialize() error {
+	if es.URL == "" || es.Index == "" {
+		return errors.New("url and index are required")
+	}
+
+	es.URL = strings.TrimRight(es.URL, "/")
+
+	if es.TimeField == "" {
+		es.TimeField = "@timestamp"
+	}
+
+	if es.MessageField == "" {
+		es.MessageField = "message"
+	}
+
+	if es.LevelField == "" {
+		es.LevelField = "log.level"
+	}
+
+	return nil
+}
+
+// Gets the total number of hits, the number of hits within each step and the
+// latest documents in a single search
+func (es *elasticsearchConnection) search(
+	ctx context.Context,
+	client requestDoer,
+	now time.Time,
+	period time.Duration,
+	step time.Duration,
+	limit int,
+) ([]float64, []logLine, error) {
+	start := now.Add(-period)
+
+	var query any = map[string]any{"match_all": map[string]any{}}
+	if es.Query != "" {
+		query = map[string]any{"query_string": map[string]any{"query": es.Query}}
+	}
+
+	body, _ := json.Marshal(map[string]any{
+		"size":             limit,
+		"track_total_hits": true,
+		"sort":             []any{map[string]any{es.TimeField: "desc"}},
+		"query": map[string]any{
+			"bool": map[string]any{
+				"must": query,
+				"filter": map[string]any{
+					"range": map[string]any{
+						es.TimeField: map[string]any{
+							"gte":    start.UnixMilli(),
+							"lte":    now.UnixMilli(),
+							"format": "epoch_millis",
+						},
+					},
+				},
+			},
+		},
+		"aggs": map[string]any{
+			"over_time": map[string]any{
+				"date_histogram": map[string]any{
+					"field":          es.TimeField,
+					"fixed_interval": strconv.FormatInt(int64(step.Seconds()), 10) + "s",
+					"min_doc_count":  0,
+					"extended_bounds": map[string]any{
+						"min": start.UnixMilli(),
+						"max": now.UnixMilli(),
+					},
+				},
+			},
+		},
+	})
+
+	request, _ := http.NewRequestWithContext(ctx, "POST", es.URL+"/"+url.PathEscape(es.Index)+"/_search", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	if es.APIKey != "" {
+		request.Header.Set("Authorization", "ApiKey "+es.APIKey)
+	} else if es.Username != "" {
+		request.SetBasicAuth(es.Username, es.Password)
+	}
+
+	response, err := decodeJsonFromRequest[json.RawMessage](client, request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := gjson.ParseBytes(response)
+
+	buckets := result.Get("aggregations.over_time.buckets").Array()
+	counts := make([]float64, 0, len(buckets))
+	for _, bucket := range buckets {
+		counts = append(counts, bucket.Get("doc_count").Float())
+	}
+
+	// the first and last buckets are only partially within the range, which
+	// makes the total more accurate than adding up the buckets
+	total := result.Get("hits.total.value")
+	if !total.Exists() {
+		total = result.Get("hits.total")
+	}
+
+	if len(counts) > 0 {
+		counts = scaleCountsToTotal(counts, total.Float())
+	}
+
+	hits := result.Get("hits.hits").Array()
+	entries := make([]logLine, 0, len(hits))
+
+	for _, hit := range hits {
+		source := hit.Get("_source")
+
+		entries = append(entries, logLine{
+			Time:  parseElasticsearchTime(es.sourceField(source, es.TimeField)),
+			Text:  es.sourceField(source, es.MessageField).String(),
+			Level: normalizeLogLevel(es.sourceField(source, es.LevelField).String()),
+		})
+	}
+
+	return counts, entries, nil
+}
+
+// Fields can either be nested objects or have dots in their names
+func (es *elasticsearchConnection) sourceField(source gjson.Result, field string) gjson.Result {
+	if value := source.Get(field); value.Exists() {
+		return value
+	}
+
+	return source.Get(strings.ReplaceAll(field, ".", `\.`))
+}
+
+func parseElasticsearchTime(value gjson.Result) time.Time {
+	if value.Type == gjson.Number {
+		return time.UnixMilli(value.Int())
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, value.String())
+	if err != nil {
+		return time.Time{}
+	}
+
+	return parsed
+}
+
+// The number of hits shown is taken from the counts, so they're adjusted to
+// add up to the total while keeping the shape of the chart
+func scaleCountsToTotal(counts []float64, total float64) []float64 {
+	sum := 0.0
+	for _, count := range counts {
+		sum += count
+	}
+
+	if sum == 0 || sum == total {
+		return counts
+	}
+
+	scaled := make([]float64, len(counts))
+	for i := range counts {
+		scaled[i] = counts[i] * total / sum
+	}
+
+	return scaled
+}
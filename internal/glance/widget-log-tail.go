@@ -0,0 +1,361 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var logTailWidgetTemplate = mustParseTemplate("log-tail.html", "widget-base.html")
+
+const (
+	// only the end of the file is read, lines further back than this aren't shown
+	logTailMaxFileBytes  = 1024 * 1024
+	logTailMaxLineLength = 1000
+	logTailMaxLines      = 100
+)
+
+var logTailLevelPattern = regexp.MustCompile(`(?i)\b(fatal|panic|emerg|alert|crit|critical|error|err|warning|warn|info|notice|debug|trace)\b`)
+
+type logTailWidget struct {
+	widgetBase      `yaml:",inline"`
+	File            string           `yaml:"file"`
+	Loki            *lokiConnection  `yaml:"loki"`
+	Journald        *logTailJournald `yaml:"journald"`
+	Filter          string           `yaml:"filter"`
+	Lines           int              `yaml:"lines"`
+	RefreshInterval durationField    `yaml:"refresh-interval"`
+	filter          *regexp.Regexp
+
+	// lines can also be fetched by the browser polling for new ones, outside
+	// of the regular updates of the widget
+	mu        sync.Mutex
+	entries   []logLine
+	fetchedAt time.Time
+}
+
+type logTailJournald struct {
+	Units       []string `yaml:"units"`
+	Identifiers []string `yaml:"identifiers"`
+}
+
+// Shared with the log query widget
+type logLine struct {
+	Time  time.Time
+	Text  string
+	Level string
+}
+
+func (widget *logTailWidget) initialize() error {
+	widget.withTitle("Logs").withCacheDuration(time.Minute)
+
+	sources := 0
+	for _, set := range []bool{widget.File != "", widget.Loki != nil, widget.Journald != nil} {
+		if set {
+			sources++
+		}
+	}
+
+	if sources != 1 {
+		return errors.New("exactly one of file, loki or journald is required")
+	}
+
+	if widget.Loki != nil {
+		if err := widget.Loki.initialize(); err != nil {
+			return fmt.Errorf("loki: %v", err)
+		}
+
+		if widget.Loki.Since <= 0 {
+			widget.Loki.Since = durationField(time.Hour)
+		}
+	}
+
+	if widget.Filter != "" {
+		var err error
+		if widget.filter, err = regexp.Compile(widget.Filter); err != nil {
+			return fmt.Errorf("invalid filter: %v", err)
+		}
+	}
+
+	if widget.Lines <= 0 {
+		widget.Lines = 20
+	} else if widget.Lines > logTailMaxLines {
+		return fmt.Errorf("lines can't be more than %d", logTailMaxLines)
+	}
+
+	if widget.RefreshInterval == 0 {
+		widget.RefreshInterval = durationField(10 * time.Second)
+	} else if widget.RefreshInterval < durationField(2*time.Second) {
+		return errors.New("refresh-interval must be at least 2s")
+	}
+
+	return nil
+}
+
+func (widget *logTailWidget) getCommands() [][]string {
+	if widget.Journald == nil {
+		return nil
+	}
+
+	return [][]string{{"journalctl"}}
+}
+
+func (widget *logTailWidget) update(ctx context.Context) {
+	lines, err := widget.fetchLines(ctx)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.setLines(lines)
+}
+
+func (widget *logTailWidget) setLines(lines []logLine) {
+	widget.mu.Lock()
+	defer widget.mu.Unlock()
+
+	widget.entries = lines
+	widget.fetchedAt = time.Now()
+}
+
+// The newest line comes first
+func (widget *logTailWidget) fetchLines(ctx context.Context) ([]logLine, error) {
+	var lines []logLine
+	var err error
+
+	switch {
+	case widget.Loki != nil:
+		lines, err = widget.fetchLokiLines(ctx)
+	case widget.Journald != nil:
+		lines, err = widget.fetchJournaldLines(ctx)
+	default:
+		lines, err = widget.readFileLines()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	for i := range lines {
+		lines[i].Text, _ = limitStringLength(lines[i].Text, logTailMaxLineLength)
+
+		if lines[i].Level == "" {
+			lines[i].Level = detectLogLevel(lines[i].Text)
+		}
+	}
+
+	return lines, nil
+}
+
+func (widget *logTailWidget) readFileLines() ([]logLine, error) {
+	file, err := os.Open(widget.File)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offset := max(0, info.Size()-logTailMaxFileBytes)
+	contents, err := io.ReadAll(io.NewSectionReader(file, offset, info.Size()-offset))
+	if err != nil {
+		return nil, err
+	}
+
+	// the first line is likely only partially read
+	if offset > 0 {
+		if i := bytes.IndexByte(contents, '\n'); i >= 0 {
+			contents = contents[i+1:]
+		}
+	}
+
+	split := strings.Split(strings.TrimRight(string(contents), "\r\n"), "\n")
+	lines := make([]logLine, 0, widget.Lines)
+
+	for i := len(split) - 1; i >= 0 && len(lines) < widget.Lines; i-- {
+		text := strings.TrimRight(split[i], "\r")
+		if text == "" || widget.filter != nil && !widget.filter.MatchString(text) {
+			continue
+		}
+
+		lines = append(lines, logLine{Text: text})
+	}
+
+	return lines, nil
+}
+
+func (widget *logTailWidget) fetchLokiLines(ctx context.Context) ([]logLine, error) {
+	query := widget.Loki.Query
+
+	// filtered by Loki so that the limit applies to matching lines only
+	if widget.Filter != "" {
+		query += " |~ " + strconv.Quote(widget.Filter)
+	}
+
+	now := time.Now()
+	client := widget.httpClient(widget.Loki.AllowInsecure)
+
+	return widget.Loki.queryLines(ctx, client, query, widget.Lines, now.Add(-time.Duration(widget.Loki.Since)), now)
+}
+
+func (widget *logTailWidget) fetchJournaldLines(ctx context.Context) ([]logLine, error) {
+	command := []string{
+		"journalctl", "--no-pager", "--quiet",
+		"--output=json", "--output-fields=MESSAGE,PRIORITY",
+		"--lines=" + strconv.Itoa(widget.Lines),
+	}
+
+	for _, unit := range widget.Journald.Units {
+		command = append(command, "--unit="+unit)
+	}
+
+	for _, identifier := range widget.Journald.Identifiers {
+		command = append(command, "--identifier="+identifier)
+	}
+
+	// filtered by journalctl so that the number of lines applies to matching lines only
+	if widget.Filter != "" {
+		command = append(command, "--grep="+widget.Filter)
+	}
+
+	output, err := runWidgetCommand(ctx, command, 10*time.Second, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	split := strings.Split(output, "\n")
+	lines := make([]logLine, 0, len(split))
+
+	for i := len(split) - 1; i >= 0; i-- {
+		if split[i] == "" {
+			continue
+		}
+
+		var entry journaldEntryJson
+		if err := json.Unmarshal([]byte(split[i]), &entry); err != nil {
+			continue
+		}
+
+		// messages that aren't valid UTF-8 are encoded as an array of bytes
+		var message string
+		if err := json.Unmarshal(entry.Message, &message); err != nil {
+			continue
+		}
+
+		microseconds, _ := strconv.ParseInt(entry.RealtimeTimestamp, 10, 64)
+
+		lines = append(lines, logLine{
+			Time:  time.UnixMicro(microseconds),
+			Text:  message,
+			Level: journaldPriorityToLevel(entry.Priority),
+		})
+	}
+
+	return lines, nil
+}
+
+type journaldEntryJson struct {
+	Message           json.RawMessage `json:"MESSAGE"`
+	Priority          string          `json:"PRIORITY"`
+	RealtimeTimestamp string          `json:"__REALTIME_TIMESTAMP"`
+}
+
+func journaldPriorityToLevel(priority string) string {
+	switch priority {
+	case "0", "1", "2", "3":
+		return "error"
+	case "4":
+		return "warning"
+	case "5", "6":
+		return "info"
+	case "7":
+		return "debug"
+	}
+
+	return ""
+}
+
+// Uses the first word in the line that looks like a level, such as ERROR or
+// level=warn
+func detectLogLevel(text string) string {
+	return normalizeLogLevel(logTailLevelPattern.FindString(text))
+}
+
+func normalizeLogLevel(level string) string {
+	switch strings.ToLower(level) {
+	case "fatal", "panic", "emerg", "alert", "crit", "critical", "error", "err":
+		return "error"
+	case "warning", "warn":
+		return "warning"
+	case "info", "notice":
+		return "info"
+	case "debug", "trace":
+		return "debug"
+	}
+
+	return ""
+}
+
+func (widget *logTailWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || r.PathValue("path") != "lines" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	widget.mu.Lock()
+	outdated := time.Since(widget.fetchedAt) >= time.Duration(widget.RefreshInterval)/2
+	widget.mu.Unlock()
+
+	// any number of open pages only cause a fetch every so often
+	if outdated {
+		lines, err := widget.fetchLines(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		widget.setLines(lines)
+	}
+
+	widget.mu.Lock()
+	defer widget.mu.Unlock()
+
+	var html bytes.Buffer
+	if err := logTailWidgetTemplate.ExecuteTemplate(&html, "lines", widget); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(html.Bytes())
+}
+
+func (widget *logTailWidget) RefreshIntervalMilliseconds() int64 {
+	return time.Duration(widget.RefreshInterval).Milliseconds()
+}
+
+func (widget *logTailWidget) Entries() []logLine {
+	return widget.entries
+}
+
+func (widget *logTailWidget) Render() template.HTML {
+	widget.mu.Lock()
+	defer widget.mu.Unlock()
+
+	return widget.renderTemplate(widget, logTailWidgetTemplate)
+}
@@ -0,0 +1,322 @@
+package glance
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var mailServerWidgetTemplate = mustParseTemplate("mail-server.html", "widget-base.html")
+
+type mailServerWidget struct {
+	widgetBase        `yaml:",inline"`
+	QueueDirectory    string          `yaml:"queue-directory"`
+	LogFile           string          `yaml:"log-file"`
+	RejectsPeriod     durationField   `yaml:"rejects-period"`
+	DMARCReportFile   string          `yaml:"dmarc-report-file"`
+	DMARCReportersMax int             `yaml:"dmarc-reporters-limit"`
+	Stats             mailServerStats `yaml:"-"`
+}
+
+func (widget *mailServerWidget) initialize() error {
+	widget.withTitle("Mail Server").withCacheDuration(5 * time.Minute)
+
+	if widget.QueueDirectory == "" && widget.LogFile == "" && widget.DMARCReportFile == "" {
+		return errors.New("at least one of queue-directory, log-file or dmarc-report-file must be specified")
+	}
+
+	if widget.RejectsPeriod == 0 {
+		widget.RejectsPeriod = durationField(24 * time.Hour)
+	}
+
+	if widget.DMARCReportersMax <= 0 {
+		widget.DMARCReportersMax = 5
+	}
+
+	return nil
+}
+
+func (widget *mailServerWidget) update(ctx context.Context) {
+	stats := mailServerStats{}
+	var failed []string
+
+	if widget.QueueDirectory != "" {
+		queue, err := countPostfixQueue(widget.QueueDirectory)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("queue: %v", err))
+		} else {
+			stats.Queue = queue
+			stats.QueueIsAvailable = true
+		}
+	}
+
+	if widget.LogFile != "" {
+		rejects, err := countPostfixRejects(widget.LogFile, time.Duration(widget.RejectsPeriod))
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("rejects: %v", err))
+		} else {
+			stats.Rejects = rejects
+			stats.RejectsIsAvailable = true
+		}
+	}
+
+	if widget.DMARCReportFile != "" {
+		dmarc, err := summarizeParsedmarcReports(widget.DMARCReportFile, widget.DMARCReportersMax)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("dmarc: %v", err))
+		} else {
+			stats.DMARC = dmarc
+			stats.DMARCIsAvailable = true
+		}
+	}
+
+	var err error
+
+	if len(failed) > 0 {
+		if stats.QueueIsAvailable || stats.RejectsIsAvailable || stats.DMARCIsAvailable {
+			err = fmt.Errorf("%w: %s", errPartialContent, strings.Join(failed, "; "))
+		} else {
+			err = fmt.Errorf("%w: %s", errNoContent, strings.Join(failed, "; "))
+		}
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Stats = stats
+}
+
+func (widget *mailServerWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, mailServerWidgetTemplate)
+}
+
+type mailServerStats struct {
+	Queue              postfixQueueStats
+	QueueIsAvailable   bool
+	Rejects            int
+	RejectsIsAvailable bool
+	DMARC              dmarcSummary
+	DMARCIsAvailable   bool
+}
+
+type postfixQueueStats struct {
+	Active   int
+	Deferred int
+	Hold     int
+	Incoming int
+}
+
+func (s postfixQueueStats) Total() int {
+	return s.Active + s.Deferred + s.Hold + s.Incoming
+}
+
+// Counting the files in the queue directories is what `qshape` and friends do
+// as well and doesn't require access to the postfix binaries, so it works even
+// when the spool is only mounted into the container glance is running in
+func countPostfixQueue(queueDirectory string) (postfixQueueStats, error) {
+	stats := postfixQueueStats{}
+	queues := map[string]*int{
+		"active":   &stats.Active,
+		"deferred": &stats.Deferred,
+		"hold":     &stats.Hold,
+		"incoming": &stats.Incoming,
+	}
+
+	for name, count := range queues {
+		err := filepath.WalkDir(filepath.Join(queueDirectory, name), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !d.IsDir() {
+				*count++
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// only read the end of the log file, it can get quite large and we only care about recent entries
+const mailLogTailBytes = 8 * 1024 * 1024
+
+func countPostfixRejects(logFile string, period time.Duration) (int, error) {
+	file, err := os.Open(logFile)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if stat.Size() > mailLogTailBytes {
+		if _, err := file.Seek(-mailLogTailBytes, io.SeekEnd); err != nil {
+			return 0, err
+		}
+	}
+
+	now := time.Now()
+	since := now.Add(-period)
+	count := 0
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		if !bytes.Contains(line, []byte("postfix/")) || !bytes.Contains(line, []byte(": reject: ")) {
+			continue
+		}
+
+		loggedAt, ok := parseSyslogLineTime(string(line), now)
+		if ok && loggedAt.After(since) {
+			count++
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func parseSyslogLineTime(line string, now time.Time) (time.Time, bool) {
+	// RFC 3339 timestamps as written by rsyslog's high precision format
+	if timestamp, _, found := strings.Cut(line, " "); found {
+		if parsed, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+			return parsed, true
+		}
+	}
+
+	// traditional syslog timestamps, e.g. "Oct  5 07:36:01", which have no year
+	if len(line) < len(time.Stamp) {
+		return time.Time{}, false
+	}
+
+	parsed, err := time.ParseInLocation(time.Stamp, line[:len(time.Stamp)], now.Location())
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	parsed = parsed.AddDate(now.Year(), 0, 0)
+	if parsed.After(now.Add(24 * time.Hour)) {
+		parsed = parsed.AddDate(-1, 0, 0)
+	}
+
+	return parsed, true
+}
+
+type dmarcSummary struct {
+	Messages       int
+	Passed         int
+	Failed         int
+	PassedPercent  int
+	LatestReportAt time.Time
+	Reporters      []dmarcReporter
+}
+
+type dmarcReporter struct {
+	Name     string
+	Messages int
+	Failed   int
+}
+
+type parsedmarcAggregateReportJson struct {
+	ReportMetadata struct {
+		OrgName string `json:"org_name"`
+		EndDate string `json:"end_date"`
+	} `json:"report_metadata"`
+	Records []struct {
+		Count           int `json:"count"`
+		PolicyEvaluated struct {
+			DKIM string `json:"dkim"`
+			SPF  string `json:"spf"`
+		} `json:"policy_evaluated"`
+	} `json:"records"`
+}
+
+// Reads the aggregate.json file that parsedmarc writes when run with an output directory
+func summarizeParsedmarcReports(reportFile string, maxReporters int) (dmarcSummary, error) {
+	contents, err := os.ReadFile(reportFile)
+	if err != nil {
+		return dmarcSummary{}, err
+	}
+
+	var reports []parsedmarcAggregateReportJson
+	if err := json.Unmarshal(contents, &reports); err != nil {
+		return dmarcSummary{}, fmt.Errorf("parsing report file: %v", err)
+	}
+
+	summary := dmarcSummary{}
+	reporters := make(map[string]*dmarcReporter)
+
+	for i := range reports {
+		report := &reports[i]
+		name := report.ReportMetadata.OrgName
+
+		reporter, exists := reporters[name]
+		if !exists {
+			reporter = &dmarcReporter{Name: name}
+			reporters[name] = reporter
+		}
+
+		if endDate, err := time.Parse(time.DateTime, report.ReportMetadata.EndDate); err == nil {
+			if endDate.After(summary.LatestReportAt) {
+				summary.LatestReportAt = endDate
+			}
+		}
+
+		for j := range report.Records {
+			record := &report.Records[j]
+			summary.Messages += record.Count
+			reporter.Messages += record.Count
+
+			if record.PolicyEvaluated.DKIM == "pass" || record.PolicyEvaluated.SPF == "pass" {
+				summary.Passed += record.Count
+			} else {
+				summary.Failed += record.Count
+				reporter.Failed += record.Count
+			}
+		}
+	}
+
+	if summary.Messages > 0 {
+		summary.PassedPercent = summary.Passed * 100 / summary.Messages
+	}
+
+	summary.Reporters = make([]dmarcReporter, 0, len(reporters))
+	for _, reporter := range reporters {
+		summary.Reporters = append(summary.Reporters, *reporter)
+	}
+
+	sort.Slice(summary.Reporters, func(i, j int) bool {
+		return summary.Reporters[i].Messages > summary.Reporters[j].Messages
+	})
+
+	if len(summary.Reporters) > maxReporters {
+		summary.Reporters = summary.Reporters[:maxReporters]
+	}
+
+	return summary, nil
+}
@@ -48,7 +48,7 @@ func (widget *marketsWidget) initialize() error {
 }
 
 func (widget *marketsWidget) update(ctx context.Context) {
-	markets, err := fetchMarketsDataFromYahoo(widget.MarketRequests)
+	markets, err := fetchMarketsDataFromYahoo(widget.cachedHTTPClient(false), widget.MarketRequests)
 
 	if !widget.canContinueUpdateAfterHandlingErr(err) {
 		return
@@ -121,7 +121,7 @@ type marketResponseJson struct {
 // TODO: allow changing chart time frame
 const marketChartDays = 21
 
-func fetchMarketsDataFromYahoo(marketRequests []marketRequest) (marketList, error) {
+func fetchMarketsDataFromYahoo(client requestDoer, marketRequests []marketRequest) (marketList, error) {
 	requests := make([]*http.Request, 0, len(marketRequests))
 
 	for i := range marketRequests {
@@ -130,7 +130,7 @@ func fetchMarketsDataFromYahoo(marketRequests []marketRequest) (marketList, erro
 		requests = append(requests, request)
 	}
 
-	job := newJob(decodeJsonFromRequestTask[marketResponseJson](defaultHTTPClient), requests)
+	job := newJob(decodeJsonFromRequestTask[marketResponseJson](client), requests)
 	responses, errs, err := workerPoolDo(job)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", errNoContent, err)
@@ -226,3 +226,28 @@ var currencyToSymbol = map[string]string{
 	"PLN": "zł",
 	"PHP": "₱",
 }
+
+type marketJson struct {
+	Symbol        string  `json:"symbol"`
+	Name          string  `json:"name"`
+	Currency      string  `json:"currency"`
+	Price         float64 `json:"price"`
+	PercentChange float64 `json:"percent_change"`
+}
+
+func (widget *marketsWidget) data() any {
+	markets := make([]marketJson, 0, len(widget.Markets))
+
+	for i := range widget.Markets {
+		market := &widget.Markets[i]
+		markets = append(markets, marketJson{
+			Symbol:        market.Symbol,
+			Name:          market.Name,
+			Currency:      market.Currency,
+			Price:         market.Price,
+			PercentChange: market.PercentChange,
+		})
+	}
+
+	return markets
+}
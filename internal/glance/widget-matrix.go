@@ -0,0 +1,256 @@
+package glance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+var matrixWidgetTemplate = mustParseTemplate("matrix.html", "widget-base.html")
+
+type matrixWidget struct {
+	widgetBase    `yaml:",inline"`
+	HomeserverURL string              `yaml:"homeserver-url"`
+	AccessToken   string              `yaml:"access-token"`
+	RoomRequests  []matrixRoomRequest `yaml:"rooms"`
+	MessagesLimit int                 `yaml:"messages-limit"`
+	CollapseAfter int                 `yaml:"collapse-after"`
+	Rooms         []matrixRoom        `yaml:"-"`
+}
+
+type matrixRoomRequest struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+}
+
+func (widget *matrixWidget) initialize() error {
+	widget.withTitle("Matrix").withCacheDuration(5 * time.Minute)
+
+	if widget.HomeserverURL == "" {
+		return errors.New("homeserver-url is required")
+	}
+
+	if widget.AccessToken == "" {
+		return errors.New("access-token is required")
+	}
+
+	widget.HomeserverURL = strings.TrimRight(widget.HomeserverURL, "/")
+
+	if widget.MessagesLimit <= 0 {
+		widget.MessagesLimit = 3
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *matrixWidget) update(ctx context.Context) {
+	// initial syncs can take a while on accounts with lots of rooms
+	var client requestDoer = matrixSyncHTTPClient
+	if widget.HTTPOptions != nil {
+		client = widget.httpClient(false)
+	}
+
+	rooms, err := fetchMatrixRooms(client, widget.HomeserverURL, widget.AccessToken, widget.RoomRequests, widget.MessagesLimit)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Rooms = rooms
+}
+
+func (widget *matrixWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, matrixWidgetTemplate)
+}
+
+type matrixRoom struct {
+	ID         string
+	Name       string
+	URL        string
+	Unread     int
+	Highlights int
+	Messages   []matrixMessage
+}
+
+type matrixMessage struct {
+	Sender string
+	Body   string
+	SentAt time.Time
+}
+
+type matrixEventJson struct {
+	Type           string          `json:"type"`
+	Sender         string          `json:"sender"`
+	StateKey       *string         `json:"state_key"`
+	OriginServerTS int64           `json:"origin_server_ts"`
+	Content        json.RawMessage `json:"content"`
+}
+
+type matrixSyncResponseJson struct {
+	Rooms struct {
+		Join map[string]struct {
+			UnreadNotifications struct {
+				HighlightCount    int `json:"highlight_count"`
+				NotificationCount int `json:"notification_count"`
+			} `json:"unread_notifications"`
+			State struct {
+				Events []matrixEventJson `json:"events"`
+			} `json:"state"`
+			Timeline struct {
+				Events []matrixEventJson `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+var matrixSyncHTTPClient = &http.Client{Timeout: 30 * time.Second, Transport: newOutboundTransport(false)}
+
+func fetchMatrixRooms(client requestDoer, homeserverURL string, token string, roomRequests []matrixRoomRequest, messagesLimit int) ([]matrixRoom, error) {
+	// a single filtered initial sync returns everything we need, the unread counts
+	// for every joined room along with the names and the latest messages
+	filter := map[string]any{
+		"presence":     map[string]any{"types": []string{}},
+		"account_data": map[string]any{"types": []string{}},
+		"room": map[string]any{
+			"account_data": map[string]any{"types": []string{}},
+			"ephemeral":    map[string]any{"types": []string{}},
+			"state": map[string]any{
+				"types":             []string{"m.room.name", "m.room.canonical_alias", "m.room.member"},
+				"lazy_load_members": true,
+			},
+			"timeline": map[string]any{
+				"types": []string{"m.room.message"},
+				"limit": messagesLimit,
+			},
+		},
+	}
+
+	encodedFilter, _ := json.Marshal(filter)
+	request, _ := http.NewRequest(
+		"GET",
+		homeserverURL+"/_matrix/client/v3/sync?timeout=0&filter="+url.QueryEscape(string(encodedFilter)),
+		nil,
+	)
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	response, err := decodeJsonFromRequest[matrixSyncResponseJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]string, len(roomRequests))
+	for _, r := range roomRequests {
+		selected[r.ID] = r.Name
+	}
+
+	rooms := make([]matrixRoom, 0, len(response.Rooms.Join))
+
+	for id, joined := range response.Rooms.Join {
+		customName, isSelected := selected[id]
+
+		// rooms other than the selected ones are only shown when they have something unread
+		if !isSelected && joined.UnreadNotifications.NotificationCount == 0 {
+			continue
+		}
+
+		room := matrixRoom{
+			ID:         id,
+			URL:        "https://matrix.to/#/" + id,
+			Unread:     joined.UnreadNotifications.NotificationCount,
+			Highlights: joined.UnreadNotifications.HighlightCount,
+		}
+
+		displayNames := make(map[string]string)
+		var alias string
+
+		for _, event := range joined.State.Events {
+			switch event.Type {
+			case "m.room.name":
+				var content struct {
+					Name string `json:"name"`
+				}
+				json.Unmarshal(event.Content, &content)
+				room.Name = content.Name
+			case "m.room.canonical_alias":
+				var content struct {
+					Alias string `json:"alias"`
+				}
+				json.Unmarshal(event.Content, &content)
+				alias = content.Alias
+			case "m.room.member":
+				var content struct {
+					DisplayName string `json:"displayname"`
+				}
+				json.Unmarshal(event.Content, &content)
+				if event.StateKey != nil && content.DisplayName != "" {
+					displayNames[*event.StateKey] = content.DisplayName
+				}
+			}
+		}
+
+		if customName != "" {
+			room.Name = customName
+		} else if room.Name == "" {
+			room.Name = ternary(alias != "", alias, id)
+		}
+
+		if isSelected {
+			for i := len(joined.Timeline.Events) - 1; i >= 0; i-- {
+				event := joined.Timeline.Events[i]
+
+				var content struct {
+					Body string `json:"body"`
+				}
+				json.Unmarshal(event.Content, &content)
+				if content.Body == "" {
+					continue
+				}
+
+				sender, exists := displayNames[event.Sender]
+				if !exists {
+					sender = event.Sender
+				}
+
+				room.Messages = append(room.Messages, matrixMessage{
+					Sender: sender,
+					Body:   content.Body,
+					SentAt: time.UnixMilli(event.OriginServerTS),
+				})
+			}
+		}
+
+		rooms = append(rooms, room)
+	}
+
+	order := make(map[string]int, len(roomRequests))
+	for i, r := range roomRequests {
+		order[r.ID] = i + 1
+	}
+
+	// selected rooms first in the order they were defined, then the rest by unread count
+	sort.Slice(rooms, func(i, j int) bool {
+		oi, oj := order[rooms[i].ID], order[rooms[j].ID]
+
+		if oi != 0 || oj != 0 {
+			return oj == 0 || (oi != 0 && oi < oj)
+		}
+
+		if rooms[i].Unread != rooms[j].Unread {
+			return rooms[i].Unread > rooms[j].Unread
+		}
+
+		return rooms[i].Name < rooms[j].Name
+	})
+
+	return rooms, nil
+}
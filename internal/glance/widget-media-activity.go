@@ -0,0 +1,340 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var mediaActivityWidgetTemplate = mustParseTemplate("media-activity.html", "widget-base.html")
+
+var mediaActivityPosterPattern = regexp.MustCompile(`<img[^>]+src="([^"]+)"`)
+
+type mediaActivityWidget struct {
+	widgetBase    `yaml:",inline"`
+	Feeds         []mediaActivityFeed  `yaml:"feeds"`
+	Limit         int                  `yaml:"limit"`
+	CollapseAfter int                  `yaml:"collapse-after"`
+	Entries       []mediaActivityEntry `yaml:"-"`
+	images        imageProxy           `yaml:"-"`
+}
+
+type mediaActivityFeed struct {
+	Service string `yaml:"service"`
+	User    string `yaml:"user"`
+	Name    string `yaml:"name"`
+	Shelf   string `yaml:"shelf"`
+}
+
+type mediaActivityEntry struct {
+	Title    string
+	Subtitle string
+	URL      string
+	ImageURL string
+	User     string
+	Verb     string
+	Rating   float64
+	Time     time.Time
+	imageURL string
+}
+
+// Returns the rating as stars, with halves for ratings such as 3.5
+func (e mediaActivityEntry) Stars() string {
+	if e.Rating <= 0 {
+		return ""
+	}
+
+	stars := strings.Repeat("★", int(e.Rating))
+	if e.Rating-float64(int(e.Rating)) >= 0.5 {
+		stars += "½"
+	}
+
+	return stars
+}
+
+func (widget *mediaActivityWidget) initialize() error {
+	widget.withTitle("Recent Activity").withCacheDuration(time.Hour)
+
+	if len(widget.Feeds) == 0 {
+		return errors.New("no feeds specified")
+	}
+
+	for i := range widget.Feeds {
+		feed := &widget.Feeds[i]
+
+		if feed.Service != "letterboxd" && feed.Service != "goodreads" {
+			return fmt.Errorf("feed %d: service must be either letterboxd or goodreads", i+1)
+		}
+
+		if feed.User == "" {
+			return fmt.Errorf("feed %d: user is required", i+1)
+		}
+
+		if feed.Name == "" {
+			feed.Name = feed.User
+		}
+
+		if feed.Service == "goodreads" && feed.Shelf == "" {
+			feed.Shelf = "currently-reading"
+		}
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 10
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *mediaActivityWidget) update(ctx context.Context) {
+	entries, err := fetchMediaActivity(widget.httpClient(false), widget.Feeds)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	// the name of the user is only useful to tell entries from different people apart
+	names := make(map[string]struct{})
+	for i := range widget.Feeds {
+		names[widget.Feeds[i].Name] = struct{}{}
+	}
+
+	if len(names) == 1 {
+		for i := range entries {
+			entries[i].User = ""
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+
+	if len(entries) > widget.Limit {
+		entries = entries[:widget.Limit]
+	}
+
+	images := make(imageProxySet)
+
+	for i := range entries {
+		entries[i].ImageURL = images.add(&widget.widgetBase, "", entries[i].imageURL)
+	}
+
+	widget.images.commit(images)
+	widget.Entries = entries
+}
+
+func (widget *mediaActivityWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || r.PathValue("path") != "image" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	widget.images.serve(w, r, widget.httpClient(false), setBrowserUserAgentHeader)
+}
+
+func (widget *mediaActivityWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, mediaActivityWidgetTemplate)
+}
+
+type letterboxdFeedXml struct {
+	Channel struct {
+		Items []struct {
+			Link         string `xml:"link"`
+			PubDate      string `xml:"pubDate"`
+			Description  string `xml:"description"`
+			FilmTitle    string `xml:"https://letterboxd.com filmTitle"`
+			FilmYear     int    `xml:"https://letterboxd.com filmYear"`
+			MemberRating string `xml:"https://letterboxd.com memberRating"`
+			Rewatch      string `xml:"https://letterboxd.com rewatch"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type goodreadsFeedXml struct {
+	Channel struct {
+		Items []struct {
+			Title         string `xml:"title"`
+			Link          string `xml:"link"`
+			PubDate       string `xml:"pubDate"`
+			AuthorName    string `xml:"author_name"`
+			BookImageURL  string `xml:"book_large_image_url"`
+			UserRating    string `xml:"user_rating"`
+			UserReadAt    string `xml:"user_read_at"`
+			UserDateAdded string `xml:"user_date_added"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+func parseFeedTime(value string) time.Time {
+	// days of the month aren't always zero padded
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (feed *mediaActivityFeed) fetch(client requestDoer) ([]mediaActivityEntry, error) {
+	if feed.Service == "letterboxd" {
+		return feed.fetchLetterboxd(client)
+	}
+
+	return feed.fetchGoodreads(client)
+}
+
+func (feed *mediaActivityFeed) fetchLetterboxd(client requestDoer) ([]mediaActivityEntry, error) {
+	request, err := http.NewRequest("GET", "https://letterboxd.com/"+url.PathEscape(feed.User)+"/rss/", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	setBrowserUserAgentHeader(request)
+
+	response, err := decodeXmlFromRequest[letterboxdFeedXml](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]mediaActivityEntry, 0, len(response.Channel.Items))
+
+	for i := range response.Channel.Items {
+		item := &response.Channel.Items[i]
+
+		// lists are also part of the feed
+		if item.FilmTitle == "" {
+			continue
+		}
+
+		entry := mediaActivityEntry{
+			Title: item.FilmTitle,
+			URL:   item.Link,
+			User:  feed.Name,
+			Verb:  ternary(item.Rewatch == "Yes", "Rewatched", "Watched"),
+			Time:  parseFeedTime(item.PubDate),
+		}
+
+		if item.FilmYear > 0 {
+			entry.Subtitle = strconv.Itoa(item.FilmYear)
+		}
+
+		if rating, err := strconv.ParseFloat(item.MemberRating, 64); err == nil {
+			entry.Rating = rating
+		}
+
+		if matches := mediaActivityPosterPattern.FindStringSubmatch(item.Description); matches != nil {
+			entry.imageURL = matches[1]
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (feed *mediaActivityFeed) fetchGoodreads(client requestDoer) ([]mediaActivityEntry, error) {
+	feedURL := "https://www.goodreads.com/review/list_rss/" + url.PathEscape(feed.User) + "?shelf=" + url.QueryEscape(feed.Shelf)
+
+	request, err := http.NewRequest("GET", feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	setBrowserUserAgentHeader(request)
+
+	response, err := decodeXmlFromRequest[goodreadsFeedXml](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	verb := "Shelved"
+	switch feed.Shelf {
+	case "currently-reading":
+		verb = "Reading"
+	case "read":
+		verb = "Read"
+	case "to-read":
+		verb = "Wants to read"
+	}
+
+	entries := make([]mediaActivityEntry, 0, len(response.Channel.Items))
+
+	for i := range response.Channel.Items {
+		item := &response.Channel.Items[i]
+
+		entry := mediaActivityEntry{
+			Title:    strings.TrimSpace(item.Title),
+			Subtitle: strings.TrimSpace(item.AuthorName),
+			URL:      strings.TrimSpace(item.Link),
+			User:     feed.Name,
+			Verb:     verb,
+			imageURL: strings.TrimSpace(item.BookImageURL),
+		}
+
+		if rating, err := strconv.Atoi(strings.TrimSpace(item.UserRating)); err == nil {
+			entry.Rating = float64(rating)
+		}
+
+		for _, value := range []string{item.UserReadAt, item.UserDateAdded, item.PubDate} {
+			if entry.Time = parseFeedTime(value); !entry.Time.IsZero() {
+				break
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func fetchMediaActivity(client requestDoer, feeds []mediaActivityFeed) ([]mediaActivityEntry, error) {
+	requests := make([]*mediaActivityFeed, len(feeds))
+	for i := range feeds {
+		requests[i] = &feeds[i]
+	}
+
+	job := newJob(func(feed *mediaActivityFeed) ([]mediaActivityEntry, error) {
+		return feed.fetch(client)
+	}, requests).withWorkers(len(requests))
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	var entries []mediaActivityEntry
+	var failed int
+
+	for i := range results {
+		if errs[i] != nil {
+			failed++
+			slog.Error("Failed to fetch activity feed", "service", feeds[i].Service, "user", feeds[i].User, "error", errs[i])
+			continue
+		}
+
+		entries = append(entries, results[i]...)
+	}
+
+	if failed == len(feeds) {
+		return nil, fmt.Errorf("%w: %v", errNoContent, errs[0])
+	}
+
+	if failed > 0 {
+		return entries, fmt.Errorf("%w: could not fetch %d feeds", errPartialContent, failed)
+	}
+
+	return entries, nil
+}
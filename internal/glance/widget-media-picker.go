@@ -0,0 +1,355 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var mediaPickerWidgetTemplate = mustParseTemplate("media-picker.html", "widget-base.html")
+
+type mediaPickerWidget struct {
+	widgetBase    `yaml:",inline"`
+	Service       string        `yaml:"service"`
+	URL           string        `yaml:"url"`
+	Token         string        `yaml:"token"`
+	User          string        `yaml:"user"`
+	AllowInsecure bool          `yaml:"allow-insecure"`
+	MediaType     string        `yaml:"media-type"`
+	Libraries     []string      `yaml:"libraries"`
+	Genres        []string      `yaml:"genres"`
+	MinDuration   durationField `yaml:"min-duration"`
+	MaxDuration   durationField `yaml:"max-duration"`
+	Count         int           `yaml:"count"`
+	Picks         []mediaPick   `yaml:"-"`
+
+	candidates      []mediaPick `yaml:"-"`
+	candidatesMutex sync.Mutex  `yaml:"-"`
+	images          imageProxy  `yaml:"-"`
+}
+
+type mediaPick struct {
+	Title     string
+	Year      int
+	Duration  time.Duration
+	Genres    []string
+	URL       string
+	ImageURL  string
+	imagePath string
+}
+
+func (p mediaPick) FormattedDuration() string {
+	return formatDuration(p.Duration)
+}
+
+func (widget *mediaPickerWidget) initialize() error {
+	widget.withTitle("What to watch").withCacheDuration(time.Hour)
+
+	if widget.Service != "jellyfin" && widget.Service != "plex" {
+		return errors.New("service must be either jellyfin or plex")
+	}
+
+	if widget.URL == "" {
+		return errors.New("url is required")
+	}
+
+	if widget.Token == "" {
+		return errors.New("token is required")
+	}
+
+	if widget.Service == "jellyfin" && widget.User == "" {
+		return errors.New("user is required when using jellyfin")
+	}
+
+	if widget.MediaType == "" {
+		widget.MediaType = "movies"
+	} else if widget.MediaType != "movies" && widget.MediaType != "shows" {
+		return errors.New("media-type must be either movies or shows")
+	}
+
+	if widget.MaxDuration > 0 && widget.MinDuration > widget.MaxDuration {
+		return errors.New("min-duration cannot be greater than max-duration")
+	}
+
+	widget.URL = strings.TrimRight(widget.URL, "/")
+
+	for i := range widget.Genres {
+		widget.Genres[i] = strings.ToLower(widget.Genres[i])
+	}
+
+	if widget.Count <= 0 {
+		widget.Count = 1
+	}
+
+	return nil
+}
+
+func (widget *mediaPickerWidget) update(ctx context.Context) {
+	client := widget.httpClient(widget.AllowInsecure)
+
+	var candidates []mediaPick
+	var err error
+
+	if widget.Service == "jellyfin" {
+		candidates, err = fetchJellyfinUnwatchedItems(client, widget.URL, widget.Token, widget.User, widget.MediaType)
+	} else {
+		candidates, err = fetchPlexUnwatchedItems(client, widget.URL, widget.Token, widget.MediaType, widget.Libraries)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	candidates = widget.filterCandidates(candidates)
+	images := make(imageProxySet)
+
+	for i := range candidates {
+		candidates[i].ImageURL = images.add(&widget.widgetBase, widget.URL, candidates[i].imagePath)
+	}
+
+	widget.images.commit(images)
+
+	widget.candidatesMutex.Lock()
+	widget.candidates = candidates
+	widget.candidatesMutex.Unlock()
+}
+
+func (widget *mediaPickerWidget) filterCandidates(candidates []mediaPick) []mediaPick {
+	filtered := make([]mediaPick, 0, len(candidates))
+
+	for i := range candidates {
+		candidate := &candidates[i]
+
+		if widget.MinDuration > 0 && candidate.Duration < time.Duration(widget.MinDuration) {
+			continue
+		}
+
+		if widget.MaxDuration > 0 && (candidate.Duration == 0 || candidate.Duration > time.Duration(widget.MaxDuration)) {
+			continue
+		}
+
+		if len(widget.Genres) > 0 && !slices.ContainsFunc(candidate.Genres, func(genre string) bool {
+			return slices.Contains(widget.Genres, strings.ToLower(genre))
+		}) {
+			continue
+		}
+
+		filtered = append(filtered, *candidate)
+	}
+
+	return filtered
+}
+
+func (widget *mediaPickerWidget) pick() []mediaPick {
+	widget.candidatesMutex.Lock()
+	defer widget.candidatesMutex.Unlock()
+
+	count := min(widget.Count, len(widget.candidates))
+	picks := make([]mediaPick, 0, count)
+
+	for _, i := range rand.Perm(len(widget.candidates))[:count] {
+		picks = append(picks, widget.candidates[i])
+	}
+
+	return picks
+}
+
+func (widget *mediaPickerWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	switch r.PathValue("path") {
+	case "image":
+		widget.images.serve(w, r, widget.httpClient(widget.AllowInsecure), widget.authorizeRequest)
+	case "roll":
+		var html bytes.Buffer
+
+		if err := mediaPickerWidgetTemplate.ExecuteTemplate(&html, "picks", widget.pick()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(html.Bytes())
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (widget *mediaPickerWidget) authorizeRequest(request *http.Request) {
+	setMediaServerToken(request, widget.Service, widget.Token)
+}
+
+func (widget *mediaPickerWidget) Render() template.HTML {
+	widget.Picks = widget.pick()
+
+	return widget.renderTemplate(widget, mediaPickerWidgetTemplate)
+}
+
+type jellyfinUserJson struct {
+	ID   string `json:"Id"`
+	Name string `json:"Name"`
+}
+
+type jellyfinLibraryItemJson struct {
+	ID             string            `json:"Id"`
+	Name           string            `json:"Name"`
+	ProductionYear int               `json:"ProductionYear"`
+	RunTimeTicks   int64             `json:"RunTimeTicks"`
+	Genres         []string          `json:"Genres"`
+	ImageTags      map[string]string `json:"ImageTags"`
+}
+
+func fetchJellyfinUnwatchedItems(client requestDoer, instanceURL, token, userName, mediaType string) ([]mediaPick, error) {
+	request, err := newJellyfinRequest(instanceURL, token, "/Users")
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := decodeJsonFromRequest[[]jellyfinUserJson](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching users: %v", err)
+	}
+
+	var userID string
+	for i := range users {
+		if strings.EqualFold(users[i].Name, userName) {
+			userID = users[i].ID
+			break
+		}
+	}
+
+	if userID == "" {
+		return nil, fmt.Errorf("user %s not found", userName)
+	}
+
+	query := url.Values{
+		"Recursive":        {"true"},
+		"IsPlayed":         {"false"},
+		"IncludeItemTypes": {ternary(mediaType == "shows", "Series", "Movie")},
+		"Fields":           {"Genres"},
+	}
+
+	request, err = newJellyfinRequest(instanceURL, token, "/Users/"+userID+"/Items?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeJsonFromRequest[struct {
+		Items []jellyfinLibraryItemJson `json:"Items"`
+	}](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching items: %v", err)
+	}
+
+	picks := make([]mediaPick, 0, len(response.Items))
+
+	for i := range response.Items {
+		item := &response.Items[i]
+
+		pick := mediaPick{
+			Title: item.Name,
+			Year:  item.ProductionYear,
+			// ticks are 100 nanoseconds each
+			Duration: time.Duration(item.RunTimeTicks * 100),
+			Genres:   item.Genres,
+			URL:      instanceURL + "/web/index.html#!/details?id=" + item.ID,
+		}
+
+		if tag, ok := item.ImageTags["Primary"]; ok {
+			pick.imagePath = "/Items/" + item.ID + "/Images/Primary?fillHeight=300&tag=" + url.QueryEscape(tag)
+		}
+
+		picks = append(picks, pick)
+	}
+
+	return picks, nil
+}
+
+type plexSectionsResponseJson struct {
+	MediaContainer struct {
+		Directory []struct {
+			Key   string `json:"key"`
+			Type  string `json:"type"`
+			Title string `json:"title"`
+		} `json:"Directory"`
+	} `json:"MediaContainer"`
+}
+
+type plexLibraryItemsResponseJson struct {
+	MediaContainer struct {
+		Metadata []struct {
+			Title    string `json:"title"`
+			Year     int    `json:"year"`
+			Duration int64  `json:"duration"`
+			Thumb    string `json:"thumb"`
+			Genre    []struct {
+				Tag string `json:"tag"`
+			} `json:"Genre"`
+		} `json:"Metadata"`
+	} `json:"MediaContainer"`
+}
+
+func fetchPlexUnwatchedItems(client requestDoer, instanceURL, token, mediaType string, libraries []string) ([]mediaPick, error) {
+	request, err := newPlexRequest(instanceURL, token, "/library/sections")
+	if err != nil {
+		return nil, err
+	}
+
+	sections, err := decodeJsonFromRequest[plexSectionsResponseJson](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching libraries: %v", err)
+	}
+
+	sectionType := ternary(mediaType == "shows", "show", "movie")
+	picks := make([]mediaPick, 0)
+
+	for _, section := range sections.MediaContainer.Directory {
+		if section.Type != sectionType {
+			continue
+		}
+
+		if len(libraries) > 0 && !slices.ContainsFunc(libraries, func(name string) bool {
+			return strings.EqualFold(name, section.Title)
+		}) {
+			continue
+		}
+
+		request, err := newPlexRequest(instanceURL, token, "/library/sections/"+url.PathEscape(section.Key)+"/all?unwatched=1")
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := decodeJsonFromRequest[plexLibraryItemsResponseJson](client, request)
+		if err != nil {
+			return nil, fmt.Errorf("fetching library %s: %v", section.Title, err)
+		}
+
+		for _, item := range response.MediaContainer.Metadata {
+			pick := mediaPick{
+				Title:     item.Title,
+				Year:      item.Year,
+				Duration:  time.Duration(item.Duration) * time.Millisecond,
+				imagePath: item.Thumb,
+			}
+
+			for _, genre := range item.Genre {
+				pick.Genres = append(pick.Genres, genre.Tag)
+			}
+
+			picks = append(picks, pick)
+		}
+	}
+
+	return picks, nil
+}
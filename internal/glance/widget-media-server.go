@@ -0,0 +1,447 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var mediaServerWidgetTemplate = mustParseTemplate("media-server.html", "widget-base.html")
+
+type mediaServerWidget struct {
+	widgetBase        `yaml:",inline"`
+	Service           string               `yaml:"service"`
+	URL               string               `yaml:"url"`
+	Token             string               `yaml:"token"`
+	AllowInsecure     bool                 `yaml:"allow-insecure"`
+	HideSessions      bool                 `yaml:"hide-sessions"`
+	HideRecentlyAdded bool                 `yaml:"hide-recently-added"`
+	Limit             int                  `yaml:"limit"`
+	CollapseAfter     int                  `yaml:"collapse-after"`
+	Sessions          []mediaServerSession `yaml:"-"`
+	RecentlyAdded     []mediaServerItem    `yaml:"-"`
+	images            imageProxy           `yaml:"-"`
+}
+
+type mediaServerItem struct {
+	Title     string
+	Subtitle  string
+	ImageURL  string
+	AddedAt   time.Time
+	imagePath string
+}
+
+type mediaServerSession struct {
+	mediaServerItem
+	User     string
+	Device   string
+	Progress int
+	IsPaused bool
+}
+
+func (widget *mediaServerWidget) initialize() error {
+	widget.withCacheDuration(time.Minute)
+
+	switch widget.Service {
+	case "jellyfin":
+		widget.withTitle("Jellyfin")
+	case "plex":
+		widget.withTitle("Plex")
+	default:
+		return errors.New("service must be either jellyfin or plex")
+	}
+
+	if widget.URL == "" {
+		return errors.New("url is required")
+	}
+
+	if widget.Token == "" {
+		return errors.New("token is required")
+	}
+
+	if widget.HideSessions && widget.HideRecentlyAdded {
+		return errors.New("hide-sessions and hide-recently-added cannot both be set")
+	}
+
+	widget.URL = strings.TrimRight(widget.URL, "/")
+
+	if widget.Limit <= 0 {
+		widget.Limit = 10
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *mediaServerWidget) update(ctx context.Context) {
+	var sessions []mediaServerSession
+	var recent []mediaServerItem
+	var sessionsErr, recentErr error
+
+	client := widget.httpClient(widget.AllowInsecure)
+
+	var wg sync.WaitGroup
+
+	if !widget.HideSessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if widget.Service == "jellyfin" {
+				sessions, sessionsErr = fetchJellyfinSessions(client, widget.URL, widget.Token)
+			} else {
+				sessions, sessionsErr = fetchPlexSessions(client, widget.URL, widget.Token)
+			}
+		}()
+	}
+
+	if !widget.HideRecentlyAdded {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if widget.Service == "jellyfin" {
+				recent, recentErr = fetchJellyfinRecentlyAdded(client, widget.URL, widget.Token, widget.Limit)
+			} else {
+				recent, recentErr = fetchPlexRecentlyAdded(client, widget.URL, widget.Token, widget.Limit)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	var err error
+
+	if sessionsErr != nil || recentErr != nil {
+		if (sessionsErr != nil || widget.HideSessions) && (recentErr != nil || widget.HideRecentlyAdded) {
+			err = fmt.Errorf("%w: %v", errNoContent, errors.Join(sessionsErr, recentErr))
+		} else {
+			err = fmt.Errorf("%w: %v", errPartialContent, errors.Join(sessionsErr, recentErr))
+		}
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	images := make(imageProxySet)
+
+	for i := range sessions {
+		sessions[i].ImageURL = images.add(&widget.widgetBase, widget.URL, sessions[i].imagePath)
+	}
+
+	for i := range recent {
+		recent[i].ImageURL = images.add(&widget.widgetBase, widget.URL, recent[i].imagePath)
+	}
+
+	widget.images.commit(images)
+
+	widget.Sessions = sessions
+	widget.RecentlyAdded = recent
+}
+
+func (widget *mediaServerWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || r.PathValue("path") != "image" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	widget.images.serve(w, r, widget.httpClient(widget.AllowInsecure), widget.authorizeRequest)
+}
+
+func (widget *mediaServerWidget) authorizeRequest(request *http.Request) {
+	setMediaServerToken(request, widget.Service, widget.Token)
+}
+
+func setMediaServerToken(request *http.Request, service, token string) {
+	if service == "jellyfin" {
+		request.Header.Set("X-Emby-Token", token)
+	} else {
+		request.Header.Set("X-Plex-Token", token)
+	}
+}
+
+func (widget *mediaServerWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, mediaServerWidgetTemplate)
+}
+
+func formatEpisodeNumber(season, episode int) string {
+	return fmt.Sprintf("S%02dE%02d", season, episode)
+}
+
+type jellyfinItemJson struct {
+	ID                    string            `json:"Id"`
+	Name                  string            `json:"Name"`
+	Type                  string            `json:"Type"`
+	SeriesName            string            `json:"SeriesName"`
+	SeriesID              string            `json:"SeriesId"`
+	SeriesPrimaryImageTag string            `json:"SeriesPrimaryImageTag"`
+	ParentIndexNumber     int               `json:"ParentIndexNumber"`
+	IndexNumber           int               `json:"IndexNumber"`
+	ProductionYear        int               `json:"ProductionYear"`
+	RunTimeTicks          int64             `json:"RunTimeTicks"`
+	DateCreated           string            `json:"DateCreated"`
+	ImageTags             map[string]string `json:"ImageTags"`
+}
+
+type jellyfinSessionJson struct {
+	UserName       string            `json:"UserName"`
+	Client         string            `json:"Client"`
+	DeviceName     string            `json:"DeviceName"`
+	NowPlayingItem *jellyfinItemJson `json:"NowPlayingItem"`
+	PlayState      struct {
+		PositionTicks int64 `json:"PositionTicks"`
+		IsPaused      bool  `json:"IsPaused"`
+	} `json:"PlayState"`
+}
+
+type jellyfinItemsResponseJson struct {
+	Items []jellyfinItemJson `json:"Items"`
+}
+
+func newJellyfinRequest(instanceURL, token, path string) (*http.Request, error) {
+	request, err := http.NewRequest("GET", instanceURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("X-Emby-Token", token)
+
+	return request, nil
+}
+
+func jellyfinItemToMediaServerItem(item *jellyfinItemJson) mediaServerItem {
+	converted := mediaServerItem{
+		Title:   item.Name,
+		AddedAt: parseRFC3339Time(item.DateCreated),
+	}
+
+	if item.Type == "Episode" {
+		converted.Title = item.SeriesName
+		converted.Subtitle = formatEpisodeNumber(item.ParentIndexNumber, item.IndexNumber) + " · " + item.Name
+	} else if item.ProductionYear > 0 {
+		converted.Subtitle = strconv.Itoa(item.ProductionYear)
+	}
+
+	// prefer the poster of the show over the thumbnail of the episode
+	if item.SeriesID != "" && item.SeriesPrimaryImageTag != "" {
+		converted.imagePath = "/Items/" + item.SeriesID + "/Images/Primary?fillHeight=300&tag=" + url.QueryEscape(item.SeriesPrimaryImageTag)
+	} else if tag, ok := item.ImageTags["Primary"]; ok {
+		converted.imagePath = "/Items/" + item.ID + "/Images/Primary?fillHeight=300&tag=" + url.QueryEscape(tag)
+	}
+
+	return converted
+}
+
+func fetchJellyfinSessions(client requestDoer, instanceURL, token string) ([]mediaServerSession, error) {
+	request, err := newJellyfinRequest(instanceURL, token, "/Sessions?activeWithinSeconds=600")
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeJsonFromRequest[[]jellyfinSessionJson](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching sessions: %v", err)
+	}
+
+	sessions := make([]mediaServerSession, 0)
+
+	for i := range response {
+		s := &response[i]
+		if s.NowPlayingItem == nil {
+			continue
+		}
+
+		session := mediaServerSession{
+			mediaServerItem: jellyfinItemToMediaServerItem(s.NowPlayingItem),
+			User:            s.UserName,
+			Device:          ternary(s.DeviceName != "", s.DeviceName, s.Client),
+			IsPaused:        s.PlayState.IsPaused,
+		}
+
+		if s.NowPlayingItem.RunTimeTicks > 0 {
+			session.Progress = int(s.PlayState.PositionTicks * 100 / s.NowPlayingItem.RunTimeTicks)
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+func fetchJellyfinRecentlyAdded(client requestDoer, instanceURL, token string, limit int) ([]mediaServerItem, error) {
+	query := url.Values{
+		"SortBy":           {"DateCreated"},
+		"SortOrder":        {"Descending"},
+		"Recursive":        {"true"},
+		"IncludeItemTypes": {"Movie,Episode"},
+		"Fields":           {"DateCreated"},
+		"Limit":            {strconv.Itoa(limit)},
+	}
+
+	request, err := newJellyfinRequest(instanceURL, token, "/Items?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeJsonFromRequest[jellyfinItemsResponseJson](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching recently added: %v", err)
+	}
+
+	items := make([]mediaServerItem, 0, len(response.Items))
+	for i := range response.Items {
+		items = append(items, jellyfinItemToMediaServerItem(&response.Items[i]))
+	}
+
+	return items, nil
+}
+
+type plexMetadataJson struct {
+	Title            string `json:"title"`
+	Type             string `json:"type"`
+	GrandparentTitle string `json:"grandparentTitle"`
+	ParentIndex      int    `json:"parentIndex"`
+	Index            int    `json:"index"`
+	Year             int    `json:"year"`
+	Duration         int64  `json:"duration"`
+	ViewOffset       int64  `json:"viewOffset"`
+	AddedAt          int64  `json:"addedAt"`
+	Thumb            string `json:"thumb"`
+	ParentThumb      string `json:"parentThumb"`
+	GrandparentThumb string `json:"grandparentThumb"`
+	User             *struct {
+		Title string `json:"title"`
+	} `json:"User"`
+	Player *struct {
+		Title   string `json:"title"`
+		Product string `json:"product"`
+		State   string `json:"state"`
+	} `json:"Player"`
+}
+
+type plexMediaContainerResponseJson struct {
+	MediaContainer struct {
+		Metadata []plexMetadataJson `json:"Metadata"`
+	} `json:"MediaContainer"`
+}
+
+func newPlexRequest(instanceURL, token, path string) (*http.Request, error) {
+	request, err := http.NewRequest("GET", instanceURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("X-Plex-Token", token)
+	request.Header.Set("Accept", "application/json")
+
+	return request, nil
+}
+
+func plexMetadataToMediaServerItem(metadata *plexMetadataJson) mediaServerItem {
+	item := mediaServerItem{
+		Title:     metadata.Title,
+		imagePath: metadata.Thumb,
+	}
+
+	if metadata.AddedAt > 0 {
+		item.AddedAt = time.Unix(metadata.AddedAt, 0)
+	}
+
+	switch metadata.Type {
+	case "episode":
+		item.Title = metadata.GrandparentTitle
+		item.Subtitle = formatEpisodeNumber(metadata.ParentIndex, metadata.Index) + " · " + metadata.Title
+		item.imagePath = ternary(metadata.GrandparentThumb != "", metadata.GrandparentThumb, metadata.Thumb)
+	case "season":
+		// recently added episodes of the same season get grouped together
+		item.Title = metadata.GrandparentTitle
+		item.Subtitle = metadata.Title
+		item.imagePath = ternary(metadata.Thumb != "", metadata.Thumb, metadata.ParentThumb)
+	default:
+		if metadata.Year > 0 {
+			item.Subtitle = strconv.Itoa(metadata.Year)
+		}
+	}
+
+	if item.Title == "" {
+		item.Title = metadata.Title
+	}
+
+	return item
+}
+
+func fetchPlexSessions(client requestDoer, instanceURL, token string) ([]mediaServerSession, error) {
+	request, err := newPlexRequest(instanceURL, token, "/status/sessions")
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeJsonFromRequest[plexMediaContainerResponseJson](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching sessions: %v", err)
+	}
+
+	sessions := make([]mediaServerSession, 0, len(response.MediaContainer.Metadata))
+
+	for i := range response.MediaContainer.Metadata {
+		metadata := &response.MediaContainer.Metadata[i]
+
+		session := mediaServerSession{
+			mediaServerItem: plexMetadataToMediaServerItem(metadata),
+		}
+
+		if metadata.User != nil {
+			session.User = metadata.User.Title
+		}
+
+		if metadata.Player != nil {
+			session.Device = ternary(metadata.Player.Title != "", metadata.Player.Title, metadata.Player.Product)
+			session.IsPaused = metadata.Player.State == "paused"
+		}
+
+		if metadata.Duration > 0 {
+			session.Progress = int(metadata.ViewOffset * 100 / metadata.Duration)
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+func fetchPlexRecentlyAdded(client requestDoer, instanceURL, token string, limit int) ([]mediaServerItem, error) {
+	query := url.Values{
+		"X-Plex-Container-Start": {"0"},
+		"X-Plex-Container-Size":  {strconv.Itoa(limit)},
+	}
+
+	request, err := newPlexRequest(instanceURL, token, "/library/recentlyAdded?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeJsonFromRequest[plexMediaContainerResponseJson](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching recently added: %v", err)
+	}
+
+	items := make([]mediaServerItem, 0, len(response.MediaContainer.Metadata))
+	for i := range response.MediaContainer.Metadata {
+		items = append(items, plexMetadataToMediaServerItem(&response.MediaContainer.Metadata[i]))
+	}
+
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	return items, nil
+}
@@ -1,45 +1,285 @@
 package glance
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"html/template"
+	"io"
+	"math"
 	"net/http"
+	"net/http/httptrace"
+	"regexp"
 	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/tidwall/gjson"
 )
 
+// the longest that notifications for a site can be silenced for at a time
+const monitorMaxSilenceDuration = 30 * 24 * time.Hour
+
 var (
-	monitorWidgetTemplate        = mustParseTemplate("monitor.html", "widget-base.html")
-	monitorWidgetCompactTemplate = mustParseTemplate("monitor-compact.html", "widget-base.html")
+	monitorWidgetTemplate        = mustParseTemplate("monitor.html", "widget-base.html", "monitor-shared.html")
+	monitorWidgetCompactTemplate = mustParseTemplate("monitor-compact.html", "widget-base.html", "monitor-shared.html")
 )
 
 type monitorWidget struct {
-	widgetBase `yaml:",inline"`
-	Sites      []struct {
-		*SiteStatusRequest `yaml:",inline"`
-		Status             *siteStatus     `yaml:"-"`
-		URL                string          `yaml:"-"`
-		ErrorURL           string          `yaml:"error-url"`
-		Title              string          `yaml:"title"`
-		Icon               customIconField `yaml:"icon"`
-		SameTab            bool            `yaml:"same-tab"`
-		StatusText         string          `yaml:"-"`
-		StatusStyle        string          `yaml:"-"`
-		AltStatusCodes     []int           `yaml:"alt-status-codes"`
-	} `yaml:"sites"`
-	Style           string `yaml:"style"`
-	ShowFailingOnly bool   `yaml:"show-failing-only"`
-	HasFailing      bool   `yaml:"-"`
+	widgetBase      `yaml:",inline"`
+	Sites           []monitorSite `yaml:"sites"`
+	Style           string        `yaml:"style"`
+	ShowFailingOnly bool          `yaml:"show-failing-only"`
+	UptimeReport    bool          `yaml:"uptime-report"`
+	UptimeTarget    float64       `yaml:"uptime-target"`
+	Notify          []string      `yaml:"notify"`
+	SilenceDuration durationField `yaml:"silence-duration"`
+	HasFailing      bool          `yaml:"-"`
+	lastUptimeCheck time.Time
+	// silence requests don't lock the page, this guards the fields of the
+	// sites that get set when updating, which those requests read and write
+	sitesMu sync.Mutex
+}
+
+type monitorSite struct {
+	*SiteStatusRequest `yaml:",inline"`
+	Status             *siteStatus                `yaml:"-"`
+	URL                string                     `yaml:"-"`
+	ErrorURL           string                     `yaml:"error-url"`
+	Title              string                     `yaml:"title"`
+	Icon               customIconField            `yaml:"icon"`
+	SameTab            bool                       `yaml:"same-tab"`
+	StatusText         string                     `yaml:"-"`
+	StatusStyle        string                     `yaml:"-"`
+	AltStatusCodes     []int                      `yaml:"alt-status-codes"`
+	UptimeText         string                     `yaml:"-"`
+	Maintenance        []monitorMaintenanceWindow `yaml:"maintenance"`
+	DependsOn          string                     `yaml:"depends-on"`
+	InMaintenance      bool                       `yaml:"-"`
+	Skipped            bool                       `yaml:"-"`
+	CanSilence         bool                       `yaml:"-"`
+	SilencedUntil      time.Time                  `yaml:"-"`
+	// whether the last notification that was sent said that the site is down
+	notifiedDown bool
+	parent       *monitorSite
+}
+
+type monitorCheckOutcome int
+
+const (
+	monitorCheckOK monitorCheckOutcome = iota
+	monitorCheckFailed
+	monitorCheckMaintenance
+	// the site failed while a site it depends on wasn't up, so it's
+	// unknown whether the site itself has a problem
+	monitorCheckSkipped
+)
+
+// Either a recurring window that starts according to a cron expression and
+// lasts for the given duration, or a one-off window between two dates
+type monitorMaintenanceWindow struct {
+	Cron     string        `yaml:"cron"`
+	Duration durationField `yaml:"duration"`
+	Start    string        `yaml:"start"`
+	End      string        `yaml:"end"`
+	schedule *cronSchedule
+	start    time.Time
+	end      time.Time
+}
+
+func (w *monitorMaintenanceWindow) initialize() error {
+	if w.Cron != "" {
+		if w.Start != "" || w.End != "" {
+			return errors.New("cron cannot be combined with start and end")
+		}
+
+		if w.Duration <= 0 {
+			return errors.New("duration is required when using cron")
+		}
+
+		schedule, err := parseCronSchedule(w.Cron)
+		if err != nil {
+			return fmt.Errorf("invalid cron expression: %v", err)
+		}
+
+		w.schedule = schedule
+		return nil
+	}
+
+	if w.Start == "" || w.End == "" {
+		return errors.New("either cron or start and end are required")
+	}
+
+	var err error
+	if w.start, err = parseMaintenanceTime(w.Start); err != nil {
+		return fmt.Errorf("invalid start: %v", err)
+	}
+
+	if w.end, err = parseMaintenanceTime(w.End); err != nil {
+		return fmt.Errorf("invalid end: %v", err)
+	}
+
+	if !w.end.After(w.start) {
+		return errors.New("end must be after start")
+	}
+
+	return nil
+}
+
+func parseMaintenanceTime(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+func (w *monitorMaintenanceWindow) isActive(now time.Time) bool {
+	if w.schedule == nil {
+		return !now.Before(w.start) && now.Before(w.end)
+	}
+
+	started := w.schedule.prev(now)
+
+	return !started.IsZero() && now.Before(started.Add(time.Duration(w.Duration)))
+}
+
+func (site *monitorSite) inMaintenanceWindow(now time.Time) bool {
+	for i := range site.Maintenance {
+		if site.Maintenance[i].isActive(now) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (widget *monitorWidget) initialize() error {
 	widget.withTitle("Monitor").withCacheDuration(5 * time.Minute)
 
+	for i := range widget.Sites {
+		request := widget.Sites[i].SiteStatusRequest
+		if request == nil {
+			continue
+		}
+
+		if err := request.initialize(); err != nil {
+			return fmt.Errorf("site %s: %v", widget.Sites[i].Title, err)
+		}
+
+		request.client = widget.checkHTTPClient(request.AllowInsecure)
+
+		for m := range widget.Sites[i].Maintenance {
+			if err := widget.Sites[i].Maintenance[m].initialize(); err != nil {
+				return fmt.Errorf("site %s: maintenance window %d: %v", widget.Sites[i].Title, m+1, err)
+			}
+		}
+	}
+
+	if err := widget.linkDependencies(); err != nil {
+		return err
+	}
+
+	if widget.UptimeTarget < 0 || widget.UptimeTarget > 100 {
+		return errors.New("uptime-target must be between 0 and 100")
+	}
+
+	if widget.SilenceDuration <= 0 {
+		widget.SilenceDuration = durationField(time.Hour)
+	} else if time.Duration(widget.SilenceDuration) > monitorMaxSilenceDuration {
+		return errors.New("silence-duration can't be longer than 30 days")
+	}
+
+	return nil
+}
+
+func (widget *monitorWidget) linkDependencies() error {
+	for i := range widget.Sites {
+		site := &widget.Sites[i]
+		if site.DependsOn == "" {
+			continue
+		}
+
+		for j := range widget.Sites {
+			if i != j && widget.Sites[j].Title == site.DependsOn {
+				site.parent = &widget.Sites[j]
+				break
+			}
+		}
+
+		if site.parent == nil {
+			return fmt.Errorf("site %s: depends-on: no other site with the title %s", site.Title, site.DependsOn)
+		}
+	}
+
+	for i := range widget.Sites {
+		visited := []string{widget.Sites[i].Title}
+
+		// cycles that don't include this site get reported when getting to one of the sites within them
+		for parent := widget.Sites[i].parent; parent != nil && len(visited) <= len(widget.Sites); parent = parent.parent {
+			visited = append(visited, parent.Title)
+
+			if parent == &widget.Sites[i] {
+				return fmt.Errorf("site %s: depends-on: dependency cycle: %s", widget.Sites[i].Title, strings.Join(visited, " -> "))
+			}
+		}
+	}
+
 	return nil
 }
 
+// A site's outcome depends on the outcome of the site it depends on, so the
+// outcomes of all sites are worked out together
+func (widget *monitorWidget) checkOutcomes(statuses []siteStatus, now time.Time) []monitorCheckOutcome {
+	outcomes := make([]monitorCheckOutcome, len(widget.Sites))
+	resolved := make([]bool, len(widget.Sites))
+
+	var resolve func(i int) monitorCheckOutcome
+	resolve = func(i int) monitorCheckOutcome {
+		if resolved[i] {
+			return outcomes[i]
+		}
+
+		site := &widget.Sites[i]
+		outcome := monitorCheckOK
+
+		if siteCheckFailed(&statuses[i], site.AltStatusCodes) {
+			switch {
+			case site.inMaintenanceWindow(now):
+				outcome = monitorCheckMaintenance
+			case site.parent != nil && resolve(widget.siteIndex(site.parent)) != monitorCheckOK:
+				outcome = monitorCheckSkipped
+			default:
+				outcome = monitorCheckFailed
+			}
+		}
+
+		outcomes[i], resolved[i] = outcome, true
+		return outcome
+	}
+
+	for i := range widget.Sites {
+		resolve(i)
+	}
+
+	return outcomes
+}
+
+func (widget *monitorWidget) siteIndex(site *monitorSite) int {
+	for i := range widget.Sites {
+		if &widget.Sites[i] == site {
+			return i
+		}
+	}
+
+	return -1
+}
+
 func (widget *monitorWidget) update(ctx context.Context) {
 	requests := make([]*SiteStatusRequest, len(widget.Sites))
 
@@ -54,28 +294,238 @@ func (widget *monitorWidget) update(ctx context.Context) {
 	}
 
 	widget.HasFailing = false
+	now := time.Now()
+
+	if widget.UptimeReport {
+		widget.withTitleURL(widget.Providers.uptimeReportURLResolver(widget.GetID()))
+	}
+
+	outcomes := widget.checkOutcomes(statuses, now)
+
+	widget.sitesMu.Lock()
+	defer widget.sitesMu.Unlock()
 
 	for i := range widget.Sites {
 		site := &widget.Sites[i]
 		status := &statuses[i]
 		site.Status = status
+		site.InMaintenance = outcomes[i] == monitorCheckMaintenance
+		site.Skipped = outcomes[i] == monitorCheckSkipped
 
-		if !slices.Contains(site.AltStatusCodes, status.Code) && (status.Code >= 400 || status.Error != nil) {
+		if outcomes[i] == monitorCheckFailed {
 			widget.HasFailing = true
 		}
 
+		site.CanSilence = len(widget.Notify) > 0
+		site.SilencedUntil = widget.silencedUntil(site, now)
+
+		if widget.UptimeReport {
+			history := loadMonitorUptimeHistory(widget.Providers.state, site.DefaultURL)
+			site.UptimeText = formatUptime(history.month(now.Year(), now.Month()))
+		}
+
 		if status.Error != nil && site.ErrorURL != "" {
 			site.URL = site.ErrorURL
 		} else {
 			site.URL = site.DefaultURL
 		}
 
-		site.StatusText = statusCodeToText(status.Code, site.AltStatusCodes)
-		site.StatusStyle = statusCodeToStyle(status.Code, site.AltStatusCodes)
+		if site.InMaintenance {
+			site.StatusText = "Maintenance"
+			site.StatusStyle = "maintenance"
+		} else if site.Skipped {
+			site.StatusText = "Skipped"
+			site.StatusStyle = "skipped"
+		} else {
+			site.StatusText = statusCodeToText(status.Code, site.AltStatusCodes)
+			site.StatusStyle = statusCodeToStyle(status.Code, site.AltStatusCodes)
+		}
+	}
+}
+
+// When the uptime is tracked or notifications are enabled the sites also get
+// checked in the background so that neither depends on whether anyone is
+// looking at the page
+func (widget *monitorWidget) runBackgroundTask(now time.Time) {
+	if !widget.UptimeReport && len(widget.Notify) == 0 {
+		return
+	}
+
+	if now.Sub(widget.lastUptimeCheck) < widget.cacheDuration {
+		return
+	}
+
+	widget.lastUptimeCheck = now
+	requests := make([]*SiteStatusRequest, len(widget.Sites))
+
+	for i := range widget.Sites {
+		requests[i] = widget.Sites[i].SiteStatusRequest
+	}
+
+	statuses, err := fetchStatusForSites(requests)
+	if err != nil {
+		widget.logger().Error("Failed to check sites", "error", err)
+		return
+	}
+
+	outcomes := widget.checkOutcomes(statuses, now)
+
+	for i := range widget.Sites {
+		site := &widget.Sites[i]
+		// failures during maintenance or while a site this one depends on is
+		// down don't count against the uptime
+		failed := outcomes[i] == monitorCheckFailed
+
+		if widget.UptimeReport {
+			recordMonitorCheck(widget.Providers.state, site.DefaultURL, failed, now)
+		}
+
+		if len(widget.Notify) > 0 && (failed || outcomes[i] == monitorCheckOK) && widget.silencedUntil(site, now).IsZero() {
+			widget.notifyStatusChange(site, &statuses[i], failed)
+		}
+	}
+}
+
+func (widget *monitorWidget) getNotificationTargets() []string {
+	return widget.Notify
+}
+
+// Only changes in status get notified about. While a site is in maintenance or
+// silenced nothing gets sent, so if it's still down afterwards the notification
+// goes out then, and if it came back up in the meantime so does that one.
+func (widget *monitorWidget) notifyStatusChange(site *monitorSite, status *siteStatus, failed bool) {
+	if failed == site.notifiedDown {
+		return
+	}
+
+	site.notifiedDown = failed
+	n := notification{Title: site.Title + " is back up", URL: site.DefaultURL}
+
+	if failed {
+		n.Title = site.Title + " is down"
+
+		switch {
+		case status.TimedOut:
+			n.Message = "Timed out"
+		case status.Error != nil:
+			n.Message = status.Error.Error()
+		default:
+			n.Message = fmt.Sprintf("%s (%d)", statusCodeToText(status.Code, site.AltStatusCodes), status.Code)
+		}
+	}
+
+	widget.Providers.notifier.notify(widget.Notify, n)
+}
+
+func monitorSilenceStateKey(url string) string {
+	return "monitor-silence:" + seenItemID(url)
+}
+
+// Returns the zero time if the notifications for the site aren't silenced
+func (widget *monitorWidget) silencedUntil(site *monitorSite, now time.Time) time.Time {
+	var until int64
+	if !widget.Providers.state.get(monitorSilenceStateKey(site.DefaultURL), &until) {
+		return time.Time{}
+	}
+
+	if t := time.Unix(until, 0); t.After(now) {
+		return t
 	}
+
+	return time.Time{}
+}
+
+func (widget *monitorWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("path") != "silence" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Site     string `json:"site"`
+		Duration string `json:"duration"`
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4*1024)).Decode(&body); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var site *monitorSite
+	for i := range widget.Sites {
+		if widget.Sites[i].Title == body.Site {
+			site = &widget.Sites[i]
+			break
+		}
+	}
+
+	if site == nil || len(widget.Notify) == 0 {
+		http.Error(w, "site not found", http.StatusNotFound)
+		return
+	}
+
+	duration := time.Duration(widget.SilenceDuration)
+	if body.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(body.Duration); err != nil || duration < 0 {
+			http.Error(w, "invalid duration", http.StatusBadRequest)
+			return
+		}
+
+		if duration > monitorMaxSilenceDuration {
+			http.Error(w, "duration can't be longer than 30 days", http.StatusBadRequest)
+			return
+		}
+	}
+
+	widget.sitesMu.Lock()
+	defer widget.sitesMu.Unlock()
+
+	now := time.Now()
+	key := monitorSilenceStateKey(site.DefaultURL)
+	describe := func(until time.Time) string {
+		return ternary(until.After(now), "silenced until "+until.Format("Jan 2 15:04"), "not silenced")
+	}
+	before := describe(widget.silencedUntil(site, now))
+
+	if duration == 0 {
+		widget.Providers.state.delete(key)
+		site.SilencedUntil = time.Time{}
+		widget.Providers.audit.record(r, "Unsilenced site", widget.Title+": "+site.Title, before, "not silenced")
+	} else {
+		site.SilencedUntil = now.Add(duration)
+		widget.Providers.state.set(key, site.SilencedUntil.Unix())
+		widget.Providers.audit.record(r, "Silenced site", widget.Title+": "+site.Title, before, describe(site.SilencedUntil))
+	}
+
+	if site.Status == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var html bytes.Buffer
+	t := ternary(widget.Style == "compact", monitorWidgetCompactTemplate, monitorWidgetTemplate)
+	if err := t.ExecuteTemplate(&html, "site", site); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(html.Bytes())
+}
+
+func siteCheckFailed(status *siteStatus, altStatusCodes []int) bool {
+	return !slices.Contains(altStatusCodes, status.Code) && (status.Code >= 400 || status.Error != nil)
 }
 
 func (widget *monitorWidget) Render() template.HTML {
+	widget.sitesMu.Lock()
+	defer widget.sitesMu.Unlock()
+
 	if widget.Style == "compact" {
 		return widget.renderTemplate(widget, monitorWidgetCompactTemplate)
 	}
@@ -115,19 +565,175 @@ func statusCodeToStyle(status int, altStatusCodes []int) string {
 }
 
 type SiteStatusRequest struct {
-	DefaultURL    string `yaml:"url"`
-	CheckURL      string `yaml:"check-url"`
-	AllowInsecure bool   `yaml:"allow-insecure"`
+	DefaultURL    string             `yaml:"url"`
+	CheckURL      string             `yaml:"check-url"`
+	AllowInsecure bool               `yaml:"allow-insecure"`
+	Timeout       durationField      `yaml:"timeout"`
+	Steps         []monitorCheckStep `yaml:"steps"`
+	client        requestDoer
+}
+
+// A single request of a check that's made up of multiple requests, such as
+// logging in and then fetching a page that requires being logged in
+type monitorCheckStep struct {
+	URL          string                          `yaml:"url"`
+	Method       string                          `yaml:"method"`
+	Headers      map[string]string               `yaml:"headers"`
+	Body         string                          `yaml:"body"`
+	ExpectStatus int                             `yaml:"expect-status"`
+	ExpectBody   string                          `yaml:"expect-body"`
+	Extract      map[string]*monitorCheckExtract `yaml:"extract"`
+}
+
+type monitorCheckExtract struct {
+	JSON   string `yaml:"json"`
+	Regex  string `yaml:"regex"`
+	Header string `yaml:"header"`
+	regex  *regexp.Regexp
+}
+
+func (r *SiteStatusRequest) initialize() error {
+	if r.Timeout == 0 {
+		r.Timeout = durationField(ternary(len(r.Steps) > 0, 10*time.Second, 3*time.Second))
+	}
+
+	for i := range r.Steps {
+		step := &r.Steps[i]
+
+		if step.URL == "" {
+			return fmt.Errorf("step %d: url is required", i+1)
+		}
+
+		if step.Method == "" {
+			step.Method = ternary(step.Body != "", http.MethodPost, http.MethodGet)
+		}
+		step.Method = strings.ToUpper(step.Method)
+
+		for name, extract := range step.Extract {
+			if extract == nil || extract.JSON == "" && extract.Regex == "" && extract.Header == "" {
+				return fmt.Errorf("step %d: extract %s must have one of json, regex or header", i+1, name)
+			}
+
+			if extract.Regex != "" {
+				regex, err := regexp.Compile(extract.Regex)
+				if err != nil {
+					return fmt.Errorf("step %d: extract %s: %v", i+1, name, err)
+				}
+
+				extract.regex = regex
+			}
+		}
+	}
+
+	return nil
 }
 
 type siteStatus struct {
 	Code         int
 	TimedOut     bool
 	ResponseTime time.Duration
+	Timings      siteStatusTimings
 	Error        error
 }
 
+// When a check is made up of multiple steps the timings are the sum of the
+// timings of all steps
+type siteStatusTimings struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration
+	// false when all requests were sent over already open connections, in
+	// which case there's nothing to show for DNS, connect and TLS
+	NewConnection bool
+}
+
+func (t *siteStatusTimings) IsAvailable() bool {
+	return t.TTFB > 0
+}
+
+// Callbacks for dialing can get called from other goroutines, and even after
+// the request is done in the case of connections that lost the race to be
+// used, hence the lock and the timings being copied out at the end
+type latencyTrace struct {
+	mu           sync.Mutex
+	timings      siteStatusTimings
+	requestStart time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+}
+
+func (t *latencyTrace) context(ctx context.Context) context.Context {
+	t.mu.Lock()
+	t.requestStart = time.Now()
+	t.mu.Unlock()
+
+	locked := func(f func()) {
+		t.mu.Lock()
+		f()
+		t.mu.Unlock()
+	}
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			locked(func() { t.dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			locked(func() { t.timings.DNS += time.Since(t.dnsStart) })
+		},
+		ConnectStart: func(_, _ string) {
+			// multiple addresses can get dialed at the same time, the
+			// connect time is measured from the first one
+			locked(func() {
+				if t.connectStart.IsZero() {
+					t.connectStart = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			locked(func() {
+				if err == nil && !t.connectStart.IsZero() {
+					t.timings.Connect += time.Since(t.connectStart)
+					t.connectStart = time.Time{}
+				}
+			})
+		},
+		TLSHandshakeStart: func() {
+			locked(func() { t.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			locked(func() {
+				if err == nil {
+					t.timings.TLS += time.Since(t.tlsStart)
+				}
+			})
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			locked(func() {
+				if !info.Reused {
+					t.timings.NewConnection = true
+				}
+			})
+		},
+		GotFirstResponseByte: func() {
+			locked(func() { t.timings.TTFB += time.Since(t.requestStart) })
+		},
+	})
+}
+
+func (t *latencyTrace) result() siteStatusTimings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.timings
+}
+
 func fetchSiteStatusTask(statusRequest *SiteStatusRequest) (siteStatus, error) {
+	if len(statusRequest.Steps) > 0 {
+		return runMultiStepCheck(statusRequest), nil
+	}
+
 	var url string
 	if statusRequest.CheckURL != "" {
 		url = statusRequest.CheckURL
@@ -141,19 +747,16 @@ func fetchSiteStatusTask(statusRequest *SiteStatusRequest) (siteStatus, error) {
 		}, nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(statusRequest.Timeout))
 	defer cancel()
-	request = request.WithContext(ctx)
+	trace := &latencyTrace{}
+	request = request.WithContext(trace.context(ctx))
 	requestSentAt := time.Now()
 	var response *http.Response
 
-	if !statusRequest.AllowInsecure {
-		response, err = defaultHTTPClient.Do(request)
-	} else {
-		response, err = defaultInsecureHTTPClient.Do(request)
-	}
+	response, err = statusRequest.client.Do(request)
 
-	status := siteStatus{ResponseTime: time.Since(requestSentAt)}
+	status := siteStatus{ResponseTime: time.Since(requestSentAt), Timings: trace.result()}
 
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -171,6 +774,128 @@ func fetchSiteStatusTask(statusRequest *SiteStatusRequest) (siteStatus, error) {
 	return status, nil
 }
 
+// Runs the steps one after another, stopping at the first one that fails. The
+// timeout applies to all of the steps together rather than to each one.
+func runMultiStepCheck(statusRequest *SiteStatusRequest) siteStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(statusRequest.Timeout))
+	defer cancel()
+
+	values := make(map[string]string)
+	cookies := make(map[string]*http.Cookie)
+	trace := &latencyTrace{}
+	startedAt := time.Now()
+	var status siteStatus
+
+	for i := range statusRequest.Steps {
+		code, err := statusRequest.Steps[i].run(ctx, statusRequest.client, trace, values, cookies)
+		status.Code = code
+
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				status.TimedOut = true
+			}
+
+			status.Error = fmt.Errorf("step %d: %w", i+1, err)
+			break
+		}
+	}
+
+	status.ResponseTime = time.Since(startedAt)
+	status.Timings = trace.result()
+
+	return status
+}
+
+const monitorCheckMaxBodySize = 1024 * 1024
+
+// Values extracted by previous steps can be used in the URL, headers and body
+// of the step through {name} placeholders. Cookies get carried over between
+// steps so that sessions work the same way they would in a browser.
+func (step *monitorCheckStep) run(
+	ctx context.Context,
+	client requestDoer,
+	trace *latencyTrace,
+	values map[string]string,
+	cookies map[string]*http.Cookie,
+) (int, error) {
+	placeholders := make([]string, 0, len(values)*2)
+	for name, value := range values {
+		placeholders = append(placeholders, "{"+name+"}", value)
+	}
+	replacer := strings.NewReplacer(placeholders...)
+
+	var body io.Reader
+	if step.Body != "" {
+		body = strings.NewReader(replacer.Replace(step.Body))
+	}
+
+	request, err := http.NewRequestWithContext(trace.context(ctx), step.Method, replacer.Replace(step.URL), body)
+	if err != nil {
+		return 0, err
+	}
+
+	for key, value := range step.Headers {
+		request.Header.Set(key, replacer.Replace(value))
+	}
+
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	code := response.StatusCode
+
+	for _, cookie := range response.Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+
+	if step.ExpectStatus != 0 && code != step.ExpectStatus {
+		return code, fmt.Errorf("expected status code %d, got %d", step.ExpectStatus, code)
+	} else if step.ExpectStatus == 0 && code >= 400 {
+		return code, fmt.Errorf("unexpected status code %d", code)
+	}
+
+	contents, err := io.ReadAll(io.LimitReader(response.Body, monitorCheckMaxBodySize))
+	if err != nil {
+		return code, err
+	}
+	responseBody := string(contents)
+
+	if step.ExpectBody != "" && !strings.Contains(responseBody, step.ExpectBody) {
+		return code, fmt.Errorf("response does not contain %q", step.ExpectBody)
+	}
+
+	for name, extract := range step.Extract {
+		var value string
+
+		switch {
+		case extract.Header != "":
+			value = response.Header.Get(extract.Header)
+		case extract.JSON != "":
+			value = gjson.Get(responseBody, extract.JSON).String()
+		case extract.regex != nil:
+			if match := extract.regex.FindStringSubmatch(responseBody); len(match) > 1 {
+				value = match[1]
+			} else if len(match) == 1 {
+				value = match[0]
+			}
+		}
+
+		if value == "" {
+			return code, fmt.Errorf("could not extract %s from the response", name)
+		}
+
+		values[name] = value
+	}
+
+	return code, nil
+}
+
 func fetchStatusForSites(requests []*SiteStatusRequest) ([]siteStatus, error) {
 	job := newJob(fetchSiteStatusTask, requests).withWorkers(20)
 	results, _, err := workerPoolDo(job)
@@ -180,3 +905,165 @@ func fetchStatusForSites(requests []*SiteStatusRequest) ([]siteStatus, error) {
 
 	return results, nil
 }
+
+// Only the daily totals are kept rather than every check, which is
+// plenty for working out the uptime over a month
+const monitorUptimeMaxDays = 400
+
+type monitorUptimeDay struct {
+	Date   string `json:"date"`
+	Checks int    `json:"checks"`
+	Failed int    `json:"failed"`
+}
+
+type monitorUptimeHistory struct {
+	Days []monitorUptimeDay `json:"days"`
+}
+
+func monitorUptimeStateKey(url string) string {
+	return "monitor-uptime:" + seenItemID(url)
+}
+
+func loadMonitorUptimeHistory(state *stateStore, url string) monitorUptimeHistory {
+	var history monitorUptimeHistory
+	state.get(monitorUptimeStateKey(url), &history)
+
+	return history
+}
+
+func recordMonitorCheck(state *stateStore, url string, failed bool, now time.Time) {
+	history := loadMonitorUptimeHistory(state, url)
+	date := now.Format(time.DateOnly)
+
+	if n := len(history.Days); n == 0 || history.Days[n-1].Date != date {
+		history.Days = append(history.Days, monitorUptimeDay{Date: date})
+	}
+
+	day := &history.Days[len(history.Days)-1]
+	day.Checks++
+	if failed {
+		day.Failed++
+	}
+
+	if len(history.Days) > monitorUptimeMaxDays {
+		history.Days = history.Days[len(history.Days)-monitorUptimeMaxDays:]
+	}
+
+	state.set(monitorUptimeStateKey(url), history)
+}
+
+// Returns the number of checks and how many of them failed during the given month
+func (h *monitorUptimeHistory) month(year int, month time.Month) (int, int) {
+	prefix := fmt.Sprintf("%04d-%02d-", year, month)
+	var checks, failed int
+
+	for i := range h.Days {
+		if strings.HasPrefix(h.Days[i].Date, prefix) {
+			checks += h.Days[i].Checks
+			failed += h.Days[i].Failed
+		}
+	}
+
+	return checks, failed
+}
+
+func uptimePercentage(checks, failed int) float64 {
+	if checks == 0 {
+		return 0
+	}
+
+	return float64(checks-failed) / float64(checks) * 100
+}
+
+// Rounded down so that a single failed check doesn't get displayed as 100%
+func formatUptime(checks, failed int) string {
+	if checks == 0 {
+		return ""
+	}
+
+	if failed == 0 {
+		return "100%"
+	}
+
+	return strconv.FormatFloat(math.Floor(uptimePercentage(checks, failed)*100)/100, 'f', 2, 64) + "%"
+}
+
+type monitorUptimeReportRow struct {
+	Title       string
+	URL         string
+	Checks      int
+	Failed      int
+	Uptime      string
+	BelowTarget bool
+}
+
+func (widget *monitorWidget) uptimeReport(year int, month time.Month) []monitorUptimeReportRow {
+	rows := make([]monitorUptimeReportRow, 0, len(widget.Sites))
+
+	for i := range widget.Sites {
+		site := &widget.Sites[i]
+		history := loadMonitorUptimeHistory(widget.Providers.state, site.DefaultURL)
+		checks, failed := history.month(year, month)
+
+		rows = append(rows, monitorUptimeReportRow{
+			Title:       site.Title,
+			URL:         site.DefaultURL,
+			Checks:      checks,
+			Failed:      failed,
+			Uptime:      formatUptime(checks, failed),
+			BelowTarget: checks > 0 && widget.UptimeTarget > 0 && uptimePercentage(checks, failed) < widget.UptimeTarget,
+		})
+	}
+
+	return rows
+}
+
+type monitorSiteJson struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	// one of ok, error, maintenance, skipped or pending when the site
+	// hasn't been checked yet
+	Status         string     `json:"status"`
+	StatusCode     int        `json:"status_code,omitempty"`
+	TimedOut       bool       `json:"timed_out,omitempty"`
+	ResponseTimeMs int64      `json:"response_time_ms,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	Uptime         string     `json:"uptime,omitempty"`
+	SilencedUntil  *time.Time `json:"silenced_until,omitempty"`
+}
+
+func (widget *monitorWidget) data() any {
+	widget.sitesMu.Lock()
+	defer widget.sitesMu.Unlock()
+
+	sites := make([]monitorSiteJson, 0, len(widget.Sites))
+
+	for i := range widget.Sites {
+		site := &widget.Sites[i]
+		siteJson := monitorSiteJson{
+			Title:         site.Title,
+			URL:           site.URL,
+			Status:        "pending",
+			Uptime:        site.UptimeText,
+			SilencedUntil: timeOrNil(site.SilencedUntil),
+		}
+
+		if site.Status != nil {
+			siteJson.Status = site.StatusStyle
+			siteJson.StatusCode = site.Status.Code
+			siteJson.TimedOut = site.Status.TimedOut
+			siteJson.ResponseTimeMs = site.Status.ResponseTime.Milliseconds()
+
+			if site.Status.Error != nil {
+				siteJson.Error = site.Status.Error.Error()
+			}
+		}
+
+		sites = append(sites, siteJson)
+	}
+
+	return map[string]any{
+		"failing": widget.HasFailing,
+		"sites":   sites,
+	}
+}
@@ -0,0 +1,451 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var networkUsageWidgetTemplate = mustParseTemplate("network-usage.html", "widget-base.html")
+
+type networkUsageWidget struct {
+	widgetBase    `yaml:",inline"`
+	Source        string                      `yaml:"source"`
+	URL           string                      `yaml:"url"`
+	Token         string                      `yaml:"token"`
+	Username      string                      `yaml:"username"`
+	Password      string                      `yaml:"password"`
+	Interface     string                      `yaml:"interface"`
+	AllowInsecure bool                        `yaml:"allow-insecure"`
+	Devices       []networkUsageDeviceRequest `yaml:"devices"`
+	Limit         int                         `yaml:"limit"`
+	CollapseAfter int                         `yaml:"collapse-after"`
+	Usage         []networkUsageDevice        `yaml:"-"`
+}
+
+type networkUsageDeviceRequest struct {
+	Name      string   `yaml:"name"`
+	Addresses []string `yaml:"addresses"`
+}
+
+// A client as reported by the source, not all sources provide all of the fields
+type networkUsageClient struct {
+	Address       string
+	MAC           string
+	Name          string
+	BytesSent     uint64
+	BytesReceived uint64
+	Queries       int
+	FirstSeen     time.Time
+	LastSeen      time.Time
+}
+
+type networkUsageDevice struct {
+	Name          string
+	Address       string
+	Bytes         uint64
+	BytesSent     uint64
+	BytesReceived uint64
+	Queries       int
+	OnlineFor     time.Duration
+	LastSeen      time.Time
+	Percentage    int
+}
+
+func (d networkUsageDevice) FormattedBytes() string {
+	return formatNetworkUsageBytes(d.Bytes)
+}
+
+func (d networkUsageDevice) FormattedOnlineFor() string {
+	hours := int(d.OnlineFor.Hours())
+	minutes := int(d.OnlineFor.Minutes()) % 60
+
+	if hours == 0 {
+		return strconv.Itoa(minutes) + "m"
+	}
+
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+func formatNetworkUsageBytes(bytes uint64) string {
+	const unit = 1000
+	if bytes < unit {
+		return strconv.FormatUint(bytes, 10) + " B"
+	}
+
+	value := float64(bytes)
+	suffixes := []string{"KB", "MB", "GB", "TB"}
+	i := -1
+
+	for value >= unit && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+
+	if value < 10 {
+		return fmt.Sprintf("%.1f %s", value, suffixes[i])
+	}
+
+	return fmt.Sprintf("%.0f %s", value, suffixes[i])
+}
+
+func (widget *networkUsageWidget) initialize() error {
+	widget.withTitle("Network Usage").withCacheDuration(5 * time.Minute)
+
+	if widget.Source != "adguard" && widget.Source != "ntopng" && widget.Source != "opnsense" {
+		return errors.New("source must be one of adguard, ntopng or opnsense")
+	}
+
+	if widget.URL == "" {
+		return errors.New("url is required")
+	}
+
+	widget.URL = strings.TrimRight(widget.URL, "/")
+
+	for i := range widget.Devices {
+		if widget.Devices[i].Name == "" {
+			return fmt.Errorf("device %d has no name", i+1)
+		}
+
+		if len(widget.Devices[i].Addresses) == 0 {
+			return fmt.Errorf("device %s has no addresses", widget.Devices[i].Name)
+		}
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 10
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *networkUsageWidget) update(ctx context.Context) {
+	var clients []networkUsageClient
+	var err error
+
+	client := widget.httpClient(widget.AllowInsecure)
+
+	switch widget.Source {
+	case "adguard":
+		clients, err = fetchAdguardClientUsage(client, widget.URL, widget.Username, widget.Password)
+	case "ntopng":
+		clients, err = fetchNtopngClientUsage(client, widget.URL, widget.Interface, widget.Token, widget.Username, widget.Password)
+	case "opnsense":
+		clients, err = fetchOPNsenseClientUsage(client, widget.URL, widget.Username, widget.Password)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	usage := widget.groupClientsIntoDevices(clients, time.Now())
+
+	if len(usage) > widget.Limit {
+		usage = usage[:widget.Limit]
+	}
+
+	widget.Usage = usage
+}
+
+// When devices are configured only they are shown, with the usage of all of
+// their addresses combined, otherwise every client is shown on its own
+func (widget *networkUsageWidget) groupClientsIntoDevices(clients []networkUsageClient, now time.Time) []networkUsageDevice {
+	devices := make([]networkUsageDevice, 0)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	addClient := func(device *networkUsageDevice, client *networkUsageClient) {
+		device.BytesSent += client.BytesSent
+		device.BytesReceived += client.BytesReceived
+		device.Bytes += client.BytesSent + client.BytesReceived
+		device.Queries += client.Queries
+
+		if !client.FirstSeen.IsZero() && !client.LastSeen.IsZero() {
+			firstSeen := client.FirstSeen
+			if firstSeen.Before(midnight) {
+				firstSeen = midnight
+			}
+
+			if client.LastSeen.After(firstSeen) {
+				device.OnlineFor = max(device.OnlineFor, client.LastSeen.Sub(firstSeen))
+			}
+		}
+
+		if client.LastSeen.After(device.LastSeen) {
+			device.LastSeen = client.LastSeen
+		}
+	}
+
+	if len(widget.Devices) == 0 {
+		for i := range clients {
+			device := networkUsageDevice{
+				Name:    ternary(clients[i].Name != "", clients[i].Name, clients[i].Address),
+				Address: clients[i].Address,
+			}
+
+			addClient(&device, &clients[i])
+			devices = append(devices, device)
+		}
+	} else {
+		for _, request := range widget.Devices {
+			device := networkUsageDevice{Name: request.Name}
+
+			for i := range clients {
+				if networkUsageClientMatches(&clients[i], request.Addresses) {
+					addClient(&device, &clients[i])
+				}
+			}
+
+			devices = append(devices, device)
+		}
+	}
+
+	sort.SliceStable(devices, func(i, j int) bool {
+		if devices[i].Bytes != devices[j].Bytes {
+			return devices[i].Bytes > devices[j].Bytes
+		}
+
+		return devices[i].Queries > devices[j].Queries
+	})
+
+	if len(devices) > 0 {
+		maxBytes, maxQueries := devices[0].Bytes, devices[0].Queries
+
+		for i := range devices {
+			if maxBytes > 0 {
+				devices[i].Percentage = int(float64(devices[i].Bytes) / float64(maxBytes) * 100)
+			} else if maxQueries > 0 {
+				devices[i].Percentage = int(float64(devices[i].Queries) / float64(maxQueries) * 100)
+			}
+		}
+	}
+
+	return devices
+}
+
+func networkUsageClientMatches(client *networkUsageClient, addresses []string) bool {
+	for _, address := range addresses {
+		if strings.EqualFold(address, client.Address) ||
+			(client.MAC != "" && strings.EqualFold(address, client.MAC)) ||
+			(client.Name != "" && strings.EqualFold(address, client.Name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (widget *networkUsageWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, networkUsageWidgetTemplate)
+}
+
+type adguardClientStatsResponseJson struct {
+	TopClients []map[string]int `json:"top_clients"`
+}
+
+type adguardClientsResponseJson struct {
+	Clients []struct {
+		Name string   `json:"name"`
+		IDs  []string `json:"ids"`
+	} `json:"clients"`
+	AutoClients []struct {
+		IP   string `json:"ip"`
+		Name string `json:"name"`
+	} `json:"auto_clients"`
+}
+
+// AdGuard Home only knows about DNS queries, so the usage is the number of
+// queries each client made within the configured statistics interval
+func fetchAdguardClientUsage(client requestDoer, instanceURL, username, password string) ([]networkUsageClient, error) {
+	statsRequest, err := http.NewRequest("GET", instanceURL+"/control/stats", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	statsRequest.SetBasicAuth(username, password)
+
+	stats, err := decodeJsonFromRequest[adguardClientStatsResponseJson](client, statsRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+
+	clientsRequest, _ := http.NewRequest("GET", instanceURL+"/control/clients", nil)
+	clientsRequest.SetBasicAuth(username, password)
+
+	// names are nice to have, the usage can still be shown without them
+	if response, err := decodeJsonFromRequest[adguardClientsResponseJson](client, clientsRequest); err == nil {
+		for _, auto := range response.AutoClients {
+			names[auto.IP] = auto.Name
+		}
+
+		for _, persistent := range response.Clients {
+			for _, id := range persistent.IDs {
+				names[id] = persistent.Name
+			}
+		}
+	}
+
+	clients := make([]networkUsageClient, 0, len(stats.TopClients))
+
+	for _, entry := range stats.TopClients {
+		for address, queries := range entry {
+			clients = append(clients, networkUsageClient{
+				Address: address,
+				Name:    names[address],
+				Queries: queries,
+			})
+		}
+	}
+
+	return clients, nil
+}
+
+type ntopngActiveHostsResponseJson struct {
+	RC    int    `json:"rc"`
+	RCStr string `json:"rc_str"`
+	Rsp   struct {
+		Data []struct {
+			IP    string `json:"ip"`
+			MAC   string `json:"mac"`
+			Name  string `json:"name"`
+			Bytes struct {
+				Sent     uint64 `json:"sent"`
+				Received uint64 `json:"recvd"`
+			} `json:"bytes"`
+			FirstSeen int64 `json:"first_seen"`
+			LastSeen  int64 `json:"last_seen"`
+		} `json:"data"`
+	} `json:"rsp"`
+}
+
+func fetchNtopngClientUsage(client requestDoer, instanceURL, iface, token, username, password string) ([]networkUsageClient, error) {
+	query := url.Values{
+		"ifid":       {ternary(iface != "", iface, "0")},
+		"perPage":    {"1000"},
+		"sortColumn": {"traffic"},
+		"sortOrder":  {"desc"},
+		"mode":       {"local"},
+	}
+
+	request, err := http.NewRequest("GET", instanceURL+"/lua/rest/v2/get/host/active.lua?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if token != "" {
+		request.Header.Set("Authorization", "Token "+token)
+	} else if username != "" {
+		request.SetBasicAuth(username, password)
+	}
+
+	response, err := decodeJsonFromRequest[ntopngActiveHostsResponseJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.RC != 0 {
+		return nil, fmt.Errorf("ntopng returned an error: %s", response.RCStr)
+	}
+
+	clients := make([]networkUsageClient, 0, len(response.Rsp.Data))
+
+	for _, host := range response.Rsp.Data {
+		clients = append(clients, networkUsageClient{
+			Address:       host.IP,
+			MAC:           host.MAC,
+			Name:          ternary(host.Name != host.IP, host.Name, ""),
+			BytesSent:     host.Bytes.Sent,
+			BytesReceived: host.Bytes.Received,
+			FirstSeen:     time.Unix(host.FirstSeen, 0),
+			LastSeen:      time.Unix(host.LastSeen, 0),
+		})
+	}
+
+	return clients, nil
+}
+
+type opnsenseInsightTopResponseJson []struct {
+	SourceAddress      string `json:"src_addr"`
+	DestinationAddress string `json:"dst_addr"`
+	Total              uint64 `json:"total"`
+	LastSeen           int64  `json:"last_seen"`
+}
+
+// Uses the netflow data collected by OPNsense's Insight, which has to be enabled
+// for the interfaces that the devices are on. The username and password are the
+// key and secret of an API key.
+func fetchOPNsenseClientUsage(client requestDoer, instanceURL, key, secret string) ([]networkUsageClient, error) {
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	fetchTop := func(provider, field string) (opnsenseInsightTopResponseJson, error) {
+		requestURL := fmt.Sprintf(
+			"%s/api/diagnostics/networkinsight/top/%s/%d/%d/%s/octets/100",
+			instanceURL, provider, midnight.Unix(), now.Unix(), field,
+		)
+
+		request, err := http.NewRequest("GET", requestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		request.SetBasicAuth(key, secret)
+
+		return decodeJsonFromRequest[opnsenseInsightTopResponseJson](client, request)
+	}
+
+	sent, err := fetchTop("FlowSourceAddrTotals", "src_addr")
+	if err != nil {
+		return nil, err
+	}
+
+	received, err := fetchTop("FlowDstAddrTotals", "dst_addr")
+	if err != nil {
+		return nil, err
+	}
+
+	byAddress := make(map[string]*networkUsageClient)
+
+	get := func(address string) *networkUsageClient {
+		if _, exists := byAddress[address]; !exists {
+			byAddress[address] = &networkUsageClient{Address: address}
+		}
+
+		return byAddress[address]
+	}
+
+	for _, entry := range sent {
+		c := get(entry.SourceAddress)
+		c.BytesSent += entry.Total
+		c.LastSeen = time.Unix(max(c.LastSeen.Unix(), entry.LastSeen), 0)
+	}
+
+	for _, entry := range received {
+		c := get(entry.DestinationAddress)
+		c.BytesReceived += entry.Total
+		c.LastSeen = time.Unix(max(c.LastSeen.Unix(), entry.LastSeen), 0)
+	}
+
+	clients := make([]networkUsageClient, 0, len(byAddress))
+	for _, c := range byAddress {
+		clients = append(clients, *c)
+	}
+
+	return clients, nil
+}
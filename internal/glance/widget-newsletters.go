@@ -0,0 +1,389 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glanceapp/glance/pkg/readability"
+	"golang.org/x/net/html/charset"
+)
+
+var newslettersWidgetTemplate = mustParseTemplate("newsletters.html", "widget-base.html")
+
+var mailWordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+type newslettersWidget struct {
+	widgetBase    `yaml:",inline"`
+	Feeds         []string                     `yaml:"feeds"`
+	IMAP          *newslettersIMAP             `yaml:"imap"`
+	Limit         int                          `yaml:"limit"`
+	CollapseAfter int                          `yaml:"collapse-after"`
+	Issues        []newsletterIssue            `yaml:"-"`
+	articles      map[string]newsletterArticle `yaml:"-"`
+	articlesMutex sync.Mutex                   `yaml:"-"`
+}
+
+type newslettersIMAP struct {
+	Host          string `yaml:"host"`
+	Port          int    `yaml:"port"`
+	Username      string `yaml:"username"`
+	Password      string `yaml:"password"`
+	Folder        string `yaml:"folder"`
+	AllowInsecure bool   `yaml:"allow-insecure"`
+}
+
+type newsletterIssue struct {
+	Title       string
+	Sender      string
+	Excerpt     string
+	ReaderURL   string
+	PublishedAt time.Time
+	key         string
+	content     string
+	articleURL  string
+}
+
+type newsletterArticle struct {
+	article    *readability.Article
+	articleURL string
+}
+
+func (widget *newslettersWidget) initialize() error {
+	widget.withTitle("Newsletters").withCacheDuration(30 * time.Minute)
+
+	if len(widget.Feeds) == 0 && widget.IMAP == nil {
+		return errors.New("either feeds or imap must be specified")
+	}
+
+	if widget.IMAP != nil {
+		if widget.IMAP.Host == "" || widget.IMAP.Username == "" || widget.IMAP.Password == "" {
+			return errors.New("imap: host, username and password are required")
+		}
+
+		if widget.IMAP.Port == 0 {
+			widget.IMAP.Port = 993
+		}
+
+		if widget.IMAP.Folder == "" {
+			widget.IMAP.Folder = "INBOX"
+		}
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 10
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *newslettersWidget) update(ctx context.Context) {
+	issues, err := widget.fetchIssues()
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].PublishedAt.After(issues[j].PublishedAt)
+	})
+
+	if len(issues) > widget.Limit {
+		issues = issues[:widget.Limit]
+	}
+
+	articles := make(map[string]newsletterArticle, len(issues))
+
+	for i := range issues {
+		issue := &issues[i]
+
+		var base *url.URL
+		if issue.articleURL != "" {
+			base, _ = url.Parse(issue.articleURL)
+		}
+
+		article, err := readability.Extract(strings.NewReader(issue.content), base)
+		if err != nil {
+			widget.logger().Warn("Failed to extract newsletter content", "title", issue.Title, "error", err)
+		} else {
+			issue.Excerpt, _ = limitStringLength(article.Excerpt, 200)
+			if article.Title == "" {
+				article.Title = issue.Title
+			}
+
+			if article.Byline == "" {
+				article.Byline = issue.Sender
+			}
+		}
+
+		// the content is no longer needed once extracted
+		issue.content = ""
+		issue.ReaderURL = widget.Providers.readerItemURLResolver(widget.GetID(), issue.key)
+		articles[issue.key] = newsletterArticle{article: article, articleURL: issue.articleURL}
+	}
+
+	widget.articlesMutex.Lock()
+	widget.articles = articles
+	widget.articlesMutex.Unlock()
+
+	widget.Issues = issues
+}
+
+func (widget *newslettersWidget) readerViewArticle(key string) (*readability.Article, string, bool) {
+	widget.articlesMutex.Lock()
+	defer widget.articlesMutex.Unlock()
+
+	entry, ok := widget.articles[key]
+	return entry.article, entry.articleURL, ok
+}
+
+func (widget *newslettersWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, newslettersWidgetTemplate)
+}
+
+func newsletterIssueKey(id string) string {
+	hash := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(hash[:8])
+}
+
+func (widget *newslettersWidget) fetchIssues() ([]newsletterIssue, error) {
+	var issues []newsletterIssue
+	var failed, total int
+	var lastErr error
+
+	for _, feedURL := range widget.Feeds {
+		total++
+
+		feedIssues, err := fetchNewsletterFeed(widget.httpClient(false), feedURL)
+		if err != nil {
+			failed++
+			lastErr = err
+			widget.logger().Error("Failed to fetch newsletter feed", "url", feedURL, "error", err)
+			continue
+		}
+
+		issues = append(issues, feedIssues...)
+	}
+
+	if widget.IMAP != nil {
+		total++
+
+		mailIssues, err := widget.IMAP.fetch(widget.Limit)
+		if err != nil {
+			failed++
+			lastErr = err
+			widget.logger().Error("Failed to fetch newsletters over IMAP", "host", widget.IMAP.Host, "error", err)
+		}
+
+		issues = append(issues, mailIssues...)
+	}
+
+	if failed == total {
+		return nil, fmt.Errorf("%w: %v", errNoContent, lastErr)
+	}
+
+	if failed > 0 {
+		return issues, fmt.Errorf("%w: could not fetch %d sources", errPartialContent, failed)
+	}
+
+	return issues, nil
+}
+
+func fetchNewsletterFeed(client requestDoer, feedURL string) ([]newsletterIssue, error) {
+	request, err := http.NewRequest("GET", feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", response.StatusCode, feedURL)
+	}
+
+	feed, err := feedParser.Parse(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]newsletterIssue, 0, len(feed.Items))
+
+	for _, item := range feed.Items {
+		issue := newsletterIssue{
+			Title:      item.Title,
+			Sender:     feed.Title,
+			key:        newsletterIssueKey(feedURL + item.GUID + item.Link),
+			content:    ternary(item.Content != "", item.Content, item.Description),
+			articleURL: item.Link,
+		}
+
+		if item.Author != nil && item.Author.Name != "" {
+			issue.Sender = item.Author.Name
+		}
+
+		if item.PublishedParsed != nil {
+			issue.PublishedAt = *item.PublishedParsed
+		} else if item.UpdatedParsed != nil {
+			issue.PublishedAt = *item.UpdatedParsed
+		}
+
+		issues = append(issues, issue)
+	}
+
+	return issues, nil
+}
+
+func (source *newslettersIMAP) fetch(limit int) ([]newsletterIssue, error) {
+	messages, err := fetchLatestIMAPMessages(
+		source.Host, source.Port, source.AllowInsecure,
+		source.Username, source.Password, source.Folder, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]newsletterIssue, 0, len(messages))
+
+	for i := range messages {
+		issue, err := parseNewsletterEmail(messages[i].body)
+		if err != nil {
+			slog.Warn("Failed to parse newsletter email", "uid", messages[i].uid, "error", err)
+			continue
+		}
+
+		issue.key = newsletterIssueKey(source.Host + source.Folder + messages[i].uid)
+		issues = append(issues, issue)
+	}
+
+	return issues, nil
+}
+
+func parseNewsletterEmail(raw []byte) (newsletterIssue, error) {
+	var issue newsletterIssue
+
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return issue, err
+	}
+
+	issue.Title, err = mailWordDecoder.DecodeHeader(message.Header.Get("Subject"))
+	if err != nil {
+		issue.Title = message.Header.Get("Subject")
+	}
+
+	mailParser := mail.AddressParser{WordDecoder: mailWordDecoder}
+	if from, err := mailParser.Parse(message.Header.Get("From")); err == nil {
+		issue.Sender = ternary(from.Name != "", from.Name, from.Address)
+	}
+
+	if date, err := message.Header.Date(); err == nil {
+		issue.PublishedAt = date
+	}
+
+	htmlBody, textBody, err := findEmailBodies(message.Header.Get("Content-Type"), message.Header.Get("Content-Transfer-Encoding"), message.Body)
+	if err != nil {
+		return issue, err
+	}
+
+	if htmlBody != "" {
+		issue.content = htmlBody
+	} else {
+		issue.content = "<div><p>" + strings.ReplaceAll(html.EscapeString(textBody), "\n\n", "</p><p>") + "</p></div>"
+	}
+
+	return issue, nil
+}
+
+// Walks the parts of the email and returns the first HTML and plain text bodies
+// found, decoded to UTF-8
+func findEmailBodies(contentType, transferEncoding string, body io.Reader) (string, string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var htmlBody, textBody string
+		reader := multipart.NewReader(body, params["boundary"])
+
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return htmlBody, textBody, err
+			}
+
+			partHTML, partText, err := findEmailBodies(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				continue
+			}
+
+			if htmlBody == "" {
+				htmlBody = partHTML
+			}
+
+			if textBody == "" {
+				textBody = partText
+			}
+		}
+
+		return htmlBody, textBody, nil
+	}
+
+	if mediaType != "text/html" && mediaType != "text/plain" {
+		return "", "", nil
+	}
+
+	switch strings.ToLower(transferEncoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		// the decoder ignores the line breaks that base64 encoded parts are split with
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	if label := params["charset"]; label != "" {
+		if decoded, err := charset.NewReaderLabel(label, body); err == nil {
+			body = decoded
+		}
+	}
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return "", "", err
+	}
+
+	if mediaType == "text/html" {
+		return string(content), "", nil
+	}
+
+	return "", string(content), nil
+}
@@ -0,0 +1,283 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var nightscoutWidgetTemplate = mustParseTemplate("nightscout.html", "widget-base.html")
+
+const (
+	nightscoutGraphWidth  = 200
+	nightscoutGraphHeight = 60
+	mgdlPerMmol           = 18.0182
+)
+
+type nightscoutWidget struct {
+	widgetBase    `yaml:",inline"`
+	URL           string          `yaml:"url"`
+	Token         string          `yaml:"token"`
+	Units         string          `yaml:"units"`
+	UrgentLow     float64         `yaml:"urgent-low"`
+	TargetLow     float64         `yaml:"target-low"`
+	TargetHigh    float64         `yaml:"target-high"`
+	UrgentHigh    float64         `yaml:"urgent-high"`
+	GraphDuration durationField   `yaml:"graph-duration"`
+	Reading       *glucoseReading `yaml:"-"`
+	Graph         glucoseGraph    `yaml:"-"`
+	// thresholds converted to mg/dL, which is what Nightscout always stores values in
+	thresholds glucoseThresholds `yaml:"-"`
+}
+
+type glucoseThresholds struct {
+	urgentLow  float64
+	targetLow  float64
+	targetHigh float64
+	urgentHigh float64
+}
+
+func (widget *nightscoutWidget) initialize() error {
+	widget.withTitle("Glucose").withCacheDuration(5 * time.Minute)
+
+	if widget.URL == "" {
+		return errors.New("url is required")
+	}
+
+	widget.URL = strings.TrimRight(widget.URL, "/")
+	widget.withTitleURL(widget.URL)
+
+	if widget.Units == "" {
+		widget.Units = "mg/dl"
+	} else if widget.Units != "mg/dl" && widget.Units != "mmol" {
+		return errors.New("units must be either mg/dl or mmol")
+	}
+
+	toMgdl := func(value float64, defaultMgdl float64) float64 {
+		if value == 0 {
+			return defaultMgdl
+		}
+
+		return ternary(widget.Units == "mmol", value*mgdlPerMmol, value)
+	}
+
+	widget.thresholds = glucoseThresholds{
+		urgentLow:  toMgdl(widget.UrgentLow, 55),
+		targetLow:  toMgdl(widget.TargetLow, 70),
+		targetHigh: toMgdl(widget.TargetHigh, 180),
+		urgentHigh: toMgdl(widget.UrgentHigh, 250),
+	}
+
+	t := widget.thresholds
+	if !(t.urgentLow <= t.targetLow && t.targetLow < t.targetHigh && t.targetHigh <= t.urgentHigh) {
+		return errors.New("thresholds must be in the order urgent-low, target-low, target-high, urgent-high")
+	}
+
+	if widget.GraphDuration == 0 {
+		widget.GraphDuration = durationField(3 * time.Hour)
+	}
+
+	return nil
+}
+
+func (widget *nightscoutWidget) update(ctx context.Context) {
+	readings, err := fetchNightscoutReadings(widget.httpClient(false), widget.URL, widget.Token, time.Duration(widget.GraphDuration))
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	current := &readings[0]
+	reading := &glucoseReading{
+		Value:     widget.formatGlucose(current.sgv),
+		Trend:     nightscoutDirectionToArrow(current.direction),
+		Range:     widget.thresholds.rangeOf(current.sgv),
+		Time:      current.time,
+		IsStale:   time.Since(current.time) > 15*time.Minute,
+		UnitLabel: ternary(widget.Units == "mmol", "mmol/L", "mg/dL"),
+	}
+
+	// only show the change when the previous reading is recent enough for it to be meaningful
+	if len(readings) > 1 && current.time.Sub(readings[1].time) <= 10*time.Minute {
+		delta := current.sgv - readings[1].sgv
+		reading.Delta = ternary(delta >= 0, "+", "-") + widget.formatGlucose(math.Abs(delta))
+	}
+
+	widget.Reading = reading
+	widget.Graph = widget.createGraph(readings)
+}
+
+func (widget *nightscoutWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, nightscoutWidgetTemplate)
+}
+
+type glucoseReading struct {
+	Value     string
+	Delta     string
+	Trend     string
+	Range     string
+	UnitLabel string
+	Time      time.Time
+	IsStale   bool
+}
+
+type glucoseGraph struct {
+	Width      int
+	Height     int
+	TargetTop  float64
+	TargetSize float64
+	Points     []glucoseGraphPoint
+}
+
+type glucoseGraphPoint struct {
+	X     float64
+	Y     float64
+	Range string
+}
+
+func (t glucoseThresholds) rangeOf(sgv float64) string {
+	switch {
+	case sgv <= t.urgentLow:
+		return "urgent-low"
+	case sgv < t.targetLow:
+		return "low"
+	case sgv >= t.urgentHigh:
+		return "urgent-high"
+	case sgv > t.targetHigh:
+		return "high"
+	default:
+		return "in-range"
+	}
+}
+
+func (widget *nightscoutWidget) formatGlucose(mgdl float64) string {
+	if widget.Units == "mmol" {
+		return strconv.FormatFloat(mgdl/mgdlPerMmol, 'f', 1, 64)
+	}
+
+	return strconv.Itoa(int(math.Round(mgdl)))
+}
+
+func (widget *nightscoutWidget) createGraph(readings []nightscoutReading) glucoseGraph {
+	graph := glucoseGraph{
+		Width:  nightscoutGraphWidth,
+		Height: nightscoutGraphHeight,
+	}
+
+	// keep the scale fixed unless a reading falls outside of it so that
+	// the graph doesn't look dramatic when values barely change
+	minValue := math.Min(40, widget.thresholds.urgentLow)
+	maxValue := math.Max(300, widget.thresholds.urgentHigh)
+
+	for i := range readings {
+		minValue = math.Min(minValue, readings[i].sgv)
+		maxValue = math.Max(maxValue, readings[i].sgv)
+	}
+
+	toY := func(sgv float64) float64 {
+		return (maxValue - sgv) / (maxValue - minValue) * nightscoutGraphHeight
+	}
+
+	graph.TargetTop = toY(widget.thresholds.targetHigh)
+	graph.TargetSize = toY(widget.thresholds.targetLow) - graph.TargetTop
+
+	end := time.Now()
+	duration := time.Duration(widget.GraphDuration)
+	start := end.Add(-duration)
+
+	graph.Points = make([]glucoseGraphPoint, 0, len(readings))
+
+	for i := range readings {
+		if readings[i].time.Before(start) {
+			continue
+		}
+
+		graph.Points = append(graph.Points, glucoseGraphPoint{
+			X:     float64(readings[i].time.Sub(start)) / float64(duration) * nightscoutGraphWidth,
+			Y:     toY(readings[i].sgv),
+			Range: widget.thresholds.rangeOf(readings[i].sgv),
+		})
+	}
+
+	return graph
+}
+
+func nightscoutDirectionToArrow(direction string) string {
+	switch direction {
+	case "DoubleUp":
+		return "⇈"
+	case "SingleUp":
+		return "↑"
+	case "FortyFiveUp":
+		return "↗"
+	case "Flat":
+		return "→"
+	case "FortyFiveDown":
+		return "↘"
+	case "SingleDown":
+		return "↓"
+	case "DoubleDown":
+		return "⇊"
+	default:
+		return ""
+	}
+}
+
+type nightscoutEntryJson struct {
+	Type      string  `json:"type"`
+	SGV       float64 `json:"sgv"`
+	Date      int64   `json:"date"`
+	Direction string  `json:"direction"`
+}
+
+type nightscoutReading struct {
+	sgv       float64
+	direction string
+	time      time.Time
+}
+
+func fetchNightscoutReadings(client requestDoer, instanceURL string, token string, duration time.Duration) ([]nightscoutReading, error) {
+	query := url.Values{}
+	// readings are normally sent every 5 minutes, leave some room for sensors that send them more often
+	query.Set("count", strconv.Itoa(int(duration/time.Minute)+1))
+	query.Set("find[date][$gte]", strconv.FormatInt(time.Now().Add(-duration).UnixMilli(), 10))
+
+	if token != "" {
+		query.Set("token", token)
+	}
+
+	request, _ := http.NewRequest("GET", instanceURL+"/api/v1/entries/sgv.json?"+query.Encode(), nil)
+	request.Header.Set("Accept", "application/json")
+
+	entries, err := decodeJsonFromRequest[[]nightscoutEntryJson](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	readings := make([]nightscoutReading, 0, len(entries))
+
+	for i := range entries {
+		if entries[i].SGV <= 0 {
+			continue
+		}
+
+		readings = append(readings, nightscoutReading{
+			sgv:       entries[i].SGV,
+			direction: entries[i].Direction,
+			time:      time.UnixMilli(entries[i].Date),
+		})
+	}
+
+	if len(readings) == 0 {
+		return nil, fmt.Errorf("%w: no readings within the last %s", errNoContent, duration)
+	}
+
+	return readings, nil
+}
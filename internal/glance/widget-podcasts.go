@@ -0,0 +1,219 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var podcastsWidgetTemplate = mustParseTemplate("podcasts.html", "widget-base.html")
+
+type podcastsWidget struct {
+	widgetBase    `yaml:",inline"`
+	FeedRequests  []podcastFeedRequest `yaml:"feeds"`
+	Limit         int                  `yaml:"limit"`
+	CollapseAfter int                  `yaml:"collapse-after"`
+	Episodes      []podcastEpisode     `yaml:"-"`
+}
+
+type podcastFeedRequest struct {
+	URL   string `yaml:"url"`
+	Title string `yaml:"title"`
+}
+
+func (widget *podcastsWidget) initialize() error {
+	widget.withTitle("Podcasts").withCacheDuration(2 * time.Hour)
+
+	if len(widget.FeedRequests) == 0 {
+		return errors.New("no feeds specified")
+	}
+
+	for i := range widget.FeedRequests {
+		if widget.FeedRequests[i].URL == "" {
+			return fmt.Errorf("feed %d has no url", i+1)
+		}
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 10
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *podcastsWidget) update(ctx context.Context) {
+	episodes, err := fetchPodcastEpisodes(widget.cachedHTTPClient(false), widget.FeedRequests)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	if len(episodes) > widget.Limit {
+		episodes = episodes[:widget.Limit]
+	}
+
+	widget.Episodes = episodes
+}
+
+func (widget *podcastsWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, podcastsWidgetTemplate)
+}
+
+type podcastEpisode struct {
+	Title       string
+	Link        string
+	Show        string
+	ShowURL     string
+	ImageURL    string
+	AudioURL    string
+	AudioType   string
+	Duration    string
+	PublishedAt time.Time
+}
+
+// itunes:duration can either be a number of seconds or in the form of [HH:]MM:SS
+func parsePodcastDuration(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	var seconds int
+
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+
+		seconds = seconds*60 + n
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+func fetchPodcastEpisodesTask(client requestDoer, request podcastFeedRequest) ([]podcastEpisode, error) {
+	req, err := http.NewRequest("GET", request.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, request.URL)
+	}
+
+	feed, err := feedParser.Parse(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	show := ternary(request.Title != "", request.Title, feed.Title)
+
+	var showImage string
+	if feed.ITunesExt != nil && feed.ITunesExt.Image != "" {
+		showImage = feed.ITunesExt.Image
+	} else if feed.Image != nil {
+		showImage = feed.Image.URL
+	}
+
+	episodes := make([]podcastEpisode, 0, len(feed.Items))
+
+	for _, item := range feed.Items {
+		episode := podcastEpisode{
+			Title:    html.UnescapeString(item.Title),
+			Link:     item.Link,
+			Show:     show,
+			ShowURL:  feed.Link,
+			ImageURL: showImage,
+		}
+
+		for _, enclosure := range item.Enclosures {
+			if strings.HasPrefix(enclosure.Type, "audio/") || episode.AudioURL == "" {
+				episode.AudioURL = enclosure.URL
+				episode.AudioType = enclosure.Type
+			}
+		}
+
+		// the episode is of little use without something to listen to
+		if episode.AudioURL == "" {
+			continue
+		}
+
+		if item.ITunesExt != nil {
+			episode.Duration = formatDuration(parsePodcastDuration(item.ITunesExt.Duration))
+
+			if item.ITunesExt.Image != "" {
+				episode.ImageURL = item.ITunesExt.Image
+			}
+		}
+
+		if item.PublishedParsed != nil {
+			episode.PublishedAt = *item.PublishedParsed
+		} else {
+			episode.PublishedAt = time.Now()
+		}
+
+		if episode.Link == "" {
+			episode.Link = episode.AudioURL
+		}
+
+		episodes = append(episodes, episode)
+	}
+
+	return episodes, nil
+}
+
+func fetchPodcastEpisodes(client requestDoer, requests []podcastFeedRequest) ([]podcastEpisode, error) {
+	job := newJob(func(request podcastFeedRequest) ([]podcastEpisode, error) {
+		return fetchPodcastEpisodesTask(client, request)
+	}, requests).withWorkers(20)
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	episodes := make([]podcastEpisode, 0, len(requests)*10)
+	var failed int
+
+	for i := range results {
+		if errs[i] != nil {
+			failed++
+			slog.Error("Failed to get podcast feed", "url", requests[i].URL, "error", errs[i])
+			continue
+		}
+
+		episodes = append(episodes, results[i]...)
+	}
+
+	if failed == len(requests) {
+		return nil, errNoContent
+	}
+
+	sort.Slice(episodes, func(i, j int) bool {
+		return episodes[i].PublishedAt.After(episodes[j].PublishedAt)
+	})
+
+	if failed > 0 {
+		return episodes, fmt.Errorf("%w: missing %d feeds", errPartialContent, failed)
+	}
+
+	return episodes, nil
+}
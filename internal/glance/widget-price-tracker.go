@@ -0,0 +1,423 @@
+package glance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var priceTrackerWidgetTemplate = mustParseTemplate("price-tracker.html", "widget-base.html")
+
+// price history older than this is discarded
+const priceTrackerRetention = 365 * 24 * time.Hour
+
+type priceTrackerWidget struct {
+	widgetBase `yaml:",inline"`
+	Products   []priceTrackerProduct `yaml:"products"`
+	Notify     []string              `yaml:"notify"`
+	stateMutex sync.Mutex            `yaml:"-"`
+}
+
+type priceTrackerProduct struct {
+	Name        string            `yaml:"name"`
+	URL         string            `yaml:"url"`
+	Selector    string            `yaml:"selector"`
+	Currency    string            `yaml:"currency"`
+	TargetPrice float64           `yaml:"target-price"`
+	Headers     map[string]string `yaml:"headers"`
+	Price       float64           `yaml:"-"`
+	Lowest      float64           `yaml:"-"`
+	Highest     float64           `yaml:"-"`
+	Previous    float64           `yaml:"-"`
+	ChangedAt   time.Time         `yaml:"-"`
+	Chart       string            `yaml:"-"`
+	Error       bool              `yaml:"-"`
+}
+
+type priceTrackerState struct {
+	// pairs of unix timestamps and prices, only recorded when the price changes
+	History [][2]float64 `json:"history"`
+	// the price that the last notification about going below the target was sent for
+	NotifiedPrice float64 `json:"notified_price,omitempty"`
+}
+
+func (widget *priceTrackerWidget) initialize() error {
+	widget.withTitle("Price Tracker").withCacheDuration(6 * time.Hour)
+
+	if len(widget.Products) == 0 {
+		return errors.New("no products specified")
+	}
+
+	for i := range widget.Products {
+		product := &widget.Products[i]
+
+		if product.URL == "" {
+			return fmt.Errorf("product %d: url is required", i+1)
+		}
+
+		if product.Name == "" {
+			if parsed, err := url.Parse(product.URL); err == nil {
+				product.Name = parsed.Hostname()
+			}
+		}
+	}
+
+	return nil
+}
+
+func (widget *priceTrackerWidget) getNotificationTargets() []string {
+	return widget.Notify
+}
+
+func (widget *priceTrackerWidget) update(ctx context.Context) {
+	client := widget.httpClient(false)
+	job := newJob(func(product *priceTrackerProduct) (float64, error) {
+		return product.fetchPrice(client)
+	}, widget.productPointers()).withWorkers(len(widget.Products))
+
+	prices, errs, err := workerPoolDo(job)
+	if err != nil {
+		widget.canContinueUpdateAfterHandlingErr(fmt.Errorf("%w: %v", errNoContent, err))
+		return
+	}
+
+	var failed int
+
+	for i := range errs {
+		if errs[i] != nil {
+			failed++
+			widget.logger().Error("Failed to fetch price", "url", widget.Products[i].URL, "error", errs[i])
+		}
+	}
+
+	if failed == len(widget.Products) {
+		err = fmt.Errorf("%w: %v", errNoContent, errs[0])
+	} else if failed > 0 {
+		err = fmt.Errorf("%w: could not fetch the price of %d products", errPartialContent, failed)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	now := time.Now()
+
+	for i := range widget.Products {
+		widget.Products[i].Error = errs[i] != nil
+
+		if errs[i] == nil {
+			widget.recordPrice(&widget.Products[i], prices[i], now)
+		}
+	}
+}
+
+func (widget *priceTrackerWidget) productPointers() []*priceTrackerProduct {
+	products := make([]*priceTrackerProduct, len(widget.Products))
+	for i := range widget.Products {
+		products[i] = &widget.Products[i]
+	}
+
+	return products
+}
+
+func (widget *priceTrackerWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, priceTrackerWidgetTemplate)
+}
+
+// Scoped by the widget and the target price as well, otherwise the same
+// product in another widget or with a new target wouldn't be notified about
+func (widget *priceTrackerWidget) stateKey(product *priceTrackerProduct) string {
+	return "price-tracker:" + seenItemID(widget.stateScope()+"#"+product.URL+"#"+strconv.FormatFloat(product.TargetPrice, 'f', -1, 64))
+}
+
+// Adds the price to the product's history and sends a notification if it's
+// at or below the target price for the first time
+func (widget *priceTrackerWidget) recordPrice(product *priceTrackerProduct, price float64, now time.Time) {
+	widget.stateMutex.Lock()
+	defer widget.stateMutex.Unlock()
+
+	var state priceTrackerState
+	widget.Providers.state.get(widget.stateKey(product), &state)
+
+	if len(state.History) == 0 || state.History[len(state.History)-1][1] != price {
+		state.History = append(state.History, [2]float64{float64(now.Unix()), price})
+	}
+
+	// always keep the most recent change even if it's older than the retention
+	for len(state.History) > 1 && now.Sub(time.Unix(int64(state.History[0][0]), 0)) > priceTrackerRetention {
+		state.History = state.History[1:]
+	}
+
+	product.Price = price
+	product.Lowest, product.Highest = price, price
+	product.Previous = 0
+	product.ChangedAt = time.Unix(int64(state.History[len(state.History)-1][0]), 0)
+
+	for i := range state.History {
+		product.Lowest = min(product.Lowest, state.History[i][1])
+		product.Highest = max(product.Highest, state.History[i][1])
+	}
+
+	product.Chart = ""
+	if len(state.History) > 1 {
+		product.Previous = state.History[len(state.History)-2][1]
+		product.Chart = priceTrackerChartPoints(state.History, now, product.Lowest, product.Highest)
+	}
+
+	if product.TargetPrice > 0 {
+		if price > product.TargetPrice {
+			state.NotifiedPrice = 0
+		} else if state.NotifiedPrice != price && len(widget.Notify) > 0 {
+			delivered := widget.Providers.notifier.notify(widget.Notify, notification{
+				Title:   "Price drop: " + product.Name,
+				Message: "Now " + product.FormatPrice(price) + ", at or below the target of " + product.FormatPrice(product.TargetPrice),
+				URL:     product.URL,
+			})
+
+			// failed deliveries get retried on the next update
+			if delivered {
+				state.NotifiedPrice = price
+			}
+		}
+	}
+
+	widget.Providers.state.set(widget.stateKey(product), state)
+}
+
+// Returns the points of a stepped line going from the first recorded price up
+// until now, scaled between the lowest and highest price since the differences
+// are usually small
+func priceTrackerChartPoints(history [][2]float64, now time.Time, lowest, highest float64) string {
+	const width, height = 100, 50
+
+	start := history[0][0]
+	duration := max(float64(now.Unix())-start, 1)
+
+	x := func(t float64) float64 {
+		return (t - start) / duration * width
+	}
+
+	y := func(price float64) float64 {
+		if highest == lowest {
+			return height / 2
+		}
+
+		return height - (price-lowest)/(highest-lowest)*height*0.9 - height*0.05
+	}
+
+	coordinates := make([]string, 0, len(history)*2+1)
+
+	for i := range history {
+		if i > 0 {
+			coordinates = append(coordinates, fmt.Sprintf("%.2f,%.2f", x(history[i][0]), y(history[i-1][1])))
+		}
+
+		coordinates = append(coordinates, fmt.Sprintf("%.2f,%.2f", x(history[i][0]), y(history[i][1])))
+	}
+
+	coordinates = append(coordinates, fmt.Sprintf("%d,%.2f", width, y(history[len(history)-1][1])))
+
+	return strings.Join(coordinates, " ")
+}
+
+func (product *priceTrackerProduct) BelowTarget() bool {
+	return product.TargetPrice > 0 && product.Price > 0 && product.Price <= product.TargetPrice
+}
+
+func (product *priceTrackerProduct) FormatPrice(price float64) string {
+	formatted := strconv.FormatFloat(price, 'f', 2, 64)
+	formatted = strings.TrimSuffix(formatted, ".00")
+
+	if product.Currency == "" {
+		return formatted
+	}
+
+	if symbol, ok := currencyToSymbol[strings.ToUpper(product.Currency)]; ok {
+		return symbol + formatted
+	}
+
+	return formatted + " " + product.Currency
+}
+
+func (product *priceTrackerProduct) fetchPrice(client requestDoer) (float64, error) {
+	request, err := http.NewRequest("GET", product.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	setBrowserUserAgentHeader(request)
+	for key, value := range product.Headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status code %d from %s", response.StatusCode, product.URL)
+	}
+
+	document, err := goquery.NewDocumentFromReader(response.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if product.Selector != "" {
+		element := document.Find(product.Selector).First()
+		if element.Length() == 0 {
+			return 0, fmt.Errorf("no elements matched %s", product.Selector)
+		}
+
+		text, _ := element.Attr("content")
+		if text == "" {
+			text = element.Text()
+		}
+
+		price, ok := parsePrice(text)
+		if !ok {
+			return 0, fmt.Errorf("could not parse price from %q", limitedSelectionText(element))
+		}
+
+		return price, nil
+	}
+
+	price, currency, ok := extractSchemaOrgPrice(document)
+	if !ok {
+		return 0, errors.New("could not find a price in the page's metadata, try specifying a selector")
+	}
+
+	if product.Currency == "" {
+		product.Currency = currency
+	}
+
+	return price, nil
+}
+
+// Looks for the price of a product in its JSON-LD or microdata schema.org
+// metadata, falling back to the Open Graph product tags
+func extractSchemaOrgPrice(document *goquery.Document) (float64, string, bool) {
+	var price float64
+	var currency string
+	var found bool
+
+	document.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, script *goquery.Selection) bool {
+		var data any
+		if err := json.Unmarshal([]byte(script.Text()), &data); err != nil {
+			return true
+		}
+
+		price, currency, found = findJSONLDProductPrice(data)
+		return !found
+	})
+
+	if found {
+		return price, currency, true
+	}
+
+	if element := document.Find(`[itemprop="price"]`).First(); element.Length() > 0 {
+		text, _ := element.Attr("content")
+		if text == "" {
+			text = element.Text()
+		}
+
+		if price, ok := parsePrice(text); ok {
+			currency, _ = document.Find(`[itemprop="priceCurrency"]`).First().Attr("content")
+			return price, currency, true
+		}
+	}
+
+	for _, property := range []string{"product:price:amount", "og:price:amount"} {
+		text, _ := document.Find(`meta[property="` + property + `"]`).First().Attr("content")
+		if price, ok := parsePrice(text); ok {
+			currency, _ = document.Find(`meta[property="` + strings.Replace(property, "amount", "currency", 1) + `"]`).First().Attr("content")
+			return price, currency, true
+		}
+	}
+
+	return 0, "", false
+}
+
+func findJSONLDProductPrice(data any) (float64, string, bool) {
+	switch value := data.(type) {
+	case []any:
+		for i := range value {
+			if price, currency, ok := findJSONLDProductPrice(value[i]); ok {
+				return price, currency, true
+			}
+		}
+	case map[string]any:
+		if graph, ok := value["@graph"]; ok {
+			return findJSONLDProductPrice(graph)
+		}
+
+		if !jsonLDHasType(value["@type"], "Product", "ProductGroup") {
+			return 0, "", false
+		}
+
+		return findJSONLDOfferPrice(value["offers"])
+	}
+
+	return 0, "", false
+}
+
+func findJSONLDOfferPrice(data any) (float64, string, bool) {
+	switch value := data.(type) {
+	case []any:
+		for i := range value {
+			if price, currency, ok := findJSONLDOfferPrice(value[i]); ok {
+				return price, currency, true
+			}
+		}
+	case map[string]any:
+		currency, _ := value["priceCurrency"].(string)
+
+		for _, key := range []string{"price", "lowPrice"} {
+			switch price := value[key].(type) {
+			case float64:
+				return price, currency, true
+			case string:
+				if parsed, ok := parsePrice(price); ok {
+					return parsed, currency, true
+				}
+			}
+		}
+
+		if specification, ok := value["priceSpecification"]; ok {
+			return findJSONLDOfferPrice(specification)
+		}
+	}
+
+	return 0, "", false
+}
+
+func jsonLDHasType(value any, types ...string) bool {
+	switch t := value.(type) {
+	case string:
+		for i := range types {
+			if t == types[i] || t == "http://schema.org/"+types[i] || t == "https://schema.org/"+types[i] {
+				return true
+			}
+		}
+	case []any:
+		for i := range t {
+			if jsonLDHasType(t[i], types...) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,180 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+var qrCodeWidgetTemplate = mustParseTemplate("qr-code.html", "widget-base.html")
+
+type qrCodeWidget struct {
+	widgetBase      `yaml:",inline"`
+	cachedHTML      template.HTML `yaml:"-"`
+	WiFi            *qrCodeWiFi   `yaml:"wifi"`
+	URL             string        `yaml:"url"`
+	Text            string        `yaml:"text"`
+	PublicIP        bool          `yaml:"public-ip"`
+	IPURL           string        `yaml:"ip-url"`
+	ErrorCorrection string        `yaml:"error-correction"`
+	Caption         string        `yaml:"caption"`
+	SVG             template.HTML `yaml:"-"`
+	ecl             qrErrorCorrection
+	captionTemplate string
+	// what the current code was generated from, to only generate a new one
+	// once the public IP changes
+	content string
+}
+
+type qrCodeWiFi struct {
+	SSID         string `yaml:"ssid"`
+	Password     string `yaml:"password"`
+	Security     string `yaml:"security"`
+	Hidden       bool   `yaml:"hidden"`
+	ShowPassword bool   `yaml:"show-password"`
+}
+
+func (widget *qrCodeWidget) initialize() error {
+	widget.withTitle("QR Code")
+
+	var err error
+	if widget.ecl, err = parseQRErrorCorrection(widget.ErrorCorrection); err != nil {
+		return err
+	}
+
+	sources := 0
+	for _, set := range []bool{widget.WiFi != nil, widget.URL != "", widget.Text != ""} {
+		if set {
+			sources++
+		}
+	}
+
+	if sources > 1 || sources == 0 && !widget.PublicIP {
+		return errors.New("exactly one of wifi, url, text or public-ip is required")
+	}
+
+	if widget.PublicIP {
+		if widget.WiFi != nil {
+			return errors.New("public-ip can't be used with wifi")
+		}
+
+		if widget.IPURL == "" {
+			widget.IPURL = "https://api.ipify.org"
+		}
+
+		if widget.URL == "" && widget.Text == "" {
+			widget.Text = "{ip}"
+		}
+
+		widget.captionTemplate = ternary(widget.Caption == "", widget.URL+widget.Text, widget.Caption)
+
+		widget.withCacheDuration(10 * time.Minute)
+		return nil
+	}
+
+	content := widget.URL + widget.Text
+
+	if widget.WiFi != nil {
+		if content, err = widget.WiFi.content(); err != nil {
+			return fmt.Errorf("wifi: %v", err)
+		}
+
+		if widget.Caption == "" {
+			widget.Caption = widget.WiFi.SSID
+			if widget.WiFi.ShowPassword && widget.WiFi.Password != "" {
+				widget.Caption += " · " + widget.WiFi.Password
+			}
+		}
+	} else if widget.Caption == "" {
+		widget.Caption = widget.URL
+	}
+
+	if err := widget.generate(content); err != nil {
+		return err
+	}
+
+	widget.withError(nil)
+	widget.cachedHTML = widget.renderTemplate(widget, qrCodeWidgetTemplate)
+
+	return nil
+}
+
+// Uses the format that phones recognize for joining a network
+func (wifi *qrCodeWiFi) content() (string, error) {
+	if wifi.SSID == "" {
+		return "", errors.New("ssid is required")
+	}
+
+	switch strings.ToUpper(wifi.Security) {
+	case "", "WPA", "WPA2", "WPA3":
+		wifi.Security = "WPA"
+	case "WEP":
+		wifi.Security = "WEP"
+	case "NONE", "NOPASS":
+		wifi.Security = "nopass"
+	default:
+		return "", errors.New("security must be one of WPA, WEP or none")
+	}
+
+	if wifi.Security == "nopass" && wifi.Password != "" {
+		return "", errors.New("password can't be set for a network without security")
+	}
+
+	if wifi.Security != "nopass" && wifi.Password == "" {
+		return "", errors.New("password is required")
+	}
+
+	escape := strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, `"`, `\"`, `:`, `\:`).Replace
+
+	content := "WIFI:T:" + wifi.Security + ";S:" + escape(wifi.SSID) + ";"
+	if wifi.Password != "" {
+		content += "P:" + escape(wifi.Password) + ";"
+	}
+	if wifi.Hidden {
+		content += "H:true;"
+	}
+
+	return content + ";", nil
+}
+
+func (widget *qrCodeWidget) generate(content string) error {
+	code, err := encodeQRCode([]byte(content), widget.ecl)
+	if err != nil {
+		return err
+	}
+
+	widget.SVG = template.HTML(code.svg())
+	widget.content = content
+
+	return nil
+}
+
+func (widget *qrCodeWidget) update(ctx context.Context) {
+	ip, err := fetchPublicIP(widget.httpClient(false), widget.IPURL)
+	if err != nil {
+		widget.withError(fmt.Errorf("getting public IP: %w", err)).scheduleEarlyUpdate()
+		return
+	}
+
+	content := strings.ReplaceAll(widget.URL+widget.Text, "{ip}", ip)
+	if content != widget.content {
+		err = widget.generate(content)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Caption = strings.ReplaceAll(widget.captionTemplate, "{ip}", ip)
+}
+
+func (widget *qrCodeWidget) Render() template.HTML {
+	if widget.cachedHTML != "" {
+		return widget.cachedHTML
+	}
+
+	return widget.renderTemplate(widget, qrCodeWidgetTemplate)
+}
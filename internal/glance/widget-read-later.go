@@ -0,0 +1,74 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"time"
+)
+
+var readLaterWidgetTemplate = mustParseTemplate("read-later.html", "widget-base.html")
+
+type readLaterWidget struct {
+	widgetBase    `yaml:",inline"`
+	Service       string          `yaml:"service"`
+	Limit         int             `yaml:"limit"`
+	CollapseAfter int             `yaml:"collapse-after"`
+	Items         []readLaterItem `yaml:"-"`
+}
+
+func (widget *readLaterWidget) initialize() error {
+	widget.withTitle("Read Later").withCacheDuration(10 * time.Minute)
+
+	if widget.Service == "" {
+		return errors.New("service is required")
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 10
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *readLaterWidget) getBookmarkServices() []string {
+	return []string{widget.Service}
+}
+
+func (widget *readLaterWidget) update(ctx context.Context) {
+	service := widget.Providers.bookmarkServices[widget.Service]
+	items, err := service.fetchUnreadItems(widget.httpClient(false), widget.Limit)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Items = items
+}
+
+func (widget *readLaterWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, readLaterWidgetTemplate)
+}
+
+type readLaterItemJson struct {
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	ReadURL     string    `json:"read_url"`
+	Domain      string    `json:"domain"`
+	ReadingTime int       `json:"reading_time,omitempty"`
+	AddedAt     time.Time `json:"added_at"`
+}
+
+func (widget *readLaterWidget) data() any {
+	items := make([]readLaterItemJson, 0, len(widget.Items))
+
+	for _, item := range widget.Items {
+		items = append(items, readLaterItemJson(item))
+	}
+
+	return items
+}
@@ -0,0 +1,311 @@
+package glance
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var recentLinksWidgetTemplate = mustParseTemplate("recent-links.html", "widget-base.html")
+
+type recentLinksWidget struct {
+	widgetBase `yaml:",inline"`
+	Service    string `yaml:"service"`
+	// Shaarli only
+	URL       string `yaml:"url"`
+	APISecret string `yaml:"api-secret"`
+	// Pinboard only, the token is needed for private links, public links
+	// only need the user
+	Token          string       `yaml:"token"`
+	User           string       `yaml:"user"`
+	Tags           []string     `yaml:"tags"`
+	IncludePrivate bool         `yaml:"include-private"`
+	Limit          int          `yaml:"limit"`
+	CollapseAfter  int          `yaml:"collapse-after"`
+	Links          []recentLink `yaml:"-"`
+}
+
+type recentLink struct {
+	Title       string
+	URL         string
+	Domain      string
+	Description string
+	Tags        []string
+	Private     bool
+	AddedAt     time.Time
+}
+
+func (widget *recentLinksWidget) initialize() error {
+	widget.withTitle("Recent Links").withCacheDuration(30 * time.Minute)
+
+	switch widget.Service {
+	case "shaarli":
+		if widget.URL == "" {
+			return errors.New("url is required for shaarli")
+		}
+
+		if widget.APISecret == "" {
+			return errors.New("api-secret is required for shaarli")
+		}
+
+		widget.URL = strings.TrimRight(widget.URL, "/")
+	case "pinboard":
+		if widget.Token == "" && widget.User == "" {
+			return errors.New("either token or user is required for pinboard")
+		}
+
+		if widget.IncludePrivate && widget.Token == "" {
+			return errors.New("include-private requires a token for pinboard")
+		}
+
+		// Pinboard only allows filtering by up to 3 tags at a time
+		if len(widget.Tags) > 3 {
+			return errors.New("pinboard only supports filtering by up to 3 tags")
+		}
+	default:
+		return errors.New("service must be either shaarli or pinboard")
+	}
+
+	for i := range widget.Tags {
+		widget.Tags[i] = strings.TrimSpace(widget.Tags[i])
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 10
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *recentLinksWidget) update(ctx context.Context) {
+	var links []recentLink
+	var err error
+
+	client := widget.httpClient(false)
+
+	if widget.Service == "shaarli" {
+		links, err = fetchShaarliLinks(client, widget.URL, widget.APISecret, widget.Tags, widget.IncludePrivate, widget.Limit)
+	} else if widget.Token != "" {
+		links, err = fetchPinboardLinks(client, widget.Token, widget.Tags, widget.IncludePrivate, widget.Limit)
+	} else {
+		links, err = fetchPinboardPublicLinks(client, widget.User, widget.Tags, widget.Limit)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Links = links
+}
+
+func (widget *recentLinksWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, recentLinksWidgetTemplate)
+}
+
+type shaarliLinksResponseJson []struct {
+	URL         string   `json:"url"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Private     bool     `json:"private"`
+	Created     string   `json:"created"`
+}
+
+// Shaarli authenticates API requests with a JWT signed using the API secret
+// found on its settings page, which is only valid for a few minutes so a new
+// one gets created for every request
+func shaarliJWT(secret string) string {
+	encoding := base64.RawURLEncoding
+	header := encoding.EncodeToString([]byte(`{"typ":"JWT","alg":"HS512"}`))
+	payload := encoding.EncodeToString([]byte(`{"iat":` + strconv.FormatInt(time.Now().Unix(), 10) + `}`))
+
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write([]byte(header + "." + payload))
+
+	return header + "." + payload + "." + encoding.EncodeToString(mac.Sum(nil))
+}
+
+func fetchShaarliLinks(client requestDoer, instanceURL, secret string, tags []string, includePrivate bool, limit int) ([]recentLink, error) {
+	query := url.Values{
+		"limit":      {strconv.Itoa(limit)},
+		"visibility": {ternary(includePrivate, "all", "public")},
+	}
+
+	if len(tags) > 0 {
+		query.Set("searchtags", strings.Join(tags, " "))
+	}
+
+	request, err := http.NewRequest("GET", instanceURL+"/api/v1/links?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+shaarliJWT(secret))
+
+	response, err := decodeJsonFromRequest[shaarliLinksResponseJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]recentLink, 0, len(response))
+	for _, link := range response {
+		links = append(links, recentLink{
+			Title:       ternary(link.Title != "", link.Title, link.URL),
+			URL:         link.URL,
+			Domain:      extractDomainFromUrl(link.URL),
+			Description: link.Description,
+			Tags:        link.Tags,
+			Private:     link.Private,
+			AddedAt:     parseRFC3339Time(link.Created),
+		})
+	}
+
+	return links, nil
+}
+
+type pinboardRecentResponseJson struct {
+	Posts []struct {
+		Href        string `json:"href"`
+		Description string `json:"description"`
+		Extended    string `json:"extended"`
+		Tags        string `json:"tags"`
+		Time        string `json:"time"`
+		Shared      string `json:"shared"`
+	} `json:"posts"`
+}
+
+// The recent posts endpoint returns at most 100 posts, private ones are
+// filtered out here since the API doesn't have a way of excluding them
+func fetchPinboardLinks(client requestDoer, token string, tags []string, includePrivate bool, limit int) ([]recentLink, error) {
+	query := url.Values{
+		"auth_token": {token},
+		"format":     {"json"},
+		"count":      {strconv.Itoa(min(ternary(includePrivate, limit, limit*2), 100))},
+	}
+
+	if len(tags) > 0 {
+		query.Set("tag", strings.Join(tags, " "))
+	}
+
+	request, err := http.NewRequest("GET", "https://api.pinboard.in/v1/posts/recent?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeJsonFromRequest[pinboardRecentResponseJson](client, request)
+	if err != nil {
+		return nil, &redactedError{err: err, secret: url.QueryEscape(token)}
+	}
+
+	links := make([]recentLink, 0, min(limit, len(response.Posts)))
+	for _, post := range response.Posts {
+		if len(links) >= limit {
+			break
+		}
+
+		private := post.Shared == "no"
+		if private && !includePrivate {
+			continue
+		}
+
+		links = append(links, recentLink{
+			Title:       ternary(post.Description != "", post.Description, post.Href),
+			URL:         post.Href,
+			Domain:      extractDomainFromUrl(post.Href),
+			Description: post.Extended,
+			Tags:        strings.Fields(post.Tags),
+			Private:     private,
+			AddedAt:     parseRFC3339Time(post.Time),
+		})
+	}
+
+	return links, nil
+}
+
+// Pinboard only accepts the token as part of the URL, which gets included in
+// the errors of failed requests
+type redactedError struct {
+	err    error
+	secret string
+}
+
+func (e *redactedError) Error() string {
+	return strings.ReplaceAll(e.err.Error(), e.secret, "REDACTED")
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+type pinboardFeedResponseJson []struct {
+	URL         string   `json:"u"`
+	Title       string   `json:"d"`
+	Description string   `json:"n"`
+	Time        string   `json:"dt"`
+	Tags        []string `json:"t"`
+}
+
+func fetchPinboardPublicLinks(client requestDoer, user string, tags []string, limit int) ([]recentLink, error) {
+	path := "/json/u:" + url.PathEscape(user)
+	for _, tag := range tags {
+		path += "/t:" + url.PathEscape(tag)
+	}
+
+	request, err := http.NewRequest("GET", "https://feeds.pinboard.in"+path+"/?count="+strconv.Itoa(limit), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeJsonFromRequest[pinboardFeedResponseJson](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching public links of %s: %w", user, err)
+	}
+
+	links := make([]recentLink, 0, min(limit, len(response)))
+	for i := 0; i < len(response) && i < limit; i++ {
+		post := &response[i]
+		links = append(links, recentLink{
+			Title:       ternary(post.Title != "", post.Title, post.URL),
+			URL:         post.URL,
+			Domain:      extractDomainFromUrl(post.URL),
+			Description: post.Description,
+			Tags:        post.Tags,
+			AddedAt:     parseRFC3339Time(post.Time),
+		})
+	}
+
+	return links, nil
+}
+
+type recentLinkJson struct {
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Domain      string    `json:"domain"`
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Private     bool      `json:"private"`
+	AddedAt     time.Time `json:"added_at"`
+}
+
+func (widget *recentLinksWidget) data() any {
+	links := make([]recentLinkJson, 0, len(widget.Links))
+
+	for _, link := range widget.Links {
+		links = append(links, recentLinkJson(link))
+	}
+
+	return links
+}
@@ -19,6 +19,8 @@ var (
 
 type redditWidget struct {
 	widgetBase          `yaml:",inline"`
+	seenItemsOptions    `yaml:",inline"`
+	saveForLaterOptions `yaml:",inline"`
 	Posts               forumPostList     `yaml:"-"`
 	Subreddit           string            `yaml:"subreddit"`
 	Proxy               proxyOptionsField `yaml:"proxy"`
@@ -40,6 +42,8 @@ func (widget *redditWidget) initialize() error {
 		return errors.New("subreddit is required")
 	}
 
+	widget.initializeSeenItems()
+
 	if widget.Limit <= 0 {
 		widget.Limit = 15
 	}
@@ -88,6 +92,11 @@ func isValidRedditTopPeriod(period string) bool {
 
 func (widget *redditWidget) update(ctx context.Context) {
 	// TODO: refactor, use a struct to pass all of these
+	var proxyClient requestDoer
+	if widget.Proxy.client != nil {
+		proxyClient = widget.wrapCachedHTTPClient(widget.Proxy.client)
+	}
+
 	posts, err := fetchSubredditPosts(
 		widget.Subreddit,
 		widget.SortBy,
@@ -95,7 +104,8 @@ func (widget *redditWidget) update(ctx context.Context) {
 		widget.Search,
 		widget.CommentsUrlTemplate,
 		widget.RequestUrlTemplate,
-		widget.Proxy.client,
+		widget.cachedHTTPClient(false),
+		proxyClient,
 		widget.ShowFlairs,
 	)
 
@@ -115,6 +125,14 @@ func (widget *redditWidget) update(ctx context.Context) {
 	widget.Posts = posts
 }
 
+func (widget *redditWidget) briefing(_ time.Time, headlines int) []string {
+	return widget.Posts.briefing(widget.Title, headlines)
+}
+
+func (widget *redditWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	handleSaveForLaterRequest(w, r, &widget.widgetBase, widget.SaveTo, widget.Posts.titleByURL)
+}
+
 func (widget *redditWidget) Render() template.HTML {
 	if widget.Style == "horizontal-cards" {
 		return widget.renderTemplate(widget, redditWidgetHorizontalCardsTemplate)
@@ -170,7 +188,8 @@ func fetchSubredditPosts(
 	search,
 	commentsUrlTemplate,
 	requestUrlTemplate string,
-	proxyClient *http.Client,
+	client requestDoer,
+	proxyClient requestDoer,
 	showFlairs bool,
 ) (forumPostList, error) {
 	query := url.Values{}
@@ -191,8 +210,6 @@ func fetchSubredditPosts(
 		requestUrl = fmt.Sprintf("https://www.reddit.com/r/%s/%s.json?%s", subreddit, sort, query.Encode())
 	}
 
-	var client requestDoer = defaultHTTPClient
-
 	if requestUrlTemplate != "" {
 		requestUrl = strings.ReplaceAll(requestUrlTemplate, "{REQUEST-URL}", requestUrl)
 	} else if proxyClient != nil {
@@ -274,3 +291,7 @@ func fetchSubredditPosts(
 
 	return posts, nil
 }
+
+func (widget *redditWidget) data() any {
+	return widget.Posts.data()
+}
@@ -53,7 +53,7 @@ func (widget *releasesWidget) initialize() error {
 }
 
 func (widget *releasesWidget) update(ctx context.Context) {
-	releases, err := fetchLatestReleases(widget.Repositories)
+	releases, err := fetchLatestReleases(widget.cachedHTTPClient(false), widget.Repositories)
 
 	if !widget.canContinueUpdateAfterHandlingErr(err) {
 		return
@@ -118,13 +118,13 @@ func (r *releaseRequest) UnmarshalYAML(node *yaml.Node) error {
 
 	if err := node.Decode(&repository); err != nil {
 		if err := node.Decode(alias); err != nil {
-			return fmt.Errorf("could not umarshal repository into string or struct: %v", err)
+			return errorAtConfigLine(node, fmt.Errorf("could not umarshal repository into string or struct: %v", err))
 		}
 	}
 
 	if r.Repository == "" {
 		if repository == "" {
-			return errors.New("repository is required")
+			return errorAtConfigLine(node, errors.New("repository is required"))
 		} else {
 			r.Repository = repository
 		}
@@ -146,15 +146,17 @@ func (r *releaseRequest) UnmarshalYAML(node *yaml.Node) error {
 		case string(releaseSourceCodeberg):
 			r.source = releaseSourceCodeberg
 		default:
-			return errors.New("invalid source")
+			return errorAtConfigLine(node, errors.New("invalid source"))
 		}
 	}
 
 	return nil
 }
 
-func fetchLatestReleases(requests []*releaseRequest) (appReleaseList, error) {
-	job := newJob(fetchLatestReleaseTask, requests).withWorkers(20)
+func fetchLatestReleases(client requestDoer, requests []*releaseRequest) (appReleaseList, error) {
+	job := newJob(func(request *releaseRequest) (*appRelease, error) {
+		return fetchLatestReleaseTask(client, request)
+	}, requests).withWorkers(20)
 	results, errs, err := workerPoolDo(job)
 	if err != nil {
 		return nil, err
@@ -187,16 +189,16 @@ func fetchLatestReleases(requests []*releaseRequest) (appReleaseList, error) {
 	return releases, nil
 }
 
-func fetchLatestReleaseTask(request *releaseRequest) (*appRelease, error) {
+func fetchLatestReleaseTask(client requestDoer, request *releaseRequest) (*appRelease, error) {
 	switch request.source {
 	case releaseSourceCodeberg:
-		return fetchLatestCodebergRelease(request)
+		return fetchLatestCodebergRelease(client, request)
 	case releaseSourceGithub:
-		return fetchLatestGithubRelease(request)
+		return fetchLatestGithubRelease(client, request)
 	case releaseSourceGitlab:
-		return fetchLatestGitLabRelease(request)
+		return fetchLatestGitLabRelease(client, request)
 	case releaseSourceDockerHub:
-		return fetchLatestDockerHubRelease(request)
+		return fetchLatestDockerHubRelease(client, request)
 	}
 
 	return nil, errors.New("unsupported source")
@@ -211,7 +213,7 @@ type githubReleaseResponseJson struct {
 	} `json:"reactions"`
 }
 
-func fetchLatestGithubRelease(request *releaseRequest) (*appRelease, error) {
+func fetchLatestGithubRelease(client requestDoer, request *releaseRequest) (*appRelease, error) {
 	var requestURL string
 	if !request.IncludePreleases {
 		requestURL = fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", request.Repository)
@@ -231,12 +233,12 @@ func fetchLatestGithubRelease(request *releaseRequest) (*appRelease, error) {
 	var response githubReleaseResponseJson
 
 	if !request.IncludePreleases {
-		response, err = decodeJsonFromRequest[githubReleaseResponseJson](defaultHTTPClient, httpRequest)
+		response, err = decodeJsonFromRequest[githubReleaseResponseJson](client, httpRequest)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		responses, err := decodeJsonFromRequest[[]githubReleaseResponseJson](defaultHTTPClient, httpRequest)
+		responses, err := decodeJsonFromRequest[[]githubReleaseResponseJson](client, httpRequest)
 		if err != nil {
 			return nil, err
 		}
@@ -272,7 +274,7 @@ const dockerHubRepoTagURLFormat = "https://hub.docker.com/r/%s/tags?name=%s"
 const dockerHubTagsURLFormat = "https://hub.docker.com/v2/namespaces/%s/repositories/%s/tags"
 const dockerHubSpecificTagURLFormat = "https://hub.docker.com/v2/namespaces/%s/repositories/%s/tags/%s"
 
-func fetchLatestDockerHubRelease(request *releaseRequest) (*appRelease, error) {
+func fetchLatestDockerHubRelease(client requestDoer, request *releaseRequest) (*appRelease, error) {
 	nameParts := strings.Split(request.Repository, "/")
 
 	if len(nameParts) > 2 {
@@ -302,7 +304,7 @@ func fetchLatestDockerHubRelease(request *releaseRequest) (*appRelease, error) {
 	var tag *dockerHubRepositoryTagResponse
 
 	if len(tagParts) == 1 {
-		response, err := decodeJsonFromRequest[dockerHubRepositoryTagsResponse](defaultHTTPClient, httpRequest)
+		response, err := decodeJsonFromRequest[dockerHubRepositoryTagsResponse](client, httpRequest)
 		if err != nil {
 			return nil, err
 		}
@@ -313,7 +315,7 @@ func fetchLatestDockerHubRelease(request *releaseRequest) (*appRelease, error) {
 
 		tag = &response.Results[0]
 	} else {
-		response, err := decodeJsonFromRequest[dockerHubRepositoryTagResponse](defaultHTTPClient, httpRequest)
+		response, err := decodeJsonFromRequest[dockerHubRepositoryTagResponse](client, httpRequest)
 		if err != nil {
 			return nil, err
 		}
@@ -356,7 +358,7 @@ type gitlabReleaseResponseJson struct {
 	} `json:"_links"`
 }
 
-func fetchLatestGitLabRelease(request *releaseRequest) (*appRelease, error) {
+func fetchLatestGitLabRelease(client requestDoer, request *releaseRequest) (*appRelease, error) {
 	httpRequest, err := http.NewRequest(
 		"GET",
 		fmt.Sprintf(
@@ -373,7 +375,7 @@ func fetchLatestGitLabRelease(request *releaseRequest) (*appRelease, error) {
 		httpRequest.Header.Add("PRIVATE-TOKEN", *request.token)
 	}
 
-	response, err := decodeJsonFromRequest[gitlabReleaseResponseJson](defaultHTTPClient, httpRequest)
+	response, err := decodeJsonFromRequest[gitlabReleaseResponseJson](client, httpRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -393,7 +395,7 @@ type codebergReleaseResponseJson struct {
 	HtmlUrl     string `json:"html_url"`
 }
 
-func fetchLatestCodebergRelease(request *releaseRequest) (*appRelease, error) {
+func fetchLatestCodebergRelease(client requestDoer, request *releaseRequest) (*appRelease, error) {
 	httpRequest, err := http.NewRequest(
 		"GET",
 		fmt.Sprintf(
@@ -406,7 +408,7 @@ func fetchLatestCodebergRelease(request *releaseRequest) (*appRelease, error) {
 		return nil, err
 	}
 
-	response, err := decodeJsonFromRequest[codebergReleaseResponseJson](defaultHTTPClient, httpRequest)
+	response, err := decodeJsonFromRequest[codebergReleaseResponseJson](client, httpRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -419,3 +421,28 @@ func fetchLatestCodebergRelease(request *releaseRequest) (*appRelease, error) {
 		TimeReleased: parseRFC3339Time(response.PublishedAt),
 	}, nil
 }
+
+type appReleaseJson struct {
+	Name       string    `json:"name"`
+	Version    string    `json:"version"`
+	Source     string    `json:"source"`
+	URL        string    `json:"url"`
+	ReleasedAt time.Time `json:"released_at"`
+}
+
+func (widget *releasesWidget) data() any {
+	releases := make([]appReleaseJson, 0, len(widget.Releases))
+
+	for i := range widget.Releases {
+		release := &widget.Releases[i]
+		releases = append(releases, appReleaseJson{
+			Name:       release.Name,
+			Version:    release.Version,
+			Source:     string(release.Source),
+			URL:        release.NotesUrl,
+			ReleasedAt: release.TimeReleased,
+		})
+	}
+
+	return releases
+}
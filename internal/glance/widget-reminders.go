@@ -0,0 +1,280 @@
+package glance
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var remindersWidgetTemplate = mustParseTemplate("reminders.html", "widget-base.html")
+
+type remindersWidget struct {
+	widgetBase    `yaml:",inline"`
+	Reminders     []reminder    `yaml:"reminders"`
+	Notify        []string      `yaml:"notify"`
+	EscalateAfter durationField `yaml:"escalate-after"`
+	stateMutex    sync.Mutex    `yaml:"-"`
+}
+
+type remindersTemplateData struct {
+	*remindersWidget
+	Items []reminderItem
+}
+
+type reminder struct {
+	Name          string         `yaml:"name"`
+	Time          string         `yaml:"time"`
+	Days          []string       `yaml:"days"`
+	Cron          string         `yaml:"cron"`
+	EscalateAfter *durationField `yaml:"escalate-after"`
+	schedule      *cronSchedule
+	escalateAfter time.Duration
+}
+
+type reminderState struct {
+	LastDone     int64 `json:"last_done"`
+	LastNotified int64 `json:"last_notified"`
+}
+
+type reminderItem struct {
+	Name    string
+	DueAt   time.Time
+	NextAt  time.Time
+	IsDone  bool
+	IsLate  bool
+	IsEmpty bool
+}
+
+func (widget *remindersWidget) initialize() error {
+	widget.withTitle("Reminders").withError(nil)
+
+	if len(widget.Reminders) == 0 {
+		return errors.New("no reminders specified")
+	}
+
+	names := make(map[string]struct{}, len(widget.Reminders))
+
+	for i := range widget.Reminders {
+		r := &widget.Reminders[i]
+
+		if r.Name == "" {
+			return fmt.Errorf("reminder %d has no name", i+1)
+		}
+
+		if _, exists := names[r.Name]; exists {
+			return fmt.Errorf("multiple reminders with the name %s", r.Name)
+		}
+
+		names[r.Name] = struct{}{}
+
+		expression, err := r.cronExpression()
+		if err != nil {
+			return fmt.Errorf("reminder %s: %v", r.Name, err)
+		}
+
+		r.schedule, err = parseCronSchedule(expression)
+		if err != nil {
+			return fmt.Errorf("reminder %s: invalid cron expression: %v", r.Name, err)
+		}
+
+		if r.EscalateAfter != nil {
+			r.escalateAfter = time.Duration(*r.EscalateAfter)
+		} else if widget.EscalateAfter > 0 {
+			r.escalateAfter = time.Duration(widget.EscalateAfter)
+		} else {
+			r.escalateAfter = time.Hour
+		}
+	}
+
+	return nil
+}
+
+// Daily and weekly schedules are just a more convenient way of writing a cron expression
+func (r *reminder) cronExpression() (string, error) {
+	if r.Cron != "" {
+		if r.Time != "" || len(r.Days) > 0 {
+			return "", errors.New("cron cannot be combined with time or days")
+		}
+
+		return r.Cron, nil
+	}
+
+	if r.Time == "" {
+		return "", errors.New("either time or cron must be specified")
+	}
+
+	at, err := time.Parse("15:04", r.Time)
+	if err != nil {
+		return "", fmt.Errorf("invalid time %s, must be in the format of HH:MM", r.Time)
+	}
+
+	days := "*"
+
+	if len(r.Days) > 0 {
+		for i := range r.Days {
+			day := strings.ToLower(r.Days[i])
+			if len(day) > 3 {
+				day = day[:3]
+			}
+
+			if _, ok := cronDayOfWeekNames[day]; !ok {
+				return "", fmt.Errorf("invalid day %s", r.Days[i])
+			}
+
+			r.Days[i] = day
+		}
+
+		days = strings.Join(r.Days, ",")
+	}
+
+	return fmt.Sprintf("%d %d * * %s", at.Minute(), at.Hour(), days), nil
+}
+
+func (widget *remindersWidget) getNotificationTargets() []string {
+	return widget.Notify
+}
+
+func (widget *remindersWidget) stateKey(r *reminder) string {
+	return "reminders:" + widget.stateScope() + ":" + r.Name
+}
+
+func (widget *remindersWidget) getState(r *reminder) (reminderState, bool) {
+	var state reminderState
+	exists := widget.Providers.state.get(widget.stateKey(r), &state)
+
+	return state, exists
+}
+
+func (widget *remindersWidget) buildItems(now time.Time) []reminderItem {
+	items := make([]reminderItem, 0, len(widget.Reminders))
+
+	for i := range widget.Reminders {
+		r := &widget.Reminders[i]
+		state, _ := widget.getState(r)
+
+		item := reminderItem{
+			Name:   r.Name,
+			DueAt:  r.schedule.prev(now),
+			NextAt: r.schedule.next(now),
+		}
+
+		if item.DueAt.IsZero() {
+			item.IsEmpty = true
+		} else {
+			item.IsDone = state.LastDone >= item.DueAt.Unix()
+			item.IsLate = !item.IsDone && now.Sub(item.DueAt) >= r.escalateAfter
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func (widget *remindersWidget) runBackgroundTask(now time.Time) {
+	if len(widget.Notify) == 0 {
+		return
+	}
+
+	widget.stateMutex.Lock()
+	defer widget.stateMutex.Unlock()
+
+	for i := range widget.Reminders {
+		r := &widget.Reminders[i]
+
+		dueAt := r.schedule.prev(now)
+		if dueAt.IsZero() {
+			continue
+		}
+
+		state, exists := widget.getState(r)
+
+		// don't notify about occurrences that were missed before the reminder was added
+		if !exists {
+			widget.Providers.state.set(widget.stateKey(r), reminderState{LastNotified: dueAt.Unix()})
+			continue
+		}
+
+		if state.LastDone >= dueAt.Unix() || state.LastNotified >= dueAt.Unix() {
+			continue
+		}
+
+		if now.Sub(dueAt) < r.escalateAfter {
+			continue
+		}
+
+		delivered := widget.Providers.notifier.notify(widget.Notify, notification{
+			Title:   "Missed reminder: " + r.Name,
+			Message: "Was due at " + dueAt.Format("Mon, 15:04") + " and hasn't been marked as done",
+		})
+
+		// failed deliveries get retried on the next run
+		if delivered {
+			state.LastNotified = dueAt.Unix()
+			widget.Providers.state.set(widget.stateKey(r), state)
+		}
+	}
+}
+
+func (widget *remindersWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	body, ok := decodeChecklistToggleRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var target *reminder
+	for i := range widget.Reminders {
+		if widget.Reminders[i].Name == body.Item {
+			target = &widget.Reminders[i]
+			break
+		}
+	}
+
+	if target == nil {
+		http.Error(w, "reminder not found", http.StatusNotFound)
+		return
+	}
+
+	widget.stateMutex.Lock()
+	defer widget.stateMutex.Unlock()
+
+	state, _ := widget.getState(target)
+	before := ternary(state.LastDone > 0, "last done "+time.Unix(state.LastDone, 0).Format("Jan 2 15:04"), "never done")
+
+	if body.Done {
+		state.LastDone = time.Now().Unix()
+		widget.Providers.audit.record(r, "Checked off reminder", widget.Title+": "+target.Name, before, "done")
+	} else {
+		state.LastDone = 0
+		widget.Providers.audit.record(r, "Unchecked reminder", widget.Title+": "+target.Name, before, "never done")
+	}
+
+	widget.Providers.state.set(widget.stateKey(target), state)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (widget *remindersWidget) briefing(now time.Time, _ int) []string {
+	var sentences []string
+
+	for _, item := range widget.buildItems(now) {
+		if item.IsEmpty || item.IsDone || item.DueAt.YearDay() != now.YearDay() || item.DueAt.Year() != now.Year() {
+			continue
+		}
+
+		sentences = append(sentences, fmt.Sprintf("Reminder: %s, due at %s.", item.Name, briefingTime(item.DueAt)))
+	}
+
+	return sentences
+}
+
+func (widget *remindersWidget) Render() template.HTML {
+	// passed along rather than set on the widget so that rendering leaves it untouched
+	return widget.renderTemplate(&remindersTemplateData{
+		remindersWidget: widget,
+		Items:           widget.buildItems(time.Now()),
+	}, remindersWidgetTemplate)
+}
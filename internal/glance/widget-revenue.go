@@ -0,0 +1,434 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var revenueWidgetTemplate = mustParseTemplate("revenue.html", "widget-base.html")
+
+const stripeAPIURL = "https://api.stripe.com/v1"
+
+// the number of pages fetched from each list endpoint is capped to stay well
+// within the rate limits of both services
+const revenueMaxPages = 25
+
+type revenueWidget struct {
+	widgetBase   `yaml:",inline"`
+	ProviderName string          `yaml:"provider"`
+	Key          string          `yaml:"key"`
+	Sandbox      bool            `yaml:"sandbox"`
+	Summary      *revenueSummary `yaml:"-"`
+	provider     revenueProvider `yaml:"-"`
+}
+
+type revenueProvider interface {
+	fetchSummary(now time.Time) (*revenueSummary, error)
+}
+
+// Amounts are in the smallest unit of the currency, e.g. cents
+type revenueSummary struct {
+	Currency          string
+	Today             int64
+	Month             int64
+	NewCustomersToday int
+	NewCustomersMonth int
+	MRR               int64
+	HasMRR            bool
+}
+
+func (widget *revenueWidget) initialize() error {
+	// both services are fine with a request every now and then, however
+	// summing a busy month can take a couple dozen paginated requests
+	widget.withTitle("Revenue").withCacheDuration(30 * time.Minute)
+
+	if widget.Key == "" {
+		return errors.New("key is required")
+	}
+
+	switch widget.ProviderName {
+	case "stripe":
+		widget.provider = &stripeProvider{key: widget.Key, client: widget.httpClient(false)}
+	case "paddle":
+		widget.provider = &paddleProvider{
+			url:    ternary(widget.Sandbox, "https://sandbox-api.paddle.com", "https://api.paddle.com"),
+			key:    widget.Key,
+			client: widget.httpClient(false),
+		}
+	case "":
+		return errors.New("provider is required")
+	default:
+		return fmt.Errorf("unsupported provider %s, must be one of stripe or paddle", widget.ProviderName)
+	}
+
+	return nil
+}
+
+func (widget *revenueWidget) update(ctx context.Context) {
+	summary, err := widget.provider.fetchSummary(time.Now())
+	if err != nil {
+		err = fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Summary = summary
+}
+
+func (widget *revenueWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, revenueWidgetTemplate)
+}
+
+// Currencies that don't have a minor unit, so their amounts aren't in cents
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true,
+	"KRW": true, "MGA": true, "PYG": true, "RWF": true, "UGX": true, "VND": true,
+	"VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+func (summary *revenueSummary) FormatAmount(amount int64) string {
+	var formatted string
+
+	if zeroDecimalCurrencies[summary.Currency] {
+		formatted = intl.Sprint(amount)
+	} else {
+		formatted = intl.Sprintf("%.2f", float64(amount)/100)
+		formatted = strings.TrimSuffix(formatted, ".00")
+	}
+
+	if symbol, ok := currencyToSymbol[summary.Currency]; ok {
+		return symbol + formatted
+	}
+
+	return formatted + " " + summary.Currency
+}
+
+func revenuePeriodStarts(now time.Time) (time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	return today, month
+}
+
+// Converts the price of a subscription item to a monthly amount
+func monthlyRecurringAmount(amount int64, quantity int, interval string, count int) int64 {
+	total := float64(amount) * float64(max(quantity, 1)) / float64(max(count, 1))
+
+	switch interval {
+	case "day":
+		total = total * 365 / 12
+	case "week":
+		total = total * 52 / 12
+	case "year":
+		total = total / 12
+	}
+
+	return int64(total + 0.5)
+}
+
+type stripeProvider struct {
+	key    string
+	client requestDoer
+}
+
+type stripeBalanceTransactionJson struct {
+	ID       string `json:"id"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Type     string `json:"type"`
+	Created  int64  `json:"created"`
+}
+
+type stripeCustomerJson struct {
+	ID      string `json:"id"`
+	Created int64  `json:"created"`
+}
+
+type stripeSubscriptionJson struct {
+	ID    string `json:"id"`
+	Items struct {
+		Data []struct {
+			Quantity int `json:"quantity"`
+			Price    struct {
+				Currency   string `json:"currency"`
+				UnitAmount *int64 `json:"unit_amount"`
+				Recurring  *struct {
+					Interval      string `json:"interval"`
+					IntervalCount int    `json:"interval_count"`
+				} `json:"recurring"`
+			} `json:"price"`
+		} `json:"data"`
+	} `json:"items"`
+}
+
+type stripeListResponseJson[T any] struct {
+	Data    []T  `json:"data"`
+	HasMore bool `json:"has_more"`
+}
+
+func stripeList[T any](p *stripeProvider, path string, query url.Values, id func(*T) string) ([]T, error) {
+	var items []T
+	query.Set("limit", "100")
+
+	for range revenueMaxPages {
+		request, _ := http.NewRequest("GET", stripeAPIURL+path+"?"+query.Encode(), nil)
+		request.Header.Set("Authorization", "Bearer "+p.key)
+
+		response, err := decodeJsonFromRequest[stripeListResponseJson[T]](p.client, request)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, response.Data...)
+
+		if !response.HasMore || len(response.Data) == 0 {
+			return items, nil
+		}
+
+		query.Set("starting_after", id(&response.Data[len(response.Data)-1]))
+	}
+
+	slog.Warn("Stripe list was cut short after reaching the page limit", "path", path, "pages", revenueMaxPages)
+	return items, nil
+}
+
+func (p *stripeProvider) fetchSummary(now time.Time) (*revenueSummary, error) {
+	today, month := revenuePeriodStarts(now)
+	since := url.Values{"created[gte]": {strconv.FormatInt(month.Unix(), 10)}}
+
+	// balance transactions are used instead of charges since they're all in
+	// the account's settlement currency regardless of what the customer paid in
+	transactions, err := stripeList(p, "/balance_transactions", since, func(t *stripeBalanceTransactionJson) string { return t.ID })
+	if err != nil {
+		return nil, fmt.Errorf("fetching balance transactions: %v", err)
+	}
+
+	summary := &revenueSummary{}
+
+	for i := range transactions {
+		t := &transactions[i]
+
+		switch t.Type {
+		case "charge", "payment", "refund", "payment_refund":
+		default:
+			continue
+		}
+
+		summary.Currency = strings.ToUpper(t.Currency)
+		summary.Month += t.Amount
+		if t.Created >= today.Unix() {
+			summary.Today += t.Amount
+		}
+	}
+
+	customers, err := stripeList(p, "/customers", url.Values{"created[gte]": since["created[gte]"]}, func(c *stripeCustomerJson) string { return c.ID })
+	if err != nil {
+		return nil, fmt.Errorf("fetching customers: %v", err)
+	}
+
+	summary.NewCustomersMonth = len(customers)
+	for i := range customers {
+		if customers[i].Created >= today.Unix() {
+			summary.NewCustomersToday++
+		}
+	}
+
+	subscriptions, err := stripeList(p, "/subscriptions", url.Values{"status": {"active"}}, func(s *stripeSubscriptionJson) string { return s.ID })
+	if err != nil {
+		return nil, fmt.Errorf("fetching subscriptions: %v", err)
+	}
+
+	for i := range subscriptions {
+		for _, item := range subscriptions[i].Items.Data {
+			price := &item.Price
+			if price.Recurring == nil || price.UnitAmount == nil {
+				continue
+			}
+
+			currency := strings.ToUpper(price.Currency)
+			if summary.Currency == "" {
+				summary.Currency = currency
+			} else if currency != summary.Currency {
+				continue
+			}
+
+			summary.HasMRR = true
+			summary.MRR += monthlyRecurringAmount(*price.UnitAmount, item.Quantity, price.Recurring.Interval, price.Recurring.IntervalCount)
+		}
+	}
+
+	return summary, nil
+}
+
+type paddleProvider struct {
+	url    string
+	key    string
+	client requestDoer
+}
+
+type paddleTransactionJson struct {
+	BilledAt string `json:"billed_at"`
+	Details  struct {
+		Totals struct {
+			Total        string `json:"total"`
+			CurrencyCode string `json:"currency_code"`
+		} `json:"totals"`
+		PayoutTotals *struct {
+			Total        string `json:"total"`
+			CurrencyCode string `json:"currency_code"`
+		} `json:"payout_totals"`
+	} `json:"details"`
+}
+
+type paddleCustomerJson struct {
+	CreatedAt string `json:"created_at"`
+}
+
+type paddleSubscriptionJson struct {
+	CurrencyCode string `json:"currency_code"`
+	Items        []struct {
+		Quantity int `json:"quantity"`
+		Price    struct {
+			BillingCycle *struct {
+				Interval  string `json:"interval"`
+				Frequency int    `json:"frequency"`
+			} `json:"billing_cycle"`
+			UnitPrice struct {
+				Amount string `json:"amount"`
+			} `json:"unit_price"`
+		} `json:"price"`
+	} `json:"items"`
+}
+
+type paddleListResponseJson[T any] struct {
+	Data []T `json:"data"`
+	Meta struct {
+		Pagination struct {
+			Next    string `json:"next"`
+			HasMore bool   `json:"has_more"`
+		} `json:"pagination"`
+	} `json:"meta"`
+}
+
+// The callback can stop the pagination early by returning false
+func paddleList[T any](p *paddleProvider, path string, query url.Values, each func(*T) bool) error {
+	query.Set("per_page", "200")
+	next := p.url + path + "?" + query.Encode()
+
+	for range revenueMaxPages {
+		request, _ := http.NewRequest("GET", next, nil)
+		request.Header.Set("Authorization", "Bearer "+p.key)
+
+		response, err := decodeJsonFromRequest[paddleListResponseJson[T]](p.client, request)
+		if err != nil {
+			return err
+		}
+
+		for i := range response.Data {
+			if !each(&response.Data[i]) {
+				return nil
+			}
+		}
+
+		if !response.Meta.Pagination.HasMore || response.Meta.Pagination.Next == "" {
+			return nil
+		}
+
+		next = response.Meta.Pagination.Next
+	}
+
+	slog.Warn("Paddle list was cut short after reaching the page limit", "path", path, "pages", revenueMaxPages)
+	return nil
+}
+
+func (p *paddleProvider) fetchSummary(now time.Time) (*revenueSummary, error) {
+	today, month := revenuePeriodStarts(now)
+	summary := &revenueSummary{}
+
+	err := paddleList(p, "/transactions", url.Values{
+		"status":         {"completed"},
+		"billed_at[GTE]": {month.UTC().Format(time.RFC3339)},
+	}, func(t *paddleTransactionJson) bool {
+		// payout totals are converted to the payout currency, they're missing
+		// for transactions that were paid for in that currency
+		total, currency := t.Details.Totals.Total, t.Details.Totals.CurrencyCode
+		if t.Details.PayoutTotals != nil {
+			total, currency = t.Details.PayoutTotals.Total, t.Details.PayoutTotals.CurrencyCode
+		}
+
+		amount, err := strconv.ParseInt(total, 10, 64)
+		if err != nil {
+			return true
+		}
+
+		if summary.Currency == "" {
+			summary.Currency = currency
+		} else if currency != summary.Currency {
+			return true
+		}
+
+		summary.Month += amount
+		if billedAt, err := time.Parse(time.RFC3339, t.BilledAt); err == nil && !billedAt.Before(today) {
+			summary.Today += amount
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching transactions: %v", err)
+	}
+
+	// customers can't be filtered by when they were created, however their IDs
+	// are ordered by creation time so going from the newest one is enough
+	err = paddleList(p, "/customers", url.Values{"order_by": {"id[DESC]"}}, func(c *paddleCustomerJson) bool {
+		createdAt, err := time.Parse(time.RFC3339, c.CreatedAt)
+		if err != nil || createdAt.Before(month) {
+			return false
+		}
+
+		summary.NewCustomersMonth++
+		if !createdAt.Before(today) {
+			summary.NewCustomersToday++
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching customers: %v", err)
+	}
+
+	err = paddleList(p, "/subscriptions", url.Values{"status": {"active"}}, func(s *paddleSubscriptionJson) bool {
+		if summary.Currency == "" {
+			summary.Currency = s.CurrencyCode
+		} else if s.CurrencyCode != summary.Currency {
+			return true
+		}
+
+		for _, item := range s.Items {
+			amount, err := strconv.ParseInt(item.Price.UnitPrice.Amount, 10, 64)
+			if err != nil || item.Price.BillingCycle == nil {
+				continue
+			}
+
+			summary.HasMRR = true
+			summary.MRR += monthlyRecurringAmount(amount, item.Quantity, item.Price.BillingCycle.Interval, item.Price.BillingCycle.Frequency)
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching subscriptions: %v", err)
+	}
+
+	return summary, nil
+}
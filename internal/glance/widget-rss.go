@@ -2,16 +2,19 @@ package glance
 
 import (
 	"context"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"html"
 	"html/template"
 	"io"
-	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mmcdole/gofeed"
@@ -26,21 +29,30 @@ var (
 )
 
 type rssWidget struct {
-	widgetBase       `yaml:",inline"`
-	FeedRequests     []rssFeedRequest `yaml:"feeds"`
-	Style            string           `yaml:"style"`
-	ThumbnailHeight  float64          `yaml:"thumbnail-height"`
-	CardHeight       float64          `yaml:"card-height"`
-	Items            rssFeedItemList  `yaml:"-"`
-	Limit            int              `yaml:"limit"`
-	CollapseAfter    int              `yaml:"collapse-after"`
-	SingleLineTitles bool             `yaml:"single-line-titles"`
-	PreserveOrder    bool             `yaml:"preserve-order"`
-	NoItemsMessage   string           `yaml:"-"`
+	widgetBase          `yaml:",inline"`
+	seenItemsOptions    `yaml:",inline"`
+	saveForLaterOptions `yaml:",inline"`
+	FeedRequests        []rssFeedRequest `yaml:"feeds"`
+	OPML                string           `yaml:"opml"`
+	Style               string           `yaml:"style"`
+	ThumbnailHeight     float64          `yaml:"thumbnail-height"`
+	CardHeight          float64          `yaml:"card-height"`
+	Items               rssFeedItemList  `yaml:"-"`
+	Limit               int              `yaml:"limit"`
+	CollapseAfter       int              `yaml:"collapse-after"`
+	SingleLineTitles    bool             `yaml:"single-line-titles"`
+	PreserveOrder       bool             `yaml:"preserve-order"`
+	ReaderView          bool             `yaml:"reader-view"`
+	Summarize           bool             `yaml:"summarize"`
+	NoItemsMessage      string           `yaml:"-"`
+
+	cachedFeedsMutex sync.Mutex
+	cachedFeeds      map[string]*cachedRSSFeed
 }
 
 func (widget *rssWidget) initialize() error {
 	widget.withTitle("RSS Feed").withCacheDuration(1 * time.Hour)
+	widget.initializeSeenItems()
 
 	if widget.Limit <= 0 {
 		widget.Limit = 25
@@ -58,6 +70,15 @@ func (widget *rssWidget) initialize() error {
 		widget.CardHeight = 0
 	}
 
+	if widget.OPML != "" {
+		feeds, err := loadFeedRequestsFromOPML(widget.cachedHTTPClient(false), widget.OPML)
+		if err != nil {
+			return fmt.Errorf("loading OPML: %v", err)
+		}
+
+		widget.FeedRequests = mergeFeedRequests(widget.FeedRequests, feeds)
+	}
+
 	if widget.Style == "detailed-list" {
 		for i := range widget.FeedRequests {
 			widget.FeedRequests[i].IsDetailed = true
@@ -65,12 +86,13 @@ func (widget *rssWidget) initialize() error {
 	}
 
 	widget.NoItemsMessage = "No items were returned from the feeds."
+	widget.cachedFeeds = make(map[string]*cachedRSSFeed)
 
 	return nil
 }
 
 func (widget *rssWidget) update(ctx context.Context) {
-	items, err := fetchItemsFromRSSFeeds(widget.FeedRequests)
+	items, err := widget.fetchItemsFromFeeds()
 
 	if !widget.canContinueUpdateAfterHandlingErr(err) {
 		return
@@ -84,9 +106,96 @@ func (widget *rssWidget) update(ctx context.Context) {
 		items = items[:widget.Limit]
 	}
 
+	if widget.ReaderView {
+		for i := range items {
+			items[i].OriginalLink = items[i].Link
+			items[i].Link = widget.Providers.readerURLResolver(widget.GetID(), items[i].Link)
+		}
+	}
+
+	if widget.Summarize {
+		widget.summarizeItems(ctx, items)
+	}
+
 	widget.Items = items
 }
 
+func (widget *rssWidget) usesSummarizer() bool {
+	return widget.Summarize
+}
+
+// Items that fail to be summarized are shown without a summary, summaries
+// that were already made come from the summarizer's cache
+func (widget *rssWidget) summarizeItems(ctx context.Context, items rssFeedItemList) {
+	summarizer := widget.Providers.summarizer
+
+	job := newJob(func(item rssFeedItem) (string, error) {
+		if item.content == "" {
+			return "", nil
+		}
+
+		return summarizer.summarizeArticle(ctx, item.Title, item.content)
+	}, items).withWorkers(4)
+
+	summaries, errs, err := workerPoolDo(job)
+	if err != nil {
+		widget.logger().Error("Failed to summarize RSS items", "error", err)
+		return
+	}
+
+	failed := 0
+	var lastErr error
+
+	for i := range items {
+		if errs[i] != nil {
+			failed++
+			lastErr = errs[i]
+			continue
+		}
+
+		items[i].Summary = summaries[i]
+	}
+
+	if failed > 0 {
+		widget.logger().Warn("Failed to summarize RSS items", "failed", failed, "error", lastErr)
+	}
+}
+
+func (widget *rssWidget) hasReaderViewItem(articleURL string) bool {
+	if !widget.ReaderView {
+		return false
+	}
+
+	for i := range widget.Items {
+		if widget.Items[i].OriginalLink == articleURL {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (widget *rssWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	handleSaveForLaterRequest(w, r, &widget.widgetBase, widget.SaveTo, func(url string) (string, bool) {
+		for i := range widget.Items {
+			if widget.Items[i].SaveURL() == url {
+				return widget.Items[i].Title, true
+			}
+		}
+
+		return "", false
+	})
+}
+
+func (widget *rssWidget) briefing(_ time.Time, headlines int) []string {
+	titles := make([]string, 0, min(headlines, len(widget.Items)))
+	for i := 0; i < len(widget.Items) && i < headlines; i++ {
+		titles = append(titles, widget.Items[i].Title)
+	}
+
+	return briefingHeadlines(widget.Title, titles, headlines)
+}
+
 func (widget *rssWidget) Render() template.HTML {
 	if widget.Style == "horizontal-cards" {
 		return widget.renderTemplate(widget, rssWidgetHorizontalCardsTemplate)
@@ -104,14 +213,27 @@ func (widget *rssWidget) Render() template.HTML {
 }
 
 type rssFeedItem struct {
-	ChannelName string
-	ChannelURL  string
-	Title       string
-	Link        string
-	ImageURL    string
-	Categories  []string
-	Description string
-	PublishedAt time.Time
+	ChannelName  string
+	ChannelURL   string
+	Title        string
+	Link         string
+	OriginalLink string
+	ImageURL     string
+	Categories   []string
+	Description  string
+	Summary      string
+	PublishedAt  time.Time
+	// the text of the item that gets summarized, only kept when summarizing
+	content string
+}
+
+// The link of the article itself rather than of the reader view, when it's used
+func (i rssFeedItem) SaveURL() string {
+	return ternary(i.OriginalLink != "", i.OriginalLink, i.Link)
+}
+
+func (i rssFeedItem) ID() string {
+	return seenItemID(ternary(i.OriginalLink != "", i.OriginalLink, i.Link))
 }
 
 // doesn't cover all cases but works the vast majority of the time
@@ -151,6 +273,7 @@ type rssFeedRequest struct {
 	Limit           int               `yaml:"limit"`
 	ItemLinkPrefix  string            `yaml:"item-link-prefix"`
 	Headers         map[string]string `yaml:"headers"`
+	Proxy           proxyOptionsField `yaml:"proxy"`
 	IsDetailed      bool              `yaml:"-"`
 }
 
@@ -166,7 +289,16 @@ func (f rssFeedItemList) sortByNewest() rssFeedItemList {
 
 var feedParser = gofeed.NewParser()
 
-func fetchItemsFromRSSFeedTask(request rssFeedRequest) ([]rssFeedItem, error) {
+// Holds the validators from the last successful response of a feed so that
+// subsequent requests can be made conditional and skip parsing when the
+// feed hasn't changed
+type cachedRSSFeed struct {
+	etag         string
+	lastModified string
+	items        []rssFeedItem
+}
+
+func (widget *rssWidget) fetchItemsFromFeedTask(request rssFeedRequest) ([]rssFeedItem, error) {
 	req, err := http.NewRequest("GET", request.URL, nil)
 	if err != nil {
 		return nil, err
@@ -176,12 +308,35 @@ func fetchItemsFromRSSFeedTask(request rssFeedRequest) ([]rssFeedItem, error) {
 		req.Header.Add(key, value)
 	}
 
-	resp, err := defaultHTTPClient.Do(req)
+	widget.cachedFeedsMutex.Lock()
+	cached := widget.cachedFeeds[request.URL]
+	widget.cachedFeedsMutex.Unlock()
+
+	if cached != nil {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	client := widget.cachedHTTPClient(false)
+	if request.Proxy.client != nil {
+		client = widget.wrapCachedHTTPClient(request.Proxy.client)
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached.items, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, request.URL)
 	}
@@ -262,6 +417,10 @@ func fetchItemsFromRSSFeedTask(request rssFeedRequest) ([]rssFeedItem, error) {
 			}
 		}
 
+		if widget.Summarize {
+			rssItem.content = sanitizeFeedDescription(ternary(item.Content != "", item.Content, item.Description))
+		}
+
 		if request.Title != "" {
 			rssItem.ChannelName = request.Title
 		} else {
@@ -289,6 +448,20 @@ func fetchItemsFromRSSFeedTask(request rssFeedRequest) ([]rssFeedItem, error) {
 		items = append(items, rssItem)
 	}
 
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+
+	widget.cachedFeedsMutex.Lock()
+	if etag != "" || lastModified != "" {
+		widget.cachedFeeds[request.URL] = &cachedRSSFeed{
+			etag:         etag,
+			lastModified: lastModified,
+			items:        items,
+		}
+	} else {
+		delete(widget.cachedFeeds, request.URL)
+	}
+	widget.cachedFeedsMutex.Unlock()
+
 	return items, nil
 }
 
@@ -322,8 +495,9 @@ func findThumbnailInItemExtensions(item *gofeed.Item) string {
 	return recursiveFindThumbnailInExtensions(media)
 }
 
-func fetchItemsFromRSSFeeds(requests []rssFeedRequest) (rssFeedItemList, error) {
-	job := newJob(fetchItemsFromRSSFeedTask, requests).withWorkers(30)
+func (widget *rssWidget) fetchItemsFromFeeds() (rssFeedItemList, error) {
+	requests := widget.FeedRequests
+	job := newJob(widget.fetchItemsFromFeedTask, requests).withWorkers(30)
 	feeds, errs, err := workerPoolDo(job)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", errNoContent, err)
@@ -335,7 +509,7 @@ func fetchItemsFromRSSFeeds(requests []rssFeedRequest) (rssFeedItemList, error)
 	for i := range feeds {
 		if errs[i] != nil {
 			failed++
-			slog.Error("Failed to get RSS feed", "url", requests[i].URL, "error", errs[i])
+			widget.logger().Error("Failed to get RSS feed", "url", requests[i].URL, "error", errs[i], "error_class", errorClass(errs[i]))
 			continue
 		}
 
@@ -352,3 +526,127 @@ func fetchItemsFromRSSFeeds(requests []rssFeedRequest) (rssFeedItemList, error)
 
 	return entries, nil
 }
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr"`
+	XMLURL   string        `xml:"xmlUrl,attr"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlDocument struct {
+	Body struct {
+		Outlines []opmlOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+// The source can either be a path to a local file or a URL, it's read every time
+// the config gets loaded so changes to it get picked up along with config reloads
+func loadFeedRequestsFromOPML(client requestDoer, source string) ([]rssFeedRequest, error) {
+	var contents []byte
+	var err error
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		var response *http.Response
+		request, _ := http.NewRequest("GET", source, nil)
+		response, err = client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status code %d from %s", response.StatusCode, source)
+		}
+
+		contents, err = io.ReadAll(response.Body)
+	} else {
+		contents, err = os.ReadFile(source)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var document opmlDocument
+	if err := xml.Unmarshal(contents, &document); err != nil {
+		return nil, fmt.Errorf("parsing: %v", err)
+	}
+
+	var feeds []rssFeedRequest
+	var collect func(outlines []opmlOutline)
+
+	// feeds are commonly grouped into folders using nested outlines
+	collect = func(outlines []opmlOutline) {
+		for i := range outlines {
+			if outlines[i].XMLURL != "" {
+				feeds = append(feeds, rssFeedRequest{
+					URL:   outlines[i].XMLURL,
+					Title: ternary(outlines[i].Title != "", outlines[i].Title, outlines[i].Text),
+				})
+			}
+
+			collect(outlines[i].Outlines)
+		}
+	}
+
+	collect(document.Body.Outlines)
+
+	if len(feeds) == 0 {
+		return nil, errors.New("no feeds found")
+	}
+
+	return feeds, nil
+}
+
+// feeds that were explicitly defined in the config take precedence over the ones from OPML
+func mergeFeedRequests(defined []rssFeedRequest, imported []rssFeedRequest) []rssFeedRequest {
+	seen := make(map[string]struct{}, len(defined))
+	for i := range defined {
+		seen[defined[i].URL] = struct{}{}
+	}
+
+	for i := range imported {
+		if _, exists := seen[imported[i].URL]; exists {
+			continue
+		}
+
+		seen[imported[i].URL] = struct{}{}
+		defined = append(defined, imported[i])
+	}
+
+	return defined
+}
+
+type rssFeedItemJson struct {
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	ChannelName string    `json:"channel_name"`
+	ChannelURL  string    `json:"channel_url"`
+	ImageURL    string    `json:"image_url,omitempty"`
+	Categories  []string  `json:"categories,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Summary     string    `json:"summary,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+func (widget *rssWidget) data() any {
+	items := make([]rssFeedItemJson, 0, len(widget.Items))
+
+	for i := range widget.Items {
+		item := &widget.Items[i]
+		items = append(items, rssFeedItemJson{
+			Title:       item.Title,
+			URL:         item.SaveURL(),
+			ChannelName: item.ChannelName,
+			ChannelURL:  item.ChannelURL,
+			ImageURL:    item.ImageURL,
+			Categories:  item.Categories,
+			Description: item.Description,
+			Summary:     item.Summary,
+			PublishedAt: item.PublishedAt,
+		})
+	}
+
+	return items
+}
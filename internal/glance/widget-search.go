@@ -1,27 +1,39 @@
 package glance
 
 import (
+	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+
+	"github.com/tidwall/gjson"
 )
 
 var searchWidgetTemplate = mustParseTemplate("search.html", "widget-base.html")
 
+const searchWidgetMaxSuggestions = 8
+
 type SearchBang struct {
-	Title    string
-	Shortcut string
-	URL      string
+	Title         string
+	Shortcut      string
+	URL           string
+	SuggestionURL string `yaml:"suggestion-url"`
 }
 
 type searchWidget struct {
-	widgetBase   `yaml:",inline"`
-	cachedHTML   template.HTML `yaml:"-"`
-	SearchEngine string        `yaml:"search-engine"`
-	Bangs        []SearchBang  `yaml:"bangs"`
-	NewTab       bool          `yaml:"new-tab"`
-	Autofocus    bool          `yaml:"autofocus"`
-	Placeholder  string        `yaml:"placeholder"`
+	widgetBase    `yaml:",inline"`
+	cachedHTML    template.HTML `yaml:"-"`
+	SearchEngine  string        `yaml:"search-engine"`
+	SuggestionURL string        `yaml:"suggestion-url"`
+	Suggestions   bool          `yaml:"suggestions"`
+	Bangs         []SearchBang  `yaml:"bangs"`
+	NewTab        bool          `yaml:"new-tab"`
+	Autofocus     bool          `yaml:"autofocus"`
+	Placeholder   string        `yaml:"placeholder"`
 }
 
 func convertSearchUrl(url string) string {
@@ -30,9 +42,22 @@ func convertSearchUrl(url string) string {
 	return strings.ReplaceAll(url, "{QUERY}", "!QUERY!")
 }
 
-var searchEngines = map[string]string{
-	"duckduckgo": "https://duckduckgo.com/?q={QUERY}",
-	"google":     "https://www.google.com/search?q={QUERY}",
+type searchEngine struct {
+	title         string
+	url           string
+	suggestionURL string
+}
+
+// The suggestion URLs all respond in the OpenSearch suggestions format
+var searchEngines = map[string]searchEngine{
+	"duckduckgo": {"DuckDuckGo", "https://duckduckgo.com/?q={QUERY}", "https://duckduckgo.com/ac/?type=list&q={QUERY}"},
+	"google":     {"Google", "https://www.google.com/search?q={QUERY}", "https://suggestqueries.google.com/complete/search?client=firefox&ie=utf-8&oe=utf-8&q={QUERY}"},
+	"bing":       {"Bing", "https://www.bing.com/search?q={QUERY}", "https://api.bing.com/osjson.aspx?query={QUERY}"},
+	"brave":      {"Brave", "https://search.brave.com/search?q={QUERY}", "https://search.brave.com/api/suggest?q={QUERY}"},
+	"startpage":  {"Startpage", "https://www.startpage.com/do/search?q={QUERY}", ""},
+	"wikipedia":  {"Wikipedia", "https://en.wikipedia.org/w/index.php?search={QUERY}", "https://en.wikipedia.org/w/api.php?action=opensearch&format=json&search={QUERY}"},
+	"youtube":    {"YouTube", "https://www.youtube.com/results?search_query={QUERY}", "https://suggestqueries.google.com/complete/search?client=firefox&ie=utf-8&oe=utf-8&ds=yt&q={QUERY}"},
+	"github":     {"GitHub", "https://github.com/search?q={QUERY}", ""},
 }
 
 func (widget *searchWidget) initialize() error {
@@ -46,22 +71,41 @@ func (widget *searchWidget) initialize() error {
 		widget.Placeholder = "Type here to search…"
 	}
 
-	if url, ok := searchEngines[widget.SearchEngine]; ok {
-		widget.SearchEngine = url
+	if engine, ok := searchEngines[widget.SearchEngine]; ok {
+		widget.SearchEngine = engine.url
+		if widget.SuggestionURL == "" {
+			widget.SuggestionURL = engine.suggestionURL
+		}
+	}
+
+	if widget.Suggestions && widget.SuggestionURL == "" {
+		return fmt.Errorf("suggestions require a suggestion-url when using a custom search engine")
 	}
 
 	widget.SearchEngine = convertSearchUrl(widget.SearchEngine)
 
 	for i := range widget.Bangs {
-		if widget.Bangs[i].Shortcut == "" {
+		bang := &widget.Bangs[i]
+
+		if bang.Shortcut == "" {
 			return fmt.Errorf("search bang #%d has no shortcut", i+1)
 		}
 
-		if widget.Bangs[i].URL == "" {
+		if bang.URL == "" {
 			return fmt.Errorf("search bang #%d has no URL", i+1)
 		}
 
-		widget.Bangs[i].URL = convertSearchUrl(widget.Bangs[i].URL)
+		if engine, ok := searchEngines[bang.URL]; ok {
+			bang.URL = engine.url
+			if bang.Title == "" {
+				bang.Title = engine.title
+			}
+			if bang.SuggestionURL == "" {
+				bang.SuggestionURL = engine.suggestionURL
+			}
+		}
+
+		bang.URL = convertSearchUrl(bang.URL)
 	}
 
 	widget.cachedHTML = widget.renderTemplate(widget, searchWidgetTemplate)
@@ -71,3 +115,84 @@ func (widget *searchWidget) initialize() error {
 func (widget *searchWidget) Render() template.HTML {
 	return widget.cachedHTML
 }
+
+// Suggestions are requested through Glance so that the browser doesn't have
+// to talk to the search engine, and so that CORS isn't an issue
+func (widget *searchWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("path") != "suggestions" || r.Method != http.MethodGet || !widget.Suggestions {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	query, _ = limitStringLength(query, 200)
+	suggestionURL := widget.SuggestionURL
+
+	if value := r.URL.Query().Get("bang"); value != "" {
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 0 || i >= len(widget.Bangs) {
+			http.Error(w, "bang not found", http.StatusNotFound)
+			return
+		}
+
+		// bangs without suggestions of their own get none rather than the
+		// ones of the default engine, which would likely be unrelated
+		suggestionURL = widget.Bangs[i].SuggestionURL
+	}
+
+	suggestions := []string{}
+
+	if query != "" && suggestionURL != "" {
+		var err error
+		suggestions, err = widget.fetchSuggestions(r, suggestionURL, query)
+		if err != nil {
+			widget.logger().Debug("Failed to fetch search suggestions", "error", err)
+			http.Error(w, "failed to fetch suggestions", http.StatusBadGateway)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(suggestions)
+}
+
+func (widget *searchWidget) fetchSuggestions(r *http.Request, suggestionURL, query string) ([]string, error) {
+	requestURL := strings.ReplaceAll(suggestionURL, "{QUERY}", url.QueryEscape(query))
+	request, err := http.NewRequestWithContext(r.Context(), "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := widget.httpClient(false).Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+
+	if !gjson.ValidBytes(body) {
+		return nil, fmt.Errorf("invalid response JSON")
+	}
+
+	suggestions := []string{}
+	for _, suggestion := range gjson.GetBytes(body, "1").Array() {
+		if value := suggestion.String(); value != "" {
+			suggestions = append(suggestions, value)
+		}
+
+		if len(suggestions) == searchWidgetMaxSuggestions {
+			break
+		}
+	}
+
+	return suggestions, nil
+}
@@ -0,0 +1,430 @@
+package glance
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+var sensorsWidgetTemplate = mustParseTemplate("sensors.html", "widget-base.html")
+
+var sensorStatNames = []string{"min", "avg", "max"}
+
+type sensorsWidget struct {
+	widgetBase    `yaml:",inline"`
+	MQTT          *mqttConnection          `yaml:"mqtt"`
+	HomeAssistant *homeAssistantConnection `yaml:"home-assistant"`
+	Prometheus    *prometheusConnection    `yaml:"prometheus"`
+	Sensors       []sensorSource           `yaml:"sensors"`
+	Stats         []string                 `yaml:"stats"`
+	Unit          string                   `yaml:"unit"`
+	Precision     *int                     `yaml:"precision"`
+	ShowSensors   bool                     `yaml:"show-sensors"`
+	Overall       sensorStats              `yaml:"-"`
+	Groups        []sensorGroup            `yaml:"-"`
+	unit          string
+}
+
+type sensorSource struct {
+	Name  string `yaml:"name"`
+	Group string `yaml:"group"`
+	Topic string `yaml:"topic"`
+	Field string `yaml:"field"`
+	// Home Assistant entity ID
+	Entity string `yaml:"entity"`
+	Query  string `yaml:"query"`
+}
+
+type prometheusConnection struct {
+	URL           string `yaml:"url"`
+	Username      string `yaml:"username"`
+	Password      string `yaml:"password"`
+	AllowInsecure bool   `yaml:"allow-insecure"`
+}
+
+type sensorReading struct {
+	Name     string
+	Value    float64
+	HasValue bool
+	unit     string
+}
+
+type sensorStats struct {
+	Min   float64
+	Max   float64
+	Avg   float64
+	Count int
+}
+
+type sensorGroup struct {
+	Name     string
+	Stats    sensorStats
+	Readings []sensorReading
+}
+
+func (widget *sensorsWidget) initialize() error {
+	widget.withTitle("Sensors").withCacheDuration(time.Minute)
+
+	if len(widget.Sensors) == 0 {
+		return errors.New("no sensors specified")
+	}
+
+	for i := range widget.Sensors {
+		sensor := &widget.Sensors[i]
+		sources := 0
+
+		if sensor.Topic != "" {
+			sources++
+			if widget.MQTT == nil {
+				return fmt.Errorf("sensor %d: mqtt is required to read topics", i+1)
+			}
+			if isMQTTTopicWildcard(sensor.Topic) {
+				return fmt.Errorf("sensor %d: topic can't contain wildcards", i+1)
+			}
+		}
+
+		if sensor.Entity != "" {
+			sources++
+			if widget.HomeAssistant == nil {
+				return fmt.Errorf("sensor %d: home-assistant is required to read entities", i+1)
+			}
+		}
+
+		if sensor.Query != "" {
+			sources++
+			if widget.Prometheus == nil {
+				return fmt.Errorf("sensor %d: prometheus is required to run queries", i+1)
+			}
+		}
+
+		if sources != 1 {
+			return fmt.Errorf("sensor %d: exactly one of topic, entity or query is required", i+1)
+		}
+
+		if sensor.Name == "" {
+			sensor.Name = cmp.Or(sensor.Topic, sensor.Entity, sensor.Query)
+		}
+	}
+
+	if widget.MQTT != nil {
+		if err := widget.MQTT.initialize(); err != nil {
+			return fmt.Errorf("mqtt: %v", err)
+		}
+	}
+
+	if widget.HomeAssistant != nil {
+		if err := widget.HomeAssistant.initialize(); err != nil {
+			return fmt.Errorf("home-assistant: %v", err)
+		}
+	}
+
+	if widget.Prometheus != nil {
+		if widget.Prometheus.URL == "" {
+			return errors.New("prometheus: url is required")
+		}
+
+		widget.Prometheus.URL = strings.TrimRight(widget.Prometheus.URL, "/")
+	}
+
+	if len(widget.Stats) == 0 {
+		widget.Stats = sensorStatNames
+	}
+
+	for _, stat := range widget.Stats {
+		if !slices.Contains(sensorStatNames, stat) {
+			return fmt.Errorf("unknown stat %s, must be one of %s", stat, strings.Join(sensorStatNames, ", "))
+		}
+	}
+
+	if widget.Precision == nil || *widget.Precision < 0 {
+		precision := 1
+		widget.Precision = &precision
+	}
+
+	return nil
+}
+
+func (widget *sensorsWidget) update(ctx context.Context) {
+	readings, err := widget.fetchReadings(ctx)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	groups := make([]sensorGroup, 0)
+	all := make([]float64, 0, len(readings))
+	unit := widget.Unit
+
+	for i := range readings {
+		name := widget.Sensors[i].Group
+
+		index := slices.IndexFunc(groups, func(g sensorGroup) bool { return g.Name == name })
+		if index == -1 {
+			groups = append(groups, sensorGroup{Name: name})
+			index = len(groups) - 1
+		}
+
+		groups[index].Readings = append(groups[index].Readings, readings[i])
+
+		if readings[i].HasValue {
+			all = append(all, readings[i].Value)
+			unit = cmp.Or(unit, readings[i].unit)
+		}
+	}
+
+	for i := range groups {
+		values := make([]float64, 0, len(groups[i].Readings))
+		for _, reading := range groups[i].Readings {
+			if reading.HasValue {
+				values = append(values, reading.Value)
+			}
+		}
+
+		groups[i].Stats = newSensorStats(values)
+	}
+
+	// a single group without a name is the same as the overall stats
+	if len(groups) == 1 && groups[0].Name == "" && !widget.ShowSensors {
+		groups = nil
+	}
+
+	widget.Overall = newSensorStats(all)
+	widget.Groups = groups
+	widget.unit = unit
+}
+
+func (s sensorStats) Get(stat string) float64 {
+	switch stat {
+	case "min":
+		return s.Min
+	case "max":
+		return s.Max
+	}
+
+	return s.Avg
+}
+
+func newSensorStats(values []float64) sensorStats {
+	if len(values) == 0 {
+		return sensorStats{}
+	}
+
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+
+	return sensorStats{
+		Min:   slices.Min(values),
+		Max:   slices.Max(values),
+		Avg:   sum / float64(len(values)),
+		Count: len(values),
+	}
+}
+
+// Returns a reading for every sensor, in the same order, including those that
+// couldn't be read
+func (widget *sensorsWidget) fetchReadings(ctx context.Context) ([]sensorReading, error) {
+	readings := make([]sensorReading, len(widget.Sensors))
+	for i := range widget.Sensors {
+		readings[i].Name = widget.Sensors[i].Name
+	}
+
+	var errs []error
+
+	if widget.MQTT != nil {
+		errs = append(errs, widget.readMQTT(ctx, readings))
+	}
+
+	if widget.HomeAssistant != nil {
+		errs = append(errs, widget.readHomeAssistant(readings))
+	}
+
+	if widget.Prometheus != nil {
+		errs = append(errs, widget.readPrometheus(ctx, readings))
+	}
+
+	missing := make([]string, 0)
+	for i := range readings {
+		if !readings[i].HasValue {
+			missing = append(missing, readings[i].Name)
+		}
+	}
+
+	err := errors.Join(errs...)
+
+	if len(missing) == len(readings) {
+		if err == nil {
+			err = errors.New("none of the sensors had a value")
+		}
+
+		return nil, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	if len(missing) > 0 {
+		if err == nil {
+			err = fmt.Errorf("no value for %s", strings.Join(missing, ", "))
+		}
+
+		return readings, fmt.Errorf("%w: %v", errPartialContent, err)
+	}
+
+	return readings, nil
+}
+
+func (widget *sensorsWidget) readMQTT(ctx context.Context, readings []sensorReading) error {
+	topics := make([]string, 0)
+	for _, sensor := range widget.Sensors {
+		if sensor.Topic != "" && !slices.Contains(topics, sensor.Topic) {
+			topics = append(topics, sensor.Topic)
+		}
+	}
+
+	messages, err := widget.MQTT.latestMessages(ctx, topics)
+	if err != nil {
+		return fmt.Errorf("mqtt: %v", err)
+	}
+
+	for i, sensor := range widget.Sensors {
+		payload, ok := messages[sensor.Topic]
+		if sensor.Topic == "" || !ok {
+			continue
+		}
+
+		// payloads are often JSON objects with several values, such as those
+		// of Zigbee2MQTT
+		value := strings.TrimSpace(string(payload))
+		if sensor.Field != "" {
+			value = gjson.Get(value, sensor.Field).String()
+		}
+
+		readings[i].Value, readings[i].HasValue = parseSensorValue(value)
+	}
+
+	return nil
+}
+
+func (widget *sensorsWidget) readHomeAssistant(readings []sensorReading) error {
+	client := widget.httpClient(widget.HomeAssistant.AllowInsecure)
+
+	states, err := widget.HomeAssistant.fetchEntityStates(client)
+	if err != nil {
+		return fmt.Errorf("home assistant: %v", err)
+	}
+
+	for i, sensor := range widget.Sensors {
+		state, ok := states[sensor.Entity]
+		if sensor.Entity == "" || !ok {
+			continue
+		}
+
+		readings[i].Value, readings[i].HasValue = parseSensorValue(state.State)
+		readings[i].unit = state.Attributes.UnitOfMeasurement
+	}
+
+	return nil
+}
+
+func (widget *sensorsWidget) readPrometheus(ctx context.Context, readings []sensorReading) error {
+	client := widget.httpClient(widget.Prometheus.AllowInsecure)
+	var errs []error
+
+	for i, sensor := range widget.Sensors {
+		if sensor.Query == "" {
+			continue
+		}
+
+		value, err := widget.Prometheus.query(ctx, client, sensor.Query)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("prometheus: %s: %v", sensor.Name, err))
+			continue
+		}
+
+		readings[i].Value, readings[i].HasValue = value, !math.IsNaN(value)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Runs an instant query that has to result in a single number
+func (p *prometheusConnection) query(ctx context.Context, client requestDoer, query string) (float64, error) {
+	request, _ := http.NewRequestWithContext(ctx, "GET", p.URL+"/api/v1/query?query="+url.QueryEscape(query), nil)
+	if p.Username != "" {
+		request.SetBasicAuth(p.Username, p.Password)
+	}
+
+	response, err := decodeJsonFromRequest[prometheusQueryResponseJson](client, request)
+	if err != nil {
+		return 0, err
+	}
+
+	if response.Status != "success" {
+		return 0, fmt.Errorf("query failed: %s", response.Error)
+	}
+
+	result := gjson.ParseBytes(response.Data.Result)
+
+	var value gjson.Result
+	switch response.Data.ResultType {
+	case "scalar":
+		value = result.Get("1")
+	case "vector":
+		series := result.Array()
+		if len(series) == 0 {
+			return math.NaN(), nil
+		}
+
+		if len(series) > 1 {
+			return 0, fmt.Errorf("query returned %d series, combine them with something like avg()", len(series))
+		}
+
+		value = series[0].Get("value.1")
+	default:
+		return 0, fmt.Errorf("unsupported result type %s", response.Data.ResultType)
+	}
+
+	parsed, ok := parseSensorValue(value.String())
+	if !ok {
+		return 0, fmt.Errorf("value %q is not a number", value.String())
+	}
+
+	return parsed, nil
+}
+
+type prometheusQueryResponseJson struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+func parseSensorValue(value string) (float64, bool) {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+		return 0, false
+	}
+
+	return parsed, true
+}
+
+func (widget *sensorsWidget) Format(value float64) string {
+	return widget.printer().Sprintf("%.*f", *widget.Precision, value) + widget.unit
+}
+
+func (widget *sensorsWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, sensorsWidgetTemplate)
+}
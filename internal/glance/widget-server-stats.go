@@ -3,7 +3,6 @@ package glance
 import (
 	"context"
 	"html/template"
-	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
@@ -51,7 +50,7 @@ func (widget *serverStatsWidget) update(context.Context) {
 
 			if len(errs) > 0 {
 				for i := range errs {
-					slog.Warn("Getting system info: " + errs[i].Error())
+					widget.logger().Warn("Getting system info: " + errs[i].Error())
 				}
 			}
 
@@ -61,9 +60,9 @@ func (widget *serverStatsWidget) update(context.Context) {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				info, err := fetchRemoteServerInfo(serv)
+				info, err := fetchRemoteServerInfo(widget.httpClient(false), serv)
 				if err != nil {
-					slog.Warn("Getting remote system info: " + err.Error())
+					widget.logger().Warn("Getting remote system info: " + err.Error())
 					serv.IsReachable = false
 					serv.Info = &sysinfo.SystemInfo{
 						Hostname: "Unnamed server #" + strconv.Itoa(i+1),
@@ -99,7 +98,7 @@ type serverStatsRequest struct {
 	// Provider                   string              `yaml:"provider"`
 }
 
-func fetchRemoteServerInfo(infoReq *serverStatsRequest) (*sysinfo.SystemInfo, error) {
+func fetchRemoteServerInfo(client requestDoer, infoReq *serverStatsRequest) (*sysinfo.SystemInfo, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(infoReq.Timeout))
 	defer cancel()
 
@@ -108,7 +107,7 @@ func fetchRemoteServerInfo(infoReq *serverStatsRequest) (*sysinfo.SystemInfo, er
 		request.Header.Set("Authorization", "Bearer "+infoReq.Token)
 	}
 
-	info, err := decodeJsonFromRequest[*sysinfo.SystemInfo](defaultHTTPClient, request)
+	info, err := decodeJsonFromRequest[*sysinfo.SystemInfo](client, request)
 	if err != nil {
 		return nil, err
 	}
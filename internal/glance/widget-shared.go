@@ -1,8 +1,17 @@
 package glance
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
 	"math"
+	"net/http"
+	"slices"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -25,8 +34,36 @@ type forumPost struct {
 	IsCrosspost     bool
 }
 
+func (p forumPost) ID() string {
+	return seenItemID(p.DiscussionUrl)
+}
+
 type forumPostList []forumPost
 
+// The link of the post itself rather than the discussion, when it has one
+func (p forumPost) SaveURL() string {
+	return ternary(p.TargetUrl != "", p.TargetUrl, p.DiscussionUrl)
+}
+
+func (p forumPostList) titleByURL(url string) (string, bool) {
+	for i := range p {
+		if p[i].SaveURL() == url {
+			return p[i].Title, true
+		}
+	}
+
+	return "", false
+}
+
+func (p forumPostList) briefing(source string, headlines int) []string {
+	titles := make([]string, 0, min(headlines, len(p)))
+	for i := 0; i < len(p) && i < headlines; i++ {
+		titles = append(titles, p[i].Title)
+	}
+
+	return briefingHeadlines(source, titles, headlines)
+}
+
 const depreciatePostsOlderThanHours = 7
 const maxDepreciation = 0.9
 const maxDepreciationAfterHours = 24
@@ -57,8 +94,191 @@ func (p forumPostList) calculateEngagement() {
 	}
 }
 
+func (p forumPostList) filterByMinimums(minScore int, minComments int) forumPostList {
+	filtered := make(forumPostList, 0, len(p))
+
+	for i := range p {
+		if p[i].Score >= minScore && p[i].CommentCount >= minComments {
+			filtered = append(filtered, p[i])
+		}
+	}
+
+	return filtered
+}
+
+// Removes posts that have any of the given tags
+func (p forumPostList) filterByExcludedTags(excluded []string) forumPostList {
+	if len(excluded) == 0 {
+		return p
+	}
+
+	filtered := make(forumPostList, 0, len(p))
+
+outer:
+	for i := range p {
+		for _, tag := range p[i].Tags {
+			if slices.Contains(excluded, strings.ToLower(tag)) {
+				continue outer
+			}
+		}
+
+		filtered = append(filtered, p[i])
+	}
+
+	return filtered
+}
+
+// Removes posts that appear more than once, such as when merging the posts of
+// multiple feeds, keeping the first occurrence
+func (p forumPostList) deduplicate() forumPostList {
+	seen := make(map[string]struct{}, len(p))
+	deduplicated := make(forumPostList, 0, len(p))
+
+	for i := range p {
+		if _, exists := seen[p[i].DiscussionUrl]; exists {
+			continue
+		}
+
+		seen[p[i].DiscussionUrl] = struct{}{}
+		deduplicated = append(deduplicated, p[i])
+	}
+
+	return deduplicated
+}
+
+func (p forumPostList) sortByNewest() {
+	sort.Slice(p, func(i, j int) bool {
+		return p[i].TimePosted.After(p[j].TimePosted)
+	})
+}
+
 func (p forumPostList) sortByEngagement() {
 	sort.Slice(p, func(i, j int) bool {
 		return p[i].Engagement > p[j].Engagement
 	})
 }
+
+type checklistToggleRequest struct {
+	Item string `json:"item"`
+	Done bool   `json:"done"`
+}
+
+// Decodes the request sent by checklists.js when an item gets checked off,
+// responding with an error and returning false if it isn't valid
+func decodeChecklistToggleRequest(w http.ResponseWriter, r *http.Request) (checklistToggleRequest, bool) {
+	var body checklistToggleRequest
+
+	if r.Method != http.MethodPost || r.PathValue("path") != "done" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return body, false
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4*1024)).Decode(&body); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return body, false
+	}
+
+	return body, true
+}
+
+// Proxies images through Glance so that credentials needed to load them never
+// make it to the browser. Only images that the widget is currently showing can
+// be requested.
+type imageProxy struct {
+	mu   sync.Mutex
+	urls map[string]string
+}
+
+type imageProxySet map[string]string
+
+// Returns the URL through which the image at baseURL+path can be loaded, path may
+// also be an absolute URL in which case baseURL can be left empty
+func (set imageProxySet) add(widget *widgetBase, baseURL, path string) string {
+	if path == "" {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(baseURL + path))
+	key := hex.EncodeToString(hash[:8])
+	set[key] = baseURL + path
+
+	return widget.Providers.widgetURLResolver(widget.GetID(), "image?key="+key)
+}
+
+// Replaces the images that can be requested with the given set
+func (p *imageProxy) commit(set imageProxySet) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.urls = set
+}
+
+func (p *imageProxy) serve(w http.ResponseWriter, r *http.Request, client requestDoer, authorize func(*http.Request)) {
+	p.mu.Lock()
+	url, exists := p.urls[r.URL.Query().Get("key")]
+	p.mu.Unlock()
+
+	if !exists {
+		http.Error(w, "image not found", http.StatusNotFound)
+		return
+	}
+
+	request, err := http.NewRequestWithContext(r.Context(), "GET", url, nil)
+	if err != nil {
+		http.Error(w, "image not found", http.StatusNotFound)
+		return
+	}
+
+	if authorize != nil {
+		authorize(request)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		slog.Error("Failed to fetch proxied image", "url", url, "error", err)
+		http.Error(w, "failed to fetch image", http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK || !strings.HasPrefix(response.Header.Get("Content-Type"), "image/") {
+		http.Error(w, "failed to fetch image", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", response.Header.Get("Content-Type"))
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	io.Copy(w, response.Body)
+}
+
+type forumPostJson struct {
+	Title         string    `json:"title"`
+	URL           string    `json:"url"`
+	DiscussionURL string    `json:"discussion_url"`
+	Domain        string    `json:"domain,omitempty"`
+	ThumbnailURL  string    `json:"thumbnail_url,omitempty"`
+	Comments      int       `json:"comments"`
+	Score         int       `json:"score"`
+	Tags          []string  `json:"tags,omitempty"`
+	PostedAt      time.Time `json:"posted_at"`
+}
+
+func (p forumPostList) data() []forumPostJson {
+	posts := make([]forumPostJson, 0, len(p))
+
+	for i := range p {
+		posts = append(posts, forumPostJson{
+			Title:         p[i].Title,
+			URL:           p[i].SaveURL(),
+			DiscussionURL: p[i].DiscussionUrl,
+			Domain:        p[i].TargetUrlDomain,
+			ThumbnailURL:  p[i].ThumbnailUrl,
+			Comments:      p[i].CommentCount,
+			Score:         p[i].Score,
+			Tags:          p[i].Tags,
+			PostedAt:      p[i].TimePosted,
+		})
+	}
+
+	return posts
+}
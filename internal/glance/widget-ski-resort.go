@@ -0,0 +1,306 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/tidwall/gjson"
+)
+
+var skiResortWidgetTemplate = mustParseTemplate("ski-resort.html", "widget-base.html")
+
+var skiResortNumberPattern = regexp.MustCompile(`\d+`)
+
+type skiResortWidget struct {
+	widgetBase    `yaml:",inline"`
+	Liftie        string            `yaml:"liftie"`
+	Report        *skiResortReport  `yaml:"report"`
+	Webcams       []skiResortWebcam `yaml:"webcams"`
+	WebcamRefresh durationField     `yaml:"webcam-refresh"`
+	Conditions    skiConditions     `yaml:"-"`
+}
+
+// Where to find each value in the resort's snow report, as CSS selectors for
+// pages and as paths for JSON responses
+type skiResortReport struct {
+	URL        string            `yaml:"url"`
+	Headers    map[string]string `yaml:"headers"`
+	SnowDepth  string            `yaml:"snow-depth"`
+	NewSnow    string            `yaml:"new-snow"`
+	Conditions string            `yaml:"conditions"`
+	LiftsOpen  string            `yaml:"lifts-open"`
+	LiftsTotal string            `yaml:"lifts-total"`
+	RunsOpen   string            `yaml:"runs-open"`
+	RunsTotal  string            `yaml:"runs-total"`
+}
+
+type skiResortWebcam struct {
+	Name          string      `yaml:"name"`
+	URL           string      `yaml:"url"`
+	AllowInsecure bool        `yaml:"allow-insecure"`
+	ProxyURL      string      `yaml:"-"`
+	snapshot      cachedImage `yaml:"-"`
+}
+
+type skiConditions struct {
+	SnowDepth  string
+	NewSnow    string
+	Conditions string
+	LiftsOpen  int
+	LiftsTotal int
+	HasLifts   bool
+	RunsOpen   int
+	RunsTotal  int
+	HasRuns    bool
+}
+
+func (widget *skiResortWidget) initialize() error {
+	widget.withTitle("Ski Resort").withCacheDuration(30 * time.Minute)
+
+	if widget.Liftie == "" && widget.Report == nil && len(widget.Webcams) == 0 {
+		return errors.New("at least one of liftie, report or webcams is required")
+	}
+
+	if widget.Report != nil {
+		if widget.Report.URL == "" {
+			return errors.New("report: url is required")
+		}
+
+		r := widget.Report
+		if r.SnowDepth == "" && r.NewSnow == "" && r.Conditions == "" && r.LiftsOpen == "" && r.RunsOpen == "" {
+			return errors.New("report: at least one of snow-depth, new-snow, conditions, lifts-open or runs-open is required")
+		}
+	}
+
+	for i := range widget.Webcams {
+		if widget.Webcams[i].URL == "" {
+			return fmt.Errorf("webcam %d: url is required", i+1)
+		}
+	}
+
+	if widget.WebcamRefresh == 0 {
+		widget.WebcamRefresh = durationField(time.Minute)
+	} else if widget.WebcamRefresh < durationField(10*time.Second) {
+		return errors.New("webcam-refresh must be at least 10s")
+	}
+
+	return nil
+}
+
+// Webcams are loaded by the browser, the update only gets the conditions
+func (widget *skiResortWidget) update(ctx context.Context) {
+	for i := range widget.Webcams {
+		widget.Webcams[i].ProxyURL = widget.Providers.widgetURLResolver(widget.GetID(), "webcam?index="+strconv.Itoa(i))
+	}
+
+	if widget.Liftie == "" && widget.Report == nil {
+		widget.canContinueUpdateAfterHandlingErr(nil)
+		return
+	}
+
+	var conditions skiConditions
+	var errs []error
+
+	if widget.Report != nil {
+		if err := widget.Report.fetch(ctx, widget.httpClient(false), &conditions); err != nil {
+			errs = append(errs, fmt.Errorf("snow report: %v", err))
+		}
+	}
+
+	// lift statuses from Liftie are more up to date than most snow reports
+	if widget.Liftie != "" {
+		if err := fetchLiftieStatus(ctx, widget.httpClient(false), widget.Liftie, &conditions); err != nil {
+			errs = append(errs, fmt.Errorf("liftie: %v", err))
+		}
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		if len(errs) == ternary(widget.Liftie != "", 1, 0)+ternary(widget.Report != nil, 1, 0) {
+			err = fmt.Errorf("%w: %v", errNoContent, err)
+		} else {
+			err = fmt.Errorf("%w: %v", errPartialContent, err)
+		}
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Conditions = conditions
+}
+
+func (r *skiResortReport) fetch(ctx context.Context, client requestDoer, conditions *skiConditions) error {
+	request, err := http.NewRequestWithContext(ctx, "GET", r.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	setBrowserUserAgentHeader(request)
+	for key, value := range r.Headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	var find func(string) (string, error)
+
+	if strings.Contains(response.Header.Get("Content-Type"), "json") {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return err
+		}
+
+		if !gjson.ValidBytes(body) {
+			return errors.New("invalid JSON")
+		}
+
+		find = func(path string) (string, error) {
+			result := gjson.GetBytes(body, path)
+			if !result.Exists() {
+				return "", fmt.Errorf("nothing found at %s", path)
+			}
+
+			return result.String(), nil
+		}
+	} else {
+		document, err := goquery.NewDocumentFromReader(response.Body)
+		if err != nil {
+			return err
+		}
+
+		find = func(selector string) (string, error) {
+			element := document.Find(selector).First()
+			if element.Length() == 0 {
+				return "", fmt.Errorf("no elements matched %s", selector)
+			}
+
+			return strings.Join(strings.Fields(element.Text()), " "), nil
+		}
+	}
+
+	var errs []error
+
+	text := func(query string, target *string) {
+		if query == "" {
+			return
+		}
+
+		value, err := find(query)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+
+		*target, _ = limitStringLength(value, 100)
+	}
+
+	number := func(query string, target *int) bool {
+		var value string
+		text(query, &value)
+		if value == "" {
+			return false
+		}
+
+		match := skiResortNumberPattern.FindString(value)
+		if match == "" {
+			errs = append(errs, fmt.Errorf("no number in %q", value))
+			return false
+		}
+
+		*target, _ = strconv.Atoi(match)
+		return true
+	}
+
+	text(r.SnowDepth, &conditions.SnowDepth)
+	text(r.NewSnow, &conditions.NewSnow)
+	text(r.Conditions, &conditions.Conditions)
+	conditions.HasLifts = number(r.LiftsOpen, &conditions.LiftsOpen)
+	number(r.LiftsTotal, &conditions.LiftsTotal)
+	conditions.HasRuns = number(r.RunsOpen, &conditions.RunsOpen)
+	number(r.RunsTotal, &conditions.RunsTotal)
+
+	return errors.Join(errs...)
+}
+
+// Liftie (https://liftie.info) tracks the status of the lifts of a few hundred
+// resorts, which are identified by the last part of their URL such as "zermatt"
+func fetchLiftieStatus(ctx context.Context, client requestDoer, resort string, conditions *skiConditions) error {
+	request, _ := http.NewRequestWithContext(ctx, "GET", "https://liftie.info/api/resort/"+url.PathEscape(resort), nil)
+
+	response, err := decodeJsonFromRequest[liftieResortResponseJson](client, request)
+	if err != nil {
+		return err
+	}
+
+	stats := response.Lifts.Stats
+	total := stats.Open + stats.Hold + stats.Scheduled + stats.Closed
+	if total == 0 {
+		return errors.New("resort has no lifts")
+	}
+
+	conditions.LiftsOpen = stats.Open
+	conditions.LiftsTotal = total
+	conditions.HasLifts = true
+
+	return nil
+}
+
+type liftieResortResponseJson struct {
+	Lifts struct {
+		Stats struct {
+			Open      int `json:"open"`
+			Hold      int `json:"hold"`
+			Scheduled int `json:"scheduled"`
+			Closed    int `json:"closed"`
+		} `json:"stats"`
+	} `json:"lifts"`
+}
+
+func (widget *skiResortWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || r.PathValue("path") != "webcam" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	index, err := strconv.Atoi(r.URL.Query().Get("index"))
+	if err != nil || index < 0 || index >= len(widget.Webcams) {
+		http.Error(w, "webcam not found", http.StatusNotFound)
+		return
+	}
+
+	webcam := &widget.Webcams[index]
+
+	webcam.snapshot.serve(w, r, time.Duration(widget.WebcamRefresh)/2, func(ctx context.Context) ([]byte, string, error) {
+		ctx, cancel := context.WithTimeout(ctx, cameraSnapshotTimeout)
+		defer cancel()
+
+		return fetchCameraImage(ctx, widget.httpClient(webcam.AllowInsecure), webcam.URL, "", "")
+	})
+}
+
+func (widget *skiResortWidget) WebcamRefreshMilliseconds() int64 {
+	return time.Duration(widget.WebcamRefresh).Milliseconds()
+}
+
+func (widget *skiResortWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, skiResortWidgetTemplate)
+}
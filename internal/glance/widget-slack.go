@@ -0,0 +1,456 @@
+package glance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var slackWidgetTemplate = mustParseTemplate("slack.html", "widget-base.html")
+
+type slackWidget struct {
+	widgetBase    `yaml:",inline"`
+	Token         string         `yaml:"token"`
+	Limit         int            `yaml:"limit"`
+	CollapseAfter int            `yaml:"collapse-after"`
+	Messages      []slackMessage `yaml:"-"`
+	MentionsCount int            `yaml:"-"`
+	DMsCount      int            `yaml:"-"`
+}
+
+func (widget *slackWidget) initialize() error {
+	widget.withTitle("Slack").withCacheDuration(5 * time.Minute)
+
+	if widget.Token == "" {
+		return errors.New("token is required")
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 15
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *slackWidget) update(ctx context.Context) {
+	client := &slackClient{httpClient: widget.httpClient(false), token: widget.Token}
+	messages, err := fetchSlackUnreadMessages(client)
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.MentionsCount = 0
+	widget.DMsCount = 0
+
+	for i := range messages {
+		if messages[i].IsDM {
+			widget.DMsCount++
+		} else {
+			widget.MentionsCount++
+		}
+	}
+
+	if len(messages) > widget.Limit {
+		messages = messages[:widget.Limit]
+	}
+
+	if client.workspaceURL != "" {
+		widget.withTitleURL(client.workspaceURL)
+	}
+
+	widget.Messages = messages
+}
+
+func (widget *slackWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, slackWidgetTemplate)
+}
+
+type slackMessage struct {
+	IsDM    bool
+	Channel string
+	Sender  string
+	Text    string
+	URL     string
+	SentAt  time.Time
+}
+
+type slackResponseBaseJson struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+type slackAuthTestResponseJson struct {
+	UserID string `json:"user_id"`
+	URL    string `json:"url"`
+}
+
+type slackMessageJson struct {
+	TS       string `json:"ts"`
+	User     string `json:"user"`
+	Username string `json:"username"`
+	Text     string `json:"text"`
+	Subtype  string `json:"subtype"`
+}
+
+type slackConversationsListResponseJson struct {
+	Channels []struct {
+		ID     string `json:"id"`
+		IsMpim bool   `json:"is_mpim"`
+		Name   string `json:"name"`
+	} `json:"channels"`
+	ResponseMetadata struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+}
+
+type slackConversationInfoResponseJson struct {
+	Channel struct {
+		LastRead string `json:"last_read"`
+	} `json:"channel"`
+}
+
+type slackConversationHistoryResponseJson struct {
+	Messages []slackMessageJson `json:"messages"`
+}
+
+type slackSearchResponseJson struct {
+	Messages struct {
+		Matches []struct {
+			slackMessageJson
+			Permalink string `json:"permalink"`
+			Channel   struct {
+				ID     string `json:"id"`
+				Name   string `json:"name"`
+				IsIM   bool   `json:"is_im"`
+				IsMpim bool   `json:"is_mpim"`
+			} `json:"channel"`
+		} `json:"matches"`
+	} `json:"messages"`
+}
+
+type slackUserInfoResponseJson struct {
+	User struct {
+		Name    string `json:"name"`
+		Profile struct {
+			DisplayName string `json:"display_name"`
+			RealName    string `json:"real_name"`
+		} `json:"profile"`
+	} `json:"user"`
+}
+
+type slackClient struct {
+	httpClient   requestDoer
+	token        string
+	userID       string
+	workspaceURL string
+
+	usersMutex sync.Mutex
+	users      map[string]string
+}
+
+// Slack responds with a 200 status code even when a request fails, the
+// actual result is indicated by the "ok" property in the response body
+func slackAPIRequest[T any](client *slackClient, method string, params url.Values) (T, error) {
+	var result T
+
+	request, _ := http.NewRequest("GET", "https://slack.com/api/"+method+"?"+params.Encode(), nil)
+	request.Header.Set("Authorization", "Bearer "+client.token)
+
+	body, err := decodeJsonFromRequest[json.RawMessage](client.httpClient, request)
+	if err != nil {
+		return result, err
+	}
+
+	var base slackResponseBaseJson
+	if err := json.Unmarshal(body, &base); err != nil {
+		return result, err
+	}
+
+	if !base.Ok {
+		return result, fmt.Errorf("slack %s request failed: %s", method, base.Error)
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (client *slackClient) userName(userID string) string {
+	client.usersMutex.Lock()
+	name, exists := client.users[userID]
+	client.usersMutex.Unlock()
+
+	if exists {
+		return name
+	}
+
+	response, err := slackAPIRequest[slackUserInfoResponseJson](client, "users.info", url.Values{"user": {userID}})
+	if err != nil {
+		slog.Error("Failed to fetch slack user", "user", userID, "error", err)
+		name = userID
+	} else {
+		name = response.User.Profile.DisplayName
+		if name == "" {
+			name = ternary(response.User.Profile.RealName != "", response.User.Profile.RealName, response.User.Name)
+		}
+	}
+
+	client.usersMutex.Lock()
+	client.users[userID] = name
+	client.usersMutex.Unlock()
+
+	return name
+}
+
+func (client *slackClient) lastRead(channelID string) (float64, error) {
+	response, err := slackAPIRequest[slackConversationInfoResponseJson](client, "conversations.info", url.Values{"channel": {channelID}})
+	if err != nil {
+		return 0, err
+	}
+
+	lastRead, _ := strconv.ParseFloat(response.Channel.LastRead, 64)
+
+	return lastRead, nil
+}
+
+func (client *slackClient) permalink(channelID string, ts string) string {
+	if client.workspaceURL == "" {
+		return ""
+	}
+
+	return client.workspaceURL + "archives/" + channelID + "/p" + strings.ReplaceAll(ts, ".", "")
+}
+
+var slackUserMentionPattern = regexp.MustCompile(`<@([A-Z0-9]+)(?:\|[^>]*)?>`)
+var slackLinkPattern = regexp.MustCompile(`<([^@#!][^>|]*)(?:\|([^>]*))?>`)
+
+func (client *slackClient) formatText(text string) string {
+	text = slackUserMentionPattern.ReplaceAllStringFunc(text, func(match string) string {
+		return "@" + client.userName(slackUserMentionPattern.FindStringSubmatch(match)[1])
+	})
+
+	return formatSlackLinks(text)
+}
+
+// also used for the payloads of Slack-compatible webhooks
+func formatSlackLinks(text string) string {
+	text = slackLinkPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := slackLinkPattern.FindStringSubmatch(match)
+		return ternary(parts[2] != "", parts[2], parts[1])
+	})
+
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&").Replace(text)
+}
+
+func parseSlackTimestamp(ts string) time.Time {
+	seconds, err := strconv.ParseFloat(ts, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(int64(seconds), 0)
+}
+
+func fetchSlackUnreadMessages(client *slackClient) ([]slackMessage, error) {
+	client.users = make(map[string]string)
+
+	auth, err := slackAPIRequest[slackAuthTestResponseJson](client, "auth.test", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	client.userID = auth.UserID
+	client.workspaceURL = auth.URL
+
+	dms, dmsErr := fetchSlackUnreadDMs(client)
+	if dmsErr != nil {
+		slog.Error("Failed to fetch slack DMs", "error", dmsErr)
+	}
+
+	var mentions []slackMessage
+	var mentionsErr error
+
+	// bot tokens can't use the search API, in which case only DMs are shown
+	if strings.HasPrefix(client.token, "xoxp-") {
+		mentions, mentionsErr = fetchSlackUnreadMentions(client)
+		if mentionsErr != nil {
+			slog.Error("Failed to fetch slack mentions", "error", mentionsErr)
+		}
+	}
+
+	if dmsErr != nil && (mentionsErr != nil || mentions == nil) {
+		return nil, fmt.Errorf("%w: could not fetch messages", errNoContent)
+	}
+
+	messages := append(dms, mentions...)
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].SentAt.After(messages[j].SentAt)
+	})
+
+	if dmsErr != nil || mentionsErr != nil {
+		return messages, fmt.Errorf("%w: could not fetch all messages", errPartialContent)
+	}
+
+	return messages, nil
+}
+
+type slackConversation struct {
+	ID   string
+	Name string
+}
+
+func fetchSlackUnreadDMs(client *slackClient) ([]slackMessage, error) {
+	conversations := make([]slackConversation, 0)
+	params := url.Values{"types": {"im,mpim"}, "exclude_archived": {"true"}, "limit": {"200"}}
+
+	for {
+		response, err := slackAPIRequest[slackConversationsListResponseJson](client, "conversations.list", params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, channel := range response.Channels {
+			conversations = append(conversations, slackConversation{
+				ID:   channel.ID,
+				Name: ternary(channel.IsMpim, channel.Name, ""),
+			})
+		}
+
+		if response.ResponseMetadata.NextCursor == "" {
+			break
+		}
+
+		params.Set("cursor", response.ResponseMetadata.NextCursor)
+	}
+
+	task := func(conversation slackConversation) ([]slackMessage, error) {
+		lastRead, err := client.lastRead(conversation.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		history, err := slackAPIRequest[slackConversationHistoryResponseJson](client, "conversations.history", url.Values{
+			"channel": {conversation.ID},
+			"oldest":  {strconv.FormatFloat(lastRead, 'f', 6, 64)},
+			"limit":   {"20"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		messages := make([]slackMessage, 0, len(history.Messages))
+
+		for _, message := range history.Messages {
+			if message.User == client.userID || message.Subtype != "" {
+				continue
+			}
+
+			messages = append(messages, slackMessage{
+				IsDM:    true,
+				Channel: conversation.Name,
+				Sender:  client.userName(message.User),
+				Text:    client.formatText(message.Text),
+				URL:     client.permalink(conversation.ID, message.TS),
+				SentAt:  parseSlackTimestamp(message.TS),
+			})
+		}
+
+		return messages, nil
+	}
+
+	// Slack rate limits these methods fairly aggressively so keep the concurrency low
+	job := newJob(task, conversations).withWorkers(3)
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]slackMessage, 0)
+	var failed int
+
+	for i := range results {
+		if errs[i] != nil {
+			failed++
+			slog.Error("Failed to fetch slack conversation", "channel", conversations[i].ID, "error", errs[i])
+			continue
+		}
+
+		messages = append(messages, results[i]...)
+	}
+
+	if failed > 0 && failed == len(conversations) {
+		return nil, errors.New("could not fetch any conversations")
+	}
+
+	return messages, nil
+}
+
+func fetchSlackUnreadMentions(client *slackClient) ([]slackMessage, error) {
+	response, err := slackAPIRequest[slackSearchResponseJson](client, "search.messages", url.Values{
+		"query":    {"<@" + client.userID + ">"},
+		"sort":     {"timestamp"},
+		"sort_dir": {"desc"},
+		"count":    {"50"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lastReadByChannel := make(map[string]float64)
+	messages := make([]slackMessage, 0)
+
+	for _, match := range response.Messages.Matches {
+		// unread DMs are already included regardless of whether they mention the user
+		if match.Channel.IsIM || match.Channel.IsMpim {
+			continue
+		}
+
+		lastRead, exists := lastReadByChannel[match.Channel.ID]
+		if !exists {
+			lastRead, err = client.lastRead(match.Channel.ID)
+			if err != nil {
+				slog.Error("Failed to fetch slack channel", "channel", match.Channel.ID, "error", err)
+			}
+
+			lastReadByChannel[match.Channel.ID] = lastRead
+		}
+
+		ts, _ := strconv.ParseFloat(match.TS, 64)
+		if ts <= lastRead || match.User == client.userID {
+			continue
+		}
+
+		sender := match.Username
+		if match.User != "" {
+			sender = client.userName(match.User)
+		}
+
+		messages = append(messages, slackMessage{
+			Channel: "#" + match.Channel.Name,
+			Sender:  sender,
+			Text:    client.formatText(match.Text),
+			URL:     match.Permalink,
+			SentAt:  parseSlackTimestamp(match.TS),
+		})
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,306 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+var sportsWidgetTemplate = mustParseTemplate("sports.html", "widget-base.html")
+
+const espnAPIURL = "https://site.api.espn.com/apis/site/v2/sports"
+
+type sportsWidget struct {
+	widgetBase    `yaml:",inline"`
+	Leagues       []sportsLeague `yaml:"leagues"`
+	ResultsDays   int            `yaml:"results-days"`
+	FixturesDays  int            `yaml:"fixtures-days"`
+	CollapseAfter int            `yaml:"collapse-after"`
+}
+
+type sportsLeague struct {
+	League  string        `yaml:"league"`
+	Name    string        `yaml:"name"`
+	Teams   []string      `yaml:"teams"`
+	Matches []sportsMatch `yaml:"-"`
+}
+
+type sportsMatch struct {
+	Home     sportsTeam
+	Away     sportsTeam
+	URL      string
+	StartsAt time.Time
+	State    string
+	Status   string
+}
+
+type sportsTeam struct {
+	Name     string
+	LogoURL  string
+	Score    string
+	Winner   bool
+	id       string
+	short    string
+	fullName string
+}
+
+func (match *sportsMatch) IsLive() bool {
+	return match.State == "in"
+}
+
+func (match *sportsMatch) IsUpcoming() bool {
+	return match.State == "pre"
+}
+
+func (widget *sportsWidget) initialize() error {
+	widget.withTitle("Sports").withCacheDuration(5 * time.Minute)
+
+	if len(widget.Leagues) == 0 {
+		return errors.New("no leagues specified")
+	}
+
+	for i := range widget.Leagues {
+		league := &widget.Leagues[i]
+
+		// leagues are specified as sport/league, e.g. soccer/eng.1 or basketball/nba
+		if strings.Count(league.League, "/") != 1 {
+			return fmt.Errorf("league %d: league must be in the format sport/league, e.g. soccer/eng.1", i+1)
+		}
+
+		for j := range league.Teams {
+			league.Teams[j] = strings.ToLower(league.Teams[j])
+		}
+	}
+
+	if widget.ResultsDays <= 0 {
+		widget.ResultsDays = 3
+	}
+
+	if widget.FixturesDays <= 0 {
+		widget.FixturesDays = 7
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *sportsWidget) update(ctx context.Context) {
+	requests := make([]*sportsLeague, len(widget.Leagues))
+	for i := range widget.Leagues {
+		requests[i] = &widget.Leagues[i]
+	}
+
+	now := time.Now()
+	from := now.AddDate(0, 0, -widget.ResultsDays)
+	to := now.AddDate(0, 0, widget.FixturesDays)
+
+	job := newJob(func(league *sportsLeague) ([]sportsMatch, error) {
+		return league.fetchMatches(widget.cachedHTTPClient(false), from, to)
+	}, requests).withWorkers(len(requests))
+
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		widget.canContinueUpdateAfterHandlingErr(fmt.Errorf("%w: %v", errNoContent, err))
+		return
+	}
+
+	var failed int
+
+	for i := range errs {
+		if errs[i] != nil {
+			failed++
+			widget.logger().Error("Failed to fetch sports matches", "league", widget.Leagues[i].League, "error", errs[i])
+		}
+	}
+
+	if failed == len(widget.Leagues) {
+		err = fmt.Errorf("%w: %v", errNoContent, errs[0])
+	} else if failed > 0 {
+		err = fmt.Errorf("%w: could not fetch %d leagues", errPartialContent, failed)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	for i := range widget.Leagues {
+		if errs[i] == nil {
+			widget.Leagues[i].Matches = results[i]
+		}
+	}
+}
+
+func (widget *sportsWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, sportsWidgetTemplate)
+}
+
+type espnTeamJson struct {
+	ID               string `json:"id"`
+	Abbreviation     string `json:"abbreviation"`
+	DisplayName      string `json:"displayName"`
+	ShortDisplayName string `json:"shortDisplayName"`
+	Logo             string `json:"logo"`
+}
+
+type espnScoreboardResponseJson struct {
+	Leagues []struct {
+		Name string `json:"name"`
+	} `json:"leagues"`
+	Events []struct {
+		Date  string `json:"date"`
+		Links []struct {
+			Href string `json:"href"`
+		} `json:"links"`
+		Competitions []struct {
+			Competitors []struct {
+				HomeAway string       `json:"homeAway"`
+				Score    string       `json:"score"`
+				Winner   bool         `json:"winner"`
+				Team     espnTeamJson `json:"team"`
+			} `json:"competitors"`
+			Status struct {
+				Type struct {
+					State       string `json:"state"`
+					ShortDetail string `json:"shortDetail"`
+				} `json:"type"`
+			} `json:"status"`
+		} `json:"competitions"`
+	} `json:"events"`
+}
+
+func (league *sportsLeague) fetchMatches(client requestDoer, from, to time.Time) ([]sportsMatch, error) {
+	requestURL := fmt.Sprintf(
+		"%s/%s/scoreboard?limit=500&dates=%s-%s",
+		espnAPIURL, league.League, from.Format("20060102"), to.Format("20060102"),
+	)
+
+	request, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeJsonFromRequest[espnScoreboardResponseJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if league.Name == "" && len(response.Leagues) > 0 {
+		league.Name = response.Leagues[0].Name
+	}
+
+	matches := make([]sportsMatch, 0, len(response.Events))
+
+	for i := range response.Events {
+		event := &response.Events[i]
+		if len(event.Competitions) == 0 {
+			continue
+		}
+
+		competition := &event.Competitions[0]
+
+		match := sportsMatch{
+			StartsAt: parseESPNTime(event.Date),
+			State:    competition.Status.Type.State,
+			Status:   competition.Status.Type.ShortDetail,
+		}
+
+		if len(event.Links) > 0 {
+			match.URL = event.Links[0].Href
+		}
+
+		for j := range competition.Competitors {
+			competitor := &competition.Competitors[j]
+
+			team := sportsTeam{
+				Name:     ternary(competitor.Team.ShortDisplayName != "", competitor.Team.ShortDisplayName, competitor.Team.DisplayName),
+				LogoURL:  competitor.Team.Logo,
+				Score:    competitor.Score,
+				Winner:   competitor.Winner,
+				id:       competitor.Team.ID,
+				short:    competitor.Team.Abbreviation,
+				fullName: competitor.Team.DisplayName,
+			}
+
+			// scores are reported as 0 before the match starts
+			if match.State == "pre" {
+				team.Score = ""
+			}
+
+			if competitor.HomeAway == "home" {
+				match.Home = team
+			} else {
+				match.Away = team
+			}
+		}
+
+		if len(league.Teams) > 0 && !league.includesTeam(&match.Home) && !league.includesTeam(&match.Away) {
+			continue
+		}
+
+		matches = append(matches, match)
+	}
+
+	sortSportsMatches(matches)
+
+	return matches, nil
+}
+
+// Teams can be referred to by their ID, abbreviation or name
+func (league *sportsLeague) includesTeam(team *sportsTeam) bool {
+	for _, wanted := range league.Teams {
+		if wanted == team.id || wanted == strings.ToLower(team.short) ||
+			wanted == strings.ToLower(team.Name) || wanted == strings.ToLower(team.fullName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Live matches come first, followed by upcoming ones in the order they start
+// and finally the results starting from the most recent
+func sortSportsMatches(matches []sportsMatch) {
+	rank := func(match *sportsMatch) int {
+		switch match.State {
+		case "in":
+			return 0
+		case "pre":
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := &matches[i], &matches[j]
+
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+
+		if a.State == "pre" {
+			return a.StartsAt.Before(b.StartsAt)
+		}
+
+		return a.StartsAt.After(b.StartsAt)
+	})
+}
+
+// Dates are usually missing the seconds, e.g. 2025-03-08T15:00Z
+func parseESPNTime(value string) time.Time {
+	for _, layout := range []string{"2006-01-02T15:04Z07:00", time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,398 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var surfForecastWidgetTemplate = mustParseTemplate("surf-forecast.html", "widget-base.html")
+
+const (
+	surfForecastColumns   = 8
+	surfForecastColumnGap = 3 // hours
+)
+
+var compassDirections = []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+
+var surfWindUnits = map[string]string{
+	"kn":  "kn",
+	"kmh": "km/h",
+	"mph": "mph",
+	"ms":  "m/s",
+}
+
+type surfForecastWidget struct {
+	widgetBase `yaml:",inline"`
+	Spots      []surfSpot     `yaml:"spots"`
+	GoWhen     *surfThreshold `yaml:"go-when"`
+	Units      string         `yaml:"units"`
+	WindUnit   string         `yaml:"wind-unit"`
+	HourFormat string         `yaml:"hour-format"`
+}
+
+type surfSpot struct {
+	Name      string         `yaml:"name"`
+	Latitude  float64        `yaml:"latitude"`
+	Longitude float64        `yaml:"longitude"`
+	GoWhen    *surfThreshold `yaml:"go-when"`
+	Columns   []surfColumn   `yaml:"-"`
+	Error     bool           `yaml:"-"`
+}
+
+// Conditions that all have to be met for a spot to be worth going to, those
+// that aren't set are ignored
+type surfThreshold struct {
+	MinWaveHeight  float64  `yaml:"min-wave-height"`
+	MaxWaveHeight  float64  `yaml:"max-wave-height"`
+	MinWavePeriod  float64  `yaml:"min-wave-period"`
+	MinWindSpeed   float64  `yaml:"min-wind-speed"`
+	MaxWindSpeed   float64  `yaml:"max-wind-speed"`
+	WindDirections []string `yaml:"wind-directions"`
+	windDegrees    []float64
+}
+
+type surfColumn struct {
+	Time          time.Time
+	Label         string
+	WaveHeight    float64
+	WavePeriod    float64
+	WaveDirection float64
+	HasWaves      bool
+	WindSpeed     float64
+	WindGusts     float64
+	WindDirection float64
+	HasWind       bool
+	Go            bool
+}
+
+func (widget *surfForecastWidget) initialize() error {
+	widget.withTitle("Surf Forecast").withCacheDuration(time.Hour)
+
+	if len(widget.Spots) == 0 {
+		return errors.New("no spots specified")
+	}
+
+	if widget.GoWhen != nil {
+		if err := widget.GoWhen.initialize(); err != nil {
+			return fmt.Errorf("go-when: %v", err)
+		}
+	}
+
+	for i := range widget.Spots {
+		spot := &widget.Spots[i]
+
+		if spot.Latitude == 0 && spot.Longitude == 0 {
+			return fmt.Errorf("spot %d: latitude and longitude are required", i+1)
+		}
+
+		if spot.Latitude < -90 || spot.Latitude > 90 || spot.Longitude < -180 || spot.Longitude > 180 {
+			return fmt.Errorf("spot %d: latitude or longitude is out of range", i+1)
+		}
+
+		if spot.Name == "" {
+			spot.Name = fmt.Sprintf("%.3f, %.3f", spot.Latitude, spot.Longitude)
+		}
+
+		if spot.GoWhen == nil {
+			spot.GoWhen = widget.GoWhen
+		} else if err := spot.GoWhen.initialize(); err != nil {
+			return fmt.Errorf("spot %d: go-when: %v", i+1, err)
+		}
+	}
+
+	if widget.Units != "" && widget.Units != "metric" && widget.Units != "imperial" {
+		return errors.New("units must be either metric or imperial")
+	}
+
+	if widget.WindUnit == "" {
+		widget.WindUnit = "kn"
+	} else if _, ok := surfWindUnits[widget.WindUnit]; !ok {
+		return errors.New("wind-unit must be one of kn, kmh, mph or ms")
+	}
+
+	if widget.HourFormat == "" {
+		widget.HourFormat = "24h"
+	} else if widget.HourFormat != "12h" && widget.HourFormat != "24h" {
+		return errors.New("hour-format must be either 12h or 24h")
+	}
+
+	return nil
+}
+
+func (t *surfThreshold) initialize() error {
+	for _, direction := range t.WindDirections {
+		index := slices.Index(compassDirections, strings.ToUpper(direction))
+		if index == -1 {
+			return fmt.Errorf("unknown wind direction %s, must be one of %s", direction, strings.Join(compassDirections, ", "))
+		}
+
+		t.windDegrees = append(t.windDegrees, float64(index)*22.5)
+	}
+
+	if t.MaxWaveHeight > 0 && t.MaxWaveHeight < t.MinWaveHeight {
+		return errors.New("max-wave-height can't be lower than min-wave-height")
+	}
+
+	if t.MaxWindSpeed > 0 && t.MaxWindSpeed < t.MinWindSpeed {
+		return errors.New("max-wind-speed can't be lower than min-wind-speed")
+	}
+
+	return nil
+}
+
+// Missing values don't meet any of the conditions that depend on them
+func (t *surfThreshold) isMet(c *surfColumn) bool {
+	if t.MinWaveHeight > 0 || t.MaxWaveHeight > 0 || t.MinWavePeriod > 0 {
+		if !c.HasWaves || c.WaveHeight < t.MinWaveHeight || c.WavePeriod < t.MinWavePeriod {
+			return false
+		}
+
+		if t.MaxWaveHeight > 0 && c.WaveHeight > t.MaxWaveHeight {
+			return false
+		}
+	}
+
+	if t.MinWindSpeed > 0 || t.MaxWindSpeed > 0 || len(t.windDegrees) > 0 {
+		if !c.HasWind || c.WindSpeed < t.MinWindSpeed {
+			return false
+		}
+
+		if t.MaxWindSpeed > 0 && c.WindSpeed > t.MaxWindSpeed {
+			return false
+		}
+
+		// each direction covers the half of the way to its neighbours on an
+		// 8 point compass, so W also includes WSW and WNW
+		if len(t.windDegrees) > 0 && !slices.ContainsFunc(t.windDegrees, func(degrees float64) bool {
+			return angleBetween(degrees, c.WindDirection) <= 22.5
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func angleBetween(a, b float64) float64 {
+	diff := math.Mod(math.Abs(a-b), 360)
+	return math.Min(diff, 360-diff)
+}
+
+func (widget *surfForecastWidget) update(ctx context.Context) {
+	job := newJob(func(spot *surfSpot) ([]surfColumn, error) {
+		return widget.fetchForecast(ctx, spot)
+	}, widget.spots()).withWorkers(len(widget.Spots))
+
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		widget.canContinueUpdateAfterHandlingErr(fmt.Errorf("%w: %v", errNoContent, err))
+		return
+	}
+
+	var failed int
+
+	for i := range results {
+		if errs[i] != nil {
+			failed++
+			widget.logger().Error("Failed to fetch surf forecast", "spot", widget.Spots[i].Name, "error", errs[i])
+		}
+	}
+
+	if failed == len(widget.Spots) {
+		err = fmt.Errorf("%w: %v", errNoContent, errs[0])
+	} else if failed > 0 {
+		err = fmt.Errorf("%w: could not fetch the forecast of %d spots", errPartialContent, failed)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	for i := range results {
+		widget.Spots[i].Error = errs[i] != nil
+		widget.Spots[i].Columns = results[i]
+	}
+}
+
+func (widget *surfForecastWidget) spots() []*surfSpot {
+	spots := make([]*surfSpot, len(widget.Spots))
+	for i := range widget.Spots {
+		spots[i] = &widget.Spots[i]
+	}
+
+	return spots
+}
+
+type openMeteoMarineResponseJson struct {
+	Hourly struct {
+		Time          []int64    `json:"time"`
+		WaveHeight    []*float64 `json:"wave_height"`
+		WavePeriod    []*float64 `json:"wave_period"`
+		WaveDirection []*float64 `json:"wave_direction"`
+	} `json:"hourly"`
+}
+
+type openMeteoWindResponseJson struct {
+	UTCOffsetSeconds int `json:"utc_offset_seconds"`
+	Hourly           struct {
+		Time          []int64    `json:"time"`
+		WindSpeed     []*float64 `json:"wind_speed_10m"`
+		WindGusts     []*float64 `json:"wind_gusts_10m"`
+		WindDirection []*float64 `json:"wind_direction_10m"`
+	} `json:"hourly"`
+}
+
+// Waves come from the marine API and wind from the regular forecast, the
+// marine API has no waves for spots that are too far inland
+func (widget *surfForecastWidget) fetchForecast(ctx context.Context, spot *surfSpot) ([]surfColumn, error) {
+	client := widget.cachedHTTPClient(false)
+
+	query := url.Values{}
+	query.Set("latitude", strconv.FormatFloat(spot.Latitude, 'f', -1, 64))
+	query.Set("longitude", strconv.FormatFloat(spot.Longitude, 'f', -1, 64))
+	query.Set("timeformat", "unixtime")
+	query.Set("timezone", "auto")
+	query.Set("forecast_days", "2")
+
+	marineQuery := maps.Clone(query)
+	marineQuery.Set("hourly", "wave_height,wave_period,wave_direction")
+	marineQuery.Set("length_unit", widget.units(widget.Units))
+
+	request, _ := http.NewRequestWithContext(ctx, "GET", "https://marine-api.open-meteo.com/v1/marine?"+marineQuery.Encode(), nil)
+	marine, err := decodeJsonFromRequest[openMeteoMarineResponseJson](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching waves: %v", err)
+	}
+
+	windQuery := maps.Clone(query)
+	windQuery.Set("hourly", "wind_speed_10m,wind_gusts_10m,wind_direction_10m")
+	windQuery.Set("wind_speed_unit", widget.WindUnit)
+
+	request, _ = http.NewRequestWithContext(ctx, "GET", "https://api.open-meteo.com/v1/forecast?"+windQuery.Encode(), nil)
+	wind, err := decodeJsonFromRequest[openMeteoWindResponseJson](client, request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching wind: %v", err)
+	}
+
+	// labels are in the local time of the spot
+	location := time.FixedZone("", wind.UTCOffsetSeconds)
+	now := time.Now().Truncate(time.Hour)
+	columns := make([]surfColumn, 0, surfForecastColumns)
+
+	for i, t := range wind.Hourly.Time {
+		at := time.Unix(t, 0)
+		if at.Before(now) || at.Sub(now)%(surfForecastColumnGap*time.Hour) != 0 {
+			continue
+		}
+
+		column := surfColumn{Time: at, Label: widget.formatHour(at.In(location))}
+
+		if speed, direction := valueAt(wind.Hourly.WindSpeed, i), valueAt(wind.Hourly.WindDirection, i); speed != nil && direction != nil {
+			column.WindSpeed, column.WindDirection, column.HasWind = *speed, *direction, true
+			if gusts := valueAt(wind.Hourly.WindGusts, i); gusts != nil {
+				column.WindGusts = *gusts
+			}
+		}
+
+		if j := slices.Index(marine.Hourly.Time, t); j != -1 {
+			height, period := valueAt(marine.Hourly.WaveHeight, j), valueAt(marine.Hourly.WavePeriod, j)
+			if height != nil && period != nil {
+				column.WaveHeight, column.WavePeriod, column.HasWaves = *height, *period, true
+				if direction := valueAt(marine.Hourly.WaveDirection, j); direction != nil {
+					column.WaveDirection = *direction
+				}
+			}
+		}
+
+		if spot.GoWhen != nil {
+			column.Go = spot.GoWhen.isMet(&column)
+		}
+
+		columns = append(columns, column)
+		if len(columns) == surfForecastColumns {
+			break
+		}
+	}
+
+	if len(columns) == 0 {
+		return nil, errors.New("no forecast for the upcoming hours")
+	}
+
+	return columns, nil
+}
+
+func valueAt(values []*float64, index int) *float64 {
+	if index < len(values) {
+		return values[index]
+	}
+
+	return nil
+}
+
+func (widget *surfForecastWidget) formatHour(t time.Time) string {
+	if widget.HourFormat == "12h" {
+		return strings.ToLower(t.Format("3pm"))
+	}
+
+	return t.Format("15:04")
+}
+
+func (widget *surfForecastWidget) HeightUnit() string {
+	return ternary(widget.units(widget.Units) == "imperial", "ft", "m")
+}
+
+func (widget *surfForecastWidget) SpeedUnit() string {
+	return surfWindUnits[widget.WindUnit]
+}
+
+func (c surfColumn) FormattedWaveHeight() string {
+	return strconv.FormatFloat(c.WaveHeight, 'f', 1, 64)
+}
+
+func (c surfColumn) RoundedWavePeriod() int {
+	return int(math.Round(c.WavePeriod))
+}
+
+func (c surfColumn) RoundedWindSpeed() int {
+	return int(math.Round(c.WindSpeed))
+}
+
+func (c surfColumn) RoundedWindGusts() int {
+	return int(math.Round(c.WindGusts))
+}
+
+func (c surfColumn) WindCompassDirection() string {
+	return compassDirection(c.WindDirection)
+}
+
+func (c surfColumn) WaveCompassDirection() string {
+	return compassDirection(c.WaveDirection)
+}
+
+// Directions are where the wind comes from, the arrow points to where it's going
+func (c surfColumn) WindArrowRotation() int {
+	return (int(math.Round(c.WindDirection)) + 180) % 360
+}
+
+func compassDirection(degrees float64) string {
+	index := int(math.Round(math.Mod(degrees+360, 360)/22.5)) % len(compassDirections)
+	return compassDirections[index]
+}
+
+func (spot surfSpot) HasThreshold() bool {
+	return spot.GoWhen != nil
+}
+
+func (widget *surfForecastWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, surfForecastWidgetTemplate)
+}
@@ -0,0 +1,348 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var timetableWidgetTemplate = mustParseTemplate("timetable.html", "widget-base.html")
+
+// lessons are looked up this far ahead so that the next one can be found
+// over weekends and short holidays
+const timetableLookahead = 14
+
+type timetableWidget struct {
+	widgetBase `yaml:",inline"`
+	People     []timetablePerson     `yaml:"people"`
+	View       string                `yaml:"view"`
+	HourFormat string                `yaml:"hour-format"`
+	Items      []timetablePersonItem `yaml:"-"`
+	importedMu sync.Mutex
+}
+
+type timetablePerson struct {
+	Name     string                       `yaml:"name"`
+	Color    *hslColorField               `yaml:"color"`
+	Week     map[string][]timetableLesson `yaml:"week"`
+	URL      string                       `yaml:"url"`
+	lessons  []timetableLesson
+	imported []timetableOccurrence
+}
+
+type timetableLesson struct {
+	Subject string `yaml:"subject"`
+	Start   string `yaml:"start"`
+	End     string `yaml:"end"`
+	Room    string `yaml:"room"`
+	Teacher string `yaml:"teacher"`
+	day     time.Weekday
+	start   time.Duration
+	end     time.Duration
+}
+
+type timetableOccurrence struct {
+	Subject   string
+	Room      string
+	Teacher   string
+	Start     time.Time
+	End       time.Time
+	IsCurrent bool
+	IsNext    bool
+}
+
+type timetablePersonItem struct {
+	Name    string
+	Color   *hslColorField
+	Current *timetableOccurrence
+	Next    *timetableOccurrence
+	Days    []timetableDay
+}
+
+type timetableDay struct {
+	Date    time.Time
+	IsToday bool
+	Lessons []timetableOccurrence
+}
+
+func (widget *timetableWidget) initialize() error {
+	widget.withTitle("Timetable").withCacheDuration(6 * time.Hour)
+
+	if len(widget.People) == 0 {
+		return errors.New("no people specified")
+	}
+
+	if widget.View == "" {
+		widget.View = "day"
+	} else if widget.View != "day" && widget.View != "week" {
+		return errors.New("view must be either day or week")
+	}
+
+	if widget.HourFormat == "" {
+		widget.HourFormat = "24h"
+	} else if widget.HourFormat != "12h" && widget.HourFormat != "24h" {
+		return errors.New("hour-format must be either 12h or 24h")
+	}
+
+	for i := range widget.People {
+		person := &widget.People[i]
+
+		if person.Name == "" {
+			return fmt.Errorf("person %d has no name", i+1)
+		}
+
+		if len(person.Week) == 0 && person.URL == "" {
+			return fmt.Errorf("person %s: either week or url is required", person.Name)
+		}
+
+		for dayName, lessons := range person.Week {
+			day, ok := calendarWeekdaysToInt[strings.ToLower(dayName)]
+			if !ok {
+				return fmt.Errorf("person %s: invalid day %s", person.Name, dayName)
+			}
+
+			for j := range lessons {
+				lesson := lessons[j]
+				if lesson.Subject == "" {
+					return fmt.Errorf("person %s: lesson %d on %s has no subject", person.Name, j+1, dayName)
+				}
+
+				var err error
+				if lesson.start, err = parseTimetableTime(lesson.Start); err != nil {
+					return fmt.Errorf("person %s: %s on %s: invalid start: %v", person.Name, lesson.Subject, dayName, err)
+				}
+
+				if lesson.end, err = parseTimetableTime(lesson.End); err != nil {
+					return fmt.Errorf("person %s: %s on %s: invalid end: %v", person.Name, lesson.Subject, dayName, err)
+				}
+
+				if lesson.end <= lesson.start {
+					return fmt.Errorf("person %s: %s on %s ends before it starts", person.Name, lesson.Subject, dayName)
+				}
+
+				lesson.day = day
+				person.lessons = append(person.lessons, lesson)
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseTimetableTime(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be in the format of HH:MM", value)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Only timetables that are imported need updating, the rest is worked out when
+// rendering so that the current lesson is always up to date
+func (widget *timetableWidget) update(ctx context.Context) {
+	from := timetableWeekStart(time.Now())
+	until := from.AddDate(0, 0, 7+timetableLookahead)
+
+	var failed, total int
+	var lastErr error
+
+	for i := range widget.People {
+		person := &widget.People[i]
+		if person.URL == "" {
+			continue
+		}
+
+		total++
+		occurrences, err := fetchTimetableFromICalendar(ctx, widget.httpClient(false), person.URL, from, until, widget.logger())
+		if err != nil {
+			failed++
+			lastErr = err
+			widget.logger().Error("Failed to fetch timetable", "person", person.Name, "error", err)
+			continue
+		}
+
+		widget.importedMu.Lock()
+		person.imported = occurrences
+		widget.importedMu.Unlock()
+	}
+
+	var err error
+	if failed > 0 && failed == total && failed == len(widget.People) {
+		err = fmt.Errorf("%w: %v", errNoContent, lastErr)
+	} else if failed > 0 {
+		err = fmt.Errorf("%w: could not fetch %d timetables", errPartialContent, failed)
+	}
+
+	widget.canContinueUpdateAfterHandlingErr(err)
+}
+
+// The Monday of the week that t falls within
+func timetableWeekStart(t time.Time) time.Time {
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	return date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+}
+
+// Lessons from the config repeat every week, whereas imported ones happen on
+// the dates they're scheduled for
+func (widget *timetableWidget) occurrences(person *timetablePerson, from, until time.Time) []timetableOccurrence {
+	occurrences := make([]timetableOccurrence, 0)
+
+	for date := from; date.Before(until); date = date.AddDate(0, 0, 1) {
+		for i := range person.lessons {
+			lesson := &person.lessons[i]
+			if lesson.day != date.Weekday() {
+				continue
+			}
+
+			occurrences = append(occurrences, timetableOccurrence{
+				Subject: lesson.Subject,
+				Room:    lesson.Room,
+				Teacher: lesson.Teacher,
+				Start:   date.Add(lesson.start),
+				End:     date.Add(lesson.end),
+			})
+		}
+	}
+
+	widget.importedMu.Lock()
+	for _, occurrence := range person.imported {
+		if !occurrence.Start.Before(from) && occurrence.Start.Before(until) {
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+	widget.importedMu.Unlock()
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].Start.Before(occurrences[j].Start)
+	})
+
+	return occurrences
+}
+
+func (widget *timetableWidget) buildItems(now time.Time) []timetablePersonItem {
+	weekStart := timetableWeekStart(now)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	items := make([]timetablePersonItem, 0, len(widget.People))
+
+	for i := range widget.People {
+		person := &widget.People[i]
+		occurrences := widget.occurrences(person, weekStart, today.AddDate(0, 0, timetableLookahead))
+		item := timetablePersonItem{Name: person.Name, Color: person.Color}
+
+		current, next := -1, -1
+		for j := range occurrences {
+			o := &occurrences[j]
+			if current == -1 && !now.Before(o.Start) && now.Before(o.End) {
+				current = j
+				o.IsCurrent = true
+			} else if next == -1 && o.Start.After(now) {
+				next = j
+				o.IsNext = true
+			}
+		}
+
+		// the days shown are the ones of this week or, in the day view, today
+		// unless it's over, in which case it's the day of the next lesson
+		var from, until time.Time
+		if widget.View == "week" {
+			from, until = weekStart, weekStart.AddDate(0, 0, 7)
+		} else {
+			from = today
+			if current == -1 && next != -1 && !slices.ContainsFunc(occurrences, func(o timetableOccurrence) bool {
+				return o.Start.After(now) && o.Start.Before(today.AddDate(0, 0, 1))
+			}) {
+				start := occurrences[next].Start
+				from = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+			}
+			until = from.AddDate(0, 0, 1)
+		}
+
+		for j := range occurrences {
+			o := occurrences[j]
+			if o.Start.Before(from) || !o.Start.Before(until) {
+				continue
+			}
+
+			date := time.Date(o.Start.Year(), o.Start.Month(), o.Start.Day(), 0, 0, 0, 0, time.Local)
+			if len(item.Days) == 0 || !item.Days[len(item.Days)-1].Date.Equal(date) {
+				item.Days = append(item.Days, timetableDay{Date: date, IsToday: date.Equal(today)})
+			}
+
+			day := &item.Days[len(item.Days)-1]
+			day.Lessons = append(day.Lessons, o)
+		}
+
+		if current != -1 {
+			item.Current = &occurrences[current]
+		}
+
+		if next != -1 {
+			item.Next = &occurrences[next]
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func (widget *timetableWidget) FormatTime(t time.Time) string {
+	if widget.HourFormat == "12h" {
+		return t.Format("3:04pm")
+	}
+
+	return t.Format("15:04")
+}
+
+func (widget *timetableWidget) Render() template.HTML {
+	widget.Items = widget.buildItems(time.Now())
+
+	return widget.renderTemplate(widget, timetableWidgetTemplate)
+}
+
+// Timetables exported from school management systems are usually a calendar
+// with an event for every lesson, or one that repeats weekly
+func fetchTimetableFromICalendar(ctx context.Context, client requestDoer, source string, from, until time.Time, logger *slog.Logger) ([]timetableOccurrence, error) {
+	events, err := fetchICalendarEvents(ctx, client, source)
+	if err != nil {
+		return nil, err
+	}
+
+	var occurrences []timetableOccurrence
+
+	for _, occurrence := range icalOccurrences(events, from, until, logger) {
+		event := occurrence.event
+		start, _ := event.dateTime("DTSTART")
+		end, _ := event.dateTime("DTEND")
+		subject := event.text("SUMMARY")
+
+		// all day events are usually holidays rather than lessons
+		if subject == "" || event.isAllDay() || !end.After(start) {
+			continue
+		}
+
+		lesson := timetableOccurrence{
+			Subject: subject,
+			Room:    event.text("LOCATION"),
+			Start:   occurrence.start,
+			End:     occurrence.start.Add(end.Sub(start)),
+		}
+
+		// Untis and a few others put the teacher here, anything longer is left out
+		if description := event.text("DESCRIPTION"); len(description) <= 40 {
+			lesson.Teacher = description
+		}
+
+		occurrences = append(occurrences, lesson)
+	}
+
+	return occurrences, nil
+}
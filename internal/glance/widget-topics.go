@@ -0,0 +1,643 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+var topicsWidgetTemplate = mustParseTemplate("topics.html", "widget-base.html")
+
+const (
+	defaultTopicsPeriod     = 24 * time.Hour
+	defaultTopicsSimilarity = 0.3
+)
+
+// A headline from one of the feed widgets, such as RSS or Hacker News
+type feedItem struct {
+	Title       string
+	URL         string
+	Sources     []string
+	PublishedAt time.Time
+}
+
+// Widgets whose items can be grouped into topics by the topics widget
+type feedItemSource interface {
+	feedItems() []feedItem
+}
+
+func (widget *rssWidget) feedItems() []feedItem {
+	items := make([]feedItem, 0, len(widget.Items))
+
+	for i := range widget.Items {
+		item := &widget.Items[i]
+		items = append(items, feedItem{
+			Title:       item.Title,
+			URL:         item.SaveURL(),
+			Sources:     []string{ternary(item.ChannelName != "", item.ChannelName, widget.Title)},
+			PublishedAt: item.PublishedAt,
+		})
+	}
+
+	return items
+}
+
+func (p forumPostList) feedItems(source string) []feedItem {
+	items := make([]feedItem, 0, len(p))
+
+	for i := range p {
+		items = append(items, feedItem{
+			Title:       p[i].Title,
+			URL:         p[i].SaveURL(),
+			Sources:     []string{source},
+			PublishedAt: p[i].TimePosted,
+		})
+	}
+
+	return items
+}
+
+func (widget *hackerNewsWidget) feedItems() []feedItem {
+	return widget.Posts.feedItems(widget.Title)
+}
+
+func (widget *lobstersWidget) feedItems() []feedItem {
+	return widget.Posts.feedItems(widget.Title)
+}
+
+func (widget *redditWidget) feedItems() []feedItem {
+	return widget.Posts.feedItems(widget.Title)
+}
+
+// Keeps the items that each feed widget had after it was last updated, so
+// that the topics widget doesn't have to read from widgets that may be in the
+// middle of being updated
+type feedItemIndex struct {
+	mu    sync.Mutex
+	items map[uint64][]feedItem
+}
+
+func newFeedItemIndex() *feedItemIndex {
+	return &feedItemIndex{items: make(map[uint64][]feedItem)}
+}
+
+// Called right after the widget was updated, by whoever updated it
+func publishFeedItems(widget widget) {
+	source, ok := widget.(feedItemSource)
+	if !ok {
+		return
+	}
+
+	base, ok := widget.(interface{ getWidgetBase() *widgetBase })
+	if !ok || base.getWidgetBase().Providers == nil || base.getWidgetBase().Providers.feedItems == nil {
+		return
+	}
+
+	index := base.getWidgetBase().Providers.feedItems
+	items := source.feedItems()
+
+	index.mu.Lock()
+	index.items[widget.GetID()] = items
+	index.mu.Unlock()
+}
+
+// Drops the items of widgets that no longer exist after a config reload
+func (i *feedItemIndex) prune(widgetByID map[uint64]widget) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for id := range i.items {
+		if _, exists := widgetByID[id]; !exists {
+			delete(i.items, id)
+		}
+	}
+}
+
+// Returns the items of the feed widgets that anyone who can see the given
+// widget can also see, along with the number of feed widgets that haven't
+// been updated yet. Outdated feed widgets on pages that aren't being
+// rendered at the moment get updated first.
+func (a *application) recentFeedItems(forWidgetID uint64) ([]feedItem, int) {
+	var outdated func(widget widget, now *time.Time, into []widget) []widget
+	outdated = func(widget widget, now *time.Time, into []widget) []widget {
+		if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+			for _, child := range container.getChildWidgets() {
+				into = outdated(child, now, into)
+			}
+
+			return into
+		}
+
+		if _, ok := widget.(feedItemSource); ok && widget.requiresUpdate(now) {
+			into = append(into, widget)
+		}
+
+		return into
+	}
+
+	for p := range a.Config.Pages {
+		page := &a.Config.Pages[p]
+
+		// the page the widget is on is locked while it's being updated and its
+		// other widgets are getting updated along with it
+		if !page.mu.TryLock() {
+			continue
+		}
+
+		now := time.Now()
+		var pending []widget
+		for c := range page.Columns {
+			for _, widget := range page.Columns[c].Widgets {
+				pending = outdated(widget, &now, pending)
+			}
+		}
+
+		var wg sync.WaitGroup
+		for _, widget := range pending {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				a.updateWidgetWithTimeout(widget)
+			}()
+		}
+
+		wg.Wait()
+		page.mu.Unlock()
+	}
+
+	index := a.feedItems
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	var items []feedItem
+	notUpdated := 0
+
+	for id, widget := range a.widgetByID {
+		if _, ok := widget.(feedItemSource); !ok || !a.visibleAlongside(forWidgetID, id) {
+			continue
+		}
+
+		published, ok := index.items[id]
+		if !ok {
+			notUpdated++
+			continue
+		}
+
+		items = append(items, published...)
+	}
+
+	return items, notUpdated
+}
+
+// Whether anyone who can see the viewing widget can also see the other one,
+// for widgets that show what other widgets have
+func (a *application) visibleAlongside(viewerID, widgetID uint64) bool {
+	viewerAccess := a.widgetAccess[viewerID]
+
+	for _, access := range a.widgetAccess[widgetID] {
+		if access.isRestricted() && !slices.Contains(viewerAccess, access) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type feedTopic struct {
+	Label   string
+	Items   []feedItem
+	Sources int
+}
+
+// Groups today's items from all feed widgets into topics so that whatever
+// many of them are talking about stands out
+type topicsWidget struct {
+	widgetBase    `yaml:",inline"`
+	Period        durationField `yaml:"period"`
+	Similarity    float64       `yaml:"similarity"`
+	MinItems      int           `yaml:"min-items"`
+	Limit         int           `yaml:"limit"`
+	CollapseAfter int           `yaml:"collapse-after"`
+	Topics        []feedTopic   `yaml:"-"`
+	ItemCount     int           `yaml:"-"`
+}
+
+func (widget *topicsWidget) initialize() error {
+	widget.withTitle("Topics").withCacheDuration(10 * time.Minute)
+
+	if widget.Similarity < 0 || widget.Similarity > 1 {
+		return errors.New("similarity must be between 0 and 1")
+	}
+
+	if widget.Similarity == 0 {
+		widget.Similarity = defaultTopicsSimilarity
+	}
+
+	if widget.MinItems <= 0 {
+		widget.MinItems = 3
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 10
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *topicsWidget) update(ctx context.Context) {
+	items, notUpdated := widget.Providers.feedItemsResolver(widget.GetID())
+
+	cutoff := time.Now().Add(-ternary(widget.Period > 0, time.Duration(widget.Period), defaultTopicsPeriod))
+	items = mergeFeedItems(items, cutoff)
+
+	topics := clusterFeedItems(items, widget.Similarity, widget.MinItems)
+	if len(topics) > widget.Limit {
+		topics = topics[:widget.Limit]
+	}
+
+	widget.Topics = topics
+	widget.ItemCount = len(items)
+	widget.canContinueUpdateAfterHandlingErr(nil)
+
+	// widgets on the same page get updated at the same time as this one, so
+	// their items only become available on the next update
+	if notUpdated > 0 {
+		widget.scheduleEarlyUpdate()
+	}
+}
+
+func (widget *topicsWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, topicsWidgetTemplate)
+}
+
+// Leaves out items published before the cutoff and combines items that link
+// to the same page, such as a story submitted to both Hacker News and Lobsters
+func mergeFeedItems(items []feedItem, cutoff time.Time) []feedItem {
+	merged := make([]feedItem, 0, len(items))
+	byURL := make(map[string]int, len(items))
+
+	for _, item := range items {
+		if item.PublishedAt.Before(cutoff) || item.Title == "" {
+			continue
+		}
+
+		if i, ok := byURL[item.URL]; ok && item.URL != "" {
+			for _, source := range item.Sources {
+				if !slices.Contains(merged[i].Sources, source) {
+					merged[i].Sources = append(merged[i].Sources, source)
+				}
+			}
+
+			continue
+		}
+
+		byURL[item.URL] = len(merged)
+		item.Sources = slices.Clone(item.Sources)
+		merged = append(merged, item)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].PublishedAt.After(merged[j].PublishedAt)
+	})
+
+	return merged
+}
+
+var topicWordPattern = regexp.MustCompile(`[\p{L}\p{N}]+(?:[-'’][\p{L}\p{N}]+)*`)
+
+var topicStopWords = func() map[string]struct{} {
+	words := strings.Fields(`
+		a about above after again against all also am an and any are as at be because been before being below
+		between both but by can could did do does doing down during each few for from further get gets got had has
+		have having he her here hers herself him himself his how i if in into is it its itself just let like made
+		make makes many me more most much my myself new no nor not now of off on once one only or other our ours
+		ourselves out over own really same say says said she should so some still such than that the their theirs
+		them themselves then there these they this those through to too under until up upon us use used using very
+		via vs was way we were what when where which while who whom why will with without would you your yours
+		yourself yourselves ask show hn tell launch just first last next back big best top year years day days
+		week weeks today here's it's what's that's don't can't won't isn't you're i'm we're they're
+	`)
+
+	set := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		set[word] = struct{}{}
+	}
+
+	return set
+}()
+
+// A crude stemmer that only takes care of plurals and possessives, it doesn't
+// need to produce real words since the label uses the words as they appeared
+func stemTopicWord(word string) string {
+	word = strings.TrimSuffix(strings.TrimSuffix(word, "'s"), "’s")
+
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 3 && strings.HasSuffix(word, "s") &&
+		!strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		return word[:len(word)-1]
+	}
+
+	return word
+}
+
+// Returns the stems of the words worth comparing along with the way each of
+// them was written, short words are only kept when they're acronyms like AI
+func topicTerms(title string) ([]string, []string) {
+	var terms, forms []string
+
+	for _, word := range topicWordPattern.FindAllString(title, -1) {
+		lower := strings.ToLower(word)
+		if _, stop := topicStopWords[lower]; stop {
+			continue
+		}
+
+		if strings.IndexFunc(word, func(r rune) bool { return !unicode.IsDigit(r) }) == -1 {
+			continue
+		}
+
+		if len([]rune(word)) < 3 && strings.ToUpper(word) != word {
+			continue
+		}
+
+		terms = append(terms, stemTopicWord(lower))
+		forms = append(forms, word)
+	}
+
+	return terms, forms
+}
+
+type topicVector map[string]float64
+
+func (v topicVector) normalize() {
+	var sum float64
+	for _, weight := range v {
+		sum += weight * weight
+	}
+
+	if sum == 0 {
+		return
+	}
+
+	norm := math.Sqrt(sum)
+	for term := range v {
+		v[term] /= norm
+	}
+}
+
+func (v topicVector) dot(other topicVector) float64 {
+	if len(other) < len(v) {
+		v, other = other, v
+	}
+
+	var sum float64
+	for term, weight := range v {
+		sum += weight * other[term]
+	}
+
+	return sum
+}
+
+type topicCluster struct {
+	items    []int
+	centroid topicVector
+}
+
+func (c *topicCluster) add(item int, vector topicVector) {
+	c.items = append(c.items, item)
+	for term, weight := range vector {
+		c.centroid[term] += weight
+	}
+}
+
+func (c *topicCluster) normalizedCentroid() topicVector {
+	centroid := make(topicVector, len(c.centroid))
+	for term, weight := range c.centroid {
+		centroid[term] = weight
+	}
+
+	centroid.normalize()
+	return centroid
+}
+
+// Weighs the words of each title with TF-IDF so that words used by only a few
+// of the items count the most, then puts each item into the group whose
+// average is the most similar to it or starts a new group when none is
+// similar enough. Groups with fewer than minItems items are left out and the
+// rest are ordered by how many different sources they come from.
+func clusterFeedItems(items []feedItem, similarity float64, minItems int) []feedTopic {
+	termsOfItem := make([][]string, len(items))
+	documentFrequency := make(map[string]int)
+	// how many times each way of writing a stem was used, for the labels
+	forms := make(map[string]map[string]int)
+
+	for i := range items {
+		terms, written := topicTerms(items[i].Title)
+		termsOfItem[i] = terms
+
+		seen := make(map[string]struct{}, len(terms))
+		for t, term := range terms {
+			if forms[term] == nil {
+				forms[term] = make(map[string]int)
+			}
+			forms[term][written[t]]++
+
+			if _, ok := seen[term]; !ok {
+				seen[term] = struct{}{}
+				documentFrequency[term]++
+			}
+		}
+	}
+
+	vectors := make([]topicVector, len(items))
+	for i, terms := range termsOfItem {
+		vector := make(topicVector, len(terms))
+		for _, term := range terms {
+			// a word that's in a single item can't connect it to any other one
+			if documentFrequency[term] < 2 {
+				continue
+			}
+
+			vector[term] += math.Log(float64(len(items)+1)/float64(documentFrequency[term]+1)) + 1
+		}
+
+		vector.normalize()
+		vectors[i] = vector
+	}
+
+	var clusters []*topicCluster
+
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			continue
+		}
+
+		var best *topicCluster
+		bestSimilarity := similarity
+
+		for _, cluster := range clusters {
+			if s := vector.dot(cluster.normalizedCentroid()); s >= bestSimilarity {
+				best, bestSimilarity = cluster, s
+			}
+		}
+
+		if best == nil {
+			best = &topicCluster{centroid: make(topicVector)}
+			clusters = append(clusters, best)
+		}
+
+		best.add(i, vector)
+	}
+
+	// which group an item ends up in depends on the order they were gone
+	// through in, going through them again with the groups as they are now
+	// lets items move to the group they fit the best
+	centroids := make([]topicVector, len(clusters))
+	for c, cluster := range clusters {
+		centroids[c] = cluster.normalizedCentroid()
+		cluster.items = nil
+		cluster.centroid = make(topicVector)
+	}
+
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			continue
+		}
+
+		best, bestSimilarity := 0, -1.0
+		for c := range centroids {
+			if s := vector.dot(centroids[c]); s > bestSimilarity {
+				best, bestSimilarity = c, s
+			}
+		}
+
+		clusters[best].add(i, vector)
+	}
+
+	topics := make([]feedTopic, 0, len(clusters))
+
+	for _, cluster := range clusters {
+		if len(cluster.items) < minItems {
+			continue
+		}
+
+		topic := feedTopic{
+			Label: topicLabel(cluster, termsOfItem, forms),
+			Items: make([]feedItem, 0, len(cluster.items)),
+		}
+
+		sources := make(map[string]struct{})
+		for _, i := range cluster.items {
+			topic.Items = append(topic.Items, items[i])
+			for _, source := range items[i].Sources {
+				sources[source] = struct{}{}
+			}
+		}
+
+		topic.Sources = len(sources)
+		topics = append(topics, topic)
+	}
+
+	sort.SliceStable(topics, func(i, j int) bool {
+		if topics[i].Sources != topics[j].Sources {
+			return topics[i].Sources > topics[j].Sources
+		}
+
+		return len(topics[i].Items) > len(topics[j].Items)
+	})
+
+	return topics
+}
+
+// Made out of up to three of the words shared by the most items in the group,
+// written the way they were written most often
+func topicLabel(cluster *topicCluster, termsOfItem [][]string, forms map[string]map[string]int) string {
+	shared := make(map[string]int)
+	for _, i := range cluster.items {
+		seen := make(map[string]struct{})
+		for _, term := range termsOfItem[i] {
+			if _, ok := seen[term]; !ok {
+				seen[term] = struct{}{}
+				shared[term]++
+			}
+		}
+	}
+
+	terms := make([]string, 0, len(shared))
+	for term, count := range shared {
+		if count >= 2 {
+			terms = append(terms, term)
+		}
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		if shared[terms[i]] != shared[terms[j]] {
+			return shared[terms[i]] > shared[terms[j]]
+		}
+
+		if cluster.centroid[terms[i]] != cluster.centroid[terms[j]] {
+			return cluster.centroid[terms[i]] > cluster.centroid[terms[j]]
+		}
+
+		return terms[i] < terms[j]
+	})
+
+	if len(terms) > 3 {
+		terms = terms[:3]
+	}
+
+	words := make([]string, 0, len(terms))
+	for _, term := range terms {
+		best, bestCount := term, 0
+		for form, count := range forms[term] {
+			if count > bestCount || (count == bestCount && form < best) {
+				best, bestCount = form, count
+			}
+		}
+
+		words = append(words, best)
+	}
+
+	return strings.Join(words, ", ")
+}
+
+type feedTopicJson struct {
+	Label   string         `json:"label"`
+	Sources int            `json:"sources"`
+	Items   []feedItemJson `json:"items"`
+}
+
+type feedItemJson struct {
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Sources     []string  `json:"sources"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+func (widget *topicsWidget) data() any {
+	topics := make([]feedTopicJson, 0, len(widget.Topics))
+
+	for _, topic := range widget.Topics {
+		items := make([]feedItemJson, 0, len(topic.Items))
+		for _, item := range topic.Items {
+			items = append(items, feedItemJson(item))
+		}
+
+		topics = append(topics, feedTopicJson{Label: topic.Label, Sources: topic.Sources, Items: items})
+	}
+
+	return topics
+}
@@ -0,0 +1,215 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var traktWidgetTemplate = mustParseTemplate("trakt.html", "widget-base.html")
+
+const traktAPIURL = "https://api.trakt.tv"
+
+type traktWidget struct {
+	widgetBase    `yaml:",inline"`
+	ClientID      string         `yaml:"client-id"`
+	AccessToken   string         `yaml:"access-token"`
+	Days          int            `yaml:"days"`
+	HideTrending  bool           `yaml:"hide-trending"`
+	Limit         int            `yaml:"limit"`
+	CollapseAfter int            `yaml:"collapse-after"`
+	Upcoming      []traktEpisode `yaml:"-"`
+	Trending      []traktShow    `yaml:"-"`
+}
+
+type traktEpisode struct {
+	Show     string
+	Subtitle string
+	URL      string
+	AirsAt   time.Time
+}
+
+type traktShow struct {
+	Title    string
+	Year     int
+	URL      string
+	Watchers int
+}
+
+func (widget *traktWidget) initialize() error {
+	widget.withTitle("Trakt").withCacheDuration(time.Hour)
+
+	if widget.ClientID == "" {
+		return errors.New("client-id is required")
+	}
+
+	if widget.AccessToken == "" && widget.HideTrending {
+		return errors.New("access-token is required when trending shows are hidden")
+	}
+
+	if widget.Days <= 0 {
+		widget.Days = 7
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 10
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *traktWidget) update(ctx context.Context) {
+	var upcoming []traktEpisode
+	var trending []traktShow
+	var err error
+
+	if widget.AccessToken != "" {
+		upcoming, err = fetchTraktUpcomingEpisodes(widget.httpClient(false), widget.ClientID, widget.AccessToken, widget.Days, widget.Limit)
+		if err != nil {
+			err = fmt.Errorf("fetching calendar: %v", err)
+		}
+	}
+
+	if err == nil && !widget.HideTrending {
+		trending, err = fetchTraktTrendingShows(widget.httpClient(false), widget.ClientID, widget.Limit)
+		if err != nil {
+			err = fmt.Errorf("fetching trending shows: %v", err)
+		}
+	}
+
+	if err != nil {
+		err = fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Upcoming = upcoming
+	widget.Trending = trending
+}
+
+func (widget *traktWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, traktWidgetTemplate)
+}
+
+type traktShowJson struct {
+	Title string `json:"title"`
+	Year  int    `json:"year"`
+	IDs   struct {
+		Slug string `json:"slug"`
+	} `json:"ids"`
+}
+
+type traktCalendarEntryJson struct {
+	FirstAired string `json:"first_aired"`
+	Episode    struct {
+		Title  string `json:"title"`
+		Season int    `json:"season"`
+		Number int    `json:"number"`
+	} `json:"episode"`
+	Show traktShowJson `json:"show"`
+}
+
+type traktTrendingShowJson struct {
+	Watchers int           `json:"watchers"`
+	Show     traktShowJson `json:"show"`
+}
+
+func newTraktRequest(clientID, path string) (*http.Request, error) {
+	request, err := http.NewRequest("GET", traktAPIURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("trakt-api-version", "2")
+	request.Header.Set("trakt-api-key", clientID)
+
+	return request, nil
+}
+
+func traktShowURL(show *traktShowJson) string {
+	return "https://trakt.tv/shows/" + show.IDs.Slug
+}
+
+func fetchTraktUpcomingEpisodes(client requestDoer, clientID, accessToken string, days int, limit int) ([]traktEpisode, error) {
+	start := time.Now().Format("2006-01-02")
+
+	request, err := newTraktRequest(clientID, "/calendars/my/shows/"+start+"/"+strconv.Itoa(days))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+
+	entries, err := decodeJsonFromRequest[[]traktCalendarEntryJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	episodes := make([]traktEpisode, 0, min(len(entries), limit))
+	now := time.Now()
+
+	for i := range entries {
+		entry := &entries[i]
+
+		airsAt := parseRFC3339Time(entry.FirstAired)
+		if airsAt.Before(now) {
+			continue
+		}
+
+		subtitle := formatEpisodeNumber(entry.Episode.Season, entry.Episode.Number)
+		if entry.Episode.Title != "" {
+			subtitle += " · " + entry.Episode.Title
+		}
+
+		episodes = append(episodes, traktEpisode{
+			Show:     entry.Show.Title,
+			Subtitle: subtitle,
+			URL:      fmt.Sprintf("%s/seasons/%d/episodes/%d", traktShowURL(&entry.Show), entry.Episode.Season, entry.Episode.Number),
+			AirsAt:   airsAt,
+		})
+
+		if len(episodes) == limit {
+			break
+		}
+	}
+
+	return episodes, nil
+}
+
+func fetchTraktTrendingShows(client requestDoer, clientID string, limit int) ([]traktShow, error) {
+	request, err := newTraktRequest(clientID, "/shows/trending?limit="+strconv.Itoa(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeJsonFromRequest[[]traktTrendingShowJson](client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	shows := make([]traktShow, 0, len(response))
+
+	for i := range response {
+		item := &response[i]
+
+		shows = append(shows, traktShow{
+			Title:    item.Show.Title,
+			Year:     item.Show.Year,
+			URL:      traktShowURL(&item.Show),
+			Watchers: item.Watchers,
+		})
+	}
+
+	return shows, nil
+}
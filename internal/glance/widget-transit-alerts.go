@@ -0,0 +1,379 @@
+package glance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+var transitAlertsWidgetTemplate = mustParseTemplate("transit-alerts.html", "widget-base.html")
+
+const transitAlertsFeedMaxSize = 20 * 1024 * 1024
+
+const (
+	transitAlertSeverityInfo = iota + 1
+	transitAlertSeverityWarning
+	transitAlertSeveritySevere
+)
+
+var transitAlertSeverityNames = []string{"info", "warning", "severe"}
+
+type transitAlertsWidget struct {
+	widgetBase    `yaml:",inline"`
+	Feeds         []transitAlertsFeed `yaml:"feeds"`
+	MinSeverity   string              `yaml:"min-severity"`
+	CollapseAfter int                 `yaml:"collapse-after"`
+	Alerts        []transitAlert      `yaml:"-"`
+	minSeverity   int
+}
+
+type transitAlertsFeed struct {
+	Type          string              `yaml:"type"`
+	Name          string              `yaml:"name"`
+	URL           string              `yaml:"url"`
+	APIKey        string              `yaml:"api-key"`
+	Headers       map[string]string   `yaml:"headers"`
+	AllowInsecure bool                `yaml:"allow-insecure"`
+	Lines         []string            `yaml:"lines"`
+	source        transitAlertsSource `yaml:"-"`
+}
+
+// Implemented by each of the supported types of feeds. Only the alerts that
+// currently apply to the configured lines are returned.
+type transitAlertsSource interface {
+	fetchAlerts(ctx context.Context, lang string) ([]transitAlert, error)
+}
+
+type transitAlert struct {
+	Feed        string
+	Lines       []string
+	Header      string
+	Description string
+	URL         string
+	Effect      string
+	Severity    int
+	Start       time.Time
+	End         time.Time
+}
+
+func (a transitAlert) SeverityName() string {
+	return transitAlertSeverityNames[a.Severity-1]
+}
+
+func (widget *transitAlertsWidget) initialize() error {
+	widget.withTitle("Service Alerts").withCacheDuration(5 * time.Minute)
+
+	if len(widget.Feeds) == 0 {
+		return errors.New("no feeds specified")
+	}
+
+	for i := range widget.Feeds {
+		feed := &widget.Feeds[i]
+		client := widget.httpClient(feed.AllowInsecure)
+
+		switch feed.Type {
+		case "", "gtfs-rt":
+			if feed.URL == "" {
+				return fmt.Errorf("feed %d: url is required", i+1)
+			}
+
+			feed.source = &gtfsRealtimeAlertsSource{url: feed.URL, headers: feed.Headers, lines: feed.Lines, client: client}
+		case "tfl":
+			if len(feed.Lines) == 0 {
+				return fmt.Errorf("feed %d: lines are required", i+1)
+			}
+
+			feed.source = &tflAlertsSource{apiKey: feed.APIKey, lines: feed.Lines, client: client}
+		default:
+			return fmt.Errorf("feed %d: unsupported type %s, must be either gtfs-rt or tfl", i+1, feed.Type)
+		}
+	}
+
+	if widget.MinSeverity == "" {
+		widget.MinSeverity = "info"
+	}
+
+	widget.minSeverity = slices.Index(transitAlertSeverityNames, widget.MinSeverity) + 1
+	if widget.minSeverity == 0 {
+		return fmt.Errorf("min-severity must be one of %s", strings.Join(transitAlertSeverityNames, ", "))
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *transitAlertsWidget) update(ctx context.Context) {
+	lang := widget.locale().language()
+
+	job := newJob(func(feed *transitAlertsFeed) ([]transitAlert, error) {
+		return feed.source.fetchAlerts(ctx, lang)
+	}, widget.feeds()).withWorkers(len(widget.Feeds))
+
+	results, errs, err := workerPoolDo(job)
+	if err != nil {
+		widget.canContinueUpdateAfterHandlingErr(fmt.Errorf("%w: %v", errNoContent, err))
+		return
+	}
+
+	alerts := make([]transitAlert, 0)
+	var failed int
+
+	for i := range results {
+		if errs[i] != nil {
+			failed++
+			widget.logger().Error("Failed to fetch transit alerts", "feed", widget.Feeds[i].Name, "error", errs[i])
+			continue
+		}
+
+		for _, alert := range results[i] {
+			if alert.Severity < widget.minSeverity {
+				continue
+			}
+
+			alert.Feed = widget.Feeds[i].Name
+			alerts = append(alerts, alert)
+		}
+	}
+
+	if failed == len(widget.Feeds) {
+		err = fmt.Errorf("%w: %v", errNoContent, errs[0])
+	} else if failed > 0 {
+		err = fmt.Errorf("%w: could not fetch %d feeds", errPartialContent, failed)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	// the most severe first, and the most recent first within the same severity
+	slices.SortStableFunc(alerts, func(a, b transitAlert) int {
+		if a.Severity != b.Severity {
+			return b.Severity - a.Severity
+		}
+
+		return b.Start.Compare(a.Start)
+	})
+
+	widget.Alerts = alerts
+}
+
+func (widget *transitAlertsWidget) feeds() []*transitAlertsFeed {
+	feeds := make([]*transitAlertsFeed, len(widget.Feeds))
+	for i := range widget.Feeds {
+		feeds[i] = &widget.Feeds[i]
+	}
+
+	return feeds
+}
+
+func (widget *transitAlertsWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, transitAlertsWidgetTemplate)
+}
+
+type gtfsRealtimeAlertsSource struct {
+	url     string
+	headers map[string]string
+	lines   []string
+	client  requestDoer
+}
+
+func (s *gtfsRealtimeAlertsSource) fetchAlerts(ctx context.Context, lang string) ([]transitAlert, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range s.headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, transitAlertsFeedMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > transitAlertsFeedMaxSize {
+		return nil, errors.New("feed is too large")
+	}
+
+	feedAlerts, err := parseGTFSRealtimeAlerts(data, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	alerts := make([]transitAlert, 0, len(feedAlerts))
+
+	for i := range feedAlerts {
+		feedAlert := &feedAlerts[i]
+
+		start, end, active := feedAlert.activePeriod(now)
+		if !active {
+			continue
+		}
+
+		lines := feedAlert.Routes
+		if len(s.lines) > 0 {
+			lines = slices.DeleteFunc(slices.Clone(lines), func(route string) bool {
+				return !slices.Contains(s.lines, route)
+			})
+
+			if len(lines) == 0 {
+				continue
+			}
+		}
+
+		effect := gtfsAlertEffects[feedAlert.Effect]
+
+		alerts = append(alerts, transitAlert{
+			Lines:       lines,
+			Header:      ternary(feedAlert.Header != "", feedAlert.Header, effect),
+			Description: feedAlert.Description,
+			URL:         feedAlert.URL,
+			Effect:      ternary(feedAlert.Effect == 8 || feedAlert.Header == "", "", effect),
+			Severity:    gtfsAlertSeverity(feedAlert),
+			Start:       start,
+			End:         end,
+		})
+	}
+
+	return alerts, nil
+}
+
+// Not all feeds set a severity, in which case it's guessed from the effect
+func gtfsAlertSeverity(alert *gtfsAlert) int {
+	switch alert.Severity {
+	case gtfsSeverityInfo:
+		return transitAlertSeverityInfo
+	case gtfsSeverityWarning:
+		return transitAlertSeverityWarning
+	case gtfsSeveritySevere:
+		return transitAlertSeveritySevere
+	}
+
+	switch alert.Effect {
+	case 1:
+		return transitAlertSeveritySevere
+	case 2, 3, 4, 6, 9:
+		return transitAlertSeverityWarning
+	}
+
+	return transitAlertSeverityInfo
+}
+
+// The line status API of Transport for London
+type tflAlertsSource struct {
+	apiKey string
+	lines  []string
+	client requestDoer
+}
+
+type tflLineStatusResponseJson []struct {
+	Name         string `json:"name"`
+	LineStatuses []struct {
+		StatusSeverity            int    `json:"statusSeverity"`
+		StatusSeverityDescription string `json:"statusSeverityDescription"`
+		Reason                    string `json:"reason"`
+		ValidityPeriods           []struct {
+			FromDate string `json:"fromDate"`
+			ToDate   string `json:"toDate"`
+			IsNow    bool   `json:"isNow"`
+		} `json:"validityPeriods"`
+	} `json:"lineStatuses"`
+}
+
+func (s *tflAlertsSource) fetchAlerts(ctx context.Context, _ string) ([]transitAlert, error) {
+	lines := make([]string, len(s.lines))
+	for i := range s.lines {
+		lines[i] = url.PathEscape(s.lines[i])
+	}
+
+	target := "https://api.tfl.gov.uk/Line/" + strings.Join(lines, ",") + "/Status"
+	if s.apiKey != "" {
+		target += "?app_key=" + url.QueryEscape(s.apiKey)
+	}
+
+	request, _ := http.NewRequestWithContext(ctx, "GET", target, nil)
+	response, err := decodeJsonFromRequest[tflLineStatusResponseJson](s.client, request)
+	if err != nil {
+		if s.apiKey != "" {
+			return nil, &redactedError{err: err, secret: s.apiKey}
+		}
+
+		return nil, err
+	}
+
+	alerts := make([]transitAlert, 0)
+
+	for _, line := range response {
+		for _, status := range line.LineStatuses {
+			severity := tflStatusSeverity(status.StatusSeverity)
+			if severity == 0 {
+				continue
+			}
+
+			alert := transitAlert{
+				Lines:       []string{line.Name},
+				Header:      status.StatusSeverityDescription,
+				Description: status.Reason,
+				Severity:    severity,
+			}
+
+			for _, period := range status.ValidityPeriods {
+				if period.IsNow {
+					alert.Start, _ = time.Parse(time.RFC3339, period.FromDate)
+					alert.End, _ = time.Parse(time.RFC3339, period.ToDate)
+					break
+				}
+			}
+
+			// the same disruption is listed separately for every line it affects
+			index := slices.IndexFunc(alerts, func(a transitAlert) bool {
+				return a.Header == alert.Header && a.Description == alert.Description
+			})
+
+			if index != -1 {
+				alerts[index].Lines = append(alerts[index].Lines, line.Name)
+				continue
+			}
+
+			alerts = append(alerts, alert)
+		}
+	}
+
+	return alerts, nil
+}
+
+// Good service and no issues are left out
+func tflStatusSeverity(status int) int {
+	switch status {
+	case 10, 18:
+		return 0
+	case 1, 2, 3, 4, 5, 6, 11, 16, 20:
+		return transitAlertSeveritySevere
+	case 7, 8, 9, 14, 15, 17:
+		return transitAlertSeverityWarning
+	}
+
+	return transitAlertSeverityInfo
+}
@@ -0,0 +1,579 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var travelTimeWidgetTemplate = mustParseTemplate("travel-time.html", "widget-base.html")
+
+type travelTimeWidget struct {
+	widgetBase   `yaml:",inline"`
+	ProviderName string             `yaml:"provider"`
+	APIKey       string             `yaml:"api-key"`
+	URL          string             `yaml:"url"`
+	Units        string             `yaml:"units"`
+	UpdateDuring timeWindows        `yaml:"update-during"`
+	Routes       []travelTimeRoute  `yaml:"routes"`
+	Results      []travelTimeResult `yaml:"-"`
+	provider     travelTimeProvider `yaml:"-"`
+	locations    travelTimeLocations
+	// guards Results being set, see weatherWidget
+	resultsMu sync.Mutex
+}
+
+type travelTimeRoute struct {
+	Name         string      `yaml:"name"`
+	Origin       string      `yaml:"origin"`
+	Destination  string      `yaml:"destination"`
+	UpdateDuring timeWindows `yaml:"update-during"`
+}
+
+// Implemented by each of the supported services. The typical duration is what
+// the trip takes without traffic and is left as 0 by services that don't take
+// traffic into account.
+type travelTimeProvider interface {
+	fetchTravelTime(ctx context.Context, origin, destination string) (travelTime, error)
+}
+
+type travelTime struct {
+	Duration time.Duration
+	Typical  time.Duration
+	// in meters
+	Distance float64
+}
+
+type travelTimeResult struct {
+	travelTime
+	Name      string
+	HasValue  bool
+	Error     bool
+	UpdatedAt time.Time
+	units     string
+	windows   timeWindows
+}
+
+func (widget *travelTimeWidget) initialize() error {
+	widget.withTitle("Travel Time").withCacheDuration(5 * time.Minute)
+
+	if len(widget.Routes) == 0 {
+		return errors.New("no routes specified")
+	}
+
+	for i := range widget.Routes {
+		route := &widget.Routes[i]
+
+		if route.Origin == "" || route.Destination == "" {
+			return fmt.Errorf("route %d: origin and destination are required", i+1)
+		}
+
+		if route.Name == "" {
+			route.Name = route.Origin + " → " + route.Destination
+		}
+	}
+
+	if widget.Units != "" && widget.Units != "metric" && widget.Units != "imperial" {
+		return errors.New("units must be either metric or imperial")
+	}
+
+	client := widget.httpClient(false)
+
+	switch widget.ProviderName {
+	case "openrouteservice":
+		if widget.URL == "" {
+			if widget.APIKey == "" {
+				return errors.New("api-key is required")
+			}
+
+			widget.URL = "https://api.openrouteservice.org"
+		}
+
+		widget.provider = &openRouteServiceProvider{
+			url:       strings.TrimRight(widget.URL, "/"),
+			apiKey:    widget.APIKey,
+			client:    client,
+			locations: &widget.locations,
+		}
+	case "here":
+		if widget.APIKey == "" {
+			return errors.New("api-key is required")
+		}
+
+		widget.provider = &hereProvider{apiKey: widget.APIKey, client: client, locations: &widget.locations}
+	case "google":
+		if widget.APIKey == "" {
+			return errors.New("api-key is required")
+		}
+
+		widget.provider = &googleRoutesProvider{apiKey: widget.APIKey, client: client}
+	case "":
+		return errors.New("provider is required")
+	default:
+		return fmt.Errorf("unsupported provider %s, must be one of openrouteservice, here or google", widget.ProviderName)
+	}
+
+	return nil
+}
+
+// Routes are only checked during their update windows, outside of them the
+// last known travel time is kept around
+func (widget *travelTimeWidget) requiresUpdate(now *time.Time) bool {
+	if !widget.widgetBase.requiresUpdate(now) {
+		return false
+	}
+
+	if widget.Results == nil {
+		return true
+	}
+
+	for i := range widget.Routes {
+		if widget.routeNeedsUpdate(i, *now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (widget *travelTimeWidget) routeNeedsUpdate(index int, now time.Time) bool {
+	if widget.Results == nil || !widget.Results[index].HasValue {
+		return true
+	}
+
+	windows := widget.routeWindows(index)
+	return len(windows) == 0 || windows.contains(now)
+}
+
+func (widget *travelTimeWidget) routeWindows(index int) timeWindows {
+	if len(widget.Routes[index].UpdateDuring) > 0 {
+		return widget.Routes[index].UpdateDuring
+	}
+
+	return widget.UpdateDuring
+}
+
+func (widget *travelTimeWidget) update(ctx context.Context) {
+	now := time.Now()
+	routes := make([]*travelTimeRoute, 0, len(widget.Routes))
+	indexes := make([]int, 0, len(widget.Routes))
+
+	for i := range widget.Routes {
+		if widget.routeNeedsUpdate(i, now) {
+			routes = append(routes, &widget.Routes[i])
+			indexes = append(indexes, i)
+		}
+	}
+
+	job := newJob(func(route *travelTimeRoute) (travelTime, error) {
+		return widget.provider.fetchTravelTime(ctx, route.Origin, route.Destination)
+	}, routes).withWorkers(len(routes))
+
+	times, errs, err := workerPoolDo(job)
+	if err != nil {
+		widget.canContinueUpdateAfterHandlingErr(fmt.Errorf("%w: %v", errNoContent, err))
+		return
+	}
+
+	results := make([]travelTimeResult, len(widget.Routes))
+	if widget.Results != nil {
+		copy(results, widget.Results)
+	}
+
+	var failed int
+	var lastErr error
+
+	for i, index := range indexes {
+		if errs[i] != nil {
+			failed++
+			lastErr = errs[i]
+			widget.logger().Error("Failed to fetch travel time", "provider", widget.ProviderName, "route", widget.Routes[index].Name, "error", errs[i])
+			results[index].Error = true
+			continue
+		}
+
+		results[index] = travelTimeResult{travelTime: times[i], HasValue: true, UpdatedAt: now}
+	}
+
+	units := widget.units(widget.Units)
+	for i := range results {
+		results[i].Name = widget.Routes[i].Name
+		results[i].units = units
+		results[i].windows = widget.routeWindows(i)
+	}
+
+	if failed > 0 && failed == len(indexes) && widget.Results == nil {
+		err = fmt.Errorf("%w: %v", errNoContent, lastErr)
+	} else if failed > 0 {
+		err = fmt.Errorf("%w: could not fetch the travel time of %d routes", errPartialContent, failed)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.resultsMu.Lock()
+	widget.Results = results
+	widget.resultsMu.Unlock()
+}
+
+func (r travelTimeResult) FormattedDuration() string {
+	return formatDuration(r.Duration.Round(time.Minute))
+}
+
+// How much longer the trip takes because of traffic, shorter delays than a
+// minute aren't worth mentioning
+func (r travelTimeResult) Delay() time.Duration {
+	if r.Typical == 0 || r.Duration-r.Typical < time.Minute {
+		return 0
+	}
+
+	return (r.Duration - r.Typical).Round(time.Minute)
+}
+
+func (r travelTimeResult) FormattedDelay() string {
+	return formatDuration(r.Delay())
+}
+
+// Delays of more than a quarter of the usual travel time stand out
+func (r travelTimeResult) HasMajorDelay() bool {
+	return r.Delay() > 0 && r.Delay()*4 >= r.Typical
+}
+
+func (r travelTimeResult) HasTraffic() bool {
+	return r.Typical > 0
+}
+
+func (r travelTimeResult) FormattedDistance() string {
+	if r.units == "imperial" {
+		return strconv.FormatFloat(r.Distance/1609.344, 'f', 1, 64) + " mi"
+	}
+
+	return strconv.FormatFloat(r.Distance/1000, 'f', 1, 64) + " km"
+}
+
+// Outside of its update window, a route shows the travel time from the last
+// time it was checked
+func (r travelTimeResult) IsPaused() bool {
+	return r.HasValue && len(r.windows) > 0 && !r.windows.contains(time.Now())
+}
+
+func (widget *travelTimeWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, travelTimeWidgetTemplate)
+}
+
+type travelTimeLocation struct {
+	Lat float64
+	Lon float64
+}
+
+// Parses locations in the format of latitude,longitude such as 52.52,13.405
+func parseTravelTimeLocation(value string) (travelTimeLocation, bool) {
+	lat, lon, found := strings.Cut(value, ",")
+	if !found {
+		return travelTimeLocation{}, false
+	}
+
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return travelTimeLocation{}, false
+	}
+
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return travelTimeLocation{}, false
+	}
+
+	return travelTimeLocation{Lat: latitude, Lon: longitude}, true
+}
+
+// Addresses only get looked up once since they're not expected to move and
+// geocoding requests count towards the same limits as routes do
+type travelTimeLocations struct {
+	mu     sync.Mutex
+	cached map[string]travelTimeLocation
+}
+
+func (l *travelTimeLocations) resolve(
+	ctx context.Context,
+	value string,
+	geocode func(context.Context, string) (travelTimeLocation, error),
+) (travelTimeLocation, error) {
+	if location, ok := parseTravelTimeLocation(value); ok {
+		return location, nil
+	}
+
+	l.mu.Lock()
+	location, ok := l.cached[value]
+	l.mu.Unlock()
+
+	if ok {
+		return location, nil
+	}
+
+	location, err := geocode(ctx, value)
+	if err != nil {
+		return travelTimeLocation{}, fmt.Errorf("looking up %s: %v", value, err)
+	}
+
+	l.mu.Lock()
+	if l.cached == nil {
+		l.cached = make(map[string]travelTimeLocation)
+	}
+	l.cached[value] = location
+	l.mu.Unlock()
+
+	return location, nil
+}
+
+// OpenRouteService doesn't take traffic into account, so there's never a delay
+type openRouteServiceProvider struct {
+	url       string
+	apiKey    string
+	client    requestDoer
+	locations *travelTimeLocations
+}
+
+type openRouteServiceDirectionsResponseJson struct {
+	Features []struct {
+		Properties struct {
+			Summary struct {
+				Duration float64 `json:"duration"`
+				Distance float64 `json:"distance"`
+			} `json:"summary"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+type openRouteServiceGeocodeResponseJson struct {
+	Features []struct {
+		Geometry struct {
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"features"`
+}
+
+func (p *openRouteServiceProvider) newRequest(ctx context.Context, path string, query url.Values) *http.Request {
+	request, _ := http.NewRequestWithContext(ctx, "GET", p.url+path+"?"+query.Encode(), nil)
+	request.Header.Set("Accept", "application/json, application/geo+json")
+	if p.apiKey != "" {
+		request.Header.Set("Authorization", p.apiKey)
+	}
+
+	return request
+}
+
+func (p *openRouteServiceProvider) fetchTravelTime(ctx context.Context, origin, destination string) (travelTime, error) {
+	from, err := p.locations.resolve(ctx, origin, p.geocode)
+	if err != nil {
+		return travelTime{}, err
+	}
+
+	to, err := p.locations.resolve(ctx, destination, p.geocode)
+	if err != nil {
+		return travelTime{}, err
+	}
+
+	// coordinates are the other way around from what's usual
+	request := p.newRequest(ctx, "/v2/directions/driving-car", url.Values{
+		"start": {fmt.Sprintf("%f,%f", from.Lon, from.Lat)},
+		"end":   {fmt.Sprintf("%f,%f", to.Lon, to.Lat)},
+	})
+
+	response, err := decodeJsonFromRequest[openRouteServiceDirectionsResponseJson](p.client, request)
+	if err != nil {
+		return travelTime{}, err
+	}
+
+	if len(response.Features) == 0 {
+		return travelTime{}, errors.New("no route found")
+	}
+
+	summary := response.Features[0].Properties.Summary
+
+	return travelTime{
+		Duration: time.Duration(summary.Duration * float64(time.Second)),
+		Distance: summary.Distance,
+	}, nil
+}
+
+func (p *openRouteServiceProvider) geocode(ctx context.Context, address string) (travelTimeLocation, error) {
+	request := p.newRequest(ctx, "/geocode/search", url.Values{"text": {address}, "size": {"1"}})
+
+	response, err := decodeJsonFromRequest[openRouteServiceGeocodeResponseJson](p.client, request)
+	if err != nil {
+		return travelTimeLocation{}, err
+	}
+
+	if len(response.Features) == 0 || len(response.Features[0].Geometry.Coordinates) < 2 {
+		return travelTimeLocation{}, errors.New("no results")
+	}
+
+	coordinates := response.Features[0].Geometry.Coordinates
+
+	return travelTimeLocation{Lat: coordinates[1], Lon: coordinates[0]}, nil
+}
+
+type hereProvider struct {
+	apiKey    string
+	client    requestDoer
+	locations *travelTimeLocations
+}
+
+type hereRoutesResponseJson struct {
+	Routes []struct {
+		Sections []struct {
+			Summary struct {
+				Duration     int     `json:"duration"`
+				BaseDuration int     `json:"baseDuration"`
+				Length       float64 `json:"length"`
+			} `json:"summary"`
+		} `json:"sections"`
+	} `json:"routes"`
+	Notices []struct {
+		Title string `json:"title"`
+	} `json:"notices"`
+}
+
+type hereGeocodeResponseJson struct {
+	Items []struct {
+		Position struct {
+			Lat float64 `json:"lat"`
+			Lng float64 `json:"lng"`
+		} `json:"position"`
+	} `json:"items"`
+}
+
+// HERE only accepts the API key as part of the URL, which ends up in errors
+func (p *hereProvider) fetchTravelTime(ctx context.Context, origin, destination string) (travelTime, error) {
+	from, err := p.locations.resolve(ctx, origin, p.geocode)
+	if err != nil {
+		return travelTime{}, err
+	}
+
+	to, err := p.locations.resolve(ctx, destination, p.geocode)
+	if err != nil {
+		return travelTime{}, err
+	}
+
+	query := url.Values{
+		"transportMode": {"car"},
+		"origin":        {fmt.Sprintf("%f,%f", from.Lat, from.Lon)},
+		"destination":   {fmt.Sprintf("%f,%f", to.Lat, to.Lon)},
+		"return":        {"summary"},
+		"apiKey":        {p.apiKey},
+	}
+
+	request, _ := http.NewRequestWithContext(ctx, "GET", "https://router.hereapi.com/v8/routes?"+query.Encode(), nil)
+	response, err := decodeJsonFromRequest[hereRoutesResponseJson](p.client, request)
+	if err != nil {
+		return travelTime{}, &redactedError{err: err, secret: p.apiKey}
+	}
+
+	if len(response.Routes) == 0 {
+		if len(response.Notices) > 0 {
+			return travelTime{}, fmt.Errorf("no route found: %s", response.Notices[0].Title)
+		}
+
+		return travelTime{}, errors.New("no route found")
+	}
+
+	var result travelTime
+	for _, section := range response.Routes[0].Sections {
+		result.Duration += time.Duration(section.Summary.Duration) * time.Second
+		result.Typical += time.Duration(section.Summary.BaseDuration) * time.Second
+		result.Distance += section.Summary.Length
+	}
+
+	return result, nil
+}
+
+func (p *hereProvider) geocode(ctx context.Context, address string) (travelTimeLocation, error) {
+	query := url.Values{"q": {address}, "limit": {"1"}, "apiKey": {p.apiKey}}
+
+	request, _ := http.NewRequestWithContext(ctx, "GET", "https://geocode.search.hereapi.com/v1/geocode?"+query.Encode(), nil)
+	response, err := decodeJsonFromRequest[hereGeocodeResponseJson](p.client, request)
+	if err != nil {
+		return travelTimeLocation{}, &redactedError{err: err, secret: p.apiKey}
+	}
+
+	if len(response.Items) == 0 {
+		return travelTimeLocation{}, errors.New("no results")
+	}
+
+	return travelTimeLocation{Lat: response.Items[0].Position.Lat, Lon: response.Items[0].Position.Lng}, nil
+}
+
+// Uses the Routes API, which accepts addresses as they are so nothing has to
+// be looked up beforehand
+type googleRoutesProvider struct {
+	apiKey string
+	client requestDoer
+}
+
+type googleRoutesResponseJson struct {
+	Routes []struct {
+		DistanceMeters float64 `json:"distanceMeters"`
+		Duration       string  `json:"duration"`
+		StaticDuration string  `json:"staticDuration"`
+	} `json:"routes"`
+}
+
+func googleRoutesWaypoint(value string) map[string]any {
+	if location, ok := parseTravelTimeLocation(value); ok {
+		return map[string]any{
+			"location": map[string]any{
+				"latLng": map[string]float64{"latitude": location.Lat, "longitude": location.Lon},
+			},
+		}
+	}
+
+	return map[string]any{"address": value}
+}
+
+func (p *googleRoutesProvider) fetchTravelTime(ctx context.Context, origin, destination string) (travelTime, error) {
+	body, err := json.Marshal(map[string]any{
+		"origin":            googleRoutesWaypoint(origin),
+		"destination":       googleRoutesWaypoint(destination),
+		"travelMode":        "DRIVE",
+		"routingPreference": "TRAFFIC_AWARE",
+	})
+	if err != nil {
+		return travelTime{}, err
+	}
+
+	request, _ := http.NewRequestWithContext(ctx, "POST", "https://routes.googleapis.com/directions/v2:computeRoutes", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Goog-Api-Key", p.apiKey)
+	request.Header.Set("X-Goog-FieldMask", "routes.duration,routes.staticDuration,routes.distanceMeters")
+
+	response, err := decodeJsonFromRequest[googleRoutesResponseJson](p.client, request)
+	if err != nil {
+		return travelTime{}, err
+	}
+
+	if len(response.Routes) == 0 {
+		return travelTime{}, errors.New("no route found")
+	}
+
+	route := response.Routes[0]
+
+	// durations are in the format of 1234s
+	duration, err := time.ParseDuration(route.Duration)
+	if err != nil {
+		return travelTime{}, fmt.Errorf("invalid duration %s", route.Duration)
+	}
+
+	typical, _ := time.ParseDuration(route.StaticDuration)
+
+	return travelTime{Duration: duration, Typical: typical, Distance: route.DistanceMeters}, nil
+}
@@ -3,11 +3,15 @@ package glance
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,10 +20,13 @@ var twitchChannelsWidgetTemplate = mustParseTemplate("twitch-channels.html", "wi
 
 type twitchChannelsWidget struct {
 	widgetBase      `yaml:",inline"`
-	ChannelsRequest []string        `yaml:"channels"`
-	Channels        []twitchChannel `yaml:"-"`
-	CollapseAfter   int             `yaml:"collapse-after"`
-	SortBy          string          `yaml:"sort-by"`
+	ChannelsRequest []string                `yaml:"channels"`
+	Channels        []twitchChannel         `yaml:"-"`
+	CollapseAfter   int                     `yaml:"collapse-after"`
+	SortBy          string                  `yaml:"sort-by"`
+	ClientID        string                  `yaml:"client-id"`
+	ClientSecret    string                  `yaml:"client-secret"`
+	helixToken      *clientCredentialsToken `yaml:"-"`
 }
 
 func (widget *twitchChannelsWidget) initialize() error {
@@ -36,11 +43,26 @@ func (widget *twitchChannelsWidget) initialize() error {
 		widget.SortBy = "viewers"
 	}
 
+	if (widget.ClientID == "") != (widget.ClientSecret == "") {
+		return errors.New("both client-id and client-secret must be set in order to use the Twitch API")
+	}
+
+	if widget.ClientID != "" {
+		widget.helixToken = newClientCredentialsToken(widget.cachedHTTPClient(false), "https://id.twitch.tv/oauth2/token", widget.ClientID, widget.ClientSecret)
+	}
+
 	return nil
 }
 
 func (widget *twitchChannelsWidget) update(ctx context.Context) {
-	channels, err := fetchChannelsFromTwitch(widget.ChannelsRequest)
+	var channels twitchChannelList
+	var err error
+
+	if widget.helixToken != nil {
+		channels, err = fetchChannelsFromTwitchHelix(widget.helixToken, widget.ChannelsRequest)
+	} else {
+		channels, err = fetchChannelsFromTwitch(widget.cachedHTTPClient(false), widget.ChannelsRequest)
+	}
 
 	if !widget.canContinueUpdateAfterHandlingErr(err) {
 		return
@@ -60,11 +82,13 @@ func (widget *twitchChannelsWidget) Render() template.HTML {
 }
 
 type twitchChannel struct {
-	Login        string
-	Exists       bool
-	Name         string
-	StreamTitle  string
-	AvatarUrl    string
+	Login       string
+	Exists      bool
+	Name        string
+	StreamTitle string
+	AvatarUrl   string
+	// only set when using Helix, the preview is otherwise built from the login
+	ThumbnailUrl string
 	IsLive       bool
 	LiveSince    time.Time
 	Category     string
@@ -130,7 +154,7 @@ const twitchChannelStatusOperationRequestBody = `[
 // what the limit is for max operations per request and batch operations in
 // multiple requests if number of channels exceeds allowed limit.
 
-func fetchChannelFromTwitchTask(channel string) (twitchChannel, error) {
+func fetchChannelFromTwitch(client requestDoer, channel string) (twitchChannel, error) {
 	result := twitchChannel{
 		Login: strings.ToLower(channel),
 	}
@@ -139,7 +163,7 @@ func fetchChannelFromTwitchTask(channel string) (twitchChannel, error) {
 	request, _ := http.NewRequest("POST", twitchGqlEndpoint, reader)
 	request.Header.Add("Client-ID", twitchGqlClientId)
 
-	response, err := decodeJsonFromRequest[[]twitchOperationResponse](defaultHTTPClient, request)
+	response, err := decodeJsonFromRequest[[]twitchOperationResponse](client, request)
 	if err != nil {
 		return result, err
 	}
@@ -201,10 +225,12 @@ func fetchChannelFromTwitchTask(channel string) (twitchChannel, error) {
 	return result, nil
 }
 
-func fetchChannelsFromTwitch(channelLogins []string) (twitchChannelList, error) {
+func fetchChannelsFromTwitch(client requestDoer, channelLogins []string) (twitchChannelList, error) {
 	result := make(twitchChannelList, 0, len(channelLogins))
 
-	job := newJob(fetchChannelFromTwitchTask, channelLogins).withWorkers(10)
+	job := newJob(func(channel string) (twitchChannel, error) {
+		return fetchChannelFromTwitch(client, channel)
+	}, channelLogins).withWorkers(10)
 	channels, errs, err := workerPoolDo(job)
 	if err != nil {
 		return result, err
@@ -232,3 +258,126 @@ func fetchChannelsFromTwitch(channelLogins []string) (twitchChannelList, error)
 
 	return result, nil
 }
+
+const twitchHelixEndpoint = "https://api.twitch.tv/helix"
+
+// the maximum number of logins that can be passed to a single Helix request
+const twitchHelixMaxLoginsPerRequest = 100
+
+type twitchHelixUsersResponseJson struct {
+	Data []struct {
+		Login           string `json:"login"`
+		DisplayName     string `json:"display_name"`
+		ProfileImageURL string `json:"profile_image_url"`
+	} `json:"data"`
+}
+
+type twitchHelixStreamsResponseJson struct {
+	Data []struct {
+		UserLogin    string `json:"user_login"`
+		GameName     string `json:"game_name"`
+		Title        string `json:"title"`
+		ViewerCount  int    `json:"viewer_count"`
+		StartedAt    string `json:"started_at"`
+		ThumbnailURL string `json:"thumbnail_url"`
+	} `json:"data"`
+}
+
+var twitchCategorySlugInvalidCharsPattern = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Helix doesn't return the slug used in category URLs, this covers the vast majority of them
+func twitchCategoryNameToSlug(name string) string {
+	slug := titleToSlug(name)
+	return twitchCategorySlugInvalidCharsPattern.ReplaceAllString(slug, "")
+}
+
+func twitchHelixRequest[T any](token *clientCredentialsToken, path string, query url.Values) (T, error) {
+	var result T
+
+	for attempt := 0; attempt < 2; attempt++ {
+		accessToken, err := token.get()
+		if err != nil {
+			return result, err
+		}
+
+		request, _ := http.NewRequest("GET", twitchHelixEndpoint+path+"?"+query.Encode(), nil)
+		request.Header.Set("Client-ID", token.clientID)
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+
+		result, err = decodeJsonFromRequest[T](token.client, request)
+		if err == nil {
+			return result, nil
+		}
+
+		// app access tokens can get revoked before they expire, retry once with a new one
+		if !strings.Contains(err.Error(), "status code 401") {
+			return result, err
+		}
+
+		token.invalidate()
+	}
+
+	return result, errors.New("access token was rejected by the Twitch API")
+}
+
+func fetchChannelsFromTwitchHelix(token *clientCredentialsToken, channelLogins []string) (twitchChannelList, error) {
+	channelByLogin := make(map[string]*twitchChannel, len(channelLogins))
+	result := make(twitchChannelList, len(channelLogins))
+
+	for i := range channelLogins {
+		result[i] = twitchChannel{
+			Login: strings.ToLower(channelLogins[i]),
+		}
+		result[i].Name = result[i].Login
+		channelByLogin[result[i].Login] = &result[i]
+	}
+
+	for start := 0; start < len(result); start += twitchHelixMaxLoginsPerRequest {
+		end := min(start+twitchHelixMaxLoginsPerRequest, len(result))
+		usersQuery := url.Values{}
+		streamsQuery := url.Values{"first": {strconv.Itoa(twitchHelixMaxLoginsPerRequest)}}
+
+		for i := start; i < end; i++ {
+			usersQuery.Add("login", result[i].Login)
+			streamsQuery.Add("user_login", result[i].Login)
+		}
+
+		users, err := twitchHelixRequest[twitchHelixUsersResponseJson](token, "/users", usersQuery)
+		if err != nil {
+			return nil, fmt.Errorf("%w: fetching users: %v", errNoContent, err)
+		}
+
+		for _, user := range users.Data {
+			if channel, exists := channelByLogin[user.Login]; exists {
+				channel.Exists = true
+				channel.Name = user.DisplayName
+				channel.AvatarUrl = user.ProfileImageURL
+			}
+		}
+
+		streams, err := twitchHelixRequest[twitchHelixStreamsResponseJson](token, "/streams", streamsQuery)
+		if err != nil {
+			return nil, fmt.Errorf("%w: fetching streams: %v", errNoContent, err)
+		}
+
+		for _, stream := range streams.Data {
+			channel, exists := channelByLogin[stream.UserLogin]
+			if !exists {
+				continue
+			}
+
+			channel.IsLive = true
+			channel.StreamTitle = stream.Title
+			channel.ViewersCount = stream.ViewerCount
+			channel.LiveSince = parseRFC3339Time(stream.StartedAt)
+			channel.ThumbnailUrl = strings.NewReplacer("{width}", "440", "{height}", "248").Replace(stream.ThumbnailURL)
+
+			if stream.GameName != "" {
+				channel.Category = stream.GameName
+				channel.CategorySlug = twitchCategoryNameToSlug(stream.GameName)
+			}
+		}
+	}
+
+	return result, nil
+}
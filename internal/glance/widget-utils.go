@@ -3,6 +3,7 @@ package glance
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
@@ -10,6 +11,8 @@ import (
 	"io"
 	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"os"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -24,20 +27,84 @@ var (
 const defaultClientTimeout = 5 * time.Second
 
 var defaultHTTPClient = &http.Client{
-	Timeout: defaultClientTimeout,
+	Timeout:   defaultClientTimeout,
+	Transport: newOutboundTransport(false),
 }
 
 var defaultInsecureHTTPClient = &http.Client{
 	Timeout: defaultClientTimeout,
-	Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	},
+	Transport: func() *outboundTransport {
+		transport := newOutboundTransport(false)
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		return transport
+	}(),
 }
 
 type requestDoer interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
+// Creates a client with the given options, allowInsecure skips verifying
+// certificates regardless of the options
+func newHTTPClient(options *httpClientOptionsField, allowInsecure bool) (*http.Client, error) {
+	transport := newOutboundTransport(false)
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: allowInsecure || options.AllowInsecure}
+
+	if options.Proxy != "" {
+		proxyURL, err := url.Parse(options.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy URL: %v", err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if options.CAFile != "" {
+		contents, err := os.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %v", err)
+		}
+
+		// the certificates are trusted in addition to the ones from the system
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(contents) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", options.CAFile)
+		}
+
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	client := &http.Client{
+		Timeout:   ternary(options.Timeout > 0, time.Duration(options.Timeout), defaultClientTimeout),
+		Transport: transport,
+	}
+
+	if len(options.Headers) > 0 {
+		client.Transport = &headersTransport{headers: options.Headers, base: transport}
+	}
+
+	return client, nil
+}
+
+// Adds headers to every request, overriding any that the widget sets itself
+type headersTransport struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
+
+func (t *headersTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	for key, value := range t.headers {
+		request.Header.Set(key, value)
+	}
+
+	return t.base.RoundTrip(request)
+}
+
 var userAgentPersistentVersion atomic.Int32
 
 func setBrowserUserAgentHeader(request *http.Request) {
@@ -0,0 +1,330 @@
+package glance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var vaultwardenWidgetTemplate = mustParseTemplate("vaultwarden.html", "widget-base.html")
+
+type vaultwardenWidget struct {
+	widgetBase    `yaml:",inline"`
+	URL           string            `yaml:"url"`
+	AdminToken    string            `yaml:"admin-token"`
+	ReportFile    string            `yaml:"report-file"`
+	AllowInsecure bool              `yaml:"allow-insecure"`
+	Status        vaultwardenStatus `yaml:"-"`
+
+	// the session cookie of the admin panel, reused between updates since
+	// logging in is rate limited
+	adminSession   string
+	adminSessionMu sync.Mutex
+}
+
+func (widget *vaultwardenWidget) initialize() error {
+	widget.withTitle("Vaultwarden").withCacheDuration(15 * time.Minute)
+
+	if widget.URL == "" && widget.ReportFile == "" {
+		return errors.New("at least one of url or report-file must be specified")
+	}
+
+	if widget.URL == "" && widget.AdminToken != "" {
+		return errors.New("admin-token requires url to be set")
+	}
+
+	if widget.URL != "" {
+		widget.URL = strings.TrimRight(widget.URL, "/")
+		widget.withTitleURL(widget.URL)
+	}
+
+	return nil
+}
+
+func (widget *vaultwardenWidget) update(ctx context.Context) {
+	status := vaultwardenStatus{}
+	client := widget.httpClient(widget.AllowInsecure)
+	var failed []string
+
+	if widget.URL != "" {
+		status.ServerIsAvailable = true
+		status.Online, status.Version = fetchVaultwardenServerStatus(client, widget.URL)
+
+		if status.Online && widget.AdminToken != "" {
+			users, err := widget.fetchUsers(client)
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("users: %v", err))
+			} else {
+				status.Users = users
+				status.UsersIsAvailable = true
+			}
+		}
+	}
+
+	if widget.ReportFile != "" {
+		report, err := readVaultwardenReport(widget.ReportFile)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("report: %v", err))
+		} else {
+			status.Report = report
+			status.ReportIsAvailable = true
+		}
+	}
+
+	var err error
+
+	if len(failed) > 0 {
+		if status.ServerIsAvailable || status.ReportIsAvailable {
+			err = fmt.Errorf("%w: %s", errPartialContent, strings.Join(failed, "; "))
+		} else {
+			err = fmt.Errorf("%w: %s", errNoContent, strings.Join(failed, "; "))
+		}
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.Status = status
+}
+
+func (widget *vaultwardenWidget) Render() template.HTML {
+	return widget.renderTemplate(widget, vaultwardenWidgetTemplate)
+}
+
+type vaultwardenStatus struct {
+	ServerIsAvailable bool
+	Online            bool
+	Version           string
+	Users             vaultwardenUserCounts
+	UsersIsAvailable  bool
+	Report            vaultwardenReport
+	ReportIsAvailable bool
+}
+
+type vaultwardenUserCounts struct {
+	Total      int
+	Invited    int
+	Disabled   int
+	Without2FA int
+}
+
+// Only the counts from the report get read, it's up to whatever generates it
+// to leave out anything else
+type vaultwardenReport struct {
+	Weak        int       `json:"weak"`
+	Reused      int       `json:"reused"`
+	Exposed     int       `json:"exposed"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+func (r vaultwardenReport) Total() int {
+	return r.Weak + r.Reused + r.Exposed
+}
+
+// Being offline isn't treated as an error since that's what the widget is
+// there to show, same as with the monitor widget
+func fetchVaultwardenServerStatus(client requestDoer, instanceURL string) (bool, string) {
+	request, _ := http.NewRequest("GET", instanceURL+"/alive", nil)
+	response, err := client.Do(request)
+	if err != nil {
+		return false, ""
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return false, ""
+	}
+
+	request, _ = http.NewRequest("GET", instanceURL+"/api/version", nil)
+	version, err := decodeJsonFromRequest[string](client, request)
+	if err != nil {
+		return true, ""
+	}
+
+	return true, version
+}
+
+type vaultwardenUserResponseJson struct {
+	Status           int  `json:"_status"`
+	Enabled          bool `json:"userEnabled"`
+	TwoFactorEnabled bool `json:"twoFactorEnabled"`
+}
+
+const vaultwardenUserStatusInvited = 1
+
+func (widget *vaultwardenWidget) fetchUsers(client requestDoer) (vaultwardenUserCounts, error) {
+	widget.adminSessionMu.Lock()
+	defer widget.adminSessionMu.Unlock()
+
+	counts := vaultwardenUserCounts{}
+	loggedIn := false
+
+	login := func() error {
+		session, err := loginToVaultwardenAdmin(client, widget.URL, widget.AdminToken)
+		widget.adminSession = session
+		loggedIn = true
+		return err
+	}
+
+	if widget.adminSession == "" {
+		if err := login(); err != nil {
+			return counts, err
+		}
+	}
+
+	users, err := fetchVaultwardenUsers(client, widget.URL, widget.adminSession)
+	// sessions expire after 20 minutes by default
+	if errors.Is(err, errVaultwardenUnauthorized) && !loggedIn {
+		if err := login(); err != nil {
+			return counts, err
+		}
+
+		users, err = fetchVaultwardenUsers(client, widget.URL, widget.adminSession)
+	}
+
+	if err != nil {
+		if errors.Is(err, errVaultwardenUnauthorized) {
+			widget.adminSession = ""
+		}
+
+		return counts, err
+	}
+
+	for i := range users {
+		counts.Total++
+
+		if users[i].Status == vaultwardenUserStatusInvited {
+			counts.Invited++
+			continue
+		}
+
+		if !users[i].Enabled {
+			counts.Disabled++
+		} else if !users[i].TwoFactorEnabled {
+			counts.Without2FA++
+		}
+	}
+
+	return counts, nil
+}
+
+var errVaultwardenUnauthorized = errors.New("not logged in to the admin panel")
+
+// The admin panel responds with a redirect that sets the session cookie, which
+// the client follows, so the cookie is on the response that caused the redirect
+func loginToVaultwardenAdmin(client requestDoer, instanceURL, token string) (string, error) {
+	body := url.Values{"token": {token}}.Encode()
+	request, _ := http.NewRequest("POST", instanceURL+"/admin", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("logging in to the admin panel: %v", err)
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	cookies := response.Cookies()
+	if response.Request != nil && response.Request.Response != nil {
+		cookies = append(cookies, response.Request.Response.Cookies()...)
+	}
+
+	for _, cookie := range cookies {
+		if cookie.Name == "VW_ADMIN" && cookie.Value != "" {
+			return cookie.Value, nil
+		}
+	}
+
+	if response.StatusCode == http.StatusTooManyRequests {
+		return "", errors.New("logging in to the admin panel: too many login attempts")
+	}
+
+	return "", fmt.Errorf("logging in to the admin panel failed with status code %d, check that the admin token is correct", response.StatusCode)
+}
+
+func fetchVaultwardenUsers(client requestDoer, instanceURL, session string) ([]vaultwardenUserResponseJson, error) {
+	request, _ := http.NewRequest("GET", instanceURL+"/admin/users", nil)
+	request.Header.Set("Accept", "application/json")
+	request.AddCookie(&http.Cookie{Name: "VW_ADMIN", Value: session})
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusUnauthorized {
+		return nil, errVaultwardenUnauthorized
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d for %s", response.StatusCode, request.URL)
+	}
+
+	// an expired session can also get the login page instead
+	if !strings.Contains(response.Header.Get("Content-Type"), "json") {
+		return nil, errVaultwardenUnauthorized
+	}
+
+	var users []vaultwardenUserResponseJson
+	if err := json.NewDecoder(response.Body).Decode(&users); err != nil {
+		return nil, fmt.Errorf("decoding users: %v", err)
+	}
+
+	return users, nil
+}
+
+func readVaultwardenReport(path string) (vaultwardenReport, error) {
+	report := vaultwardenReport{}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return report, err
+	}
+
+	if err := json.Unmarshal(contents, &report); err != nil {
+		return report, fmt.Errorf("parsing %s: %v", path, err)
+	}
+
+	if report.GeneratedAt.IsZero() {
+		if info, err := os.Stat(path); err == nil {
+			report.GeneratedAt = info.ModTime()
+		}
+	}
+
+	return report, nil
+}
+
+func (widget *vaultwardenWidget) data() any {
+	data := map[string]any{}
+
+	if widget.Status.ServerIsAvailable {
+		data["online"] = widget.Status.Online
+		data["version"] = widget.Status.Version
+	}
+
+	if widget.Status.UsersIsAvailable {
+		data["users"] = map[string]int{
+			"total":       widget.Status.Users.Total,
+			"invited":     widget.Status.Users.Invited,
+			"disabled":    widget.Status.Users.Disabled,
+			"without_2fa": widget.Status.Users.Without2FA,
+		}
+	}
+
+	if widget.Status.ReportIsAvailable {
+		data["report"] = widget.Status.Report
+	}
+
+	return data
+}
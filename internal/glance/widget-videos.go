@@ -2,12 +2,15 @@ package glance
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -31,6 +34,7 @@ type videosWidget struct {
 	Playlists         []string  `yaml:"playlists"`
 	Limit             int       `yaml:"limit"`
 	IncludeShorts     bool      `yaml:"include-shorts"`
+	APIKey            string    `yaml:"api-key"`
 }
 
 func (widget *videosWidget) initialize() error {
@@ -64,7 +68,16 @@ func (widget *videosWidget) initialize() error {
 }
 
 func (widget *videosWidget) update(ctx context.Context) {
-	videos, err := fetchYoutubeChannelUploads(widget.Channels, widget.VideoUrlTemplate, widget.IncludeShorts)
+	// when an API key is provided Shorts get filtered out based on their duration instead,
+	// which also works for playlists and doesn't exclude live streams like the uploads playlist does
+	videos, err := fetchYoutubeChannelUploads(widget.cachedHTTPClient(false), widget.Channels, widget.VideoUrlTemplate, widget.IncludeShorts || widget.APIKey != "")
+
+	// the videos of the channels that could be fetched still need filtering
+	if widget.APIKey != "" && len(videos) > 0 && (err == nil || errors.Is(err, errPartialContent)) {
+		var detailsErr error
+		videos, detailsErr = fetchYoutubeVideoDetails(widget.cachedHTTPClient(false), widget.APIKey, videos, widget.IncludeShorts)
+		err = errors.Join(err, detailsErr)
+	}
 
 	if !widget.canContinueUpdateAfterHandlingErr(err) {
 		return
@@ -96,6 +109,7 @@ type youtubeFeedResponseXml struct {
 	Channel     string `xml:"author>name"`
 	ChannelLink string `xml:"author>uri"`
 	Videos      []struct {
+		ID        string `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
 		Title     string `xml:"title"`
 		Published string `xml:"published"`
 		Link      struct {
@@ -120,12 +134,17 @@ func parseYoutubeFeedTime(t string) time.Time {
 }
 
 type video struct {
+	ID           string
 	ThumbnailUrl string
 	Title        string
 	Url          string
 	Author       string
 	AuthorUrl    string
 	TimePosted   time.Time
+	Duration     time.Duration
+	IsLive       bool
+	IsUpcoming   bool
+	StartsAt     time.Time
 }
 
 type videoList []video
@@ -138,7 +157,37 @@ func (v videoList) sortByNewest() videoList {
 	return v
 }
 
-func fetchYoutubeChannelUploads(channelOrPlaylistIDs []string, videoUrlTemplate string, includeShorts bool) (videoList, error) {
+// Live streams come first, followed by upcoming streams in the order they're scheduled to start
+func (v videoList) sortByLiveThenNewest() videoList {
+	rank := func(v *video) int {
+		if v.IsLive {
+			return 0
+		}
+
+		if v.IsUpcoming {
+			return 1
+		}
+
+		return 2
+	}
+
+	sort.SliceStable(v, func(i, j int) bool {
+		ri, rj := rank(&v[i]), rank(&v[j])
+		if ri != rj {
+			return ri < rj
+		}
+
+		if ri == 1 {
+			return v[i].StartsAt.Before(v[j].StartsAt)
+		}
+
+		return v[i].TimePosted.After(v[j].TimePosted)
+	})
+
+	return v
+}
+
+func fetchYoutubeChannelUploads(client requestDoer, channelOrPlaylistIDs []string, videoUrlTemplate string, includeShorts bool) (videoList, error) {
 	requests := make([]*http.Request, 0, len(channelOrPlaylistIDs))
 
 	for i := range channelOrPlaylistIDs {
@@ -157,7 +206,7 @@ func fetchYoutubeChannelUploads(channelOrPlaylistIDs []string, videoUrlTemplate
 		requests = append(requests, request)
 	}
 
-	job := newJob(decodeXmlFromRequestTask[youtubeFeedResponseXml](defaultHTTPClient), requests).withWorkers(30)
+	job := newJob(decodeXmlFromRequestTask[youtubeFeedResponseXml](client), requests).withWorkers(30)
 	responses, errs, err := workerPoolDo(job)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", errNoContent, err)
@@ -192,6 +241,7 @@ func fetchYoutubeChannelUploads(channelOrPlaylistIDs []string, videoUrlTemplate
 			}
 
 			videos = append(videos, video{
+				ID:           v.ID,
 				ThumbnailUrl: v.Group.Thumbnail.Url,
 				Title:        v.Title,
 				Url:          videoUrl,
@@ -214,3 +264,162 @@ func fetchYoutubeChannelUploads(channelOrPlaylistIDs []string, videoUrlTemplate
 
 	return videos, nil
 }
+
+// YouTube allows Shorts to be up to 3 minutes long
+const youtubeShortsMaxDuration = 3 * time.Minute
+
+type youtubeVideosResponseJson struct {
+	Items []struct {
+		ID      string `json:"id"`
+		Snippet struct {
+			LiveBroadcastContent string `json:"liveBroadcastContent"`
+		} `json:"snippet"`
+		ContentDetails struct {
+			Duration string `json:"duration"`
+		} `json:"contentDetails"`
+		LiveStreamingDetails struct {
+			ScheduledStartTime string `json:"scheduledStartTime"`
+			ActualStartTime    string `json:"actualStartTime"`
+		} `json:"liveStreamingDetails"`
+	} `json:"items"`
+}
+
+var iso8601DurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+func parseISO8601Duration(value string) time.Duration {
+	matches := iso8601DurationPattern.FindStringSubmatch(value)
+	if matches == nil {
+		return 0
+	}
+
+	units := [...]time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var duration time.Duration
+
+	for i, unit := range units {
+		if matches[i+1] == "" {
+			continue
+		}
+
+		n, _ := strconv.Atoi(matches[i+1])
+		duration += time.Duration(n) * unit
+	}
+
+	return duration
+}
+
+func fetchYoutubeVideoDetails(client requestDoer, apiKey string, videos videoList, includeShorts bool) (videoList, error) {
+	const batchSize = 50
+
+	requests := make([]*http.Request, 0, len(videos)/batchSize+1)
+
+	for i := 0; i < len(videos); i += batchSize {
+		ids := make([]string, 0, batchSize)
+		for j := i; j < min(i+batchSize, len(videos)); j++ {
+			ids = append(ids, videos[j].ID)
+		}
+
+		request, _ := http.NewRequest("GET", "https://www.googleapis.com/youtube/v3/videos?"+url.Values{
+			"part": {"snippet,contentDetails,liveStreamingDetails"},
+			"id":   {strings.Join(ids, ",")},
+			"key":  {apiKey},
+		}.Encode(), nil)
+		requests = append(requests, request)
+	}
+
+	job := newJob(decodeJsonFromRequestTask[youtubeVideosResponseJson](client), requests).withWorkers(10)
+	responses, errs, err := workerPoolDo(job)
+	if err != nil {
+		return videos, fmt.Errorf("%w: %v", errPartialContent, err)
+	}
+
+	videoByID := make(map[string]*video, len(videos))
+	for i := range videos {
+		videoByID[videos[i].ID] = &videos[i]
+	}
+
+	var failed int
+
+	for i := range responses {
+		if errs[i] != nil {
+			failed++
+			slog.Error("Failed to fetch youtube video details", "error", errs[i])
+			continue
+		}
+
+		for _, item := range responses[i].Items {
+			v, exists := videoByID[item.ID]
+			if !exists {
+				continue
+			}
+
+			v.Duration = parseISO8601Duration(item.ContentDetails.Duration)
+			v.IsLive = item.Snippet.LiveBroadcastContent == "live"
+			v.IsUpcoming = item.Snippet.LiveBroadcastContent == "upcoming"
+
+			if v.IsLive {
+				v.StartsAt = parseRFC3339Time(item.LiveStreamingDetails.ActualStartTime)
+			} else if v.IsUpcoming {
+				v.StartsAt = parseRFC3339Time(item.LiveStreamingDetails.ScheduledStartTime)
+			}
+		}
+	}
+
+	filtered := make(videoList, 0, len(videos))
+
+	for i := range videos {
+		v := &videos[i]
+
+		// live streams and premieres have a duration of 0 until they end
+		if !includeShorts && !v.IsLive && !v.IsUpcoming && v.Duration > 0 && v.Duration <= youtubeShortsMaxDuration {
+			continue
+		}
+
+		filtered = append(filtered, *v)
+	}
+
+	filtered.sortByLiveThenNewest()
+
+	if failed > 0 {
+		return filtered, fmt.Errorf("%w: could not fetch details for some videos", errPartialContent)
+	}
+
+	return filtered, nil
+}
+
+type videoJson struct {
+	Title           string     `json:"title"`
+	URL             string     `json:"url"`
+	Author          string     `json:"author"`
+	AuthorURL       string     `json:"author_url"`
+	ThumbnailURL    string     `json:"thumbnail_url"`
+	PublishedAt     time.Time  `json:"published_at"`
+	DurationSeconds int        `json:"duration_seconds,omitempty"`
+	Live            bool       `json:"live,omitempty"`
+	StartsAt        *time.Time `json:"starts_at,omitempty"`
+}
+
+func (widget *videosWidget) data() any {
+	videos := make([]videoJson, 0, len(widget.Videos))
+
+	for i := range widget.Videos {
+		video := &widget.Videos[i]
+		videoJson := videoJson{
+			Title:           video.Title,
+			URL:             video.Url,
+			Author:          video.Author,
+			AuthorURL:       video.AuthorUrl,
+			ThumbnailURL:    video.ThumbnailUrl,
+			PublishedAt:     video.TimePosted,
+			DurationSeconds: int(video.Duration.Seconds()),
+			Live:            video.IsLive,
+		}
+
+		if video.IsUpcoming {
+			videoJson.StartsAt = timeOrNil(video.StartsAt)
+		}
+
+		videos = append(videos, videoJson)
+	}
+
+	return videos
+}
@@ -0,0 +1,364 @@
+package glance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var wakeOnLANWidgetTemplate = mustParseTemplate("wake-on-lan.html", "widget-base.html")
+
+const (
+	wakeOnLANCheckTimeout = 3 * time.Second
+	wakeOnLANPollInterval = 5 * time.Second
+)
+
+type wakeOnLANWidget struct {
+	widgetBase  `yaml:",inline"`
+	Machines    []wakeOnLANMachine `yaml:"machines"`
+	WakeTimeout durationField      `yaml:"wake-timeout"`
+	Statuses    []wakeOnLANStatus  `yaml:"-"`
+
+	// written to by the checks that run after waking a machine, which don't
+	// hold the lock of the page
+	statesMu sync.Mutex
+	states   []wakeOnLANState
+}
+
+type wakeOnLANMachine struct {
+	Name         string          `yaml:"name"`
+	MAC          string          `yaml:"mac"`
+	Address      string          `yaml:"address"`
+	Port         int             `yaml:"port"`
+	Broadcast    string          `yaml:"broadcast"`
+	HealthCheck  string          `yaml:"health-check"`
+	Icon         customIconField `yaml:"icon"`
+	hardwareAddr net.HardwareAddr
+}
+
+type wakeOnLANState struct {
+	online     bool
+	waking     bool
+	wakeFailed bool
+}
+
+type wakeOnLANStatus struct {
+	Name       string
+	Icon       customIconField
+	Checked    bool
+	Online     bool
+	Waking     bool
+	WakeFailed bool
+}
+
+func (widget *wakeOnLANWidget) initialize() error {
+	widget.withTitle("Wake on LAN").withCacheDuration(time.Minute)
+
+	if len(widget.Machines) == 0 {
+		return errors.New("at least one machine is required")
+	}
+
+	if widget.WakeTimeout <= 0 {
+		widget.WakeTimeout = durationField(3 * time.Minute)
+	}
+
+	names := make(map[string]bool, len(widget.Machines))
+
+	for i := range widget.Machines {
+		machine := &widget.Machines[i]
+
+		if machine.Name == "" {
+			return fmt.Errorf("machine %d: name is required", i+1)
+		}
+
+		if names[machine.Name] {
+			return fmt.Errorf("machine %s is listed more than once", machine.Name)
+		}
+		names[machine.Name] = true
+
+		mac, err := net.ParseMAC(machine.MAC)
+		if err != nil || len(mac) != 6 {
+			return fmt.Errorf("machine %s: invalid mac address %q", machine.Name, machine.MAC)
+		}
+		machine.hardwareAddr = mac
+
+		if machine.Port == 0 {
+			machine.Port = 22
+		}
+
+		if machine.Broadcast == "" {
+			machine.Broadcast = "255.255.255.255"
+		}
+
+		if _, _, err := net.SplitHostPort(machine.Broadcast); err != nil {
+			machine.Broadcast = net.JoinHostPort(machine.Broadcast, "9")
+		}
+	}
+
+	widget.states = make([]wakeOnLANState, len(widget.Machines))
+
+	return nil
+}
+
+func (widget *wakeOnLANWidget) update(ctx context.Context) {
+	job := newJob(func(machine *wakeOnLANMachine) (bool, error) {
+		return widget.machineIsUp(ctx, machine), nil
+	}, widget.machinePointers()).withWorkers(10)
+
+	online, _, err := workerPoolDo(job)
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.statesMu.Lock()
+	for i := range widget.states {
+		// the check that runs after waking the machine decides how that ends
+		if !widget.states[i].waking {
+			widget.states[i].online = online[i]
+		}
+	}
+	widget.statesMu.Unlock()
+}
+
+func (widget *wakeOnLANWidget) machinePointers() []*wakeOnLANMachine {
+	machines := make([]*wakeOnLANMachine, len(widget.Machines))
+	for i := range widget.Machines {
+		machines[i] = &widget.Machines[i]
+	}
+
+	return machines
+}
+
+func (widget *wakeOnLANWidget) Render() template.HTML {
+	widget.Statuses = widget.statuses()
+	return widget.renderTemplate(widget, wakeOnLANWidgetTemplate)
+}
+
+func (widget *wakeOnLANWidget) statuses() []wakeOnLANStatus {
+	widget.statesMu.Lock()
+	defer widget.statesMu.Unlock()
+
+	statuses := make([]wakeOnLANStatus, len(widget.Machines))
+	for i := range widget.Machines {
+		statuses[i] = widget.status(i)
+	}
+
+	return statuses
+}
+
+// Requires holding statesMu
+func (widget *wakeOnLANWidget) status(i int) wakeOnLANStatus {
+	machine := &widget.Machines[i]
+	state := widget.states[i]
+
+	return wakeOnLANStatus{
+		Name:       machine.Name,
+		Icon:       machine.Icon,
+		Checked:    machine.Address != "" || machine.HealthCheck != "",
+		Online:     state.online,
+		Waking:     state.waking,
+		WakeFailed: state.wakeFailed,
+	}
+}
+
+// Machines without an address or health check are never considered up. A
+// refused connection still means that something answered at the address.
+func (widget *wakeOnLANWidget) machineIsUp(ctx context.Context, machine *wakeOnLANMachine) bool {
+	if machine.Address == "" && machine.HealthCheck == "" {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, wakeOnLANCheckTimeout)
+	defer cancel()
+
+	if machine.Address != "" {
+		conn, err := dialOutbound(ctx, "tcp", net.JoinHostPort(machine.Address, strconv.Itoa(machine.Port)))
+		if err == nil {
+			conn.Close()
+		} else if !errors.Is(err, syscall.ECONNREFUSED) {
+			return false
+		}
+	}
+
+	if machine.HealthCheck != "" {
+		request, err := http.NewRequestWithContext(ctx, "GET", machine.HealthCheck, nil)
+		if err != nil {
+			return false
+		}
+
+		response, err := widget.httpClient(false).Do(request)
+		if err != nil {
+			return false
+		}
+		response.Body.Close()
+
+		return response.StatusCode >= 200 && response.StatusCode < 300
+	}
+
+	return true
+}
+
+func sendMagicPacket(mac net.HardwareAddr, broadcast string) error {
+	packet := bytes.Repeat([]byte{0xff}, 6)
+	for range 16 {
+		packet = append(packet, mac...)
+	}
+
+	conn, err := net.Dial("udp4", broadcast)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(packet)
+	return err
+}
+
+func (widget *wakeOnLANWidget) handleRequest(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.PathValue("path") == "wake" && r.Method == http.MethodPost:
+		var body struct {
+			Machine string `json:"machine"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		widget.handleWakeRequest(w, r, body.Machine)
+	case r.PathValue("path") == "machine" && r.Method == http.MethodGet:
+		i := widget.machineIndex(r.URL.Query().Get("name"))
+		if i < 0 {
+			http.Error(w, "machine not found", http.StatusNotFound)
+			return
+		}
+
+		widget.writeMachine(w, i)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (widget *wakeOnLANWidget) machineIndex(name string) int {
+	for i := range widget.Machines {
+		if widget.Machines[i].Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (widget *wakeOnLANWidget) handleWakeRequest(w http.ResponseWriter, r *http.Request, name string) {
+	i := widget.machineIndex(name)
+	if i < 0 {
+		http.Error(w, "machine not found", http.StatusNotFound)
+		return
+	}
+
+	machine := &widget.Machines[i]
+	checked := machine.Address != "" || machine.HealthCheck != ""
+
+	widget.statesMu.Lock()
+	if widget.states[i].waking {
+		widget.statesMu.Unlock()
+		widget.writeMachine(w, i)
+		return
+	}
+	previous := widget.states[i]
+	widget.states[i] = wakeOnLANState{waking: checked}
+	widget.statesMu.Unlock()
+
+	if err := sendMagicPacket(machine.hardwareAddr, machine.Broadcast); err != nil {
+		widget.statesMu.Lock()
+		widget.states[i] = previous
+		widget.statesMu.Unlock()
+
+		widget.logger().Error("Failed to send magic packet", "machine", machine.Name, "error", err)
+		http.Error(w, "failed to send magic packet: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	widget.logger().Info("Sent magic packet", "machine", machine.Name)
+	widget.Providers.audit.record(r, "Woke machine", widget.Title+": "+machine.Name, "", "")
+
+	if checked {
+		go widget.waitForMachine(i)
+	}
+
+	widget.writeMachine(w, i)
+}
+
+// Checks the machine until it's up or the wake timeout passes
+func (widget *wakeOnLANWidget) waitForMachine(i int) {
+	machine := &widget.Machines[i]
+	deadline := time.Now().Add(time.Duration(widget.WakeTimeout))
+	up := false
+
+	for !up && time.Now().Before(deadline) {
+		time.Sleep(wakeOnLANPollInterval)
+		up = widget.machineIsUp(context.Background(), machine)
+	}
+
+	if up {
+		widget.logger().Info("Machine woke up", "machine", machine.Name)
+	} else {
+		widget.logger().Warn("Machine didn't wake up", "machine", machine.Name, "timeout", time.Duration(widget.WakeTimeout))
+	}
+
+	widget.statesMu.Lock()
+	widget.states[i] = wakeOnLANState{online: up, wakeFailed: !up}
+	widget.statesMu.Unlock()
+}
+
+func (widget *wakeOnLANWidget) writeMachine(w http.ResponseWriter, i int) {
+	widget.statesMu.Lock()
+	status := widget.status(i)
+	widget.statesMu.Unlock()
+
+	var html bytes.Buffer
+	if err := wakeOnLANWidgetTemplate.ExecuteTemplate(&html, "machine", status); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(html.Bytes())
+}
+
+type wakeOnLANMachineJson struct {
+	Name       string `json:"name"`
+	Online     *bool  `json:"online"`
+	Waking     bool   `json:"waking"`
+	WakeFailed bool   `json:"wake_failed"`
+}
+
+func (widget *wakeOnLANWidget) data() any {
+	statuses := widget.statuses()
+	machines := make([]wakeOnLANMachineJson, len(statuses))
+
+	for i, status := range statuses {
+		machines[i] = wakeOnLANMachineJson{
+			Name:       status.Name,
+			Waking:     status.Waking,
+			WakeFailed: status.WakeFailed,
+		}
+
+		if status.Checked {
+			machines[i].Online = &status.Online
+		}
+	}
+
+	return map[string]any{"machines": machines}
+}
@@ -0,0 +1,399 @@
+package glance
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var wasteCollectionWidgetTemplate = mustParseTemplate("waste-collection.html", "widget-base.html")
+
+// how long to wait before trying again when the collections couldn't be
+// fetched for the evening's notification
+const wasteCollectionNotifyRetryInterval = 15 * time.Minute
+
+type wasteCollectionWidget struct {
+	widgetBase `yaml:",inline"`
+	Provider   string     `yaml:"provider"`
+	URL        string     `yaml:"url"`
+	PlaceID    string     `yaml:"place-id"`
+	ServiceID  string     `yaml:"service-id"`
+	Bins       []wasteBin `yaml:"bins"`
+	Days       int        `yaml:"days"`
+	Notify     []string   `yaml:"notify"`
+	NotifyAt   string     `yaml:"notify-at"`
+	source     wasteCollectionSource
+	notifyAt   time.Duration
+	loadedMu   sync.Mutex
+	loaded     []wasteCollection
+	// guarded by stateMutex
+	lastNotifyAttempt time.Time
+	stateMutex        sync.Mutex
+}
+
+type wasteCollectionTemplateData struct {
+	*wasteCollectionWidget
+	Items []wasteCollectionItem
+}
+
+type wasteBin struct {
+	Name  string         `yaml:"name"`
+	Match []string       `yaml:"match"`
+	Color *hslColorField `yaml:"color"`
+}
+
+// Implemented by each of the supported providers, returns the collections
+// between the two dates, both inclusive
+type wasteCollectionSource interface {
+	fetchCollections(ctx context.Context, from, until time.Time) ([]wasteCollection, error)
+}
+
+type wasteCollection struct {
+	Date time.Time
+	Name string
+}
+
+type wasteCollectionItem struct {
+	Date time.Time
+	Days int
+	Bins []wasteCollectionBin
+}
+
+type wasteCollectionBin struct {
+	Name  string
+	Color *hslColorField
+}
+
+type wasteCollectionState struct {
+	// the date of the collection that the last notification was sent for
+	LastNotified string `json:"last_notified"`
+}
+
+func (widget *wasteCollectionWidget) initialize() error {
+	widget.withTitle("Waste Collection").withCacheDuration(6 * time.Hour)
+
+	switch widget.Provider {
+	case "", "ical":
+		if widget.URL == "" {
+			return errors.New("url is required")
+		}
+
+		widget.source = &icalWasteCollectionSource{source: widget.URL, client: widget.httpClient(false), logger: widget.logger}
+	case "recollect":
+		if widget.PlaceID == "" || widget.ServiceID == "" {
+			return errors.New("place-id and service-id are required")
+		}
+
+		widget.source = &recollectWasteCollectionSource{placeID: widget.PlaceID, serviceID: widget.ServiceID, client: widget.httpClient(false)}
+	default:
+		return fmt.Errorf("unsupported provider %s, must be either ical or recollect", widget.Provider)
+	}
+
+	for i := range widget.Bins {
+		if widget.Bins[i].Name == "" {
+			return fmt.Errorf("bin %d has no name", i+1)
+		}
+	}
+
+	if widget.Days <= 0 {
+		widget.Days = 14
+	}
+
+	if widget.NotifyAt == "" {
+		widget.NotifyAt = "18:00"
+	}
+
+	at, err := time.Parse("15:04", widget.NotifyAt)
+	if err != nil {
+		return fmt.Errorf("invalid notify-at %s, must be in the format of HH:MM", widget.NotifyAt)
+	}
+
+	widget.notifyAt = time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+
+	return nil
+}
+
+func (widget *wasteCollectionWidget) getNotificationTargets() []string {
+	return widget.Notify
+}
+
+func (widget *wasteCollectionWidget) update(ctx context.Context) {
+	collections, err := widget.fetchCollections(ctx, time.Now())
+	if err != nil {
+		err = fmt.Errorf("%w: %v", errNoContent, err)
+	}
+
+	if !widget.canContinueUpdateAfterHandlingErr(err) {
+		return
+	}
+
+	widget.loadedMu.Lock()
+	widget.loaded = collections
+	widget.loadedMu.Unlock()
+}
+
+// Collections from today onwards, for a day longer than shown so that the
+// evening before the last day shown can still be notified about
+func (widget *wasteCollectionWidget) fetchCollections(ctx context.Context, now time.Time) ([]wasteCollection, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	return widget.source.fetchCollections(ctx, today, today.AddDate(0, 0, widget.Days+1))
+}
+
+// Returns the bin the collection is of and whether it should be shown, when
+// no bins are configured every collection is shown as it's named
+func (widget *wasteCollectionWidget) binFor(name string) (wasteCollectionBin, bool) {
+	if len(widget.Bins) == 0 {
+		return wasteCollectionBin{Name: name}, true
+	}
+
+	lowered := strings.ToLower(name)
+
+	for i := range widget.Bins {
+		bin := &widget.Bins[i]
+		match := bin.Match
+		if len(match) == 0 {
+			match = []string{bin.Name}
+		}
+
+		for _, m := range match {
+			if strings.Contains(lowered, strings.ToLower(m)) {
+				return wasteCollectionBin{Name: bin.Name, Color: bin.Color}, true
+			}
+		}
+	}
+
+	return wasteCollectionBin{}, false
+}
+
+func (widget *wasteCollectionWidget) buildItems(collections []wasteCollection, now time.Time) []wasteCollectionItem {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	until := today.AddDate(0, 0, widget.Days)
+	items := make([]wasteCollectionItem, 0)
+
+	for _, collection := range collections {
+		if collection.Date.Before(today) || !collection.Date.Before(until) {
+			continue
+		}
+
+		bin, ok := widget.binFor(collection.Name)
+		if !ok {
+			continue
+		}
+
+		index := slices.IndexFunc(items, func(item wasteCollectionItem) bool {
+			return item.Date.Equal(collection.Date)
+		})
+
+		if index == -1 {
+			items = append(items, wasteCollectionItem{
+				Date: collection.Date,
+				// rounded since days around DST changes aren't exactly 24 hours long
+				Days: int(collection.Date.Sub(today).Hours()/24 + 0.5),
+			})
+			index = len(items) - 1
+		}
+
+		item := &items[index]
+		if !slices.ContainsFunc(item.Bins, func(b wasteCollectionBin) bool { return b.Name == bin.Name }) {
+			item.Bins = append(item.Bins, bin)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Date.Before(items[j].Date)
+	})
+
+	return items
+}
+
+// The bins that go out tonight for a collection tomorrow, otherwise those that
+// are being collected today
+func (data *wasteCollectionTemplateData) PutOut() *wasteCollectionItem {
+	for _, days := range []int{1, 0} {
+		for i := range data.Items {
+			if data.Items[i].Days == days {
+				return &data.Items[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+func (widget *wasteCollectionWidget) stateKey() string {
+	return "waste-collection:" + cmp.Or(widget.URL, widget.PlaceID+"/"+widget.ServiceID)
+}
+
+// Sends a notification on the evening before a collection about which bins
+// need to be put out
+func (widget *wasteCollectionWidget) runBackgroundTask(now time.Time) {
+	if len(widget.Notify) == 0 {
+		return
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if now.Before(today.Add(widget.notifyAt)) {
+		return
+	}
+
+	tomorrow := today.AddDate(0, 0, 1)
+	key := tomorrow.Format(time.DateOnly)
+
+	widget.stateMutex.Lock()
+	defer widget.stateMutex.Unlock()
+
+	var state wasteCollectionState
+	widget.Providers.state.get(widget.stateKey(), &state)
+	if state.LastNotified >= key || now.Sub(widget.lastNotifyAttempt) < wasteCollectionNotifyRetryInterval {
+		return
+	}
+
+	widget.lastNotifyAttempt = now
+
+	// fetched again rather than relying on the last update, which only happens
+	// when someone's looking at the page
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collections, err := widget.fetchCollections(ctx, now)
+	if err != nil {
+		widget.logger().Error("Failed to fetch waste collections", "error", err)
+		return
+	}
+
+	var bins []string
+	for _, item := range widget.buildItems(collections, now) {
+		if !item.Date.Equal(tomorrow) {
+			continue
+		}
+
+		for _, bin := range item.Bins {
+			bins = append(bins, bin.Name)
+		}
+	}
+
+	if len(bins) > 0 {
+		delivered := widget.Providers.notifier.notify(widget.Notify, notification{
+			Title:   "Put the bins out tonight",
+			Message: strings.Join(bins, ", ") + " will be collected tomorrow",
+		})
+
+		// failed deliveries get retried after the retry interval
+		if !delivered {
+			return
+		}
+	}
+
+	state.LastNotified = key
+	widget.Providers.state.set(widget.stateKey(), state)
+}
+
+func (widget *wasteCollectionWidget) Render() template.HTML {
+	widget.loadedMu.Lock()
+	items := widget.buildItems(widget.loaded, time.Now())
+	widget.loadedMu.Unlock()
+
+	// passed along rather than set on the widget so that rendering leaves it untouched
+	return widget.renderTemplate(&wasteCollectionTemplateData{
+		wasteCollectionWidget: widget,
+		Items:                 items,
+	}, wasteCollectionWidgetTemplate)
+}
+
+// Most councils and waste management companies publish their schedules as an
+// iCalendar feed, either as individual events or as recurring ones
+type icalWasteCollectionSource struct {
+	source string
+	client requestDoer
+	logger func() *slog.Logger
+}
+
+func (s *icalWasteCollectionSource) fetchCollections(ctx context.Context, from, until time.Time) ([]wasteCollection, error) {
+	events, err := fetchICalendarEvents(ctx, s.client, s.source)
+	if err != nil {
+		return nil, err
+	}
+
+	var collections []wasteCollection
+
+	for _, occurrence := range icalOccurrences(events, from, until, s.logger()) {
+		name := occurrence.event.text("SUMMARY")
+		if name == "" {
+			continue
+		}
+
+		// collections only happen on a day, some feeds still give them a time
+		date := time.Date(occurrence.start.Year(), occurrence.start.Month(), occurrence.start.Day(), 0, 0, 0, 0, time.Local)
+		collections = append(collections, wasteCollection{Date: date, Name: name})
+	}
+
+	return collections, nil
+}
+
+// ReCollect (https://recollect.net) runs the collection calendars of many
+// municipalities in North America, the place and service IDs can be found in
+// the requests made by the calendar on the municipality's website
+type recollectWasteCollectionSource struct {
+	placeID   string
+	serviceID string
+	client    requestDoer
+}
+
+type recollectEventsResponseJson struct {
+	Events []struct {
+		Day   string `json:"day"`
+		Flags []struct {
+			Name      string `json:"name"`
+			Subject   string `json:"subject"`
+			EventType string `json:"event_type"`
+		} `json:"flags"`
+	} `json:"events"`
+}
+
+func (s *recollectWasteCollectionSource) fetchCollections(ctx context.Context, from, until time.Time) ([]wasteCollection, error) {
+	query := url.Values{}
+	query.Set("nomerge", "1")
+	query.Set("hide", "reminder_only,event_private")
+	query.Set("after", from.Format(time.DateOnly))
+	query.Set("before", until.Format(time.DateOnly))
+
+	requestURL := fmt.Sprintf(
+		"https://api.recollect.net/api/places/%s/services/%s/events?%s",
+		url.PathEscape(s.placeID), url.PathEscape(s.serviceID), query.Encode(),
+	)
+
+	request, _ := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	response, err := decodeJsonFromRequest[recollectEventsResponseJson](s.client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make([]wasteCollection, 0, len(response.Events))
+
+	for _, event := range response.Events {
+		date, err := time.ParseInLocation(time.DateOnly, event.Day, time.Local)
+		if err != nil {
+			continue
+		}
+
+		for _, flag := range event.Flags {
+			if flag.EventType != "" && flag.EventType != "pickup" {
+				continue
+			}
+
+			collections = append(collections, wasteCollection{Date: date, Name: cmp.Or(flag.Subject, flag.Name)})
+		}
+	}
+
+	return collections, nil
+}
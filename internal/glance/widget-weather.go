@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	_ "time/tzdata"
@@ -27,6 +28,9 @@ type weatherWidget struct {
 	Place        *openMeteoPlaceResponseJson `yaml:"-"`
 	Weather      *weather                    `yaml:"-"`
 	TimeLabels   [12]string                  `yaml:"-"`
+	// guards Place and Weather being set, the day summary reads them without
+	// holding the lock of the widget's page
+	weatherMu sync.Mutex
 }
 
 var timeLabels12h = [12]string{"2am", "4am", "6am", "8am", "10am", "12pm", "2pm", "4pm", "6pm", "8pm", "10pm", "12am"}
@@ -47,33 +51,65 @@ func (widget *weatherWidget) initialize() error {
 		return errors.New("hour-format must be either 12h or 24h")
 	}
 
-	if widget.Units == "" {
-		widget.Units = "metric"
-	} else if widget.Units != "metric" && widget.Units != "imperial" {
+	if widget.Units != "" && widget.Units != "metric" && widget.Units != "imperial" {
 		return errors.New("units must be either metric or imperial")
 	}
 
 	return nil
 }
 
+func (widget *weatherWidget) TemperatureUnit() string {
+	return ternary(widget.units(widget.Units) == "metric", "C", "F")
+}
+
 func (widget *weatherWidget) update(ctx context.Context) {
 	if widget.Place == nil {
-		place, err := fetchOpenMeteoPlaceFromName(widget.Location)
+		place, err := fetchOpenMeteoPlaceFromName(widget.cachedHTTPClient(false), widget.Location)
 		if err != nil {
 			widget.withError(err).scheduleEarlyUpdate()
 			return
 		}
 
+		widget.weatherMu.Lock()
 		widget.Place = place
+		widget.weatherMu.Unlock()
 	}
 
-	weather, err := fetchWeatherForOpenMeteoPlace(widget.Place, widget.Units)
+	weather, err := fetchWeatherForOpenMeteoPlace(widget.cachedHTTPClient(false), widget.Place, widget.units(widget.Units))
 
 	if !widget.canContinueUpdateAfterHandlingErr(err) {
 		return
 	}
 
+	widget.weatherMu.Lock()
 	widget.Weather = weather
+	widget.weatherMu.Unlock()
+}
+
+func (widget *weatherWidget) briefing(_ time.Time, _ int) []string {
+	if widget.Weather == nil || widget.Place == nil {
+		return nil
+	}
+
+	weather := widget.Weather
+	current := fmt.Sprintf("In %s it's currently %d degrees", widget.Place.Name, weather.Temperature)
+	if condition := weather.WeatherCodeAsString(); condition != "" {
+		current += " and " + strings.ToLower(condition)
+	}
+
+	sentences := []string{current + "."}
+
+	if len(weather.Columns) > 0 {
+		high, low := weather.Columns[0].Temperature, weather.Columns[0].Temperature
+		for _, column := range weather.Columns[1:] {
+			high = max(high, column.Temperature)
+			low = min(low, column.Temperature)
+		}
+
+		sentences = append(sentences, fmt.Sprintf("Today's high is %d and the low is %d degrees.", high, low))
+	}
+
+	return sentences
 }
 
 func (widget *weatherWidget) Render() template.HTML {
@@ -168,11 +204,11 @@ func parsePlaceName(name string) (string, string) {
 	return parts[0] + ", " + expandCountryAbbreviations(parts[2]), strings.TrimSpace(parts[1])
 }
 
-func fetchOpenMeteoPlaceFromName(location string) (*openMeteoPlaceResponseJson, error) {
+func fetchOpenMeteoPlaceFromName(client requestDoer, location string) (*openMeteoPlaceResponseJson, error) {
 	location, area := parsePlaceName(location)
 	requestUrl := fmt.Sprintf("https://geocoding-api.open-meteo.com/v1/search?name=%s&count=10&language=en&format=json", url.QueryEscape(location))
 	request, _ := http.NewRequest("GET", requestUrl, nil)
-	responseJson, err := decodeJsonFromRequest[openMeteoPlacesResponseJson](defaultHTTPClient, request)
+	responseJson, err := decodeJsonFromRequest[openMeteoPlacesResponseJson](client, request)
 	if err != nil {
 		return nil, fmt.Errorf("fetching places data: %v", err)
 	}
@@ -210,7 +246,7 @@ func fetchOpenMeteoPlaceFromName(location string) (*openMeteoPlaceResponseJson,
 	return place, nil
 }
 
-func fetchWeatherForOpenMeteoPlace(place *openMeteoPlaceResponseJson, units string) (*weather, error) {
+func fetchWeatherForOpenMeteoPlace(client requestDoer, place *openMeteoPlaceResponseJson, units string) (*weather, error) {
 	query := url.Values{}
 	var temperatureUnit string
 
@@ -232,7 +268,7 @@ func fetchWeatherForOpenMeteoPlace(place *openMeteoPlaceResponseJson, units stri
 
 	requestUrl := "https://api.open-meteo.com/v1/forecast?" + query.Encode()
 	request, _ := http.NewRequest("GET", requestUrl, nil)
-	responseJson, err := decodeJsonFromRequest[openMeteoWeatherResponseJson](defaultHTTPClient, request)
+	responseJson, err := decodeJsonFromRequest[openMeteoWeatherResponseJson](client, request)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", errNoContent, err)
 	}
@@ -324,3 +360,41 @@ var weatherCodeTable = map[int]string{
 	96: "Thunderstorm",
 	99: "Thunderstorm",
 }
+
+type weatherHourJson struct {
+	Time             string `json:"time"`
+	Temperature      int    `json:"temperature"`
+	HasPrecipitation bool   `json:"precipitation_likely"`
+}
+
+// Each of the hours is the average of the two hours that lead up to it
+func (widget *weatherWidget) data() any {
+	if widget.Weather == nil || widget.Place == nil {
+		return nil
+	}
+
+	hours := make([]weatherHourJson, 0, len(widget.Weather.Columns))
+	for i, column := range widget.Weather.Columns {
+		if i >= len(widget.TimeLabels) {
+			break
+		}
+
+		hours = append(hours, weatherHourJson{
+			Time:             widget.TimeLabels[i],
+			Temperature:      column.Temperature,
+			HasPrecipitation: column.HasPrecipitation,
+		})
+	}
+
+	return map[string]any{
+		"location":             widget.Place.Name,
+		"area":                 widget.Place.Area,
+		"country":              widget.Place.Country,
+		"units":                widget.units(widget.Units),
+		"temperature":          widget.Weather.Temperature,
+		"apparent_temperature": widget.Weather.ApparentTemperature,
+		"weather_code":         widget.Weather.WeatherCode,
+		"condition":            widget.Weather.WeatherCodeAsString(),
+		"hours":                hours,
+	}
+}
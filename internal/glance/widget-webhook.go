@@ -0,0 +1,351 @@
+package glance
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var webhookWidgetTemplate = mustParseTemplate("webhook.html", "widget-base.html")
+
+const webhookMaxBodySize = 64 * 1024
+
+type webhookWidget struct {
+	widgetBase    `yaml:",inline"`
+	Name          string           `yaml:"name"`
+	Secret        string           `yaml:"secret"`
+	Format        string           `yaml:"format"`
+	Limit         int              `yaml:"limit"`
+	CollapseAfter int              `yaml:"collapse-after"`
+	Messages      []webhookMessage `yaml:"-"`
+	messagesMutex sync.Mutex       `yaml:"-"`
+}
+
+func (widget *webhookWidget) initialize() error {
+	widget.withTitle("Notifications").withError(nil)
+
+	if widget.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if widget.Name != url.PathEscape(widget.Name) {
+		return errors.New("name can only contain characters that are valid in a URL path")
+	}
+
+	if widget.Format == "" {
+		widget.Format = "auto"
+	} else if widget.Format != "auto" && widget.Format != "generic" && widget.Format != "slack" && widget.Format != "discord" {
+		return fmt.Errorf("unsupported format %s, must be one of auto, generic, slack or discord", widget.Format)
+	}
+
+	if widget.Limit <= 0 {
+		widget.Limit = 20
+	}
+
+	if widget.CollapseAfter == 0 || widget.CollapseAfter < -1 {
+		widget.CollapseAfter = 5
+	}
+
+	return nil
+}
+
+func (widget *webhookWidget) Render() template.HTML {
+	widget.messagesMutex.Lock()
+	defer widget.messagesMutex.Unlock()
+
+	return widget.renderTemplate(widget, webhookWidgetTemplate)
+}
+
+type webhookMessage struct {
+	Title      string
+	Text       string
+	URL        string
+	Domain     string
+	Sender     string
+	ReceivedAt time.Time
+}
+
+func (widget *webhookWidget) addMessages(messages []webhookMessage) {
+	widget.messagesMutex.Lock()
+	defer widget.messagesMutex.Unlock()
+
+	widget.Messages = append(append(make([]webhookMessage, 0, len(messages)), messages...), widget.Messages...)
+
+	if len(widget.Messages) > widget.Limit {
+		widget.Messages = widget.Messages[:widget.Limit]
+	}
+}
+
+func (widget *webhookWidget) isAuthorized(r *http.Request) bool {
+	if widget.Secret == "" {
+		return true
+	}
+
+	// most services that send Slack and Discord webhooks don't allow setting
+	// custom headers, so the secret can also be passed as a query parameter
+	secret := r.URL.Query().Get("secret")
+	if secret == "" {
+		secret = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(widget.Secret)) == 1
+}
+
+func (a *application) handleWebhookRequest(w http.ResponseWriter, r *http.Request) {
+	widget, exists := a.webhookByName[r.PathValue("name")]
+	if !exists {
+		a.handleNotFound(w, r)
+		return
+	}
+
+	if !widget.isAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBodySize))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// legacy Slack integrations send the payload as a form value
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		values, err := url.ParseQuery(string(body))
+		if err == nil && values.Has("payload") {
+			body = []byte(values.Get("payload"))
+		}
+	}
+
+	messages, err := parseWebhookPayload(widget.Format, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	for i := range messages {
+		messages[i].ReceivedAt = now
+
+		if messages[i].URL != "" {
+			messages[i].Domain = extractDomainFromUrl(messages[i].URL)
+		}
+	}
+
+	widget.addMessages(messages)
+
+	// clients of Slack-compatible webhooks tend to check for this exact response
+	w.Write([]byte("ok"))
+}
+
+type webhookGenericPayloadJson struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	Text    string `json:"text"`
+	URL     string `json:"url"`
+	Sender  string `json:"sender"`
+}
+
+type webhookSlackPayloadJson struct {
+	Text        string `json:"text"`
+	Username    string `json:"username"`
+	Attachments []struct {
+		Pretext   string `json:"pretext"`
+		Title     string `json:"title"`
+		TitleLink string `json:"title_link"`
+		Text      string `json:"text"`
+		Fallback  string `json:"fallback"`
+	} `json:"attachments"`
+	Blocks []struct {
+		Type string `json:"type"`
+		Text struct {
+			Text string `json:"text"`
+		} `json:"text"`
+	} `json:"blocks"`
+}
+
+type webhookDiscordPayloadJson struct {
+	Content  string `json:"content"`
+	Username string `json:"username"`
+	Embeds   []struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		URL         string `json:"url"`
+	} `json:"embeds"`
+}
+
+func detectWebhookPayloadFormat(body []byte) string {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return "generic"
+	}
+
+	if _, ok := keys["embeds"]; ok {
+		return "discord"
+	}
+
+	if _, ok := keys["content"]; ok {
+		return "discord"
+	}
+
+	if _, ok := keys["attachments"]; ok {
+		return "slack"
+	}
+
+	if _, ok := keys["blocks"]; ok {
+		return "slack"
+	}
+
+	// a lone text property is what most Slack-compatible integrations send
+	_, hasTitle := keys["title"]
+	_, hasMessage := keys["message"]
+	if _, ok := keys["text"]; ok && !hasTitle && !hasMessage {
+		return "slack"
+	}
+
+	return "generic"
+}
+
+func parseWebhookPayload(format string, body []byte) ([]webhookMessage, error) {
+	if format == "auto" {
+		format = detectWebhookPayloadFormat(body)
+	}
+
+	var messages []webhookMessage
+	var err error
+
+	switch format {
+	case "slack":
+		messages, err = parseSlackWebhookPayload(body)
+	case "discord":
+		messages, err = parseDiscordWebhookPayload(body)
+	default:
+		messages, err = parseGenericWebhookPayload(body)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(messages) == 0 {
+		return nil, errors.New("payload contains no message")
+	}
+
+	return messages, nil
+}
+
+func parseGenericWebhookPayload(body []byte) ([]webhookMessage, error) {
+	text := strings.TrimSpace(string(body))
+	if text == "" {
+		return nil, nil
+	}
+
+	// anything that isn't a JSON object is treated as a plain text message
+	if !strings.HasPrefix(text, "{") {
+		return []webhookMessage{{Text: text}}, nil
+	}
+
+	var payload webhookGenericPayloadJson
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %v", err)
+	}
+
+	message := webhookMessage{
+		Title:  payload.Title,
+		Text:   ternary(payload.Message != "", payload.Message, payload.Text),
+		URL:    payload.URL,
+		Sender: payload.Sender,
+	}
+
+	if message.Title == "" && message.Text == "" {
+		return nil, nil
+	}
+
+	return []webhookMessage{message}, nil
+}
+
+func parseSlackWebhookPayload(body []byte) ([]webhookMessage, error) {
+	var payload webhookSlackPayloadJson
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %v", err)
+	}
+
+	text := payload.Text
+
+	// the text is used as a fallback when blocks are present, prefer the blocks
+	blockTexts := make([]string, 0, len(payload.Blocks))
+	for _, block := range payload.Blocks {
+		if block.Type == "section" || block.Type == "header" {
+			if block.Text.Text != "" {
+				blockTexts = append(blockTexts, block.Text.Text)
+			}
+		}
+	}
+
+	if len(blockTexts) > 0 {
+		text = strings.Join(blockTexts, "\n")
+	}
+
+	messages := make([]webhookMessage, 0, 1+len(payload.Attachments))
+
+	if text != "" {
+		messages = append(messages, webhookMessage{
+			Text:   formatSlackLinks(text),
+			Sender: payload.Username,
+		})
+	}
+
+	for _, attachment := range payload.Attachments {
+		message := webhookMessage{
+			Title:  formatSlackLinks(ternary(attachment.Title != "", attachment.Title, attachment.Pretext)),
+			Text:   formatSlackLinks(ternary(attachment.Text != "", attachment.Text, attachment.Fallback)),
+			URL:    attachment.TitleLink,
+			Sender: payload.Username,
+		}
+
+		if message.Title != "" || message.Text != "" {
+			messages = append(messages, message)
+		}
+	}
+
+	return messages, nil
+}
+
+func parseDiscordWebhookPayload(body []byte) ([]webhookMessage, error) {
+	var payload webhookDiscordPayloadJson
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %v", err)
+	}
+
+	messages := make([]webhookMessage, 0, 1+len(payload.Embeds))
+
+	if payload.Content != "" {
+		messages = append(messages, webhookMessage{
+			Text:   payload.Content,
+			Sender: payload.Username,
+		})
+	}
+
+	for _, embed := range payload.Embeds {
+		if embed.Title == "" && embed.Description == "" {
+			continue
+		}
+
+		messages = append(messages, webhookMessage{
+			Title:  embed.Title,
+			Text:   embed.Description,
+			URL:    embed.URL,
+			Sender: payload.Username,
+		})
+	}
+
+	return messages, nil
+}
@@ -3,15 +3,19 @@ package glance
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
 	"math"
 	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"golang.org/x/text/message"
 	"gopkg.in/yaml.v3"
 )
 
@@ -31,6 +35,10 @@ func newWidget(widgetType string) (widget, error) {
 		w = &weatherWidget{}
 	case "bookmarks":
 		w = &bookmarksWidget{}
+	case "read-later":
+		w = &readLaterWidget{}
+	case "recent-links":
+		w = &recentLinksWidget{}
 	case "iframe":
 		w = &iframeWidget{}
 	case "html":
@@ -47,6 +55,10 @@ func newWidget(widgetType string) (widget, error) {
 		w = &redditWidget{}
 	case "rss":
 		w = &rssWidget{}
+	case "digest":
+		w = &digestWidget{}
+	case "topics":
+		w = &topicsWidget{}
 	case "monitor":
 		w = &monitorWidget{}
 	case "twitch-top-games":
@@ -63,6 +75,8 @@ func newWidget(widgetType string) (widget, error) {
 		w = &searchWidget{}
 	case "extension":
 		w = &extensionWidget{}
+	case "exec":
+		w = &execWidget{}
 	case "group":
 		w = &groupWidget{}
 	case "dns-stats":
@@ -75,6 +89,116 @@ func newWidget(widgetType string) (widget, error) {
 		w = &dockerContainersWidget{}
 	case "server-stats":
 		w = &serverStatsWidget{}
+	case "exchange-rates":
+		w = &exchangeRatesWidget{}
+	case "github-security-alerts":
+		w = &githubSecurityAlertsWidget{}
+	case "vaultwarden":
+		w = &vaultwardenWidget{}
+	case "identity-events":
+		w = &identityEventsWidget{}
+	case "firewall":
+		w = &firewallWidget{}
+	case "dynamic-dns":
+		w = &dynamicDNSWidget{}
+	case "wake-on-lan":
+		w = &wakeOnLANWidget{}
+	case "buttons":
+		w = &buttonsWidget{}
+	case "qr-code":
+		w = &qrCodeWidget{}
+	case "home-network":
+		w = &homeNetworkWidget{}
+	case "files":
+		w = &filesWidget{}
+	case "log-tail":
+		w = &logTailWidget{}
+	case "log-query":
+		w = &logQueryWidget{}
+	case "sensors":
+		w = &sensorsWidget{}
+	case "cameras":
+		w = &camerasWidget{}
+	case "camera-events":
+		w = &cameraEventsWidget{}
+	case "travel-time":
+		w = &travelTimeWidget{}
+	case "transit-alerts":
+		w = &transitAlertsWidget{}
+	case "ski-resort":
+		w = &skiResortWidget{}
+	case "surf-forecast":
+		w = &surfForecastWidget{}
+	case "garden":
+		w = &gardenWidget{}
+	case "waste-collection":
+		w = &wasteCollectionWidget{}
+	case "timetable":
+		w = &timetableWidget{}
+	case "focus-timer":
+		w = &focusTimerWidget{}
+	case "day-summary":
+		w = &daySummaryWidget{}
+	case "mail-server":
+		w = &mailServerWidget{}
+	case "matrix":
+		w = &matrixWidget{}
+	case "discord":
+		w = &discordWidget{}
+	case "slack":
+		w = &slackWidget{}
+	case "webhook":
+		w = &webhookWidget{}
+	case "nightscout":
+		w = &nightscoutWidget{}
+	case "fitness":
+		w = &fitnessWidget{}
+	case "podcasts":
+		w = &podcastsWidget{}
+	case "reminders":
+		w = &remindersWidget{}
+	case "chores":
+		w = &choresWidget{}
+	case "network-usage":
+		w = &networkUsageWidget{}
+	case "arr":
+		w = &arrWidget{}
+	case "media-server":
+		w = &mediaServerWidget{}
+	case "media-picker":
+		w = &mediaPickerWidget{}
+	case "home-assistant":
+		w = &homeAssistantWidget{}
+	case "trakt":
+		w = &traktWidget{}
+	case "media-activity":
+		w = &mediaActivityWidget{}
+	case "newsletters":
+		w = &newslettersWidget{}
+	case "bandwidth":
+		w = &bandwidthWidget{}
+	case "job-listings":
+		w = &jobListingsWidget{}
+	case "listing-watch":
+		w = &listingWatchWidget{}
+	case "price-tracker":
+		w = &priceTrackerWidget{}
+	case "sports":
+		w = &sportsWidget{}
+	case "kpi":
+		w = &kpiWidget{}
+	case "f1":
+		w = &f1Widget{}
+	case "analytics":
+		w = &analyticsWidget{}
+	case "countdown":
+		w = &countdownWidget{}
+	case "revenue":
+		w = &revenueWidget{}
+	case "anniversaries":
+		w = &anniversariesWidget{}
+	case "app-stats":
+		w = &appStatsWidget{}
 	default:
 		return nil, fmt.Errorf("unknown widget type: %s", widgetType)
 	}
@@ -99,18 +223,49 @@ func (w *widgets) UnmarshalYAML(node *yaml.Node) error {
 		}{}
 
 		if err := node.Decode(&meta); err != nil {
-			return err
+			return errorAtConfigLine(&node, err)
 		}
 
 		widget, err := newWidget(meta.Type)
 		if err != nil {
-			return err
+			return errorAtConfigLine(&node, err)
 		}
 
 		if err = node.Decode(widget); err != nil {
+			return errorAtConfigLine(&node, err)
+		}
+
+		if base, ok := widget.(interface{ getWidgetBase() *widgetBase }); ok {
+			if err := base.getWidgetBase().parseCustomTemplate(); err != nil {
+				return errorAtConfigLine(&node, err)
+			}
+		}
+
+		widget.setConfigLine(node.Line)
+
+		// used to tell whether the widget has changed when the config gets reloaded
+		encoded, err := yaml.Marshal(&node)
+		if err != nil {
 			return err
 		}
 
+		hash := sha256.New()
+		hash.Write(encoded)
+
+		// the config stays the same when only a template file changes, be it
+		// the widget's own or one of the widgets within it
+		if base, ok := widget.(interface{ getWidgetBase() *widgetBase }); ok {
+			hash.Write(base.getWidgetBase().customTemplateFileContents)
+		}
+
+		if container, ok := widget.(interface{ getChildWidgets() widgets }); ok {
+			for _, child := range container.getChildWidgets() {
+				hash.Write([]byte(child.getConfigHash()))
+			}
+		}
+
+		widget.setConfigHash(hex.EncodeToString(hash.Sum(nil)))
+
 		*w = append(*w, widget)
 	}
 
@@ -128,8 +283,13 @@ type widget interface {
 	setProviders(*widgetProviders)
 	update(context.Context)
 	setID(uint64)
+	getConfigHash() string
+	setConfigHash(string)
+	getConfigLine() int
+	setConfigLine(int)
 	handleRequest(w http.ResponseWriter, r *http.Request)
 	setHideHeader(bool)
+	getAccessControl() *accessControl
 }
 
 type cacheType int
@@ -141,31 +301,74 @@ const (
 )
 
 type widgetBase struct {
-	ID                  uint64           `yaml:"-"`
-	Providers           *widgetProviders `yaml:"-"`
-	Type                string           `yaml:"type"`
-	Title               string           `yaml:"title"`
-	TitleURL            string           `yaml:"title-url"`
-	CSSClass            string           `yaml:"css-class"`
-	CustomCacheDuration durationField    `yaml:"cache"`
-	ContentAvailable    bool             `yaml:"-"`
-	WIP                 bool             `yaml:"-"`
-	Error               error            `yaml:"-"`
-	Notice              error            `yaml:"-"`
-	templateBuffer      bytes.Buffer     `yaml:"-"`
-	cacheDuration       time.Duration    `yaml:"-"`
-	cacheType           cacheType        `yaml:"-"`
-	nextUpdate          time.Time        `yaml:"-"`
-	updateRetriedTimes  int              `yaml:"-"`
-	HideHeader          bool             `yaml:"-"`
+	ID                   uint64                   `yaml:"-"`
+	Providers            *widgetProviders         `yaml:"-"`
+	Type                 string                   `yaml:"type"`
+	Title                string                   `yaml:"title"`
+	StateID              string                   `yaml:"id"`
+	TitleURL             string                   `yaml:"title-url"`
+	CSSClass             string                   `yaml:"css-class"`
+	CustomCacheDuration  durationField            `yaml:"cache"`
+	HTTPOptions          *httpClientOptionsField  `yaml:"http"`
+	StaleWhileRevalidate durationField            `yaml:"stale-while-revalidate"`
+	StaleIfError         *durationField           `yaml:"stale-if-error"`
+	Budget               *widgetBudget            `yaml:"budget"`
+	Access               accessControl            `yaml:",inline"`
+	Schedule             visibilitySchedule       `yaml:",inline"`
+	Locale               localeField              `yaml:"locale"`
+	ContentAvailable     bool                     `yaml:"-"`
+	WIP                  bool                     `yaml:"-"`
+	Error                error                    `yaml:"-"`
+	Notice               error                    `yaml:"-"`
+	templateBuffer       bytes.Buffer             `yaml:"-"`
+	cacheDuration        time.Duration            `yaml:"-"`
+	cacheType            cacheType                `yaml:"-"`
+	nextUpdate           time.Time                `yaml:"-"`
+	updateRetriedTimes   int                      `yaml:"-"`
+	configHash           string                   `yaml:"-"`
+	configLine           int                      `yaml:"-"`
+	HideHeader           bool                     `yaml:"-"`
+	staleMu              sync.Mutex               `yaml:"-"`
+	staleResponses       map[string]staleResponse `yaml:"-"`
+	usedRestoredResponse atomic.Bool              `yaml:"-"`
+	bypassResponseCache  atomic.Bool              `yaml:"-"`
+	usageTracker         widgetUsageTracker       `yaml:"-"`
+	budgetThrottledUntil time.Time                `yaml:"-"`
+	metrics              widgetMetrics            `yaml:"-"`
+	pageTitle            string                   `yaml:"-"`
+	location             string                   `yaml:"-"`
+	updateCtxMu          sync.Mutex               `yaml:"-"`
+	updateCtx            context.Context          `yaml:"-"`
+	inheritedSchedules   []*visibilitySchedule    `yaml:"-"`
+	// replaces the content of the widget, see parseCustomTemplate
+	CustomTemplate          string             `yaml:"custom-template"`
+	CustomTemplateFile      string             `yaml:"custom-template-file"`
+	customTemplate          *template.Template `yaml:"-"`
+	renderingCustomTemplate bool               `yaml:"-"`
+	// included in the config hash since the file can change on its own
+	customTemplateFileContents []byte `yaml:"-"`
 }
 
 type widgetProviders struct {
-	assetResolver func(string) string
+	assetResolver           func(string) string
+	readerURLResolver       func(widgetID uint64, articleURL string) string
+	readerItemURLResolver   func(widgetID uint64, key string) string
+	widgetURLResolver       func(widgetID uint64, path string) string
+	uptimeReportURLResolver func(widgetID uint64) string
+	dataPath                string
+	state                   *stateStore
+	audit                   *auditLog
+	notifier                *notifier
+	bookmarkServices        map[string]*bookmarkService
+	summarizer              *summarizer
+	feedItems               *feedItemIndex
+	feedItemsResolver       func(widgetID uint64) ([]feedItem, int)
+	daySummaryResolver      func(widgetID uint64, now time.Time, l *locale) []daySummaryPart
+	locale                  localeField
 }
 
 func (w *widgetBase) requiresUpdate(now *time.Time) bool {
-	if w.cacheType == cacheTypeInfinite {
+	if w.cacheType == cacheTypeInfinite || !w.isScheduledVisible(*now) {
 		return false
 	}
 
@@ -173,6 +376,11 @@ func (w *widgetBase) requiresUpdate(now *time.Time) bool {
 		return true
 	}
 
+	// widgets still get updated once while paused so that they have something to show
+	if w.isScheduledPaused(*now) {
+		return false
+	}
+
 	return now.After(w.nextUpdate)
 }
 
@@ -192,6 +400,37 @@ func (w *widgetBase) setID(id uint64) {
 	w.ID = id
 }
 
+func (w *widgetBase) getConfigHash() string {
+	return w.configHash
+}
+
+func (w *widgetBase) setConfigHash(hash string) {
+	w.configHash = hash
+}
+
+func (w *widgetBase) getConfigLine() int {
+	return w.configLine
+}
+
+func (w *widgetBase) setConfigLine(line int) {
+	w.configLine = line
+}
+
+// Scopes what the widget keeps in the state store, so that widgets with the
+// same title don't share it and renaming one doesn't lose it. Unless an id is
+// set it's where the widget is in the config, which changes if it gets moved.
+func (w *widgetBase) stateScope() string {
+	if w.StateID != "" {
+		return w.StateID
+	}
+
+	return w.location
+}
+
+func (w *widgetBase) getAccessControl() *accessControl {
+	return &w.Access
+}
+
 func (w *widgetBase) setHideHeader(value bool) {
 	w.HideHeader = value
 }
@@ -200,6 +439,95 @@ func (widget *widgetBase) handleRequest(w http.ResponseWriter, r *http.Request)
 	http.Error(w, "not implemented", http.StatusNotImplemented)
 }
 
+// Returns the client that outgoing requests should be made with, taking into
+// account the http options of the widget. Widgets with their own allow-insecure
+// option should pass its value along.
+func (w *widgetBase) httpClient(allowInsecure bool) requestDoer {
+	return w.wrapHTTPClient(w.sharedHTTPClient(allowInsecure))
+}
+
+// Puts everything that requests made by widgets go through in front of the
+// given client, for requests that need a client of their own such as ones
+// going through a proxy
+func (w *widgetBase) wrapHTTPClient(shared *http.Client) requestDoer {
+	return &updateContextHTTPClient{
+		client: &coalescingHTTPClient{
+			client: &resilientHTTPClient{
+				client: &meteredHTTPClient{client: shared, widget: w},
+			},
+			shared: shared,
+		},
+		widget: w,
+	}
+}
+
+// Same as httpClient but requests are made only once and on their own, without
+// retries, the circuit breaker or rate limits, for checks such as the monitor's
+// where how the request went is what gets shown
+func (w *widgetBase) checkHTTPClient(allowInsecure bool) requestDoer {
+	return &updateContextHTTPClient{
+		client: &meteredHTTPClient{client: w.sharedHTTPClient(allowInsecure), widget: w},
+		widget: w,
+	}
+}
+
+// Most widgets create their requests without a context, so requests made
+// while the widget is being updated get the context of the update, which
+// is what lets a page stop waiting on a widget once it times out
+type updateContextHTTPClient struct {
+	client requestDoer
+	widget *widgetBase
+}
+
+func (c *updateContextHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	if request.Context() == context.Background() {
+		if ctx := c.widget.currentUpdateContext(); ctx != nil {
+			request = request.WithContext(ctx)
+		}
+	}
+
+	return c.client.Do(request)
+}
+
+func (w *widgetBase) setUpdateContext(ctx context.Context) {
+	w.updateCtxMu.Lock()
+	w.updateCtx = ctx
+	w.updateCtxMu.Unlock()
+}
+
+func (w *widgetBase) currentUpdateContext() context.Context {
+	w.updateCtxMu.Lock()
+	defer w.updateCtxMu.Unlock()
+
+	return w.updateCtx
+}
+
+// The underlying client, which is shared between widgets that don't have
+// their own http options
+func (w *widgetBase) sharedHTTPClient(allowInsecure bool) *http.Client {
+	if w.HTTPOptions == nil {
+		return ternary(allowInsecure, defaultInsecureHTTPClient, defaultHTTPClient)
+	}
+
+	return ternary(allowInsecure, w.HTTPOptions.insecureClient, w.HTTPOptions.client)
+}
+
+// Same as httpClient but GET requests go through the shared response cache,
+// meant for widgets showing data where something slightly out of date is
+// better than an error, unlike a monitor
+func (w *widgetBase) cachedHTTPClient(allowInsecure bool) requestDoer {
+	return w.wrapCachedHTTPClient(w.sharedHTTPClient(allowInsecure))
+}
+
+// Same as wrapHTTPClient but GET requests go through the shared response cache
+func (w *widgetBase) wrapCachedHTTPClient(shared *http.Client) requestDoer {
+	return &cachedHTTPClient{
+		client: w.wrapHTTPClient(shared),
+		shared: shared,
+		widget: w,
+	}
+}
+
 func (w *widgetBase) GetType() string {
 	return w.Type
 }
@@ -209,6 +537,21 @@ func (w *widgetBase) setProviders(providers *widgetProviders) {
 }
 
 func (w *widgetBase) renderTemplate(data any, t *template.Template) template.HTML {
+	t = localizedTemplate(t, w.locale())
+
+	// only replaces the templates that render the widget itself
+	if w.customTemplate != nil && t.Lookup("widget-content") != nil {
+		// would otherwise recurse forever if the custom template calls .Render
+		if w.renderingCustomTemplate {
+			return ""
+		}
+
+		w.renderingCustomTemplate = true
+		defer func() { w.renderingCustomTemplate = false }()
+
+		t = w.customTemplate
+	}
+
 	w.templateBuffer.Reset()
 	err := t.Execute(&w.templateBuffer, data)
 	if err != nil {
@@ -234,6 +577,38 @@ func (w *widgetBase) renderTemplate(data any, t *template.Template) template.HTM
 	return template.HTML(w.templateBuffer.String())
 }
 
+// The locale of the widget if it has one, otherwise the global one
+func (w *widgetBase) locale() localeField {
+	if w.Locale.isSet() || w.Providers == nil {
+		return w.Locale
+	}
+
+	return w.Providers.locale
+}
+
+// The units that were configured, otherwise the ones used where the widget's
+// locale is from
+func (w *widgetBase) units(configured string) string {
+	if configured != "" {
+		return configured
+	}
+
+	return ternary(w.locale().usesImperialUnits(), "imperial", "metric")
+}
+
+// Set on the widget's element when its locale differs from the page's
+func (w *widgetBase) Lang() string {
+	if !w.Locale.isSet() || w.Providers != nil && w.Locale == w.Providers.locale {
+		return ""
+	}
+
+	return w.Locale.String()
+}
+
+func (w *widgetBase) printer() *message.Printer {
+	return getLocale(w.locale()).printer
+}
+
 func (w *widgetBase) withTitle(title string) *widgetBase {
 	if w.Title == "" {
 		w.Title = title
@@ -321,23 +696,28 @@ func (w *widgetBase) canContinueUpdateAfterHandlingErr(err error) bool {
 func (w *widgetBase) getNextUpdateTime() time.Time {
 	now := time.Now()
 
-	if w.cacheType == cacheTypeDuration {
-		return now.Add(w.cacheDuration)
-	}
+	var next time.Time
 
-	if w.cacheType == cacheTypeOnTheHour {
-		return now.Add(time.Duration(
+	if w.cacheType == cacheTypeDuration {
+		next = now.Add(w.cacheDuration)
+	} else if w.cacheType == cacheTypeOnTheHour {
+		next = now.Add(time.Duration(
 			((60-now.Minute())*60)-now.Second(),
 		) * time.Second)
 	}
 
-	return time.Time{}
+	return w.throttleForBudget(next)
 }
 
 func (w *widgetBase) scheduleNextUpdate() *widgetBase {
 	w.nextUpdate = w.getNextUpdateTime()
 	w.updateRetriedTimes = 0
 
+	// the responses from before a restart are being refreshed in the background
+	if w.usedRestoredResponse.Swap(false) {
+		w.nextUpdate = time.Now().Add(restoredResponseRefreshDelay)
+	}
+
 	return w
 }
 
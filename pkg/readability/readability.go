@@ -0,0 +1,461 @@
+// Package readability extracts the main content of an article from an HTML
+// document, loosely following the heuristics of Mozilla's Readability.
+package readability
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var ErrNoContent = errors.New("could not find any article content")
+
+type Article struct {
+	Title       string
+	Byline      string
+	SiteName    string
+	Excerpt     string
+	Content     string
+	TextLength  int
+	ReadingTime int // in minutes
+}
+
+var (
+	positiveCandidatePattern = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story`)
+	negativeCandidatePattern = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|widget|nav|menu|cookie|newsletter|subscribe`)
+	whitespacePattern        = regexp.MustCompile(`\s+`)
+)
+
+// elements that never contain article content and are removed before scoring
+var removedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Iframe:   true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Input:    true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Svg:      true,
+	atom.Canvas:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Link:     true,
+	atom.Meta:     true,
+}
+
+// elements kept in the output, anything else gets unwrapped and only its children are kept
+var allowedElements = map[atom.Atom]bool{
+	atom.P: true, atom.Br: true, atom.Hr: true, atom.Div: true, atom.Span: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Code: true,
+	atom.Em: true, atom.Strong: true, atom.B: true, atom.I: true, atom.U: true, atom.S: true,
+	atom.Sub: true, atom.Sup: true, atom.Small: true, atom.Mark: true,
+	atom.A: true, atom.Img: true, atom.Figure: true, atom.Figcaption: true, atom.Picture: true,
+	atom.Table: true, atom.Thead: true, atom.Tbody: true, atom.Tfoot: true, atom.Tr: true, atom.Th: true, atom.Td: true,
+	atom.Caption: true,
+}
+
+var allowedAttributes = map[string]bool{
+	"href":    true,
+	"src":     true,
+	"alt":     true,
+	"title":   true,
+	"colspan": true,
+	"rowspan": true,
+}
+
+// Extract parses the document read from r and returns its main content.
+// pageURL is used to resolve relative links and image sources.
+func Extract(r io.Reader, pageURL *url.URL) (*Article, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	article := &Article{}
+	extractMetadata(doc, article)
+	removeUnlikelyNodes(doc)
+
+	candidate := findTopCandidate(doc)
+	if candidate == nil {
+		return nil, ErrNoContent
+	}
+
+	var buffer bytes.Buffer
+	for child := candidate.FirstChild; child != nil; child = child.NextSibling {
+		writeSanitized(&buffer, child, pageURL)
+	}
+
+	article.Content = strings.TrimSpace(buffer.String())
+	article.TextLength = utf8.RuneCountInString(normalizedText(candidate))
+
+	if article.TextLength == 0 {
+		return nil, ErrNoContent
+	}
+
+	// assume an average reading speed of about 200 words, or ~1000 characters per minute
+	article.ReadingTime = max(1, article.TextLength/1000)
+
+	if article.Excerpt == "" {
+		article.Excerpt = firstParagraphText(candidate)
+	}
+
+	return article, nil
+}
+
+func extractMetadata(doc *html.Node, article *Article) {
+	var title string
+
+	walk(doc, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return true
+		}
+
+		switch n.DataAtom {
+		case atom.Title:
+			if title == "" {
+				title = normalizedText(n)
+			}
+		case atom.Meta:
+			key := strings.ToLower(attr(n, "property"))
+			if key == "" {
+				key = strings.ToLower(attr(n, "name"))
+			}
+			content := strings.TrimSpace(attr(n, "content"))
+
+			switch key {
+			case "og:title", "twitter:title":
+				if article.Title == "" {
+					article.Title = content
+				}
+			case "author", "article:author":
+				if article.Byline == "" && !strings.HasPrefix(content, "http") {
+					article.Byline = content
+				}
+			case "og:site_name":
+				article.SiteName = content
+			case "description", "og:description":
+				if article.Excerpt == "" {
+					article.Excerpt = content
+				}
+			}
+		}
+
+		return true
+	})
+
+	if article.Title == "" {
+		article.Title = title
+	}
+}
+
+func removeUnlikelyNodes(doc *html.Node) {
+	var removed []*html.Node
+
+	walk(doc, func(n *html.Node) bool {
+		if n.Type == html.CommentNode {
+			removed = append(removed, n)
+			return false
+		}
+
+		if n.Type != html.ElementNode {
+			return true
+		}
+
+		if removedElements[n.DataAtom] || attr(n, "hidden") != "" || attr(n, "aria-hidden") == "true" {
+			removed = append(removed, n)
+			return false
+		}
+
+		// don't remove the body or elements that are likely to be the article
+		// itself even if they match one of the negative patterns
+		if n.DataAtom == atom.Body || n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+			return true
+		}
+
+		classAndID := attr(n, "class") + " " + attr(n, "id")
+		if negativeCandidatePattern.MatchString(classAndID) && !positiveCandidatePattern.MatchString(classAndID) {
+			removed = append(removed, n)
+			return false
+		}
+
+		return true
+	})
+
+	for _, n := range removed {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+func findTopCandidate(doc *html.Node) *html.Node {
+	scores := make(map[*html.Node]float64)
+
+	walk(doc, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return true
+		}
+
+		if n.DataAtom != atom.P && n.DataAtom != atom.Pre && n.DataAtom != atom.Td && n.DataAtom != atom.Blockquote {
+			return true
+		}
+
+		text := normalizedText(n)
+		if len(text) < 25 {
+			return false
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+
+		if parent := n.Parent; parent != nil && parent.Type == html.ElementNode {
+			if _, exists := scores[parent]; !exists {
+				scores[parent] = initialScore(parent)
+			}
+			scores[parent] += score
+
+			if grandparent := parent.Parent; grandparent != nil && grandparent.Type == html.ElementNode {
+				if _, exists := scores[grandparent]; !exists {
+					scores[grandparent] = initialScore(grandparent)
+				}
+				scores[grandparent] += score / 2
+			}
+		}
+
+		return false
+	})
+
+	var top *html.Node
+	var topScore float64
+
+	for n, score := range scores {
+		score *= 1 - linkDensity(n)
+
+		if top == nil || score > topScore {
+			top = n
+			topScore = score
+		}
+	}
+
+	return top
+}
+
+func initialScore(n *html.Node) float64 {
+	var score float64
+
+	switch n.DataAtom {
+	case atom.Article:
+		score += 10
+	case atom.Div, atom.Main, atom.Section:
+		score += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score += 3
+	case atom.Ol, atom.Ul, atom.Dl, atom.Form:
+		score -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score -= 5
+	}
+
+	classAndID := attr(n, "class") + " " + attr(n, "id")
+
+	if positiveCandidatePattern.MatchString(classAndID) {
+		score += 25
+	}
+
+	if negativeCandidatePattern.MatchString(classAndID) {
+		score -= 25
+	}
+
+	return score
+}
+
+func linkDensity(n *html.Node) float64 {
+	textLength := len(normalizedText(n))
+	if textLength == 0 {
+		return 0
+	}
+
+	linkLength := 0
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.ElementNode && c.DataAtom == atom.A {
+			linkLength += len(normalizedText(c))
+			return false
+		}
+
+		return true
+	})
+
+	return float64(linkLength) / float64(textLength)
+}
+
+func writeSanitized(w *bytes.Buffer, n *html.Node, pageURL *url.URL) {
+	switch n.Type {
+	case html.TextNode:
+		w.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if !allowedElements[n.DataAtom] {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			writeSanitized(w, child, pageURL)
+		}
+		return
+	}
+
+	// lazy loaded images commonly keep their real source in a data attribute
+	if n.DataAtom == atom.Img {
+		if src := attr(n, "data-src"); src != "" {
+			setAttr(n, "src", src)
+		}
+
+		if attr(n, "src") == "" {
+			return
+		}
+	}
+
+	w.WriteByte('<')
+	w.WriteString(n.Data)
+
+	for _, a := range n.Attr {
+		if a.Namespace != "" || !allowedAttributes[a.Key] {
+			continue
+		}
+
+		value := a.Val
+
+		if a.Key == "href" || a.Key == "src" {
+			value = resolveURL(pageURL, value)
+			if value == "" {
+				continue
+			}
+		}
+
+		w.WriteByte(' ')
+		w.WriteString(a.Key)
+		w.WriteString(`="`)
+		w.WriteString(html.EscapeString(value))
+		w.WriteByte('"')
+	}
+
+	if n.DataAtom == atom.A {
+		w.WriteString(` target="_blank" rel="noreferrer"`)
+	} else if n.DataAtom == atom.Img {
+		w.WriteString(` loading="lazy"`)
+	}
+
+	if n.DataAtom == atom.Img || n.DataAtom == atom.Br || n.DataAtom == atom.Hr {
+		w.WriteByte('>')
+		return
+	}
+
+	w.WriteByte('>')
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		writeSanitized(w, child, pageURL)
+	}
+
+	w.WriteString("</")
+	w.WriteString(n.Data)
+	w.WriteByte('>')
+}
+
+func resolveURL(base *url.URL, value string) string {
+	parsed, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return ""
+	}
+
+	if base != nil {
+		parsed = base.ResolveReference(parsed)
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return ""
+	}
+
+	return parsed.String()
+}
+
+func firstParagraphText(n *html.Node) string {
+	var text string
+
+	walk(n, func(c *html.Node) bool {
+		if text != "" {
+			return false
+		}
+
+		if c.Type == html.ElementNode && c.DataAtom == atom.P {
+			text = normalizedText(c)
+			return false
+		}
+
+		return true
+	})
+
+	return text
+}
+
+func normalizedText(n *html.Node) string {
+	var builder strings.Builder
+
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.TextNode {
+			builder.WriteString(c.Data)
+			builder.WriteByte(' ')
+		}
+
+		return true
+	})
+
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(builder.String(), " "))
+}
+
+// walk calls fn for n and each of its descendants, skipping the
+// descendants of any node for which fn returns false
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, fn)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func setAttr(n *html.Node, key string, value string) {
+	for i := range n.Attr {
+		if n.Attr[i].Namespace == "" && n.Attr[i].Key == key {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: value})
+}
@@ -1,6 +1,7 @@
 package sysinfo
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -129,6 +130,12 @@ func Collect(req *SystemInfoRequest) (*SystemInfo, []error) {
 	var errs []error
 
 	addErr := func(err error) {
+		// metrics that aren't supported on the current platform are simply left
+		// out rather than reported as errors on every update
+		if isNotImplementedError(err) {
+			return
+		}
+
 		errs = append(errs, err)
 	}
 
@@ -151,7 +158,7 @@ func Collect(req *SystemInfoRequest) (*SystemInfo, []error) {
 			cachedHostInfo = hostInfo
 			applyCachedHostInfo()
 		} else {
-			addErr(fmt.Errorf("getting host info: %v", err))
+			addErr(fmt.Errorf("getting host info: %w", err))
 		}
 	}
 
@@ -172,10 +179,10 @@ func Collect(req *SystemInfoRequest) (*SystemInfo, []error) {
 				info.CPU.Load15Percent = uint8(math.Min((loadAvg.Load15/float64(coreCount))*100, 100))
 			}
 		} else {
-			addErr(fmt.Errorf("getting load avg: %v", err))
+			addErr(fmt.Errorf("getting load avg: %w", err))
 		}
 	} else {
-		addErr(fmt.Errorf("getting core count: %v", err))
+		addErr(fmt.Errorf("getting core count: %w", err))
 	}
 
 	memory, err := mem.VirtualMemory()
@@ -185,7 +192,7 @@ func Collect(req *SystemInfoRequest) (*SystemInfo, []error) {
 		info.Memory.UsedMB = memory.Used / 1024 / 1024
 		info.Memory.UsedPercent = uint8(math.Min(memory.UsedPercent, 100))
 	} else {
-		addErr(fmt.Errorf("getting memory info: %v", err))
+		addErr(fmt.Errorf("getting memory info: %w", err))
 	}
 
 	swapMemory, err := mem.SwapMemory()
@@ -195,7 +202,7 @@ func Collect(req *SystemInfoRequest) (*SystemInfo, []error) {
 		info.Memory.SwapUsedMB = swapMemory.Used / 1024 / 1024
 		info.Memory.SwapUsedPercent = uint8(math.Min(swapMemory.UsedPercent, 100))
 	} else {
-		addErr(fmt.Errorf("getting swap memory info: %v", err))
+		addErr(fmt.Errorf("getting swap memory info: %w", err))
 	}
 
 	// currently disabled on Windows because it requires elevated privilidges, otherwise
@@ -205,6 +212,12 @@ func Collect(req *SystemInfoRequest) (*SystemInfo, []error) {
 	// also disabled on openbsd because it's not implemented by go-psutil
 	if runtime.GOOS != "windows" && runtime.GOOS != "openbsd" {
 		sensorReadings, err := sensors.SensorsTemperatures()
+		// some of the sensors failing to be read doesn't mean the rest are unusable
+		var warnings *sensors.Warnings
+		if errors.As(err, &warnings) && len(sensorReadings) > 0 {
+			err = nil
+		}
+
 		if err == nil {
 			if req.CPUTempSensor != "" {
 				for i := range sensorReadings {
@@ -223,7 +236,7 @@ func Collect(req *SystemInfoRequest) (*SystemInfo, []error) {
 				info.CPU.TemperatureC = uint8(cpuTempSensor.Temperature)
 			}
 		} else {
-			addErr(fmt.Errorf("getting sensor readings: %v", err))
+			addErr(fmt.Errorf("getting sensor readings: %w", err))
 		}
 	}
 
@@ -251,11 +264,11 @@ func Collect(req *SystemInfoRequest) (*SystemInfo, []error) {
 
 				info.Mountpoints = append(info.Mountpoints, mpInfo)
 			} else {
-				addErr(fmt.Errorf("getting filesystem usage for %s: %v", fs.Mountpoint, err))
+				addErr(fmt.Errorf("getting filesystem usage for %s: %w", fs.Mountpoint, err))
 			}
 		}
 	} else {
-		addErr(fmt.Errorf("getting filesystems: %v", err))
+		addErr(fmt.Errorf("getting filesystems: %w", err))
 	}
 
 	sort.Slice(info.Mountpoints, func(a, b int) bool {
@@ -265,6 +278,17 @@ func Collect(req *SystemInfoRequest) (*SystemInfo, []error) {
 	return info, errs
 }
 
+// The error gopsutil returns for unsupported platforms is part of an internal package
+func isNotImplementedError(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if err.Error() == "not implemented yet" {
+			return true
+		}
+	}
+
+	return false
+}
+
 func inferCPUTempSensor(sensors []sensors.TemperatureStat) *sensors.TemperatureStat {
 	for i := range sensors {
 		switch sensors[i].SensorKey {